@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// client 后端 API 客户端
+type client struct {
+	base string
+	http *http.Client
+}
+
+func newClient(server string) *client {
+	// 不设整体超时：导出与重新生成可能持续较长时间
+	return &client{base: strings.TrimSuffix(server, "/") + "/api/v1", http: &http.Client{}}
+}
+
+// apiError 后端返回的错误（{"error": {"code": ..., "message": ..., "fields": [...]}}）
+type apiError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Fields  []struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	} `json:"fields"`
+}
+
+func (e *apiError) Error() string {
+	msg := fmt.Sprintf("HTTP %d", e.Status)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	return msg + ": " + e.Message
+}
+
+// parseAPIError 解析错误响应体，非 JSON 时以原文作为错误信息
+func parseAPIError(status int, r io.Reader) *apiError {
+	raw, _ := io.ReadAll(io.LimitReader(r, 4096))
+	e := &apiError{Status: status}
+	var env struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(raw, &env) == nil && json.Unmarshal(env.Error, e) == nil && e.Message != "" {
+		return e
+	}
+	e.Message = strings.TrimSpace(string(raw))
+	return e
+}
+
+func (c *client) do(method, path string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, parseAPIError(resp.StatusCode, resp.Body)
+	}
+	return resp, nil
+}
+
+// getJSON 请求并解析 {"data": ...} 响应
+func (c *client) getJSON(path string, out interface{}) error {
+	resp, err := c.do(http.MethodGet, path, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeData(resp.Body, out)
+}
+
+// sendJSON 以 JSON 请求体发送并解析 {"data": ...} 响应
+func (c *client) sendJSON(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if r, ok := in.(io.Reader); ok {
+		body = r
+	} else {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	resp, err := c.do(method, path, body, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return decodeData(resp.Body, out)
+}
+
+func decodeData(r io.Reader, out interface{}) error {
+	var env struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(r).Decode(&env); err != nil {
+		return err
+	}
+	return json.Unmarshal(env.Data, out)
+}
+
+// sseEvent 服务端推送事件
+type sseEvent struct {
+	Name string
+	Data string
+}
+
+// stream 读取 SSE 响应，每个事件回调一次
+func (c *client) stream(path string, onEvent func(sseEvent) error) error {
+	req, err := http.NewRequest(http.MethodGet, c.base+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return parseAPIError(resp.StatusCode, resp.Body)
+	}
+
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64*1024), 4<<20)
+	var ev sseEvent
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if ev.Name != "" || ev.Data != "" {
+				if err := onEvent(ev); err != nil {
+					return err
+				}
+			}
+			ev = sseEvent{}
+		case strings.HasPrefix(line, "event:"):
+			ev.Name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if ev.Data != "" {
+				ev.Data += "\n"
+			}
+			ev.Data += strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+		}
+	}
+	if ev.Name != "" || ev.Data != "" {
+		if err := onEvent(ev); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// elapsed 格式化耗时
+func elapsed(start time.Time) string {
+	return time.Since(start).Round(100 * time.Millisecond).String()
+}
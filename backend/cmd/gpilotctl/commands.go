@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type session struct {
+	ID             string `json:"id"`
+	Title          string `json:"title"`
+	GeneratedDocID string `json:"generated_doc_id"`
+}
+
+type document struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+func (c *client) projectSessions(projectID string) ([]session, error) {
+	var sessions []session
+	err := c.getJSON("/sessions?project_id="+url.QueryEscape(projectID), &sessions)
+	return sessions, err
+}
+
+// ─────────────────────────────────────
+// export
+// ─────────────────────────────────────
+
+var exportExts = map[string]string{"md": ".md", "mdzip": ".zip", "json": ".json", "chrome-recorder": ".recording.json", "bpmn": ".bpmn"}
+
+func runExport(c *client, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	projectID := fs.String("project", "", "项目 ID")
+	format := fs.String("format", "md", "导出格式 md|mdzip|json|chrome-recorder|bpmn")
+	view := fs.String("view", "business", "视图 business|technical|both")
+	approved := fs.Bool("approved", false, "仅导出已审批的文档")
+	outDir := fs.String("o", ".", "输出目录")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *projectID == "" {
+		return errors.New("-project is required")
+	}
+	ext, ok := exportExts[*format]
+	if !ok {
+		return fmt.Errorf("unsupported format %q", *format)
+	}
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		return err
+	}
+
+	sessions, err := c.projectSessions(*projectID)
+	if err != nil {
+		return err
+	}
+	exported, skipped := 0, 0
+	for _, s := range sessions {
+		if s.GeneratedDocID == "" {
+			skipped++
+			continue
+		}
+		if *approved {
+			var doc document
+			if err := c.getJSON("/documents/"+s.GeneratedDocID, &doc); err != nil {
+				return fmt.Errorf("session %s: %w", s.ID, err)
+			}
+			if doc.Status != "approved" {
+				skipped++
+				continue
+			}
+		}
+		q := url.Values{"format": {*format}, "view": {*view}}
+		resp, err := c.do(http.MethodGet, "/documents/"+s.GeneratedDocID+"/export?"+q.Encode(), nil, "")
+		if err != nil {
+			return fmt.Errorf("session %s: %w", s.ID, err)
+		}
+		path := filepath.Join(*outDir, fileName(s.Title, s.ID)+ext)
+		err = writeFile(path, resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		fmt.Println("  ✓", path)
+		exported++
+	}
+	fmt.Printf("✅ exported %d document(s), skipped %d session(s)\n", exported, skipped)
+	return nil
+}
+
+// fileName 由会话标题生成安全的文件名，附带短 ID 避免重名
+func fileName(title, id string) string {
+	clean := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	if len([]rune(clean)) > 60 {
+		clean = string([]rune(clean)[:60])
+	}
+	short := strings.ReplaceAll(id, "-", "")
+	if len(short) > 8 {
+		short = short[:8]
+	}
+	if clean == "" {
+		return short
+	}
+	return clean + "-" + short
+}
+
+// writeFile 先写临时文件再改名，中断时不留下半个文件
+func writeFile(path string, r io.Reader) error {
+	tmp := path + ".part"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ─────────────────────────────────────
+// regenerate
+// ─────────────────────────────────────
+
+func runRegenerate(c *client, args []string) error {
+	fs := flag.NewFlagSet("regenerate", flag.ContinueOnError)
+	projectID := fs.String("project", "", "重新生成项目下全部会话")
+	sessionID := fs.String("session", "", "重新生成单个会话")
+	faq := fs.Bool("faq", false, "追加常见问题章节")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var ids []string
+	switch {
+	case *sessionID != "" && *projectID == "":
+		ids = []string{*sessionID}
+	case *projectID != "" && *sessionID == "":
+		sessions, err := c.projectSessions(*projectID)
+		if err != nil {
+			return err
+		}
+		for _, s := range sessions {
+			ids = append(ids, s.ID)
+		}
+	default:
+		return errors.New("exactly one of -project or -session is required")
+	}
+
+	failed := 0
+	for _, id := range ids {
+		start := time.Now()
+		docID, err := c.regenerate(id, *faq)
+		if err != nil {
+			// 单个会话失败不影响其余会话，最终以非零状态退出
+			fmt.Fprintf(os.Stderr, "  ✗ %s: %v\n", id, err)
+			failed++
+			continue
+		}
+		fmt.Printf("  ✓ %s → document %s (%s)\n", id, docID, elapsed(start))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d session(s) failed", failed, len(ids))
+	}
+	fmt.Printf("✅ regenerated %d session(s)\n", len(ids))
+	return nil
+}
+
+// regenerate 触发单个会话的文档生成并等待完成，返回新文档 ID
+func (c *client) regenerate(sessionID string, faq bool) (string, error) {
+	path := "/sessions/" + url.PathEscape(sessionID) + "/generate"
+	if faq {
+		path += "?faq=true"
+	}
+	var docID string
+	var stepErrors int
+	err := c.stream(path, func(ev sseEvent) error {
+		switch ev.Name {
+		case "progress":
+			var p struct {
+				Current, Total int
+				Error          string
+			}
+			if json.Unmarshal([]byte(ev.Data), &p) == nil && p.Error != "" {
+				stepErrors++
+			}
+		case "complete":
+			var done struct {
+				DocID string `json:"doc_id"`
+			}
+			if err := json.Unmarshal([]byte(ev.Data), &done); err != nil {
+				return err
+			}
+			docID = done.DocID
+		case "error":
+			var e apiError
+			if err := json.Unmarshal([]byte(ev.Data), &e); err != nil || e.Message == "" {
+				return errors.New(ev.Data)
+			}
+			return errors.New(e.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if docID == "" {
+		return "", errors.New("generation ended without a document")
+	}
+	if stepErrors > 0 {
+		fmt.Fprintf(os.Stderr, "    %s: %d step(s) failed and kept their previous description\n", sessionID, stepErrors)
+	}
+	return docID, nil
+}
+
+// ─────────────────────────────────────
+// bundle / import
+// ─────────────────────────────────────
+
+func runBundle(c *client, args []string) error {
+	fs := flag.NewFlagSet("bundle", flag.ContinueOnError)
+	projectID := fs.String("project", "", "项目 ID")
+	out := fs.String("o", "", "输出文件（默认 project-<ID>.json）")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *projectID == "" {
+		return errors.New("-project is required")
+	}
+	if *out == "" {
+		*out = "project-" + *projectID + ".json"
+	}
+	resp, err := c.do(http.MethodGet, "/projects/"+url.PathEscape(*projectID)+"/bundle", nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := writeFile(*out, resp.Body); err != nil {
+		return err
+	}
+	fmt.Println("✅ bundle written:", *out)
+	return nil
+}
+
+func runImport(c *client, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	name := fs.String("name", "", "导入后的项目名称（默认沿用包内名称）")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("bundle file is required")
+	}
+
+	var r io.Reader = os.Stdin
+	if fs.Arg(0) != "-" {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	if *name != "" {
+		// 需要改名时才整体解析，否则直接流式上传
+		var bundle map[string]json.RawMessage
+		if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+			return fmt.Errorf("invalid bundle: %w", err)
+		}
+		var project map[string]interface{}
+		if err := json.Unmarshal(bundle["project"], &project); err != nil {
+			return fmt.Errorf("invalid bundle project: %w", err)
+		}
+		project["name"] = *name
+		bundle["project"], _ = json.Marshal(project)
+		b, _ := json.Marshal(bundle)
+		r = strings.NewReader(string(b))
+	}
+
+	var result struct {
+		Project struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"project"`
+		Sessions int `json:"sessions"`
+	}
+	if err := c.sendJSON(http.MethodPost, "/projects/import", r, &result); err != nil {
+		return err
+	}
+	fmt.Printf("✅ imported project %q (%s) with %d session(s)\n", result.Project.Name, result.Project.ID, result.Sessions)
+	return nil
+}
+
+// ─────────────────────────────────────
+// rotate-key
+// ─────────────────────────────────────
+
+type llmProvider struct {
+	Name      string `json:"name"`
+	IsDefault bool   `json:"is_default"`
+}
+
+func runRotateKey(c *client, args []string) error {
+	fs := flag.NewFlagSet("rotate-key", flag.ContinueOnError)
+	provider := fs.String("provider", "", "提供商名称")
+	keyEnv := fs.String("key-env", "", "从该环境变量读取新 Key（默认读取标准输入第一行）")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *provider == "" {
+		return errors.New("-provider is required")
+	}
+
+	var key string
+	if *keyEnv != "" {
+		key = os.Getenv(*keyEnv)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		key = line
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return errors.New("new API key is empty")
+	}
+
+	// 更新接口会覆盖 is_default，先读取当前值以保持默认提供商不变
+	var providers []llmProvider
+	if err := c.getJSON("/llm/providers", &providers); err != nil {
+		return err
+	}
+	var current *llmProvider
+	for i := range providers {
+		if providers[i].Name == *provider {
+			current = &providers[i]
+		}
+	}
+	if current == nil {
+		return fmt.Errorf("provider %q is not configured", *provider)
+	}
+
+	body := map[string]interface{}{"name": current.Name, "api_key": key, "is_default": current.IsDefault}
+	if err := c.sendJSON(http.MethodPut, "/llm/providers", body, nil); err != nil {
+		return err
+	}
+	fmt.Printf("✅ rotated API key for %s\n", current.Name)
+	return nil
+}
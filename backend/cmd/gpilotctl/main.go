@@ -0,0 +1,74 @@
+// gpilotctl 通过 HTTP API 执行无界面运维操作，供定时任务等脚本使用
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const usage = `用法：
+  gpilotctl [-server URL] <命令> [参数]
+
+全局参数：
+  -server URL     后端地址（默认环境变量 GPILOT_SERVER，否则 http://localhost:3210）
+
+命令：
+  export -project ID [-format md|mdzip|json|chrome-recorder|bpmn] [-view business|technical|both] [-approved] [-o DIR]
+                  导出项目下全部文档到目录（默认当前目录）
+  regenerate (-project ID | -session ID) [-faq]
+                  重新生成文档（逐个会话执行，输出进度）
+  bundle -project ID [-o FILE]
+                  下载项目包（JSON，含会话、步骤、截图、文档）
+  import [-name NAME] FILE
+                  导入项目包为新项目（FILE 为 - 时从标准输入读取）
+  rotate-key -provider NAME [-key-env VAR]
+                  轮换模型提供商 API Key（从环境变量 VAR 或标准输入读取，避免出现在命令行历史中）
+`
+
+func main() {
+	global := flag.NewFlagSet("gpilotctl", flag.ContinueOnError)
+	global.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	server := global.String("server", defaultServer(), "后端地址")
+	if err := global.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+	args := global.Args()
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	c := newClient(*server)
+	var err error
+	switch args[0] {
+	case "export":
+		err = runExport(c, args[1:])
+	case "regenerate":
+		err = runRegenerate(c, args[1:])
+	case "bundle":
+		err = runBundle(c, args[1:])
+	case "import":
+		err = runImport(c, args[1:])
+	case "rotate-key":
+		err = runRotateKey(c, args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		err = fmt.Errorf("unknown command %q", args[0])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+}
+
+func defaultServer() string {
+	if v := os.Getenv("GPILOT_SERVER"); v != "" {
+		return strings.TrimSuffix(v, "/")
+	}
+	return "http://localhost:3210"
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/gpilot/backend/internal/config"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+const usage = `用法：
+  gpilot-server [-config FILE] [命令]
+                                    -config 指定 YAML/TOML 配置文件（或环境变量 GPILOT_CONFIG），
+                                    环境变量优先于配置文件
+  gpilot-server                     启动 HTTP 服务
+  gpilot-server migrate [up]        执行所有未应用的迁移
+  gpilot-server migrate down [N]    回滚最近 N 个迁移（默认 1）
+  gpilot-server migrate status      查看迁移状态
+  gpilot-server backup [-o FILE]    备份数据库与截图存储（默认写入存储目录 backups/）
+  gpilot-server restore [-verify] FILE
+                                    从备份归档恢复（-verify 仅校验不恢复）
+  gpilot-server seal                用配置的加密密钥加密启用加密前保存的截图与文档
+  gpilot-server seed-demo           导入示例项目与录制会话，无需浏览器插件即可体验文档生成
+`
+
+// runCommand 执行子命令，执行完毕后进程退出
+func runCommand(cfg *config.Config, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(cfg, args[1:])
+	case "backup":
+		return runBackup(cfg, args[1:])
+	case "restore":
+		return runRestore(cfg, args[1:])
+	case "seal":
+		return runSeal(cfg)
+	case "seed-demo":
+		return runSeedDemo(cfg)
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+func runMigrate(cfg *config.Config, args []string) error {
+	if err := db.Open(cfg.DB); err != nil {
+		return err
+	}
+
+	sub := "up"
+	if len(args) > 0 {
+		sub = args[0]
+	}
+	switch sub {
+	case "up":
+		if err := db.Migrate(db.DB); err != nil {
+			return err
+		}
+		fmt.Println("✅ migrations applied")
+		return nil
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+			steps = n
+		}
+		if err := db.Rollback(db.DB, steps); err != nil {
+			return err
+		}
+		fmt.Printf("✅ rolled back %d migration(s)\n", steps)
+		return nil
+	case "status":
+		states, err := db.MigrationStatus(db.DB)
+		if err != nil {
+			return err
+		}
+		for _, st := range states {
+			mark := "pending"
+			if st.Applied {
+				mark = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("  %-40s %s\n", st.Version, mark)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate subcommand %q (up|down|status)", sub)
+	}
+}
+
+func runBackup(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	out := fs.String("o", "", "输出文件路径")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := initDB(cfg); err != nil {
+		return err
+	}
+	svc := service.NewBackupService(cfg.Storage.Path)
+
+	if *out == "" {
+		name, manifest, err := svc.CreateBackupFile()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ backup written: %s/%s (%d tables, %d files)\n",
+			svc.BackupDir(), name, len(manifest.Tables), len(manifest.Files))
+		return nil
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	manifest, err := svc.WriteBackup(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(*out)
+		return err
+	}
+	fmt.Printf("✅ backup written: %s (%d tables, %d files)\n", *out, len(manifest.Tables), len(manifest.Files))
+	return nil
+}
+
+func runRestore(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	verifyOnly := fs.Bool("verify", false, "仅校验归档完整性")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("backup file is required")
+	}
+	if err := initDB(cfg); err != nil {
+		return err
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	svc := service.NewBackupService(cfg.Storage.Path)
+	if *verifyOnly {
+		manifest, err := svc.VerifyBackup(f, info.Size())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ backup verified (schema %s, created %s)\n", manifest.SchemaVersion, manifest.CreatedAt)
+		return nil
+	}
+	manifest, err := svc.RestoreBackup(f, info.Size())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ restored backup from %s (schema %s)\n", manifest.CreatedAt, manifest.SchemaVersion)
+	return nil
+}
+
+func runSeal(cfg *config.Config) error {
+	if err := initDB(cfg); err != nil {
+		return err
+	}
+	if !db.EncryptionEnabled() {
+		return fmt.Errorf("storage.encryption_key or storage.encryption_key_file is required")
+	}
+	n, err := db.SealExisting(db.DB)
+	if err != nil {
+		return err
+	}
+	files, err := service.SealMediaFiles(cfg.Storage.Path)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ sealed %d row(s), %d media file(s)\n", n, files)
+	return nil
+}
+
+func runSeedDemo(cfg *config.Config) error {
+	if err := initDB(cfg); err != nil {
+		return err
+	}
+	result, err := service.SeedDemo()
+	if errors.Is(err, service.ErrDemoSeeded) {
+		fmt.Printf("ℹ️  demo project already exists: %s\n", result.ProjectID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ demo project %s seeded: session %s with %d steps\n", result.ProjectID, result.SessionID, result.Steps)
+	return nil
+}
+
+// initDB 设置截图加密密钥并初始化数据库
+func initDB(cfg *config.Config) error {
+	key, err := cfg.Storage.EncryptionKeyBytes()
+	if err != nil {
+		return err
+	}
+	if err := db.SetEncryptionKey(key); err != nil {
+		return err
+	}
+	return db.Init(cfg.DB)
+}
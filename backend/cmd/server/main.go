@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"net"
+	"os"
 
 	"github.com/gpilot/backend/internal/api"
 	"github.com/gpilot/backend/internal/config"
@@ -10,21 +14,79 @@ import (
 )
 
 func main() {
-	// 加载配置
-	cfg := config.Load()
+	// 加载配置：-config 或 GPILOT_CONFIG 指定 YAML/TOML 配置文件，环境变量优先于文件
+	configPath := flag.String("config", os.Getenv("GPILOT_CONFIG"), "配置文件路径（.yaml/.yml/.toml）")
+	flag.Usage = func() { os.Stderr.WriteString(usage) }
+	flag.Parse()
+	cfg, err := config.LoadFrom(*configPath)
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+
+	// 子命令模式（migrate 等），执行完即退出
+	if args := flag.Args(); len(args) > 0 {
+		if err := runCommand(cfg, args); err != nil {
+			log.Fatalf("%s: %v", args[0], err)
+		}
+		return
+	}
 
 	// 初始化数据库
-	if err := db.Init(cfg.DB.Path); err != nil {
+	if err := initDB(cfg); err != nil {
 		log.Fatalf("failed to init db: %v", err)
 	}
-	log.Println("✅ Database initialized:", cfg.DB.Path)
+	if db.EncryptionEnabled() {
+		log.Println("🔒 Screenshot encryption at rest enabled")
+	}
+	if cfg.DB.Driver == "" || cfg.DB.Driver == "sqlite" {
+		log.Println("✅ Database initialized:", cfg.DB.Path)
+	} else {
+		log.Println("✅ Database initialized:", cfg.DB.Driver)
+	}
 
 	// 初始化服务
 	aiService := service.NewAIService(&cfg.LLM)
 	docService := service.NewDocService()
 	api.SetServices(aiService, docService)
+	api.SetConfig(cfg)
+
+	// 截图文字识别
+	service.ConfigureOCR(cfg.OCR)
+	if service.OCREnabled() {
+		log.Printf("🔤 Screenshot OCR enabled (%s, %s)", cfg.OCR.Command, cfg.OCR.Languages)
+	}
+
+	// 语义检索
+	service.ConfigureEmbedding(cfg.Embedding, aiService)
+	if model := service.EmbeddingModel(); model != "" {
+		log.Printf("🧭 Semantic search enabled (%s)", model)
+	}
+
+	// 步骤回放
+	service.ConfigureReplay(cfg.Replay)
+	if n, err := service.FailInterruptedReplays(); err != nil {
+		log.Printf("⚠️  Failed to close interrupted replays: %v", err)
+	} else if n > 0 {
+		log.Printf("⚠️  Marked %d interrupted replay(s) as failed", n)
+	}
+	if service.ReplayEnabled() {
+		log.Printf("🎬 Step replay enabled (%s)", cfg.Replay.ChromePath)
+	}
+
+	// 后台导出
+	if n, err := service.FailInterruptedExports(); err != nil {
+		log.Printf("⚠️  Failed to close interrupted exports: %v", err)
+	} else if n > 0 {
+		log.Printf("⚠️  Marked %d interrupted export(s) as failed", n)
+	}
+
+	// 数据保留策略后台清理
+	service.NewRetentionService(cfg.Retention.Interval, cfg.Storage.Path).Start(context.Background())
 
 	// 打印 VLM 提供商状态
+	if cfg.LLM.LocalOnly {
+		log.Println("🔌 Local-only mode: external VLM providers disabled")
+	}
 	log.Println("📡 VLM Provider Status (Free-First Chain):")
 	for _, p := range aiService.GetProvidersStatus() {
 		status := "❌ Not configured"
@@ -41,11 +103,25 @@ func main() {
 	// 启动路由
 	r := api.SetupRouter()
 
-	addr := ":" + cfg.Server.Port
-	log.Printf("🚀 G-Pilot Backend started on http://localhost%s", addr)
-	log.Println("📖 API Docs: http://localhost" + addr + "/health")
+	scheme := "http"
+	if cfg.Server.TLSEnabled() {
+		scheme = "https"
+	}
+	host := cfg.Server.Host
+	if host == "" {
+		host = "localhost"
+	}
+	if domains := cfg.Server.Domains(); len(domains) > 0 {
+		host = domains[0]
+	}
+	base := scheme + "://" + net.JoinHostPort(host, cfg.Server.Port)
+	log.Printf("🚀 G-Pilot Backend started on %s (listening on %s)", base, cfg.Server.Addr())
+	log.Println("📖 API Docs: " + base + "/health")
+	if cfg.Server.HTTPRedirectPort != "" {
+		log.Printf("↪️  HTTP on port %s redirects to HTTPS", cfg.Server.HTTPRedirectPort)
+	}
 
-	if err := r.Run(addr); err != nil {
+	if err := serve(cfg, r); err != nil {
 		log.Fatalf("server error: %v", err)
 	}
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gpilot/backend/internal/config"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// serve 按配置以 HTTP 或 HTTPS 启动服务，阻塞直到任一监听出错
+func serve(cfg *config.Config, handler http.Handler) error {
+	s := cfg.Server
+	srv := &http.Server{
+		Addr:              s.Addr(),
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if !s.TLSEnabled() {
+		return srv.ListenAndServe()
+	}
+
+	redirect := httpsRedirect(s.Port)
+	if len(s.Domains()) > 0 {
+		cacheDir := s.AutocertCacheDir
+		if cacheDir == "" {
+			cacheDir = filepath.Join(cfg.Storage.Path, "autocert")
+		}
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(s.Domains()...),
+			Cache:      autocert.DirCache(cacheDir),
+			Email:      s.AutocertEmail,
+		}
+		// 主端口走 TLS-ALPN-01 验证；配置了跳转端口时同时支持 HTTP-01
+		srv.TLSConfig = m.TLSConfig()
+		redirect = m.HTTPHandler(redirect)
+	}
+
+	errc := make(chan error, 2)
+	if s.HTTPRedirectPort != "" {
+		go func() {
+			rs := &http.Server{
+				Addr:              net.JoinHostPort(s.Host, s.HTTPRedirectPort),
+				Handler:           redirect,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			errc <- rs.ListenAndServe()
+		}()
+	}
+	go func() {
+		// autocert 时证书由 TLSConfig 提供，文件路径为空
+		errc <- srv.ListenAndServeTLS(s.TLSCertFile, s.TLSKeyFile)
+	}()
+	return <-errc
+}
+
+// httpsRedirect 将 HTTP 请求永久跳转到同一主机的 HTTPS 端口（保留方法与请求体）
+func httpsRedirect(tlsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if tlsPort != "443" {
+			host = net.JoinHostPort(host, tlsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
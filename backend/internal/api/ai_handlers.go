@@ -2,11 +2,19 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gpilot/backend/internal/db"
 	"github.com/gpilot/backend/internal/service"
+	"gorm.io/gorm"
 )
 
 var aiSvc *service.AIService
@@ -20,57 +28,98 @@ func SetServices(ai *service.AIService, doc *service.DocService) {
 // GetProvidersStatus VLM 提供商状态查询
 func GetProvidersStatus(c *gin.Context) {
 	statuses := aiSvc.GetProvidersStatus()
-	c.JSON(http.StatusOK, gin.H{"data": statuses})
+	respond(c, http.StatusOK, statuses)
 }
 
-// GenerateStepDescription 单步骤 AI 描述生成（同步）
+// GetValidationFailures 最近的模型输出格式校验失败记录（?provider= 按类型过滤，?limit= 默认 50，最多 500）
+func GetValidationFailures(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		failValidation(c, "limit", "limit must be 1-500")
+		return
+	}
+	respond(c, http.StatusOK, service.ValidationFailures(c.Query("provider"), limit))
+}
+
+// aiFor 会话使用的 AI 服务：?free_only=true 或所属项目开启仅免费生成时只使用免费提供商；
+// 带上所属项目的术语表与禁用词
+func aiFor(c *gin.Context, sessionID string) *service.AIService {
+	ai := aiSvc
+	if free, _ := strconv.ParseBool(c.Query("free_only")); free || service.ProjectFreeOnly(sessionID) {
+		ai = ai.FreeOnly()
+	}
+	return ai.WithGlossary(service.SessionGlossary(sessionID)).WithBannedPhrases(service.SessionBannedPhrases(sessionID))
+}
+
+// GenerateStepDescription 单步骤 AI 描述生成（同步）；
+// ?provider=openai&model=gpt-4o 时跳过免费优先路由链，直接用指定模型重新生成；
+// ?free_only=true 时只使用免费提供商
 func GenerateStepDescription(c *gin.Context) {
 	stepID := c.Param("stepId")
 	var step db.RecordingStep
 	if err := db.DB.First(&step, "id = ?", stepID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "step not found"})
+		failNotFound(c, "step")
 		return
 	}
-
-	var screenshot db.Screenshot
-	var screenshotB64 string
-	if step.ScreenshotID != "" {
-		db.DB.First(&screenshot, "id = ?", step.ScreenshotID)
-		screenshotB64 = screenshot.DataURL
-	}
-
-	req := service.VLMRequest{
-		StepAction:    step.Action,
-		TargetElement: step.TargetElement,
-		PageURL:       step.PageURL,
-		PageTitle:     step.PageTitle,
-		MaskedText:    step.MaskedText,
-		ScreenshotB64: screenshotB64,
+	ai := aiFor(c, step.SessionID)
+
+	req := service.StepVLMRequest(&step)
+	req.PreviousSteps = ai.PreviousDescriptions(&step)
+
+	var resp *service.VLMResponse
+	var err error
+	if provider := c.Query("provider"); provider != "" {
+		resp, err = ai.GenerateWithProvider(req, provider, c.Query("model"))
+		if errors.Is(err, service.ErrUnknownProvider) {
+			failValidation(c, "provider", err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrProviderDisabled) || errors.Is(err, service.ErrPaidProviderBlocked) {
+			fail(c, http.StatusForbidden, ErrCodeForbidden, err.Error())
+			return
+		}
+		if errors.Is(err, service.ErrQuotaReached) {
+			fail(c, http.StatusTooManyRequests, ErrCodeQuotaExceeded, err.Error())
+			return
+		}
+		if err != nil {
+			fail(c, http.StatusBadGateway, ErrCodeUpstream, err.Error())
+			return
+		}
+	} else if resp, err = ai.GenerateStepDescription(req); err != nil {
+		failInternal(c, err)
+		return
 	}
 
-	resp, err := aiSvc.GenerateStepDescription(req)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// 保存描述及生成来源到步骤
+	if err := service.SaveStepDescription(&step, resp); err != nil {
+		failInternal(c, err)
 		return
 	}
 
-	// 保存描述到步骤
-	db.DB.Model(&step).Update("ai_description", resp.Description)
-
-	c.JSON(http.StatusOK, gin.H{
+	respond(c, http.StatusOK, gin.H{
 		"description": resp.Description,
+		"title":       resp.Title,
 		"provider":    resp.Provider,
+		"label":       resp.Label,
+		"model":       resp.Model,
+		"latency_ms":  resp.LatencyMS,
 		"is_free":     resp.UsedFree,
+		"banned_hits": resp.BannedHits, // 重试后仍命中的禁用词，需人工修改
+		"invalid":     resp.Invalid,    // 指定提供商时纠正重问后仍未通过的格式校验项
+		"text_only":   resp.TextOnly,   // 未发送截图，仅依据操作元数据生成
 	})
 }
 
-// GenerateDoc 为整个 session 批量生成文档（SSE 流式进度）
+// GenerateDoc 为整个 session 批量生成文档（SSE 流式进度）；?faq=true 时追加常见问题章节，
+// ?free_only=true 时只使用免费提供商
 func GenerateDoc(c *gin.Context) {
 	sessionID := c.Param("id")
+	withFAQ := c.Query("faq") == "true"
 
 	var session db.Session
 	if err := db.DB.First(&session, "id = ?", sessionID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		failNotFound(c, "session")
 		return
 	}
 
@@ -81,9 +130,10 @@ func GenerateDoc(c *gin.Context) {
 	c.Header("X-Accel-Buffering", "no")
 
 	progressCh := make(chan service.DocGenerateProgress, 20)
+	ai := aiFor(c, sessionID)
 
 	go func() {
-		_ = aiSvc.GenerateDocForSession(sessionID, progressCh)
+		_ = ai.GenerateDocForSession(sessionID, progressCh)
 	}()
 
 	for progress := range progressCh {
@@ -92,27 +142,41 @@ func GenerateDoc(c *gin.Context) {
 		c.Writer.Flush()
 
 		if progress.Done {
-			// 生成文档内容并保存
-			content, err := docSvc.BuildDocument(sessionID)
-			if err == nil {
-				doc, err := docSvc.SaveGeneratedDoc(sessionID, content)
-				if err == nil {
-					db.DB.Model(&session).Update("status", "completed")
-					finalData, _ := json.Marshal(map[string]string{"doc_id": doc.ID})
-					c.SSEvent("complete", string(finalData))
-					c.Writer.Flush()
-				}
+			// 生成文档内容并保存；失败时以 error 事件返回统一格式的错误体
+			doc, err := buildAndSaveDoc(ai, sessionID, withFAQ)
+			if err != nil {
+				errData, _ := json.Marshal(ErrorBody{Code: ErrCodeInternal, Message: err.Error()})
+				c.SSEvent("error", string(errData))
+				c.Writer.Flush()
+				break
 			}
+			db.DB.Model(&session).Update("status", "completed")
+			finalData, _ := json.Marshal(map[string]string{"doc_id": doc.ID})
+			c.SSEvent("complete", string(finalData))
+			c.Writer.Flush()
 			break
 		}
 	}
 }
 
-// GetDocument 获取已生成的文档
+func buildAndSaveDoc(ai *service.AIService, sessionID string, withFAQ bool) (*db.GeneratedDocument, error) {
+	content, err := docSvc.BuildDocument(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	ai.EnrichSections(content)
+	ai.InsertOverview(content)
+	if withFAQ {
+		_ = ai.AppendFAQ(content)
+	}
+	return docSvc.SaveGeneratedDoc(sessionID, content)
+}
+
+// GetDocument 获取已生成的文档，带 ETag，未变化时返回 304
 func GetDocument(c *gin.Context) {
 	var doc db.GeneratedDocument
 	if err := db.DB.First(&doc, "id = ?", c.Param("docId")).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		failNotFound(c, "document")
 		return
 	}
 
@@ -120,54 +184,329 @@ func GetDocument(c *gin.Context) {
 	_ = json.Unmarshal([]byte(doc.BusinessView), &bizView)
 	_ = json.Unmarshal([]byte(doc.TechnicalView), &techView)
 
-	c.JSON(http.StatusOK, gin.H{
-		"data": map[string]interface{}{
-			"id":             doc.ID,
-			"session_id":     doc.SessionID,
-			"project_id":     doc.ProjectID,
-			"status":         doc.Status,
-			"created_at":     doc.CreatedAt,
-			"business_view":  bizView,
-			"technical_view": techView,
-		},
-	})
+	respondETag(c, gin.H{
+		"id":             doc.ID,
+		"session_id":     doc.SessionID,
+		"project_id":     doc.ProjectID,
+		"status":         doc.Status,
+		"metadata":       doc.Metadata,
+		"created_at":     doc.CreatedAt,
+		"business_view":  bizView,
+		"technical_view": techView,
+	}, nil)
 }
 
-// ExportDocument 导出文档（md/json）
+// UpdateDocumentStatus 更新文档审批状态（draft | approved）
+func UpdateDocumentStatus(c *gin.Context) {
+	var req struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	var v checks
+	v.oneOf("status", req.Status, service.DocumentStatuses)
+	if v.failed(c) {
+		return
+	}
+
+	var doc db.GeneratedDocument
+	if err := db.DB.First(&doc, "id = ?", c.Param("docId")).Error; err != nil {
+		failNotFound(c, "document")
+		return
+	}
+
+	// 业务视图含禁用词时不能审批，需先人工修改
+	if req.Status == "approved" {
+		content, err := docSvc.LoadDocument(&doc)
+		if err != nil {
+			failInternal(c, err)
+			return
+		}
+		if hits := service.DocumentBannedHits(content, service.ProjectBannedPhrases(doc.ProjectID)); len(hits) > 0 {
+			fields := make([]FieldError, len(hits))
+			for i, h := range hits {
+				field := fmt.Sprintf("sections[%d]", h.Section)
+				if h.StepIndex > 0 {
+					field = fmt.Sprintf("steps[%d]", h.StepIndex)
+				}
+				fields[i] = FieldError{Field: field, Message: "contains banned phrases: " + strings.Join(h.Phrases, ", ")}
+			}
+			fail(c, http.StatusConflict, ErrCodeConflict, "document contains banned phrases; edit them before approving", fields...)
+			return
+		}
+	}
+
+	updates := map[string]interface{}{"status": req.Status, "approved_at": nil}
+	if req.Status == "approved" {
+		now := time.Now()
+		updates["approved_at"] = &now
+	}
+	if err := db.DB.Model(&doc).Updates(updates).Error; err != nil {
+		failInternal(c, err)
+		return
+	}
+	db.DB.First(&doc, "id = ?", doc.ID)
+	respond(c, http.StatusOK, gin.H{"id": doc.ID, "status": doc.Status, "approved_at": doc.ApprovedAt})
+}
+
+// UpdateDocumentMetadata 整体替换文档自定义字段（未设置的字段沿用项目默认值）
+func UpdateDocumentMetadata(c *gin.Context) {
+	var doc db.GeneratedDocument
+	if err := db.DB.First(&doc, "id = ?", c.Param("docId")).Error; err != nil {
+		failNotFound(c, "document")
+		return
+	}
+	meta, ok := bindMetadata(c)
+	if !ok {
+		return
+	}
+	if err := db.DB.Model(&doc).Update("metadata", meta).Error; err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"id": doc.ID, "metadata": meta})
+}
+
+// ExportDocument 导出文档（md/mdzip/json/chrome-recorder/bpmn）；view=both 时业务说明后附技术附录
 func ExportDocument(c *gin.Context) {
 	docID := c.Param("docId")
-	format := c.Query("format") // md|json
+	format := c.Query("format") // md|mdzip|json|chrome-recorder|bpmn
 	viewType := c.Query("view") // business|technical|both
 
 	if format == "" {
-		format = "md"
-	}
-	if viewType == "" {
-		viewType = "business"
+		format = service.CurrentSettings().DefaultExportFormat
 	}
 
-	var session db.Session
-	if err := db.DB.First(&session, "generated_doc_id = ?", docID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "doc not found"})
+	var doc db.GeneratedDocument
+	if err := db.DB.First(&doc, "id = ?", docID).Error; err != nil {
+		failNotFound(c, "document")
 		return
 	}
 
-	content, err := docSvc.BuildDocument(session.ID)
+	content, err := docSvc.LoadDocument(&doc)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		failInternal(c, err)
+		return
+	}
+	content, viewType, ok := applyExportOptions(c, content, viewType)
+	if !ok {
+		return
+	}
+	// ?flowchart=true 在 Markdown 正文前插入页面跳转的 Mermaid 流程图
+	if v, _ := strconv.ParseBool(c.Query("flowchart")); v {
+		graph, err := service.SessionFlow(db.DB, doc.SessionID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			failInternal(c, err)
+			return
+		}
+		if graph != nil {
+			content.Flowchart = graph.Mermaid()
+		}
+	}
+	// ?filename= 自定义下载文件名（不含扩展名），默认 manual
+	filename := c.Query("filename")
+
+	if writeManual(c, content, format, viewType, filename) {
 		return
 	}
+	switch format {
+	case "chrome-recorder":
+		// 技术视图的步骤，可直接导入 DevTools Recorder 回放
+		rec, err := service.ChromeRecordingForDocument(&doc, content)
+		if err != nil {
+			failInternal(c, err)
+			return
+		}
+		c.Header("Content-Disposition", attachment(service.ExportFilename(filename, "recording", ".json")))
+		c.JSON(http.StatusOK, rec)
+	case "bpmn":
+		// 会话流程图转换的 BPMN 2.0 XML（页面为泳道，步骤为用户任务），供 BPM 建模工具导入
+		graph, err := service.SessionFlow(db.DB, doc.SessionID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			failNotFound(c, "session")
+			return
+		}
+		if err != nil {
+			failInternal(c, err)
+			return
+		}
+		data, err := service.ExportBPMN(graph)
+		if err != nil {
+			failInternal(c, err)
+			return
+		}
+		c.Header("Content-Disposition", attachment(service.ExportFilename(filename, "process", ".bpmn")))
+		c.Data(http.StatusOK, "application/xml; charset=utf-8", data)
+	default:
+		failValidation(c, "format", "format must be one of: md, mdzip, json, chrome-recorder, bpmn")
+	}
+}
+
+// applyExportOptions 按查询参数调整导出内容（视图、耗时提示、编号、遮蔽、截图、头部信息），
+// 单篇文档与合订手册共用；参数无效时已返回 400，ok 为 false
+func applyExportOptions(c *gin.Context, content *service.GeneratedDocContent, viewType string) (*service.GeneratedDocContent, string, bool) {
+	opts, ok := parseExportOptions(c)
+	if !ok {
+		return nil, "", false
+	}
+	content, viewType = opts.apply(content, viewType)
+	return content, viewType, true
+}
+
+// exportOptions 导出查询参数，先在请求中校验，后台导出任务在执行时才应用到文档内容
+type exportOptions struct {
+	timing      *bool  // ?timing=true|false 覆盖项目的耗时提示设置
+	numbering   string // ?numbering=step|hierarchical|english 覆盖项目的编号样式
+	redactStyle string // 烧录遮蔽区域的方式，空表示导出原图
+	screenshots string // ?screenshots=full|thumbnail|none
+	metadata    *bool  // ?metadata=false 省略 Markdown 头部的项目、生成时间与自定义字段
+}
+
+// parseExportOptions 读取并校验导出查询参数；参数无效时已返回 400，ok 为 false
+func parseExportOptions(c *gin.Context) (exportOptions, bool) {
+	var opts exportOptions
+	if v, err := strconv.ParseBool(c.Query("timing")); err == nil {
+		opts.timing = &v
+	}
+	if v := c.Query("numbering"); v != "" {
+		if !service.OneOf(v, service.NumberingStyles) {
+			failValidation(c, "numbering", "numbering must be one of: step, hierarchical, english")
+			return opts, false
+		}
+		opts.numbering = v
+	}
+	// 默认烧录截图中的遮蔽区域；?redact=false 导出原图（仅供内部核对），?redact_style=pixelate 改用马赛克
+	if redact, err := strconv.ParseBool(c.DefaultQuery("redact", "true")); err != nil || redact {
+		opts.redactStyle = c.DefaultQuery("redact_style", service.RedactBlack)
+		if !service.OneOf(opts.redactStyle, service.RedactStyles) {
+			failValidation(c, "redact_style", "redact_style must be one of: black, pixelate")
+			return opts, false
+		}
+	}
+	opts.screenshots = c.DefaultQuery("screenshots", service.ScreenshotsFull)
+	if !service.OneOf(opts.screenshots, service.ScreenshotModes) {
+		failValidation(c, "screenshots", "screenshots must be one of: full, thumbnail, none")
+		return opts, false
+	}
+	if v, err := strconv.ParseBool(c.Query("metadata")); err == nil {
+		opts.metadata = &v
+	}
+	return opts, true
+}
+
+// apply 按导出参数调整文档内容；未指定视图时使用项目模板的默认视图
+func (o exportOptions) apply(content *service.GeneratedDocContent, viewType string) (*service.GeneratedDocContent, string) {
+	if viewType == "" {
+		viewType = service.TemplateView(content.Template)
+	}
+	if o.timing != nil {
+		content.ShowTiming = *o.timing
+	}
+	if o.numbering != "" {
+		content.Numbering = o.numbering
+	}
+	if o.redactStyle != "" {
+		content = docSvc.RedactContent(content, o.redactStyle)
+	}
+	// 截图在遮蔽之后处理
+	content = docSvc.ScreenshotContent(content, o.screenshots)
+	if o.metadata != nil {
+		content.HideHeader = !*o.metadata
+	}
+	return content, viewType
+}
 
+// writeManual 以 md / mdzip / json 格式输出文档内容；format 不是这三种时不输出并返回 false
+func writeManual(c *gin.Context, content *service.GeneratedDocContent, format, viewType, filename string) bool {
 	switch format {
 	case "md":
 		md := docSvc.GenerateMarkdown(content, viewType)
-		c.Header("Content-Disposition", `attachment; filename="manual.md"`)
+		c.Header("Content-Disposition", attachment(service.ExportFilename(filename, "manual", ".md")))
 		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(md))
+	case "mdzip":
+		// Markdown + images/ 目录，图片以相对路径引用
+		c.Header("Content-Disposition", attachment(service.ExportFilename(filename, "manual", ".zip")))
+		c.Header("Content-Type", "application/zip")
+		if err := docSvc.WriteMarkdownZip(c.Writer, content, viewType); err != nil {
+			c.Error(err)
+		}
 	case "json":
-		c.JSON(http.StatusOK, gin.H{"data": content})
+		respond(c, http.StatusOK, content)
 	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format"})
+		return false
 	}
+	return true
+}
+
+// attachment 构造下载响应的 Content-Disposition，非 ASCII 文件名按 RFC 2231 编码
+func attachment(filename string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+}
+
+// ExportProjectSite 将项目已审批文档打包为静态站点 zip 下载
+func ExportProjectSite(c *gin.Context) {
+	files, ok := buildProjectSite(c)
+	if !ok {
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="site.zip"`)
+	c.Header("Content-Type", "application/zip")
+	if err := service.WriteSiteZip(c.Writer, files); err != nil {
+		c.Error(err)
+	}
+}
+
+// GetProjectDocIndex 项目的跨文档目录：所有已审批文档的摘要与深链接，?format=md 下载 Markdown 版本
+func GetProjectDocIndex(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "md" {
+		failValidation(c, "format", "format must be one of: json, md")
+		return
+	}
+	idx, err := docSvc.ProjectDocIndex(c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		failNotFound(c, "project")
+		return
+	}
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	if format == "md" {
+		c.Header("Content-Disposition", attachment("index.md"))
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(idx.Markdown()))
+		return
+	}
+	respond(c, http.StatusOK, idx)
+}
+
+// PublishProjectSite 将静态站点发布到 <STORAGE_PATH>/sites/<projectId>，供内网 Web 服务器直接托管
+func PublishProjectSite(c *gin.Context) {
+	files, ok := buildProjectSite(c)
+	if !ok {
+		return
+	}
+	dir := filepath.Join(getConfig().Storage.Path, "sites", c.Param("id"))
+	if err := service.WriteSiteDir(dir, files); err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"path": dir, "files": len(files)})
+}
+
+func buildProjectSite(c *gin.Context) ([]service.SiteFile, bool) {
+	files, err := docSvc.BuildSite(c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		failNotFound(c, "project")
+		return nil, false
+	}
+	if err != nil {
+		failInternal(c, err)
+		return nil, false
+	}
+	return files, true
 }
 
 // ─────────────────────────────────────
@@ -176,56 +515,124 @@ func ExportDocument(c *gin.Context) {
 
 func GetLLMProviders(c *gin.Context) {
 	var providers []db.LLMProvider
-	db.DB.Find(&providers)
+	db.DB.Order("name, is_default DESC, created_at, label").Find(&providers)
 	// 不返回 API Key（安全）
 	type safeProvider struct {
 		ID        string `json:"id"`
 		Name      string `json:"name"`
+		Label     string `json:"label"`
 		Model     string `json:"model"`
 		BaseURL   string `json:"base_url"`
+		Proxy     string `json:"proxy"` // 密码已隐藏
 		HasAPIKey bool   `json:"has_api_key"`
 		IsDefault bool   `json:"is_default"`
 		IsActive  bool   `json:"is_active"`
+		TextOnly  bool   `json:"text_only"`
+
+		TimeoutSeconds int      `json:"timeout_seconds"`
+		MaxRetries     int      `json:"max_retries"`
+		Temperature    *float64 `json:"temperature"`
+		MaxTokens      int      `json:"max_tokens"`
+
+		CostPerCall    float64                     `json:"cost_per_call"`
+		DailyCallCap   int                         `json:"daily_call_cap"`
+		MonthlyCallCap int                         `json:"monthly_call_cap"`
+		DailyCostCap   float64                     `json:"daily_cost_cap"`
+		MonthlyCostCap float64                     `json:"monthly_cost_cap"`
+		Usage          service.ProviderBudgetUsage `json:"usage"`
+		QuotaReached   bool                        `json:"quota_reached"`
 	}
+	now := time.Now()
 	var safe []safeProvider
 	for _, p := range providers {
+		reached, _ := service.QuotaExceeded(p, now)
 		safe = append(safe, safeProvider{
 			ID:        p.ID,
 			Name:      p.Name,
+			Label:     p.Label,
 			Model:     p.Model,
 			BaseURL:   p.BaseURL,
+			Proxy:     service.RedactProxy(p.Proxy),
 			HasAPIKey: p.APIKey != "",
 			IsDefault: p.IsDefault,
 			IsActive:  p.IsActive,
+			TextOnly:  p.TextOnly,
+
+			TimeoutSeconds: p.TimeoutSeconds,
+			MaxRetries:     p.MaxRetries,
+			Temperature:    p.Temperature,
+			MaxTokens:      p.MaxTokens,
+
+			CostPerCall:    p.CostPerCall,
+			DailyCallCap:   p.DailyCallCap,
+			MonthlyCallCap: p.MonthlyCallCap,
+			DailyCostCap:   p.DailyCostCap,
+			MonthlyCostCap: p.MonthlyCostCap,
+			Usage:          service.UsageOf(p.ID, now),
+			QuotaReached:   reached,
 		})
 	}
-	c.JSON(http.StatusOK, gin.H{"data": safe})
+	respond(c, http.StatusOK, safe)
 }
 
 func UpsertLLMProvider(c *gin.Context) {
 	var req struct {
-		Name      string `json:"name" binding:"required"`
+		Name string `json:"name" binding:"required"`
+		// 同类型多个配置时的区分名称，按 name + label 新建或更新；不能包含 ":"（生成时以 "类型:label" 指定配置）
+		Label     string `json:"label" binding:"max=64,excludes=:"`
 		APIKey    string `json:"api_key"`
 		BaseURL   string `json:"base_url"`
 		Model     string `json:"model"`
 		IsDefault bool   `json:"is_default"`
+		// 出站代理 URL 或 "direct"；省略时保持不变，空串表示清除（使用全局 llm.proxy）
+		Proxy *string `json:"proxy"`
+
+		providerOptions
+
+		// 保存前用提交的 api_key 发起一次轻量调用验证，失败时不保存
+		Verify bool `json:"verify"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		failBind(c, err)
+		return
+	}
+	if req.Proxy != nil {
+		if err := service.CheckProviderProxy(*req.Proxy); err != nil {
+			failValidation(c, "proxy", err.Error())
+			return
+		}
+	}
+	// 离线模式不保存云端 API Key，避免涉密网络中留存外部服务凭据
+	if aiSvc.LocalOnly() && req.APIKey != "" && service.IsExternalProvider(req.Name) {
+		fail(c, http.StatusForbidden, ErrCodeForbidden, "local-only mode: API keys for external providers cannot be saved",
+			FieldError{Field: "api_key", Message: "not allowed in local-only mode"})
 		return
 	}
 
+	verified := false
+	if req.Verify && req.APIKey != "" {
+		if !verifyProviderKey(c, req.Name, req.APIKey, req.BaseURL) {
+			return
+		}
+		verified = true
+	}
+
 	var provider db.LLMProvider
-	if err := db.DB.First(&provider, "name = ?", req.Name).Error; err != nil {
+	if err := db.DB.First(&provider, "name = ? AND label = ?", req.Name, req.Label).Error; err != nil {
 		// 新建
 		provider = db.LLMProvider{
 			Name:      req.Name,
+			Label:     req.Label,
 			APIKey:    req.APIKey,
 			BaseURL:   req.BaseURL,
 			Model:     req.Model,
 			IsDefault: req.IsDefault,
 			IsActive:  true,
 		}
+		if req.Proxy != nil {
+			provider.Proxy = *req.Proxy
+		}
+		req.providerOptions.apply(&provider)
 		db.DB.Create(&provider)
 	} else {
 		// 更新
@@ -242,12 +649,177 @@ func UpsertLLMProvider(c *gin.Context) {
 		if req.Model != "" {
 			updates["model"] = req.Model
 		}
+		if req.Proxy != nil {
+			updates["proxy"] = *req.Proxy
+		}
+		req.providerOptions.apply(&provider)
+		updates["text_only"] = provider.TextOnly
+		updates["timeout_seconds"] = provider.TimeoutSeconds
+		updates["max_retries"] = provider.MaxRetries
+		updates["temperature"] = provider.Temperature
+		updates["max_tokens"] = provider.MaxTokens
+		updates["cost_per_call"] = provider.CostPerCall
+		updates["daily_call_cap"] = provider.DailyCallCap
+		updates["monthly_call_cap"] = provider.MonthlyCallCap
+		updates["daily_cost_cap"] = provider.DailyCostCap
+		updates["monthly_cost_cap"] = provider.MonthlyCostCap
 		db.DB.Model(&provider).Updates(updates)
 	}
 
 	if req.IsDefault {
-		db.DB.Model(&db.LLMProvider{}).Where("name != ?", req.Name).Update("is_default", false)
+		db.DB.Model(&db.LLMProvider{}).Where("id != ?", provider.ID).Update("is_default", false)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "saved", "id": provider.ID})
+	respond(c, http.StatusOK, gin.H{"id": provider.ID, "name": provider.Name, "label": provider.Label, "verified": verified})
+}
+
+// DeleteLLMProvider 删除一个提供商配置（同类型的其余配置继续生效，全部删除后回退到环境变量配置）
+func DeleteLLMProvider(c *gin.Context) {
+	res := db.DB.Delete(&db.LLMProvider{}, "id = ?", c.Param("id"))
+	if res.Error != nil {
+		failInternal(c, res.Error)
+		return
+	}
+	if res.RowsAffected == 0 {
+		failNotFound(c, "provider")
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"id": c.Param("id"), "deleted": true})
+}
+
+// verifyProviderKey 验证 API Key，失败时写入错误响应：密钥无效 / 配额不足返回 422，网络等其他错误返回 502
+func verifyProviderKey(c *gin.Context, provider, apiKey, baseURL string) bool {
+	err := aiSvc.VerifyKey(provider, apiKey, baseURL)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, service.ErrUnknownProvider):
+		failValidation(c, "name", err.Error())
+	case errors.Is(err, service.ErrKeyInvalid):
+		fail(c, http.StatusUnprocessableEntity, ErrCodeKeyInvalid, err.Error(), FieldError{Field: "api_key", Message: "rejected by provider"})
+	case errors.Is(err, service.ErrQuotaExceeded):
+		fail(c, http.StatusUnprocessableEntity, ErrCodeQuotaExceeded, err.Error(), FieldError{Field: "api_key", Message: "quota exceeded"})
+	default:
+		fail(c, http.StatusBadGateway, ErrCodeUpstream, "key verification failed: "+err.Error())
+	}
+	return false
+}
+
+// ListProviderModels 查询提供商的可用模型列表，供界面下拉选择
+func ListProviderModels(c *gin.Context) {
+	models, err := aiSvc.ListModels(c.Param("name"))
+	switch {
+	case errors.Is(err, service.ErrUnknownProvider):
+		failNotFound(c, "provider")
+	case errors.Is(err, service.ErrProviderDisabled):
+		fail(c, http.StatusForbidden, ErrCodeForbidden, err.Error())
+	case errors.Is(err, service.ErrModelListUnsupported):
+		fail(c, http.StatusUnprocessableEntity, ErrCodeUnprocessable, err.Error())
+	case err != nil:
+		fail(c, http.StatusBadGateway, ErrCodeUpstream, err.Error())
+	default:
+		respond(c, http.StatusOK, models)
+	}
+}
+
+// providerOptions 提供商的调用参数与预算上限：省略的项保持不变，0 表示使用默认值 / 不限
+type providerOptions struct {
+	// 模型不支持图片时开启，调用时不发送截图
+	TextOnly *bool `json:"text_only"`
+
+	// 调用参数；温度为负数时恢复默认
+	TimeoutSeconds *int     `json:"timeout_seconds" binding:"omitempty,min=0,max=600"`
+	MaxRetries     *int     `json:"max_retries"     binding:"omitempty,min=0,max=5"`
+	Temperature    *float64 `json:"temperature"     binding:"omitempty,max=2"`
+	MaxTokens      *int     `json:"max_tokens"      binding:"omitempty,min=0,max=32768"`
+
+	// 预算上限，费用按 cost_per_call 估算
+	CostPerCall    *float64 `json:"cost_per_call"    binding:"omitempty,min=0"`
+	DailyCallCap   *int     `json:"daily_call_cap"   binding:"omitempty,min=0"`
+	MonthlyCallCap *int     `json:"monthly_call_cap" binding:"omitempty,min=0"`
+	DailyCostCap   *float64 `json:"daily_cost_cap"   binding:"omitempty,min=0"`
+	MonthlyCostCap *float64 `json:"monthly_cost_cap" binding:"omitempty,min=0"`
+}
+
+// apply 将提交的项写入 provider
+func (o providerOptions) apply(p *db.LLMProvider) {
+	if o.TextOnly != nil {
+		p.TextOnly = *o.TextOnly
+	}
+	if o.TimeoutSeconds != nil {
+		p.TimeoutSeconds = *o.TimeoutSeconds
+	}
+	if o.MaxRetries != nil {
+		p.MaxRetries = *o.MaxRetries
+	}
+	if o.Temperature != nil {
+		p.Temperature = o.Temperature
+		if *o.Temperature < 0 {
+			p.Temperature = nil
+		}
+	}
+	if o.MaxTokens != nil {
+		p.MaxTokens = *o.MaxTokens
+	}
+	if o.CostPerCall != nil {
+		p.CostPerCall = *o.CostPerCall
+	}
+	if o.DailyCallCap != nil {
+		p.DailyCallCap = *o.DailyCallCap
+	}
+	if o.MonthlyCallCap != nil {
+		p.MonthlyCallCap = *o.MonthlyCallCap
+	}
+	if o.DailyCostCap != nil {
+		p.DailyCostCap = *o.DailyCostCap
+	}
+	if o.MonthlyCostCap != nil {
+		p.MonthlyCostCap = *o.MonthlyCostCap
+	}
+}
+
+// ReviewSession AI 一致性审阅：检查序号错误、术语不一致和缺失步骤，返回可逐条采纳的建议
+func ReviewSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	var session db.Session
+	if err := db.DB.First(&session, "id = ?", sessionID).Error; err != nil {
+		failNotFound(c, "session")
+		return
+	}
+	result, err := aiFor(c, sessionID).ReviewSession(sessionID)
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, result)
+}
+
+// AcceptReviewSuggestions 采纳审阅建议，写回对应步骤的描述；缺失步骤建议不能采纳，返回 422
+func AcceptReviewSuggestions(c *gin.Context) {
+	var req struct {
+		Suggestions []service.AcceptedSuggestion `json:"suggestions" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	var updated int64
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		updated, err = service.ApplyReviewSuggestions(tx, c.Param("id"), req.Suggestions)
+		return err
+	})
+	if errors.Is(err, service.ErrMissingStepNotApplicable) {
+		fail(c, http.StatusUnprocessableEntity, ErrCodeUnprocessable, err.Error())
+		return
+	}
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	// 描述已改写，事务提交后重建这些步骤的检索索引
+	for _, s := range req.Suggestions {
+		service.QueueEmbedding(service.EmbedStep, s.StepID)
+	}
+	respond(c, http.StatusOK, gin.H{"updated": updated})
 }
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+// maxBulkIDs 单次批量操作的记录数上限
+const maxBulkIDs = 500
+
+// bulkDeleteResult 批量删除结果：deleted 为已删除的 ID，not_found 为不存在（或已删除）的 ID
+type bulkDeleteResult struct {
+	Deleted  []string `json:"deleted"`
+	NotFound []string `json:"not_found"`
+}
+
+// bindBulkIDs 解析批量操作的 {"ids": [...]}，去掉空值与重复项
+func bindBulkIDs(c *gin.Context) ([]string, bool) {
+	var req struct {
+		IDs []string `json:"ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return nil, false
+	}
+	seen := map[string]bool{}
+	var ids []string
+	for _, id := range req.IDs {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	switch {
+	case len(ids) == 0:
+		failValidation(c, "ids", "must contain at least one id")
+		return nil, false
+	case len(ids) > maxBulkIDs:
+		failValidation(c, "ids", fmt.Sprintf("must contain at most %d ids", maxBulkIDs))
+		return nil, false
+	}
+	return ids, true
+}
+
+// splitExisting 按请求顺序把 ID 分为已存在与不存在两组
+func splitExisting(tx *gorm.DB, model interface{}, ids []string) (existing, missing []string, err error) {
+	var found []string
+	if err := tx.Model(model).Where("id IN ?", ids).Pluck("id", &found).Error; err != nil {
+		return nil, nil, err
+	}
+	ok := map[string]bool{}
+	for _, id := range found {
+		ok[id] = true
+	}
+	existing, missing = []string{}, []string{}
+	for _, id := range ids {
+		if ok[id] {
+			existing = append(existing, id)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return existing, missing, nil
+}
+
+// BulkDeleteSessions 在一个事务中批量删除会话（连同步骤、截图、文档等，同 DELETE /sessions/:id），
+// 不存在的 ID 列入 not_found，不影响其余会话的删除
+func BulkDeleteSessions(c *gin.Context) {
+	ids, ok := bindBulkIDs(c)
+	if !ok {
+		return
+	}
+	var result bulkDeleteResult
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result.Deleted, result.NotFound, err = splitExisting(tx, &db.Session{}, ids)
+		if err != nil {
+			return err
+		}
+		return service.DeleteSessions(tx, result.Deleted)
+	})
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	if err := service.RemoveSessionMediaFiles(getConfig().Storage.Path, result.Deleted); err != nil {
+		c.Error(err)
+	}
+	respond(c, http.StatusOK, result)
+}
+
+// BulkDeleteDocuments 在一个事务中批量删除生成文档及其分享链接；会话的当前文档被删除时改为该会话最新的剩余文档
+func BulkDeleteDocuments(c *gin.Context) {
+	ids, ok := bindBulkIDs(c)
+	if !ok {
+		return
+	}
+	var result bulkDeleteResult
+	var promoted []string
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result.Deleted, result.NotFound, err = splitExisting(tx, &db.GeneratedDocument{}, ids)
+		if err != nil {
+			return err
+		}
+		promoted, err = service.DeleteDocuments(tx, result.Deleted)
+		return err
+	})
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	for _, id := range promoted {
+		service.QueueEmbedding(service.EmbedDocument, id)
+	}
+	respond(c, http.StatusOK, result)
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+// ChatSession 针对单个会话的多轮问答（供文档查看页内嵌助手使用）：
+// 不带 conversation_id 时新建对话，带上时结合该对话的历史回答追问；?free_only=true 时只使用免费提供商
+func ChatSession(c *gin.Context) {
+	var req struct {
+		Message        string `json:"message" binding:"required"`
+		ConversationID string `json:"conversation_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if n := utf8.RuneCountInString(req.Message); n == 0 || n > maxQuestionRunes {
+		failValidation(c, "message", fmt.Sprintf("message must be 1-%d characters", maxQuestionRunes))
+		return
+	}
+	var session db.Session
+	if err := db.DB.Select("id", "purged_at").First(&session, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "session")
+		return
+	}
+	if session.PurgedAt != nil {
+		fail(c, http.StatusConflict, ErrCodeConflict, "session has been purged")
+		return
+	}
+
+	reply, err := aiFor(c, session.ID).Chat(session.ID, req.ConversationID, req.Message)
+	switch {
+	case errors.Is(err, service.ErrChatNotFound):
+		failNotFound(c, "conversation")
+	case errors.Is(err, service.ErrNoProvider):
+		fail(c, http.StatusServiceUnavailable, ErrCodeUnavailable, err.Error())
+	case err != nil:
+		fail(c, http.StatusBadGateway, ErrCodeUpstream, err.Error())
+	default:
+		respond(c, http.StatusOK, reply)
+	}
+}
+
+// GetSessionChats 列出会话的问答对话（最近活跃的在前，不含消息）
+func GetSessionChats(c *gin.Context) {
+	var convs []db.ChatConversation
+	if err := db.DB.Where("session_id = ?", c.Param("id")).Order("updated_at DESC").Find(&convs).Error; err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, convs)
+}
+
+// GetChat 对话及其全部消息（按时间顺序）
+func GetChat(c *gin.Context) {
+	var conv db.ChatConversation
+	err := db.DB.Preload("Messages", func(tx *gorm.DB) *gorm.DB { return tx.Order("created_at, role DESC") }).
+		First(&conv, "id = ?", c.Param("chatId")).Error
+	if err != nil {
+		failNotFound(c, "conversation")
+		return
+	}
+	respond(c, http.StatusOK, conv)
+}
+
+// DeleteChat 删除对话及其消息
+func DeleteChat(c *gin.Context) {
+	var conv db.ChatConversation
+	if err := db.DB.First(&conv, "id = ?", c.Param("chatId")).Error; err != nil {
+		failNotFound(c, "conversation")
+		return
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("conversation_id = ?", conv.ID).Delete(&db.ChatMessage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&conv).Error
+	})
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"id": conv.ID, "deleted": true})
+}
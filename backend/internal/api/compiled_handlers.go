@@ -0,0 +1,163 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+// CreateCompiledDocument 新建项目合订手册，session_ids 的顺序即章节顺序
+func CreateCompiledDocument(c *gin.Context) {
+	var req struct {
+		Title       string   `json:"title"       binding:"required"`
+		Description string   `json:"description"`
+		SessionIDs  []string `json:"session_ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	var project db.Project
+	if err := db.DB.First(&project, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "project")
+		return
+	}
+	compiled := db.CompiledDocument{ProjectID: project.ID, Title: req.Title, Description: req.Description}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&compiled).Error; err != nil {
+			return err
+		}
+		chapters := make([]db.CompiledChapter, len(req.SessionIDs))
+		for i, id := range req.SessionIDs {
+			chapters[i].SessionID = id
+		}
+		var err error
+		compiled.Chapters, err = service.SetCompiledChapters(tx, &compiled, chapters)
+		return err
+	})
+	if errors.Is(err, service.ErrInvalidChapters) {
+		failValidation(c, "session_ids", err.Error())
+		return
+	}
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusCreated, compiled)
+}
+
+// SetCompiledChapters 整体替换合订手册的章节配置：数组顺序即章节顺序，可设置章标题、分组标题（group）
+// 与排除标记（excluded），如 {"chapters": [{"session_id": "...", "group": "受理", "excluded": false}]}
+func SetCompiledChapters(c *gin.Context) {
+	var req struct {
+		Chapters []struct {
+			SessionID string `json:"session_id" binding:"required"`
+			Title     string `json:"title"`
+			Group     string `json:"group"`
+			Excluded  bool   `json:"excluded"`
+		} `json:"chapters" binding:"required,dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	compiled, ok := loadCompiled(c)
+	if !ok {
+		return
+	}
+	chapters := make([]db.CompiledChapter, len(req.Chapters))
+	for i, ch := range req.Chapters {
+		chapters[i] = db.CompiledChapter{SessionID: ch.SessionID, Title: ch.Title, GroupTitle: ch.Group, Excluded: ch.Excluded}
+	}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		compiled.Chapters, err = service.SetCompiledChapters(tx, compiled, chapters)
+		return err
+	})
+	if errors.Is(err, service.ErrInvalidChapters) {
+		failValidation(c, "chapters", err.Error())
+		return
+	}
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, compiled)
+}
+
+// GetCompiledDocuments 项目的合订手册列表（含章节）
+func GetCompiledDocuments(c *gin.Context) {
+	var list []db.CompiledDocument
+	err := db.DB.Where("project_id = ?", c.Param("id")).Order("created_at DESC").
+		Preload("Chapters", func(tx *gorm.DB) *gorm.DB { return tx.Order("position") }).Find(&list).Error
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, list)
+}
+
+// GetCompiledDocument 合订手册及其章节
+func GetCompiledDocument(c *gin.Context) {
+	compiled, ok := loadCompiled(c)
+	if !ok {
+		return
+	}
+	respond(c, http.StatusOK, compiled)
+}
+
+// DeleteCompiledDocument 删除合订手册（不影响各会话的文档）
+func DeleteCompiledDocument(c *gin.Context) {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return service.DeleteCompiledDocuments(tx, []string{c.Param("compiledId")})
+	})
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"id": c.Param("compiledId"), "deleted": true})
+}
+
+// ExportCompiledDocument 汇编并导出合订手册（md/mdzip/json），查询参数与单篇文档导出相同；
+// 某章的会话尚未生成文档时返回 409
+func ExportCompiledDocument(c *gin.Context) {
+	compiled, ok := loadCompiled(c)
+	if !ok {
+		return
+	}
+	format := c.Query("format")
+	if format == "" {
+		format = service.CurrentSettings().DefaultExportFormat
+	}
+	content, err := docSvc.CompileDocument(compiled)
+	if errors.Is(err, service.ErrChapterWithoutDocument) {
+		fail(c, http.StatusConflict, ErrCodeConflict, err.Error())
+		return
+	}
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	content, viewType, ok := applyExportOptions(c, content, c.Query("view"))
+	if !ok {
+		return
+	}
+	if !writeManual(c, content, format, viewType, c.Query("filename")) {
+		failValidation(c, "format", "format must be one of: md, mdzip, json")
+	}
+}
+
+func loadCompiled(c *gin.Context) (*db.CompiledDocument, bool) {
+	var compiled db.CompiledDocument
+	err := db.DB.Preload("Chapters", func(tx *gorm.DB) *gorm.DB { return tx.Order("position") }).
+		First(&compiled, "id = ?", c.Param("compiledId")).Error
+	if err != nil {
+		failNotFound(c, "compiled document")
+		return nil, false
+	}
+	return &compiled, true
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+// exportFiles 各导出格式产物的默认文件名与扩展名
+var exportFiles = map[string][2]string{
+	"md":              {"manual", ".md"},
+	"mdzip":           {"manual", ".zip"},
+	"json":            {"manual", ".json"},
+	"chrome-recorder": {"recording", ".json"},
+	"bpmn":            {"process", ".bpmn"},
+}
+
+// StartDocumentExport 在后台导出文档，立即返回 202 与导出任务；查询参数与 GET /documents/:docId/export 相同，
+// 完成后通过 GET /export-jobs/:jobId/download 下载产物
+func StartDocumentExport(c *gin.Context) {
+	var doc db.GeneratedDocument
+	if err := db.DB.First(&doc, "id = ?", c.Param("docId")).Error; err != nil {
+		failNotFound(c, "document")
+		return
+	}
+	format, ok := exportJobFormat(c, "md", "mdzip", "json", "chrome-recorder", "bpmn")
+	if !ok {
+		return
+	}
+	opts, ok := parseExportOptions(c)
+	if !ok {
+		return
+	}
+	viewType := c.Query("view")
+	flowchart, _ := strconv.ParseBool(c.Query("flowchart"))
+
+	job := &db.ExportJob{SourceType: service.ExportSourceDocument, SourceID: doc.ID, SessionID: doc.SessionID}
+	startExportJob(c, job, format, func(w io.Writer) error {
+		content, err := docSvc.LoadDocument(&doc)
+		if err != nil {
+			return err
+		}
+		content, viewType := opts.apply(content, viewType)
+		if flowchart {
+			graph, err := service.SessionFlow(db.DB, doc.SessionID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if graph != nil {
+				content.Flowchart = graph.Mermaid()
+			}
+		}
+		switch format {
+		case "chrome-recorder":
+			rec, err := service.ChromeRecordingForDocument(&doc, content)
+			if err != nil {
+				return err
+			}
+			return json.NewEncoder(w).Encode(rec)
+		case "bpmn":
+			graph, err := service.SessionFlow(db.DB, doc.SessionID)
+			if err != nil {
+				return err
+			}
+			data, err := service.ExportBPMN(graph)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(data)
+			return err
+		}
+		return writeManualFile(w, content, format, viewType)
+	})
+}
+
+// StartCompiledExport 在后台汇编并导出合订手册（md/mdzip/json），查询参数与 GET /compiled/:compiledId/export 相同；
+// 某章的会话尚未生成文档时任务失败
+func StartCompiledExport(c *gin.Context) {
+	compiled, ok := loadCompiled(c)
+	if !ok {
+		return
+	}
+	format, ok := exportJobFormat(c, "md", "mdzip", "json")
+	if !ok {
+		return
+	}
+	opts, ok := parseExportOptions(c)
+	if !ok {
+		return
+	}
+	viewType := c.Query("view")
+
+	job := &db.ExportJob{SourceType: service.ExportSourceCompiled, SourceID: compiled.ID}
+	startExportJob(c, job, format, func(w io.Writer) error {
+		content, err := docSvc.CompileDocument(compiled)
+		if err != nil {
+			return err
+		}
+		content, viewType := opts.apply(content, viewType)
+		return writeManualFile(w, content, format, viewType)
+	})
+}
+
+// exportJobFormat 读取 ?format=（默认使用运行时设置 default_export_format）并校验是否为 allowed 之一
+func exportJobFormat(c *gin.Context, allowed ...string) (string, bool) {
+	format := c.Query("format")
+	if format == "" {
+		format = service.CurrentSettings().DefaultExportFormat
+	}
+	if !service.OneOf(format, allowed) {
+		failValidation(c, "format", "format must be one of: "+strings.Join(allowed, ", "))
+		return "", false
+	}
+	return format, true
+}
+
+// startExportJob 补全导出任务的格式、参数与文件名（?filename= 不含扩展名）后在后台执行，返回 202
+func startExportJob(c *gin.Context, job *db.ExportJob, format string, write func(w io.Writer) error) {
+	file := exportFiles[format]
+	job.Format = format
+	job.Options = c.Request.URL.RawQuery
+	job.FileName = service.ExportFilename(c.Query("filename"), file[0], file[1])
+	if err := service.StartExportJob(getConfig().Storage.Path, job, write); err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusAccepted, job)
+}
+
+// writeManualFile 以 md / mdzip / json 格式把文档内容写入导出产物（json 为文档内容本身，不带响应信封）
+func writeManualFile(w io.Writer, content *service.GeneratedDocContent, format, viewType string) error {
+	switch format {
+	case "md":
+		_, err := io.WriteString(w, docSvc.GenerateMarkdown(content, viewType))
+		return err
+	case "mdzip":
+		return docSvc.WriteMarkdownZip(w, content, viewType)
+	}
+	return json.NewEncoder(w).Encode(content)
+}
+
+// GetExportJob 导出任务的状态；完成后 expires_at 为产物过期时间
+func GetExportJob(c *gin.Context) {
+	var job db.ExportJob
+	if err := db.DB.First(&job, "id = ?", c.Param("jobId")).Error; err != nil {
+		failNotFound(c, "export job")
+		return
+	}
+	respond(c, http.StatusOK, job)
+}
+
+// DownloadExportJob 下载导出产物；任务未完成或失败时返回 409，产物已过期返回 410
+func DownloadExportJob(c *gin.Context) {
+	var job db.ExportJob
+	if err := db.DB.First(&job, "id = ?", c.Param("jobId")).Error; err != nil {
+		failNotFound(c, "export job")
+		return
+	}
+	switch {
+	case service.ExportJobExpired(&job, time.Now()):
+		fail(c, http.StatusGone, ErrCodeGone, "export has expired")
+		return
+	case job.Status == service.ExportRunning:
+		fail(c, http.StatusConflict, ErrCodeConflict, "export is still running")
+		return
+	case job.Status != service.ExportCompleted:
+		fail(c, http.StatusConflict, ErrCodeConflict, "export failed: "+job.Error)
+		return
+	}
+	path := service.ExportJobFile(getConfig().Storage.Path, &job)
+	sealed, err := service.SealedFile(path)
+	if err != nil {
+		fail(c, http.StatusGone, ErrCodeGone, "export file missing")
+		return
+	}
+	if !sealed {
+		c.Header("Content-Disposition", attachment(job.FileName))
+		c.File(path)
+		return
+	}
+	// 开启静态加密时产物以密文落盘，解密后返回
+	data, err := service.ReadSealedFile(path)
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	c.Header("Content-Disposition", attachment(job.FileName))
+	contentType := mime.TypeByExtension(filepath.Ext(job.FileName))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	c.Data(http.StatusOK, contentType, data)
+}
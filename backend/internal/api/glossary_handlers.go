@@ -0,0 +1,52 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+// GetProjectGlossary 项目术语表
+func GetProjectGlossary(c *gin.Context) {
+	var project db.Project
+	if err := db.DB.First(&project, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "project")
+		return
+	}
+	respond(c, http.StatusOK, service.ProjectGlossary(project.ID))
+}
+
+// SetProjectGlossary 整体替换项目术语表，如 {"terms": [{"term": "操作员", "preferred": "经办人"}]}
+func SetProjectGlossary(c *gin.Context) {
+	var req struct {
+		Terms []db.GlossaryTerm `json:"terms"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	var project db.Project
+	if err := db.DB.First(&project, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "project")
+		return
+	}
+	var terms []db.GlossaryTerm
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		terms, err = service.SetGlossary(tx, project.ID, req.Terms)
+		return err
+	})
+	if errors.Is(err, service.ErrInvalidGlossary) {
+		failValidation(c, "terms", err.Error())
+		return
+	}
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, terms)
+}
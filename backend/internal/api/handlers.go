@@ -1,11 +1,18 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+	"gorm.io/gorm"
 )
 
 // ─────────────────────────────────────
@@ -14,60 +21,313 @@ import (
 
 func GetProjects(c *gin.Context) {
 	var projects []db.Project
-	db.DB.Preload("Sessions").Find(&projects)
-	c.JSON(http.StatusOK, gin.H{"data": projects})
+	q := service.FilterByTags(db.DB, "project_tags", "project_id", service.ParseTagFilter(c.Query("tags")))
+	q.Preload("Sessions").Preload("Tags").Find(&projects)
+	groups := make([][]db.Session, len(projects))
+	for i := range projects {
+		groups[i] = projects[i].Sessions
+	}
+	if err := service.FillStepCounts(db.DB, groups...); err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, projects)
 }
 
 func CreateProject(c *gin.Context) {
 	var req struct {
-		Name             string `json:"name" binding:"required"`
-		Description      string `json:"description"`
-		TemplateType     string `json:"template_type"`
-		MaskingProfileID string `json:"masking_profile_id"`
+		Name             string            `json:"name" binding:"required"`
+		Description      string            `json:"description"`
+		TemplateType     string            `json:"template_type"`
+		MaskingProfileID string            `json:"masking_profile_id"`
+		Metadata         map[string]string `json:"metadata"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		failBind(c, err)
 		return
 	}
 	if req.TemplateType == "" {
 		req.TemplateType = "both"
 	}
+	var v checks
+	if _, ok := service.FindTemplate(req.TemplateType); !ok {
+		v.add("template_type", "references a nonexistent template (%s)", req.TemplateType)
+	}
+	v.exists("masking_profile_id", req.MaskingProfileID, &db.MaskingProfile{})
+	if v.failed(c) {
+		return
+	}
+	meta, err := service.ValidateMetadata(req.Metadata)
+	if err != nil {
+		failValidation(c, "metadata", err.Error())
+		return
+	}
 	project := db.Project{
 		Name:             req.Name,
 		Description:      req.Description,
 		TemplateType:     req.TemplateType,
 		MaskingProfileID: req.MaskingProfileID,
+		Metadata:         meta,
 	}
 	if err := db.DB.Create(&project).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		failInternal(c, err)
 		return
 	}
-	c.JSON(http.StatusCreated, gin.H{"data": project})
+	respond(c, http.StatusCreated, project)
 }
 
 func GetProject(c *gin.Context) {
 	var project db.Project
-	if err := db.DB.Preload("Sessions").First(&project, "id = ?", c.Param("id")).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+	if err := db.DB.Preload("Sessions.Tags").Preload("Tags").First(&project, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "project")
 		return
 	}
 
 	// 填充 sessions 的步骤统计
-	for i := range project.Sessions {
-		var count int64
-		db.DB.Model(&db.RecordingStep{}).Where("session_id = ?", project.Sessions[i].ID).Count(&count)
-		project.Sessions[i].StepCount = count
+	if err := service.FillStepCounts(db.DB, project.Sessions); err != nil {
+		failInternal(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, project)
+}
+
+// UpdateProjectRetention 设置项目数据保留策略（0 表示永久保留）
+func UpdateProjectRetention(c *gin.Context) {
+	var req struct {
+		ScreenshotRetentionDays *int `json:"screenshot_retention_days"`
+		SessionRetentionDays    *int `json:"session_retention_days"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+
+	var project db.Project
+	if err := db.DB.First(&project, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "project")
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.ScreenshotRetentionDays != nil {
+		if *req.ScreenshotRetentionDays < 0 {
+			failValidation(c, "screenshot_retention_days", "screenshot_retention_days must be >= 0")
+			return
+		}
+		updates["screenshot_retention_days"] = *req.ScreenshotRetentionDays
+	}
+	if req.SessionRetentionDays != nil {
+		if *req.SessionRetentionDays < 0 {
+			failValidation(c, "session_retention_days", "session_retention_days must be >= 0")
+			return
+		}
+		updates["session_retention_days"] = *req.SessionRetentionDays
+	}
+	if len(updates) > 0 {
+		db.DB.Model(&project).Updates(updates)
+	}
+	db.DB.First(&project, "id = ?", project.ID)
+	respond(c, http.StatusOK, project)
+}
+
+// UpdateProjectMergeRules 设置业务视图步骤合并策略
+func UpdateProjectMergeRules(c *gin.Context) {
+	var req struct {
+		Strategy      string `json:"strategy" binding:"required"`
+		WindowSeconds *int   `json:"window_seconds"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	valid := false
+	for _, s := range service.MergeStrategies {
+		if req.Strategy == s {
+			valid = true
+		}
+	}
+	if !valid {
+		failValidation(c, "strategy", "strategy must be one of: "+strings.Join(service.MergeStrategies, ", "))
+		return
+	}
+
+	var project db.Project
+	if err := db.DB.First(&project, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "project")
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": project})
+	updates := map[string]interface{}{"merge_strategy": req.Strategy}
+	if req.WindowSeconds != nil {
+		if *req.WindowSeconds <= 0 {
+			failValidation(c, "window_seconds", "window_seconds must be > 0")
+			return
+		}
+		updates["merge_window_seconds"] = *req.WindowSeconds
+	}
+	db.DB.Model(&project).Updates(updates)
+	db.DB.First(&project, "id = ?", project.ID)
+	respond(c, http.StatusOK, project)
 }
 
+// UpdateProjectDocOptions 设置项目文档选项（是否在业务视图中标注耗时、是否只用免费提供商生成、
+// 导出编号样式与标题级别、文档模板）
+func UpdateProjectDocOptions(c *gin.Context) {
+	var req struct {
+		ShowTiming     *bool     `json:"show_timing"`
+		FreeOnly       *bool     `json:"free_only"`
+		NumberingStyle *string   `json:"numbering_style" binding:"omitempty,oneof=step hierarchical english"`
+		HeadingBase    *int      `json:"heading_base"    binding:"omitempty,min=1,max=4"`
+		TemplateType   *string   `json:"template_type"`
+		BannedPhrases  *[]string `json:"banned_phrases"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+
+	var project db.Project
+	if err := db.DB.First(&project, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "project")
+		return
+	}
+	if req.TemplateType != nil {
+		if _, ok := service.FindTemplate(*req.TemplateType); !ok {
+			failValidation(c, "template_type", "references a nonexistent template ("+*req.TemplateType+")")
+			return
+		}
+	}
+
+	var banned []string
+	if req.BannedPhrases != nil {
+		var err error
+		if banned, err = service.NormalizeBannedPhrases(*req.BannedPhrases); err != nil {
+			failValidation(c, "banned_phrases", err.Error())
+			return
+		}
+	}
+
+	updates := map[string]interface{}{}
+	if req.ShowTiming != nil {
+		updates["show_timing"] = *req.ShowTiming
+	}
+	if req.FreeOnly != nil {
+		updates["free_only"] = *req.FreeOnly
+	}
+	if req.NumberingStyle != nil {
+		updates["numbering_style"] = *req.NumberingStyle
+	}
+	if req.HeadingBase != nil {
+		updates["heading_base"] = *req.HeadingBase
+	}
+	if req.TemplateType != nil {
+		updates["template_type"] = *req.TemplateType
+	}
+	if req.BannedPhrases != nil {
+		updates["banned_phrases"] = db.StringList(banned)
+	}
+	if len(updates) > 0 {
+		if err := db.DB.Model(&project).Updates(updates).Error; err != nil {
+			failInternal(c, err)
+			return
+		}
+	}
+	db.DB.First(&project, "id = ?", project.ID)
+	respond(c, http.StatusOK, project)
+}
+
+// UpdateProjectMetadata 整体替换项目自定义字段，作为项目下文档的默认值
+func UpdateProjectMetadata(c *gin.Context) {
+	var project db.Project
+	if err := db.DB.First(&project, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "project")
+		return
+	}
+	meta, ok := bindMetadata(c)
+	if !ok {
+		return
+	}
+	if err := db.DB.Model(&project).Update("metadata", meta).Error; err != nil {
+		failInternal(c, err)
+		return
+	}
+	project.Metadata = meta
+	respond(c, http.StatusOK, project)
+}
+
+// bindMetadata 解析 {"metadata": {...}} 请求体并校验
+func bindMetadata(c *gin.Context) (db.Metadata, bool) {
+	var req struct {
+		Metadata map[string]string `json:"metadata"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return nil, false
+	}
+	meta, err := service.ValidateMetadata(req.Metadata)
+	if err != nil {
+		failValidation(c, "metadata", err.Error())
+		return nil, false
+	}
+	return meta, true
+}
+
+// ExportProjectBundle 下载项目包（JSON，含会话、步骤、截图、文档），用于迁移到其他实例
+func ExportProjectBundle(c *gin.Context) {
+	bundle, err := service.ExportProjectBundle(c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		failNotFound(c, "project")
+		return
+	}
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="project-bundle.json"`)
+	c.JSON(http.StatusOK, bundle)
+}
+
+// ImportProjectBundle 导入项目包，创建为新项目
+func ImportProjectBundle(c *gin.Context) {
+	var bundle service.ProjectBundle
+	if err := c.ShouldBindJSON(&bundle); err != nil {
+		failBind(c, err)
+		return
+	}
+	var project *db.Project
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		project, err = service.ImportProjectBundle(tx, &bundle)
+		return err
+	})
+	if errors.Is(err, service.ErrBundleVersion) {
+		failValidation(c, "version", err.Error())
+		return
+	}
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusCreated, gin.H{"project": project, "sessions": len(bundle.Sessions)})
+}
+
+// DeleteProject 删除项目及其全部会话、文档、合订手册与术语表
 func DeleteProject(c *gin.Context) {
-	if err := db.DB.Delete(&db.Project{}, "id = ?", c.Param("id")).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var sessionIDs []string
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		sessionIDs, err = service.DeleteProjects(tx, []string{c.Param("id")})
+		return err
+	})
+	if err != nil {
+		failInternal(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+	if err := service.RemoveSessionMediaFiles(getConfig().Storage.Path, sessionIDs); err != nil {
+		c.Error(err)
+	}
+	respond(c, http.StatusOK, gin.H{"id": c.Param("id"), "deleted": true, "sessions_deleted": len(sessionIDs)})
 }
 
 // ─────────────────────────────────────
@@ -81,16 +341,16 @@ func GetSessions(c *gin.Context) {
 	if projectID != "" {
 		q = q.Where("project_id = ?", projectID)
 	}
-	q.Find(&sessions)
+	q = service.FilterByTags(q, "session_tags", "session_id", service.ParseTagFilter(c.Query("tags")))
+	q.Preload("Tags").Find(&sessions)
 
 	// 填充步骤统计
-	for i := range sessions {
-		var count int64
-		db.DB.Model(&db.RecordingStep{}).Where("session_id = ?", sessions[i].ID).Count(&count)
-		sessions[i].StepCount = count
+	if err := service.FillStepCounts(db.DB, sessions); err != nil {
+		failInternal(c, err)
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": sessions})
+	respond(c, http.StatusOK, sessions)
 }
 
 func CreateSession(c *gin.Context) {
@@ -100,7 +360,12 @@ func CreateSession(c *gin.Context) {
 		TargetURL string `json:"target_url"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		failBind(c, err)
+		return
+	}
+	var v checks
+	v.exists("project_id", req.ProjectID, &db.Project{})
+	if v.failed(c) {
 		return
 	}
 	now := time.Now()
@@ -112,19 +377,99 @@ func CreateSession(c *gin.Context) {
 		StartedAt: &now,
 	}
 	if err := db.DB.Create(&session).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		failInternal(c, err)
 		return
 	}
-	c.JSON(http.StatusCreated, gin.H{"data": session})
+	respond(c, http.StatusCreated, session)
+}
+
+// ImportSession 把外部录制转换为项目下的新会话：chrome-recorder 为 DevTools Recorder 导出的 JSON，
+// playwright-trace 为 trace.zip（multipart 字段 file 或直接作为请求体，大小上限同会话附件），
+// selenium-side 为 Selenium IDE 的 .side 项目，其中每个测试用例导入为一个会话，返回导入结果列表
+func ImportSession(c *gin.Context) {
+	projectID, format := c.Query("project_id"), c.Query("format")
+	var v checks
+	v.oneOf("format", format, service.ImportFormats)
+	if projectID == "" {
+		v.add("project_id", "is required")
+	}
+	v.exists("project_id", projectID, &db.Project{})
+	if v.failed(c) {
+		return
+	}
+	var importer func(tx *gorm.DB) (interface{}, error)
+	switch format {
+	case "playwright-trace":
+		maxBytes := int(service.CurrentSettings().MaxMediaBytes())
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(maxBytes)+1<<20)
+		archive, err := readUploadBody(c, maxBytes)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) || len(archive) > maxBytes {
+			fail(c, http.StatusRequestEntityTooLarge, ErrCodeTooLarge, "trace too large")
+			return
+		}
+		if err != nil {
+			fail(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+		// 轨迹中的截图按归档大小计入项目存储配额
+		if err := service.ReserveStorage(projectID, int64(len(archive))); err != nil {
+			failStorage(c, err)
+			return
+		}
+		importer = func(tx *gorm.DB) (interface{}, error) {
+			return service.ImportPlaywrightTrace(tx, projectID, archive)
+		}
+	case "selenium-side":
+		var side service.SeleniumProject
+		if err := c.ShouldBindJSON(&side); err != nil {
+			failBind(c, err)
+			return
+		}
+		importer = func(tx *gorm.DB) (interface{}, error) {
+			return service.ImportSeleniumProject(tx, projectID, &side)
+		}
+	default:
+		var rec service.ChromeRecording
+		if err := c.ShouldBindJSON(&rec); err != nil {
+			failBind(c, err)
+			return
+		}
+		importer = func(tx *gorm.DB) (interface{}, error) {
+			return service.ImportChromeRecording(tx, projectID, &rec)
+		}
+	}
+	var result interface{}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = importer(tx)
+		return err
+	})
+	switch {
+	case errors.Is(err, service.ErrEmptyRecording):
+		failValidation(c, "steps", err.Error())
+		return
+	case errors.Is(err, service.ErrInvalidTrace):
+		fail(c, http.StatusUnsupportedMediaType, ErrCodeUnsupportedMedia, err.Error())
+		return
+	case errors.Is(err, service.ErrTraceTooLarge):
+		fail(c, http.StatusRequestEntityTooLarge, ErrCodeTooLarge, err.Error())
+		return
+	}
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusCreated, result)
 }
 
 func GetSession(c *gin.Context) {
 	var session db.Session
-	if err := db.DB.First(&session, "id = ?", c.Param("id")).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+	if err := db.DB.Preload("Tags").First(&session, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "session")
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"data": session})
+	respond(c, http.StatusOK, session)
 }
 
 func UpdateSessionStatus(c *gin.Context) {
@@ -132,13 +477,18 @@ func UpdateSessionStatus(c *gin.Context) {
 		Status string `json:"status" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		failBind(c, err)
+		return
+	}
+	var v checks
+	v.oneOf("status", req.Status, service.SessionStatuses)
+	if v.failed(c) {
 		return
 	}
 
 	var session db.Session
 	if err := db.DB.First(&session, "id = ?", c.Param("id")).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		failNotFound(c, "session")
 		return
 	}
 
@@ -147,28 +497,171 @@ func UpdateSessionStatus(c *gin.Context) {
 		now := time.Now()
 		updates["ended_at"] = &now
 	}
-	db.DB.Model(&session).Updates(updates)
-	c.JSON(http.StatusOK, gin.H{"data": session})
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&session).Updates(updates).Error; err != nil {
+			return err
+		}
+		if req.Status == "completed" && service.CurrentSettings().ExcludeNoiseOnComplete {
+			_, err := service.ApplyNoiseFilter(tx, session.ID, nil, false)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, session)
 }
 
 func DeleteSession(c *gin.Context) {
-	id := c.Param("id")
-	db.DB.Delete(&db.RecordingStep{}, "session_id = ?", id)
-	db.DB.Delete(&db.Screenshot{}, "session_id = ?", id)
-	db.DB.Delete(&db.GeneratedDocument{}, "session_id = ?", id)
-	db.DB.Delete(&db.Session{}, "id = ?", id)
-	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return service.DeleteSessions(tx, []string{c.Param("id")})
+	})
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	if err := service.RemoveSessionMediaFiles(getConfig().Storage.Path, []string{c.Param("id")}); err != nil {
+		c.Error(err)
+	}
+	respond(c, http.StatusOK, gin.H{"id": c.Param("id"), "deleted": true})
+}
+
+// PurgeSession 应数据主体删除请求不可逆地清除会话内容，只保留匿名骨架（步骤序号、操作类型、耗时等）
+func PurgeSession(c *gin.Context) {
+	var result *service.PurgeResult
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = service.PurgeSession(tx, c.Param("id"), time.Now())
+		return err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		failNotFound(c, "session")
+		return
+	}
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	if err := service.RemoveSessionMediaFiles(getConfig().Storage.Path, []string{result.SessionID}); err != nil {
+		c.Error(err)
+	}
+	respond(c, http.StatusOK, result)
 }
 
 // ─────────────────────────────────────
 // Step
 // ─────────────────────────────────────
 
+// stepListItem 步骤列表项：默认只带截图地址，由前端按需通过 /screenshots/:id/image 加载；
+// ?include=screenshots 时内嵌截图（含 data URL）
+type stepListItem struct {
+	db.RecordingStep
+	ScreenshotURL string         `json:"screenshot_url,omitempty"`
+	Screenshot    *db.Screenshot `json:"screenshot,omitempty"`
+}
+
+// 步骤分页：默认每页条数与上限
+const (
+	defaultStepPageSize = 100
+	maxStepPageSize     = 500
+)
+
+// GetSteps 会话的步骤，不含截图数据（?include=screenshots 时内嵌）；带 ETag，前端轮询时以 If-None-Match 校验，未变化时返回 304。
+// 带 ?after_step_index= 或 ?limit= 时按步骤序号游标分页：返回序号大于 after_step_index 的前 limit 步，
+// meta.page.next_after_step_index 为下一页（或录制中新上报步骤）的游标，generation 只统计本页
 func GetSteps(c *gin.Context) {
+	paged := c.Query("after_step_index") != "" || c.Query("limit") != ""
+	after, limit := 0, defaultStepPageSize
+	if paged {
+		var err error
+		if after, err = strconv.Atoi(c.DefaultQuery("after_step_index", "0")); err != nil || after < 0 {
+			failValidation(c, "after_step_index", "after_step_index must be a non-negative integer")
+			return
+		}
+		if limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultStepPageSize))); err != nil || limit < 1 || limit > maxStepPageSize {
+			failValidation(c, "limit", fmt.Sprintf("limit must be 1-%d", maxStepPageSize))
+			return
+		}
+	}
+
+	includeScreenshots := false
+	if include := c.Query("include"); include != "" {
+		for _, v := range strings.Split(include, ",") {
+			if strings.TrimSpace(v) != "screenshots" {
+				failValidation(c, "include", "must be screenshots")
+				return
+			}
+		}
+		includeScreenshots = true
+	}
+
 	sessionID := c.Param("id")
 	var steps []db.RecordingStep
-	db.DB.Where("session_id = ?", sessionID).Order("step_index").Find(&steps)
-	c.JSON(http.StatusOK, gin.H{"data": steps})
+	q := db.DB.Where("session_id = ?", sessionID).Order("step_index")
+	if paged {
+		// 多取一条判断是否还有下一页
+		q = q.Where("step_index > ?", after).Limit(limit + 1)
+	}
+	if err := q.Find(&steps).Error; err != nil {
+		failInternal(c, err)
+		return
+	}
+	hasMore := paged && len(steps) > limit
+	if hasMore {
+		steps = steps[:limit]
+	}
+
+	shots := map[string]*db.Screenshot{}
+	if includeScreenshots {
+		var ids []string
+		for _, s := range steps {
+			if s.ScreenshotID != "" {
+				ids = append(ids, s.ScreenshotID)
+			}
+		}
+		if len(ids) > 0 {
+			var list []db.Screenshot
+			if err := db.DB.Where("id IN ?", ids).Find(&list).Error; err != nil {
+				failInternal(c, err)
+				return
+			}
+			for i := range list {
+				shots[list[i].ID] = &list[i]
+			}
+		}
+	}
+
+	// 汇总描述来源，提示是否有步骤降级到了付费模型、有多少步骤未参考截图
+	providers := map[string]int{}
+	paid, textOnly := 0, 0
+	items := make([]stepListItem, len(steps))
+	for i, s := range steps {
+		items[i] = stepListItem{RecordingStep: s, Screenshot: shots[s.ScreenshotID]}
+		if s.ScreenshotID != "" {
+			items[i].ScreenshotURL = "/api/v1/screenshots/" + s.ScreenshotID + "/image"
+		}
+		if s.AIProvider == "" {
+			continue
+		}
+		providers[s.AIProvider]++
+		if !s.AIUsedFree {
+			paid++
+		}
+		if s.AITextOnly {
+			textOnly++
+		}
+	}
+	meta := gin.H{"generation": gin.H{"providers": providers, "paid_steps": paid, "text_only_steps": textOnly}}
+	if paged {
+		next := after
+		if len(steps) > 0 {
+			next = steps[len(steps)-1].StepIndex
+		}
+		meta["page"] = gin.H{"limit": limit, "has_more": hasMore, "next_after_step_index": next}
+	}
+	respondETag(c, items, meta)
 }
 
 func CreateStep(c *gin.Context) {
@@ -187,28 +680,100 @@ func CreateStep(c *gin.Context) {
 		PageTitle      string `json:"page_title"`
 		IsMasked       bool   `json:"is_masked"`
 		DOMFingerprint string `json:"dom_fingerprint"`
+		ClientStepID   string `json:"client_step_id"` // 幂等键，也可通过 Idempotency-Key 头传入
+		// 键盘操作：key_combo（如 "ctrl+s"）或 key + modifiers
+		KeyCombo  string   `json:"key_combo"`
+		Key       string   `json:"key"`
+		Modifiers []string `json:"modifiers"`
+		// 多标签页 / iframe 录制：由插件后台填写标签页与窗口 ID，内容脚本填写 iframe 路径
+		TabID     int    `json:"tab_id"`
+		WindowID  int    `json:"window_id"`
+		FramePath string `json:"frame_path"`
+		// 交互位置（视口 CSS 像素）；bbox 缺省时取 element_rect
+		ClickX int `json:"click_x"`
+		ClickY int `json:"click_y"`
+		BBox   *struct {
+			X      int `json:"x"`
+			Y      int `json:"y"`
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		} `json:"bbox"`
+		ElementRect *struct {
+			X      float64 `json:"x"`
+			Y      float64 `json:"y"`
+			Width  float64 `json:"width"`
+			Height float64 `json:"height"`
+		} `json:"element_rect"`
+		// 视口尺寸与滚动偏移（CSS 像素）
+		ViewportWidth  int `json:"viewport_width"`
+		ViewportHeight int `json:"viewport_height"`
+		ScrollX        int `json:"scroll_x"`
+		ScrollY        int `json:"scroll_y"`
+		// 步骤触发的网络请求（仅元数据，服务端脱敏后保存）
+		Network []service.NetworkEntry `json:"network"`
+		// 步骤期间的控制台输出（插件已按脱敏规则处理）
+		Console []service.ConsoleEntry `json:"console"`
+		// 脱敏审计：插件记录的规则命中情况（不含原文）
+		MaskingAudit []service.MaskingHit `json:"masking_audit"`
 		// 截图（base64）
 		ScreenshotDataURL string `json:"screenshot_data_url"`
 		ScreenshotWidth   int    `json:"screenshot_width"`
 		ScreenshotHeight  int    `json:"screenshot_height"`
+		// 截图中需遮蔽的区域（视口 CSS 像素），导出与调用 VLM 前烧录
+		MaskedRegions []service.MaskRegion `json:"masked_regions"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		failBind(c, err)
 		return
 	}
 
 	sessionID := c.Param("id")
+	var sessionCount int64
+	if db.DB.Model(&db.Session{}).Where("id = ?", sessionID).Count(&sessionCount); sessionCount == 0 {
+		failNotFound(c, "session")
+		return
+	}
+	var v checks
+	v.oneOf("action", req.Action, service.ActionTypes)
+	var keyCombo string
+	if service.IsKeyAction(req.Action) {
+		field := "key"
+		if req.KeyCombo != "" {
+			field = "key_combo"
+		}
+		combo, err := service.NormalizeKeyCombo(req.KeyCombo, req.Key, req.Modifiers)
+		switch {
+		case errors.Is(err, service.ErrKeyRequired):
+			v.add(field, "is required for %s actions", req.Action)
+		case err != nil:
+			v.add(field, "%v", err)
+		case req.Action == service.ActionShortcut && !service.HasModifier(combo):
+			v.add("modifiers", "must include ctrl, alt, shift or meta for shortcut actions")
+		}
+		keyCombo = combo
+	}
+	v.maskRegions("masked_regions", req.MaskedRegions)
+	for i, h := range req.MaskingAudit {
+		prefix := fmt.Sprintf("masking_audit[%d].", i)
+		v.oneOf(prefix+"rule_type", h.RuleType, service.MaskingRuleTypes)
+		v.oneOf(prefix+"field", h.Field, service.MaskedFields)
+		if h.Count < 1 {
+			v.add(prefix+"count", "must be at least 1")
+		}
+	}
+	if v.failed(c) {
+		return
+	}
+	var purged int64
+	db.DB.Model(&db.Session{}).Where("id = ? AND purged_at IS NOT NULL", sessionID).Count(&purged)
+	if purged > 0 {
+		fail(c, http.StatusConflict, ErrCodeConflict, "session has been purged")
+		return
+	}
 	if req.SessionID == "" {
 		req.SessionID = sessionID
 	}
 
-	// 自动计算步骤序号
-	if req.StepIndex == 0 {
-		var count int64
-		db.DB.Model(&db.RecordingStep{}).Where("session_id = ?", sessionID).Count(&count)
-		req.StepIndex = int(count) + 1
-	}
-
 	step := db.RecordingStep{
 		SessionID:      sessionID,
 		StepIndex:      req.StepIndex,
@@ -220,52 +785,264 @@ func CreateStep(c *gin.Context) {
 		AriaLabel:      req.AriaLabel,
 		MaskedText:     req.MaskedText,
 		InputValue:     req.InputValue,
+		KeyCombo:       keyCombo,
 		PageURL:        req.PageURL,
 		PageTitle:      req.PageTitle,
+		TabID:          req.TabID,
+		WindowID:       req.WindowID,
+		FramePath:      req.FramePath,
 		IsMasked:       req.IsMasked,
 		DOMFingerprint: req.DOMFingerprint,
+		ClickX:         req.ClickX,
+		ClickY:         req.ClickY,
+		ViewportW:      req.ViewportWidth,
+		ViewportH:      req.ViewportHeight,
+		ScrollX:        req.ScrollX,
+		ScrollY:        req.ScrollY,
 	}
-	if err := db.DB.Create(&step).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+	if req.BBox != nil {
+		step.BBoxX, step.BBoxY, step.BBoxW, step.BBoxH = req.BBox.X, req.BBox.Y, req.BBox.Width, req.BBox.Height
+	} else if r := req.ElementRect; r != nil {
+		step.BBoxX, step.BBoxY, step.BBoxW, step.BBoxH = int(r.X), int(r.Y), int(r.Width), int(r.Height)
+	}
+
+	if key := c.GetHeader("Idempotency-Key"); key != "" {
+		req.ClientStepID = key
+	}
+	if req.ClientStepID != "" {
+		if len(req.ClientStepID) > 64 {
+			failValidation(c, "client_step_id", "idempotency key too long (max 64)")
+			return
+		}
+		step.IdempotencyKey = &req.ClientStepID
 	}
 
-	// 保存截图
+	// 步骤与截图在同一事务内写入，避免中途失败留下悬空记录
+	in := service.StepInput{
+		Step:          step,
+		Requests:      service.SanitizeRequests(req.Network),
+		Logs:          service.SanitizeConsoleEntries(req.Console),
+		MaskingEvents: service.MaskingEvents(req.MaskingAudit),
+		SkipDuplicate: c.Query("on_duplicate") == "skip",
+	}
 	if req.ScreenshotDataURL != "" {
-		screenshot := db.Screenshot{
-			SessionID:  sessionID,
-			StepID:     step.ID,
-			CapturedAt: req.Timestamp,
-			DataURL:    req.ScreenshotDataURL,
-			Width:      req.ScreenshotWidth,
-			Height:     req.ScreenshotHeight,
+		// base64 解码后约为原长度的 3/4
+		if len(req.ScreenshotDataURL)/4*3 > service.CurrentSettings().MaxScreenshotBytes() {
+			fail(c, http.StatusRequestEntityTooLarge, ErrCodeTooLarge, "screenshot too large")
+			return
+		}
+		if err := service.ReserveSessionStorage(sessionID, int64(len(req.ScreenshotDataURL))); err != nil {
+			failStorage(c, err)
+			return
+		}
+		in.Screenshot = &db.Screenshot{
+			CapturedAt:    req.Timestamp,
+			DataURL:       db.LongText(req.ScreenshotDataURL),
+			Width:         req.ScreenshotWidth,
+			Height:        req.ScreenshotHeight,
+			MaskedRegions: service.EncodeMaskedRegions(req.MaskedRegions),
 		}
-		db.DB.Create(&screenshot)
-		db.DB.Model(&step).Update("screenshot_id", screenshot.ID)
-		step.ScreenshotID = screenshot.ID
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"data": step})
+	result, err := service.IngestStep(db.DB, in)
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	switch {
+	case result.Replayed:
+		c.Header("Idempotent-Replayed", "true")
+		respondMeta(c, http.StatusOK, result.Step, gin.H{"replayed": true})
+	case result.Duplicate:
+		respondMeta(c, http.StatusOK, result.Step, gin.H{"duplicate": true})
+	case result.Coalesced:
+		service.QueueOCR(result.Step.ScreenshotID)
+		respondMeta(c, http.StatusOK, result.Step, gin.H{"coalesced": true})
+	default:
+		service.QueueOCR(result.Step.ScreenshotID)
+		respond(c, http.StatusCreated, result.Step)
+	}
 }
 
 func UpdateStep(c *gin.Context) {
 	var req struct {
 		AIDescription string `json:"ai_description"`
+		AITitle       string `json:"ai_title"` // 步骤短标题
 		IsEdited      *bool  `json:"is_edited"`
+		Excluded      *bool  `json:"excluded"` // true 时不写入业务视图
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		failBind(c, err)
 		return
 	}
 	updates := map[string]interface{}{}
 	if req.AIDescription != "" {
-		updates["ai_description"] = req.AIDescription
+		updates["AIDescription"] = req.AIDescription
+	}
+	if req.AITitle = strings.TrimSpace(req.AITitle); req.AITitle != "" {
+		if utf8.RuneCountInString(req.AITitle) > maxStepTitleRunes {
+			failValidation(c, "ai_title", fmt.Sprintf("ai_title must be at most %d characters", maxStepTitleRunes))
+			return
+		}
+		updates["AITitle"] = req.AITitle
 	}
 	if req.IsEdited != nil {
 		updates["is_edited"] = *req.IsEdited
 	}
-	db.DB.Model(&db.RecordingStep{}).Where("id = ?", c.Param("stepId")).Updates(updates)
-	c.JSON(http.StatusOK, gin.H{"message": "updated"})
+	if req.Excluded != nil {
+		updates["excluded"] = *req.Excluded
+	}
+	var step db.RecordingStep
+	if err := db.DB.First(&step, "id = ?", c.Param("stepId")).Error; err != nil {
+		failNotFound(c, "step")
+		return
+	}
+	if len(updates) > 0 {
+		if err := db.DB.Model(&step).Updates(updates).Error; err != nil {
+			failInternal(c, err)
+			return
+		}
+		service.QueueEmbedding(service.EmbedStep, step.ID)
+	}
+	respond(c, http.StatusOK, step)
+}
+
+// maxStepTitleRunes 手动修改的步骤短标题的最大长度（字符），生成的短标题为 4～8 个字
+const maxStepTitleRunes = 20
+
+// RepairStepIndexes 修复会话中重复的步骤序号（历史并发上报遗留），重新编号为 1..N
+func RepairStepIndexes(c *gin.Context) {
+	var duplicated int64
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		duplicated, err = service.RepairStepIndexes(tx, c.Param("id"))
+		return err
+	})
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"duplicated_indexes": duplicated})
+}
+
+// GetDuplicateSteps 扫描会话并返回疑似重复提交的步骤（只读，不写入标记）
+func GetDuplicateSteps(c *gin.Context) {
+	duplicates, err := service.DetectDuplicateSteps(db.DB, c.Param("id"))
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, duplicates)
+}
+
+// FlagDuplicateSteps 扫描会话并将疑似重复提交的步骤标记为重复（写入 duplicate_of），返回被标记的步骤
+func FlagDuplicateSteps(c *gin.Context) {
+	var flagged []db.RecordingStep
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		flagged, err = service.FlagDuplicateSteps(tx, c.Param("id"))
+		return err
+	})
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, flagged)
+}
+
+// CleanupDuplicateSteps 一键标记并删除重复步骤，然后重新编号
+func CleanupDuplicateSteps(c *gin.Context) {
+	var removed int64
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := service.FlagDuplicateSteps(tx, c.Param("id")); err != nil {
+			return err
+		}
+		var err error
+		removed, err = service.RemoveDuplicateSteps(tx, c.Param("id"))
+		return err
+	})
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"removed": removed})
+}
+
+// GetNoiseSteps 预览会话中疑似噪声的步骤（孤立滚动、只悬停、点击空白处、随即撤销的操作）
+func GetNoiseSteps(c *gin.Context) {
+	noise, err := service.DetectNoiseSteps(db.DB, c.Param("id"))
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, noise)
+}
+
+// ApplyNoiseFilter 处理预览中的噪声步骤：默认排除出业务视图，remove 为 true 时删除并重新编号；
+// step_ids 为空时处理全部疑似噪声步骤
+func ApplyNoiseFilter(c *gin.Context) {
+	var req struct {
+		StepIDs []string `json:"step_ids"`
+		Remove  bool     `json:"remove"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			failBind(c, err)
+			return
+		}
+	}
+	var session db.Session
+	if err := db.DB.First(&session, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "session")
+		return
+	}
+	var applied []service.NoiseStep
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		applied, err = service.ApplyNoiseFilter(tx, c.Param("id"), req.StepIDs, req.Remove)
+		return err
+	})
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"applied": applied, "removed": req.Remove})
+}
+
+// DiffSession 对比两次录制的步骤（?against= 为新会话，如系统升级后重新录制），列出新增、删除与变化的步骤
+func DiffSession(c *gin.Context) {
+	against := c.Query("against")
+	if against == "" {
+		failValidation(c, "against", "against is required")
+		return
+	}
+	for _, id := range []string{c.Param("id"), against} {
+		var session db.Session
+		if err := db.DB.First(&session, "id = ?", id).Error; err != nil {
+			failNotFound(c, "session")
+			return
+		}
+	}
+	diff, err := service.DiffSessions(db.DB, c.Param("id"), against)
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, diff)
+}
+
+// GetSessionFlow 会话的业务流程图（页面与步骤节点、步骤顺序与页面跳转连线），供前端绘制流程地图
+func GetSessionFlow(c *gin.Context) {
+	graph, err := service.SessionFlow(db.DB, c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		failNotFound(c, "session")
+		return
+	}
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, graph)
 }
 
 // ─────────────────────────────────────
@@ -275,10 +1052,10 @@ func UpdateStep(c *gin.Context) {
 func GetScreenshot(c *gin.Context) {
 	var screenshot db.Screenshot
 	if err := db.DB.First(&screenshot, "id = ?", c.Param("id")).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		failNotFound(c, "screenshot")
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"data": screenshot})
+	respond(c, http.StatusOK, screenshot)
 }
 
 // ─────────────────────────────────────
@@ -288,7 +1065,7 @@ func GetScreenshot(c *gin.Context) {
 func GetMaskingProfiles(c *gin.Context) {
 	var profiles []db.MaskingProfile
 	db.DB.Preload("Rules").Find(&profiles)
-	c.JSON(http.StatusOK, gin.H{"data": profiles})
+	respond(c, http.StatusOK, profiles)
 }
 
 func CreateMaskingProfile(c *gin.Context) {
@@ -297,18 +1074,43 @@ func CreateMaskingProfile(c *gin.Context) {
 		Rules []db.MaskingRule `json:"rules"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		failBind(c, err)
+		return
+	}
+	var v checks
+	for i, rule := range req.Rules {
+		v.maskingRule(fmt.Sprintf("rules[%d].", i), rule)
+	}
+	if v.failed(c) {
 		return
 	}
-	profile := db.MaskingProfile{Name: req.Name}
-	db.DB.Create(&profile)
 
-	for _, rule := range req.Rules {
-		rule.ProfileID = profile.ID
-		db.DB.Create(&rule)
+	profile := db.MaskingProfile{Name: req.Name}
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&profile).Error; err != nil {
+			return err
+		}
+		for _, rule := range req.Rules {
+			rule.ID = ""
+			rule.ProfileID = profile.ID
+			if rule.Scope == "" {
+				rule.Scope = "session"
+			}
+			if rule.URLPattern != "" && rule.URLPatternType == "" {
+				rule.URLPatternType = service.URLPatternGlob
+			}
+			if err := tx.Create(&rule).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		failInternal(c, err)
+		return
 	}
 	db.DB.Preload("Rules").First(&profile, "id = ?", profile.ID)
-	c.JSON(http.StatusCreated, gin.H{"data": profile})
+	respond(c, http.StatusCreated, profile)
 }
 
 func AddMaskingRule(c *gin.Context) {
@@ -318,9 +1120,17 @@ func AddMaskingRule(c *gin.Context) {
 		Alias       string `json:"alias" binding:"required"`
 		Scope       string `json:"scope"`
 		Description string `json:"description"`
+		// scope 为 url 时必填
+		URLPattern     string `json:"url_pattern"`
+		URLPatternType string `json:"url_pattern_type"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		failBind(c, err)
+		return
+	}
+	var profileCount int64
+	if db.DB.Model(&db.MaskingProfile{}).Where("id = ?", c.Param("profileId")).Count(&profileCount); profileCount == 0 {
+		failNotFound(c, "masking profile")
 		return
 	}
 	scope := req.Scope
@@ -328,16 +1138,49 @@ func AddMaskingRule(c *gin.Context) {
 		scope = "session"
 	}
 	rule := db.MaskingRule{
-		ProfileID:   c.Param("profileId"),
-		RuleType:    req.RuleType,
-		Pattern:     req.Pattern,
-		Alias:       req.Alias,
-		Scope:       scope,
-		IsActive:    true,
-		Description: req.Description,
+		ProfileID:      c.Param("profileId"),
+		RuleType:       req.RuleType,
+		Pattern:        req.Pattern,
+		Alias:          req.Alias,
+		Scope:          scope,
+		IsActive:       true,
+		Description:    req.Description,
+		URLPattern:     req.URLPattern,
+		URLPatternType: req.URLPatternType,
+	}
+	if rule.URLPattern != "" && rule.URLPatternType == "" {
+		rule.URLPatternType = service.URLPatternGlob
+	}
+	var v checks
+	v.maskingRule("", rule)
+	if v.failed(c) {
+		return
+	}
+	if err := db.DB.Create(&rule).Error; err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusCreated, rule)
+}
+
+// GetApplicableMaskingRules 列出在指定页面生效的启用规则（?url=，可选 ?profile_id=），
+// 插件切换页面时据此刷新规则，URL 作用范围的规则只在匹配页面下发
+func GetApplicableMaskingRules(c *gin.Context) {
+	pageURL := c.Query("url")
+	if pageURL == "" {
+		failValidation(c, "url", "url is required")
+		return
+	}
+	query := db.DB.Order("created_at")
+	if id := c.Query("profile_id"); id != "" {
+		query = query.Where("profile_id = ?", id)
+	}
+	var rules []db.MaskingRule
+	if err := query.Find(&rules).Error; err != nil {
+		failInternal(c, err)
+		return
 	}
-	db.DB.Create(&rule)
-	c.JSON(http.StatusCreated, gin.H{"data": rule})
+	respond(c, http.StatusOK, service.ApplicableRules(rules, pageURL))
 }
 
 func GetDefaultMaskingRules(c *gin.Context) {
@@ -349,5 +1192,5 @@ func GetDefaultMaskingRules(c *gin.Context) {
 		{"pattern": `\d{4}[\s\-]?\d{4}[\s\-]?\d{4}[\s\-]?\d{4}`, "alias": "【银行卡号】", "type": "regex", "description": "银行卡号"},
 		{"pattern": `\d{6}`, "alias": "【邮政编码】", "type": "regex", "description": "邮政编码"},
 	}
-	c.JSON(http.StatusOK, gin.H{"data": defaults})
+	respond(c, http.StatusOK, defaults)
 }
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+// UploadSessionMedia 上传整段操作录像（WebM / MP4），作为文档的补充材料。
+// 支持 multipart/form-data（字段 file，可带 duration_ms）或直接以 video/* 作为请求体（?duration_ms=&file_name=）
+func UploadSessionMedia(c *gin.Context) {
+	var session db.Session
+	if err := db.DB.First(&session, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "session")
+		return
+	}
+
+	maxBytes := service.CurrentSettings().MaxMediaBytes()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1<<20)
+	in := service.MediaInput{SessionID: session.ID, MaxBytes: maxBytes, FileName: c.Query("file_name")}
+	// 录像大小事先未知：剩余配额小于单个附件上限时以剩余配额为上限
+	remaining, limited, err := service.StorageRemaining(session.ProjectID)
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	quotaCapped := limited && remaining < maxBytes
+	if quotaCapped {
+		if remaining <= 0 {
+			failStorage(c, service.ErrStorageQuotaExceeded)
+			return
+		}
+		in.MaxBytes = remaining
+	}
+	in.DurationMS, _ = strconv.ParseInt(c.DefaultPostForm("duration_ms", c.Query("duration_ms")), 10, 64)
+
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			mediaError(c, err)
+			return
+		}
+		f, err := fh.Open()
+		if err != nil {
+			failInternal(c, err)
+			return
+		}
+		defer f.Close()
+		body, in.FileName = f, fh.Filename
+	}
+	in.Body = body
+
+	media, err := service.SaveSessionMedia(getConfig().Storage.Path, in)
+	if quotaCapped && errors.Is(err, service.ErrMediaTooLarge) {
+		failStorage(c, service.ErrStorageQuotaExceeded)
+		return
+	}
+	if err != nil {
+		mediaError(c, err)
+		return
+	}
+	respond(c, http.StatusCreated, media)
+}
+
+// mediaError 将上传错误映射为对应的状态码
+func mediaError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, service.ErrMediaTooLarge), errors.As(err, &tooLarge):
+		fail(c, http.StatusRequestEntityTooLarge, ErrCodeTooLarge, "media too large")
+	case errors.Is(err, service.ErrUnsupportedMedia):
+		fail(c, http.StatusUnsupportedMediaType, ErrCodeUnsupportedMedia, err.Error())
+	case errors.Is(err, http.ErrMissingFile), err.Error() == "empty body":
+		fail(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+	default:
+		failInternal(c, err)
+	}
+}
+
+// GetSessionMedia 列出会话附件
+func GetSessionMedia(c *gin.Context) {
+	var media []db.SessionMedia
+	if err := db.DB.Where("session_id = ?", c.Param("id")).Order("created_at").Find(&media).Error; err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, media)
+}
+
+// DeleteSessionMedia 删除会话附件（记录与文件）
+func DeleteSessionMedia(c *gin.Context) {
+	var media db.SessionMedia
+	if err := db.DB.First(&media, "id = ? AND session_id = ?", c.Param("mediaId"), c.Param("id")).Error; err != nil {
+		failNotFound(c, "media")
+		return
+	}
+	if err := service.DeleteSessionMedia(getConfig().Storage.Path, &media); err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"id": media.ID, "deleted": true})
+}
+
+// GetMediaFile 返回附件文件，支持 Range 请求（浏览器内拖动播放进度）与 ETag 校验
+func GetMediaFile(c *gin.Context) {
+	var media db.SessionMedia
+	if err := db.DB.First(&media, "id = ?", c.Param("mediaId")).Error; err != nil {
+		failNotFound(c, "media")
+		return
+	}
+	path := service.MediaFilePath(getConfig().Storage.Path, &media)
+	sealed, err := service.SealedFile(path)
+	if err != nil {
+		fail(c, http.StatusGone, ErrCodeGone, "media file missing")
+		return
+	}
+	if !sealed {
+		setMediaHeaders(c, &media)
+		c.File(path)
+		return
+	}
+	// 开启静态加密时附件以密文落盘，解密后返回（仍支持 Range 与 ETag）
+	data, err := service.ReadSealedFile(path)
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	setMediaHeaders(c, &media)
+	http.ServeContent(c.Writer, c.Request, "", media.UpdatedAt, bytes.NewReader(data))
+}
+
+func setMediaHeaders(c *gin.Context, media *db.SessionMedia) {
+	c.Header("Content-Type", media.MimeType)
+	c.Header("ETag", `"`+media.SHA256[:32]+`"`)
+	c.Header("Cache-Control", "private, max-age=3600")
+}
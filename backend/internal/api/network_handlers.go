@@ -0,0 +1,103 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+// GetStepRequests 步骤触发的网络请求（按发生顺序）
+func GetStepRequests(c *gin.Context) {
+	var reqs []db.StepRequest
+	err := db.DB.Where("session_id = ? AND step_id = ?", c.Param("id"), c.Param("stepId")).
+		Order("seq").Find(&reqs).Error
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, reqs)
+}
+
+// AddStepRequests 追加步骤的网络请求。点击触发的请求往往在步骤上报之后才完成，
+// 插件在观察窗口结束后通过该接口补报；URL 在服务端脱敏，静态资源请求被丢弃
+func AddStepRequests(c *gin.Context) {
+	var req struct {
+		Requests []service.NetworkEntry `json:"requests" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	var step db.RecordingStep
+	if err := db.DB.First(&step, "id = ? AND session_id = ?", c.Param("stepId"), c.Param("id")).Error; err != nil {
+		failNotFound(c, "step")
+		return
+	}
+	var saved []db.StepRequest
+	err := db.DB.Transaction(func(tx *gorm.DB) (err error) {
+		saved, err = service.AttachStepRequests(tx, &step, service.SanitizeRequests(req.Requests))
+		return err
+	})
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respondMeta(c, http.StatusCreated, saved, gin.H{"dropped": len(req.Requests) - len(saved)})
+}
+
+// GetStepLogs 步骤执行期间的控制台输出
+func GetStepLogs(c *gin.Context) {
+	var logs []db.StepLog
+	err := db.DB.Where("session_id = ? AND step_id = ?", c.Param("id"), c.Param("stepId")).
+		Order("seq").Find(&logs).Error
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, logs)
+}
+
+// AddStepLogs 追加步骤的控制台输出（脚本错误往往在操作之后才抛出，插件延迟补报）
+func AddStepLogs(c *gin.Context) {
+	var req struct {
+		Entries []service.ConsoleEntry `json:"entries" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	var step db.RecordingStep
+	if err := db.DB.First(&step, "id = ? AND session_id = ?", c.Param("stepId"), c.Param("id")).Error; err != nil {
+		failNotFound(c, "step")
+		return
+	}
+	var saved []db.StepLog
+	err := db.DB.Transaction(func(tx *gorm.DB) (err error) {
+		saved, err = service.AttachStepLogs(tx, &step, service.SanitizeConsoleEntries(req.Entries))
+		return err
+	})
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respondMeta(c, http.StatusCreated, saved, gin.H{"dropped": len(req.Entries) - len(saved)})
+}
+
+// GetMaskingAudit 会话脱敏审计：逐步骤列出命中的规则、被替换的文本类别，
+// 并对会进入文档的字段做残留敏感信息检测（只返回类别与次数，不返回原文）
+func GetMaskingAudit(c *gin.Context) {
+	var count int64
+	if db.DB.Model(&db.Session{}).Where("id = ?", c.Param("id")).Count(&count); count == 0 {
+		failNotFound(c, "session")
+		return
+	}
+	audit, err := service.BuildMaskingAudit(c.Param("id"))
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, audit)
+}
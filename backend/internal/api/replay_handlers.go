@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+// StartReplay 在后台用无头浏览器回放会话的录制步骤并截取新截图；未配置 replay.chrome_path 时返回 503
+func StartReplay(c *gin.Context) {
+	startReplayRun(c, service.StartReplay)
+}
+
+// VerifySelectors 在后台打开各步骤所在页面，检查录制的选择器与 XPath 是否仍有效并标记失效步骤；
+// 结果与回放记录一样通过 GET /replays/:runId 查看
+func VerifySelectors(c *gin.Context) {
+	startReplayRun(c, service.StartVerification)
+}
+
+// startReplayRun 校验请求与会话后启动回放或选择器校验
+func startReplayRun(c *gin.Context, start func(string, *url.URL) (*db.ReplayRun, error)) {
+	var req struct {
+		BaseURL string `json:"base_url"` // 回放到其他环境（如测试环境）时替换录制地址的协议与主机
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			failBind(c, err)
+			return
+		}
+	}
+	var session db.Session
+	if err := db.DB.First(&session, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "session")
+		return
+	}
+	if session.PurgedAt != nil {
+		fail(c, http.StatusConflict, ErrCodeConflict, "session has been purged")
+		return
+	}
+	var base *url.URL
+	if req.BaseURL != "" {
+		u, err := url.Parse(req.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			failValidation(c, "base_url", "base_url must be an http(s) URL")
+			return
+		}
+		base = u
+	}
+
+	run, err := start(session.ID, base)
+	switch {
+	case errors.Is(err, service.ErrReplayDisabled):
+		fail(c, http.StatusServiceUnavailable, ErrCodeUnavailable, err.Error())
+		return
+	case errors.Is(err, service.ErrReplayRunning):
+		fail(c, http.StatusConflict, ErrCodeConflict, err.Error())
+		return
+	case err != nil:
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusAccepted, run)
+}
+
+// GetSessionReplays 列出会话的回放记录（最新的在前，不含步骤结果）
+func GetSessionReplays(c *gin.Context) {
+	var runs []db.ReplayRun
+	if err := db.DB.Where("session_id = ?", c.Param("id")).Order("created_at DESC").Find(&runs).Error; err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, runs)
+}
+
+// GetReplay 回放记录及各步骤的结果与新截图
+func GetReplay(c *gin.Context) {
+	var run db.ReplayRun
+	if err := db.DB.First(&run, "id = ?", c.Param("runId")).Error; err != nil {
+		failNotFound(c, "replay")
+		return
+	}
+	var steps []db.ReplayStep
+	if err := db.DB.Where("run_id = ?", run.ID).Order("step_index").Find(&steps).Error; err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"run": run, "steps": steps})
+}
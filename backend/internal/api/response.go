@@ -0,0 +1,203 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// 统一响应格式：
+//
+//	成功：{"data": ..., "meta": {...}}          meta 可选，放置分页、幂等重放等附加信息
+//	失败：{"error": {"code": "...", "message": "...", "fields": [...]}}
+//
+// code 为机器可读的错误码，客户端据此分支处理；message 仅供展示；
+// fields 为字段级校验错误（字段名与请求 JSON 键一致）
+
+// 错误码
+const (
+	ErrCodeBadRequest       = "bad_request"
+	ErrCodeValidation       = "validation_failed"
+	ErrCodeNotFound         = "not_found"
+	ErrCodeForbidden        = "forbidden"
+	ErrCodeConflict         = "conflict"
+	ErrCodeGone             = "gone"
+	ErrCodeTooLarge         = "payload_too_large"
+	ErrCodeUnsupportedMedia = "unsupported_media_type"
+	ErrCodeUnprocessable    = "unprocessable"
+	ErrCodeUpstream         = "upstream_error"
+	ErrCodeKeyInvalid       = "key_invalid"
+	ErrCodeQuotaExceeded    = "quota_exceeded"
+	ErrCodeUnavailable      = "service_unavailable"
+	ErrCodeInternal         = "internal_error"
+)
+
+// FieldError 字段级校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ErrorBody 错误响应体
+type ErrorBody struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+// respond 返回成功响应 {"data": ...}
+func respond(c *gin.Context, status int, data interface{}) {
+	c.JSON(status, gin.H{"data": data})
+}
+
+// respondMeta 返回带附加信息的成功响应 {"data": ..., "meta": ...}
+func respondMeta(c *gin.Context, status int, data interface{}, meta gin.H) {
+	c.JSON(status, gin.H{"data": data, "meta": meta})
+}
+
+// respondETag 返回成功响应并附带响应体哈希作为 ETag（meta 可为 nil）；If-None-Match 命中时返回 304 不带响应体，
+// 前端轮询时无需重复下载未变化的大响应
+func respondETag(c *gin.Context, data interface{}, meta gin.H) {
+	body := gin.H{"data": data}
+	if meta != nil {
+		body["meta"] = meta
+	}
+	raw, err := json.Marshal(body)
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	sum := sha256.Sum256(raw)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("ETag", etag)
+	if match := c.GetHeader("If-None-Match"); match != "" && etagMatches(match, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", raw)
+}
+
+// fail 返回错误响应并中止后续处理
+func fail(c *gin.Context, status int, code, message string, fields ...FieldError) {
+	c.AbortWithStatusJSON(status, gin.H{"error": ErrorBody{Code: code, Message: message, Fields: fields}})
+}
+
+// failNotFound 资源不存在，what 为资源名称（如 "session"）
+func failNotFound(c *gin.Context, what string) {
+	fail(c, http.StatusNotFound, ErrCodeNotFound, what+" not found")
+}
+
+// failInternal 服务端内部错误
+func failInternal(c *gin.Context, err error) {
+	fail(c, http.StatusInternalServerError, ErrCodeInternal, err.Error())
+}
+
+// failValidation 单个字段取值不合法，message 为完整的错误描述
+func failValidation(c *gin.Context, field, message string) {
+	fail(c, http.StatusBadRequest, ErrCodeValidation, message, FieldError{Field: field, Message: message})
+}
+
+// failBind 将请求体解析/绑定错误转换为字段级错误
+func failBind(c *gin.Context, err error) {
+	var verrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &verrs):
+		fields := make([]FieldError, 0, len(verrs))
+		msgs := make([]string, 0, len(verrs))
+		for _, fe := range verrs {
+			f := FieldError{Field: fieldPath(fe), Message: validationMessage(fe)}
+			fields = append(fields, f)
+			msgs = append(msgs, f.Field+" "+f.Message)
+		}
+		fail(c, http.StatusBadRequest, ErrCodeValidation, strings.Join(msgs, "; "), fields...)
+	case errors.As(err, &typeErr):
+		msg := "must be " + typeErr.Type.String()
+		fail(c, http.StatusBadRequest, ErrCodeValidation, typeErr.Field+" "+msg, FieldError{Field: typeErr.Field, Message: msg})
+	case errors.As(err, &syntaxErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		fail(c, http.StatusBadRequest, ErrCodeBadRequest, "invalid JSON body")
+	default:
+		fail(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+	}
+}
+
+// fieldPath 去掉顶层结构体名，得到与 JSON 键一致的字段路径（如 suggestions[0].step_id）
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.IndexByte(ns, '.'); i >= 0 {
+		return ns[i+1:]
+	}
+	return fe.Field()
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "min":
+		switch fe.Kind() {
+		case reflect.String:
+			return "must be at least " + fe.Param() + " characters"
+		case reflect.Slice, reflect.Map:
+			return "must contain at least " + fe.Param() + " item(s)"
+		}
+		return "must be >= " + fe.Param()
+	case "max":
+		switch fe.Kind() {
+		case reflect.String:
+			return "must be at most " + fe.Param() + " characters"
+		case reflect.Slice, reflect.Map:
+			return "must contain at most " + fe.Param() + " item(s)"
+		}
+		return "must be <= " + fe.Param()
+	case "gte":
+		return "must be >= " + fe.Param()
+	case "lte":
+		return "must be <= " + fe.Param()
+	case "gt":
+		return "must be > " + fe.Param()
+	}
+	return fmt.Sprintf("failed %q validation", fe.Tag())
+}
+
+var registerTagNameOnce sync.Once
+
+// useJSONFieldNames 让校验错误使用 JSON 键名而非 Go 字段名
+func useJSONFieldNames() {
+	registerTagNameOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			return
+		}
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return f.Name
+			}
+			return name
+		})
+	})
+}
+
+// recovery 将 panic 转换为统一的 500 错误响应
+func recovery(c *gin.Context, err interface{}) {
+	fail(c, http.StatusInternalServerError, ErrCodeInternal, "internal server error")
+}
@@ -1,41 +1,61 @@
 package api
 
 import (
-	"net/http"
-
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/web"
 )
 
 // SetupRouter 配置路由
 func SetupRouter() *gin.Engine {
-	r := gin.Default()
+	useJSONFieldNames()
+	r := gin.New()
+	r.Use(gin.Logger(), gin.CustomRecovery(recovery))
 
 	// CORS 配置（允许插件本地请求）
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Idempotency-Key", "If-None-Match"},
+		ExposeHeaders:    []string{"Content-Length", "Idempotent-Replayed", "ETag"},
 		AllowCredentials: false,
 	}))
 
-	// 健康检查
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok", "service": "G-Pilot Backend"})
-	})
+	// 健康检查（?deep=true 深度检查）
+	r.GET("/health", Health)
+
+	// 文档对外分享（只读页面，凭令牌访问）
+	r.GET("/share/:token", ViewSharedDocument)
 
 	api := r.Group("/api/v1")
 	{
 		// ─── 项目管理 ───
 		api.GET("/projects", GetProjects)
 		api.POST("/projects", CreateProject)
+		api.POST("/projects/import", ImportProjectBundle)
 		api.GET("/projects/:id", GetProject)
+		api.PUT("/projects/:id/retention", UpdateProjectRetention)
+		api.GET("/projects/:id/storage", GetProjectStorage) // 存储占用明细与配额
+		api.PUT("/projects/:id/storage-quota", UpdateProjectStorageQuota)
+		api.PUT("/projects/:id/merge-rules", UpdateProjectMergeRules)
+		api.PUT("/projects/:id/doc-options", UpdateProjectDocOptions)
+		api.GET("/projects/:id/site", ExportProjectSite)      // 静态站点 zip
+		api.GET("/projects/:id/index", GetProjectDocIndex)    // 跨文档目录（json|md）
+		api.POST("/projects/:id/publish", PublishProjectSite) // 发布到存储目录
+		api.PUT("/projects/:id/tags", SetProjectTags)
+		api.PUT("/projects/:id/metadata", UpdateProjectMetadata)
+		api.GET("/projects/:id/glossary", GetProjectGlossary)
+		api.PUT("/projects/:id/glossary", SetProjectGlossary)
+		api.GET("/projects/:id/bundle", ExportProjectBundle)
+		api.GET("/projects/:id/compiled", GetCompiledDocuments)
+		api.POST("/projects/:id/compiled", CreateCompiledDocument) // 合订手册：多个会话的文档汇编为一份
 		api.DELETE("/projects/:id", DeleteProject)
 
 		// ─── 录制会话 ───
 		api.GET("/sessions", GetSessions)
 		api.POST("/sessions", CreateSession)
+		api.POST("/sessions/import", ImportSession) // ?format=chrome-recorder|playwright-trace|selenium-side&project_id=
+		api.POST("/sessions/bulk-delete", BulkDeleteSessions)
 
 		// 嵌套 group，避免 :id 与 :sessionId 冲突
 		sessionGroup := api.Group("/sessions/:id")
@@ -43,33 +63,123 @@ func SetupRouter() *gin.Engine {
 			sessionGroup.GET("", GetSession)
 			sessionGroup.PATCH("/status", UpdateSessionStatus)
 			sessionGroup.DELETE("", DeleteSession)
+			sessionGroup.POST("/purge", PurgeSession) // 数据主体删除请求：清除内容，保留匿名骨架
+			sessionGroup.PUT("/tags", SetSessionTags)
 			sessionGroup.GET("/steps", GetSteps)
 			sessionGroup.POST("/steps", CreateStep)
 			sessionGroup.PATCH("/steps/:stepId", UpdateStep)
+			sessionGroup.POST("/steps/reindex", RepairStepIndexes)
+			sessionGroup.PUT("/steps/:stepId/screenshot", UploadStepScreenshot) // multipart 或 image/* 二进制
+			sessionGroup.PUT("/steps/:stepId/masked-regions", SetMaskedRegions)
+			sessionGroup.POST("/screenshots/destroy-raw", DestroyRawScreenshots) // 文档审批后销毁原图
+			sessionGroup.GET("/steps/:stepId/requests", GetStepRequests)
+			sessionGroup.POST("/steps/:stepId/requests", AddStepRequests) // 插件在请求完成后补报
+			sessionGroup.GET("/steps/:stepId/console", GetStepLogs)
+			sessionGroup.POST("/steps/:stepId/console", AddStepLogs)
+			sessionGroup.GET("/masking-audit", GetMaskingAudit)
+			sessionGroup.GET("/media", GetSessionMedia)
+			sessionGroup.POST("/media", UploadSessionMedia) // multipart 或 video/* 二进制
+			sessionGroup.DELETE("/media/:mediaId", DeleteSessionMedia)
+			sessionGroup.GET("/duplicates", GetDuplicateSteps)
+			sessionGroup.POST("/duplicates/flag", FlagDuplicateSteps)
+			sessionGroup.POST("/duplicates/cleanup", CleanupDuplicateSteps)
+			sessionGroup.GET("/noise", GetNoiseSteps)
+			sessionGroup.POST("/noise/apply", ApplyNoiseFilter)
+			sessionGroup.GET("/diff", DiffSession) // ?against=<新会话 ID>
+			sessionGroup.GET("/flow", GetSessionFlow)
 			sessionGroup.GET("/generate", GenerateDoc) // SSE 流式
+			sessionGroup.POST("/review", ReviewSession)
+			sessionGroup.POST("/review/accept", AcceptReviewSuggestions)
+			sessionGroup.POST("/replay", StartReplay)               // 后台回放，返回 202
+			sessionGroup.POST("/verify-selectors", VerifySelectors) // 后台校验选择器是否仍有效，返回 202
+			sessionGroup.GET("/replays", GetSessionReplays)
+			sessionGroup.POST("/chat", ChatSession) // 会话内多轮问答，带 conversation_id 时回答追问
+			sessionGroup.GET("/chats", GetSessionChats)
 		}
 
+		// ─── 标签 ───
+		api.GET("/tags", GetTags)
+		api.POST("/tags", CreateTag)
+		api.PATCH("/tags/:tagId", UpdateTag)
+		api.DELETE("/tags/:tagId", DeleteTag)
+
 		// ─── 截图 ───
 		api.GET("/screenshots/:id", GetScreenshot)
+		api.GET("/screenshots/:id/image", GetScreenshotImage)
+		api.POST("/screenshots/:id/ocr", ExtractScreenshotText) // 同步重新识别截图文字
+		api.GET("/search/screen-text", SearchScreenText)        // ?q=&project_id=&session_id=&limit=
+		api.GET("/search/semantic", SemanticSearch)             // ?q=&project_id=&session_id=&type=step|document&limit=
+		api.POST("/search/semantic/reindex", ReindexEmbeddings) // ?project_id=
+		api.GET("/media/:mediaId/file", GetMediaFile)
+		api.GET("/replays/:runId", GetReplay)
 
 		// ─── 脱敏规则 ───
 		api.GET("/masking/profiles", GetMaskingProfiles)
 		api.POST("/masking/profiles", CreateMaskingProfile)
 		api.POST("/masking/profiles/:profileId/rules", AddMaskingRule)
+		api.GET("/masking/rules/applicable", GetApplicableMaskingRules) // ?url=&profile_id=
 		api.GET("/masking/defaults", GetDefaultMaskingRules)
 
 		// ─── AI 相关 ───
 		api.GET("/ai/providers/status", GetProvidersStatus)
 		api.GET("/ai/steps/:stepId/describe", GenerateStepDescription)
+		api.GET("/ai/validation-failures", GetValidationFailures)
+		api.POST("/ask", Ask) // 基于手册的问答，回答附带引用来源
+		api.GET("/chats/:chatId", GetChat)
+		api.DELETE("/chats/:chatId", DeleteChat)
 
 		// ─── 文档 ───
+		api.POST("/documents/bulk-delete", BulkDeleteDocuments)
 		api.GET("/documents/:docId", GetDocument)
+		api.PATCH("/documents/:docId/status", UpdateDocumentStatus)
+		api.PUT("/documents/:docId/metadata", UpdateDocumentMetadata)
 		api.GET("/documents/:docId/export", ExportDocument)
+		api.POST("/documents/:docId/export-jobs", StartDocumentExport) // 后台导出，返回 202 与任务
+		api.POST("/documents/:docId/sections", AddDocumentSection)
+		api.PUT("/documents/:docId/sections/order", ReorderDocumentSections)
+		api.PATCH("/documents/:docId/sections/:index", UpdateDocumentSection)
+		api.DELETE("/documents/:docId/sections/:index", DeleteDocumentSection)
+		api.GET("/documents/:docId/shares", GetDocumentShares)
+		api.POST("/documents/:docId/shares", CreateDocumentShare)
+		api.DELETE("/shares/:shareId", RevokeDocumentShare)
+		api.GET("/compiled/:compiledId", GetCompiledDocument)
+		api.PUT("/compiled/:compiledId/chapters", SetCompiledChapters) // 章节顺序、分组与排除
+		api.DELETE("/compiled/:compiledId", DeleteCompiledDocument)
+		api.GET("/compiled/:compiledId/export", ExportCompiledDocument) // md|mdzip|json，查询参数同文档导出
+		api.POST("/compiled/:compiledId/export-jobs", StartCompiledExport)
+		api.GET("/export-jobs/:jobId", GetExportJob)
+		api.GET("/export-jobs/:jobId/download", DownloadExportJob) // 产物过期后返回 410
+
+		// ─── 文档模板 ───
+		api.GET("/templates", GetTemplates)
+		api.POST("/templates", CreateTemplate)
+		api.PUT("/templates/:key", UpdateTemplate)
+		api.DELETE("/templates/:key", DeleteTemplate)
+		api.GET("/templates/:key/preview", PreviewTemplate)
 
 		// ─── LLM 提供商配置 ───
 		api.GET("/llm/providers", GetLLMProviders)
 		api.PUT("/llm/providers", UpsertLLMProvider)
+		api.DELETE("/llm/providers/:id", DeleteLLMProvider)
+		api.GET("/llm/providers/:name/models", ListProviderModels)
+
+		// ─── 系统管理 ───
+		api.GET("/stats", GetStats)
+		api.GET("/settings", GetSettings)
+		api.PUT("/settings", UpdateSettings)
+		api.GET("/admin/backups", ListBackups)
+		api.POST("/admin/backups", CreateBackup)
+		api.GET("/admin/backups/:name", DownloadBackup)
+		api.POST("/admin/backups/:name/restore", RestoreBackup)
+		api.POST("/admin/restore", RestoreUploadedBackup)
+		api.POST("/admin/retention/run", RunRetention)
+		api.POST("/admin/orphans/cleanup", CleanupOrphans)
+		api.GET("/admin/storage", GetStorageUsage)
+		api.POST("/admin/demo", SeedDemo) // 导入示例项目与会话
 	}
 
+	// Web 界面（内嵌静态文件，前端路由回退到 index.html）
+	r.NoRoute(web.Handler(web.Files(getConfig().Server.WebDir)))
+
 	return r
 }
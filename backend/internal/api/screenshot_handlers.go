@@ -0,0 +1,248 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+// UploadStepScreenshot 以二进制方式上传步骤截图，避免 base64 JSON 的体积膨胀。
+// 支持 multipart/form-data（字段 file）或直接以 image/* 作为请求体；已有截图时替换
+func UploadStepScreenshot(c *gin.Context) {
+	var step db.RecordingStep
+	if err := db.DB.First(&step, "id = ? AND session_id = ?", c.Param("stepId"), c.Param("id")).Error; err != nil {
+		failNotFound(c, "step")
+		return
+	}
+
+	maxBytes := service.CurrentSettings().MaxScreenshotBytes()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(maxBytes)+1<<20)
+	data, err := readUploadBody(c, maxBytes)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			fail(c, http.StatusRequestEntityTooLarge, ErrCodeTooLarge, "screenshot too large")
+			return
+		}
+		fail(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if len(data) > maxBytes {
+		fail(c, http.StatusRequestEntityTooLarge, ErrCodeTooLarge, "screenshot too large")
+		return
+	}
+
+	shot, err := service.ScreenshotFromBytes(data)
+	if err != nil {
+		fail(c, http.StatusUnsupportedMediaType, ErrCodeUnsupportedMedia, err.Error())
+		return
+	}
+	shot.CapturedAt = step.Timestamp
+	if v, err := strconv.ParseInt(c.Query("captured_at"), 10, 64); err == nil {
+		shot.CapturedAt = v
+	}
+	// 无法解析尺寸（如 webp）时使用客户端提供的宽高
+	if shot.Width == 0 {
+		shot.Width, _ = strconv.Atoi(c.DefaultPostForm("width", c.Query("width")))
+		shot.Height, _ = strconv.Atoi(c.DefaultPostForm("height", c.Query("height")))
+	}
+	if err := service.ReserveSessionStorage(step.SessionID, int64(len(shot.DataURL))); err != nil {
+		failStorage(c, err)
+		return
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		return service.AttachScreenshot(tx, &step, shot)
+	})
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	service.QueueOCR(shot.ID)
+	respond(c, http.StatusOK, gin.H{
+		"step_id":       step.ID,
+		"screenshot_id": shot.ID,
+		"width":         shot.Width,
+		"height":        shot.Height,
+		"bytes":         len(data),
+	})
+}
+
+// readUploadBody 读取上传内容：multipart 字段 file，或整个请求体
+func readUploadBody(c *gin.Context, maxBytes int) ([]byte, error) {
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return io.ReadAll(io.LimitReader(f, int64(maxBytes)+1))
+	}
+	data, err := io.ReadAll(c.Request.Body)
+	if err == nil && len(data) == 0 {
+		return nil, errors.New("empty body")
+	}
+	return data, err
+}
+
+// SetMaskedRegions 设置步骤截图的遮蔽区域（整体替换，空数组清除）；审核时发现漏遮的信息可在此补标，
+// 原图不变，导出、发布与调用 VLM 时烧录
+func SetMaskedRegions(c *gin.Context) {
+	var req struct {
+		Regions []service.MaskRegion `json:"regions"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	var step db.RecordingStep
+	if err := db.DB.First(&step, "id = ? AND session_id = ?", c.Param("stepId"), c.Param("id")).Error; err != nil {
+		failNotFound(c, "step")
+		return
+	}
+	if step.ScreenshotID == "" {
+		failNotFound(c, "screenshot")
+		return
+	}
+	var v checks
+	v.maskRegions("regions", req.Regions)
+	if v.failed(c) {
+		return
+	}
+	encoded := service.EncodeMaskedRegions(req.Regions)
+	// 识别文字来自烧录后的图片，遮蔽区域变化后需重新识别
+	if err := db.DB.Model(&db.Screenshot{}).Where("id = ?", step.ScreenshotID).
+		Updates(map[string]interface{}{"masked_regions": encoded, "ocr_text": ""}).Error; err != nil {
+		failInternal(c, err)
+		return
+	}
+	service.QueueOCR(step.ScreenshotID)
+	regions, _ := service.ParseMaskedRegions(encoded)
+	if regions == nil {
+		regions = []service.MaskRegion{}
+	}
+	respond(c, http.StatusOK, gin.H{"screenshot_id": step.ScreenshotID, "regions": regions})
+}
+
+// DestroyRawScreenshots 会话文档审批后销毁原始截图，仅保留烧录遮蔽区域后的版本（不可恢复）
+func DestroyRawScreenshots(c *gin.Context) {
+	var session db.Session
+	if err := db.DB.First(&session, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "session")
+		return
+	}
+	result, err := docSvc.DestroyRawScreenshots(session.ID)
+	if errors.Is(err, service.ErrNotApproved) {
+		fail(c, http.StatusConflict, ErrCodeConflict, "approve a document of this session before destroying raw screenshots")
+		return
+	}
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, result)
+}
+
+// GetScreenshotImage 以图片字节返回截图，带 ETag（内容哈希）和缓存头，
+// 前端和导出可直接引用 /api/v1/screenshots/:id/image，无需在 JSON 中内嵌 data URL；
+// ?variant=element 返回目标元素局部图
+func GetScreenshotImage(c *gin.Context) {
+	var screenshot db.Screenshot
+	if err := db.DB.First(&screenshot, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "screenshot")
+		return
+	}
+	if screenshot.DataURL == "" {
+		// 已按保留策略清除
+		fail(c, http.StatusGone, ErrCodeGone, "screenshot purged")
+		return
+	}
+	dataURL := screenshot.DataURL
+	switch c.DefaultQuery("variant", "full") {
+	case "full":
+	case "element":
+		if screenshot.ElementURL == "" {
+			failNotFound(c, "element screenshot")
+			return
+		}
+		dataURL = screenshot.ElementURL
+	default:
+		failValidation(c, "variant", "must be full or element")
+		return
+	}
+	mime, data, err := service.ParseDataURL(string(dataURL))
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	// 截图可被原地替换，因此要求每次用 ETag 重新校验
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("ETag", etag)
+	if match := c.GetHeader("If-None-Match"); match != "" && etagMatches(match, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, mime, data)
+}
+
+// etagMatches 判断 If-None-Match 是否命中（支持逗号分隔列表、弱校验前缀和 *）
+func etagMatches(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == etag || v == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractScreenshotText 同步重新识别截图文字（上传后的后台识别失败或更换识别语言时使用）
+func ExtractScreenshotText(c *gin.Context) {
+	text, err := service.ExtractScreenshotText(c.Param("id"))
+	switch {
+	case errors.Is(err, service.ErrOCRDisabled):
+		fail(c, http.StatusServiceUnavailable, ErrCodeUnavailable, "ocr is not configured")
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		failNotFound(c, "screenshot")
+	case err != nil:
+		fail(c, http.StatusBadGateway, ErrCodeUpstream, err.Error())
+	default:
+		respond(c, http.StatusOK, gin.H{"screenshot_id": c.Param("id"), "ocr_text": text})
+	}
+}
+
+// SearchScreenText 按截图上识别出的文字检索步骤，如 ?q=缴费 找出出现“缴费”按钮的步骤
+func SearchScreenText(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		failValidation(c, "q", "q is required")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 || limit > 500 {
+		failValidation(c, "limit", "limit must be 1-500")
+		return
+	}
+	hits, err := service.SearchScreenText(query, c.Query("project_id"), c.Query("session_id"), limit)
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respondMeta(c, http.StatusOK, hits, gin.H{"total": len(hits), "ocr_enabled": service.OCREnabled()})
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+// SemanticSearch 按语义检索步骤与文档，如 ?q=怎么退回申请 能找到描述为“点击【驳回】按钮”的步骤
+func SemanticSearch(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		failValidation(c, "q", "q is required")
+		return
+	}
+	if t := c.Query("type"); t != "" && t != service.EmbedStep && t != service.EmbedDocument {
+		failValidation(c, "type", "type must be one of: step, document")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 200 {
+		failValidation(c, "limit", "limit must be 1-200")
+		return
+	}
+	hits, err := service.SemanticSearch(query, c.Query("project_id"), c.Query("session_id"), c.Query("type"), limit)
+	switch {
+	case errors.Is(err, service.ErrEmbeddingDisabled):
+		fail(c, http.StatusServiceUnavailable, ErrCodeUnavailable, "semantic search is not configured")
+	case err != nil:
+		fail(c, http.StatusBadGateway, ErrCodeUpstream, err.Error())
+	default:
+		respondMeta(c, http.StatusOK, hits, gin.H{"total": len(hits), "model": service.EmbeddingModel()})
+	}
+}
+
+// ReindexEmbeddings 重建语义检索索引（?project_id= 限定项目），补齐缺失与过期的条目；更换向量模型后调用
+func ReindexEmbeddings(c *gin.Context) {
+	result, err := service.ReindexEmbeddings(c.Query("project_id"))
+	switch {
+	case errors.Is(err, service.ErrEmbeddingDisabled):
+		fail(c, http.StatusServiceUnavailable, ErrCodeUnavailable, "semantic search is not configured")
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		failNotFound(c, "project")
+	case err != nil:
+		fail(c, http.StatusBadGateway, ErrCodeUpstream, err.Error())
+	default:
+		respond(c, http.StatusOK, result)
+	}
+}
+
+// Ask 基于已生成的手册回答操作问题（如“如何补打回执？”），回答附带引用的文档与步骤；
+// 可用 project_id / session_id 限定范围，?free_only=true 或项目开启仅免费生成时只使用免费提供商
+func Ask(c *gin.Context) {
+	var req struct {
+		Question  string `json:"question" binding:"required"`
+		ProjectID string `json:"project_id"`
+		SessionID string `json:"session_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	req.Question = strings.TrimSpace(req.Question)
+	if n := utf8.RuneCountInString(req.Question); n == 0 || n > maxQuestionRunes {
+		failValidation(c, "question", fmt.Sprintf("question must be 1-%d characters", maxQuestionRunes))
+		return
+	}
+	var v checks
+	v.exists("project_id", req.ProjectID, &db.Project{})
+	v.exists("session_id", req.SessionID, &db.Session{})
+	if v.failed(c) {
+		return
+	}
+
+	projectID := req.ProjectID
+	if req.SessionID != "" {
+		var session db.Session
+		db.DB.Select("project_id").First(&session, "id = ?", req.SessionID)
+		projectID = session.ProjectID
+	}
+	ai := aiSvc
+	var project db.Project
+	db.DB.Select("free_only").First(&project, "id = ?", projectID)
+	if free, _ := strconv.ParseBool(c.Query("free_only")); free || project.FreeOnly {
+		ai = ai.FreeOnly()
+	}
+	ai = ai.WithGlossary(service.ProjectGlossary(projectID)).WithBannedPhrases(service.ProjectBannedPhrases(projectID))
+
+	answer, err := ai.Ask(req.Question, req.ProjectID, req.SessionID)
+	switch {
+	case errors.Is(err, service.ErrEmbeddingDisabled):
+		fail(c, http.StatusServiceUnavailable, ErrCodeUnavailable, "semantic search is not configured")
+	case err != nil:
+		fail(c, http.StatusBadGateway, ErrCodeUpstream, err.Error())
+	default:
+		respond(c, http.StatusOK, answer)
+	}
+}
+
+// maxQuestionRunes 问题的最大长度（字符）
+const maxQuestionRunes = 500
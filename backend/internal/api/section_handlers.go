@@ -0,0 +1,109 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+// editDocumentSections 加载文档并修改 ?view=（business | technical，默认 business）的章节，返回修改后的章节列表
+func editDocumentSections(c *gin.Context, status int, edit func([]service.DocSection) ([]service.DocSection, error)) {
+	view := c.DefaultQuery("view", "business")
+	var v checks
+	v.oneOf("view", view, []string{"business", "technical"})
+	if v.failed(c) {
+		return
+	}
+	var doc db.GeneratedDocument
+	if err := db.DB.First(&doc, "id = ?", c.Param("docId")).Error; err != nil {
+		failNotFound(c, "document")
+		return
+	}
+	sections, err := docSvc.EditSections(&doc, view, edit)
+	switch {
+	case errors.Is(err, service.ErrSectionNotFound):
+		failNotFound(c, "section")
+	case errors.Is(err, service.ErrSectionOrder):
+		failValidation(c, "order", err.Error())
+	case errors.Is(err, service.ErrSectionHasSteps):
+		fail(c, http.StatusConflict, ErrCodeConflict, err.Error())
+	case err != nil:
+		failInternal(c, err)
+	default:
+		respond(c, status, gin.H{"id": doc.ID, "view": view, "sections": sections})
+	}
+}
+
+// sectionIndex 解析路径中的章节序号（1 起）
+func sectionIndex(c *gin.Context) (int, bool) {
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 1 {
+		failValidation(c, "index", "index must be a positive integer")
+		return 0, false
+	}
+	return index, true
+}
+
+// AddDocumentSection 新增空章节，position 为插入位置（1 起，默认末尾）
+func AddDocumentSection(c *gin.Context) {
+	var req struct {
+		Title    string `json:"title"    binding:"required,max=200"`
+		Summary  string `json:"summary"  binding:"max=2000"`
+		Position int    `json:"position" binding:"min=0"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	editDocumentSections(c, http.StatusCreated, func(sections []service.DocSection) ([]service.DocSection, error) {
+		return service.AddSection(sections, req.Position, req.Title, req.Summary), nil
+	})
+}
+
+// UpdateDocumentSection 重命名章节或修改摘要
+func UpdateDocumentSection(c *gin.Context) {
+	var req struct {
+		Title   *string `json:"title"   binding:"omitempty,min=1,max=200"`
+		Summary *string `json:"summary" binding:"omitempty,max=2000"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	index, ok := sectionIndex(c)
+	if !ok {
+		return
+	}
+	editDocumentSections(c, http.StatusOK, func(sections []service.DocSection) ([]service.DocSection, error) {
+		return service.RenameSection(sections, index, req.Title, req.Summary)
+	})
+}
+
+// ReorderDocumentSections 按现有章节序号的排列重排章节，如 {"order": [2, 1, 3]}
+func ReorderDocumentSections(c *gin.Context) {
+	var req struct {
+		Order []int `json:"order" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	editDocumentSections(c, http.StatusOK, func(sections []service.DocSection) ([]service.DocSection, error) {
+		return service.ReorderSections(sections, req.Order)
+	})
+}
+
+// DeleteDocumentSection 删除章节，其中的步骤并入相邻章节
+func DeleteDocumentSection(c *gin.Context) {
+	index, ok := sectionIndex(c)
+	if !ok {
+		return
+	}
+	editDocumentSections(c, http.StatusOK, func(sections []service.DocSection) ([]service.DocSection, error) {
+		return service.RemoveSection(sections, index)
+	})
+}
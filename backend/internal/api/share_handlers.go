@@ -0,0 +1,91 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+// CreateDocumentShare 为已审批文档生成限时分享链接；令牌只在本次响应中返回
+func CreateDocumentShare(c *gin.Context) {
+	var req struct {
+		ExpiresInHours int    `json:"expires_in_hours" binding:"omitempty,min=1,max=2160"` // 默认 7 天，最长 90 天
+		Note           string `json:"note"             binding:"max=200"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	var doc db.GeneratedDocument
+	if err := db.DB.First(&doc, "id = ?", c.Param("docId")).Error; err != nil {
+		failNotFound(c, "document")
+		return
+	}
+
+	ttl := service.DefaultShareTTL
+	if req.ExpiresInHours > 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+	share, token, err := service.CreateShare(&doc, ttl, req.Note)
+	if errors.Is(err, service.ErrShareNotApproved) {
+		fail(c, http.StatusConflict, ErrCodeConflict, err.Error())
+		return
+	}
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusCreated, gin.H{"share": share, "token": token, "url": "/share/" + token})
+}
+
+// GetDocumentShares 列出文档的分享链接（不含令牌）
+func GetDocumentShares(c *gin.Context) {
+	var shares []db.DocumentShare
+	if err := db.DB.Where("document_id = ?", c.Param("docId")).Order("created_at DESC").Find(&shares).Error; err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, shares)
+}
+
+// RevokeDocumentShare 撤销分享链接，记录保留用于审计
+func RevokeDocumentShare(c *gin.Context) {
+	var share db.DocumentShare
+	if err := db.DB.First(&share, "id = ?", c.Param("shareId")).Error; err != nil {
+		failNotFound(c, "share")
+		return
+	}
+	if share.RevokedAt == nil {
+		now := time.Now()
+		if err := db.DB.Model(&share).Update("revoked_at", &now).Error; err != nil {
+			failInternal(c, err)
+			return
+		}
+	}
+	db.DB.First(&share, "id = ?", share.ID)
+	respond(c, http.StatusOK, share)
+}
+
+// ViewSharedDocument 对外只读页面：令牌有效时返回渲染后的文档 HTML，否则 404
+func ViewSharedDocument(c *gin.Context) {
+	// 令牌在 URL 中，禁止缓存、检索和通过 Referer 外泄
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Robots-Tag", "noindex, nofollow")
+	c.Header("Referrer-Policy", "no-referrer")
+
+	_, doc, err := service.ResolveShare(c.Param("token"), time.Now())
+	if err != nil {
+		c.Data(http.StatusNotFound, "text/plain; charset=utf-8", []byte("分享链接无效或已过期"))
+		return
+	}
+	html, err := docSvc.RenderShare(doc)
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", html)
+}
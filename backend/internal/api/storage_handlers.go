@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+// maxStorageQuotaMB 项目存储配额上限（1 TB）
+const maxStorageQuotaMB = 1 << 20
+
+// failStorage 写入前的存储配额检查失败：超出配额返回 413 quota_exceeded，其余为 500
+func failStorage(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrStorageQuotaExceeded) {
+		fail(c, http.StatusRequestEntityTooLarge, ErrCodeQuotaExceeded, err.Error())
+		return
+	}
+	failInternal(c, err)
+}
+
+// GetProjectStorage 项目的存储占用明细（截图、附件、导出产物）与配额
+func GetProjectStorage(c *gin.Context) {
+	usage, err := service.ProjectStorageUsage(db.DB, c.Param("id"))
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	if len(usage) == 0 {
+		failNotFound(c, "project")
+		return
+	}
+	respond(c, http.StatusOK, usage[0])
+}
+
+// UpdateProjectStorageQuota 设置项目存储配额（MB，0 表示不限）；已超出新配额时不删除数据，只拒绝新的写入
+func UpdateProjectStorageQuota(c *gin.Context) {
+	var req struct {
+		StorageQuotaMB *int `json:"storage_quota_mb" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	if *req.StorageQuotaMB < 0 || *req.StorageQuotaMB > maxStorageQuotaMB {
+		failValidation(c, "storage_quota_mb", "storage_quota_mb must be 0-1048576")
+		return
+	}
+	var project db.Project
+	if err := db.DB.First(&project, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "project")
+		return
+	}
+	if err := db.DB.Model(&project).Update("storage_quota_mb", *req.StorageQuotaMB).Error; err != nil {
+		failInternal(c, err)
+		return
+	}
+	GetProjectStorage(c)
+}
+
+// GetStorageUsage 各项目的存储占用明细与配额，按占用从大到小排列；meta 中为全部项目的合计
+func GetStorageUsage(c *gin.Context) {
+	usage, err := service.ProjectStorageUsage(db.DB)
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	var total service.ProjectStorage
+	exceeded := 0
+	for _, u := range usage {
+		total.ScreenshotBytes += u.ScreenshotBytes
+		total.MediaBytes += u.MediaBytes
+		total.ExportBytes += u.ExportBytes
+		total.TotalBytes += u.TotalBytes
+		if u.Exceeded {
+			exceeded++
+		}
+	}
+	respondMeta(c, http.StatusOK, usage, gin.H{
+		"screenshot_bytes":  total.ScreenshotBytes,
+		"media_bytes":       total.MediaBytes,
+		"export_bytes":      total.ExportBytes,
+		"total_bytes":       total.TotalBytes,
+		"projects_exceeded": exceeded,
+	})
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/config"
+	"github.com/gpilot/backend/internal/service"
+)
+
+var appCfg *config.Config
+
+// SetConfig 注入全局配置（未注入时使用环境变量默认值）
+func SetConfig(cfg *config.Config) {
+	appCfg = cfg
+}
+
+func getConfig() *config.Config {
+	if appCfg == nil {
+		appCfg = config.Load()
+	}
+	return appCfg
+}
+
+// Health 健康检查；?deep=true 检查各组件，?providers=true 额外 ping VLM 提供商
+func Health(c *gin.Context) {
+	if c.Query("deep") != "true" {
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "service": "G-Pilot Backend"})
+		return
+	}
+
+	checker := service.NewHealthChecker(getConfig().Storage, aiSvc)
+	report := checker.Check(c.Query("providers") == "true")
+
+	code := http.StatusOK
+	if report.Status == service.HealthDown {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{
+		"status":     report.Status,
+		"service":    "G-Pilot Backend",
+		"checked_at": report.CheckedAt,
+		"components": report.Components,
+	})
+}
+
+// GetStats 管理看板使用统计；?weeks= 指定会话趋势周数（默认 12，最多 104）
+func GetStats(c *gin.Context) {
+	weeks, err := strconv.Atoi(c.DefaultQuery("weeks", "12"))
+	if err != nil || weeks < 1 || weeks > 104 {
+		failValidation(c, "weeks", "weeks must be 1-104")
+		return
+	}
+	stats, err := service.CollectStats(getConfig().Storage.Path, weeks, time.Now())
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, stats)
+}
+
+// GetSettings 返回当前生效的运行时设置
+func GetSettings(c *gin.Context) {
+	settings, err := service.LoadSettings()
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, settings)
+}
+
+// UpdateSettings 部分更新运行时设置，只需提交要修改的项，保存后立即生效无需重启
+func UpdateSettings(c *gin.Context) {
+	var patch map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		failBind(c, err)
+		return
+	}
+	settings, err := service.UpdateSettings(patch)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSettings) {
+			fail(c, http.StatusBadRequest, ErrCodeValidation, err.Error())
+			return
+		}
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, settings)
+}
+
+// ─────────────────────────────────────
+// 备份与恢复
+// ─────────────────────────────────────
+
+func backupService() *service.BackupService {
+	return service.NewBackupService(getConfig().Storage.Path)
+}
+
+// ListBackups 列出已保存的备份
+func ListBackups(c *gin.Context) {
+	list, err := backupService().ListBackups()
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, list)
+}
+
+// CreateBackup 生成数据库 + 截图存储的完整备份
+func CreateBackup(c *gin.Context) {
+	name, manifest, err := backupService().CreateBackupFile()
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusCreated, gin.H{"name": name, "manifest": manifest})
+}
+
+// DownloadBackup 下载备份归档
+func DownloadBackup(c *gin.Context) {
+	full, err := backupService().BackupPath(c.Param("name"))
+	if err != nil {
+		failNotFound(c, "backup")
+		return
+	}
+	c.FileAttachment(full, c.Param("name"))
+}
+
+// RestoreBackup 从已保存的备份恢复；?dry_run=true 时仅做完整性校验
+func RestoreBackup(c *gin.Context) {
+	svc := backupService()
+	full, err := svc.BackupPath(c.Param("name"))
+	if err != nil {
+		failNotFound(c, "backup")
+		return
+	}
+	f, err := os.Open(full)
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	defer f.Close()
+	info, _ := f.Stat()
+	restoreFrom(c, svc, f, info.Size())
+}
+
+// RestoreUploadedBackup 从上传的备份归档（multipart 字段 file）恢复
+func RestoreUploadedBackup(c *gin.Context) {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		failValidation(c, "file", "file is required")
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		fail(c, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	defer f.Close()
+	restoreFrom(c, backupService(), f, fh.Size)
+}
+
+func restoreFrom(c *gin.Context, svc *service.BackupService, r io.ReaderAt, size int64) {
+	if c.Query("dry_run") == "true" {
+		manifest, err := svc.VerifyBackup(r, size)
+		if err != nil {
+			fail(c, http.StatusUnprocessableEntity, ErrCodeUnprocessable, err.Error())
+			return
+		}
+		respondMeta(c, http.StatusOK, manifest, gin.H{"restored": false})
+		return
+	}
+	manifest, err := svc.RestoreBackup(r, size)
+	if err != nil {
+		fail(c, http.StatusUnprocessableEntity, ErrCodeUnprocessable, err.Error())
+		return
+	}
+	respondMeta(c, http.StatusOK, manifest, gin.H{"restored": true})
+}
+
+// ─────────────────────────────────────
+// 数据保留策略
+// ─────────────────────────────────────
+
+// RunRetention 立即执行一次数据保留策略清理
+func RunRetention(c *gin.Context) {
+	result, err := service.NewRetentionService(getConfig().Retention.Interval, getConfig().Storage.Path).RunOnce(time.Now())
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, result)
+}
+
+// CleanupOrphans 立即清理上级记录已不存在的孤儿记录（保留策略的定时任务每轮也会执行），返回按表统计的删除行数
+func CleanupOrphans(c *gin.Context) {
+	result, err := service.NewRetentionService(getConfig().Retention.Interval, getConfig().Storage.Path).CleanupOrphans()
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, result)
+}
+
+// ─────────────────────────────────────
+// 示例数据
+// ─────────────────────────────────────
+
+// SeedDemo 导入示例项目与录制会话（含示意截图），无需浏览器插件即可体验文档生成；已导入时返回 409
+func SeedDemo(c *gin.Context) {
+	result, err := service.SeedDemo()
+	if errors.Is(err, service.ErrDemoSeeded) {
+		fail(c, http.StatusConflict, ErrCodeConflict, "demo project already exists: "+result.ProjectID)
+		return
+	}
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusCreated, result)
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+// ─────────────────────────────────────
+// Tag
+// ─────────────────────────────────────
+
+// GetTags 列出全部标签及其关联的项目、会话数量
+func GetTags(c *gin.Context) {
+	type tagUsage struct {
+		db.Tag
+		ProjectCount int64 `json:"project_count"`
+		SessionCount int64 `json:"session_count"`
+	}
+	var tags []db.Tag
+	db.DB.Order("name").Find(&tags)
+	out := make([]tagUsage, len(tags))
+	for i, t := range tags {
+		out[i].Tag = t
+		db.DB.Table("project_tags").Where("tag_id = ?", t.ID).Count(&out[i].ProjectCount)
+		db.DB.Table("session_tags").Where("tag_id = ?", t.ID).Count(&out[i].SessionCount)
+	}
+	respond(c, http.StatusOK, out)
+}
+
+func CreateTag(c *gin.Context) {
+	var req struct {
+		Name  string `json:"name" binding:"required"`
+		Color string `json:"color"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	names, err := service.NormalizeTagNames([]string{req.Name})
+	if err != nil {
+		failValidation(c, "name", err.Error())
+		return
+	}
+	var count int64
+	db.DB.Model(&db.Tag{}).Where("name = ?", names[0]).Count(&count)
+	if count > 0 {
+		fail(c, http.StatusConflict, ErrCodeConflict, "tag already exists")
+		return
+	}
+	tag := db.Tag{Name: names[0], Color: req.Color}
+	if err := db.DB.Create(&tag).Error; err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusCreated, tag)
+}
+
+// UpdateTag 重命名标签或修改颜色
+func UpdateTag(c *gin.Context) {
+	var req struct {
+		Name  *string `json:"name"`
+		Color *string `json:"color"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	var tag db.Tag
+	if err := db.DB.First(&tag, "id = ?", c.Param("tagId")).Error; err != nil {
+		failNotFound(c, "tag")
+		return
+	}
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		names, err := service.NormalizeTagNames([]string{*req.Name})
+		if err != nil {
+			failValidation(c, "name", err.Error())
+			return
+		}
+		var count int64
+		db.DB.Model(&db.Tag{}).Where("name = ? AND id <> ?", names[0], tag.ID).Count(&count)
+		if count > 0 {
+			fail(c, http.StatusConflict, ErrCodeConflict, "tag already exists")
+			return
+		}
+		updates["name"] = names[0]
+	}
+	if req.Color != nil {
+		updates["color"] = *req.Color
+	}
+	if len(updates) > 0 {
+		db.DB.Model(&tag).Updates(updates)
+	}
+	db.DB.First(&tag, "id = ?", tag.ID)
+	respond(c, http.StatusOK, tag)
+}
+
+// DeleteTag 删除标签，并解除其与项目、会话的关联
+func DeleteTag(c *gin.Context) {
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		return service.DeleteTag(tx, c.Param("tagId"))
+	})
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, gin.H{"id": c.Param("tagId"), "deleted": true})
+}
+
+// SetProjectTags 整体替换项目标签，不存在的标签名自动创建
+func SetProjectTags(c *gin.Context) {
+	var project db.Project
+	if err := db.DB.First(&project, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "project")
+		return
+	}
+	setTags(c, &project)
+}
+
+// SetSessionTags 整体替换会话标签，不存在的标签名自动创建
+func SetSessionTags(c *gin.Context) {
+	var session db.Session
+	if err := db.DB.First(&session, "id = ?", c.Param("id")).Error; err != nil {
+		failNotFound(c, "session")
+		return
+	}
+	setTags(c, &session)
+}
+
+func setTags(c *gin.Context, owner interface{}) {
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	var tags []db.Tag
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		tags, err = service.SetTags(tx, owner, req.Tags)
+		return err
+	})
+	if errors.Is(err, service.ErrInvalidTagName) {
+		failValidation(c, "tags", err.Error())
+		return
+	}
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, tags)
+}
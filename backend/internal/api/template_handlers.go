@@ -0,0 +1,141 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+// templateRequest 新建 / 更新模板的请求体
+type templateRequest struct {
+	Key         string `json:"key"`
+	Name        string `json:"name"        binding:"required,max=100"`
+	Description string `json:"description" binding:"max=1000"`
+	View        string `json:"view"`
+	Preface     string `json:"preface"     binding:"max=20000"`
+	Closing     string `json:"closing"     binding:"max=20000"`
+}
+
+// GetTemplates 列出文档模板（内置模板在前）
+func GetTemplates(c *gin.Context) {
+	templates, err := service.ListTemplates()
+	if err != nil {
+		failInternal(c, err)
+		return
+	}
+	respond(c, http.StatusOK, templates)
+}
+
+// CreateTemplate 新建自定义模板
+func CreateTemplate(c *gin.Context) {
+	var req templateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	var v checks
+	if !service.TemplateKeyPattern.MatchString(req.Key) {
+		v.add("key", "must be 1-64 lowercase letters, digits, - or _")
+	}
+	if req.View != "" {
+		v.oneOf("view", req.View, service.DocViews)
+	}
+	if v.failed(c) {
+		return
+	}
+	t := db.DocTemplate{Key: req.Key}
+	saveTemplate(c, &t, req, true)
+}
+
+// UpdateTemplate 更新自定义模板（标识不可修改）；内置模板返回 403
+func UpdateTemplate(c *gin.Context) {
+	var req templateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		failBind(c, err)
+		return
+	}
+	if req.View != "" {
+		var v checks
+		v.oneOf("view", req.View, service.DocViews)
+		if v.failed(c) {
+			return
+		}
+	}
+	t, ok := service.FindTemplate(c.Param("key"))
+	if !ok {
+		failNotFound(c, "template")
+		return
+	}
+	saveTemplate(c, t, req, false)
+}
+
+func saveTemplate(c *gin.Context, t *db.DocTemplate, req templateRequest, create bool) {
+	t.Name, t.Description, t.View, t.Preface, t.Closing = req.Name, req.Description, req.View, req.Preface, req.Closing
+	err := service.SaveTemplate(t, create)
+	switch {
+	case errors.Is(err, service.ErrTemplateBuiltIn):
+		fail(c, http.StatusForbidden, ErrCodeForbidden, err.Error())
+	case errors.Is(err, service.ErrTemplateExists):
+		fail(c, http.StatusConflict, ErrCodeConflict, err.Error())
+	case err != nil:
+		failInternal(c, err)
+	case create:
+		respond(c, http.StatusCreated, t)
+	default:
+		respond(c, http.StatusOK, t)
+	}
+}
+
+// DeleteTemplate 删除自定义模板；内置模板返回 403，仍被项目使用时返回 409
+func DeleteTemplate(c *gin.Context) {
+	t, ok := service.FindTemplate(c.Param("key"))
+	if !ok {
+		failNotFound(c, "template")
+		return
+	}
+	err := service.DeleteTemplate(t)
+	switch {
+	case errors.Is(err, service.ErrTemplateBuiltIn):
+		fail(c, http.StatusForbidden, ErrCodeForbidden, err.Error())
+	case errors.Is(err, service.ErrTemplateInUse):
+		fail(c, http.StatusConflict, ErrCodeConflict, err.Error())
+	case err != nil:
+		failInternal(c, err)
+	default:
+		respond(c, http.StatusOK, gin.H{"key": t.Key, "deleted": true})
+	}
+}
+
+// PreviewTemplate 以模板渲染 Markdown 预览：?doc_id= 使用已有文档，否则使用示例文档；?view= 覆盖模板的默认视图
+func PreviewTemplate(c *gin.Context) {
+	t, ok := service.FindTemplate(c.Param("key"))
+	if !ok {
+		failNotFound(c, "template")
+		return
+	}
+	content := service.SampleDocContent()
+	if docID := c.Query("doc_id"); docID != "" {
+		var doc db.GeneratedDocument
+		if err := db.DB.First(&doc, "id = ?", docID).Error; err != nil {
+			failNotFound(c, "document")
+			return
+		}
+		var err error
+		if content, err = docSvc.LoadDocument(&doc); err != nil {
+			failInternal(c, err)
+			return
+		}
+		content = docSvc.RedactContent(content, service.RedactBlack)
+	}
+	service.ApplyTemplate(content, t)
+
+	viewType := c.DefaultQuery("view", service.TemplateView(t.Key))
+	if !service.OneOf(viewType, service.DocViews) {
+		failValidation(c, "view", "view must be one of: business, technical, both")
+		return
+	}
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(docSvc.GenerateMarkdown(content, viewType)))
+}
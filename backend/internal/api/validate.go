@@ -0,0 +1,88 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+// checks 收集请求的语义校验错误（枚举取值、引用的记录是否存在等），
+// 全部检查完后通过 failed 一次性以 422 返回，便于客户端逐字段提示
+type checks struct {
+	fields []FieldError
+}
+
+func (v *checks) add(field, format string, args ...interface{}) {
+	v.fields = append(v.fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// oneOf 校验枚举取值
+func (v *checks) oneOf(field, value string, allowed []string) {
+	if !service.OneOf(value, allowed) {
+		v.add(field, "must be one of: %s (got %q)", strings.Join(allowed, ", "), value)
+	}
+}
+
+// exists 校验引用的记录存在；id 为空时跳过（必填由 binding 负责）
+func (v *checks) exists(field, id string, model interface{}) {
+	if id == "" {
+		return
+	}
+	var count int64
+	if err := db.DB.Model(model).Where("id = ?", id).Count(&count).Error; err != nil || count == 0 {
+		v.add(field, "references a nonexistent record (%s)", id)
+	}
+}
+
+// maskingRule 校验脱敏规则的类型、作用范围以及正则（含 URL 模式）能否编译
+func (v *checks) maskingRule(prefix string, rule db.MaskingRule) {
+	v.oneOf(prefix+"rule_type", rule.RuleType, service.MaskingRuleTypes)
+	if rule.Scope != "" {
+		v.oneOf(prefix+"scope", rule.Scope, service.MaskingScopes)
+	}
+	if rule.RuleType == "regex" {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			v.add(prefix+"pattern", "is not a valid regular expression: %v", err)
+		}
+	}
+	switch {
+	case rule.Scope == service.MaskingScopeURL && rule.URLPattern == "":
+		v.add(prefix+"url_pattern", "is required for url scope")
+	case rule.Scope != service.MaskingScopeURL && rule.URLPattern != "":
+		v.add(prefix+"url_pattern", "is only allowed with url scope")
+	case rule.URLPattern != "":
+		if rule.URLPatternType != "" {
+			v.oneOf(prefix+"url_pattern_type", rule.URLPatternType, service.URLPatternTypes)
+		}
+		if _, err := service.CompileURLPattern(rule.URLPatternType, rule.URLPattern); err != nil {
+			v.add(prefix+"url_pattern", "is not a valid pattern: %v", err)
+		}
+	}
+}
+
+// maskRegions 校验截图遮蔽区域：坐标非负、宽高为正
+func (v *checks) maskRegions(field string, regions []service.MaskRegion) {
+	for i, r := range regions {
+		if r.X < 0 || r.Y < 0 || r.Width <= 0 || r.Height <= 0 {
+			v.add(fmt.Sprintf("%s[%d]", field, i), "must have non-negative x/y and positive width/height")
+		}
+	}
+}
+
+// failed 存在校验错误时返回 422 并中止处理
+func (v *checks) failed(c *gin.Context) bool {
+	if len(v.fields) == 0 {
+		return false
+	}
+	msgs := make([]string, len(v.fields))
+	for i, f := range v.fields {
+		msgs[i] = f.Field + " " + f.Message
+	}
+	fail(c, http.StatusUnprocessableEntity, ErrCodeValidation, strings.Join(msgs, "; "), v.fields...)
+	return true
+}
@@ -1,23 +1,139 @@
 package config
 
 import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config 全局配置
 type Config struct {
-	Server  ServerConfig
-	DB      DBConfig
-	LLM     LLMConfig
+	Server    ServerConfig
+	DB        DBConfig
+	Storage   StorageConfig
+	Retention RetentionConfig
+	LLM       LLMConfig
+	OCR       OCRConfig
+	Replay    ReplayConfig
+	Embedding EmbeddingConfig
 }
 
 type ServerConfig struct {
+	Host string // 监听地址，空表示所有网卡；仅本机访问时设为 127.0.0.1
 	Port string
 	Mode string // "debug" | "release"
+
+	// HTTPS：证书文件与 autocert 二选一，均未配置时使用 HTTP
+	TLSCertFile      string
+	TLSKeyFile       string
+	AutocertDomains  string // 逗号分隔的域名，通过 Let's Encrypt 自动签发证书
+	AutocertEmail    string
+	AutocertCacheDir string // 证书缓存目录，默认 <storage.path>/autocert
+	HTTPRedirectPort string // 启用 HTTPS 时额外监听的 HTTP 端口，跳转到 HTTPS（autocert 时兼做 HTTP-01 验证）
+
+	WebDir string // Web 界面静态文件目录，为空时使用编译进二进制的文件
+}
+
+// Addr 监听地址 host:port
+func (s ServerConfig) Addr() string {
+	return net.JoinHostPort(s.Host, s.Port)
+}
+
+// TLSEnabled 是否启用 HTTPS
+func (s ServerConfig) TLSEnabled() bool {
+	return s.TLSCertFile != "" || s.AutocertDomains != ""
+}
+
+// Domains autocert 域名列表
+func (s ServerConfig) Domains() []string {
+	var out []string
+	for _, d := range strings.Split(s.AutocertDomains, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			out = append(out, d)
+		}
+	}
+	return out
 }
 
 type DBConfig struct {
-	Path string
+	Driver string // "sqlite" | "postgres" | "mysql"
+	Path   string // sqlite 文件路径
+	DSN    string // postgres / mysql 连接串
+
+	// sqlite 并发：WAL 模式下读写互不阻塞，写锁被占用时最多等待 BusyTimeout 而不是立即报 "database is locked"
+	JournalMode string        // sqlite 日志模式：wal | delete | truncate
+	BusyTimeout time.Duration // sqlite 等待写锁的时间，0 表示不等待
+
+	// 连接池（所有驱动）：MaxOpenConns 为 0 表示不限制，ConnMaxLifetime 为 0 表示连接不过期
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// StorageConfig 本地文件存储（截图、备份、导出产物等）
+type StorageConfig struct {
+	Path      string
+	MinFreeMB int // 深度健康检查的磁盘剩余空间告警阈值
+
+	// 截图静态加密密钥（base64 编码的 16/24/32 字节，对应 AES-128/192/256-GCM），为空时不加密；
+	// EncryptionKeyFile 从文件读取密钥（KMS / Vault 代理下发的密钥文件），与 EncryptionKey 二选一
+	EncryptionKey     string
+	EncryptionKeyFile string
+}
+
+// EncryptionKeyBytes 解析截图加密密钥，未配置时返回 nil
+func (s StorageConfig) EncryptionKeyBytes() ([]byte, error) {
+	encoded := s.EncryptionKey
+	if s.EncryptionKeyFile != "" {
+		data, err := os.ReadFile(s.EncryptionKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		encoded = string(data)
+	}
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("key is not valid base64: %w", err)
+	}
+	if n := len(key); n != 16 && n != 24 && n != 32 {
+		return nil, fmt.Errorf("key must be 16, 24 or 32 bytes, got %d", n)
+	}
+	return key, nil
+}
+
+// RetentionConfig 数据保留策略调度
+type RetentionConfig struct {
+	Interval time.Duration // 清理任务执行间隔，0 表示不启用后台调度
+}
+
+// OCRConfig 截图文字识别（调用本机 tesseract 命令行）
+type OCRConfig struct {
+	Command   string        // tesseract 可执行文件，为空时不识别
+	Languages string        // 识别语言（tesseract -l 参数）
+	Timeout   time.Duration // 单张截图的识别超时
+}
+
+// ReplayConfig 用无头浏览器（Chrome / Chromium）回放录制步骤
+type ReplayConfig struct {
+	ChromePath  string        // Chrome / Chromium 可执行文件，为空时不启用回放
+	StepTimeout time.Duration // 单个步骤（等待目标元素、执行操作、截图）的超时
+}
+
+// EmbeddingConfig 语义检索的向量模型：local 为内置的字词哈希向量（无需外部服务），
+// 其余提供商沿用 llm 中对应的地址、密钥与代理
+type EmbeddingConfig struct {
+	Provider string        // local | openai | zhipu | ollama，为空时不启用语义检索
+	Model    string        // 提供商的向量模型，为空时使用该提供商的默认模型
+	Timeout  time.Duration // 单次向量化请求的超时
 }
 
 // LLMConfig 免费优先的多模态 API 配置
@@ -26,8 +142,8 @@ type LLMConfig struct {
 	DefaultProvider string // "gemini" | "zhipu" | "ollama" | "openrouter" | "openai"
 
 	// Google Gemini 2.0 Flash (免费层: 1500 RPD, 15 RPM)
-	GeminiAPIKey string
-	GeminiModel  string
+	GeminiAPIKey  string
+	GeminiModel   string
 	GeminiBaseURL string
 
 	// 智谱 GLM-4V-Flash (免费: 100万 Token/天)
@@ -40,61 +156,251 @@ type LLMConfig struct {
 	OllamaModel   string
 
 	// OpenRouter (Qwen2.5-VL 免费配额)
-	OpenRouterAPIKey string
-	OpenRouterModel  string
+	OpenRouterAPIKey  string
+	OpenRouterModel   string
 	OpenRouterBaseURL string
 
 	// OpenAI (付费，用户自配)
 	OpenAIAPIKey  string
 	OpenAIModel   string
 	OpenAIBaseURL string
+
+	// 逐步描述时附带的前序描述条数（保持编号和指代连贯），0 表示关闭
+	ContextWindow int
+
+	// 离线模式：禁用全部外部提供商（仅 Ollama 与规则描述），拒绝保存云端 API Key，用于涉密网络部署
+	LocalOnly bool
+
+	// 访问模型提供商的出站代理（http/https/socks5 URL），为空时沿用 HTTPS_PROXY 等环境变量；
+	// NoProxy 为逗号分隔的直连主机、域名后缀或网段（NO_PROXY 语法），localhost 与回环地址始终直连
+	Proxy   string
+	NoProxy string
+
+	// 按提供商覆盖的代理（来自 LLMProvider.Proxy，不经配置文件），"direct" 表示该提供商直连
+	ProviderProxies map[string]string
+
+	// 按提供商的调用参数（来自 LLMProvider，不经配置文件）
+	ProviderTuning map[string]ProviderTuning
 }
 
-// Load 加载配置（优先读取环境变量，否则使用默认值）
-func Load() *Config {
-	cfg := &Config{
+// ProviderTuning 单个提供商的调用参数，零值表示使用默认值
+type ProviderTuning struct {
+	Timeout     time.Duration // 单次请求超时，0 使用运行时设置 ai_timeout_seconds
+	MaxRetries  int           // 网络错误、429 与 5xx 时的重试次数
+	Temperature *float64      // nil 使用默认温度
+	MaxTokens   int           // 最大输出 token 数，0 使用默认值
+}
+
+// CheckProxyURL 校验代理地址：需带 http / https / socks5 协议和主机
+func CheckProxyURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("%q must be an http, https or socks5 URL", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", raw)
+	}
+	return nil
+}
+
+// Defaults 返回内置默认配置
+func Defaults() *Config {
+	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("PORT", "3210"),
-			Mode: getEnv("GIN_MODE", "debug"),
+			Port: "3210",
+			Mode: "debug",
 		},
 		DB: DBConfig{
-			Path: getEnv("DB_PATH", "./gpilot.db"),
+			Driver:          "sqlite",
+			Path:            "./gpilot.db",
+			JournalMode:     "wal",
+			BusyTimeout:     5 * time.Second,
+			MaxOpenConns:    10,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Storage: StorageConfig{
+			Path:      "./data",
+			MinFreeMB: 500,
+		},
+		Retention: RetentionConfig{
+			Interval: time.Hour,
 		},
 		LLM: LLMConfig{
 			// 默认使用 Gemini 免费层
-			DefaultProvider: getEnv("LLM_PROVIDER", "gemini"),
+			DefaultProvider: "gemini",
 
 			// Gemini 配置（用https://aistudio.google.com/ 免费获取）
-			GeminiAPIKey:  getEnv("GEMINI_API_KEY", ""),
-			GeminiModel:   getEnv("GEMINI_MODEL", "gemini-2.0-flash"),
-			GeminiBaseURL: getEnv("GEMINI_BASE_URL", "https://generativelanguage.googleapis.com/v1beta"),
+			GeminiModel:   "gemini-2.0-flash",
+			GeminiBaseURL: "https://generativelanguage.googleapis.com/v1beta",
 
 			// 智谱 GLM-4V-Flash（https://open.bigmodel.cn/ 免费注册）
-			ZhipuAPIKey:  getEnv("ZHIPU_API_KEY", ""),
-			ZhipuModel:   getEnv("ZHIPU_MODEL", "glm-4v-flash"),
-			ZhipuBaseURL: getEnv("ZHIPU_BASE_URL", "https://open.bigmodel.cn/api/paas/v4"),
+			ZhipuModel:   "glm-4v-flash",
+			ZhipuBaseURL: "https://open.bigmodel.cn/api/paas/v4",
 
 			// Ollama 本地（需要用户提前安装 Ollama 并运行 qwen2.5-vl）
-			OllamaBaseURL: getEnv("OLLAMA_BASE_URL", "http://localhost:11434"),
-			OllamaModel:   getEnv("OLLAMA_MODEL", "qwen2.5-vl:7b"),
+			OllamaBaseURL: "http://localhost:11434",
+			OllamaModel:   "qwen2.5-vl:7b",
 
 			// OpenRouter（https://openrouter.ai/ 注册获得免费额度）
-			OpenRouterAPIKey:  getEnv("OPENROUTER_API_KEY", ""),
-			OpenRouterModel:   getEnv("OPENROUTER_MODEL", "qwen/qwen2.5-vl-72b-instruct:free"),
-			OpenRouterBaseURL: getEnv("OPENROUTER_BASE_URL", "https://openrouter.ai/api/v1"),
+			OpenRouterModel:   "qwen/qwen2.5-vl-72b-instruct:free",
+			OpenRouterBaseURL: "https://openrouter.ai/api/v1",
 
 			// OpenAI（付费，用户自配时才生效）
-			OpenAIAPIKey:  getEnv("OPENAI_API_KEY", ""),
-			OpenAIModel:   getEnv("OPENAI_MODEL", "gpt-4o-mini"),
-			OpenAIBaseURL: getEnv("OPENAI_BASE_URL", "https://api.openai.com/v1"),
+			OpenAIModel:   "gpt-4o-mini",
+			OpenAIBaseURL: "https://api.openai.com/v1",
+
+			ContextWindow: 3,
+		},
+		OCR: OCRConfig{
+			Languages: "chi_sim+eng",
+			Timeout:   30 * time.Second,
+		},
+		Replay: ReplayConfig{
+			StepTimeout: 20 * time.Second,
+		},
+		Embedding: EmbeddingConfig{
+			Provider: "local",
+			Timeout:  30 * time.Second,
 		},
 	}
+}
+
+// field 配置项：配置文件键（点分路径）、对应环境变量、目标字段（*string | *int | *bool | *time.Duration）
+type field struct {
+	key string
+	env string
+	ptr interface{}
+}
+
+// fields 列出全部配置项，配置文件与环境变量共用同一份映射
+func (c *Config) fields() []field {
+	return []field{
+		{"server.host", "SERVER_HOST", &c.Server.Host},
+		{"server.port", "PORT", &c.Server.Port},
+		{"server.mode", "GIN_MODE", &c.Server.Mode},
+		{"server.tls_cert_file", "TLS_CERT_FILE", &c.Server.TLSCertFile},
+		{"server.tls_key_file", "TLS_KEY_FILE", &c.Server.TLSKeyFile},
+		{"server.autocert_domains", "AUTOCERT_DOMAINS", &c.Server.AutocertDomains},
+		{"server.autocert_email", "AUTOCERT_EMAIL", &c.Server.AutocertEmail},
+		{"server.autocert_cache_dir", "AUTOCERT_CACHE_DIR", &c.Server.AutocertCacheDir},
+		{"server.http_redirect_port", "HTTP_REDIRECT_PORT", &c.Server.HTTPRedirectPort},
+		{"server.web_dir", "WEB_DIR", &c.Server.WebDir},
+		{"db.driver", "DB_DRIVER", &c.DB.Driver},
+		{"db.path", "DB_PATH", &c.DB.Path},
+		{"db.dsn", "DB_DSN", &c.DB.DSN},
+		{"db.journal_mode", "DB_JOURNAL_MODE", &c.DB.JournalMode},
+		{"db.busy_timeout", "DB_BUSY_TIMEOUT", &c.DB.BusyTimeout},
+		{"db.max_open_conns", "DB_MAX_OPEN_CONNS", &c.DB.MaxOpenConns},
+		{"db.max_idle_conns", "DB_MAX_IDLE_CONNS", &c.DB.MaxIdleConns},
+		{"db.conn_max_lifetime", "DB_CONN_MAX_LIFETIME", &c.DB.ConnMaxLifetime},
+		{"storage.path", "STORAGE_PATH", &c.Storage.Path},
+		{"storage.min_free_mb", "STORAGE_MIN_FREE_MB", &c.Storage.MinFreeMB},
+		{"storage.encryption_key", "STORAGE_ENCRYPTION_KEY", &c.Storage.EncryptionKey},
+		{"storage.encryption_key_file", "STORAGE_ENCRYPTION_KEY_FILE", &c.Storage.EncryptionKeyFile},
+		{"retention.interval", "RETENTION_INTERVAL", &c.Retention.Interval},
+		{"llm.default_provider", "LLM_PROVIDER", &c.LLM.DefaultProvider},
+		{"llm.gemini_api_key", "GEMINI_API_KEY", &c.LLM.GeminiAPIKey},
+		{"llm.gemini_model", "GEMINI_MODEL", &c.LLM.GeminiModel},
+		{"llm.gemini_base_url", "GEMINI_BASE_URL", &c.LLM.GeminiBaseURL},
+		{"llm.zhipu_api_key", "ZHIPU_API_KEY", &c.LLM.ZhipuAPIKey},
+		{"llm.zhipu_model", "ZHIPU_MODEL", &c.LLM.ZhipuModel},
+		{"llm.zhipu_base_url", "ZHIPU_BASE_URL", &c.LLM.ZhipuBaseURL},
+		{"llm.ollama_base_url", "OLLAMA_BASE_URL", &c.LLM.OllamaBaseURL},
+		{"llm.ollama_model", "OLLAMA_MODEL", &c.LLM.OllamaModel},
+		{"llm.openrouter_api_key", "OPENROUTER_API_KEY", &c.LLM.OpenRouterAPIKey},
+		{"llm.openrouter_model", "OPENROUTER_MODEL", &c.LLM.OpenRouterModel},
+		{"llm.openrouter_base_url", "OPENROUTER_BASE_URL", &c.LLM.OpenRouterBaseURL},
+		{"llm.openai_api_key", "OPENAI_API_KEY", &c.LLM.OpenAIAPIKey},
+		{"llm.openai_model", "OPENAI_MODEL", &c.LLM.OpenAIModel},
+		{"llm.openai_base_url", "OPENAI_BASE_URL", &c.LLM.OpenAIBaseURL},
+		{"llm.context_window", "LLM_CONTEXT_WINDOW", &c.LLM.ContextWindow},
+		{"llm.local_only", "LLM_LOCAL_ONLY", &c.LLM.LocalOnly},
+		{"llm.proxy", "LLM_PROXY", &c.LLM.Proxy},
+		{"llm.no_proxy", "LLM_NO_PROXY", &c.LLM.NoProxy},
+		{"ocr.command", "OCR_COMMAND", &c.OCR.Command},
+		{"ocr.languages", "OCR_LANGUAGES", &c.OCR.Languages},
+		{"ocr.timeout", "OCR_TIMEOUT", &c.OCR.Timeout},
+		{"replay.chrome_path", "REPLAY_CHROME_PATH", &c.Replay.ChromePath},
+		{"replay.step_timeout", "REPLAY_STEP_TIMEOUT", &c.Replay.StepTimeout},
+		{"embedding.provider", "EMBEDDING_PROVIDER", &c.Embedding.Provider},
+		{"embedding.model", "EMBEDDING_MODEL", &c.Embedding.Model},
+		{"embedding.timeout", "EMBEDDING_TIMEOUT", &c.Embedding.Timeout},
+	}
+}
+
+// Load 加载配置（仅环境变量，未设置或无法解析的项使用默认值）
+func Load() *Config {
+	cfg := Defaults()
+	_ = cfg.applyEnv()
 	return cfg
 }
 
-func getEnv(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// LoadFrom 按 默认值 < 配置文件 < 环境变量 的优先级加载并校验配置；path 为空时不读取配置文件。
+// 错误信息包含出错的配置键（及其来源的环境变量）
+func LoadFrom(path string) (*Config, error) {
+	cfg := Defaults()
+	if path != "" {
+		if err := cfg.applyFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv 用已设置的环境变量覆盖配置，返回第一个无法解析的变量
+func (c *Config) applyEnv() error {
+	var firstErr error
+	for _, f := range c.fields() {
+		v := os.Getenv(f.env)
+		if v == "" {
+			continue
+		}
+		if err := setString(f.ptr, v); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("config %s (env %s): %w", f.key, f.env, err)
+		}
+	}
+	return firstErr
+}
+
+// setString 将文本值写入目标字段
+func setString(ptr interface{}, v string) error {
+	switch p := ptr.(type) {
+	case *string:
+		*p = v
+	case *int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		*p = n
+	case *bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", v)
+		}
+		*p = b
+	case *time.Duration:
+		if v == "0" {
+			*p = 0
+			return nil
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q (e.g. 30m, 1h)", v)
+		}
+		*p = d
 	}
-	return fallback
+	return nil
 }
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFrom_YAML(t *testing.T) {
+	path := writeConfig(t, "gpilot.yaml", `
+server:
+  port: 8080
+  mode: release
+storage:
+  path: /var/lib/gpilot
+  min_free_mb: 1024
+retention:
+  interval: 30m
+llm:
+  default_provider: ollama
+  context_window: 5
+  local_only: true
+  proxy: http://proxy.corp:3128
+  no_proxy: .corp.local,10.0.0.0/8
+`)
+	t.Setenv("LLM_PROVIDER", "zhipu") // 环境变量优先于配置文件
+	cfg, err := LoadFrom(path)
+	if err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	if cfg.Server.Port != "8080" || cfg.Server.Mode != "release" {
+		t.Errorf("unexpected server config: %+v", cfg.Server)
+	}
+	if cfg.Storage.Path != "/var/lib/gpilot" || cfg.Storage.MinFreeMB != 1024 {
+		t.Errorf("unexpected storage config: %+v", cfg.Storage)
+	}
+	if cfg.Retention.Interval != 30*time.Minute {
+		t.Errorf("unexpected retention interval: %v", cfg.Retention.Interval)
+	}
+	if cfg.LLM.DefaultProvider != "zhipu" || cfg.LLM.ContextWindow != 5 {
+		t.Errorf("unexpected llm config: provider=%s window=%d", cfg.LLM.DefaultProvider, cfg.LLM.ContextWindow)
+	}
+	if !cfg.LLM.LocalOnly {
+		t.Error("expected llm.local_only to be enabled")
+	}
+	if cfg.LLM.Proxy != "http://proxy.corp:3128" || cfg.LLM.NoProxy != ".corp.local,10.0.0.0/8" {
+		t.Errorf("unexpected proxy config: %q %q", cfg.LLM.Proxy, cfg.LLM.NoProxy)
+	}
+	if cfg.DB.Path != "./gpilot.db" {
+		t.Errorf("unset keys should keep defaults, got db.path=%s", cfg.DB.Path)
+	}
+}
+
+func TestLoadFrom_TOML(t *testing.T) {
+	path := writeConfig(t, "gpilot.toml", `
+[db]
+driver = "postgres"
+dsn = "host=db user=gpilot"
+
+[retention]
+interval = 0
+`)
+	cfg, err := LoadFrom(path)
+	if err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	if cfg.DB.Driver != "postgres" || cfg.DB.DSN != "host=db user=gpilot" || cfg.Retention.Interval != 0 {
+		t.Errorf("unexpected config: %+v %+v", cfg.DB, cfg.Retention)
+	}
+}
+
+func TestLoadFrom_Errors(t *testing.T) {
+	cases := []struct {
+		name, file, content string
+		env                 map[string]string
+		want                string
+	}{
+		{"unknown key", "c.yaml", "server:\n  prot: 80\n", nil, `unknown key "server.prot"`},
+		{"wrong type", "c.yaml", "storage:\n  min_free_mb: lots\n", nil, "storage.min_free_mb"},
+		{"bad duration", "c.toml", "[retention]\ninterval = \"soon\"\n", nil, "retention.interval"},
+		{"invalid value", "c.yaml", "llm:\n  default_provider: gpt\n", nil, "llm.default_provider"},
+		{"missing dsn", "c.yaml", "db:\n  driver: mysql\n", nil, "db.dsn"},
+		{"bad journal mode", "c.yaml", "db:\n  journal_mode: wall\n", nil, "db.journal_mode"},
+		{"idle above open", "c.yaml", "", map[string]string{"DB_MAX_OPEN_CONNS": "2", "DB_MAX_IDLE_CONNS": "4"}, "db.max_idle_conns (env DB_MAX_IDLE_CONNS)"},
+		{"bad busy timeout", "c.yaml", "", map[string]string{"DB_BUSY_TIMEOUT": "5"}, "db.busy_timeout (env DB_BUSY_TIMEOUT)"},
+		{"bad env", "c.yaml", "", map[string]string{"LLM_CONTEXT_WINDOW": "many"}, "llm.context_window (env LLM_CONTEXT_WINDOW)"},
+		{"bad extension", "c.json", "{}", nil, "unsupported extension"},
+		{"bad host", "c.yaml", "server:\n  host: \"a b\"\n", nil, "server.host"},
+		{"cert without key", "c.yaml", "server:\n  tls_cert_file: cert.pem\n", nil, "server.tls_key_file"},
+		{"missing cert", "c.yaml", "server:\n  tls_cert_file: /nonexistent/cert.pem\n  tls_key_file: /nonexistent/key.pem\n", nil, "server.tls_cert_file"},
+		{"cert and autocert", "c.yaml", "server:\n  tls_cert_file: c.pem\n  tls_key_file: k.pem\n  autocert_domains: example.com\n", nil, "server.autocert_domains"},
+		{"web dir without index", "c.yaml", "server:\n  web_dir: /nonexistent\n", nil, "server.web_dir"},
+		{"bad boolean", "c.yaml", "", map[string]string{"LLM_LOCAL_ONLY": "maybe"}, "llm.local_only (env LLM_LOCAL_ONLY)"},
+		{"short encryption key", "c.yaml", "storage:\n  encryption_key: c2hvcnQ=\n", nil, "storage.encryption_key"},
+		{"key and key file", "c.yaml", "", map[string]string{"STORAGE_ENCRYPTION_KEY": "x", "STORAGE_ENCRYPTION_KEY_FILE": "k"}, "storage.encryption_key_file (env STORAGE_ENCRYPTION_KEY_FILE)"},
+		{"missing key file", "c.yaml", "storage:\n  encryption_key_file: /nonexistent/key\n", nil, "storage.encryption_key_file"},
+		{"redirect without tls", "c.yaml", "", map[string]string{"HTTP_REDIRECT_PORT": "80"}, "server.http_redirect_port (env HTTP_REDIRECT_PORT)"},
+		{"bad proxy", "c.yaml", "llm:\n  proxy: proxy.corp:3128\n", nil, "llm.proxy"},
+		{"bad embedder", "c.yaml", "embedding:\n  provider: gemini\n", nil, "embedding.provider"},
+		{"cloud embedder offline", "c.yaml", "llm:\n  local_only: true\nembedding:\n  provider: openai\n", nil, "embedding.provider"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+			_, err := LoadFrom(writeConfig(t, tc.file, tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadFrom_NoFile(t *testing.T) {
+	t.Setenv("PORT", "9000")
+	cfg, err := LoadFrom("")
+	if err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	if cfg.Server.Port != "9000" || cfg.LLM.GeminiModel != "gemini-2.0-flash" {
+		t.Errorf("unexpected config: port=%s gemini=%s", cfg.Server.Port, cfg.LLM.GeminiModel)
+	}
+}
+
+func TestLoadFrom_Server(t *testing.T) {
+	path := writeConfig(t, "c.yaml", "server:\n  host: 127.0.0.1\n  port: 8443\n  autocert_domains: \"docs.example.com, gpilot.example.com\"\n  http_redirect_port: 8080\n")
+	cfg, err := LoadFrom(path)
+	if err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	s := cfg.Server
+	if s.Addr() != "127.0.0.1:8443" || !s.TLSEnabled() || s.HTTPRedirectPort != "8080" {
+		t.Errorf("unexpected server config: %+v", s)
+	}
+	if d := s.Domains(); len(d) != 2 || d[1] != "gpilot.example.com" {
+		t.Errorf("unexpected domains: %v", d)
+	}
+	if Defaults().Server.Addr() != ":3210" || Defaults().Server.TLSEnabled() {
+		t.Error("defaults should listen on all interfaces over plain HTTP")
+	}
+}
+
+func TestStorageEncryptionKey(t *testing.T) {
+	keyFile := writeConfig(t, "key", "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=\n") // KMS 代理下发的密钥文件，末尾带换行
+	cfg, err := LoadFrom(writeConfig(t, "c.yaml", "storage:\n  encryption_key_file: "+keyFile+"\n"))
+	if err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	key, err := cfg.Storage.EncryptionKeyBytes()
+	if err != nil || string(key) != "0123456789abcdef0123456789abcdef" {
+		t.Errorf("unexpected key %q: %v", key, err)
+	}
+	if key, err := Defaults().Storage.EncryptionKeyBytes(); key != nil || err != nil {
+		t.Errorf("encryption should be disabled by default: %q %v", key, err)
+	}
+}
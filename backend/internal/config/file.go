@@ -0,0 +1,308 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// applyFile 读取 YAML / TOML 配置文件（按扩展名识别），结构与 Config 一致，例如：
+//
+//	server:
+//	  port: 3210
+//	llm:
+//	  default_provider: ollama
+//	  context_window: 3
+//
+// 未知的键视为错误，避免拼写错误被静默忽略
+func (c *Config) applyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	raw := map[string]interface{}{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return fmt.Errorf("config file %s: unsupported extension %q (.yaml/.yml/.toml)", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := map[string]interface{}{}
+	if err := flatten("", raw, values); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	known := map[string]field{}
+	for _, f := range c.fields() {
+		known[f.key] = f
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		f, ok := known[k]
+		if !ok {
+			return fmt.Errorf("config file %s: unknown key %q", path, k)
+		}
+		if err := setValue(f.ptr, values[k]); err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, k, err)
+		}
+	}
+	return nil
+}
+
+// flatten 将嵌套表展开为点分路径
+func flatten(prefix string, in map[string]interface{}, out map[string]interface{}) error {
+	for k, v := range in {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch t := v.(type) {
+		case map[string]interface{}:
+			if err := flatten(key, t, out); err != nil {
+				return err
+			}
+		case []interface{}:
+			return fmt.Errorf("%s: lists are not supported", key)
+		default:
+			out[key] = v
+		}
+	}
+	return nil
+}
+
+// setValue 将配置文件中的标量写入目标字段；数字可写入字符串字段（如 port: 3210）
+func setValue(ptr interface{}, v interface{}) error {
+	if v == nil {
+		return nil
+	}
+	switch p := ptr.(type) {
+	case *string:
+		switch t := v.(type) {
+		case string:
+			*p = t
+		case bool:
+			return fmt.Errorf("expected string, got %v", t)
+		default:
+			*p = fmt.Sprint(t)
+		}
+	case *int:
+		n, ok := toInt(v)
+		if !ok {
+			return fmt.Errorf("expected integer, got %v", v)
+		}
+		*p = n
+	case *bool:
+		switch t := v.(type) {
+		case bool:
+			*p = t
+		case string:
+			return setString(p, t)
+		default:
+			return fmt.Errorf("expected boolean, got %v", v)
+		}
+	case *time.Duration:
+		if n, ok := toInt(v); ok && n == 0 {
+			*p = 0
+			return nil
+		}
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("expected duration string (e.g. 30m, 1h), got %v", v)
+		}
+		return setString(p, s)
+	}
+	return nil
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch t := v.(type) {
+	case int:
+		return t, true
+	case int64:
+		return int(t), true
+	case uint64:
+		if t > math.MaxInt32 {
+			return 0, false
+		}
+		return int(t), true
+	case float64:
+		if t != math.Trunc(t) {
+			return 0, false
+		}
+		return int(t), true
+	}
+	return 0, false
+}
+
+var (
+	validModes     = map[string]bool{"debug": true, "release": true, "test": true}
+	validDrivers   = map[string]bool{"sqlite": true, "postgres": true, "mysql": true}
+	validJournals  = map[string]bool{"wal": true, "delete": true, "truncate": true}
+	validProviders = map[string]bool{"gemini": true, "zhipu": true, "ollama": true, "openrouter": true, "openai": true, "rule-based": true}
+	validEmbedders = map[string]bool{"": true, "local": true, "openai": true, "zhipu": true, "ollama": true}
+)
+
+// Validate 校验配置取值，错误信息包含出错的配置键及对应的环境变量
+func (c *Config) Validate() error {
+	if h := c.Server.Host; h != "" && net.ParseIP(h) == nil && strings.ContainsAny(h, ":/ ") {
+		return c.invalid("server.host", "%q is not a valid host or IP address", h)
+	}
+	if !validPort(c.Server.Port) {
+		return c.invalid("server.port", "%q is not a valid port (1-65535)", c.Server.Port)
+	}
+	if !validModes[c.Server.Mode] {
+		return c.invalid("server.mode", "%q must be one of debug, release, test", c.Server.Mode)
+	}
+	if err := c.validateTLS(); err != nil {
+		return err
+	}
+	if c.Server.WebDir != "" {
+		if _, err := os.Stat(filepath.Join(c.Server.WebDir, "index.html")); err != nil {
+			return c.invalid("server.web_dir", "index.html not found: %v", err)
+		}
+	}
+	if !validDrivers[c.DB.Driver] {
+		return c.invalid("db.driver", "%q must be one of sqlite, postgres, mysql", c.DB.Driver)
+	}
+	if c.DB.Driver == "sqlite" && c.DB.Path == "" {
+		return c.invalid("db.path", "required for sqlite")
+	}
+	if c.DB.Driver != "sqlite" && c.DB.DSN == "" {
+		return c.invalid("db.dsn", "required for %s", c.DB.Driver)
+	}
+	if !validJournals[strings.ToLower(c.DB.JournalMode)] {
+		return c.invalid("db.journal_mode", "%q must be one of wal, delete, truncate", c.DB.JournalMode)
+	}
+	if c.DB.BusyTimeout < 0 {
+		return c.invalid("db.busy_timeout", "must be >= 0")
+	}
+	if c.DB.MaxOpenConns < 0 {
+		return c.invalid("db.max_open_conns", "must be >= 0")
+	}
+	if c.DB.MaxIdleConns < 0 || (c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns) {
+		return c.invalid("db.max_idle_conns", "must be between 0 and db.max_open_conns")
+	}
+	if c.DB.ConnMaxLifetime < 0 {
+		return c.invalid("db.conn_max_lifetime", "must be >= 0")
+	}
+	if c.Storage.Path == "" {
+		return c.invalid("storage.path", "required")
+	}
+	if c.Storage.MinFreeMB < 0 {
+		return c.invalid("storage.min_free_mb", "must be >= 0")
+	}
+	if c.Storage.EncryptionKey != "" && c.Storage.EncryptionKeyFile != "" {
+		return c.invalid("storage.encryption_key_file", "cannot be combined with storage.encryption_key")
+	}
+	if _, err := c.Storage.EncryptionKeyBytes(); err != nil {
+		key := "storage.encryption_key"
+		if c.Storage.EncryptionKeyFile != "" {
+			key = "storage.encryption_key_file"
+		}
+		return c.invalid(key, "%v", err)
+	}
+	if c.Retention.Interval < 0 {
+		return c.invalid("retention.interval", "must be >= 0")
+	}
+	if c.OCR.Command != "" {
+		if c.OCR.Languages == "" {
+			return c.invalid("ocr.languages", "required when ocr.command is set")
+		}
+		if c.OCR.Timeout <= 0 {
+			return c.invalid("ocr.timeout", "must be > 0")
+		}
+	}
+	if c.Replay.ChromePath != "" && c.Replay.StepTimeout <= 0 {
+		return c.invalid("replay.step_timeout", "must be > 0")
+	}
+	if !validEmbedders[c.Embedding.Provider] {
+		return c.invalid("embedding.provider", "%q must be one of local, openai, zhipu, ollama or empty", c.Embedding.Provider)
+	}
+	if c.Embedding.Provider != "" && c.Embedding.Timeout <= 0 {
+		return c.invalid("embedding.timeout", "must be > 0")
+	}
+	if c.LLM.LocalOnly && (c.Embedding.Provider == "openai" || c.Embedding.Provider == "zhipu") {
+		return c.invalid("embedding.provider", "%q is an external provider and cannot be used with llm.local_only", c.Embedding.Provider)
+	}
+	if !validProviders[c.LLM.DefaultProvider] {
+		return c.invalid("llm.default_provider", "%q must be one of gemini, zhipu, ollama, openrouter, openai, rule-based", c.LLM.DefaultProvider)
+	}
+	if c.LLM.ContextWindow < 0 {
+		return c.invalid("llm.context_window", "must be >= 0")
+	}
+	if c.LLM.Proxy != "" {
+		if err := CheckProxyURL(c.LLM.Proxy); err != nil {
+			return c.invalid("llm.proxy", "%v", err)
+		}
+	}
+	return nil
+}
+
+// validateTLS 校验 HTTPS 配置：证书文件成对出现且可读，与 autocert 互斥
+func (c *Config) validateTLS() error {
+	s := c.Server
+	if (s.TLSCertFile == "") != (s.TLSKeyFile == "") {
+		if s.TLSCertFile == "" {
+			return c.invalid("server.tls_cert_file", "required when server.tls_key_file is set")
+		}
+		return c.invalid("server.tls_key_file", "required when server.tls_cert_file is set")
+	}
+	if s.TLSCertFile != "" && s.AutocertDomains != "" {
+		return c.invalid("server.autocert_domains", "cannot be combined with server.tls_cert_file")
+	}
+	for _, k := range []struct{ key, path string }{
+		{"server.tls_cert_file", s.TLSCertFile},
+		{"server.tls_key_file", s.TLSKeyFile},
+	} {
+		if k.path == "" {
+			continue
+		}
+		if _, err := os.Stat(k.path); err != nil {
+			return c.invalid(k.key, "%v", err)
+		}
+	}
+	if s.AutocertDomains != "" && len(s.Domains()) == 0 {
+		return c.invalid("server.autocert_domains", "no domain given")
+	}
+	if s.HTTPRedirectPort != "" {
+		if !s.TLSEnabled() {
+			return c.invalid("server.http_redirect_port", "requires HTTPS (server.tls_cert_file or server.autocert_domains)")
+		}
+		if !validPort(s.HTTPRedirectPort) || s.HTTPRedirectPort == s.Port {
+			return c.invalid("server.http_redirect_port", "%q must be a valid port different from server.port", s.HTTPRedirectPort)
+		}
+	}
+	return nil
+}
+
+func validPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port >= 1 && port <= 65535
+}
+
+func (c *Config) invalid(key, format string, args ...interface{}) error {
+	for _, f := range c.fields() {
+		if f.key == key {
+			return fmt.Errorf("config %s (env %s): %s", key, f.env, fmt.Sprintf(format, args...))
+		}
+	}
+	return fmt.Errorf("config %s: %s", key, fmt.Sprintf(format, args...))
+}
@@ -1,6 +1,12 @@
 package db
 
 import (
+	"fmt"
+	"strings"
+
+	"github.com/gpilot/backend/internal/config"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -8,25 +14,132 @@ import (
 
 var DB *gorm.DB
 
-// Init 初始化数据库连接并自动迁移
-func Init(path string) error {
-	var err error
-	DB, err = gorm.Open(sqlite.Open(path), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Warn),
-	})
-	if err != nil {
-		return err
-	}
-
-	// 自动迁移所有表
-	return DB.AutoMigrate(
+// Models 所有业务表，备份/恢复按此顺序导出与导入（新增模型需同步追加）
+func Models() []interface{} {
+	return []interface{}{
 		&Project{},
 		&Session{},
+		&Tag{},
 		&RecordingStep{},
 		&Screenshot{},
 		&MaskingProfile{},
 		&MaskingRule{},
 		&GeneratedDocument{},
 		&LLMProvider{},
-	)
+		&SessionMedia{},
+		&StepRequest{},
+		&StepLog{},
+		&MaskingEvent{},
+		&ProviderCall{},
+		&DocumentShare{},
+		&DocTemplate{},
+		&GlossaryTerm{},
+		&OutputValidationFailure{},
+		&ReplayRun{},
+		&ReplayStep{},
+		&CompiledDocument{},
+		&CompiledChapter{},
+		&Embedding{},
+		&ChatConversation{},
+		&ChatMessage{},
+		&ExportJob{},
+		&Setting{},
+	}
+}
+
+// JoinTables 多对多关联表（没有独立模型），备份时在 Models() 之后导出，恢复时最先清空、最后导入
+func JoinTables() []string {
+	return []string{"project_tags", "session_tags"}
+}
+
+// Init 初始化数据库连接并执行所有未应用的迁移
+func Init(cfg config.DBConfig) error {
+	if err := Open(cfg); err != nil {
+		return err
+	}
+	return Migrate(DB)
+}
+
+// Open 仅建立数据库连接，不执行迁移
+//
+// 支持的驱动：sqlite（默认，使用 Path）、postgres / mysql（使用 DSN）
+func Open(cfg config.DBConfig) error {
+	dialector, err := openDialector(cfg)
+	if err != nil {
+		return err
+	}
+
+	DB, err = gorm.Open(dialector, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Warn),
+	})
+	if err != nil {
+		return err
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	maxOpen, maxIdle := cfg.MaxOpenConns, cfg.MaxIdleConns
+	if isSQLiteMemory(cfg) {
+		// 内存数据库每个连接各自独立，只能使用单个连接
+		maxOpen, maxIdle = 1, 1
+	}
+	sqlDB.SetMaxOpenConns(maxOpen)
+	sqlDB.SetMaxIdleConns(maxIdle)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	return nil
+}
+
+func openDialector(cfg config.DBConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case "", "sqlite":
+		return sqlite.Open(sqliteDSN(cfg)), nil
+	case "postgres":
+		if cfg.DSN == "" {
+			return nil, fmt.Errorf("DB_DSN is required for driver %q", cfg.Driver)
+		}
+		return postgres.Open(cfg.DSN), nil
+	case "mysql":
+		if cfg.DSN == "" {
+			return nil, fmt.Errorf("DB_DSN is required for driver %q", cfg.Driver)
+		}
+		// 未指定长度的 string 字段映射为 varchar(256)，避免 longtext 无法建索引
+		return mysql.New(mysql.Config{DSN: cfg.DSN, DefaultStringSize: 256}), nil
+	default:
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q (sqlite|postgres|mysql)", cfg.Driver)
+	}
+}
+
+// sqliteDSN 在 sqlite 路径后附加连接参数（路径中已写明的参数优先）：日志模式、等待写锁的超时，
+// 以及以 BEGIN IMMEDIATE 开始事务——事务开始时即申请写锁，避免读事务升级为写事务时不经等待直接报 "database is locked"
+func sqliteDSN(cfg config.DBConfig) string {
+	params := []struct{ key, value string }{
+		{"_journal_mode", strings.ToUpper(cfg.JournalMode)},
+		{"_busy_timeout", fmt.Sprint(cfg.BusyTimeout.Milliseconds())},
+		{"_txlock", "immediate"},
+	}
+	if strings.EqualFold(cfg.JournalMode, "wal") {
+		// WAL 模式下 NORMAL 同步级别不会损坏数据库，只可能丢失断电前最后的提交
+		params = append(params, struct{ key, value string }{"_synchronous", "NORMAL"})
+	}
+	dsn := cfg.Path
+	for _, p := range params {
+		if p.value == "" || strings.Contains(dsn, p.key+"=") {
+			continue
+		}
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + p.key + "=" + p.value
+	}
+	return dsn
+}
+
+// isSQLiteMemory 是否为 sqlite 内存数据库
+func isSQLiteMemory(cfg config.DBConfig) bool {
+	if cfg.Driver != "" && cfg.Driver != "sqlite" {
+		return false
+	}
+	return strings.HasPrefix(cfg.Path, ":memory:") || strings.Contains(cfg.Path, "mode=memory")
 }
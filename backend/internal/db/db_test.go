@@ -0,0 +1,79 @@
+package db_test
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gpilot/backend/internal/config"
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+func TestOpen_SQLiteTuning(t *testing.T) {
+	cfg := config.Defaults().DB
+	cfg.Path = filepath.Join(t.TempDir(), "gpilot.db")
+	if err := db.Init(cfg); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	var journal string
+	var busy int
+	db.DB.Raw("PRAGMA journal_mode").Scan(&journal)
+	db.DB.Raw("PRAGMA busy_timeout").Scan(&busy)
+	if journal != "wal" || busy != 5000 {
+		t.Errorf("unexpected pragmas: journal_mode=%s busy_timeout=%d", journal, busy)
+	}
+	sqlDB, _ := db.DB.DB()
+	if max := sqlDB.Stats().MaxOpenConnections; max != cfg.MaxOpenConns {
+		t.Errorf("expected %d max open connections, got %d", cfg.MaxOpenConns, max)
+	}
+
+	// 并发写事务（多个插件同时上报）应排队等待写锁，而不是报 "database is locked"
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- db.DB.Transaction(func(tx *gorm.DB) error {
+				var count int64
+				if err := tx.Model(&db.Session{}).Count(&count).Error; err != nil {
+					return err
+				}
+				time.Sleep(time.Millisecond)
+				return tx.Create(&db.Session{Title: "并发"}).Error
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent write: %v", err)
+		}
+	}
+}
+
+func TestOpen_SQLiteMemorySingleConnection(t *testing.T) {
+	cfg := config.Defaults().DB
+	cfg.Path = ":memory:"
+	if err := db.Init(cfg); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	sqlDB, _ := db.DB.DB()
+	t.Cleanup(func() { sqlDB.Close() })
+	if max := sqlDB.Stats().MaxOpenConnections; max != 1 {
+		t.Errorf("in-memory database should use a single connection, got %d", max)
+	}
+	// 迁移创建的表在后续查询中可见（同一连接）
+	if err := db.DB.Create(&db.Session{Title: "内存"}).Error; err != nil {
+		t.Errorf("create after migrate: %v", err)
+	}
+}
@@ -0,0 +1,260 @@
+package db
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 静态加密：配置密钥后截图内容（及内嵌截图的文档视图）以 AES-GCM 加密入库，读取时自动解密。
+// 密文格式为 sealedPrefix + base64(nonce || ciphertext)；未加 sealedPrefix 的值视为明文（启用加密前的数据）
+const sealedPrefix = "enc:v1:"
+
+var sealer cipher.AEAD
+
+// ErrNoEncryptionKey 读到加密数据但未配置密钥
+var ErrNoEncryptionKey = errors.New("encrypted screenshot found but no encryption key is configured")
+
+// SetEncryptionKey 设置截图加密密钥（16/24/32 字节），nil 关闭加密（已加密的数据将无法读取）
+func SetEncryptionKey(key []byte) error {
+	if key == nil {
+		sealer = nil
+		return nil
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	sealer = aead
+	return nil
+}
+
+// EncryptionEnabled 是否已配置截图加密密钥
+func EncryptionEnabled() bool {
+	return sealer != nil
+}
+
+// Seal 加密一个字段值；未配置密钥、空值或已加密时原样返回。
+// 以 map 更新截图或文档视图列时需先调用（结构体写入由模型钩子处理）
+func Seal(plain string) (string, error) {
+	if sealer == nil || plain == "" || strings.HasPrefix(plain, sealedPrefix) {
+		return plain, nil
+	}
+	out, err := sealRaw([]byte(plain))
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + base64.StdEncoding.EncodeToString(out), nil
+}
+
+// Unseal 解密 Seal 的结果；明文原样返回
+func Unseal(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, sealedPrefix)
+	if !ok {
+		return value, nil
+	}
+	if sealer == nil {
+		return "", ErrNoEncryptionKey
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	plain, err := openRaw(data)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// IsSealed 值是否为加密格式
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+// SealBytes 加密文件内容（如导出产物），格式为 sealedPrefix + nonce || ciphertext；未配置密钥时原样返回
+func SealBytes(plain []byte) ([]byte, error) {
+	if sealer == nil {
+		return plain, nil
+	}
+	out, err := sealRaw(plain)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(sealedPrefix), out...), nil
+}
+
+// UnsealBytes 解密 SealBytes 的结果；不带 sealedPrefix 的内容视为明文原样返回
+func UnsealBytes(data []byte) ([]byte, error) {
+	if !IsSealedBytes(data) {
+		return data, nil
+	}
+	if sealer == nil {
+		return nil, ErrNoEncryptionKey
+	}
+	return openRaw(data[len(sealedPrefix):])
+}
+
+// IsSealedBytes 文件内容是否为 SealBytes 的加密格式（只需传入开头的若干字节）
+func IsSealedBytes(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(sealedPrefix))
+}
+
+// sealRaw 返回 nonce || ciphertext
+func sealRaw(plain []byte) ([]byte, error) {
+	nonce := make([]byte, sealer.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return sealer.Seal(nonce, nonce, plain, nil), nil
+}
+
+func openRaw(data []byte) ([]byte, error) {
+	n := sealer.NonceSize()
+	if len(data) < n {
+		return nil, errors.New("sealed value too short")
+	}
+	return sealer.Open(nil, data[:n], data[n:], nil)
+}
+
+func sealFields[T ~string](fields ...*T) error {
+	for _, f := range fields {
+		v, err := Seal(string(*f))
+		if err != nil {
+			return err
+		}
+		*f = T(v)
+	}
+	return nil
+}
+
+func unsealFields[T ~string](fields ...*T) error {
+	for _, f := range fields {
+		v, err := Unseal(string(*f))
+		if err != nil {
+			return err
+		}
+		*f = T(v)
+	}
+	return nil
+}
+
+// BeforeSave 写入前加密截图内容
+func (s *Screenshot) BeforeSave(tx *gorm.DB) error {
+	return sealFields(&s.DataURL, &s.ElementURL)
+}
+
+// AfterSave 写入后恢复内存中的明文，调用方可继续使用
+func (s *Screenshot) AfterSave(tx *gorm.DB) error {
+	return unsealFields(&s.DataURL, &s.ElementURL)
+}
+
+// AfterFind 读取后解密截图内容
+func (s *Screenshot) AfterFind(tx *gorm.DB) error {
+	return unsealFields(&s.DataURL, &s.ElementURL)
+}
+
+// 文档视图内嵌截图 data URL，与截图一同加密
+
+// BeforeSave 写入前加密文档视图
+func (d *GeneratedDocument) BeforeSave(tx *gorm.DB) error {
+	return sealFields(&d.BusinessView, &d.TechnicalView)
+}
+
+// AfterSave 写入后恢复内存中的明文
+func (d *GeneratedDocument) AfterSave(tx *gorm.DB) error {
+	return unsealFields(&d.BusinessView, &d.TechnicalView)
+}
+
+// AfterFind 读取后解密文档视图
+func (d *GeneratedDocument) AfterFind(tx *gorm.DB) error {
+	return unsealFields(&d.BusinessView, &d.TechnicalView)
+}
+
+// 回放截图与录制截图一同加密
+
+// BeforeSave 写入前加密回放截图
+func (s *ReplayStep) BeforeSave(tx *gorm.DB) error {
+	return sealFields(&s.DataURL)
+}
+
+// AfterSave 写入后恢复内存中的明文
+func (s *ReplayStep) AfterSave(tx *gorm.DB) error {
+	return unsealFields(&s.DataURL)
+}
+
+// AfterFind 读取后解密回放截图
+func (s *ReplayStep) AfterFind(tx *gorm.DB) error {
+	return unsealFields(&s.DataURL)
+}
+
+// SealExisting 加密启用加密前写入的明文截图、回放截图与文档，返回处理的行数
+func SealExisting(gdb *gorm.DB) (int, error) {
+	if sealer == nil {
+		return 0, ErrNoEncryptionKey
+	}
+	raw := gdb.Session(&gorm.Session{SkipHooks: true})
+	sealed := 0
+	seal := func(model interface{}, id string, values map[string]*LongText) error {
+		updates := map[string]interface{}{}
+		for col, v := range values {
+			if *v == "" || IsSealed(string(*v)) {
+				continue
+			}
+			if err := sealFields(v); err != nil {
+				return err
+			}
+			updates[col] = *v
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		sealed++
+		return gdb.Model(model).Where("id = ?", id).UpdateColumns(updates).Error
+	}
+
+	var shots []Screenshot
+	err := raw.Select("id", "data_url", "element_url").FindInBatches(&shots, 100, func(*gorm.DB, int) error {
+		for _, s := range shots {
+			if err := seal(&Screenshot{}, s.ID, map[string]*LongText{"data_url": &s.DataURL, "element_url": &s.ElementURL}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return sealed, err
+	}
+	var replays []ReplayStep
+	err = raw.Select("id", "data_url").FindInBatches(&replays, 100, func(*gorm.DB, int) error {
+		for _, r := range replays {
+			if err := seal(&ReplayStep{}, r.ID, map[string]*LongText{"data_url": &r.DataURL}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return sealed, err
+	}
+	var docs []GeneratedDocument
+	err = raw.Select("id", "business_view", "technical_view").FindInBatches(&docs, 100, func(*gorm.DB, int) error {
+		for _, d := range docs {
+			if err := seal(&GeneratedDocument{}, d.ID, map[string]*LongText{"business_view": &d.BusinessView, "technical_view": &d.TechnicalView}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+	return sealed, err
+}
@@ -0,0 +1,64 @@
+package db_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+func TestScreenshotEncryption(t *testing.T) {
+	gdb := openMemoryDB(t)
+	if err := db.Migrate(gdb); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	t.Cleanup(func() { db.SetEncryptionKey(nil) })
+
+	// 启用加密前写入的明文
+	legacy := db.Screenshot{SessionID: "s", StepID: "a", DataURL: "data:image/png;base64,AAAA"}
+	gdb.Create(&legacy)
+
+	if err := db.SetEncryptionKey([]byte("0123456789abcdef0123456789abcdef")); err != nil {
+		t.Fatalf("SetEncryptionKey: %v", err)
+	}
+	shot := db.Screenshot{SessionID: "s", StepID: "b", DataURL: "data:image/png;base64,BBBB", ElementURL: "data:image/png;base64,CCCC"}
+	if err := gdb.Create(&shot).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if shot.DataURL != "data:image/png;base64,BBBB" {
+		t.Error("in-memory value should stay plaintext after save")
+	}
+
+	stored := func(id string) string {
+		var raw string
+		gdb.Raw("SELECT data_url FROM screenshots WHERE id = ?", id).Scan(&raw)
+		return raw
+	}
+	if raw := stored(shot.ID); !db.IsSealed(raw) || strings.Contains(raw, "BBBB") {
+		t.Errorf("expected ciphertext in the database, got %q", raw)
+	}
+
+	var got db.Screenshot
+	gdb.First(&got, "id = ?", shot.ID)
+	if got.DataURL != shot.DataURL || got.ElementURL != shot.ElementURL {
+		t.Errorf("expected decrypted values, got %+v", got)
+	}
+	var old db.Screenshot
+	gdb.First(&old, "id = ?", legacy.ID)
+	if old.DataURL != legacy.DataURL {
+		t.Error("plaintext rows should remain readable")
+	}
+
+	if n, err := db.SealExisting(gdb); err != nil || n != 1 {
+		t.Fatalf("SealExisting: %d %v", n, err)
+	}
+	if !db.IsSealed(stored(legacy.ID)) {
+		t.Error("legacy row should be sealed")
+	}
+
+	db.SetEncryptionKey(nil)
+	if err := gdb.First(&db.Screenshot{}, "id = ?", shot.ID).Error; !errors.Is(err, db.ErrNoEncryptionKey) {
+		t.Errorf("expected ErrNoEncryptionKey without a key, got %v", err)
+	}
+}
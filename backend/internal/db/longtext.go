@@ -0,0 +1,18 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// LongText 可能超过 64 KB 的文本（截图 data URL、内嵌截图的文档视图）：
+// MySQL 的 TEXT 上限为 64 KB，使用 LONGTEXT；PostgreSQL / SQLite 的 TEXT 不限长度
+type LongText string
+
+// GormDBDataType 按数据库方言选择列类型
+func (LongText) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	if db.Dialector.Name() == "mysql" {
+		return "LONGTEXT"
+	}
+	return "TEXT"
+}
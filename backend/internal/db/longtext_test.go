@@ -0,0 +1,61 @@
+package db_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestLongText_RoundTrip(t *testing.T) {
+	gdb := openMemoryDB(t)
+	if err := db.Migrate(gdb); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	// 超过 MySQL TEXT 的 64 KB 上限
+	big := "data:image/png;base64," + strings.Repeat("A", 200<<10)
+	shot := db.Screenshot{SessionID: "s", StepID: "st", DataURL: db.LongText(big), ElementURL: db.LongText(big[:100<<10])}
+	doc := db.GeneratedDocument{SessionID: "s", ProjectID: "p", BusinessView: db.LongText(big), TechnicalView: db.LongText(big)}
+	if err := gdb.Create(&shot).Error; err != nil {
+		t.Fatalf("create screenshot: %v", err)
+	}
+	if err := gdb.Create(&doc).Error; err != nil {
+		t.Fatalf("create document: %v", err)
+	}
+
+	var gotShot db.Screenshot
+	var gotDoc db.GeneratedDocument
+	gdb.First(&gotShot, "id = ?", shot.ID)
+	gdb.First(&gotDoc, "id = ?", doc.ID)
+	if string(gotShot.DataURL) != big || len(gotShot.ElementURL) != 100<<10 {
+		t.Errorf("screenshot truncated: data_url %d bytes, element_url %d bytes", len(gotShot.DataURL), len(gotShot.ElementURL))
+	}
+	if string(gotDoc.BusinessView) != big || string(gotDoc.TechnicalView) != big {
+		t.Errorf("document truncated: %d / %d bytes", len(gotDoc.BusinessView), len(gotDoc.TechnicalView))
+	}
+}
+
+func TestLongText_ColumnType(t *testing.T) {
+	for _, tc := range []struct {
+		dialector gorm.Dialector
+		want      string
+	}{
+		{mysql.New(mysql.Config{DSN: "user:pass@tcp(localhost:3306)/gpilot", SkipInitializeWithVersion: true, DefaultStringSize: 256}), "LONGTEXT"},
+		{postgres.New(postgres.Config{DSN: "host=localhost"}), "TEXT"},
+	} {
+		gdb, err := gorm.Open(tc.dialector, &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+		if err != nil {
+			t.Fatalf("open %s: %v", tc.dialector.Name(), err)
+		}
+		stmt := &gorm.Statement{DB: gdb}
+		if err := stmt.Parse(&db.Screenshot{}); err != nil {
+			t.Fatal(err)
+		}
+		if got := gdb.Migrator().FullDataTypeOf(stmt.Schema.LookUpField("DataURL")).SQL; got != tc.want {
+			t.Errorf("%s: data_url column type %q, want %q", tc.dialector.Name(), got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Metadata 自定义键值字段，以 JSON 文本存储
+type Metadata map[string]string
+
+// Value 实现 driver.Valuer
+func (m Metadata) Value() (driver.Value, error) {
+	if len(m) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal(map[string]string(m))
+	return string(b), err
+}
+
+// Scan 实现 sql.Scanner，空值视为无字段
+func (m *Metadata) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("unsupported metadata type %T", value)
+	}
+	if len(raw) == 0 {
+		*m = nil
+		return nil
+	}
+	return json.Unmarshal(raw, (*map[string]string)(m))
+}
@@ -0,0 +1,207 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration 一次版本化的 schema 变更
+//
+// 每个迁移放在独立的 migration_NNNN_*.go 文件中，通过 init() 注册，按 Version 字典序执行。
+// 不要修改已发布的迁移，需要调整时新增一个迁移。迁移只使用在本文件中冻结的结构体（表名由
+// TableName 指定），不引用 models.go 中的业务模型，也不调用 AutoMigrate，
+// 这样业务模型之后的改动不会改变已发布迁移在新库上建出的表结构。
+type Migration struct {
+	Version string // 形如 "0001_init"
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration 已应用的迁移记录
+type SchemaMigration struct {
+	Version   string    `gorm:"primaryKey;size:64" json:"version"`
+	AppliedAt time.Time `                          json:"applied_at"`
+}
+
+func (SchemaMigration) TableName() string { return "schema_migrations" }
+
+// migrationBase 迁移结构体共用的 id / created_at / updated_at 列（冻结的 Base，以 `gorm:"embedded"` 嵌入）
+type migrationBase struct {
+	ID        string `gorm:"primaryKey;size:36"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// MigrationState 迁移状态（供 CLI / API 查询）
+type MigrationState struct {
+	Version   string     `json:"version"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+var migrations []Migration
+
+func register(m Migration) {
+	for _, existing := range migrations {
+		if existing.Version == m.Version {
+			panic("duplicate migration version: " + m.Version)
+		}
+	}
+	migrations = append(migrations, m)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+}
+
+func appliedVersions(gdb *gorm.DB) (map[string]time.Time, error) {
+	if !gdb.Migrator().HasTable(&SchemaMigration{}) {
+		if err := gdb.Migrator().CreateTable(&SchemaMigration{}); err != nil {
+			return nil, err
+		}
+	}
+	var rows []SchemaMigration
+	if err := gdb.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[string]time.Time, len(rows))
+	for _, r := range rows {
+		applied[r.Version] = r.AppliedAt
+	}
+	return applied, nil
+}
+
+// addColumns 为已有的表添加 model 的全部列并创建其上声明的索引；model 为只包含本次新增字段的冻结结构体
+func addColumns(tx *gorm.DB, model interface{}) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	m := tx.Migrator()
+	for _, name := range stmt.Schema.DBNames {
+		if err := m.AddColumn(model, name); err != nil {
+			return err
+		}
+	}
+	for _, idx := range stmt.Schema.ParseIndexes() {
+		if err := m.CreateIndex(model, idx.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropColumns 撤销 addColumns：先删除 model 上声明的索引，再删除其全部列
+func dropColumns(tx *gorm.DB, model interface{}) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	m := tx.Migrator()
+	for _, idx := range stmt.Schema.ParseIndexes() {
+		if m.HasIndex(model, idx.Name) {
+			if err := m.DropIndex(model, idx.Name); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range stmt.Schema.DBNames {
+		if err := m.DropColumn(model, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Migrate 按顺序执行所有未应用的迁移，每个迁移在独立事务中完成
+func Migrate(gdb *gorm.DB) error {
+	applied, err := appliedVersions(gdb)
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		err := gdb.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s up: %w", m.Version, err)
+		}
+	}
+	return nil
+}
+
+// Rollback 回滚最近应用的 steps 个迁移
+func Rollback(gdb *gorm.DB, steps int) error {
+	applied, err := appliedVersions(gdb)
+	if err != nil {
+		return err
+	}
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == nil {
+			return fmt.Errorf("migration %s is irreversible", m.Version)
+		}
+		err := gdb.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, "version = ?", m.Version).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s down: %w", m.Version, err)
+		}
+		steps--
+	}
+	return nil
+}
+
+// MigrationStatus 列出所有已注册迁移及其应用状态
+func MigrationStatus(gdb *gorm.DB) ([]MigrationState, error) {
+	applied, err := appliedVersions(gdb)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		st := MigrationState{Version: m.Version}
+		if at, ok := applied[m.Version]; ok {
+			at := at
+			st.Applied = true
+			st.AppliedAt = &at
+		}
+		states = append(states, st)
+	}
+	return states, nil
+}
+
+// SchemaVersion 返回数据库中最近应用的迁移版本（未迁移时为空）
+func SchemaVersion(gdb *gorm.DB) (string, error) {
+	applied, err := appliedVersions(gdb)
+	if err != nil {
+		return "", err
+	}
+	latest := ""
+	for v := range applied {
+		if v > latest {
+			latest = v
+		}
+	}
+	return latest, nil
+}
+
+// LatestVersion 返回当前代码中注册的最新迁移版本
+func LatestVersion() string {
+	if len(migrations) == 0 {
+		return ""
+	}
+	return migrations[len(migrations)-1].Version
+}
@@ -0,0 +1,130 @@
+package db_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/migrator"
+)
+
+func openMemoryDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	gdb, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open DB: %v", err)
+	}
+	return gdb
+}
+
+func TestMigrate_UpIsIdempotent(t *testing.T) {
+	gdb := openMemoryDB(t)
+	if err := db.Migrate(gdb); err != nil {
+		t.Fatalf("first migrate: %v", err)
+	}
+	if err := db.Migrate(gdb); err != nil {
+		t.Fatalf("second migrate: %v", err)
+	}
+
+	states, err := db.MigrationStatus(gdb)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(states) == 0 {
+		t.Fatal("expected registered migrations")
+	}
+	for _, st := range states {
+		if !st.Applied {
+			t.Errorf("migration %s not applied", st.Version)
+		}
+	}
+	if !gdb.Migrator().HasTable(&db.Project{}) {
+		t.Error("projects table missing after migrate")
+	}
+}
+
+func TestMigrate_RollbackAll(t *testing.T) {
+	gdb := openMemoryDB(t)
+	if err := db.Migrate(gdb); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	states, _ := db.MigrationStatus(gdb)
+	if err := db.Rollback(gdb, len(states)); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if gdb.Migrator().HasTable(&db.Project{}) {
+		t.Error("projects table should be dropped after full rollback")
+	}
+	states, _ = db.MigrationStatus(gdb)
+	for _, st := range states {
+		if st.Applied {
+			t.Errorf("migration %s still applied after rollback", st.Version)
+		}
+	}
+
+	// 回滚后可以重新迁移
+	if err := db.Migrate(gdb); err != nil {
+		t.Fatalf("re-migrate: %v", err)
+	}
+}
+
+// 迁移使用冻结的结构体建表，业务模型新增字段、索引而忘记写迁移时在这里发现
+func TestMigrate_SchemaMatchesModels(t *testing.T) {
+	gdb := openMemoryDB(t)
+	if err := db.Migrate(gdb); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	m := gdb.Migrator()
+	for _, model := range db.Models() {
+		stmt := &gorm.Statement{DB: gdb}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatalf("parse %T: %v", model, err)
+		}
+		table := stmt.Schema.Table
+		if !m.HasTable(model) {
+			t.Errorf("table %s missing", table)
+			continue
+		}
+		columns, err := m.ColumnTypes(model)
+		if err != nil {
+			t.Fatalf("column types of %s: %v", table, err)
+		}
+		types := make(map[string]string, len(columns))
+		for _, c := range columns {
+			types[c.Name()] = strings.ToLower(c.DatabaseTypeName())
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" || field.IgnoreMigration {
+				continue
+			}
+			got, ok := types[field.DBName]
+			if !ok {
+				t.Errorf("column %s.%s missing", table, field.DBName)
+				continue
+			}
+			want := gdb.Dialector.DataTypeOf(field)
+			if typer, ok := reflect.New(field.IndirectFieldType).Interface().(migrator.GormDataTypeInterface); ok {
+				want = typer.GormDBDataType(gdb, field)
+			}
+			if want = strings.ToLower(want); got != want {
+				t.Errorf("column %s.%s is %s, model wants %s", table, field.DBName, got, want)
+			}
+		}
+		for _, idx := range stmt.Schema.ParseIndexes() {
+			if !m.HasIndex(model, idx.Name) {
+				t.Errorf("index %s on %s missing", idx.Name, table)
+			}
+		}
+		for _, rel := range stmt.Schema.Relationships.Relations {
+			if c := rel.ParseConstraint(); c != nil && c.Schema == stmt.Schema && !m.HasConstraint(model, c.Name) {
+				t.Errorf("constraint %s on %s missing", c.Name, table)
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0001 时的表结构
+type project0001 struct {
+	Base             migrationBase `gorm:"embedded"`
+	Name             string        `gorm:"not null"`
+	Description      string        `gorm:"type:text"`
+	MaskingProfileID string
+	TemplateType     string        `gorm:"default:'both'"`
+	Sessions         []session0001 `gorm:"foreignKey:ProjectID"`
+}
+
+func (project0001) TableName() string { return "projects" }
+
+type session0001 struct {
+	Base           migrationBase `gorm:"embedded"`
+	ProjectID      string        `gorm:"not null;index"`
+	Title          string        `gorm:"not null"`
+	Status         string        `gorm:"default:'idle'"`
+	StartedAt      *time.Time
+	EndedAt        *time.Time
+	TargetURL      string `gorm:"type:text"`
+	GeneratedDocID string
+	Steps          []recordingStep0001 `gorm:"foreignKey:SessionID"`
+}
+
+func (session0001) TableName() string { return "sessions" }
+
+type recordingStep0001 struct {
+	Base           migrationBase `gorm:"embedded"`
+	SessionID      string        `gorm:"not null;index"`
+	StepIndex      int           `gorm:"not null"`
+	Timestamp      int64
+	Action         string `gorm:"not null"`
+	TargetSelector string `gorm:"type:text"`
+	TargetXPath    string `gorm:"type:text"`
+	TargetElement  string `gorm:"type:text"`
+	AriaLabel      string
+	MaskedText     string `gorm:"type:text"`
+	InputValue     string `gorm:"type:text"`
+	PageURL        string `gorm:"type:text"`
+	PageTitle      string
+	ScreenshotID   string
+	AIDescription  string `gorm:"type:text"`
+	AINotes        string `gorm:"type:text"`
+	IsEdited       bool   `gorm:"default:false"`
+	IsMasked       bool   `gorm:"default:false"`
+	DOMFingerprint string `gorm:"index"`
+}
+
+func (recordingStep0001) TableName() string { return "recording_steps" }
+
+type screenshot0001 struct {
+	Base          migrationBase `gorm:"embedded"`
+	SessionID     string        `gorm:"not null;index"`
+	StepID        string        `gorm:"not null;index"`
+	CapturedAt    int64
+	DataURL       string `gorm:"type:text"`
+	Width         int
+	Height        int
+	MaskedRegions string `gorm:"type:text"`
+	IsRawDeleted  bool   `gorm:"default:false"`
+}
+
+func (screenshot0001) TableName() string { return "screenshots" }
+
+type maskingProfile0001 struct {
+	Base  migrationBase     `gorm:"embedded"`
+	Name  string            `gorm:"not null"`
+	Rules []maskingRule0001 `gorm:"foreignKey:ProfileID"`
+}
+
+func (maskingProfile0001) TableName() string { return "masking_profiles" }
+
+type maskingRule0001 struct {
+	Base        migrationBase `gorm:"embedded"`
+	ProfileID   string        `gorm:"not null;index"`
+	RuleType    string        `gorm:"not null"`
+	Pattern     string        `gorm:"not null;type:text"`
+	Alias       string        `gorm:"not null"`
+	Scope       string        `gorm:"default:'session'"`
+	IsActive    bool          `gorm:"default:true"`
+	Description string
+}
+
+func (maskingRule0001) TableName() string { return "masking_rules" }
+
+type generatedDocument0001 struct {
+	Base          migrationBase `gorm:"embedded"`
+	SessionID     string        `gorm:"not null;index"`
+	ProjectID     string        `gorm:"not null;index"`
+	Status        string        `gorm:"default:'draft'"`
+	BusinessView  string        `gorm:"type:text"`
+	TechnicalView string        `gorm:"type:text"`
+}
+
+func (generatedDocument0001) TableName() string { return "generated_documents" }
+
+type llmProvider0001 struct {
+	Base      migrationBase `gorm:"embedded"`
+	Name      string        `gorm:"not null"`
+	APIKey    string
+	BaseURL   string
+	Model     string
+	IsDefault bool `gorm:"default:false"`
+	IsActive  bool `gorm:"default:true"`
+}
+
+func (llmProvider0001) TableName() string { return "llm_providers" }
+
+// 0001：初始表结构（兼容升级前由 AutoMigrate 创建的数据库：已存在的表保持不变）
+func init() {
+	tables := func() []interface{} {
+		return []interface{}{
+			&project0001{},
+			&session0001{},
+			&recordingStep0001{},
+			&screenshot0001{},
+			&maskingProfile0001{},
+			&maskingRule0001{},
+			&generatedDocument0001{},
+			&llmProvider0001{},
+		}
+	}
+	register(Migration{
+		Version: "0001_init",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, t := range tables() {
+				if m.HasTable(t) {
+					continue
+				}
+				if err := m.CreateTable(t); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(tables()...)
+		},
+	})
+}
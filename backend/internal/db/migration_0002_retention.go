@@ -0,0 +1,40 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0002 时新增的列
+type project0002 struct {
+	ScreenshotRetentionDays int `gorm:"default:0"`
+	SessionRetentionDays    int `gorm:"default:0"`
+}
+
+func (project0002) TableName() string { return "projects" }
+
+type generatedDocument0002 struct {
+	ApprovedAt *time.Time
+}
+
+func (generatedDocument0002) TableName() string { return "generated_documents" }
+
+// 0002：项目级数据保留策略 + 文档审批时间
+func init() {
+	register(Migration{
+		Version: "0002_retention",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &project0002{}); err != nil {
+				return err
+			}
+			return addColumns(tx, &generatedDocument0002{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumns(tx, &generatedDocument0002{}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &project0002{})
+		},
+	})
+}
@@ -0,0 +1,23 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0003 时新增的列
+type recordingStep0003 struct {
+	DuplicateOf string `gorm:"index"`
+}
+
+func (recordingStep0003) TableName() string { return "recording_steps" }
+
+// 0003：步骤重复标记
+func init() {
+	register(Migration{
+		Version: "0003_step_duplicate",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &recordingStep0003{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &recordingStep0003{})
+		},
+	})
+}
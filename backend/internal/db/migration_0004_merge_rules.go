@@ -0,0 +1,24 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0004 时新增的列
+type project0004 struct {
+	MergeStrategy      string `gorm:"default:'location'"`
+	MergeWindowSeconds int    `gorm:"default:30"`
+}
+
+func (project0004) TableName() string { return "projects" }
+
+// 0004：项目级业务视图合并策略
+func init() {
+	register(Migration{
+		Version: "0004_merge_rules",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &project0004{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &project0004{})
+		},
+	})
+}
@@ -0,0 +1,28 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0005 时新增的列
+type recordingStep0005 struct {
+	ClickX int
+	ClickY int
+	BBoxX  int `gorm:"column:bbox_x"`
+	BBoxY  int `gorm:"column:bbox_y"`
+	BBoxW  int `gorm:"column:bbox_w"`
+	BBoxH  int `gorm:"column:bbox_h"`
+}
+
+func (recordingStep0005) TableName() string { return "recording_steps" }
+
+// 0005：步骤点击坐标与目标元素边界框
+func init() {
+	register(Migration{
+		Version: "0005_step_geometry",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &recordingStep0005{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &recordingStep0005{})
+		},
+	})
+}
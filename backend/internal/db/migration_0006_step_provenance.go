@@ -0,0 +1,26 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0006 时新增的列
+type recordingStep0006 struct {
+	AIProvider  string `gorm:"column:ai_provider"`
+	AIModel     string `gorm:"column:ai_model"`
+	AILatencyMS int64  `gorm:"column:ai_latency_ms"`
+	AIUsedFree  bool   `gorm:"column:ai_used_free"`
+}
+
+func (recordingStep0006) TableName() string { return "recording_steps" }
+
+// 0006：记录步骤描述由哪个提供商/模型生成
+func init() {
+	register(Migration{
+		Version: "0006_step_provenance",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &recordingStep0006{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &recordingStep0006{})
+		},
+	})
+}
@@ -0,0 +1,28 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0007 时新增的列
+type session0007 struct {
+	StepSeq int `gorm:"not null;default:0"`
+}
+
+func (session0007) TableName() string { return "sessions" }
+
+// 0007：会话级步骤序号计数器，按现有最大序号初始化
+func init() {
+	register(Migration{
+		Version: "0007_step_seq",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &session0007{}); err != nil {
+				return err
+			}
+			return tx.Exec(`UPDATE sessions SET step_seq = (
+				SELECT COALESCE(MAX(step_index), 0) FROM recording_steps WHERE recording_steps.session_id = sessions.id
+			)`).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &session0007{})
+		},
+	})
+}
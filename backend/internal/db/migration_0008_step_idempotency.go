@@ -0,0 +1,23 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0008 时新增的列
+type recordingStep0008 struct {
+	IdempotencyKey *string `gorm:"size:64;uniqueIndex"`
+}
+
+func (recordingStep0008) TableName() string { return "recording_steps" }
+
+// 0008：步骤上报幂等键
+func init() {
+	register(Migration{
+		Version: "0008_step_idempotency",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &recordingStep0008{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &recordingStep0008{})
+		},
+	})
+}
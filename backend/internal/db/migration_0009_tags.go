@@ -0,0 +1,59 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0009 时新增的表；关联表的列与外键同 gorm many2many 生成的 project_tags / session_tags
+type tag0009 struct {
+	Base  migrationBase `gorm:"embedded"`
+	Name  string        `gorm:"not null;size:64;uniqueIndex"`
+	Color string        `gorm:"size:16"`
+}
+
+func (tag0009) TableName() string { return "tags" }
+
+type projectRef0009 struct {
+	ID string `gorm:"primaryKey;size:36"`
+}
+
+func (projectRef0009) TableName() string { return "projects" }
+
+type sessionRef0009 struct {
+	ID string `gorm:"primaryKey;size:36"`
+}
+
+func (sessionRef0009) TableName() string { return "sessions" }
+
+type projectTag0009 struct {
+	ProjectID string         `gorm:"primaryKey;size:36"`
+	TagID     string         `gorm:"primaryKey;size:36"`
+	Project   projectRef0009 `gorm:"foreignKey:ProjectID"`
+	Tag       tag0009        `gorm:"foreignKey:TagID"`
+}
+
+func (projectTag0009) TableName() string { return "project_tags" }
+
+type sessionTag0009 struct {
+	SessionID string         `gorm:"primaryKey;size:36"`
+	TagID     string         `gorm:"primaryKey;size:36"`
+	Session   sessionRef0009 `gorm:"foreignKey:SessionID"`
+	Tag       tag0009        `gorm:"foreignKey:TagID"`
+}
+
+func (sessionTag0009) TableName() string { return "session_tags" }
+
+// 0009：标签及项目/会话关联表
+func init() {
+	register(Migration{
+		Version: "0009_tags",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.CreateTable(&tag0009{}); err != nil {
+				return err
+			}
+			return m.CreateTable(&projectTag0009{}, &sessionTag0009{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("project_tags", "session_tags", &tag0009{})
+		},
+	})
+}
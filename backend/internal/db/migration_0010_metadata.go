@@ -0,0 +1,35 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0010 时新增的列
+type project0010 struct {
+	Metadata string `gorm:"type:text"`
+}
+
+func (project0010) TableName() string { return "projects" }
+
+type generatedDocument0010 struct {
+	Metadata string `gorm:"type:text"`
+}
+
+func (generatedDocument0010) TableName() string { return "generated_documents" }
+
+// 0010：项目与文档的自定义元数据字段
+func init() {
+	register(Migration{
+		Version: "0010_metadata",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &project0010{}); err != nil {
+				return err
+			}
+			return addColumns(tx, &generatedDocument0010{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumns(tx, &generatedDocument0010{}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &project0010{})
+		},
+	})
+}
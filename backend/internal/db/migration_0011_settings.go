@@ -0,0 +1,29 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0011 时新增的表
+type setting0011 struct {
+	Key       string `gorm:"primaryKey;size:64"`
+	Value     string `gorm:"type:text"`
+	UpdatedAt time.Time
+}
+
+func (setting0011) TableName() string { return "settings" }
+
+// 0011：运行时设置
+func init() {
+	register(Migration{
+		Version: "0011_settings",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&setting0011{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&setting0011{})
+		},
+	})
+}
@@ -0,0 +1,91 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0012 时新增的列
+type project0012 struct {
+	ShowTiming bool `gorm:"default:false"`
+}
+
+func (project0012) TableName() string { return "projects" }
+
+type session0012 struct {
+	DurationMS int64 `gorm:"not null;default:0"`
+}
+
+func (session0012) TableName() string { return "sessions" }
+
+type recordingStep0012 struct {
+	ElapsedMS int64 `gorm:"not null;default:0"`
+}
+
+func (recordingStep0012) TableName() string { return "recording_steps" }
+
+// 0012：步骤耗时、会话录制时长与项目耗时提示开关，按现有时间戳回填
+func init() {
+	register(Migration{
+		Version: "0012_timing",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &project0012{}); err != nil {
+				return err
+			}
+			if err := addColumns(tx, &session0012{}); err != nil {
+				return err
+			}
+			if err := addColumns(tx, &recordingStep0012{}); err != nil {
+				return err
+			}
+			return backfillTiming(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumns(tx, &recordingStep0012{}); err != nil {
+				return err
+			}
+			if err := dropColumns(tx, &session0012{}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &project0012{})
+		},
+	})
+}
+
+// maxStepGapMS 与 service.MaxStepGapMS 一致（迁移不依赖 service 包）
+const maxStepGapMS int64 = 5 * 60 * 1000
+
+// backfillTiming 逐会话计算步骤耗时与会话时长（规则同 service.RecomputeTiming）
+func backfillTiming(tx *gorm.DB) error {
+	var sessionIDs []string
+	if err := tx.Table("recording_steps").Distinct("session_id").Pluck("session_id", &sessionIDs).Error; err != nil {
+		return err
+	}
+	for _, id := range sessionIDs {
+		var steps []struct {
+			ID        string
+			Timestamp int64
+		}
+		if err := tx.Table("recording_steps").Select("id", "timestamp").Where("session_id = ?", id).
+			Order("step_index, created_at").Scan(&steps).Error; err != nil {
+			return err
+		}
+		var total int64
+		for i := 1; i < len(steps); i++ {
+			prev, next := steps[i-1].Timestamp, steps[i].Timestamp
+			if prev <= 0 || next <= prev {
+				continue
+			}
+			gap := next - prev
+			if gap > maxStepGapMS {
+				gap = maxStepGapMS
+			}
+			total += gap
+			if err := tx.Table("recording_steps").Where("id = ?", steps[i].ID).
+				UpdateColumn("elapsed_ms", gap).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Table("sessions").Where("id = ?", id).UpdateColumn("duration_ms", total).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
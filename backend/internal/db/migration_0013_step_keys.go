@@ -0,0 +1,23 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0013 时新增的列
+type recordingStep0013 struct {
+	KeyCombo string `gorm:"size:64"`
+}
+
+func (recordingStep0013) TableName() string { return "recording_steps" }
+
+// 0013：键盘操作的按键组合
+func init() {
+	register(Migration{
+		Version: "0013_step_keys",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &recordingStep0013{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &recordingStep0013{})
+		},
+	})
+}
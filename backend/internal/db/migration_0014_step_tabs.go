@@ -0,0 +1,25 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0014 时新增的列
+type recordingStep0014 struct {
+	TabID     int
+	WindowID  int
+	FramePath string `gorm:"type:text"`
+}
+
+func (recordingStep0014) TableName() string { return "recording_steps" }
+
+// 0014：步骤所在的标签页、窗口与 iframe 路径
+func init() {
+	register(Migration{
+		Version: "0014_step_tabs",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &recordingStep0014{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &recordingStep0014{})
+		},
+	})
+}
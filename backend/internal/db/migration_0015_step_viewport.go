@@ -0,0 +1,26 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0015 时新增的列
+type recordingStep0015 struct {
+	ViewportW int `gorm:"column:viewport_w"`
+	ViewportH int `gorm:"column:viewport_h"`
+	ScrollX   int
+	ScrollY   int
+}
+
+func (recordingStep0015) TableName() string { return "recording_steps" }
+
+// 0015：步骤的视口尺寸与滚动偏移
+func init() {
+	register(Migration{
+		Version: "0015_step_viewport",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &recordingStep0015{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &recordingStep0015{})
+		},
+	})
+}
@@ -0,0 +1,31 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0016 时新增的表
+type sessionMedia0016 struct {
+	Base       migrationBase `gorm:"embedded"`
+	SessionID  string        `gorm:"not null;index"`
+	Kind       string        `gorm:"not null"`
+	MimeType   string        `gorm:"not null"`
+	FileName   string
+	Path       string `gorm:"not null"`
+	Size       int64
+	SHA256     string `gorm:"column:sha256"`
+	DurationMS int64
+}
+
+func (sessionMedia0016) TableName() string { return "session_media" }
+
+// 0016：会话附件（操作录像）
+func init() {
+	register(Migration{
+		Version: "0016_session_media",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&sessionMedia0016{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&sessionMedia0016{})
+		},
+	})
+}
@@ -0,0 +1,32 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0017 时新增的表
+type stepRequest0017 struct {
+	Base         migrationBase `gorm:"embedded"`
+	StepID       string        `gorm:"not null;index"`
+	SessionID    string        `gorm:"not null;index"`
+	Seq          int
+	Method       string `gorm:"not null"`
+	URL          string `gorm:"not null"`
+	Status       int
+	ResourceType string
+	MimeType     string
+	DurationMS   int64
+}
+
+func (stepRequest0017) TableName() string { return "step_requests" }
+
+// 0017：步骤网络请求（HAR 元数据）
+func init() {
+	register(Migration{
+		Version: "0017_step_requests",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&stepRequest0017{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&stepRequest0017{})
+		},
+	})
+}
@@ -0,0 +1,31 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0018 时新增的表
+type stepLog0018 struct {
+	Base      migrationBase `gorm:"embedded"`
+	StepID    string        `gorm:"not null;index"`
+	SessionID string        `gorm:"not null;index"`
+	Seq       int
+	Level     string `gorm:"not null"`
+	Message   string `gorm:"type:text"`
+	Source    string
+	Line      int
+	Timestamp int64
+}
+
+func (stepLog0018) TableName() string { return "step_logs" }
+
+// 0018：步骤控制台日志
+func init() {
+	register(Migration{
+		Version: "0018_step_logs",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&stepLog0018{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&stepLog0018{})
+		},
+	})
+}
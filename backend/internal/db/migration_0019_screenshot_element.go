@@ -0,0 +1,23 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0019 时新增的列
+type screenshot0019 struct {
+	ElementURL string `gorm:"type:text"`
+}
+
+func (screenshot0019) TableName() string { return "screenshots" }
+
+// 0019：截图的目标元素局部图
+func init() {
+	register(Migration{
+		Version: "0019_screenshot_element",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &screenshot0019{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &screenshot0019{})
+		},
+	})
+}
@@ -0,0 +1,31 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0020 时新增的表
+type maskingEvent0020 struct {
+	Base      migrationBase `gorm:"embedded"`
+	StepID    string        `gorm:"not null;index"`
+	SessionID string        `gorm:"not null;index"`
+	RuleID    string
+	RuleType  string `gorm:"not null"`
+	Alias     string
+	Category  string
+	Field     string `gorm:"not null"`
+	Count     int    `gorm:"not null"`
+}
+
+func (maskingEvent0020) TableName() string { return "masking_events" }
+
+// 0020：步骤脱敏审计记录
+func init() {
+	register(Migration{
+		Version: "0020_masking_events",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&maskingEvent0020{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&maskingEvent0020{})
+		},
+	})
+}
@@ -0,0 +1,24 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0021 时新增的列
+type maskingRule0021 struct {
+	URLPattern     string
+	URLPatternType string
+}
+
+func (maskingRule0021) TableName() string { return "masking_rules" }
+
+// 0021：按 URL 生效的脱敏规则
+func init() {
+	register(Migration{
+		Version: "0021_masking_url_scope",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &maskingRule0021{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &maskingRule0021{})
+		},
+	})
+}
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0022 时新增的列
+type session0022 struct {
+	PurgedAt *time.Time
+}
+
+func (session0022) TableName() string { return "sessions" }
+
+// 0022：会话内容清除时间
+func init() {
+	register(Migration{
+		Version: "0022_session_purge",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &session0022{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &session0022{})
+		},
+	})
+}
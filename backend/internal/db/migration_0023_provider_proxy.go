@@ -0,0 +1,23 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0023 时新增的列
+type llmProvider0023 struct {
+	Proxy string
+}
+
+func (llmProvider0023) TableName() string { return "llm_providers" }
+
+// 0023：按提供商配置的出站代理
+func init() {
+	register(Migration{
+		Version: "0023_provider_proxy",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &llmProvider0023{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &llmProvider0023{})
+		},
+	})
+}
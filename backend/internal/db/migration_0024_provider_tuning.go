@@ -0,0 +1,26 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0024 时新增的列
+type llmProvider0024 struct {
+	TimeoutSeconds int `gorm:"default:0"`
+	MaxRetries     int `gorm:"default:0"`
+	Temperature    *float64
+	MaxTokens      int `gorm:"default:0"`
+}
+
+func (llmProvider0024) TableName() string { return "llm_providers" }
+
+// 0024：按提供商的超时、重试、温度与最大 token 数
+func init() {
+	register(Migration{
+		Version: "0024_provider_tuning",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &llmProvider0024{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &llmProvider0024{})
+		},
+	})
+}
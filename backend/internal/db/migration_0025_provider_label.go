@@ -0,0 +1,23 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0025 时新增的列
+type llmProvider0025 struct {
+	Label string `gorm:"default:''"`
+}
+
+func (llmProvider0025) TableName() string { return "llm_providers" }
+
+// 0025：同类型提供商的多个命名配置
+func init() {
+	register(Migration{
+		Version: "0025_provider_label",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &llmProvider0025{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &llmProvider0025{})
+		},
+	})
+}
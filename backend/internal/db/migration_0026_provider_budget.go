@@ -0,0 +1,44 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0026 时新增的表与列
+type llmProvider0026 struct {
+	CostPerCall    float64 `gorm:"default:0"`
+	DailyCallCap   int     `gorm:"default:0"`
+	MonthlyCallCap int     `gorm:"default:0"`
+	DailyCostCap   float64 `gorm:"default:0"`
+	MonthlyCostCap float64 `gorm:"default:0"`
+}
+
+func (llmProvider0026) TableName() string { return "llm_providers" }
+
+type providerCall0026 struct {
+	Base       migrationBase `gorm:"embedded"`
+	ProviderID string        `gorm:"size:36;index:idx_provider_calls,priority:1"`
+	Provider   string        `gorm:"not null"`
+	Label      string
+	Model      string
+	Cost       float64
+}
+
+func (providerCall0026) TableName() string { return "provider_calls" }
+
+// 0026：提供商预算上限与调用记录
+func init() {
+	register(Migration{
+		Version: "0026_provider_budget",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().CreateTable(&providerCall0026{}); err != nil {
+				return err
+			}
+			return addColumns(tx, &llmProvider0026{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumns(tx, &llmProvider0026{}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&providerCall0026{})
+		},
+	})
+}
@@ -0,0 +1,23 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0027 时新增的列
+type project0027 struct {
+	FreeOnly bool `gorm:"default:false"`
+}
+
+func (project0027) TableName() string { return "projects" }
+
+// 0027：项目仅免费生成开关
+func init() {
+	register(Migration{
+		Version: "0027_project_free_only",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &project0027{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &project0027{})
+		},
+	})
+}
@@ -0,0 +1,24 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0028 时新增的列
+type project0028 struct {
+	NumberingStyle string `gorm:"default:'step'"`
+	HeadingBase    int    `gorm:"default:1"`
+}
+
+func (project0028) TableName() string { return "projects" }
+
+// 0028：项目导出编号样式与标题级别
+func init() {
+	register(Migration{
+		Version: "0028_project_numbering",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &project0028{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &project0028{})
+		},
+	})
+}
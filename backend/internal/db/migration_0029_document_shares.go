@@ -0,0 +1,36 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0029 时新增的表
+type documentShare0029 struct {
+	Base         migrationBase `gorm:"embedded"`
+	DocumentID   string        `gorm:"size:36;index;not null"`
+	SessionID    string        `gorm:"size:36;index"`
+	TokenHash    string        `gorm:"size:64;uniqueIndex"`
+	TokenPrefix  string        `gorm:"size:8"`
+	Note         string
+	ExpiresAt    time.Time `gorm:"not null"`
+	RevokedAt    *time.Time
+	AccessCount  int64 `gorm:"default:0"`
+	LastAccessAt *time.Time
+}
+
+func (documentShare0029) TableName() string { return "document_shares" }
+
+// 0029：文档分享链接
+func init() {
+	register(Migration{
+		Version: "0029_document_shares",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&documentShare0029{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&documentShare0029{})
+		},
+	})
+}
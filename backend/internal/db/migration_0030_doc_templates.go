@@ -0,0 +1,29 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0030 时新增的表
+type docTemplate0030 struct {
+	Base        migrationBase `gorm:"embedded"`
+	Key         string        `gorm:"size:64;uniqueIndex;not null"`
+	Name        string        `gorm:"not null"`
+	Description string        `gorm:"type:text"`
+	View        string
+	Preface     string `gorm:"type:text"`
+	Closing     string `gorm:"type:text"`
+}
+
+func (docTemplate0030) TableName() string { return "doc_templates" }
+
+// 0030：自定义文档模板
+func init() {
+	register(Migration{
+		Version: "0030_doc_templates",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&docTemplate0030{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&docTemplate0030{})
+		},
+	})
+}
@@ -0,0 +1,23 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0031 时新增的列
+type recordingStep0031 struct {
+	Excluded bool `gorm:"default:false"`
+}
+
+func (recordingStep0031) TableName() string { return "recording_steps" }
+
+// 0031：步骤排除出业务视图的开关
+func init() {
+	register(Migration{
+		Version: "0031_step_excluded",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &recordingStep0031{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &recordingStep0031{})
+		},
+	})
+}
@@ -0,0 +1,26 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0032 时新增的表
+type glossaryTerm0032 struct {
+	Base      migrationBase `gorm:"embedded"`
+	ProjectID string        `gorm:"size:64;not null;uniqueIndex:idx_glossary_project_term"`
+	Term      string        `gorm:"size:64;not null;uniqueIndex:idx_glossary_project_term"`
+	Preferred string        `gorm:"size:64;not null"`
+}
+
+func (glossaryTerm0032) TableName() string { return "glossary_terms" }
+
+// 0032：项目术语表
+func init() {
+	register(Migration{
+		Version: "0032_glossary_terms",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&glossaryTerm0032{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&glossaryTerm0032{})
+		},
+	})
+}
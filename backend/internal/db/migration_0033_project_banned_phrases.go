@@ -0,0 +1,23 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0033 时新增的列
+type project0033 struct {
+	BannedPhrases string `gorm:"type:text"`
+}
+
+func (project0033) TableName() string { return "projects" }
+
+// 0033：项目禁用词
+func init() {
+	register(Migration{
+		Version: "0033_project_banned_phrases",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &project0033{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &project0033{})
+		},
+	})
+}
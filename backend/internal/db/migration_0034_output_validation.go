@@ -0,0 +1,31 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0034 时新增的表
+type outputValidationFailure0034 struct {
+	Base       migrationBase `gorm:"embedded"`
+	ProviderID string        `gorm:"size:36;index"`
+	Provider   string        `gorm:"index;not null"`
+	Label      string
+	Model      string
+	StepIndex  int
+	Problems   string `gorm:"type:text"`
+	Output     string `gorm:"type:text"`
+	Recovered  bool
+}
+
+func (outputValidationFailure0034) TableName() string { return "output_validation_failures" }
+
+// 0034：模型输出格式校验失败记录
+func init() {
+	register(Migration{
+		Version: "0034_output_validation",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&outputValidationFailure0034{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&outputValidationFailure0034{})
+		},
+	})
+}
@@ -0,0 +1,35 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0035 时新增的列
+type llmProvider0035 struct {
+	TextOnly bool `gorm:"default:false"`
+}
+
+func (llmProvider0035) TableName() string { return "llm_providers" }
+
+type recordingStep0035 struct {
+	AITextOnly bool `gorm:"column:ai_text_only"`
+}
+
+func (recordingStep0035) TableName() string { return "recording_steps" }
+
+// 0035：纯文本生成模式（提供商开关与步骤描述的生成模式）
+func init() {
+	register(Migration{
+		Version: "0035_text_only",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &llmProvider0035{}); err != nil {
+				return err
+			}
+			return addColumns(tx, &recordingStep0035{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumns(tx, &recordingStep0035{}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &llmProvider0035{})
+		},
+	})
+}
@@ -0,0 +1,23 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0036 时新增的列
+type screenshot0036 struct {
+	OCRText string `gorm:"column:ocr_text;type:text"`
+}
+
+func (screenshot0036) TableName() string { return "screenshots" }
+
+// 0036：截图识别文字
+func init() {
+	register(Migration{
+		Version: "0036_screenshot_ocr",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &screenshot0036{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &screenshot0036{})
+		},
+	})
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0037 时新增的表
+type replayRun0037 struct {
+	Base       migrationBase `gorm:"embedded"`
+	SessionID  string        `gorm:"size:36;index;not null"`
+	Status     string        `gorm:"not null"`
+	BaseURL    string
+	Total      int
+	Passed     int
+	Failed     int
+	Skipped    int
+	Error      string `gorm:"type:text"`
+	FinishedAt *time.Time
+}
+
+func (replayRun0037) TableName() string { return "replay_runs" }
+
+type replayStep0037 struct {
+	Base       migrationBase `gorm:"embedded"`
+	RunID      string        `gorm:"size:36;index;not null"`
+	SessionID  string        `gorm:"size:36;index;not null"`
+	StepID     string        `gorm:"size:36"`
+	StepIndex  int
+	Status     string `gorm:"not null"`
+	Error      string `gorm:"type:text"`
+	DataURL    string `gorm:"type:text"`
+	Width      int
+	Height     int
+	DurationMS int64
+}
+
+func (replayStep0037) TableName() string { return "replay_steps" }
+
+// 0037：步骤回放记录
+func init() {
+	register(Migration{
+		Version: "0037_replay_runs",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&replayRun0037{}, &replayStep0037{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&replayStep0037{}, &replayRun0037{})
+		},
+	})
+}
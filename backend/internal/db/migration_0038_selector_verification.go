@@ -0,0 +1,40 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0038 时新增的列
+type recordingStep0038 struct {
+	SelectorStale     bool `gorm:"default:false"`
+	SelectorCheckedAt *time.Time
+}
+
+func (recordingStep0038) TableName() string { return "recording_steps" }
+
+type replayRun0038 struct {
+	Mode string `gorm:"not null;default:'replay'"`
+}
+
+func (replayRun0038) TableName() string { return "replay_runs" }
+
+// 0038：选择器校验（步骤失效标记、回放方式）
+func init() {
+	register(Migration{
+		Version: "0038_selector_verification",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &recordingStep0038{}); err != nil {
+				return err
+			}
+			return addColumns(tx, &replayRun0038{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumns(tx, &replayRun0038{}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &recordingStep0038{})
+		},
+	})
+}
@@ -0,0 +1,37 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0039 时新增的表
+type compiledDocument0039 struct {
+	Base        migrationBase         `gorm:"embedded"`
+	ProjectID   string                `gorm:"size:36;index;not null"`
+	Title       string                `gorm:"not null"`
+	Description string                `gorm:"type:text"`
+	Chapters    []compiledChapter0039 `gorm:"foreignKey:CompiledID"`
+}
+
+func (compiledDocument0039) TableName() string { return "compiled_documents" }
+
+type compiledChapter0039 struct {
+	Base       migrationBase `gorm:"embedded"`
+	CompiledID string        `gorm:"size:36;index;not null"`
+	SessionID  string        `gorm:"size:36;index;not null"`
+	Position   int           `gorm:"not null"`
+	Title      string
+}
+
+func (compiledChapter0039) TableName() string { return "compiled_chapters" }
+
+// 0039：项目合订手册及其章节
+func init() {
+	register(Migration{
+		Version: "0039_compiled_documents",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&compiledDocument0039{}, &compiledChapter0039{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&compiledChapter0039{}, &compiledDocument0039{})
+		},
+	})
+}
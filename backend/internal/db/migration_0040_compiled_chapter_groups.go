@@ -0,0 +1,24 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0040 时新增的列
+type compiledChapter0040 struct {
+	GroupTitle string
+	Excluded   bool `gorm:"default:false"`
+}
+
+func (compiledChapter0040) TableName() string { return "compiled_chapters" }
+
+// 0040：合订手册章节的分组标题与排除标记
+func init() {
+	register(Migration{
+		Version: "0040_compiled_chapter_groups",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &compiledChapter0040{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &compiledChapter0040{})
+		},
+	})
+}
@@ -0,0 +1,30 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0041 时新增的表
+type embedding0041 struct {
+	Base       migrationBase `gorm:"embedded"`
+	SourceType string        `gorm:"size:16;not null;uniqueIndex:idx_embedding_source"`
+	SourceID   string        `gorm:"size:36;not null;uniqueIndex:idx_embedding_source"`
+	SessionID  string        `gorm:"size:36;index;not null"`
+	Model      string        `gorm:"not null"`
+	TextHash   string        `gorm:"size:64"`
+	Text       string        `gorm:"type:text"`
+	Vector     []byte
+}
+
+func (embedding0041) TableName() string { return "embeddings" }
+
+// 0041：语义检索的向量索引
+func init() {
+	register(Migration{
+		Version: "0041_embeddings",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&embedding0041{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&embedding0041{})
+		},
+	})
+}
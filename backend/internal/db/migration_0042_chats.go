@@ -0,0 +1,39 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0042 时新增的表
+type chatConversation0042 struct {
+	Base      migrationBase `gorm:"embedded"`
+	SessionID string        `gorm:"size:36;index;not null"`
+	Title     string
+	Messages  []chatMessage0042 `gorm:"foreignKey:ConversationID"`
+}
+
+func (chatConversation0042) TableName() string { return "chat_conversations" }
+
+type chatMessage0042 struct {
+	Base           migrationBase `gorm:"embedded"`
+	ConversationID string        `gorm:"size:36;index;not null"`
+	SessionID      string        `gorm:"size:36;index;not null"`
+	Role           string        `gorm:"size:16;not null"`
+	Content        string        `gorm:"type:text"`
+	StepIDs        string        `gorm:"type:text"`
+	Provider       string
+	Model          string
+}
+
+func (chatMessage0042) TableName() string { return "chat_messages" }
+
+// 0042：会话问答的对话与消息
+func init() {
+	register(Migration{
+		Version: "0042_chats",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&chatConversation0042{}, &chatMessage0042{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&chatMessage0042{}, &chatConversation0042{})
+		},
+	})
+}
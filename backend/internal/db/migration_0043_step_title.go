@@ -0,0 +1,23 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0043 时新增的列
+type recordingStep0043 struct {
+	AITitle string `gorm:"column:ai_title"`
+}
+
+func (recordingStep0043) TableName() string { return "recording_steps" }
+
+// 0043：步骤短标题
+func init() {
+	register(Migration{
+		Version: "0043_step_title",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &recordingStep0043{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &recordingStep0043{})
+		},
+	})
+}
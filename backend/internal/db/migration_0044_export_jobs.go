@@ -0,0 +1,38 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0044 时新增的表
+type exportJob0044 struct {
+	Base       migrationBase `gorm:"embedded"`
+	SourceType string        `gorm:"size:20;not null;index:idx_export_source"`
+	SourceID   string        `gorm:"size:36;not null;index:idx_export_source"`
+	SessionID  string        `gorm:"size:36;index"`
+	Format     string        `gorm:"size:20;not null"`
+	Options    string        `gorm:"type:text"`
+	Status     string        `gorm:"size:20;not null;index"`
+	FileName   string
+	Size       int64
+	Error      string `gorm:"type:text"`
+	FinishedAt *time.Time
+	ExpiresAt  *time.Time `gorm:"index"`
+}
+
+func (exportJob0044) TableName() string { return "export_jobs" }
+
+// 0044：后台导出任务
+func init() {
+	register(Migration{
+		Version: "0044_export_jobs",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().CreateTable(&exportJob0044{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&exportJob0044{})
+		},
+	})
+}
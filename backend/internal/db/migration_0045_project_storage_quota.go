@@ -0,0 +1,23 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0045 时新增的列
+type project0045 struct {
+	StorageQuotaMB int `gorm:"default:0"`
+}
+
+func (project0045) TableName() string { return "projects" }
+
+// 0045：项目存储配额
+func init() {
+	register(Migration{
+		Version: "0045_project_storage_quota",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &project0045{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &project0045{})
+		},
+	})
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// longTextColumns 保存截图 data URL 与内嵌截图文档视图的列，MySQL 的 TEXT 只有 64 KB，需改为 LONGTEXT
+var longTextColumns = [][2]string{
+	{"screenshots", "data_url"},
+	{"screenshots", "element_url"},
+	{"replay_steps", "data_url"},
+	{"generated_documents", "business_view"},
+	{"generated_documents", "technical_view"},
+}
+
+// 0046：MySQL 上截图与文档视图改为 LONGTEXT（PostgreSQL / SQLite 的 TEXT 不限长度，无需变更）
+func init() {
+	modify := func(tx *gorm.DB, typ string) error {
+		if tx.Dialector.Name() != "mysql" {
+			return nil
+		}
+		for _, c := range longTextColumns {
+			if err := tx.Exec("ALTER TABLE ? MODIFY ? "+typ, clause.Table{Name: c[0]}, clause.Column{Name: c[1]}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	register(Migration{
+		Version: "0046_long_text",
+		Up: func(tx *gorm.DB) error {
+			return modify(tx, "LONGTEXT")
+		},
+		Down: func(tx *gorm.DB) error {
+			return modify(tx, "TEXT")
+		},
+	})
+}
@@ -0,0 +1,35 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0047 时的幂等键索引：同一幂等键只在会话内唯一
+type recordingStep0047 struct {
+	SessionID      string  `gorm:"uniqueIndex:idx_recording_steps_session_idempotency,priority:1"`
+	IdempotencyKey *string `gorm:"size:64;uniqueIndex:idx_recording_steps_session_idempotency,priority:2"`
+}
+
+func (recordingStep0047) TableName() string { return "recording_steps" }
+
+// 0047：步骤幂等键由全局唯一改为按 (session_id, idempotency_key) 唯一；
+// 回滚时恢复全局唯一索引，若不同会话已存在相同幂等键会失败
+func init() {
+	register(Migration{
+		Version: "0047_step_idempotency_per_session",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if m.HasIndex(&recordingStep0008{}, "idx_recording_steps_idempotency_key") {
+				if err := m.DropIndex(&recordingStep0008{}, "idx_recording_steps_idempotency_key"); err != nil {
+					return err
+				}
+			}
+			return m.CreateIndex(&recordingStep0047{}, "idx_recording_steps_session_idempotency")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropIndex(&recordingStep0047{}, "idx_recording_steps_session_idempotency"); err != nil {
+				return err
+			}
+			return m.CreateIndex(&recordingStep0008{}, "idx_recording_steps_idempotency_key")
+		},
+	})
+}
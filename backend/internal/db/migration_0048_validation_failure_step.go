@@ -0,0 +1,24 @@
+package db
+
+import "gorm.io/gorm"
+
+// 0048 时新增的列
+type outputValidationFailure0048 struct {
+	SessionID string `gorm:"size:36;index"`
+	StepID    string `gorm:"size:36;index"`
+}
+
+func (outputValidationFailure0048) TableName() string { return "output_validation_failures" }
+
+// 0048：格式校验失败记录关联会话与步骤（记录中保存了模型输出原文）
+func init() {
+	register(Migration{
+		Version: "0048_validation_failure_step",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &outputValidationFailure0048{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &outputValidationFailure0048{})
+		},
+	})
+}
@@ -11,7 +11,7 @@ import (
 // 基础模型（所有表共用）
 // ─────────────────────────────────────
 type Base struct {
-	ID        string    `gorm:"primaryKey;type:text" json:"id"`
+	ID        string    `gorm:"primaryKey;size:36"   json:"id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -28,11 +28,23 @@ func (b *Base) BeforeCreate(tx *gorm.DB) error {
 // ─────────────────────────────────────
 type Project struct {
 	Base
-	Name             string    `gorm:"not null"              json:"name"`
-	Description      string    `                             json:"description"`
-	MaskingProfileID string    `                             json:"masking_profile_id,omitempty"`
-	TemplateType     string    `gorm:"default:'both'"        json:"template_type"`
-	Sessions         []Session `gorm:"foreignKey:ProjectID"  json:"sessions,omitempty"`
+	Name                    string     `gorm:"not null"              json:"name"`
+	Description             string     `gorm:"type:text"             json:"description"`
+	MaskingProfileID        string     `                             json:"masking_profile_id,omitempty"`
+	TemplateType            string     `gorm:"default:'both'"        json:"template_type"`
+	ScreenshotRetentionDays int        `gorm:"default:0"             json:"screenshot_retention_days"` // 文档审批通过 N 天后删除原始截图，0 为永久保留
+	SessionRetentionDays    int        `gorm:"default:0"             json:"session_retention_days"`    // 创建超过 N 天的会话整体清除，0 为永久保留
+	StorageQuotaMB          int        `gorm:"default:0"             json:"storage_quota_mb"`          // 截图、附件与导出产物的存储配额，0 为不限
+	MergeStrategy           string     `gorm:"default:'location'"    json:"merge_strategy"`            // 业务视图步骤合并策略：location | page | form | time | off
+	MergeWindowSeconds      int        `gorm:"default:30"            json:"merge_window_seconds"`      // merge_strategy=time 时的时间窗口
+	Metadata                Metadata   `gorm:"type:text"             json:"metadata"`                  // 自定义字段（文档编号、系统版本、责任单位等），作为项目下文档的默认值
+	ShowTiming              bool       `gorm:"default:false"         json:"show_timing"`               // 业务视图导出时标注各部分耗时（约 N 分钟）
+	FreeOnly                bool       `gorm:"default:false"         json:"free_only"`                 // 只使用免费提供商与规则描述生成（无预算团队）
+	NumberingStyle          string     `gorm:"default:'step'"        json:"numbering_style"`           // 导出编号样式：step（第 N 步）| hierarchical（1 / 1.1）| english（Step N）
+	HeadingBase             int        `gorm:"default:1"             json:"heading_base"`              // 导出文档标题的 Markdown 标题级别（1-4），章节、步骤依次下沉
+	BannedPhrases           StringList `gorm:"type:text"             json:"banned_phrases"`            // 禁用词：AI 输出命中时自动重试，文档含禁用词时不能审批（与全局设置合并）
+	Sessions                []Session  `gorm:"foreignKey:ProjectID"  json:"sessions,omitempty"`
+	Tags                    []Tag      `gorm:"many2many:project_tags" json:"tags"`
 }
 
 // ─────────────────────────────────────
@@ -45,10 +57,23 @@ type Session struct {
 	Status         string          `gorm:"default:'idle'"             json:"status"`
 	StartedAt      *time.Time      `                                  json:"started_at,omitempty"`
 	EndedAt        *time.Time      `                                  json:"ended_at,omitempty"`
-	TargetURL      string          `                                  json:"target_url"`
+	TargetURL      string          `gorm:"type:text"                  json:"target_url"`
 	GeneratedDocID string          `                                  json:"generated_doc_id,omitempty"`
+	StepSeq        int             `gorm:"not null;default:0"         json:"-"`                   // 已分配的最大步骤序号
+	DurationMS     int64           `gorm:"not null;default:0"         json:"duration_ms"`         // 录制时长：各步骤耗时之和（长时间停顿按上限计）
+	PurgedAt       *time.Time      `                                  json:"purged_at,omitempty"` // 应数据主体删除请求清除内容的时间，之后只保留匿名骨架
 	StepCount      int64           `gorm:"-"                          json:"step_count"`
 	Steps          []RecordingStep `gorm:"foreignKey:SessionID"       json:"steps,omitempty"`
+	Tags           []Tag           `gorm:"many2many:session_tags"     json:"tags"`
+}
+
+// ─────────────────────────────────────
+// Tag 标签（按系统、部门、版本等归类项目与会话）
+// ─────────────────────────────────────
+type Tag struct {
+	Base
+	Name  string `gorm:"not null;size:64;uniqueIndex" json:"name"`
+	Color string `gorm:"size:16"                      json:"color,omitempty"`
 }
 
 // ─────────────────────────────────────
@@ -56,24 +81,56 @@ type Session struct {
 // ─────────────────────────────────────
 type RecordingStep struct {
 	Base
-	SessionID      string `gorm:"not null;index"  json:"session_id"`
+	SessionID      string `gorm:"not null;index;uniqueIndex:idx_recording_steps_session_idempotency,priority:1"  json:"session_id"`
 	StepIndex      int    `gorm:"not null"        json:"step_index"`
 	Timestamp      int64  `                       json:"timestamp"`
+	ElapsedMS      int64  `gorm:"not null;default:0" json:"elapsed_ms"` // 距上一步的耗时（毫秒），首步为 0
 	Action         string `gorm:"not null"        json:"action"`
-	TargetSelector string `                       json:"target_selector"`
-	TargetXPath    string `                       json:"target_xpath"`
-	TargetElement  string `                       json:"target_element"`
+	TargetSelector string `gorm:"type:text"       json:"target_selector"`
+	TargetXPath    string `gorm:"type:text"       json:"target_xpath"`
+	TargetElement  string `gorm:"type:text"       json:"target_element"`
 	AriaLabel      string `                       json:"aria_label,omitempty"`
-	MaskedText     string `                       json:"masked_text"`
-	InputValue     string `                       json:"input_value,omitempty"`
-	PageURL        string `                       json:"page_url"`
+	MaskedText     string `gorm:"type:text"       json:"masked_text"`
+	InputValue     string `gorm:"type:text"       json:"input_value,omitempty"`
+	KeyCombo       string `gorm:"size:64"         json:"key_combo,omitempty"` // 键盘操作的按键组合（如 Enter、Ctrl+S）
+	PageURL        string `gorm:"type:text"       json:"page_url"`
 	PageTitle      string `                       json:"page_title"`
+	// 多标签页 / 弹出窗口 / iframe：浏览器标签页与窗口 ID，以及目标所在 iframe 的路径（顶层页面为空）
+	TabID          int    `                       json:"tab_id,omitempty"`
+	WindowID       int    `                       json:"window_id,omitempty"`
+	FramePath      string `gorm:"type:text"       json:"frame_path,omitempty"`
 	ScreenshotID   string `                       json:"screenshot_id,omitempty"`
-	AIDescription  string `                       json:"ai_description,omitempty"`
-	AINotes        string `                       json:"ai_notes,omitempty"`
+	AIDescription  string `gorm:"type:text"       json:"ai_description,omitempty"`
+	AITitle        string `gorm:"column:ai_title" json:"ai_title,omitempty"` // 4～8 字的步骤短标题，用于目录、流程图与截图说明
+	AINotes        string `gorm:"type:text"       json:"ai_notes,omitempty"`
 	IsEdited       bool   `gorm:"default:false"   json:"is_edited"`
 	IsMasked       bool   `gorm:"default:false"   json:"is_masked"`
+	Excluded       bool   `gorm:"default:false"   json:"excluded"` // 不写入业务视图（误点、调试步骤），技术视图与录制记录保留
 	DOMFingerprint string `gorm:"index"           json:"dom_fingerprint,omitempty"`
+	DuplicateOf    string `gorm:"index"           json:"duplicate_of,omitempty"` // 疑似重复提交时指向原步骤
+	// 选择器校验：在线上页面中找不到 TargetSelector / TargetXPath 对应的元素时标记为失效
+	SelectorStale     bool       `gorm:"default:false" json:"selector_stale"`
+	SelectorCheckedAt *time.Time `                     json:"selector_checked_at,omitempty"`
+	// 交互位置（视口 CSS 像素，与 element_rect 一致），用于裁剪截图交给 VLM
+	ClickX int `                       json:"click_x,omitempty"`
+	ClickY int `                       json:"click_y,omitempty"`
+	BBoxX  int `gorm:"column:bbox_x"   json:"bbox_x,omitempty"`
+	BBoxY  int `gorm:"column:bbox_y"   json:"bbox_y,omitempty"`
+	BBoxW  int `gorm:"column:bbox_w"   json:"bbox_w,omitempty"`
+	BBoxH  int `gorm:"column:bbox_h"   json:"bbox_h,omitempty"`
+	// 视口尺寸与滚动偏移（CSS 像素），用于提示“需滚动后可见”及回放时还原位置
+	ViewportW int `gorm:"column:viewport_w" json:"viewport_width,omitempty"`
+	ViewportH int `gorm:"column:viewport_h" json:"viewport_height,omitempty"`
+	ScrollX   int `                         json:"scroll_x,omitempty"`
+	ScrollY   int `                         json:"scroll_y,omitempty"`
+	// 描述的生成来源（提供商、模型、耗时、是否免费），便于发现降级到付费模型的调用
+	AIProvider  string `gorm:"column:ai_provider"   json:"ai_provider,omitempty"`
+	AIModel     string `gorm:"column:ai_model"      json:"ai_model,omitempty"`
+	AILatencyMS int64  `gorm:"column:ai_latency_ms" json:"ai_latency_ms,omitempty"`
+	AIUsedFree  bool   `gorm:"column:ai_used_free"  json:"ai_used_free"`
+	AITextOnly  bool   `gorm:"column:ai_text_only"  json:"ai_text_only"` // 生成时未发送截图，仅依据操作元数据
+	// 客户端生成的幂等键（Idempotency-Key 头或 client_step_id），会话内唯一，重试上报时据此返回原记录
+	IdempotencyKey *string `gorm:"size:64;uniqueIndex:idx_recording_steps_session_idempotency,priority:2" json:"client_step_id,omitempty"`
 }
 
 // ─────────────────────────────────────
@@ -81,14 +138,78 @@ type RecordingStep struct {
 // ─────────────────────────────────────
 type Screenshot struct {
 	Base
-	SessionID     string `gorm:"not null;index"  json:"session_id"`
-	StepID        string `gorm:"not null;index"  json:"step_id"`
-	CapturedAt    int64  `                       json:"captured_at"`
-	DataURL       string `gorm:"type:text"       json:"data_url"`
-	Width         int    `                       json:"width"`
-	Height        int    `                       json:"height"`
-	MaskedRegions string `gorm:"type:text"       json:"masked_regions,omitempty"`
-	IsRawDeleted  bool   `gorm:"default:false"   json:"is_raw_deleted"`
+	SessionID     string   `gorm:"not null;index"  json:"session_id"`
+	StepID        string   `gorm:"not null;index"  json:"step_id"`
+	CapturedAt    int64    `                       json:"captured_at"`
+	DataURL       LongText `                       json:"data_url"`
+	ElementURL    LongText `                       json:"element_url,omitempty"` // 按目标元素边界框裁剪（带红框）的局部图，业务视图优先使用
+	Width         int      `                       json:"width"`
+	Height        int      `                       json:"height"`
+	MaskedRegions string   `gorm:"type:text"       json:"masked_regions,omitempty"`
+	IsRawDeleted  bool     `gorm:"default:false"   json:"is_raw_deleted"`
+	// 从烧录遮蔽区域后的截图中识别出的文字，用于全文检索与补充 VLM 提示词
+	OCRText string `gorm:"column:ocr_text;type:text" json:"ocr_text,omitempty"`
+}
+
+// ─────────────────────────────────────
+// SessionMedia 会话附件（整段操作录像等），文件存放在存储目录 media/<sessionId>/ 下
+// ─────────────────────────────────────
+type SessionMedia struct {
+	Base
+	SessionID  string `gorm:"not null;index"  json:"session_id"`
+	Kind       string `gorm:"not null"        json:"kind"` // video
+	MimeType   string `gorm:"not null"        json:"mime_type"`
+	FileName   string `                       json:"file_name"`
+	Path       string `gorm:"not null"        json:"-"` // 相对存储目录的路径
+	Size       int64  `                       json:"size"`
+	SHA256     string `gorm:"column:sha256"   json:"sha256"`
+	DurationMS int64  `                       json:"duration_ms,omitempty"`
+}
+
+// ─────────────────────────────────────
+// StepRequest 步骤触发的网络请求（仅元数据：已脱敏的 URL、方法、状态码，不含请求头与正文）
+// ─────────────────────────────────────
+type StepRequest struct {
+	Base
+	StepID       string `gorm:"not null;index"  json:"step_id"`
+	SessionID    string `gorm:"not null;index"  json:"session_id"`
+	Seq          int    `                       json:"seq"` // 步骤内的请求顺序
+	Method       string `gorm:"not null"        json:"method"`
+	URL          string `gorm:"not null"        json:"url"`
+	Status       int    `                       json:"status"`
+	ResourceType string `                       json:"resource_type,omitempty"` // xhr / fetch / document
+	MimeType     string `                       json:"mime_type,omitempty"`
+	DurationMS   int64  `                       json:"duration_ms,omitempty"`
+}
+
+// ─────────────────────────────────────
+// StepLog 步骤执行期间目标系统的控制台输出（脚本错误、警告），消息由插件按脱敏规则处理后上报
+// ─────────────────────────────────────
+type StepLog struct {
+	Base
+	StepID    string `gorm:"not null;index"  json:"step_id"`
+	SessionID string `gorm:"not null;index"  json:"session_id"`
+	Seq       int    `                       json:"seq"`
+	Level     string `gorm:"not null"        json:"level"` // error / warn / info
+	Message   string `gorm:"type:text"       json:"message"`
+	Source    string `                       json:"source,omitempty"` // 脚本 URL（已脱敏）
+	Line      int    `                       json:"line,omitempty"`
+	Timestamp int64  `                       json:"timestamp,omitempty"`
+}
+
+// ─────────────────────────────────────
+// MaskingEvent 脱敏审计：某条规则在步骤的某个字段上命中的次数与文本类别（不保存原文）
+// ─────────────────────────────────────
+type MaskingEvent struct {
+	Base
+	StepID    string `gorm:"not null;index"  json:"step_id"`
+	SessionID string `gorm:"not null;index"  json:"session_id"`
+	RuleID    string `                       json:"rule_id,omitempty"` // 插件本地临时规则没有 ID
+	RuleType  string `gorm:"not null"        json:"rule_type"`
+	Alias     string `                       json:"alias"`
+	Category  string `                       json:"category"` // 被替换文本的类别（手机号、身份证号等）
+	Field     string `gorm:"not null"        json:"field"`
+	Count     int    `gorm:"not null"        json:"count"`
 }
 
 // ─────────────────────────────────────
@@ -100,16 +221,18 @@ type MaskingProfile struct {
 	Rules []MaskingRule `gorm:"foreignKey:ProfileID"    json:"rules,omitempty"`
 }
 
-// MaskingRule 脱敏规则
+// MaskingRule 脱敏规则；Scope 为 url 时仅在 URL 匹配 URLPattern 的页面生效
 type MaskingRule struct {
 	Base
-	ProfileID   string `gorm:"not null;index"  json:"profile_id"`
-	RuleType    string `gorm:"not null"        json:"rule_type"`
-	Pattern     string `gorm:"not null"        json:"pattern"`
-	Alias       string `gorm:"not null"        json:"alias"`
-	Scope       string `gorm:"default:'session'" json:"scope"`
-	IsActive    bool   `gorm:"default:true"    json:"is_active"`
-	Description string `                       json:"description,omitempty"`
+	ProfileID      string `gorm:"not null;index"  json:"profile_id"`
+	RuleType       string `gorm:"not null"        json:"rule_type"`
+	Pattern        string `gorm:"not null;type:text" json:"pattern"`
+	Alias          string `gorm:"not null"        json:"alias"`
+	Scope          string `gorm:"default:'session'" json:"scope"`
+	URLPattern     string `                       json:"url_pattern,omitempty"`
+	URLPatternType string `                       json:"url_pattern_type,omitempty"` // glob（默认，* 匹配任意字符）/ regex
+	IsActive       bool   `gorm:"default:true"    json:"is_active"`
+	Description    string `                       json:"description,omitempty"`
 }
 
 // ─────────────────────────────────────
@@ -117,11 +240,13 @@ type MaskingRule struct {
 // ─────────────────────────────────────
 type GeneratedDocument struct {
 	Base
-	SessionID     string `gorm:"not null;index"  json:"session_id"`
-	ProjectID     string `gorm:"not null;index"  json:"project_id"`
-	Status        string `gorm:"default:'draft'" json:"status"`
-	BusinessView  string `gorm:"type:text"       json:"business_view"`
-	TechnicalView string `gorm:"type:text"       json:"technical_view"`
+	SessionID     string     `gorm:"not null;index"  json:"session_id"`
+	ProjectID     string     `gorm:"not null;index"  json:"project_id"`
+	Status        string     `gorm:"default:'draft'" json:"status"` // draft | approved
+	ApprovedAt    *time.Time `                       json:"approved_at,omitempty"`
+	BusinessView  LongText   `                       json:"business_view"`
+	TechnicalView LongText   `                       json:"technical_view"`
+	Metadata      Metadata   `gorm:"type:text"       json:"metadata"` // 覆盖项目同名字段
 }
 
 // ─────────────────────────────────────
@@ -129,10 +254,219 @@ type GeneratedDocument struct {
 // ─────────────────────────────────────
 type LLMProvider struct {
 	Base
-	Name      string `gorm:"not null"        json:"name"`
-	APIKey    string `                       json:"-"` // 不输出密钥
+	Name      string `gorm:"not null"        json:"name"`  // 提供商类型：gemini | zhipu | ollama | openrouter | openai
+	Label     string `gorm:"default:''"      json:"label"` // 同类型多个配置（如两台 Ollama 主机）的区分名称，与 Name 组合唯一
+	APIKey    string `                       json:"-"`     // 不输出密钥
 	BaseURL   string `                       json:"base_url"`
 	Model     string `                       json:"model"`
+	Proxy     string `                       json:"-"` // 覆盖全局 llm.proxy，"direct" 表示直连；可能含代理凭据，不直接输出
 	IsDefault bool   `gorm:"default:false"   json:"is_default"`
 	IsActive  bool   `gorm:"default:true"    json:"is_active"`
+	TextOnly  bool   `gorm:"default:false"   json:"text_only"` // 模型不支持图片：调用时不发送截图
+
+	// 调用参数，0 / null 表示使用默认值
+	TimeoutSeconds int      `gorm:"default:0" json:"timeout_seconds"`
+	MaxRetries     int      `gorm:"default:0" json:"max_retries"`
+	Temperature    *float64 `                 json:"temperature"`
+	MaxTokens      int      `gorm:"default:0" json:"max_tokens"`
+
+	// 预算上限，0 表示不限；费用按 CostPerCall 估算（单位自定，如美元）
+	CostPerCall    float64 `gorm:"default:0" json:"cost_per_call"`
+	DailyCallCap   int     `gorm:"default:0" json:"daily_call_cap"`
+	MonthlyCallCap int     `gorm:"default:0" json:"monthly_call_cap"`
+	DailyCostCap   float64 `gorm:"default:0" json:"daily_cost_cap"`
+	MonthlyCostCap float64 `gorm:"default:0" json:"monthly_cost_cap"`
+}
+
+// ─────────────────────────────────────
+// ProviderCall 模型提供商的成功调用记录，用于预算上限统计
+// ─────────────────────────────────────
+type ProviderCall struct {
+	Base
+	ProviderID string  `gorm:"size:36;index:idx_provider_calls,priority:1" json:"provider_id"` // LLMProvider.ID
+	Provider   string  `gorm:"not null"                                    json:"provider"`
+	Label      string  `                                                   json:"label,omitempty"`
+	Model      string  `                                                   json:"model"`
+	Cost       float64 `                                                   json:"cost"`
+}
+
+// ─────────────────────────────────────
+// DocumentShare 已审批文档的对外分享链接（只读、限时，令牌只保存哈希）
+// ─────────────────────────────────────
+type DocumentShare struct {
+	Base
+	DocumentID   string     `gorm:"size:36;index;not null" json:"document_id"`
+	SessionID    string     `gorm:"size:36;index"          json:"session_id"`
+	TokenHash    string     `gorm:"size:64;uniqueIndex"    json:"-"`            // SHA-256(token) 十六进制
+	TokenPrefix  string     `gorm:"size:8"                 json:"token_prefix"` // 令牌前几位，便于在列表中辨认
+	Note         string     `                              json:"note,omitempty"`
+	ExpiresAt    time.Time  `gorm:"not null"               json:"expires_at"`
+	RevokedAt    *time.Time `                              json:"revoked_at,omitempty"`
+	AccessCount  int64      `gorm:"default:0"              json:"access_count"`
+	LastAccessAt *time.Time `                              json:"last_access_at,omitempty"`
+}
+
+// ─────────────────────────────────────
+// DocTemplate 自定义文档模板（内置模板定义在代码中，不入库）
+// ─────────────────────────────────────
+type DocTemplate struct {
+	Base
+	Key         string `gorm:"size:64;uniqueIndex;not null" json:"key"` // Project.TemplateType 引用的标识
+	Name        string `gorm:"not null"                     json:"name"`
+	Description string `gorm:"type:text"                    json:"description"`
+	View        string `                                    json:"view"`    // 默认导出视图：business | technical | both，空为 business
+	Preface     string `gorm:"type:text"                    json:"preface"` // 正文前插入的 Markdown
+	Closing     string `gorm:"type:text"                    json:"closing"` // 正文后插入的 Markdown
+	BuiltIn     bool   `gorm:"-"                            json:"built_in"`
+}
+
+// ─────────────────────────────────────
+// GlossaryTerm 项目术语表：AI 输出中的 Term 统一替换为 Preferred（如 操作员 → 经办人）
+// ─────────────────────────────────────
+type GlossaryTerm struct {
+	Base
+	ProjectID string `gorm:"size:64;not null;uniqueIndex:idx_glossary_project_term" json:"project_id"`
+	Term      string `gorm:"size:64;not null;uniqueIndex:idx_glossary_project_term" json:"term"`        // 不规范的说法
+	Preferred string `gorm:"size:64;not null"                                         json:"preferred"` // 规范用语
+}
+
+// ─────────────────────────────────────
+// OutputValidationFailure 模型给出的步骤描述未通过格式校验的记录，用于比较各提供商的输出质量
+// ─────────────────────────────────────
+type OutputValidationFailure struct {
+	Base
+	ProviderID string     `gorm:"size:36;index" json:"provider_id,omitempty"` // LLMProvider.ID，环境变量配置时为空
+	Provider   string     `gorm:"index;not null" json:"provider"`
+	Label      string     `                      json:"label,omitempty"`
+	Model      string     `                      json:"model"`
+	StepIndex  int        `                      json:"step_index"`
+	SessionID  string     `gorm:"size:36;index"  json:"session_id,omitempty"` // 所属会话与步骤，删除或清除会话时一并删除；纯文本生成任务为空
+	StepID     string     `gorm:"size:36;index"  json:"step_id,omitempty"`
+	Problems   StringList `gorm:"type:text"      json:"problems"`  // 未通过的校验项
+	Output     string     `gorm:"type:text"      json:"output"`    // 首次输出（已规范化）
+	Recovered  bool       `                      json:"recovered"` // 纠正重问后通过
+}
+
+// ─────────────────────────────────────
+// Setting 运行时可调整的服务端设置（键值，值为 JSON）
+// ─────────────────────────────────────
+type Setting struct {
+	Key       string    `gorm:"primaryKey;size:64" json:"key"`
+	Value     string    `gorm:"type:text"          json:"value"`
+	UpdatedAt time.Time `                          json:"updated_at"`
+}
+
+// ─────────────────────────────────────
+// ReplayRun 用无头浏览器按录制步骤回放会话的记录
+// ─────────────────────────────────────
+type ReplayRun struct {
+	Base
+	SessionID  string     `gorm:"size:36;index;not null" json:"session_id"`
+	Mode       string     `gorm:"not null;default:'replay'" json:"mode"`            // replay：执行步骤并截图 | verify：只校验选择器
+	Status     string     `gorm:"not null"               json:"status"`             // running | completed | failed
+	BaseURL    string     `                              json:"base_url,omitempty"` // 替换录制地址的协议与主机（如回放到测试环境）
+	Total      int        `                              json:"total"`
+	Passed     int        `                              json:"passed"`
+	Failed     int        `                              json:"failed"`
+	Skipped    int        `                              json:"skipped"`
+	Error      string     `gorm:"type:text"              json:"error,omitempty"` // 浏览器无法启动等整体失败原因
+	FinishedAt *time.Time `                              json:"finished_at,omitempty"`
+}
+
+// ─────────────────────────────────────
+// ReplayStep 回放中单个步骤的结果与新截图
+// ─────────────────────────────────────
+type ReplayStep struct {
+	Base
+	RunID      string   `gorm:"size:36;index;not null" json:"run_id"`
+	SessionID  string   `gorm:"size:36;index;not null" json:"session_id"`
+	StepID     string   `gorm:"size:36"                json:"step_id"`
+	StepIndex  int      `                              json:"step_index"`
+	Status     string   `gorm:"not null"               json:"status"` // passed | failed | skipped
+	Error      string   `gorm:"type:text"              json:"error,omitempty"`
+	DataURL    LongText `                              json:"data_url,omitempty"`
+	Width      int      `                              json:"width,omitempty"`
+	Height     int      `                              json:"height,omitempty"`
+	DurationMS int64    `                              json:"duration_ms"`
+}
+
+// ─────────────────────────────────────
+// ExportJob 后台导出任务：产物写入 <STORAGE_PATH>/exports/<id>/，完成后在过期前可下载
+// ─────────────────────────────────────
+type ExportJob struct {
+	Base
+	SourceType string     `gorm:"size:20;not null;index:idx_export_source" json:"source_type"`          // document | compiled
+	SourceID   string     `gorm:"size:36;not null;index:idx_export_source" json:"source_id"`            // 文档或合订手册 ID
+	SessionID  string     `gorm:"size:36;index"                            json:"session_id,omitempty"` // 单篇文档所属会话，删除或清除会话时一并删除
+	Format     string     `gorm:"size:20;not null"                         json:"format"`               // md | mdzip | json | chrome-recorder | bpmn
+	Options    string     `gorm:"type:text"                                json:"options,omitempty"`    // 导出参数（查询字符串，与同步导出相同）
+	Status     string     `gorm:"size:20;not null;index"                   json:"status"`               // running | completed | failed | expired
+	FileName   string     `                                                json:"file_name"`            // 下载文件名
+	Size       int64      `                                                json:"size"`
+	Error      string     `gorm:"type:text"                                json:"error,omitempty"`
+	FinishedAt *time.Time `                                                json:"finished_at,omitempty"`
+	ExpiresAt  *time.Time `gorm:"index"                                    json:"expires_at,omitempty"` // 产物过期时间，过期后不可下载并被清理
+}
+
+// ─────────────────────────────────────
+// CompiledDocument 项目合订手册：把项目中选定会话的文档按章节汇编为一份，共用封面、目录并连续编号
+// ─────────────────────────────────────
+type CompiledDocument struct {
+	Base
+	ProjectID   string            `gorm:"size:36;index;not null" json:"project_id"`
+	Title       string            `gorm:"not null"               json:"title"`
+	Description string            `gorm:"type:text"              json:"description,omitempty"` // 封面说明
+	Chapters    []CompiledChapter `gorm:"foreignKey:CompiledID"  json:"chapters,omitempty"`
+}
+
+// ─────────────────────────────────────
+// CompiledChapter 合订手册的一章，对应一个会话的最新文档
+// ─────────────────────────────────────
+type CompiledChapter struct {
+	Base
+	CompiledID string `gorm:"size:36;index;not null" json:"compiled_id"`
+	SessionID  string `gorm:"size:36;index;not null" json:"session_id"`
+	Position   int    `gorm:"not null"               json:"position"`        // 章节顺序，从 1 开始
+	Title      string `                              json:"title,omitempty"` // 章节标题，空时使用会话标题
+	GroupTitle string `                              json:"group,omitempty"` // 分组标题（如业务模块），相邻的同组章节归在一个分组标题下
+	Excluded   bool   `gorm:"default:false"          json:"excluded"`        // 保留在章节列表中但不汇编
+}
+
+// ─────────────────────────────────────
+// Embedding 语义检索的向量索引：每个步骤描述、每个会话的最新文档各一条；
+// 向量按 float32 小端序保存，检索时在内存中计算余弦相似度
+// ─────────────────────────────────────
+type Embedding struct {
+	Base
+	SourceType string `gorm:"size:16;not null;uniqueIndex:idx_embedding_source" json:"source_type"` // step | document
+	SourceID   string `gorm:"size:36;not null;uniqueIndex:idx_embedding_source" json:"source_id"`
+	SessionID  string `gorm:"size:36;index;not null"                            json:"session_id"`
+	Model      string `gorm:"not null"                                          json:"model"` // 生成向量的模型，更换模型后重建索引
+	TextHash   string `gorm:"size:64"                                           json:"-"`     // 被索引文本的 SHA-256，文本未变时不重新向量化
+	Text       string `gorm:"type:text"                                         json:"text"`
+	Vector     []byte `                                                         json:"-"`
+}
+
+// ─────────────────────────────────────
+// ChatConversation 针对单个会话的多轮问答，保存历史以便回答追问
+// ─────────────────────────────────────
+type ChatConversation struct {
+	Base
+	SessionID string        `gorm:"size:36;index;not null"    json:"session_id"`
+	Title     string        `                                 json:"title"` // 首个问题
+	Messages  []ChatMessage `gorm:"foreignKey:ConversationID" json:"messages,omitempty"`
+}
+
+// ─────────────────────────────────────
+// ChatMessage 对话中的一条消息（用户提问或模型回答）
+// ─────────────────────────────────────
+type ChatMessage struct {
+	Base
+	ConversationID string     `gorm:"size:36;index;not null" json:"conversation_id"`
+	SessionID      string     `gorm:"size:36;index;not null" json:"session_id"`
+	Role           string     `gorm:"size:16;not null"       json:"role"` // user | assistant
+	Content        string     `gorm:"type:text"              json:"content"`
+	StepIDs        StringList `gorm:"type:text"              json:"step_ids,omitempty"` // 回答引用的步骤
+	Provider       string     `                              json:"provider,omitempty"`
+	Model          string     `                              json:"model,omitempty"`
 }
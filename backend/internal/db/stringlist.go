@@ -0,0 +1,40 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// StringList 字符串列表，以 JSON 数组文本存储
+type StringList []string
+
+// Value 实现 driver.Valuer
+func (l StringList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "", nil
+	}
+	b, err := json.Marshal([]string(l))
+	return string(b), err
+}
+
+// Scan 实现 sql.Scanner，空值视为空列表
+func (l *StringList) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("unsupported string list type %T", value)
+	}
+	if len(raw) == 0 {
+		*l = nil
+		return nil
+	}
+	return json.Unmarshal(raw, (*[]string)(l))
+}
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -25,6 +27,7 @@ func MockConfigForTest() config.LLMConfig {
 		OllamaModel:       "qwen2.5-vl:7b",
 		OpenRouterBaseURL: "https://openrouter.ai/api/v1",
 		OpenAIBaseURL:     "https://api.openai.com/v1",
+		ContextWindow:     3,
 	}
 }
 
@@ -35,155 +38,547 @@ type VLMRequest struct {
 	PageURL       string
 	PageTitle     string
 	MaskedText    string
+	KeyCombo      string // 键盘操作的按键组合（如 Ctrl+S）
 	ScreenshotB64 string // base64 PNG，已脱敏
+	ScreenText    string // 截图上识别出的文字（OCR），作为辅助信息写入提示词
+	// 截图已裁剪到操作目标附近并用红框标出目标元素
+	TargetHighlighted bool
+	StepIndex         int      // 当前步骤序号，0 表示未知
+	PreviousSteps     []string // 前序步骤的描述（由远到近），用于保持叙述连贯
+	Prompt            string   // 非空时直接作为提示词（纯文本生成任务），忽略上面的步骤字段
+	Avoid             []string // 上次输出命中的禁用词，重试时要求模型避开
+	Correction        []string // 上次输出未通过的格式校验项，重问时要求模型纠正
+	SessionID         string   // 所属会话与步骤，用于关联格式校验失败记录；纯文本生成任务为空
+	StepID            string
 }
 
 // VLMResponse 统一的 VLM 响应
 type VLMResponse struct {
 	Description string
+	Title       string // 步骤短标题（仅步骤描述，模型未给出时为空）
 	Provider    string
+	Label       string // 同类型多个配置时实际使用的配置名称
+	Model       string
 	UsedFree    bool
+	LatencyMS   int64
+	BannedHits  []string // 重试后仍命中的禁用词，需人工修改
+	Invalid     []string // 纠正重问后仍未通过的格式校验项（仅指定提供商时可能非空）
+	TextOnly    bool     // 未发送截图，描述仅依据操作元数据
 }
 
 // AIService AI 调度服务（免费优先路由）
 type AIService struct {
-	cfg    *config.LLMConfig // 环境变量默认配置（就算 DB 没有记录也能工作）
-	client *http.Client
+	cfg      *config.LLMConfig // 环境变量默认配置（就算 DB 没有记录也能工作）
+	client   *http.Client
+	freeOnly bool              // 只使用免费提供商，见 FreeOnly
+	glossary []db.GlossaryTerm // 项目术语表，见 WithGlossary
+	banned   []string          // 禁用词，见 WithBannedPhrases
 }
 
 func NewAIService(cfg *config.LLMConfig) *AIService {
 	return &AIService{
 		cfg:    cfg,
-		client: &http.Client{Timeout: 30 * time.Second},
+		client: &http.Client{},
 	}
 }
 
-// effectiveCfg 每次调用时从 DB 动态加载，当前 DB 配置优先于环境变量
+// httpClient 返回调用指定提供商的 HTTP 客户端：使用该提供商的超时（未配置时为运行时设置）和出站代理
+func (s *AIService) httpClient(cfg *config.LLMConfig, provider string) *http.Client {
+	c := *s.client
+	c.Timeout = providerTuning(cfg, provider).Timeout
+	c.Transport = transportFor(cfg, provider)
+	return &c
+}
+
+// 未配置调用参数时的默认值
+const (
+	defaultTemperature = 0.2
+	defaultMaxTokens   = 256
+)
+
+// providerTuning 提供商的调用参数，未配置的项填入默认值
+func providerTuning(cfg *config.LLMConfig, provider string) config.ProviderTuning {
+	t := cfg.ProviderTuning[provider]
+	if t.Timeout <= 0 {
+		t.Timeout = CurrentSettings().AITimeout()
+	}
+	if t.Temperature == nil {
+		v := defaultTemperature
+		t.Temperature = &v
+	}
+	if t.MaxTokens <= 0 {
+		t.MaxTokens = defaultMaxTokens
+	}
+	return t
+}
+
+// effectiveCfg 每次调用时从 DB 动态加载，当前 DB 配置优先于环境变量；
+// 同类型有多个配置时取主配置（默认配置优先，其次最早创建）
 func (s *AIService) effectiveCfg() *config.LLMConfig {
 	// 拷贝环境变量默认配置
-	cfg := *s.cfg
-
-	// 从 DB 对应到配置字段的映射
-	apply := func(name string, setFn func(p db.LLMProvider)) {
-		var p db.LLMProvider
-		if err := db.DB.Where("name = ? AND is_active = ?", name, true).First(&p).Error; err == nil {
-			setFn(p)
+	cfg := cloneCfg(s.cfg)
+	seen := map[string]bool{}
+	for _, p := range activeProviders() {
+		if !seen[p.Name] {
+			seen[p.Name] = true
+			applyProvider(cfg, p)
 		}
 	}
+	stripCloudKeys(cfg)
+	return cfg
+}
 
-	apply("gemini", func(p db.LLMProvider) {
-		if p.APIKey != "" {
-			cfg.GeminiAPIKey = p.APIKey
-		}
-		if p.BaseURL != "" {
-			cfg.GeminiBaseURL = p.BaseURL
-		}
-		if p.Model != "" {
-			cfg.GeminiModel = p.Model
-		}
-	})
-	apply("zhipu", func(p db.LLMProvider) {
-		if p.APIKey != "" {
-			cfg.ZhipuAPIKey = p.APIKey
-		}
-		if p.BaseURL != "" {
-			cfg.ZhipuBaseURL = p.BaseURL
-		}
-		if p.Model != "" {
-			cfg.ZhipuModel = p.Model
-		}
-	})
-	apply("ollama", func(p db.LLMProvider) {
-		if p.BaseURL != "" {
-			cfg.OllamaBaseURL = p.BaseURL
-		}
-		if p.Model != "" {
-			cfg.OllamaModel = p.Model
-		}
-	})
-	apply("openrouter", func(p db.LLMProvider) {
-		if p.APIKey != "" {
-			cfg.OpenRouterAPIKey = p.APIKey
-		}
-		if p.BaseURL != "" {
-			cfg.OpenRouterBaseURL = p.BaseURL
-		}
-		if p.Model != "" {
-			cfg.OpenRouterModel = p.Model
-		}
-	})
-	apply("openai", func(p db.LLMProvider) {
-		if p.APIKey != "" {
-			cfg.OpenAIAPIKey = p.APIKey
-		}
-		if p.BaseURL != "" {
-			cfg.OpenAIBaseURL = p.BaseURL
-		}
-		if p.Model != "" {
-			cfg.OpenAIModel = p.Model
-		}
-	})
+// providerOrder 同类型多个配置的优先顺序
+const providerOrder = "is_default DESC, created_at, label"
+
+// activeProviders 全部启用的 DB 配置，按 providerOrder 排序
+func activeProviders() []db.LLMProvider {
+	var rows []db.LLMProvider
+	db.DB.Where("is_active = ?", true).Order(providerOrder).Find(&rows)
+	return rows
+}
 
+// cloneCfg 拷贝配置（含按提供商的代理与调用参数表）
+func cloneCfg(src *config.LLMConfig) *config.LLMConfig {
+	cfg := *src
+	cfg.ProviderProxies = map[string]string{}
+	for k, v := range src.ProviderProxies {
+		cfg.ProviderProxies[k] = v
+	}
+	cfg.ProviderTuning = map[string]config.ProviderTuning{}
+	for k, v := range src.ProviderTuning {
+		cfg.ProviderTuning[k] = v
+	}
 	return &cfg
 }
 
-// GenerateStepDescription 为操作步骤生成自然语言描述（免费优先）
-func (s *AIService) GenerateStepDescription(req VLMRequest) (*VLMResponse, error) {
-	// 每次调用时动态加载最新 DB 配置，实现“保存即生效”
-	eff := s.effectiveCfg()
+// providerFields 提供商类型在配置中对应的密钥、地址和模型字段（Ollama 没有密钥，未知类型全部为 nil）
+func providerFields(cfg *config.LLMConfig, name string) (apiKey, baseURL, model *string) {
+	switch name {
+	case "gemini":
+		return &cfg.GeminiAPIKey, &cfg.GeminiBaseURL, &cfg.GeminiModel
+	case "zhipu":
+		return &cfg.ZhipuAPIKey, &cfg.ZhipuBaseURL, &cfg.ZhipuModel
+	case "ollama":
+		return nil, &cfg.OllamaBaseURL, &cfg.OllamaModel
+	case "openrouter":
+		return &cfg.OpenRouterAPIKey, &cfg.OpenRouterBaseURL, &cfg.OpenRouterModel
+	case "openai":
+		return &cfg.OpenAIAPIKey, &cfg.OpenAIBaseURL, &cfg.OpenAIModel
+	}
+	return nil, nil, nil
+}
+
+// applyProvider 将一条 DB 配置叠加到 cfg：非空字段覆盖环境变量，代理与调用参数以该行为准
+func applyProvider(cfg *config.LLMConfig, p db.LLMProvider) {
+	apiKey, baseURL, model := providerFields(cfg, p.Name)
+	if baseURL == nil {
+		return
+	}
+	if apiKey != nil && p.APIKey != "" {
+		*apiKey = p.APIKey
+	}
+	if p.BaseURL != "" {
+		*baseURL = p.BaseURL
+	}
+	if p.Model != "" {
+		*model = p.Model
+	}
+	delete(cfg.ProviderProxies, p.Name)
+	if p.Proxy != "" {
+		cfg.ProviderProxies[p.Name] = p.Proxy
+	}
+	cfg.ProviderTuning[p.Name] = config.ProviderTuning{
+		Timeout:     time.Duration(p.TimeoutSeconds) * time.Second,
+		MaxRetries:  p.MaxRetries,
+		Temperature: p.Temperature,
+		MaxTokens:   p.MaxTokens,
+	}
+}
+
+// stripCloudKeys 离线模式下忽略环境变量和历史保存的云端 API Key
+func stripCloudKeys(cfg *config.LLMConfig) {
+	if cfg.LocalOnly {
+		cfg.GeminiAPIKey, cfg.ZhipuAPIKey, cfg.OpenRouterAPIKey, cfg.OpenAIAPIKey = "", "", "", ""
+	}
+}
+
+// localProviders 离线模式下仍可使用的提供商（不访问外部网络）
+var localProviders = map[string]bool{"ollama": true}
+
+// FreeOnly 返回只使用免费提供商的服务副本：路由链跳过付费提供商，全部失败时仍回退到规则描述
+func (s *AIService) FreeOnly() *AIService {
+	c := *s
+	c.freeOnly = true
+	return &c
+}
+
+// ErrPaidProviderBlocked 仅免费模式下指定了付费提供商
+var ErrPaidProviderBlocked = fmt.Errorf("paid providers are disabled in free-only mode")
+
+// ErrProviderDisabled 离线模式下请求了外部提供商
+var ErrProviderDisabled = fmt.Errorf("external providers are disabled in local-only mode")
+
+// LocalOnly 是否处于离线模式（llm.local_only）
+func (s *AIService) LocalOnly() bool {
+	return s.cfg.LocalOnly
+}
+
+// IsExternalProvider 提供商是否需要访问外部网络
+func IsExternalProvider(name string) bool {
+	return !localProviders[name]
+}
+
+// providerEntry 路由链中的一个提供商配置
+type providerEntry struct {
+	name    string
+	label   string // 同类型多个配置时的区分名称
+	fn      func(VLMRequest, *config.LLMConfig) (string, error)
+	isFree  bool
+	enabled bool
+	model   string
+	cfg     *config.LLMConfig // 该配置生效的完整参数
+	row     *db.LLMProvider   // 对应的 DB 配置，仅使用环境变量时为 nil
+}
 
-	// 免费优先路由链
-	chain := []struct {
-		name    string
-		fn      func(VLMRequest, *config.LLMConfig) (string, error)
-		isFree  bool
-		enabled bool
+// providerChain 免费优先路由链。同类型有多个启用的配置时依次展开（默认配置优先，其次按创建时间），
+// 前一个失败时尝试同类型的下一个，再降级到下一种类型
+func (s *AIService) providerChain(eff *config.LLMConfig) []providerEntry {
+	byName := map[string][]db.LLMProvider{}
+	for _, p := range activeProviders() {
+		byName[p.Name] = append(byName[p.Name], p)
+	}
+	var chain []providerEntry
+	for _, t := range []struct {
+		name   string
+		fn     func(VLMRequest, *config.LLMConfig) (string, error)
+		isFree bool
 	}{
-		{"ollama", s.callOllama, true, s.isOllamaAvailableWithCfg(eff)},
-		{"zhipu", s.callZhipu, true, eff.ZhipuAPIKey != ""},
-		{"gemini", s.callGemini, true, eff.GeminiAPIKey != ""},
-		{"openrouter", s.callOpenRouter, true, eff.OpenRouterAPIKey != ""},
-		{"openai", s.callOpenAI, false, eff.OpenAIAPIKey != ""},
+		{"ollama", s.callOllama, true},
+		{"zhipu", s.callZhipu, true},
+		{"gemini", s.callGemini, true},
+		{"openrouter", s.callOpenRouter, true},
+		{"openai", s.callOpenAI, false},
+	} {
+		rows := byName[t.name]
+		if len(rows) <= 1 {
+			var row *db.LLMProvider
+			if len(rows) == 1 {
+				row = &rows[0]
+			}
+			chain = append(chain, s.newEntry(t.name, row, t.fn, t.isFree, eff))
+			continue
+		}
+		for i := range rows {
+			cfg := cloneCfg(s.cfg)
+			applyProvider(cfg, rows[i])
+			stripCloudKeys(cfg)
+			chain = append(chain, s.newEntry(t.name, &rows[i], t.fn, t.isFree, cfg))
+		}
 	}
+	return chain
+}
 
-	for _, provider := range chain {
-		if !provider.enabled {
+func (s *AIService) newEntry(name string, row *db.LLMProvider, fn func(VLMRequest, *config.LLMConfig) (string, error), isFree bool, cfg *config.LLMConfig) providerEntry {
+	apiKey, _, model := providerFields(cfg, name)
+	enabled := apiKey != nil && *apiKey != ""
+	if name == "ollama" {
+		enabled = s.isOllamaAvailableWithCfg(cfg)
+	}
+	e := providerEntry{name: name, fn: fn, isFree: isFree, enabled: enabled, model: *model, cfg: cfg, row: row}
+	if row != nil {
+		e.label = row.Label
+	}
+	return e
+}
+
+// quotaReached 该配置是否已达预算上限
+func (p providerEntry) quotaReached() (bool, string) {
+	if p.row == nil {
+		return false, ""
+	}
+	return QuotaExceeded(*p.row, time.Now())
+}
+
+// prepare 纯文本模式（全局设置 text_only_generation，或该配置的模型不支持图片）下去掉截图，提示词只依据操作元数据
+func (p providerEntry) prepare(req VLMRequest) VLMRequest {
+	if CurrentSettings().TextOnlyGeneration || (p.row != nil && p.row.TextOnly) {
+		req.ScreenshotB64, req.TargetHighlighted = "", false
+	}
+	return req
+}
+
+// runChain 依次尝试可用的提供商，全部失败时返回 ErrNoProvider
+func (s *AIService) runChain(req VLMRequest) (*VLMResponse, error) {
+	// 每次调用时动态加载最新 DB 配置，实现“保存即生效”
+	eff := s.effectiveCfg()
+
+	for _, provider := range s.providerChain(eff) {
+		if !provider.enabled || (s.freeOnly && !provider.isFree) {
 			continue
 		}
-		desc, err := provider.fn(req, eff)
-		if err != nil {
+		// 已达预算上限的配置直接跳过
+		if reached, _ := provider.quotaReached(); reached {
+			continue
+		}
+		preq := provider.prepare(req)
+		start := time.Now()
+		desc, title, problems, err := s.callValidated(provider, preq)
+		latency := time.Since(start)
+		if err != nil || desc == "" || len(problems) > 0 {
 			// 降级到下一个
 			continue
 		}
 		return &VLMResponse{
 			Description: desc,
+			Title:       title,
 			Provider:    provider.name,
+			Label:       provider.label,
+			Model:       provider.model,
 			UsedFree:    provider.isFree,
+			LatencyMS:   latency.Milliseconds(),
+			TextOnly:    preq.ScreenshotB64 == "",
 		}, nil
 	}
+	return nil, ErrNoProvider
+}
+
+// retryBackoff 首次重试前的等待时间，之后每次翻倍
+var retryBackoff = 500 * time.Millisecond
+
+// callWithRetry 调用提供商，网络错误、429 与 5xx 按该提供商的 MaxRetries 重试；
+// 每次尝试单独占用并发名额，退避等待期间不占用
+func (s *AIService) callWithRetry(p providerEntry, req VLMRequest) (string, error) {
+	retries := p.cfg.ProviderTuning[p.name].MaxRetries
+	for attempt := 0; ; attempt++ {
+		release := acquireAISlot()
+		desc, err := p.fn(req, p.cfg)
+		release()
+		if err == nil && desc != "" {
+			recordProviderCall(p)
+		}
+		if err == nil || attempt >= retries || !retryable(err) {
+			return desc, err
+		}
+		time.Sleep(retryBackoff << attempt)
+	}
+}
+
+// statusError 提供商返回了非 200 状态码
+type statusError struct {
+	provider string
+	code     int
+	body     string
+}
+
+func (e *statusError) Error() string {
+	if e.body != "" {
+		return fmt.Sprintf("%s status %d: %s", e.provider, e.code, e.body)
+	}
+	return fmt.Sprintf("%s status %d", e.provider, e.code)
+}
+
+// retryable 是否值得重试：网络错误（含超时）、限流与服务端错误
+func retryable(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.code == http.StatusTooManyRequests || se.code >= 500
+	}
+	var ue *url.Error
+	return errors.As(err, &ue)
+}
+
+// ErrNoProvider 没有可用的模型提供商（或全部调用失败）
+var ErrNoProvider = fmt.Errorf("no VLM provider available")
+
+// GenerateStepDescription 为操作步骤生成自然语言描述（免费优先）
+func (s *AIService) GenerateStepDescription(req VLMRequest) (*VLMResponse, error) {
+	if resp, err := s.runChain(req); err == nil {
+		return s.checkBanned(req, resp, s.runChain), nil
+	}
 
 	// 所有 VLM 失败时，使用规则生成纯文本描述
+	desc := s.postProcess(req, s.ruleBasedDescription(req))
 	return &VLMResponse{
-		Description: s.ruleBasedDescription(req),
+		Description: desc,
 		Provider:    "rule-based",
 		UsedFree:    true,
+		BannedHits:  FindBannedPhrases(desc, s.banned),
 	}, nil
 }
 
+// ErrUnknownProvider 指定的提供商名称不存在
+var ErrUnknownProvider = fmt.Errorf("unknown provider")
+
+// GenerateWithProvider 跳过免费优先路由链，直接使用指定的提供商（可覆盖模型）生成描述；
+// provider 可写作 "类型:label" 指定同类型中的某个配置，省略 label 时使用主配置。
+// 失败时不降级，直接返回错误，便于用户用更强的模型重试
+func (s *AIService) GenerateWithProvider(req VLMRequest, provider, model string) (*VLMResponse, error) {
+	resp, err := s.generateWithProvider(req, provider, model)
+	if err != nil {
+		return nil, err
+	}
+	return s.checkBanned(req, resp, func(r VLMRequest) (*VLMResponse, error) {
+		return s.generateWithProvider(r, provider, model)
+	}), nil
+}
+
+func (s *AIService) generateWithProvider(req VLMRequest, provider, model string) (*VLMResponse, error) {
+	name, label, byLabel := strings.Cut(provider, ":")
+	for _, p := range s.providerChain(s.effectiveCfg()) {
+		if p.name != name || (byLabel && p.label != label) {
+			continue
+		}
+		if s.LocalOnly() && IsExternalProvider(p.name) {
+			return nil, fmt.Errorf("%w: %s", ErrProviderDisabled, provider)
+		}
+		if s.freeOnly && !p.isFree {
+			return nil, fmt.Errorf("%w: %s", ErrPaidProviderBlocked, provider)
+		}
+		if !p.enabled {
+			return nil, fmt.Errorf("provider %s is not configured", provider)
+		}
+		if reached, reason := p.quotaReached(); reached {
+			return nil, fmt.Errorf("%w: %s: %s", ErrQuotaReached, provider, reason)
+		}
+		if model != "" {
+			p.cfg = cloneCfg(p.cfg)
+			_, _, m := providerFields(p.cfg, p.name)
+			*m, p.model = model, model
+		}
+		req = p.prepare(req)
+		start := time.Now()
+		desc, title, problems, err := s.callValidated(p, req)
+		latency := time.Since(start)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", provider, err)
+		}
+		if desc == "" {
+			return nil, fmt.Errorf("%s: empty response", provider)
+		}
+		return &VLMResponse{
+			Description: desc,
+			Title:       title,
+			Provider:    p.name,
+			Label:       p.label,
+			Model:       p.model,
+			UsedFree:    p.isFree,
+			LatencyMS:   latency.Milliseconds(),
+			Invalid:     problems,
+			TextOnly:    req.ScreenshotB64 == "",
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+}
+
+// callValidated 调用提供商并做输出后处理（步骤描述先拆出短标题）；步骤描述未通过格式校验时附带纠正说明重问同一提供商一次，
+// 仍未通过则返回首次输出及未通过的校验项，由调用方决定是否降级
+func (s *AIService) callValidated(p providerEntry, req VLMRequest) (string, string, []string, error) {
+	raw, err := s.callWithRetry(p, req)
+	if err != nil || raw == "" {
+		return "", "", nil, err
+	}
+	desc, title := s.stepOutput(req, raw)
+	problems := ValidateDescription(desc, req)
+	if len(problems) == 0 {
+		return desc, title, nil, nil
+	}
+	retry := req
+	retry.Correction = problems
+	if raw, err := s.callWithRetry(p, retry); err == nil && raw != "" {
+		if fixed, fixedTitle := s.stepOutput(req, raw); len(ValidateDescription(fixed, req)) == 0 {
+			recordValidationFailure(p, req, desc, problems, true)
+			return fixed, fixedTitle, nil, nil
+		}
+	}
+	recordValidationFailure(p, req, desc, problems, false)
+	return desc, title, problems, nil
+}
+
+// stepOutput 步骤描述的输出拆出短标题行后做后处理；自定义提示词（标题、摘要等）原样后处理
+func (s *AIService) stepOutput(req VLMRequest, raw string) (desc, title string) {
+	if req.Prompt == "" {
+		raw, title = splitStepTitle(raw)
+		title = applyGlossary(title, s.glossary)
+	}
+	return s.postProcess(req, raw), title
+}
+
+// postProcess 输出后处理：规范化（见 NormalizeOutput）后套用项目术语表
+func (s *AIService) postProcess(req VLMRequest, desc string) string {
+	return applyGlossary(NormalizeOutput(desc, req), s.glossary)
+}
+
+// GenerateText 纯文本生成（标题、摘要等），没有可用提供商时返回 ErrNoProvider，由调用方兜底
+func (s *AIService) GenerateText(prompt string) (*VLMResponse, error) {
+	req := VLMRequest{Prompt: prompt}
+	resp, err := s.runChain(req)
+	if err != nil {
+		return nil, err
+	}
+	return s.checkBanned(req, resp, s.runChain), nil
+}
+
 // ─────────────────────────────────────────────────────────────
 // Prompt 构建（仅含脱敏后的影子数据）
 // ─────────────────────────────────────────────────────────────
+
+// assistantPreamble 生成类提示词开头的角色设定与全局说明（运行时设置，无需重新编译即可切换行业）
+func assistantPreamble() string {
+	settings := CurrentSettings()
+	return preamble(settings.AssistantPersona, settings.SystemInstructions)
+}
+
+// reviewerPreamble 审阅提示词开头的角色设定与全局说明
+func reviewerPreamble() string {
+	settings := CurrentSettings()
+	return preamble(settings.ReviewerPersona, settings.SystemInstructions)
+}
+
+func preamble(persona, instructions string) string {
+	if instructions = strings.TrimSpace(instructions); instructions != "" {
+		return fmt.Sprintf("你是%s。\n%s\n", persona, instructions)
+	}
+	return fmt.Sprintf("你是%s。", persona)
+}
 func (s *AIService) buildPrompt(req VLMRequest) string {
-	return fmt.Sprintf(`你是政务软件操作手册编写助手。根据以下截图和操作信息，用一句简洁的中文描述当前步骤。
+	if req.Prompt != "" {
+		if extra := glossaryHint(s.glossary) + avoidHint(req.Avoid); extra != "" {
+			return req.Prompt + "\n" + extra
+		}
+		return req.Prompt
+	}
+	hint := glossaryHint(s.glossary) + avoidHint(req.Avoid) + correctionHint(req.Correction) + screenTextHint(req.ScreenText)
+	if req.TargetHighlighted {
+		hint += "截图已裁剪到操作位置附近，红框标出的是本步骤操作的目标元素，请只描述红框内的控件。\n"
+	}
+	if len(req.PreviousSteps) > 0 {
+		hint += "前面几步的描述如下，请保持编号、用语和指代与之连贯，不要重复前面的内容：\n"
+		for _, d := range req.PreviousSteps {
+			hint += "- " + d + "\n"
+		}
+	}
+	if req.StepIndex > 0 {
+		hint += fmt.Sprintf("当前是第%d步。\n", req.StepIndex)
+	}
+	if req.KeyCombo != "" {
+		hint += fmt.Sprintf("本步骤是键盘操作（%s），请写明按下的按键。\n", KeyPhrase(req.StepAction, req.KeyCombo))
+	}
+	source := "截图和操作信息"
+	if req.ScreenshotB64 == "" {
+		source = "操作信息"
+	}
+	return fmt.Sprintf(`%s根据以下%s，用一句简洁的中文描述当前步骤。
 格式：第N步：[动作] [目标]，[预期效果]（不要重复格式字样本身）
-
+另起一行给出 4～8 个字的步骤短标题，格式：标题：[短标题]（如“标题：提交申请”）
+%s
 操作信息：
 - 操作类型：%s
 - 目标元素：%s
 - 页面标题：%s
 - 相关文本：%s
 
-请直接输出描述内容，不要解释，不要重复格式说明。`, req.StepAction, req.TargetElement, req.PageTitle, req.MaskedText)
+请直接输出描述内容，不要解释，不要重复格式说明。`, assistantPreamble(), source, hint, req.StepAction, req.TargetElement, req.PageTitle, req.MaskedText)
 }
 
 // ─────────────────────────────────────────────────────────────
@@ -210,6 +605,7 @@ func (s *AIService) callGemini(req VLMRequest, cfg *config.LLMConfig) (string, e
 		GenerationConfig GenConfig `json:"generationConfig"`
 	}
 
+	tuning := providerTuning(cfg, "gemini")
 	parts := []Part{{Text: s.buildPrompt(req)}}
 	if req.ScreenshotB64 != "" {
 		imgData := req.ScreenshotB64
@@ -221,25 +617,31 @@ func (s *AIService) callGemini(req VLMRequest, cfg *config.LLMConfig) (string, e
 
 	body := GeminiReq{
 		Contents:         []Content{{Parts: parts}},
-		GenerationConfig: GenConfig{MaxOutputTokens: 256, Temperature: 0.2},
+		GenerationConfig: GenConfig{MaxOutputTokens: tuning.MaxTokens, Temperature: *tuning.Temperature},
 	}
 
-	url := fmt.Sprintf("%s/models/%s:generateContent?key=%s",
-		cfg.GeminiBaseURL, cfg.GeminiModel, cfg.GeminiAPIKey)
+	url := fmt.Sprintf("%s/models/%s:generateContent", cfg.GeminiBaseURL, cfg.GeminiModel)
 
-	return s.doGeminiRequest(url, body)
+	return s.doGeminiRequest(s.httpClient(cfg, "gemini"), url, cfg.GeminiAPIKey, body)
 }
 
-func (s *AIService) doGeminiRequest(url string, body interface{}) (string, error) {
+// doGeminiRequest 调用 Gemini 接口；密钥放在请求头中，避免出现在网络错误信息的 URL 里
+func (s *AIService) doGeminiRequest(client *http.Client, url, apiKey string, body interface{}) (string, error) {
 	data, _ := json.Marshal(body)
-	resp, err := s.client.Post(url, "application/json", bytes.NewReader(data))
+	httpReq, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", apiKey)
+	resp, err := client.Do(httpReq)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("gemini status %d", resp.StatusCode)
+		return "", &statusError{provider: "gemini", code: resp.StatusCode}
 	}
 
 	var result struct {
@@ -265,6 +667,7 @@ func (s *AIService) doGeminiRequest(url string, body interface{}) (string, error
 // ─────────────────────────────────────────────────────────────
 func (s *AIService) callZhipu(req VLMRequest, cfg *config.LLMConfig) (string, error) {
 	return s.callOpenAICompatible(
+		"zhipu", cfg,
 		cfg.ZhipuBaseURL+"/chat/completions",
 		cfg.ZhipuModel,
 		cfg.ZhipuAPIKey,
@@ -277,6 +680,7 @@ func (s *AIService) callZhipu(req VLMRequest, cfg *config.LLMConfig) (string, er
 // ─────────────────────────────────────────────────────────────
 func (s *AIService) callOpenRouter(req VLMRequest, cfg *config.LLMConfig) (string, error) {
 	return s.callOpenAICompatible(
+		"openrouter", cfg,
 		cfg.OpenRouterBaseURL+"/chat/completions",
 		cfg.OpenRouterModel,
 		cfg.OpenRouterAPIKey,
@@ -289,6 +693,7 @@ func (s *AIService) callOpenRouter(req VLMRequest, cfg *config.LLMConfig) (strin
 // ─────────────────────────────────────────────────────────────
 func (s *AIService) callOpenAI(req VLMRequest, cfg *config.LLMConfig) (string, error) {
 	return s.callOpenAICompatible(
+		"openai", cfg,
 		cfg.OpenAIBaseURL+"/chat/completions",
 		cfg.OpenAIModel,
 		cfg.OpenAIAPIKey,
@@ -297,7 +702,7 @@ func (s *AIService) callOpenAI(req VLMRequest, cfg *config.LLMConfig) (string, e
 }
 
 // callOpenAICompatible 通用 OpenAI-compatible 接口调用
-func (s *AIService) callOpenAICompatible(url, model, apiKey string, req VLMRequest) (string, error) {
+func (s *AIService) callOpenAICompatible(provider string, cfg *config.LLMConfig, url, model, apiKey string, req VLMRequest) (string, error) {
 	type ImageURL struct {
 		URL    string `json:"url"`
 		Detail string `json:"detail,omitempty"`
@@ -312,11 +717,13 @@ func (s *AIService) callOpenAICompatible(url, model, apiKey string, req VLMReque
 		Content []ContentPart `json:"content"`
 	}
 	type OpenAIReq struct {
-		Model     string    `json:"model"`
-		Messages  []Message `json:"messages"`
-		MaxTokens int       `json:"max_tokens"`
+		Model       string    `json:"model"`
+		Messages    []Message `json:"messages"`
+		MaxTokens   int       `json:"max_tokens"`
+		Temperature float64   `json:"temperature"`
 	}
 
+	tuning := providerTuning(cfg, provider)
 	userParts := []ContentPart{{Type: "text", Text: s.buildPrompt(req)}}
 	if req.ScreenshotB64 != "" {
 		userParts = append(userParts, ContentPart{
@@ -333,7 +740,8 @@ func (s *AIService) callOpenAICompatible(url, model, apiKey string, req VLMReque
 				Content: userParts,
 			},
 		},
-		MaxTokens: 256,
+		MaxTokens:   tuning.MaxTokens,
+		Temperature: *tuning.Temperature,
 	}
 
 	data, _ := json.Marshal(body)
@@ -344,7 +752,7 @@ func (s *AIService) callOpenAICompatible(url, model, apiKey string, req VLMReque
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
 
-	resp, err := s.client.Do(httpReq)
+	resp, err := s.httpClient(cfg, provider).Do(httpReq)
 	if err != nil {
 		return "", err
 	}
@@ -352,7 +760,7 @@ func (s *AIService) callOpenAICompatible(url, model, apiKey string, req VLMReque
 
 	if resp.StatusCode != 200 {
 		b, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("api status %d: %s", resp.StatusCode, string(b))
+		return "", &statusError{provider: provider, code: resp.StatusCode, body: string(b)}
 	}
 
 	var result struct {
@@ -376,10 +784,11 @@ func (s *AIService) callOpenAICompatible(url, model, apiKey string, req VLMReque
 // ─────────────────────────────────────────────────────────────
 func (s *AIService) callOllama(req VLMRequest, cfg *config.LLMConfig) (string, error) {
 	type OllamaReq struct {
-		Model  string   `json:"model"`
-		Prompt string   `json:"prompt"`
-		Images []string `json:"images,omitempty"`
-		Stream bool     `json:"stream"`
+		Model   string                 `json:"model"`
+		Prompt  string                 `json:"prompt"`
+		Images  []string               `json:"images,omitempty"`
+		Stream  bool                   `json:"stream"`
+		Options map[string]interface{} `json:"options,omitempty"`
 	}
 
 	body := OllamaReq{
@@ -387,6 +796,16 @@ func (s *AIService) callOllama(req VLMRequest, cfg *config.LLMConfig) (string, e
 		Prompt: s.buildPrompt(req),
 		Stream: false,
 	}
+	// Ollama 只传显式配置的参数，其余沿用模型自身的默认值
+	if t := cfg.ProviderTuning["ollama"]; t.Temperature != nil || t.MaxTokens > 0 {
+		body.Options = map[string]interface{}{}
+		if t.Temperature != nil {
+			body.Options["temperature"] = *t.Temperature
+		}
+		if t.MaxTokens > 0 {
+			body.Options["num_predict"] = t.MaxTokens
+		}
+	}
 
 	if req.ScreenshotB64 != "" {
 		imgData := req.ScreenshotB64
@@ -399,14 +818,14 @@ func (s *AIService) callOllama(req VLMRequest, cfg *config.LLMConfig) (string, e
 	}
 
 	data, _ := json.Marshal(body)
-	resp, err := s.client.Post(cfg.OllamaBaseURL+"/api/generate", "application/json", bytes.NewReader(data))
+	resp, err := s.httpClient(cfg, "ollama").Post(cfg.OllamaBaseURL+"/api/generate", "application/json", bytes.NewReader(data))
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("ollama status %d", resp.StatusCode)
+		return "", &statusError{provider: "ollama", code: resp.StatusCode}
 	}
 
 	var result struct {
@@ -419,7 +838,8 @@ func (s *AIService) callOllama(req VLMRequest, cfg *config.LLMConfig) (string, e
 }
 
 func (s *AIService) isOllamaAvailableWithCfg(cfg *config.LLMConfig) bool {
-	client := &http.Client{Timeout: 2 * time.Second}
+	client := s.httpClient(cfg, "ollama")
+	client.Timeout = 2 * time.Second
 	resp, err := client.Get(cfg.OllamaBaseURL + "/api/tags")
 	if err != nil {
 		return false
@@ -439,6 +859,12 @@ func (s *AIService) ruleBasedDescription(req VLMRequest) string {
 		"scroll":     "滚动",
 		"hover":      "悬停在",
 	}
+	if IsKeyAction(req.StepAction) && req.KeyCombo != "" {
+		if req.MaskedText != "" {
+			return fmt.Sprintf("在[%s]页面，于[%s]%s", req.PageTitle, req.MaskedText, KeyPhrase(req.StepAction, req.KeyCombo))
+		}
+		return fmt.Sprintf("在[%s]页面，%s", req.PageTitle, KeyPhrase(req.StepAction, req.KeyCombo))
+	}
 	action := actionMap[req.StepAction]
 	if action == "" {
 		action = req.StepAction
@@ -458,11 +884,14 @@ type ProviderStatus struct {
 	Available bool   `json:"available"`
 	IsFree    bool   `json:"is_free"`
 	Reason    string `json:"reason,omitempty"`
+	Disabled  bool   `json:"disabled,omitempty"` // 离线模式下被禁用的外部提供商
+	// 该类型的全部配置都已达预算上限，路由链会跳过
+	QuotaReached bool `json:"quota_reached,omitempty"`
 }
 
 func (s *AIService) GetProvidersStatus() []ProviderStatus {
 	eff := s.effectiveCfg()
-	return []ProviderStatus{
+	statuses := []ProviderStatus{
 		{
 			ID:        "ollama",
 			Name:      "Ollama 本地 (完全免费)",
@@ -499,6 +928,35 @@ func (s *AIService) GetProvidersStatus() []ProviderStatus {
 			Reason:    "付费服务，需配置 OPENAI_API_KEY",
 		},
 	}
+	byName := map[string][]db.LLMProvider{}
+	for _, p := range activeProviders() {
+		byName[p.Name] = append(byName[p.Name], p)
+	}
+	now := time.Now()
+	for i := range statuses {
+		rows := byName[statuses[i].ID]
+		reasons := make([]string, 0, len(rows))
+		for _, p := range rows {
+			if reached, reason := QuotaExceeded(p, now); reached {
+				reasons = append(reasons, reason)
+			}
+		}
+		if len(rows) > 0 && len(reasons) == len(rows) {
+			statuses[i].Available = false
+			statuses[i].QuotaReached = true
+			statuses[i].Reason = "quota reached: " + strings.Join(reasons, "; ")
+		}
+	}
+	if s.LocalOnly() {
+		for i := range statuses {
+			if IsExternalProvider(statuses[i].ID) {
+				statuses[i].Available = false
+				statuses[i].Disabled = true
+				statuses[i].Reason = "离线模式（llm.local_only）已禁用外部提供商"
+			}
+		}
+	}
+	return statuses
 }
 
 // ─────────────────────────────────────────────────────────────
@@ -508,10 +966,51 @@ type DocGenerateProgress struct {
 	Current int
 	Total   int
 	StepID  string
+	Reused  bool // 画面与上一步几乎相同，复用了上一步的描述
 	Done    bool
 	Error   string
 }
 
+// SaveStepDescription 保存步骤描述与短标题（模型未给出时按规则生成并回填 resp.Title），同时记录生成它的提供商、模型、耗时、是否免费和是否仅文本生成；
+// 保存后在后台更新语义检索索引
+func SaveStepDescription(step *db.RecordingStep, resp *VLMResponse) error {
+	if resp.Title == "" {
+		resp.Title = RuleStepTitle(step)
+	}
+	err := db.DB.Model(step).Select("AIDescription", "AITitle", "AIProvider", "AIModel", "AILatencyMS", "AIUsedFree", "AITextOnly").
+		Updates(db.RecordingStep{
+			AIDescription: resp.Description,
+			AITitle:       resp.Title,
+			AIProvider:    resp.Provider,
+			AIModel:       resp.Model,
+			AILatencyMS:   resp.LatencyMS,
+			AIUsedFree:    resp.UsedFree,
+			AITextOnly:    resp.TextOnly,
+		}).Error
+	if err == nil {
+		QueueEmbedding(EmbedStep, step.ID)
+	}
+	return err
+}
+
+// PreviousDescriptions 读取该步骤之前最近 ContextWindow 个步骤的已有描述（由远到近）
+func (s *AIService) PreviousDescriptions(step *db.RecordingStep) []string {
+	if s.cfg.ContextWindow <= 0 {
+		return nil
+	}
+	var prev []db.RecordingStep
+	db.DB.Where("session_id = ? AND step_index < ?", step.SessionID, step.StepIndex).
+		Order("step_index DESC").Limit(s.cfg.ContextWindow).Find(&prev)
+	out := make([]string, 0, len(prev))
+	for i := len(prev) - 1; i >= 0; i-- {
+		if prev[i].AIDescription != "" {
+			out = append(out, prev[i].AIDescription)
+		}
+	}
+	return out
+}
+
+// GenerateDocForSession 逐步生成描述，并把最近的描述作为上下文传给下一步
 func (s *AIService) GenerateDocForSession(sessionID string, progressCh chan<- DocGenerateProgress) error {
 	var steps []db.RecordingStep
 	if err := db.DB.Where("session_id = ?", sessionID).Order("step_index").Find(&steps).Error; err != nil {
@@ -519,32 +1018,43 @@ func (s *AIService) GenerateDocForSession(sessionID string, progressCh chan<- Do
 	}
 
 	total := len(steps)
-	for i, step := range steps {
-		// 加载截图
-		var screenshot db.Screenshot
-		var screenshotB64 string
-		if step.ScreenshotID != "" {
-			db.DB.Where("id = ?", step.ScreenshotID).First(&screenshot)
-			screenshotB64 = screenshot.DataURL
+	window := s.cfg.ContextWindow
+	var previous []string
+	remember := func(desc string) {
+		if window <= 0 || desc == "" {
+			return
 		}
-
-		req := VLMRequest{
-			StepAction:    step.Action,
-			TargetElement: step.TargetElement,
-			PageURL:       step.PageURL,
-			PageTitle:     step.PageTitle,
-			MaskedText:    step.MaskedText,
-			ScreenshotB64: screenshotB64,
+		previous = append(previous, desc)
+		if len(previous) > window {
+			previous = previous[len(previous)-window:]
+		}
+	}
+	dedup := screenDeduper{distance: CurrentSettings().DuplicateScreenDistance}
+	for i, step := range steps {
+		req := StepVLMRequest(&step)
+		req.PreviousSteps = append([]string(nil), previous...)
+		// 表单类会话中连续多步画面几乎不变，改写上一步描述即可，省去一次 VLM 调用
+		if desc, ok := dedup.reuse(&step); ok {
+			if desc = s.postProcess(req, desc); ValidateDescription(desc, req) == nil {
+				resp := &VLMResponse{Description: desc, Provider: ProviderReused, UsedFree: true}
+				dedup.described(desc)
+				remember(desc)
+				SaveStepDescription(&step, resp)
+				progressCh <- DocGenerateProgress{Current: i + 1, Total: total, StepID: step.ID, Reused: true}
+				continue
+			}
 		}
-
 		resp, err := s.GenerateStepDescription(req)
 		if err != nil {
+			dedup.described(step.AIDescription)
+			remember(step.AIDescription)
 			progressCh <- DocGenerateProgress{Current: i + 1, Total: total, StepID: step.ID, Error: err.Error()}
 			continue
 		}
+		dedup.described(resp.Description)
+		remember(resp.Description)
 
-		// 更新步骤描述
-		db.DB.Model(&step).Update("ai_description", resp.Description)
+		SaveStepDescription(&step, resp)
 
 		progressCh <- DocGenerateProgress{Current: i + 1, Total: total, StepID: step.ID}
 	}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ─────────────────────────────────────────────────────────────
+// 文档级文本生成（章节标题、摘要等，纯文本无截图）
+// ─────────────────────────────────────────────────────────────
+
+// EnrichSections 为业务视图每个章节生成简洁标题和一段摘要；
+// 调用失败的章节保留原标题，技术视图同步使用新标题
+func (s *AIService) EnrichSections(content *GeneratedDocContent) {
+	for i := range content.BusinessView {
+		sec := &content.BusinessView[i]
+		if len(sec.Steps) == 0 {
+			continue
+		}
+		resp, err := s.GenerateText(buildSectionPrompt(content.SessionTitle, sec))
+		if err != nil {
+			continue
+		}
+		title, summary := parseSectionResponse(resp.Description)
+		if title != "" {
+			sec.Title = title
+			if i < len(content.TechnicalView) {
+				content.TechnicalView[i].Title = title + " - 技术参考"
+			}
+		}
+		if summary != "" {
+			sec.Summary = summary
+		}
+	}
+}
+
+func buildSectionPrompt(sessionTitle string, sec *DocSection) string {
+	var sb strings.Builder
+	for _, st := range sec.Steps {
+		sb.WriteString(fmt.Sprintf("- 第%d步：%s\n", st.StepIndex, st.Description))
+	}
+	return fmt.Sprintf(`%s以下是业务流程「%s」中某一章节的操作步骤：
+%s
+请为该章节生成：
+1. 一个不超过15个字的中文标题，概括本章节完成的业务操作；
+2. 一段不超过100字的中文摘要，说明本章节的目的和主要操作。
+
+严格按以下格式输出，不要输出其他内容：
+标题：<标题>
+摘要：<摘要>`, assistantPreamble(), sessionTitle, sb.String())
+}
+
+// parseSectionResponse 解析「标题：…/摘要：…」格式的模型输出
+func parseSectionResponse(text string) (title, summary string) {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.Trim(line, "*# "))
+		if v, ok := cutLabel(line, "标题"); ok {
+			title = strings.Trim(v, "「」\"《》")
+		} else if v, ok := cutLabel(line, "摘要"); ok {
+			summary = v
+		}
+	}
+	return title, summary
+}
+
+// cutLabel 去掉「标签：」或「标签:」前缀
+func cutLabel(line, label string) (string, bool) {
+	for _, sep := range []string{"：", ":"} {
+		if rest, ok := strings.CutPrefix(line, label+sep); ok {
+			return strings.TrimSpace(rest), true
+		}
+	}
+	return "", false
+}
+
+// stepOutline 按章节列出全部步骤描述，返回提纲文本和步骤总数
+func stepOutline(content *GeneratedDocContent) (string, int) {
+	var sb strings.Builder
+	total := 0
+	for _, sec := range content.BusinessView {
+		if sec.Kind != "" {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("【%s】\n", sec.Title))
+		for _, st := range sec.Steps {
+			sb.WriteString(fmt.Sprintf("- 第%d步：%s\n", st.StepIndex, st.Description))
+			total++
+		}
+	}
+	return sb.String(), total
+}
+
+// GenerateOverview 根据全部步骤描述生成一段流程概述（目的、前置条件、预期结果）；
+// 没有可用模型时按规则生成
+func (s *AIService) GenerateOverview(content *GeneratedDocContent) string {
+	outline, total := stepOutline(content)
+	if total == 0 {
+		return ""
+	}
+
+	prompt := fmt.Sprintf(`%s以下是业务流程「%s」的全部操作步骤：
+%s
+请用一段不超过200字的中文写出该流程的概述，依次说明：办理目的、开始前需要具备的前置条件（如账号权限、材料）、完成后的预期结果。
+只输出这一段话，不要分点，不要标题。`, assistantPreamble(), content.SessionTitle, outline)
+
+	if resp, err := s.GenerateText(prompt); err == nil {
+		return strings.TrimSpace(resp.Description)
+	}
+	return ruleBasedOverview(content, total)
+}
+
+func ruleBasedOverview(content *GeneratedDocContent, total int) string {
+	pages := []string{}
+	seen := map[string]bool{}
+	for _, sec := range content.BusinessView {
+		for _, st := range sec.Steps {
+			if st.PageTitle != "" && !seen[st.PageTitle] {
+				seen[st.PageTitle] = true
+				pages = append(pages, st.PageTitle)
+			}
+		}
+	}
+	overview := fmt.Sprintf("本文档说明「%s」的操作流程，共 %d 个步骤", content.SessionTitle, total)
+	if len(pages) > 0 {
+		overview += "，涉及页面：" + strings.Join(pages, "、")
+	}
+	return overview + "。请按以下步骤依次操作。"
+}
+
+// InsertOverview 生成流程概述并插入到业务视图顶部（已存在时替换）
+func (s *AIService) InsertOverview(content *GeneratedDocContent) {
+	overview := s.GenerateOverview(content)
+	if overview == "" {
+		return
+	}
+	sections := make([]DocSection, 0, len(content.BusinessView)+1)
+	sections = append(sections, DocSection{Kind: SectionOverview, Title: "流程概述", Summary: overview, Steps: []DocStep{}})
+	for _, sec := range content.BusinessView {
+		if sec.Kind != SectionOverview {
+			sections = append(sections, sec)
+		}
+	}
+	content.BusinessView = sections
+}
+
+// GenerateFAQ 根据录制步骤推导常见问题与异常情况（如“提交按钮不可点击怎么办”）
+func (s *AIService) GenerateFAQ(content *GeneratedDocContent) ([]FAQItem, error) {
+	outline, total := stepOutline(content)
+	if total == 0 {
+		return nil, nil
+	}
+	prompt := fmt.Sprintf(`%s以下是业务流程「%s」的全部操作步骤：
+%s
+请站在办事人员角度，推导该流程中最可能遇到的3~6个常见问题或异常情况（例如按钮不可点击、必填项校验失败、页面无响应），并给出简洁的处理建议。
+
+严格按以下格式输出，每组问答之间空一行，不要输出其他内容：
+问：<问题>
+答：<处理建议>`, assistantPreamble(), content.SessionTitle, outline)
+
+	resp, err := s.GenerateText(prompt)
+	if err != nil {
+		return nil, err
+	}
+	return parseFAQResponse(resp.Description), nil
+}
+
+// parseFAQResponse 解析「问：…/答：…」格式的模型输出，答案可跨多行
+func parseFAQResponse(text string) []FAQItem {
+	var items []FAQItem
+	var cur *FAQItem
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.Trim(line, "*# "))
+		if line == "" {
+			continue
+		}
+		if v, ok := cutLabel(line, "问"); ok {
+			items = append(items, FAQItem{Question: v})
+			cur = &items[len(items)-1]
+		} else if v, ok := cutLabel(line, "答"); ok && cur != nil {
+			cur.Answer = v
+		} else if cur != nil && cur.Answer != "" {
+			cur.Answer += line
+		}
+	}
+	out := items[:0]
+	for _, it := range items {
+		if it.Question != "" && it.Answer != "" {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// AppendFAQ 生成常见问题并作为章节追加到业务视图末尾（已存在时替换）
+func (s *AIService) AppendFAQ(content *GeneratedDocContent) error {
+	items, err := s.GenerateFAQ(content)
+	if err != nil {
+		return err
+	}
+	sections := make([]DocSection, 0, len(content.BusinessView)+1)
+	for _, sec := range content.BusinessView {
+		if sec.Kind != SectionFAQ {
+			sections = append(sections, sec)
+		}
+	}
+	if len(items) > 0 {
+		sections = append(sections, DocSection{Kind: SectionFAQ, Title: "常见问题", Steps: []DocStep{}, FAQ: items})
+	}
+	content.BusinessView = sections
+	return nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// askSources 每个问题检索的手册片段数
+const askSources = 6
+
+// AskCitation 回答引用的手册片段；Ref 为提示词与回答中的片段编号，步骤片段的 DocumentID 为所在会话的最新文档
+type AskCitation struct {
+	Ref          int     `json:"ref"`
+	SourceType   string  `json:"source_type"`
+	SessionID    string  `json:"session_id"`
+	SessionTitle string  `json:"session_title"`
+	DocumentID   string  `json:"document_id,omitempty"`
+	StepID       string  `json:"step_id,omitempty"`
+	StepIndex    int     `json:"step_index,omitempty"`
+	Text         string  `json:"text"`
+	Score        float64 `json:"score"`
+}
+
+// AskAnswer 问答结果；AIAnswered 为 false 表示没有可用模型或没有检索到相关片段，Answer 为兜底说明
+type AskAnswer struct {
+	Question   string        `json:"question"`
+	Answer     string        `json:"answer"`
+	AIAnswered bool          `json:"ai_answered"`
+	Provider   string        `json:"provider,omitempty"`
+	Model      string        `json:"model,omitempty"`
+	Citations  []AskCitation `json:"citations"`
+}
+
+// citationRe 回答中的片段编号标注，兼容全角括号
+var citationRe = regexp.MustCompile(`[\[［](\d+)[\]］]`)
+
+// Ask 基于已生成的手册回答操作问题（如“如何补打回执？”）：经语义索引检索相关的步骤与文档片段，
+// 交给模型只依据这些片段作答并以 [n] 标注来源；Citations 为回答引用的片段，回答未标注时为全部检索到的片段。
+// 模型不可用时返回最相关的片段原文
+func (s *AIService) Ask(question, projectID, sessionID string) (*AskAnswer, error) {
+	hits, err := SemanticSearch(question, projectID, sessionID, "", askSources)
+	if err != nil {
+		return nil, err
+	}
+	answer := &AskAnswer{Question: question, Citations: []AskCitation{}}
+	if len(hits) == 0 {
+		answer.Answer = "文档中没有找到相关说明。"
+		return answer, nil
+	}
+
+	sources := make([]AskCitation, len(hits))
+	docIDs := map[string]string{} // 会话 → 最新文档
+	for i, h := range hits {
+		c := AskCitation{
+			Ref: i + 1, SourceType: h.SourceType, SessionID: h.SessionID, SessionTitle: h.SessionTitle,
+			DocumentID: h.DocumentID, StepID: h.StepID, StepIndex: h.StepIndex, Text: h.Text, Score: h.Score,
+		}
+		if c.DocumentID == "" {
+			id, ok := docIDs[h.SessionID]
+			if !ok {
+				var session db.Session
+				db.DB.Select("generated_doc_id").First(&session, "id = ?", h.SessionID)
+				id, docIDs[h.SessionID] = session.GeneratedDocID, session.GeneratedDocID
+			}
+			c.DocumentID = id
+		}
+		sources[i] = c
+	}
+
+	resp, err := s.GenerateText(buildAskPrompt(question, sources))
+	if errors.Is(err, ErrNoProvider) {
+		var sb strings.Builder
+		sb.WriteString("暂时无法调用模型，以下是文档中最相关的内容：\n")
+		for _, c := range sources[:min(3, len(sources))] {
+			sb.WriteString(fmt.Sprintf("- %s [%d]\n", c.Text, c.Ref))
+		}
+		answer.Answer = strings.TrimSpace(sb.String())
+		answer.Citations = sources[:min(3, len(sources))]
+		return answer, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	answer.Answer, answer.AIAnswered = resp.Description, true
+	answer.Provider, answer.Model = resp.Provider, resp.Model
+
+	cited := map[int]bool{}
+	for _, m := range citationRe.FindAllStringSubmatch(resp.Description, -1) {
+		if n, _ := strconv.Atoi(m[1]); n >= 1 && n <= len(sources) {
+			cited[n] = true
+		}
+	}
+	if len(cited) == 0 {
+		answer.Citations = sources
+		return answer, nil
+	}
+	for _, c := range sources {
+		if cited[c.Ref] {
+			answer.Citations = append(answer.Citations, c)
+		}
+	}
+	return answer, nil
+}
+
+func buildAskPrompt(question string, sources []AskCitation) string {
+	var sb strings.Builder
+	for _, c := range sources {
+		where := "文档概要"
+		if c.SourceType == EmbedStep {
+			where = fmt.Sprintf("第 %d 步", c.StepIndex)
+		}
+		sb.WriteString(fmt.Sprintf("[%d] 《%s》%s：%s\n", c.Ref, c.SessionTitle, where, strings.ReplaceAll(c.Text, "\n", " / ")))
+	}
+	return fmt.Sprintf(`%s请根据下面的操作手册片段回答用户的问题。只使用片段中的信息，片段不足以回答时直接说明文档中没有相关说明，不要编造。
+用简洁的中文分步说明操作方法，每句话末尾用方括号标注所依据的片段编号，如 [1]。
+
+手册片段：
+%s
+问题：%s`, assistantPreamble(), sb.String(), question)
+}
@@ -0,0 +1,68 @@
+package service_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestAsk(t *testing.T) {
+	setupDB(t)
+	t.Cleanup(func() { service.SetEmbedder(nil, 0) })
+	proj := db.Project{Name: "收银系统"}
+	db.DB.Create(&proj)
+	sess := db.Session{ProjectID: proj.ID, Title: "补打回执"}
+	db.DB.Create(&sess)
+	steps := []db.RecordingStep{
+		{AIDescription: "在【交易记录】中找到需要补打的订单", PageTitle: "交易记录"},
+		{AIDescription: "点击【补打回执】按钮打印回执", PageTitle: "订单详情"},
+	}
+	for i := range steps {
+		steps[i].SessionID, steps[i].StepIndex = sess.ID, i+1
+		db.DB.Create(&steps[i])
+	}
+	docSvc := service.NewDocService()
+	content, _ := docSvc.BuildDocument(sess.ID)
+	doc, _ := docSvc.SaveGeneratedDoc(sess.ID, content)
+
+	var prompt string
+	aiSvc := fakeOllama(t, func(p string) string {
+		prompt = p
+		return "在交易记录中找到订单 [1]，然后点击【补打回执】按钮 [2]。"
+	})
+	if _, err := aiSvc.Ask("如何补打回执？", proj.ID, ""); !errors.Is(err, service.ErrEmbeddingDisabled) {
+		t.Fatalf("expected ErrEmbeddingDisabled, got %v", err)
+	}
+	service.SetEmbedder(service.LocalEmbedder{}, time.Second)
+	if _, err := service.ReindexEmbeddings(proj.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	answer, err := aiSvc.Ask("如何补打回执？", proj.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !answer.AIAnswered || answer.Model != "test-model" || !strings.Contains(answer.Answer, "[2]") {
+		t.Fatalf("unexpected answer %+v", answer)
+	}
+	if !strings.Contains(prompt, "如何补打回执？") || !strings.Contains(prompt, "《补打回执》") {
+		t.Errorf("prompt missing question or sources:\n%s", prompt)
+	}
+	// 只返回回答中标注的片段；步骤片段指向会话的最新文档
+	if len(answer.Citations) != 2 {
+		t.Fatalf("expected 2 citations, got %+v", answer.Citations)
+	}
+	for _, c := range answer.Citations {
+		if c.Ref > 2 || c.DocumentID != doc.ID {
+			t.Errorf("unexpected citation %+v", c)
+		}
+	}
+
+	if answer, _ := aiSvc.Ask("如何补打回执？", "other-project", ""); answer.AIAnswered || len(answer.Citations) != 0 {
+		t.Errorf("expected no sources outside the project: %+v", answer)
+	}
+}
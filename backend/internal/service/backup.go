@@ -0,0 +1,476 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	backupFormatVersion = 1
+	backupManifestName  = "manifest.json"
+	// BackupDirName 存储目录下存放备份文件的子目录（不会被打包进备份本身）
+	BackupDirName = "backups"
+)
+
+// BackupEntry 归档中的单个文件及校验和
+type BackupEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	Rows   int    `json:"rows,omitempty"` // 仅数据表
+}
+
+// BackupManifest 备份清单
+type BackupManifest struct {
+	FormatVersion int           `json:"format_version"`
+	SchemaVersion string        `json:"schema_version"`
+	CreatedAt     string        `json:"created_at"`
+	Tables        []BackupEntry `json:"tables"`
+	Files         []BackupEntry `json:"files"`
+}
+
+// BackupInfo 已保存备份的概要
+type BackupInfo struct {
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	CreatedAt string `json:"created_at"`
+}
+
+// BackupService 数据库 + 截图存储的打包备份与恢复
+type BackupService struct {
+	storagePath string
+}
+
+func NewBackupService(storagePath string) *BackupService {
+	return &BackupService{storagePath: storagePath}
+}
+
+// BackupDir 备份文件所在目录
+func (s *BackupService) BackupDir() string {
+	return filepath.Join(s.storagePath, BackupDirName)
+}
+
+// CreateBackupFile 在备份目录下生成一个新的备份归档
+func (s *BackupService) CreateBackupFile() (string, *BackupManifest, error) {
+	if err := os.MkdirAll(s.BackupDir(), 0o755); err != nil {
+		return "", nil, err
+	}
+	name := fmt.Sprintf("gpilot-backup-%s.zip", time.Now().Format("20060102-150405"))
+	full := filepath.Join(s.BackupDir(), name)
+
+	f, err := os.Create(full)
+	if err != nil {
+		return "", nil, err
+	}
+	manifest, err := s.WriteBackup(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(full)
+		return "", nil, err
+	}
+	return name, manifest, nil
+}
+
+// ListBackups 列出备份目录下的归档（新的在前）
+func (s *BackupService) ListBackups() ([]BackupInfo, error) {
+	entries, err := os.ReadDir(s.BackupDir())
+	if os.IsNotExist(err) {
+		return []BackupInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := []BackupInfo{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".zip") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		list = append(list, BackupInfo{
+			Name:      e.Name(),
+			Size:      info.Size(),
+			CreatedAt: info.ModTime().Format(time.RFC3339),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name > list[j].Name })
+	return list, nil
+}
+
+// BackupPath 校验备份名称并返回完整路径（防止路径穿越）
+func (s *BackupService) BackupPath(name string) (string, error) {
+	if name == "" || filepath.Base(name) != name || !strings.HasSuffix(name, ".zip") {
+		return "", fmt.Errorf("invalid backup name %q", name)
+	}
+	full := filepath.Join(s.BackupDir(), name)
+	if _, err := os.Stat(full); err != nil {
+		return "", err
+	}
+	return full, nil
+}
+
+// WriteBackup 将所有数据表和存储目录写入 zip 归档
+func (s *BackupService) WriteBackup(w io.Writer) (*BackupManifest, error) {
+	version, err := db.SchemaVersion(db.DB)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &BackupManifest{
+		FormatVersion: backupFormatVersion,
+		SchemaVersion: version,
+		CreatedAt:     time.Now().Format(time.RFC3339),
+		Tables:        []BackupEntry{},
+		Files:         []BackupEntry{},
+	}
+
+	zw := zip.NewWriter(w)
+
+	for _, model := range db.Models() {
+		sch, err := parseSchema(model)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := dumpTable(db.DB, model, sch)
+		if err != nil {
+			return nil, fmt.Errorf("dump %s: %w", sch.Table, err)
+		}
+		data, err := json.Marshal(rows)
+		if err != nil {
+			return nil, err
+		}
+		entry, err := writeZipEntry(zw, "db/"+sch.Table+".json", data)
+		if err != nil {
+			return nil, err
+		}
+		entry.Rows = len(rows)
+		manifest.Tables = append(manifest.Tables, entry)
+	}
+	for _, table := range db.JoinTables() {
+		var rows []map[string]interface{}
+		if err := db.DB.Table(table).Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("dump %s: %w", table, err)
+		}
+		data, err := json.Marshal(rows)
+		if err != nil {
+			return nil, err
+		}
+		entry, err := writeZipEntry(zw, "db/"+table+".json", data)
+		if err != nil {
+			return nil, err
+		}
+		entry.Rows = len(rows)
+		manifest.Tables = append(manifest.Tables, entry)
+	}
+
+	err = filepath.WalkDir(s.storagePath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == s.storagePath {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		rel, _ := filepath.Rel(s.storagePath, p)
+		if d.IsDir() {
+			if rel == BackupDirName || rel == ExportDirName {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".health-") {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		entry, err := writeZipEntry(zw, "storage/"+filepath.ToSlash(rel), data)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mdata, _ := json.MarshalIndent(manifest, "", "  ")
+	w2, err := zw.Create(backupManifestName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w2.Write(mdata); err != nil {
+		return nil, err
+	}
+	return manifest, zw.Close()
+}
+
+// VerifyBackup 校验归档完整性（清单存在、所有条目 sha256 一致、schema 版本兼容）
+func (s *BackupService) VerifyBackup(r io.ReaderAt, size int64) (*BackupManifest, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid backup archive: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	mf, ok := files[backupManifestName]
+	if !ok {
+		return nil, fmt.Errorf("backup manifest missing")
+	}
+	mdata, err := readZipFile(mf)
+	if err != nil {
+		return nil, err
+	}
+	var manifest BackupManifest
+	if err := json.Unmarshal(mdata, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid backup manifest: %w", err)
+	}
+	if manifest.FormatVersion != backupFormatVersion {
+		return nil, fmt.Errorf("unsupported backup format version %d", manifest.FormatVersion)
+	}
+	if manifest.SchemaVersion > db.LatestVersion() {
+		return nil, fmt.Errorf("backup schema %s is newer than this server (%s)", manifest.SchemaVersion, db.LatestVersion())
+	}
+
+	for _, entry := range append(append([]BackupEntry{}, manifest.Tables...), manifest.Files...) {
+		f, ok := files[entry.Path]
+		if !ok {
+			return nil, fmt.Errorf("backup entry %s missing", entry.Path)
+		}
+		data, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if checksum(data) != entry.SHA256 {
+			return nil, fmt.Errorf("checksum mismatch for %s", entry.Path)
+		}
+	}
+	return &manifest, nil
+}
+
+// RestoreBackup 校验归档后，用其内容替换所有数据表并写回存储文件
+func (s *BackupService) RestoreBackup(r io.ReaderAt, size int64) (*BackupManifest, error) {
+	manifest, err := s.VerifyBackup(r, size)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	models := db.Models()
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		// 先清空关联表并按逆序清空数据表，再按顺序导入数据表、最后导入关联表
+		for _, table := range db.JoinTables() {
+			if err := tx.Exec("DELETE FROM ?", clause.Table{Name: table}).Error; err != nil {
+				return err
+			}
+		}
+		for i := len(models) - 1; i >= 0; i-- {
+			if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(models[i]).Error; err != nil {
+				return err
+			}
+		}
+		for _, model := range models {
+			sch, err := parseSchema(model)
+			if err != nil {
+				return err
+			}
+			f, ok := files["db/"+sch.Table+".json"]
+			if !ok {
+				continue // 旧版本备份中不存在的表保持为空
+			}
+			data, err := readZipFile(f)
+			if err != nil {
+				return err
+			}
+			if err := restoreTable(tx, sch, data); err != nil {
+				return fmt.Errorf("restore %s: %w", sch.Table, err)
+			}
+		}
+		for _, table := range db.JoinTables() {
+			f, ok := files["db/"+table+".json"]
+			if !ok {
+				continue
+			}
+			data, err := readZipFile(f)
+			if err != nil {
+				return err
+			}
+			var rows []map[string]interface{}
+			if err := json.Unmarshal(data, &rows); err != nil {
+				return fmt.Errorf("restore %s: %w", table, err)
+			}
+			if len(rows) == 0 {
+				continue
+			}
+			if err := tx.Table(table).CreateInBatches(rows, 100).Error; err != nil {
+				return fmt.Errorf("restore %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 恢复的运行时设置立即生效
+	ReloadSettings()
+
+	for _, entry := range manifest.Files {
+		rel := strings.TrimPrefix(entry.Path, "storage/")
+		if rel == "" || strings.Contains(rel, "..") || path.IsAbs(rel) {
+			return nil, fmt.Errorf("unsafe storage path %s", entry.Path)
+		}
+		data, err := readZipFile(files[entry.Path])
+		if err != nil {
+			return nil, err
+		}
+		dst := filepath.Join(s.storagePath, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(dst, data, 0o600); err != nil {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
+
+// ─────────────────────────────────────
+// 表导出 / 导入（按 GORM schema 处理字段类型，json:"-" 字段也会完整保留）
+// ─────────────────────────────────────
+
+var schemaCache sync.Map
+
+func parseSchema(model interface{}) (*schema.Schema, error) {
+	return schema.Parse(model, &schemaCache, db.DB.NamingStrategy)
+}
+
+// dumpTable 导出表的原始行；跳过模型钩子，加密的截图与文档以密文写入备份（恢复时需使用相同密钥）
+func dumpTable(gdb *gorm.DB, model interface{}, sch *schema.Schema) ([]map[string]interface{}, error) {
+	slice := reflect.New(reflect.SliceOf(sch.ModelType))
+	if err := gdb.Session(&gorm.Session{SkipHooks: true}).Model(model).Find(slice.Interface()).Error; err != nil {
+		return nil, err
+	}
+	items := slice.Elem()
+	rows := make([]map[string]interface{}, 0, items.Len())
+	ctx := context.Background()
+	for i := 0; i < items.Len(); i++ {
+		rv := items.Index(i)
+		row := make(map[string]interface{}, len(sch.DBNames))
+		for _, name := range sch.DBNames {
+			v, _ := sch.FieldsByDBName[name].ValueOf(ctx, rv)
+			row[name] = v
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func restoreTable(tx *gorm.DB, sch *schema.Schema, data []byte) error {
+	var raws []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return err
+	}
+	if len(raws) == 0 {
+		return nil
+	}
+	rows := make([]map[string]interface{}, 0, len(raws))
+	for _, raw := range raws {
+		row := make(map[string]interface{}, len(raw))
+		for name, val := range raw {
+			field, ok := sch.FieldsByDBName[name]
+			if !ok {
+				continue // 备份中存在但当前 schema 已移除的列
+			}
+			ptr := reflect.New(field.FieldType)
+			if err := json.Unmarshal(val, ptr.Interface()); err != nil {
+				return fmt.Errorf("column %s: %w", name, err)
+			}
+			row[name] = ptr.Elem().Interface()
+		}
+		rows = append(rows, row)
+	}
+	return tx.Table(sch.Table).CreateInBatches(rows, 100).Error
+}
+
+func writeZipEntry(zw *zip.Writer, name string, data []byte) (BackupEntry, error) {
+	w, err := zw.Create(name)
+	if err != nil {
+		return BackupEntry{}, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return BackupEntry{}, err
+	}
+	return BackupEntry{Path: name, Size: int64(len(data)), SHA256: checksum(data)}, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// errZipEntryTooLarge zip 条目解压后超过上限
+var errZipEntryTooLarge = errors.New("zip entry too large")
+
+// readZipFileLimit 读取 zip 条目，解压后超过 limit 字节时返回 errZipEntryTooLarge（防止压缩炸弹；
+// 条目头中的大小可被伪造，读取时同样限制）
+func readZipFileLimit(f *zip.File, limit int64) ([]byte, error) {
+	if limit < 0 || f.UncompressedSize64 > uint64(limit) {
+		return nil, errZipEntryTooLarge
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errZipEntryTooLarge
+	}
+	return data, nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,156 @@
+package service_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestBackup_RoundTrip(t *testing.T) {
+	setupDB(t)
+	_, sessionID := seedSessionWithSteps(t, 3)
+	db.DB.Create(&db.LLMProvider{Name: "gemini", APIKey: "SECRET_KEY", IsActive: true})
+
+	storage := t.TempDir()
+	os.MkdirAll(filepath.Join(storage, "screenshots"), 0o755)
+	os.WriteFile(filepath.Join(storage, "screenshots", "a.png"), []byte("png-bytes"), 0o600)
+
+	svc := service.NewBackupService(storage)
+	var buf bytes.Buffer
+	manifest, err := svc.WriteBackup(&buf)
+	if err != nil {
+		t.Fatalf("WriteBackup: %v", err)
+	}
+	if len(manifest.Files) != 1 {
+		t.Errorf("expected 1 storage file, got %d", len(manifest.Files))
+	}
+
+	// 破坏现有数据后恢复
+	db.DB.Where("1 = 1").Delete(&db.RecordingStep{})
+	db.DB.Where("1 = 1").Delete(&db.LLMProvider{})
+	os.RemoveAll(filepath.Join(storage, "screenshots"))
+
+	data := buf.Bytes()
+	if _, err := svc.RestoreBackup(bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("RestoreBackup: %v", err)
+	}
+
+	var count int64
+	db.DB.Model(&db.RecordingStep{}).Where("session_id = ?", sessionID).Count(&count)
+	if count != 3 {
+		t.Errorf("expected 3 steps after restore, got %d", count)
+	}
+	var provider db.LLMProvider
+	db.DB.First(&provider, "name = ?", "gemini")
+	if provider.APIKey != "SECRET_KEY" {
+		t.Errorf("api key not preserved: %q", provider.APIKey)
+	}
+	if got, _ := os.ReadFile(filepath.Join(storage, "screenshots", "a.png")); string(got) != "png-bytes" {
+		t.Errorf("storage file not restored: %q", got)
+	}
+}
+
+func TestBackup_RoundTripTags(t *testing.T) {
+	setupDB(t)
+	projectID, sessionID := seedSessionWithSteps(t, 1)
+	tag := db.Tag{Name: "核心流程", Color: "#f00"}
+	db.DB.Create(&tag)
+	db.DB.Model(&db.Project{Base: db.Base{ID: projectID}}).Association("Tags").Append(&tag)
+	db.DB.Model(&db.Session{Base: db.Base{ID: sessionID}}).Association("Tags").Append(&tag)
+
+	svc := service.NewBackupService(t.TempDir())
+	var buf bytes.Buffer
+	if _, err := svc.WriteBackup(&buf); err != nil {
+		t.Fatalf("WriteBackup: %v", err)
+	}
+
+	// 恢复前新增的标签和关联应被清空
+	stray := db.Tag{Name: "临时"}
+	db.DB.Create(&stray)
+	db.DB.Model(&db.Project{Base: db.Base{ID: projectID}}).Association("Tags").Replace(&stray)
+	db.DB.Exec("DELETE FROM session_tags")
+
+	data := buf.Bytes()
+	if _, err := svc.RestoreBackup(bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("RestoreBackup: %v", err)
+	}
+
+	var tags []db.Tag
+	db.DB.Find(&tags)
+	if len(tags) != 1 || tags[0].ID != tag.ID {
+		t.Fatalf("expected only the backed-up tag, got %+v", tags)
+	}
+	var project db.Project
+	db.DB.Preload("Tags").First(&project, "id = ?", projectID)
+	if len(project.Tags) != 1 || project.Tags[0].ID != tag.ID {
+		t.Errorf("project tags not restored: %+v", project.Tags)
+	}
+	var session db.Session
+	db.DB.Preload("Tags").First(&session, "id = ?", sessionID)
+	if len(session.Tags) != 1 || session.Tags[0].ID != tag.ID {
+		t.Errorf("session tags not restored: %+v", session.Tags)
+	}
+}
+
+func TestBackup_RestoresSettings(t *testing.T) {
+	setupDB(t)
+	if _, err := service.UpdateSettings(map[string]json.RawMessage{"ai_concurrency": json.RawMessage(`7`)}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	svc := service.NewBackupService(t.TempDir())
+	var buf bytes.Buffer
+	if _, err := svc.WriteBackup(&buf); err != nil {
+		t.Fatalf("WriteBackup: %v", err)
+	}
+
+	if _, err := service.UpdateSettings(map[string]json.RawMessage{"ai_concurrency": json.RawMessage(`2`)}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	data := buf.Bytes()
+	if _, err := svc.RestoreBackup(bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("RestoreBackup: %v", err)
+	}
+	// 缓存仍在有效期内，恢复后也应读到备份中的设置
+	if got := service.CurrentSettings().AIConcurrency; got != 7 {
+		t.Errorf("expected restored ai_concurrency 7, got %d", got)
+	}
+}
+
+func TestBackup_VerifyRejectsTampering(t *testing.T) {
+	setupDB(t)
+	seedSessionWithSteps(t, 1)
+
+	svc := service.NewBackupService(t.TempDir())
+	var buf bytes.Buffer
+	if _, err := svc.WriteBackup(&buf); err != nil {
+		t.Fatalf("WriteBackup: %v", err)
+	}
+
+	// 重新打包，替换 sessions 表内容但保留原清单
+	src, _ := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	var tampered bytes.Buffer
+	zw := zip.NewWriter(&tampered)
+	for _, f := range src.File {
+		rc, _ := f.Open()
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		if f.Name == "db/sessions.json" {
+			content = []byte("[]")
+		}
+		w, _ := zw.Create(f.Name)
+		w.Write(content)
+	}
+	zw.Close()
+
+	data := tampered.Bytes()
+	if _, err := svc.VerifyBackup(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Error("expected verification to fail for tampered archive")
+	}
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// 禁用词数量与长度上限
+const (
+	maxBannedPhrases   = 200
+	maxBannedPhraseLen = 50
+)
+
+var errBannedPhrase = errors.New("invalid banned phrase")
+
+// validateBannedPhrases 校验禁用词列表：非空、不超过长度与数量上限
+func validateBannedPhrases(phrases []string) error {
+	if len(phrases) > maxBannedPhrases {
+		return fmt.Errorf("%w: at most %d phrases", errBannedPhrase, maxBannedPhrases)
+	}
+	for _, p := range phrases {
+		if strings.TrimSpace(p) == "" || utf8.RuneCountInString(p) > maxBannedPhraseLen {
+			return fmt.Errorf("%w: %q must be 1-%d characters", errBannedPhrase, p, maxBannedPhraseLen)
+		}
+	}
+	return nil
+}
+
+// NormalizeBannedPhrases 去除首尾空白与重复项后校验
+func NormalizeBannedPhrases(phrases []string) ([]string, error) {
+	out := make([]string, 0, len(phrases))
+	seen := make(map[string]bool, len(phrases))
+	for _, p := range phrases {
+		p = strings.TrimSpace(p)
+		if p != "" && seen[p] {
+			continue
+		}
+		seen[p] = true
+		out = append(out, p)
+	}
+	return out, validateBannedPhrases(out)
+}
+
+// ProjectBannedPhrases 项目生效的禁用词：全局设置与项目设置合并
+func ProjectBannedPhrases(projectID string) []string {
+	phrases := append([]string(nil), CurrentSettings().BannedPhrases...)
+	var project db.Project
+	if projectID != "" && db.DB.Select("banned_phrases").First(&project, "id = ?", projectID).Error == nil {
+		phrases = append(phrases, project.BannedPhrases...)
+	}
+	return phrases
+}
+
+// SessionBannedPhrases 会话所属项目生效的禁用词
+func SessionBannedPhrases(sessionID string) []string {
+	var session db.Session
+	db.DB.Select("project_id").First(&session, "id = ?", sessionID)
+	return ProjectBannedPhrases(session.ProjectID)
+}
+
+// FindBannedPhrases 文本中出现的禁用词（忽略英文大小写），按列表顺序去重
+func FindBannedPhrases(text string, phrases []string) []string {
+	if text == "" || len(phrases) == 0 {
+		return nil
+	}
+	lower := strings.ToLower(text)
+	var hits []string
+	seen := map[string]bool{}
+	for _, p := range phrases {
+		if p != "" && !seen[p] && strings.Contains(lower, strings.ToLower(p)) {
+			seen[p] = true
+			hits = append(hits, p)
+		}
+	}
+	return hits
+}
+
+// WithBannedPhrases 返回检查禁用词的服务副本：输出命中时附带纠正说明重试一次，仍命中则在响应中标出
+func (s *AIService) WithBannedPhrases(phrases []string) *AIService {
+	c := *s
+	c.banned = phrases
+	return &c
+}
+
+// checkBanned 检查输出中的禁用词；命中时以 Avoid 说明重新调用一次，重试失败时保留原输出
+func (s *AIService) checkBanned(req VLMRequest, resp *VLMResponse, call func(VLMRequest) (*VLMResponse, error)) *VLMResponse {
+	hits := FindBannedPhrases(resp.Description, s.banned)
+	if len(hits) == 0 {
+		return resp
+	}
+	req.Avoid = hits
+	if retry, err := call(req); err == nil && retry.Description != "" {
+		resp = retry
+	}
+	resp.BannedHits = FindBannedPhrases(resp.Description, s.banned)
+	return resp
+}
+
+// avoidHint 重试时附加的纠正说明
+func avoidHint(avoid []string) string {
+	if len(avoid) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("上一次的输出包含禁用词语（%s），请重新生成，输出中不得出现这些词语。\n", strings.Join(avoid, "、"))
+}
+
+// BannedHit 文档中命中禁用词的位置
+type BannedHit struct {
+	Section   int      `json:"section"`              // 章节序号（1 起）
+	StepIndex int      `json:"step_index,omitempty"` // 0 表示章节标题、摘要或常见问题
+	Phrases   []string `json:"phrases"`
+}
+
+// DocumentBannedHits 扫描业务视图的章节标题、摘要、步骤描述和常见问题；
+// 技术视图为元素原文，不做检查
+func DocumentBannedHits(content *GeneratedDocContent, phrases []string) []BannedHit {
+	if len(phrases) == 0 {
+		return nil
+	}
+	var hits []BannedHit
+	for i, sec := range content.BusinessView {
+		text := sec.Title + "\n" + sec.Summary
+		for _, f := range sec.FAQ {
+			text += "\n" + f.Question + "\n" + f.Answer
+		}
+		if found := FindBannedPhrases(text, phrases); len(found) > 0 {
+			hits = append(hits, BannedHit{Section: i + 1, Phrases: found})
+		}
+		for _, st := range sec.Steps {
+			if found := FindBannedPhrases(st.Description, phrases); len(found) > 0 {
+				hits = append(hits, BannedHit{Section: i + 1, StepIndex: st.StepIndex, Phrases: found})
+			}
+		}
+	}
+	return hits
+}
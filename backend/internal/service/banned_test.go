@@ -0,0 +1,61 @@
+package service_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestBannedPhrases(t *testing.T) {
+	setupDB(t)
+	projectID, sessionID := seedSessionWithSteps(t, 2)
+
+	if _, err := service.NormalizeBannedPhrases([]string{"  "}); err == nil {
+		t.Error("blank phrase should be rejected")
+	}
+	project, _ := service.NormalizeBannedPhrases([]string{" Please ", "Please"})
+	db.DB.Model(&db.Project{}).Where("id = ?", projectID).Update("banned_phrases", db.StringList(project))
+	if _, err := service.UpdateSettings(map[string]json.RawMessage{"banned_phrases": json.RawMessage(`["亲"]`)}); err != nil {
+		t.Fatal(err)
+	}
+	phrases := service.SessionBannedPhrases(sessionID)
+	if !reflect.DeepEqual(phrases, []string{"亲", "Please"}) {
+		t.Fatalf("global and project phrases should be merged, got %v", phrases)
+	}
+	if got := service.FindBannedPhrases("please 点击【提交】", phrases); !reflect.DeepEqual(got, []string{"Please"}) {
+		t.Errorf("matching should ignore case, got %v", got)
+	}
+
+	// 命中禁用词时带纠正说明重试
+	var prompts []string
+	stubborn := false
+	ai := fakeOllama(t, func(p string) string {
+		prompts = append(prompts, p)
+		if !stubborn && strings.Contains(p, "禁用词语（亲）") {
+			return "第1步：点击【提交】"
+		}
+		return "亲，第1步：点击【提交】"
+	}).WithBannedPhrases(phrases)
+	resp, _ := ai.GenerateStepDescription(service.VLMRequest{StepAction: "click", TargetElement: "提交"})
+	if resp.Description != "第1步：点击【提交】" || len(resp.BannedHits) != 0 || len(prompts) != 2 {
+		t.Errorf("expected a corrected retry, got %+v after %d calls", resp, len(prompts))
+	}
+
+	// 重试后仍命中时标出
+	stubborn = true
+	if resp, _ := ai.GenerateText("生成标题"); !reflect.DeepEqual(resp.BannedHits, []string{"亲"}) {
+		t.Errorf("remaining hits should be flagged, got %+v", resp)
+	}
+
+	content, _ := service.NewDocService().BuildDocument(sessionID)
+	content.BusinessView[0].Steps[0].Description = "亲，打开首页"
+	content.BusinessView[0].Summary = "Please read"
+	hits := service.DocumentBannedHits(content, phrases)
+	if len(hits) != 2 || hits[0].StepIndex != 0 || hits[1].StepIndex != content.BusinessView[0].Steps[0].StepIndex {
+		t.Errorf("unexpected document hits: %+v", hits)
+	}
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// BPMN 2.0 命名空间
+const (
+	bpmnModelNS = "http://www.omg.org/spec/BPMN/20100524/MODEL"
+	bpmnDINS    = "http://www.omg.org/spec/BPMN/20100524/DI"
+	bpmnDCNS    = "http://www.omg.org/spec/DD/20100524/DC"
+	bpmnDDINS   = "http://www.omg.org/spec/DD/20100524/DI"
+)
+
+// 图形布局（像素）：每个页面一条泳道，步骤按录制顺序从左到右排列
+const (
+	bpmnLaneHeader = 30  // 泳池左侧标题栏宽度
+	bpmnLaneHeight = 120 // 泳道高度
+	bpmnTaskW      = 100
+	bpmnTaskH      = 80
+	bpmnGap        = 40 // 相邻节点的水平间距
+	bpmnEventSize  = 36
+)
+
+type bpmnDefinitions struct {
+	XMLName         xml.Name          `xml:"bpmn:definitions"`
+	NSBPMN          string            `xml:"xmlns:bpmn,attr"`
+	NSBPMNDI        string            `xml:"xmlns:bpmndi,attr"`
+	NSDC            string            `xml:"xmlns:dc,attr"`
+	NSDI            string            `xml:"xmlns:di,attr"`
+	ID              string            `xml:"id,attr"`
+	TargetNamespace string            `xml:"targetNamespace,attr"`
+	Collaboration   bpmnCollaboration `xml:"bpmn:collaboration"`
+	Process         bpmnProcess       `xml:"bpmn:process"`
+	Diagram         bpmnDiagram       `xml:"bpmndi:BPMNDiagram"`
+}
+
+type bpmnCollaboration struct {
+	ID          string          `xml:"id,attr"`
+	Participant bpmnParticipant `xml:"bpmn:participant"`
+}
+
+type bpmnParticipant struct {
+	ID         string `xml:"id,attr"`
+	Name       string `xml:"name,attr"`
+	ProcessRef string `xml:"processRef,attr"`
+}
+
+type bpmnProcess struct {
+	ID           string     `xml:"id,attr"`
+	Name         string     `xml:"name,attr"`
+	IsExecutable bool       `xml:"isExecutable,attr"`
+	LaneSet      bpmnLanes  `xml:"bpmn:laneSet"`
+	StartEvent   bpmnNode   `xml:"bpmn:startEvent"`
+	Tasks        []bpmnNode `xml:"bpmn:userTask"`
+	EndEvent     bpmnNode   `xml:"bpmn:endEvent"`
+	Flows        []bpmnFlow `xml:"bpmn:sequenceFlow"`
+}
+
+type bpmnLanes struct {
+	ID    string     `xml:"id,attr"`
+	Lanes []bpmnLane `xml:"bpmn:lane"`
+}
+
+type bpmnLane struct {
+	ID       string   `xml:"id,attr"`
+	Name     string   `xml:"name,attr"`
+	NodeRefs []string `xml:"bpmn:flowNodeRef"`
+}
+
+type bpmnNode struct {
+	ID   string `xml:"id,attr"`
+	Name string `xml:"name,attr,omitempty"`
+}
+
+type bpmnFlow struct {
+	ID        string `xml:"id,attr"`
+	SourceRef string `xml:"sourceRef,attr"`
+	TargetRef string `xml:"targetRef,attr"`
+}
+
+type bpmnDiagram struct {
+	ID    string    `xml:"id,attr"`
+	Plane bpmnPlane `xml:"bpmndi:BPMNPlane"`
+}
+
+type bpmnPlane struct {
+	ID          string      `xml:"id,attr"`
+	BPMNElement string      `xml:"bpmnElement,attr"`
+	Shapes      []bpmnShape `xml:"bpmndi:BPMNShape"`
+	Edges       []bpmnEdge  `xml:"bpmndi:BPMNEdge"`
+}
+
+type bpmnShape struct {
+	ID           string     `xml:"id,attr"`
+	BPMNElement  string     `xml:"bpmnElement,attr"`
+	IsHorizontal bool       `xml:"isHorizontal,attr,omitempty"`
+	Bounds       bpmnBounds `xml:"dc:Bounds"`
+}
+
+type bpmnBounds struct {
+	X      int `xml:"x,attr"`
+	Y      int `xml:"y,attr"`
+	Width  int `xml:"width,attr"`
+	Height int `xml:"height,attr"`
+}
+
+type bpmnEdge struct {
+	ID          string      `xml:"id,attr"`
+	BPMNElement string      `xml:"bpmnElement,attr"`
+	Waypoints   []bpmnPoint `xml:"di:waypoint"`
+}
+
+type bpmnPoint struct {
+	X int `xml:"x,attr"`
+	Y int `xml:"y,attr"`
+}
+
+// ExportBPMN 把流程图转换为 BPMN 2.0 XML：每个页面一条泳道，步骤为用户任务，按录制顺序以顺序流相连，
+// 首尾为开始 / 结束事件；附带简单的图形布局，便于在 BPM 建模工具中直接打开
+func ExportBPMN(g *FlowGraph) ([]byte, error) {
+	name := g.Title
+	if name == "" {
+		name = "录制流程"
+	}
+	def := bpmnDefinitions{
+		NSBPMN: bpmnModelNS, NSBPMNDI: bpmnDINS, NSDC: bpmnDCNS, NSDI: bpmnDDINS,
+		ID: "Definitions_1", TargetNamespace: "https://gpilot.local/bpmn",
+		Collaboration: bpmnCollaboration{
+			ID:          "Collaboration_1",
+			Participant: bpmnParticipant{ID: "Participant_1", Name: name, ProcessRef: "Process_1"},
+		},
+		Process: bpmnProcess{
+			ID: "Process_1", Name: name,
+			LaneSet:    bpmnLanes{ID: "LaneSet_1"},
+			StartEvent: bpmnNode{ID: "StartEvent_1", Name: "开始"},
+			EndEvent:   bpmnNode{ID: "EndEvent_1", Name: "结束"},
+		},
+	}
+	plane := &def.Diagram.Plane
+	def.Diagram.ID, plane.ID, plane.BPMNElement = "BPMNDiagram_1", "BPMNPlane_1", "Collaboration_1"
+
+	pages := g.Pages()
+	if len(pages) == 0 {
+		pages = []FlowNode{{ID: "", Label: "未知页面"}}
+	}
+	lanes := map[string]int{} // 页面节点 ID → 泳道下标
+	for i, p := range pages {
+		lanes[p.ID] = i
+		def.Process.LaneSet.Lanes = append(def.Process.LaneSet.Lanes, bpmnLane{ID: fmt.Sprintf("Lane_%d", i+1), Name: p.Label})
+	}
+
+	// 节点依次排列：开始事件、各步骤任务、结束事件；记录各节点所在泳道与左右连接点
+	type placed struct {
+		id          string
+		lane        int
+		left, right bpmnPoint
+	}
+	var nodes []placed
+	x := bpmnLaneHeader + bpmnGap
+	laneTop := func(lane int) int { return lane * bpmnLaneHeight }
+	addShape := func(id string, lane, w, h int) {
+		y := laneTop(lane) + (bpmnLaneHeight-h)/2
+		plane.Shapes = append(plane.Shapes, bpmnShape{ID: id + "_di", BPMNElement: id, Bounds: bpmnBounds{X: x, Y: y, Width: w, Height: h}})
+		mid := y + h/2
+		nodes = append(nodes, placed{id: id, lane: lane, left: bpmnPoint{x, mid}, right: bpmnPoint{x + w, mid}})
+		def.Process.LaneSet.Lanes[lane].NodeRefs = append(def.Process.LaneSet.Lanes[lane].NodeRefs, id)
+		x += w + bpmnGap
+	}
+
+	var steps []FlowNode
+	for _, n := range g.Nodes {
+		if n.Type == FlowStep {
+			steps = append(steps, n)
+		}
+	}
+	firstLane, lastLane := 0, 0
+	if len(steps) > 0 {
+		firstLane, lastLane = lanes[steps[0].Page], lanes[steps[len(steps)-1].Page]
+	}
+	addShape(def.Process.StartEvent.ID, firstLane, bpmnEventSize, bpmnEventSize)
+	for i, s := range steps {
+		task := bpmnNode{ID: fmt.Sprintf("Task_%d", i+1), Name: s.Label}
+		def.Process.Tasks = append(def.Process.Tasks, task)
+		addShape(task.ID, lanes[s.Page], bpmnTaskW, bpmnTaskH)
+	}
+	addShape(def.Process.EndEvent.ID, lastLane, bpmnEventSize, bpmnEventSize)
+
+	// 顺序流：相邻节点依次相连，跨泳道时先水平再垂直折线
+	for i := 1; i < len(nodes); i++ {
+		from, to := nodes[i-1], nodes[i]
+		flow := bpmnFlow{ID: fmt.Sprintf("Flow_%d", i), SourceRef: from.id, TargetRef: to.id}
+		def.Process.Flows = append(def.Process.Flows, flow)
+		points := []bpmnPoint{from.right, to.left}
+		if from.lane != to.lane {
+			midX := (from.right.X + to.left.X) / 2
+			points = []bpmnPoint{from.right, {midX, from.right.Y}, {midX, to.left.Y}, to.left}
+		}
+		plane.Edges = append(plane.Edges, bpmnEdge{ID: flow.ID + "_di", BPMNElement: flow.ID, Waypoints: points})
+	}
+
+	// 泳池与泳道的图形放在最前面，避免覆盖任务
+	width := x - bpmnLaneHeader
+	shapes := []bpmnShape{{
+		ID: "Participant_1_di", BPMNElement: "Participant_1", IsHorizontal: true,
+		Bounds: bpmnBounds{X: 0, Y: 0, Width: width + bpmnLaneHeader, Height: len(pages) * bpmnLaneHeight},
+	}}
+	for i, lane := range def.Process.LaneSet.Lanes {
+		shapes = append(shapes, bpmnShape{
+			ID: lane.ID + "_di", BPMNElement: lane.ID, IsHorizontal: true,
+			Bounds: bpmnBounds{X: bpmnLaneHeader, Y: laneTop(i), Width: width, Height: bpmnLaneHeight},
+		})
+	}
+	plane.Shapes = append(shapes, plane.Shapes...)
+
+	out, err := xml.MarshalIndent(def, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
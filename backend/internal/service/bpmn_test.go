@@ -0,0 +1,78 @@
+package service_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestExportBPMN(t *testing.T) {
+	steps := []db.RecordingStep{
+		{Action: "click", TargetElement: "缴费", PageURL: "https://gov.example.com/cases/1", PageTitle: "案件列表"},
+		{Action: "input", TargetElement: "金额", PageURL: "https://gov.example.com/pay", PageTitle: "缴费"},
+		{Action: "click", TargetElement: "确定", PageURL: "https://gov.example.com/pay"},
+	}
+	for i := range steps {
+		steps[i].ID, steps[i].StepIndex = string(rune('a'+i)), i+1
+	}
+	g := service.BuildFlowGraph(steps)
+	g.Title = "缴费 & 查询"
+	data, err := service.ExportBPMN(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), xml.Header) || !strings.Contains(string(data), `xmlns:bpmn="http://www.omg.org/spec/BPMN/20100524/MODEL"`) {
+		t.Fatalf("missing header or namespace:\n%s", data)
+	}
+
+	// 按元素本地名解析，检查泳道、任务与顺序流
+	var doc struct {
+		Process struct {
+			Name  string `xml:"name,attr"`
+			Lanes []struct {
+				Name string   `xml:"name,attr"`
+				Refs []string `xml:"flowNodeRef"`
+			} `xml:"laneSet>lane"`
+			Tasks []struct {
+				ID   string `xml:"id,attr"`
+				Name string `xml:"name,attr"`
+			} `xml:"userTask"`
+			Flows []struct {
+				Source string `xml:"sourceRef,attr"`
+				Target string `xml:"targetRef,attr"`
+			} `xml:"sequenceFlow"`
+		} `xml:"process"`
+		Shapes []struct {
+			Element string `xml:"bpmnElement,attr"`
+		} `xml:"BPMNDiagram>BPMNPlane>BPMNShape"`
+		Edges []struct {
+			Points []struct{} `xml:"waypoint"`
+		} `xml:"BPMNDiagram>BPMNPlane>BPMNEdge"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	p := doc.Process
+	if p.Name != "缴费 & 查询" || len(p.Lanes) != 2 || p.Lanes[0].Name != "案件列表" || p.Lanes[1].Name != "缴费" {
+		t.Fatalf("unexpected process %+v", p)
+	}
+	if strings.Join(p.Lanes[0].Refs, ",") != "StartEvent_1,Task_1" || strings.Join(p.Lanes[1].Refs, ",") != "Task_2,Task_3,EndEvent_1" {
+		t.Errorf("unexpected lane members %+v", p.Lanes)
+	}
+	if len(p.Tasks) != 3 || p.Tasks[1].Name != "录入【金额】" {
+		t.Errorf("unexpected tasks %+v", p.Tasks)
+	}
+	if len(p.Flows) != 4 || p.Flows[0].Source != "StartEvent_1" || p.Flows[3].Target != "EndEvent_1" {
+		t.Errorf("unexpected flows %+v", p.Flows)
+	}
+	// 泳池 + 2 条泳道 + 开始、3 个任务、结束；跨泳道的连线为折线
+	if len(doc.Shapes) != 8 || doc.Shapes[0].Element != "Participant_1" {
+		t.Errorf("unexpected shapes %+v", doc.Shapes)
+	}
+	if len(doc.Edges) != 4 || len(doc.Edges[1].Points) != 4 || len(doc.Edges[2].Points) != 2 {
+		t.Errorf("unexpected edges %+v", doc.Edges)
+	}
+}
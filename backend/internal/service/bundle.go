@@ -0,0 +1,186 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// BundleVersion 项目包格式版本
+const BundleVersion = 1
+
+// ErrBundleVersion 项目包版本不受支持
+var ErrBundleVersion = errors.New("unsupported bundle version")
+
+// ProjectBundle 项目包：项目及其全部会话、步骤、截图、文档，用于跨实例迁移
+type ProjectBundle struct {
+	Version    int               `json:"version"`
+	ExportedAt string            `json:"exported_at"`
+	Project    db.Project        `json:"project"`
+	Glossary   []db.GlossaryTerm `json:"glossary,omitempty"`
+	Sessions   []SessionBundle   `json:"sessions"`
+}
+
+// SessionBundle 项目包中的单个会话
+type SessionBundle struct {
+	Session     db.Session             `json:"session"`
+	Steps       []db.RecordingStep     `json:"steps"`
+	Screenshots []db.Screenshot        `json:"screenshots"`
+	Documents   []db.GeneratedDocument `json:"documents"`
+}
+
+// ExportProjectBundle 导出项目包
+func ExportProjectBundle(projectID string) (*ProjectBundle, error) {
+	var project db.Project
+	if err := db.DB.Preload("Tags").First(&project, "id = ?", projectID).Error; err != nil {
+		return nil, err
+	}
+	var sessions []db.Session
+	if err := db.DB.Preload("Tags").Where("project_id = ?", projectID).Order("created_at").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	bundle := &ProjectBundle{
+		Version:    BundleVersion,
+		ExportedAt: time.Now().Format(time.RFC3339),
+		Project:    project,
+		Glossary:   ProjectGlossary(projectID),
+		Sessions:   make([]SessionBundle, 0, len(sessions)),
+	}
+	for _, s := range sessions {
+		sb := SessionBundle{Session: s}
+		if err := db.DB.Where("session_id = ?", s.ID).Order("step_index").Find(&sb.Steps).Error; err != nil {
+			return nil, err
+		}
+		if err := db.DB.Where("session_id = ?", s.ID).Find(&sb.Screenshots).Error; err != nil {
+			return nil, err
+		}
+		if err := db.DB.Where("session_id = ?", s.ID).Order("created_at").Find(&sb.Documents).Error; err != nil {
+			return nil, err
+		}
+		bundle.Sessions = append(bundle.Sessions, sb)
+	}
+	return bundle, nil
+}
+
+// ImportProjectBundle 导入项目包为新项目（需在事务中调用）。
+// 所有记录分配新 ID 并重写相互引用，同一个包可重复导入而不冲突
+func ImportProjectBundle(tx *gorm.DB, bundle *ProjectBundle) (*db.Project, error) {
+	if bundle.Version != BundleVersion {
+		return nil, fmt.Errorf("%w: %d", ErrBundleVersion, bundle.Version)
+	}
+	ids := map[string]string{}
+	remap := func(old string) string {
+		if old == "" {
+			return ""
+		}
+		if id, ok := ids[old]; ok {
+			return id
+		}
+		id := uuid.New().String()
+		ids[old] = id
+		return id
+	}
+
+	project := bundle.Project
+	project.ID = remap(project.ID)
+	project.Sessions = nil
+	tags := tagNames(project.Tags)
+	project.Tags = nil
+	if err := tx.Create(&project).Error; err != nil {
+		return nil, err
+	}
+	if _, err := SetTags(tx, &project, tags); err != nil {
+		return nil, err
+	}
+	if _, err := SetGlossary(tx, project.ID, bundle.Glossary); err != nil {
+		return nil, err
+	}
+
+	for _, sb := range bundle.Sessions {
+		session := sb.Session
+		session.ID = remap(session.ID)
+		session.ProjectID = project.ID
+		session.GeneratedDocID = remap(session.GeneratedDocID)
+		session.Steps = nil
+		sessionTags := tagNames(session.Tags)
+		session.Tags = nil
+		for _, st := range sb.Steps {
+			if st.StepIndex > session.StepSeq {
+				session.StepSeq = st.StepIndex
+			}
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return nil, err
+		}
+		if _, err := SetTags(tx, &session, sessionTags); err != nil {
+			return nil, err
+		}
+
+		for _, st := range sb.Steps {
+			st.ID = remap(st.ID)
+			st.SessionID = session.ID
+			st.ScreenshotID = remap(st.ScreenshotID)
+			st.DuplicateOf = remap(st.DuplicateOf)
+			st.IdempotencyKey = nil // 幂等键是原会话客户端的重试标识，导入副本不继承
+			if err := tx.Create(&st).Error; err != nil {
+				return nil, err
+			}
+		}
+		for _, sc := range sb.Screenshots {
+			sc.ID = remap(sc.ID)
+			sc.SessionID = session.ID
+			sc.StepID = remap(sc.StepID)
+			if err := tx.Create(&sc).Error; err != nil {
+				return nil, err
+			}
+		}
+		for _, doc := range sb.Documents {
+			doc.ID = remap(doc.ID)
+			doc.SessionID = session.ID
+			doc.ProjectID = project.ID
+			var err error
+			if doc.BusinessView, err = remapDocView(doc.BusinessView, remap); err != nil {
+				return nil, fmt.Errorf("document %s: %w", doc.ID, err)
+			}
+			if doc.TechnicalView, err = remapDocView(doc.TechnicalView, remap); err != nil {
+				return nil, fmt.Errorf("document %s: %w", doc.ID, err)
+			}
+			if err := tx.Create(&doc).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &project, nil
+}
+
+// remapDocView 重写已保存文档视图中的截图引用
+func remapDocView(view db.LongText, remap func(string) string) (db.LongText, error) {
+	if view == "" {
+		return view, nil
+	}
+	var sections []DocSection
+	if err := json.Unmarshal([]byte(view), &sections); err != nil {
+		return "", err
+	}
+	for i := range sections {
+		for j := range sections[i].Steps {
+			sections[i].Steps[j].ScreenshotID = remap(sections[i].Steps[j].ScreenshotID)
+		}
+	}
+	b, err := json.Marshal(sections)
+	return db.LongText(b), err
+}
+
+func tagNames(tags []db.Tag) []string {
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.Name
+	}
+	return names
+}
@@ -0,0 +1,101 @@
+package service_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+func TestProjectBundleRoundTrip(t *testing.T) {
+	setupDB(t)
+	projectID, sessionID := seedSessionWithSteps(t, 3)
+
+	var steps []db.RecordingStep
+	db.DB.Where("session_id = ?", sessionID).Order("step_index").Find(&steps)
+	shot := db.Screenshot{SessionID: sessionID, StepID: steps[0].ID, DataURL: "data:image/png;base64,AAAA", CapturedAt: time.Now().UnixMilli()}
+	db.DB.Create(&shot)
+	db.DB.Model(&steps[0]).Update("screenshot_id", shot.ID)
+	key := "client-key-1"
+	db.DB.Model(&steps[1]).Update("idempotency_key", &key)
+
+	svc := service.NewDocService()
+	content, _ := svc.BuildDocument(sessionID)
+	doc, _ := svc.SaveGeneratedDoc(sessionID, content)
+	db.DB.Transaction(func(tx *gorm.DB) error {
+		var p db.Project
+		tx.First(&p, "id = ?", projectID)
+		if _, err := service.SetTags(tx, &p, []string{"财务部"}); err != nil {
+			return err
+		}
+		_, err := service.SetGlossary(tx, projectID, []db.GlossaryTerm{{Term: "操作员", Preferred: "经办人"}})
+		return err
+	})
+
+	bundle, err := service.ExportProjectBundle(projectID)
+	if err != nil {
+		t.Fatalf("ExportProjectBundle: %v", err)
+	}
+	// 经过 JSON 往返，模拟跨实例传输
+	raw, _ := json.Marshal(bundle)
+	var decoded service.ProjectBundle
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("decode bundle: %v", err)
+	}
+
+	// 同一个包导入两次都应成功
+	for i := 0; i < 2; i++ {
+		var imported *db.Project
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			var err error
+			imported, err = service.ImportProjectBundle(tx, &decoded)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("import #%d: %v", i+1, err)
+		}
+		if imported.ID == projectID {
+			t.Fatal("imported project should get a new ID")
+		}
+
+		var sessions []db.Session
+		db.DB.Preload("Tags").Where("project_id = ?", imported.ID).Find(&sessions)
+		if len(sessions) != 1 {
+			t.Fatalf("expected 1 imported session, got %d", len(sessions))
+		}
+		s := sessions[0]
+		var newDoc db.GeneratedDocument
+		if err := db.DB.First(&newDoc, "id = ?", s.GeneratedDocID).Error; err != nil || newDoc.ID == doc.ID {
+			t.Errorf("generated_doc_id should point at the imported document copy")
+		}
+		var newSteps []db.RecordingStep
+		db.DB.Where("session_id = ?", s.ID).Order("step_index").Find(&newSteps)
+		if len(newSteps) != 3 || newSteps[1].IdempotencyKey != nil {
+			t.Fatalf("unexpected imported steps: %d", len(newSteps))
+		}
+		var newShot db.Screenshot
+		if err := db.DB.First(&newShot, "id = ?", newSteps[0].ScreenshotID).Error; err != nil || newShot.StepID != newSteps[0].ID {
+			t.Errorf("screenshot reference not remapped: %+v", newShot)
+		}
+		if strings.Contains(string(newDoc.BusinessView), shot.ID) {
+			t.Error("document view still references the original screenshot ID")
+		}
+		var p db.Project
+		db.DB.Preload("Tags").First(&p, "id = ?", imported.ID)
+		if len(p.Tags) != 1 || p.Tags[0].Name != "财务部" {
+			t.Errorf("project tags not imported: %+v", p.Tags)
+		}
+		if g := service.ProjectGlossary(imported.ID); len(g) != 1 || g[0].Preferred != "经办人" {
+			t.Errorf("project glossary not imported: %+v", g)
+		}
+	}
+
+	decoded.Version = 99
+	if _, err := service.ImportProjectBundle(db.DB, &decoded); err == nil {
+		t.Error("expected error for unsupported bundle version")
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// 记录之间的关联不在数据库中声明外键（SQLite 旧库无法追加约束，备份恢复也按表顺序导入），
+// 删除时由服务层逐级删除下级记录：项目 → 会话 → 步骤 → 截图等，见 DeleteProjects / DeleteSessions / RemoveSteps；
+// 历史版本遗留的孤儿记录由 CleanupOrphans 清理
+
+// DeleteProjects 删除项目及其全部会话（连同会话下的记录，见 DeleteSessions）、合订手册、术语表与标签关联；
+// 返回被删除的会话 ID，供事务提交后删除附件文件（需在事务中调用）
+func DeleteProjects(tx *gorm.DB, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var sessionIDs []string
+	if err := tx.Model(&db.Session{}).Where("project_id IN ?", ids).Pluck("id", &sessionIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := DeleteSessions(tx, sessionIDs); err != nil {
+		return nil, err
+	}
+	var compiled []string
+	if err := tx.Model(&db.CompiledDocument{}).Where("project_id IN ?", ids).Pluck("id", &compiled).Error; err != nil {
+		return nil, err
+	}
+	if err := DeleteCompiledDocuments(tx, compiled); err != nil {
+		return nil, err
+	}
+	if err := tx.Table("project_tags").Where("project_id IN ?", ids).Delete(nil).Error; err != nil {
+		return nil, err
+	}
+	for _, model := range []interface{}{&db.GlossaryTerm{}, &db.GeneratedDocument{}} {
+		if err := tx.Where("project_id IN ?", ids).Delete(model).Error; err != nil {
+			return nil, err
+		}
+	}
+	return sessionIDs, tx.Where("id IN ?", ids).Delete(&db.Project{}).Error
+}
+
+// OrphanResult 孤儿记录清理结果：按表统计删除的行数
+type OrphanResult struct {
+	Removed map[string]int64 `json:"removed"`
+	Total   int64            `json:"total"`
+	// 被删除的会话及附件记录所属的会话 ID，供事务提交后删除附件文件
+	SessionIDs []string `json:"-"`
+}
+
+func (r *OrphanResult) add(table string, n int64) {
+	if n > 0 {
+		r.Removed[table] += n
+		r.Total += n
+	}
+}
+
+// orphanRule 下级记录 model 的 column 列引用的上级记录（parent 的 id）不存在时即为孤儿；
+// 按上级在前的顺序排列，上级被清理后其下级在同一轮中一并清理
+type orphanRule struct {
+	model  interface{}
+	column string
+	parent interface{}
+	where  string // 附加条件，如只检查某类来源的检索索引
+	args   []interface{}
+}
+
+var orphanRules = []orphanRule{
+	{model: &db.RecordingStep{}, column: "session_id", parent: &db.Session{}},
+	{model: &db.Screenshot{}, column: "step_id", parent: &db.RecordingStep{}},
+	{model: &db.StepRequest{}, column: "step_id", parent: &db.RecordingStep{}},
+	{model: &db.StepLog{}, column: "step_id", parent: &db.RecordingStep{}},
+	{model: &db.MaskingEvent{}, column: "step_id", parent: &db.RecordingStep{}},
+	{model: &db.OutputValidationFailure{}, column: "step_id", parent: &db.RecordingStep{}, where: "step_id <> ?", args: []interface{}{""}},
+	{model: &db.GeneratedDocument{}, column: "session_id", parent: &db.Session{}},
+	{model: &db.DocumentShare{}, column: "document_id", parent: &db.GeneratedDocument{}},
+	{model: &db.SessionMedia{}, column: "session_id", parent: &db.Session{}},
+	{model: &db.ReplayRun{}, column: "session_id", parent: &db.Session{}},
+	{model: &db.ReplayStep{}, column: "run_id", parent: &db.ReplayRun{}},
+	{model: &db.CompiledDocument{}, column: "project_id", parent: &db.Project{}},
+	{model: &db.CompiledChapter{}, column: "compiled_id", parent: &db.CompiledDocument{}},
+	{model: &db.CompiledChapter{}, column: "session_id", parent: &db.Session{}},
+	{model: &db.ChatConversation{}, column: "session_id", parent: &db.Session{}},
+	{model: &db.ChatMessage{}, column: "conversation_id", parent: &db.ChatConversation{}},
+	{model: &db.Embedding{}, column: "source_id", parent: &db.RecordingStep{}, where: "source_type = ?", args: []interface{}{EmbedStep}},
+	{model: &db.Embedding{}, column: "source_id", parent: &db.GeneratedDocument{}, where: "source_type = ?", args: []interface{}{EmbedDocument}},
+	{model: &db.ExportJob{}, column: "source_id", parent: &db.GeneratedDocument{}, where: "source_type = ?", args: []interface{}{ExportSourceDocument}},
+	{model: &db.ExportJob{}, column: "source_id", parent: &db.CompiledDocument{}, where: "source_type = ?", args: []interface{}{ExportSourceCompiled}},
+	{model: &db.GlossaryTerm{}, column: "project_id", parent: &db.Project{}},
+	{model: &db.MaskingRule{}, column: "profile_id", parent: &db.MaskingProfile{}},
+}
+
+// orphanJoins 标签关联表：所属记录或标签不存在的行
+var orphanJoins = []struct {
+	table, column string
+	parent        interface{}
+}{
+	{"project_tags", "project_id", &db.Project{}},
+	{"project_tags", "tag_id", &db.Tag{}},
+	{"session_tags", "session_id", &db.Session{}},
+	{"session_tags", "tag_id", &db.Tag{}},
+}
+
+// CleanupOrphans 清理上级记录已不存在的孤儿记录（历史版本删除项目时未删除其会话等）：所属项目不存在的会话按 DeleteSessions
+// 连同下级记录删除，其余按 orphanRules 逐表删除（需在事务中调用）
+func CleanupOrphans(tx *gorm.DB) (*OrphanResult, error) {
+	result := &OrphanResult{Removed: map[string]int64{}}
+
+	var sessionIDs []string
+	if err := tx.Model(&db.Session{}).Where("project_id NOT IN (?)", tx.Model(&db.Project{}).Select("id")).
+		Pluck("id", &sessionIDs).Error; err != nil {
+		return nil, err
+	}
+	if err := DeleteSessions(tx, sessionIDs); err != nil {
+		return nil, err
+	}
+	result.add("sessions", int64(len(sessionIDs)))
+	result.SessionIDs = sessionIDs
+
+	var mediaSessions []string
+	if err := tx.Model(&db.SessionMedia{}).Distinct("session_id").
+		Where("session_id NOT IN (?)", tx.Model(&db.Session{}).Select("id")).Pluck("session_id", &mediaSessions).Error; err != nil {
+		return nil, err
+	}
+	result.SessionIDs = append(result.SessionIDs, mediaSessions...)
+
+	for _, rule := range orphanRules {
+		sch, err := parseSchema(rule.model)
+		if err != nil {
+			return nil, err
+		}
+		q := tx.Where(rule.column+" NOT IN (?)", tx.Model(rule.parent).Select("id"))
+		if rule.where != "" {
+			q = q.Where(rule.where, rule.args...)
+		}
+		res := q.Delete(rule.model)
+		if res.Error != nil {
+			return nil, fmt.Errorf("%s.%s: %w", sch.Table, rule.column, res.Error)
+		}
+		result.add(sch.Table, res.RowsAffected)
+	}
+	for _, join := range orphanJoins {
+		res := tx.Table(join.table).Where(join.column+" NOT IN (?)", tx.Model(join.parent).Select("id")).Delete(nil)
+		if res.Error != nil {
+			return nil, fmt.Errorf("%s.%s: %w", join.table, join.column, res.Error)
+		}
+		result.add(join.table, res.RowsAffected)
+	}
+	return result, nil
+}
@@ -0,0 +1,115 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+func countRows(t *testing.T, model interface{}, query string, args ...interface{}) int64 {
+	t.Helper()
+	var n int64
+	db.DB.Model(model).Where(query, args...).Count(&n)
+	return n
+}
+
+func TestDeleteProjects_Cascades(t *testing.T) {
+	setupDB(t)
+	projectID, sessionID := seedSessionWithSteps(t, 2)
+	var step db.RecordingStep
+	db.DB.First(&step, "session_id = ?", sessionID)
+	db.DB.Create(&db.Screenshot{SessionID: sessionID, StepID: step.ID, DataURL: "data:image/png;base64,AAAA"})
+	db.DB.Create(&db.GeneratedDocument{SessionID: sessionID, ProjectID: projectID})
+	db.DB.Create(&db.GlossaryTerm{ProjectID: projectID, Term: "操作员", Preferred: "经办人"})
+	compiled := db.CompiledDocument{ProjectID: projectID, Title: "合订本"}
+	db.DB.Create(&compiled)
+	db.DB.Create(&db.CompiledChapter{CompiledID: compiled.ID, SessionID: sessionID, Position: 1})
+	db.DB.Create(&db.OutputValidationFailure{Provider: "ollama", SessionID: sessionID, StepID: step.ID})
+	other, _ := seedSessionWithSteps(t, 1)
+
+	var sessionIDs []string
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		sessionIDs, err = service.DeleteProjects(tx, []string{projectID})
+		return err
+	})
+	if err != nil || len(sessionIDs) != 1 || sessionIDs[0] != sessionID {
+		t.Fatalf("DeleteProjects: %v %v", sessionIDs, err)
+	}
+	checks := []struct {
+		model interface{}
+		query string
+		arg   string
+	}{
+		{&db.Session{}, "project_id = ?", projectID},
+		{&db.RecordingStep{}, "session_id = ?", sessionID},
+		{&db.Screenshot{}, "session_id = ?", sessionID},
+		{&db.GeneratedDocument{}, "project_id = ?", projectID},
+		{&db.GlossaryTerm{}, "project_id = ?", projectID},
+		{&db.CompiledChapter{}, "compiled_id = ?", compiled.ID},
+		{&db.OutputValidationFailure{}, "session_id = ?", sessionID},
+	}
+	for _, c := range checks {
+		if n := countRows(t, c.model, c.query, c.arg); n != 0 {
+			t.Errorf("%T: %d rows left", c.model, n)
+		}
+	}
+	if countRows(t, &db.Session{}, "project_id = ?", other) != 1 {
+		t.Error("other project's sessions should be kept")
+	}
+}
+
+func TestCleanupOrphans(t *testing.T) {
+	setupDB(t)
+	projectID, sessionID := seedSessionWithSteps(t, 1)
+	var step db.RecordingStep
+	db.DB.First(&step, "session_id = ?", sessionID)
+	db.DB.Create(&db.Screenshot{SessionID: sessionID, StepID: step.ID})
+
+	// 历史版本删除项目后遗留的会话及其步骤，以及指向已删除步骤的截图
+	orphan := db.Session{ProjectID: "deleted-project", Title: "遗留"}
+	db.DB.Create(&orphan)
+	db.DB.Create(&db.RecordingStep{SessionID: orphan.ID, StepIndex: 1, Action: "click"})
+	db.DB.Create(&db.Screenshot{SessionID: sessionID, StepID: "deleted-step"})
+	db.DB.Create(&db.ChatMessage{ConversationID: "deleted-chat", SessionID: sessionID, Role: "user"})
+	db.DB.Exec("INSERT INTO session_tags (session_id, tag_id) VALUES (?, ?)", sessionID, "deleted-tag")
+	db.DB.Create(&db.OutputValidationFailure{Provider: "ollama", SessionID: sessionID, StepID: "deleted-step"})
+	db.DB.Create(&db.OutputValidationFailure{Provider: "ollama"}) // 纯文本生成任务，不属于任何步骤
+
+	var res *service.OrphanResult
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		res, err = service.CleanupOrphans(tx)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{"sessions": 1, "screenshots": 1, "chat_messages": 1, "session_tags": 1, "output_validation_failures": 1}
+	for table, n := range want {
+		if res.Removed[table] != n {
+			t.Errorf("%s: removed %d, want %d (%v)", table, res.Removed[table], n, res.Removed)
+		}
+	}
+	if res.Total != 5 || len(res.SessionIDs) != 1 {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if countRows(t, &db.RecordingStep{}, "session_id = ?", orphan.ID) != 0 {
+		t.Error("steps of the orphaned session should be removed")
+	}
+	if countRows(t, &db.Screenshot{}, "step_id = ?", step.ID) != 1 || countRows(t, &db.Session{}, "project_id = ?", projectID) != 1 ||
+		countRows(t, &db.OutputValidationFailure{}, "step_id = ?", "") != 1 {
+		t.Error("records with existing parents should be kept")
+	}
+
+	// 再次执行没有可清理的记录
+	db.DB.Transaction(func(tx *gorm.DB) error {
+		res, err = service.CleanupOrphans(tx)
+		return err
+	})
+	if err != nil || res.Total != 0 {
+		t.Errorf("second run should remove nothing: %+v %v", res, err)
+	}
+}
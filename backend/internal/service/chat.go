@@ -0,0 +1,181 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+const (
+	chatContextSteps    = 60 // 提示词中最多列出的步骤数，超出时按问题检索相关步骤
+	chatHistoryMessages = 10 // 提示词中保留的最近消息数
+)
+
+// 对话消息角色
+const (
+	ChatRoleUser      = "user"
+	ChatRoleAssistant = "assistant"
+)
+
+// ErrChatNotFound 对话不存在或不属于该会话
+var ErrChatNotFound = errors.New("conversation not found")
+
+// ChatCitation 回答引用的步骤
+type ChatCitation struct {
+	StepID    string `json:"step_id"`
+	StepIndex int    `json:"step_index"`
+	Text      string `json:"text"`
+}
+
+// ChatReply 一轮问答的结果
+type ChatReply struct {
+	ConversationID string         `json:"conversation_id"`
+	Question       db.ChatMessage `json:"question"`
+	Answer         db.ChatMessage `json:"answer"`
+	Citations      []ChatCitation `json:"citations"`
+}
+
+// Chat 针对单个会话的多轮问答：把会话步骤与最近的对话记录交给模型，回答中以 [n] 标注所依据的步骤编号；
+// conversationID 为空时新建对话。问答成功后才保存提问与回答，没有可用模型时返回 ErrNoProvider
+func (s *AIService) Chat(sessionID, conversationID, message string) (*ChatReply, error) {
+	var session db.Session
+	if err := db.DB.First(&session, "id = ?", sessionID).Error; err != nil {
+		return nil, err
+	}
+	conv := db.ChatConversation{SessionID: sessionID, Title: message}
+	var history []db.ChatMessage
+	if conversationID != "" {
+		if err := db.DB.First(&conv, "id = ? AND session_id = ?", conversationID, sessionID).Error; err != nil {
+			return nil, ErrChatNotFound
+		}
+		// 同一时间戳的提问排在回答之前（倒序取出后再翻转）
+		if err := db.DB.Where("conversation_id = ?", conv.ID).Order("created_at DESC, role").
+			Limit(chatHistoryMessages).Find(&history).Error; err != nil {
+			return nil, err
+		}
+		for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+			history[i], history[j] = history[j], history[i]
+		}
+	}
+
+	steps, err := chatSteps(sessionID, message)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.GenerateText(buildChatPrompt(session.Title, steps, history, message))
+	if err != nil {
+		return nil, err
+	}
+
+	reply := &ChatReply{Citations: []ChatCitation{}}
+	byIndex := make(map[int]db.RecordingStep, len(steps))
+	for _, st := range steps {
+		byIndex[st.StepIndex] = st
+	}
+	var stepIDs db.StringList
+	for _, m := range citationRe.FindAllStringSubmatch(resp.Description, -1) {
+		n, _ := strconv.Atoi(m[1])
+		st, ok := byIndex[n]
+		if !ok {
+			continue
+		}
+		delete(byIndex, n)
+		stepIDs = append(stepIDs, st.ID)
+		reply.Citations = append(reply.Citations, ChatCitation{StepID: st.ID, StepIndex: st.StepIndex, Text: st.AIDescription})
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		if conv.ID == "" {
+			if err := tx.Create(&conv).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Model(&conv).Update("updated_at", time.Now()).Error; err != nil {
+			return err
+		}
+		reply.Question = db.ChatMessage{ConversationID: conv.ID, SessionID: sessionID, Role: ChatRoleUser, Content: message}
+		if err := tx.Create(&reply.Question).Error; err != nil {
+			return err
+		}
+		reply.Answer = db.ChatMessage{
+			ConversationID: conv.ID, SessionID: sessionID, Role: ChatRoleAssistant, Content: resp.Description,
+			StepIDs: stepIDs, Provider: resp.Provider, Model: resp.Model,
+		}
+		return tx.Create(&reply.Answer).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	reply.ConversationID = conv.ID
+	return reply, nil
+}
+
+// chatSteps 提示词中列出的步骤（已排除与没有描述的步骤除外）；超过 chatContextSteps 时
+// 优先按问题语义检索相关步骤，未启用语义检索时取前 chatContextSteps 步
+func chatSteps(sessionID, question string) ([]db.RecordingStep, error) {
+	var all []db.RecordingStep
+	if err := db.DB.Select("ID", "StepIndex", "PageTitle", "AIDescription").
+		Where("session_id = ? AND excluded = ?", sessionID, false).
+		Order("step_index").Find(&all).Error; err != nil {
+		return nil, err
+	}
+	steps := all[:0]
+	for _, st := range all {
+		if st.AIDescription != "" {
+			steps = append(steps, st)
+		}
+	}
+	if len(steps) <= chatContextSteps {
+		return steps, nil
+	}
+	hits, err := SemanticSearch(question, "", sessionID, EmbedStep, chatContextSteps)
+	if err != nil || len(hits) == 0 {
+		return steps[:chatContextSteps], nil
+	}
+	relevant := make(map[string]bool, len(hits))
+	for _, h := range hits {
+		relevant[h.StepID] = true
+	}
+	picked := steps[:0]
+	for _, st := range steps {
+		if relevant[st.ID] {
+			picked = append(picked, st)
+		}
+	}
+	return picked, nil
+}
+
+func buildChatPrompt(title string, steps []db.RecordingStep, history []db.ChatMessage, message string) string {
+	var sb strings.Builder
+	for _, st := range steps {
+		sb.WriteString(fmt.Sprintf("[%d] ", st.StepIndex))
+		if st.PageTitle != "" {
+			sb.WriteString(st.PageTitle + "：")
+		}
+		sb.WriteString(strings.ReplaceAll(st.AIDescription, "\n", " / ") + "\n")
+	}
+	if len(steps) == 0 {
+		sb.WriteString("（暂无步骤说明）\n")
+	}
+	var hb strings.Builder
+	for _, m := range history {
+		who := "用户"
+		if m.Role == ChatRoleAssistant {
+			who = "助手"
+		}
+		hb.WriteString(fmt.Sprintf("%s：%s\n", who, strings.TrimSpace(m.Content)))
+	}
+	if hb.Len() > 0 {
+		hb.WriteString("\n")
+	}
+	return fmt.Sprintf(`%s你正在为《%s》的操作手册解答用户的问题。只使用下面的操作步骤与之前的对话作答，步骤中没有相关说明时直接说明，不要编造。
+用简洁的中文回答，涉及具体操作时在句末用方括号标注所依据的步骤编号，如 [3]。
+
+操作步骤：
+%s
+%s用户：%s`, assistantPreamble(), title, sb.String(), hb.String(), message)
+}
@@ -0,0 +1,69 @@
+package service_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestChat(t *testing.T) {
+	setupDB(t)
+	sess := db.Session{Title: "补打回执"}
+	db.DB.Create(&sess)
+	steps := []db.RecordingStep{
+		{AIDescription: "在【交易记录】中找到需要补打的订单", PageTitle: "交易记录"},
+		{AIDescription: "点击【补打回执】按钮打印回执", PageTitle: "订单详情"},
+		{AIDescription: "误点了帮助链接", Excluded: true},
+	}
+	for i := range steps {
+		steps[i].SessionID, steps[i].StepIndex = sess.ID, i+1
+		db.DB.Create(&steps[i])
+	}
+
+	var prompts []string
+	aiSvc := fakeOllama(t, func(p string) string {
+		prompts = append(prompts, p)
+		if len(prompts) == 1 {
+			return "先在交易记录中找到订单 [1]，再点击【补打回执】 [2]。"
+		}
+		return "打印机需要已连接，回执会从默认打印机输出 [2] [9]。"
+	})
+
+	first, err := aiSvc.Chat(sess.ID, "", "如何补打回执？")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ConversationID == "" || first.Answer.Model != "test-model" || len(first.Citations) != 2 ||
+		first.Citations[1].StepID != steps[1].ID {
+		t.Fatalf("unexpected reply %+v", first)
+	}
+	if !strings.Contains(prompts[0], "[2] 订单详情：点击【补打回执】按钮打印回执") || strings.Contains(prompts[0], "帮助链接") {
+		t.Errorf("prompt should list non-excluded steps:\n%s", prompts[0])
+	}
+
+	// 追问时带上之前的对话；不存在的步骤编号不计入引用
+	second, err := aiSvc.Chat(sess.ID, first.ConversationID, "打印机要怎么设置？")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(prompts[1], "用户：如何补打回执？\n助手：先在交易记录中找到订单") {
+		t.Errorf("prompt missing history:\n%s", prompts[1])
+	}
+	if second.ConversationID != first.ConversationID || len(second.Citations) != 1 || len(second.Answer.StepIDs) != 1 {
+		t.Errorf("unexpected follow-up %+v", second)
+	}
+	var count int64
+	db.DB.Model(&db.ChatMessage{}).Where("conversation_id = ?", first.ConversationID).Count(&count)
+	if count != 4 {
+		t.Errorf("expected 4 stored messages, got %d", count)
+	}
+
+	other := db.Session{Title: "其他"}
+	db.DB.Create(&other)
+	if _, err := aiSvc.Chat(other.ID, first.ConversationID, "你好"); !errors.Is(err, service.ErrChatNotFound) {
+		t.Errorf("expected ErrChatNotFound for another session's conversation, got %v", err)
+	}
+}
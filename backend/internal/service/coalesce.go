@@ -0,0 +1,69 @@
+package service
+
+import (
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// CanCoalesceInput 判断 next 能否并入上一步 prev：两者都是输入操作，目标元素（选择器，其次 XPath）、页面、
+// 标签页与 iframe 相同，时间间隔不超过 windowMS，且上一步尚未生成或编辑描述；windowMS 为 0 表示关闭合并
+func CanCoalesceInput(prev, next *db.RecordingStep, windowMS int64) bool {
+	if windowMS <= 0 || prev == nil || prev.Action != "input" || next.Action != "input" {
+		return false
+	}
+	if prev.AIDescription != "" || prev.IsEdited {
+		return false
+	}
+	switch {
+	case next.TargetSelector != "":
+		if prev.TargetSelector != next.TargetSelector {
+			return false
+		}
+	case next.TargetXPath != "":
+		if prev.TargetXPath != next.TargetXPath {
+			return false
+		}
+	default:
+		return false
+	}
+	if prev.PageURL != next.PageURL || prev.TabID != next.TabID || prev.FramePath != next.FramePath {
+		return false
+	}
+	if prev.Timestamp != 0 && next.Timestamp != 0 {
+		diff := next.Timestamp - prev.Timestamp
+		return diff >= 0 && diff <= windowMS
+	}
+	return true
+}
+
+// coalesceInput 把输入事件并入上一步：以最终（已脱敏）的值、时间戳与截图覆盖上一步，
+// 网络请求、控制台日志与脱敏审计追加到上一步（需在事务中调用）
+func coalesceInput(tx *gorm.DB, prev *db.RecordingStep, in StepInput) error {
+	s := in.Step
+	err := tx.Model(prev).Select("MaskedText", "InputValue", "IsMasked", "Timestamp").
+		Updates(db.RecordingStep{
+			MaskedText: s.MaskedText,
+			InputValue: s.InputValue,
+			IsMasked:   s.IsMasked,
+			Timestamp:  s.Timestamp,
+		}).Error
+	if err != nil {
+		return err
+	}
+	if in.Screenshot != nil {
+		shot := *in.Screenshot
+		if err := AttachScreenshot(tx, prev, &shot); err != nil {
+			return err
+		}
+	}
+	if _, err := AttachStepRequests(tx, prev, in.Requests); err != nil {
+		return err
+	}
+	if _, err := AttachStepLogs(tx, prev, in.Logs); err != nil {
+		return err
+	}
+	if err := AttachMaskingEvents(tx, prev, in.MaskingEvents); err != nil {
+		return err
+	}
+	return RecomputeTiming(tx, prev.SessionID)
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// ErrChapterWithoutDocument 合订手册的章节对应的会话尚未生成文档
+var ErrChapterWithoutDocument = errors.New("session has no generated document")
+
+// ErrInvalidChapters 章节引用的会话不存在、不属于该项目或重复
+var ErrInvalidChapters = errors.New("invalid chapters")
+
+// SetCompiledChapters 按给定顺序整体替换合订手册的章节（需在事务中调用），保存各章的标题、分组与排除标记，
+// 重新汇编时按相同配置输出；会话必须属于手册所在项目且不能重复
+func SetCompiledChapters(tx *gorm.DB, compiled *db.CompiledDocument, in []db.CompiledChapter) ([]db.CompiledChapter, error) {
+	seen := map[string]bool{}
+	for _, ch := range in {
+		id := ch.SessionID
+		if seen[id] {
+			return nil, fmt.Errorf("%w: session %s is listed twice", ErrInvalidChapters, id)
+		}
+		seen[id] = true
+		var count int64
+		if err := tx.Model(&db.Session{}).Where("id = ? AND project_id = ?", id, compiled.ProjectID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("%w: session %s does not belong to the project", ErrInvalidChapters, id)
+		}
+	}
+	if err := tx.Where("compiled_id = ?", compiled.ID).Delete(&db.CompiledChapter{}).Error; err != nil {
+		return nil, err
+	}
+	chapters := make([]db.CompiledChapter, 0, len(in))
+	for i, ch := range in {
+		chapters = append(chapters, db.CompiledChapter{
+			CompiledID: compiled.ID, SessionID: ch.SessionID, Position: i + 1,
+			Title: strings.TrimSpace(ch.Title), GroupTitle: strings.TrimSpace(ch.GroupTitle), Excluded: ch.Excluded,
+		})
+	}
+	if len(chapters) > 0 {
+		if err := tx.Create(&chapters).Error; err != nil {
+			return nil, err
+		}
+	}
+	return chapters, nil
+}
+
+// DeleteCompiledDocuments 删除合订手册及其章节与导出任务（需在事务中调用）
+func DeleteCompiledDocuments(tx *gorm.DB, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Where("compiled_id IN ?", ids).Delete(&db.CompiledChapter{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("source_type = ? AND source_id IN ?", ExportSourceCompiled, ids).Delete(&db.ExportJob{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN ?", ids).Delete(&db.CompiledDocument{}).Error
+}
+
+// CompileDocument 把合订手册的各章汇编为一份文档内容，可按单篇文档的方式导出（md / mdzip / json）：
+// 每章先插入章标题，随后是该会话最新文档的各章节；相邻的同组章节前插入一次分组标题，已排除的章节跳过；
+// 章与步骤序号在全书范围内连续编号，封面说明与目录写在前言中；编号样式、标题级别、模板与自定义字段使用项目设置
+func (s *DocService) CompileDocument(compiled *db.CompiledDocument) (*GeneratedDocContent, error) {
+	var project db.Project
+	if err := db.DB.First(&project, "id = ?", compiled.ProjectID).Error; err != nil {
+		return nil, err
+	}
+	var chapters []db.CompiledChapter
+	if err := db.DB.Where("compiled_id = ?", compiled.ID).Order("position").Find(&chapters).Error; err != nil {
+		return nil, err
+	}
+
+	content := &GeneratedDocContent{
+		SessionTitle:  compiled.Title,
+		ProjectName:   project.Name,
+		GeneratedAt:   time.Now().Format("2006-01-02 15:04:05"),
+		Metadata:      MergeMetadata(project.Metadata, nil),
+		ShowTiming:    project.ShowTiming,
+		Numbering:     project.NumberingStyle,
+		HeadingBase:   project.HeadingBase,
+		Watermark:     CurrentWatermark(),
+		BusinessView:  []DocSection{},
+		TechnicalView: []DocSection{},
+	}
+	if t, ok := FindTemplate(project.TemplateType); ok {
+		ApplyTemplate(content, t)
+	}
+
+	var toc []string
+	offset, number, group := 0, 0, ""
+	for _, ch := range chapters {
+		if ch.Excluded {
+			continue
+		}
+		var session db.Session
+		if err := db.DB.First(&session, "id = ?", ch.SessionID).Error; err != nil {
+			return nil, err
+		}
+		title := ch.Title
+		if title == "" {
+			title = session.Title
+		}
+		var doc db.GeneratedDocument
+		if session.GeneratedDocID == "" || db.DB.First(&doc, "id = ?", session.GeneratedDocID).Error != nil {
+			return nil, fmt.Errorf("%w: %s", ErrChapterWithoutDocument, title)
+		}
+		part, err := s.LoadDocument(&doc)
+		if err != nil {
+			return nil, err
+		}
+
+		var headings []DocSection
+		if ch.GroupTitle != group {
+			// 离开分组时插入无标题的分组标记，后续未分组的章恢复原有层级
+			group = ch.GroupTitle
+			headings = append(headings, DocSection{Kind: SectionGroup, Title: group})
+			if group != "" {
+				toc = append(toc, "- **"+group+"**")
+			}
+		}
+		number++
+		heading := DocSection{Kind: SectionChapter, Title: fmt.Sprintf("第 %d 章 %s", number, title)}
+		headings = append(headings, heading)
+		indent := ""
+		if group != "" {
+			indent = "  "
+		}
+		toc = append(toc, indent+"- "+heading.Title)
+		last := offset
+		for _, view := range []struct {
+			dst *[]DocSection
+			src []DocSection
+		}{{&content.BusinessView, part.BusinessView}, {&content.TechnicalView, part.TechnicalView}} {
+			*view.dst = append(*view.dst, headings...)
+			for _, sec := range view.src {
+				steps := make([]DocStep, len(sec.Steps))
+				for j, st := range sec.Steps {
+					st.StepIndex += offset
+					last = max(last, st.StepIndex)
+					steps[j] = st
+				}
+				sec.Steps = steps
+				*view.dst = append(*view.dst, sec)
+			}
+		}
+		offset = last
+		content.DurationMS += part.DurationMS
+		content.Media = append(content.Media, part.Media...)
+	}
+	for _, view := range [][]DocSection{content.BusinessView, content.TechnicalView} {
+		for i := range view {
+			view[i].SectionIndex = i + 1
+		}
+	}
+
+	var cover strings.Builder
+	if compiled.Description != "" {
+		cover.WriteString(strings.TrimSpace(compiled.Description) + "\n\n")
+	}
+	if len(toc) > 0 {
+		cover.WriteString("**目录**\n\n" + strings.Join(toc, "\n") + "\n\n")
+	}
+	content.Preface = strings.TrimSpace(cover.String() + content.Preface)
+	return content, nil
+}
@@ -0,0 +1,148 @@
+package service_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+// chapters 按顺序引用会话的章节配置
+func chapters(sessionIDs ...string) []db.CompiledChapter {
+	out := make([]db.CompiledChapter, len(sessionIDs))
+	for i, id := range sessionIDs {
+		out[i].SessionID = id
+	}
+	return out
+}
+
+func TestCompileDocument(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "不动产登记", NumberingStyle: service.NumberingHierarchical}
+	db.DB.Create(&proj)
+	docSvc := service.NewDocService()
+	newSession := func(title string, pages ...string) string {
+		sess := db.Session{ProjectID: proj.ID, Title: title}
+		db.DB.Create(&sess)
+		for i, page := range pages {
+			db.DB.Create(&db.RecordingStep{SessionID: sess.ID, StepIndex: i + 1, Action: "click",
+				TargetElement: "下一步", PageURL: "https://gov.example.com/" + page, PageTitle: page})
+		}
+		return sess.ID
+	}
+	login := newSession("登录", "login")
+	apply := newSession("提交申请", "form", "confirm")
+	for _, id := range []string{login, apply} {
+		content, err := docSvc.BuildDocument(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := docSvc.SaveGeneratedDoc(id, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	compiled := db.CompiledDocument{ProjectID: proj.ID, Title: "办事指南", Description: "面向窗口人员的操作手册"}
+	db.DB.Create(&compiled)
+	other := db.Project{Name: "其他"}
+	db.DB.Create(&other)
+	foreign := db.Session{ProjectID: other.ID, Title: "外部"}
+	db.DB.Create(&foreign)
+	if _, err := service.SetCompiledChapters(db.DB, &compiled, chapters(login, foreign.ID)); !errors.Is(err, service.ErrInvalidChapters) {
+		t.Errorf("expected ErrInvalidChapters for a foreign session, got %v", err)
+	}
+	if _, err := service.SetCompiledChapters(db.DB, &compiled, chapters(login, login)); !errors.Is(err, service.ErrInvalidChapters) {
+		t.Errorf("expected ErrInvalidChapters for a duplicate session, got %v", err)
+	}
+	if _, err := service.SetCompiledChapters(db.DB, &compiled, chapters(apply, login)); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := docSvc.CompileDocument(&compiled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds, titles []string
+	var indexes []int
+	for _, sec := range content.BusinessView {
+		kinds = append(kinds, sec.Kind)
+		titles = append(titles, sec.Title)
+		for _, st := range sec.Steps {
+			indexes = append(indexes, st.StepIndex)
+		}
+	}
+	if kinds[0] != service.SectionChapter || titles[0] != "第 1 章 提交申请" || len(kinds) != 5 || kinds[3] != service.SectionChapter || titles[3] != "第 2 章 登录" {
+		t.Fatalf("unexpected sections %q %q", kinds, titles)
+	}
+	// 第二章的步骤接着第一章编号
+	if len(indexes) != 3 || indexes[0] != 1 || indexes[1] != 2 || indexes[2] != 3 {
+		t.Errorf("expected continuous step numbers, got %v", indexes)
+	}
+	if !strings.Contains(content.Preface, "面向窗口人员的操作手册") || !strings.Contains(content.Preface, "- 第 2 章 登录") {
+		t.Errorf("expected cover and table of contents in preface: %q", content.Preface)
+	}
+
+	md := docSvc.GenerateMarkdown(content, "business")
+	for _, want := range []string{"# 办事指南\n", "## 第 1 章 提交申请\n", "### 1 form - 操作说明\n", "#### 1.1\n", "### 3 login - 操作说明\n", "#### 3.1\n"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+
+	// 尚未生成文档的会话
+	draft := newSession("草稿", "draft")
+	service.SetCompiledChapters(db.DB, &compiled, chapters(login, draft))
+	if _, err := docSvc.CompileDocument(&compiled); !errors.Is(err, service.ErrChapterWithoutDocument) {
+		t.Errorf("expected ErrChapterWithoutDocument, got %v", err)
+	}
+}
+
+func TestCompileDocument_Groups(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "分组"}
+	db.DB.Create(&proj)
+	docSvc := service.NewDocService()
+	var ids []string
+	for _, title := range []string{"登录", "受理", "审核", "归档"} {
+		sess := db.Session{ProjectID: proj.ID, Title: title}
+		db.DB.Create(&sess)
+		db.DB.Create(&db.RecordingStep{SessionID: sess.ID, StepIndex: 1, Action: "click", TargetElement: title,
+			PageURL: "https://gov.example.com/" + sess.ID, PageTitle: title})
+		content, _ := docSvc.BuildDocument(sess.ID)
+		docSvc.SaveGeneratedDoc(sess.ID, content)
+		ids = append(ids, sess.ID)
+	}
+	compiled := db.CompiledDocument{ProjectID: proj.ID, Title: "手册"}
+	db.DB.Create(&compiled)
+	if _, err := service.SetCompiledChapters(db.DB, &compiled, []db.CompiledChapter{
+		{SessionID: ids[0]},
+		{SessionID: ids[1], GroupTitle: " 业务办理 ", Title: "窗口受理"},
+		{SessionID: ids[3], Excluded: true},
+		{SessionID: ids[2], GroupTitle: "业务办理"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	var saved []db.CompiledChapter
+	db.DB.Where("compiled_id = ?", compiled.ID).Order("position").Find(&saved)
+	if len(saved) != 4 || saved[1].GroupTitle != "业务办理" || !saved[2].Excluded || saved[3].SessionID != ids[2] {
+		t.Fatalf("unexpected saved chapters %+v", saved)
+	}
+
+	content, err := docSvc.CompileDocument(&compiled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := docSvc.GenerateMarkdown(content, "business")
+	// 已排除的章节不出现；同组的相邻章节共用一个分组标题，章序号跳过排除的章节
+	if strings.Contains(md, "归档") || strings.Count(md, "业务办理") != 2 {
+		t.Errorf("unexpected grouping:\n%s", md)
+	}
+	for _, want := range []string{"## 第 1 章 登录\n", "## 业务办理\n", "### 第 2 章 窗口受理\n", "#### 受理 - 操作说明\n\n##### 第 2 步", "### 第 3 章 审核\n",
+		"- **业务办理**\n  - 第 2 章 窗口受理\n  - 第 3 章 审核"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+}
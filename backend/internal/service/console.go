@@ -0,0 +1,115 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// 控制台日志级别
+const (
+	LogLevelError = "error"
+	LogLevelWarn  = "warn"
+	LogLevelInfo  = "info"
+)
+
+var logLevels = map[string]string{
+	"error": LogLevelError, "exception": LogLevelError,
+	"warn": LogLevelWarn, "warning": LogLevelWarn,
+	"info": LogLevelInfo, "log": LogLevelInfo,
+}
+
+const (
+	// MaxLogsPerStep 单个步骤最多保留的日志条数
+	MaxLogsPerStep = 50
+	// maxLogMessageRunes 单条日志消息的长度上限（堆栈等长文本截断）
+	maxLogMessageRunes = 500
+	// maxNotedErrors 技术视图中逐条列出的错误数，其余只计数
+	maxNotedErrors = 3
+)
+
+// ConsoleEntry 插件上报的单条控制台输出
+type ConsoleEntry struct {
+	Level     string `json:"level"`
+	Message   string `json:"message"`
+	Source    string `json:"source"`
+	Line      int    `json:"line"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// SanitizeConsoleEntries 过滤并规整上报的日志：未知级别与空消息丢弃，消息截断，
+// 来源脚本 URL 按请求 URL 规则脱敏；超过 MaxLogsPerStep 的部分截断
+func SanitizeConsoleEntries(entries []ConsoleEntry) []db.StepLog {
+	out := make([]db.StepLog, 0, len(entries))
+	for _, e := range entries {
+		if len(out) >= MaxLogsPerStep {
+			break
+		}
+		level, ok := logLevels[strings.ToLower(strings.TrimSpace(e.Level))]
+		msg := strings.TrimSpace(e.Message)
+		if !ok || msg == "" {
+			continue
+		}
+		if utf8.RuneCountInString(msg) > maxLogMessageRunes {
+			msg = string([]rune(msg)[:maxLogMessageRunes]) + "…"
+		}
+		source, _ := SanitizeRequestURL(e.Source)
+		out = append(out, db.StepLog{Level: level, Message: msg, Source: source, Line: e.Line, Timestamp: e.Timestamp})
+	}
+	return out
+}
+
+// AttachStepLogs 将日志追加到步骤下（在步骤已有日志之后编号），返回写入的记录
+func AttachStepLogs(tx *gorm.DB, step *db.RecordingStep, logs []db.StepLog) ([]db.StepLog, error) {
+	if len(logs) == 0 {
+		return logs, nil
+	}
+	var count int64
+	if err := tx.Model(&db.StepLog{}).Where("step_id = ?", step.ID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	room := MaxLogsPerStep - int(count)
+	if room <= 0 {
+		return []db.StepLog{}, nil
+	}
+	if len(logs) > room {
+		logs = logs[:room]
+	}
+	for i := range logs {
+		logs[i].StepID, logs[i].SessionID, logs[i].Seq = step.ID, step.SessionID, int(count)+i+1
+	}
+	if err := tx.Create(&logs).Error; err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// stepErrors 按步骤汇总脚本错误（仅 error 级别），用于技术视图标记
+func stepErrors(sessionID string) map[string][]db.StepLog {
+	var logs []db.StepLog
+	db.DB.Where("session_id = ? AND level = ?", sessionID, LogLevelError).Order("step_id, seq").Find(&logs)
+	out := map[string][]db.StepLog{}
+	for _, l := range logs {
+		out[l.StepID] = append(out[l.StepID], l)
+	}
+	return out
+}
+
+// consoleErrorNote 技术说明中的错误摘要：列出前几条，其余只计数
+func consoleErrorNote(errs []db.StepLog) string {
+	var sb strings.Builder
+	for i, e := range errs {
+		if i == maxNotedErrors {
+			sb.WriteString(fmt.Sprintf("\n脚本错误：…等共 %d 条", len(errs)))
+			break
+		}
+		sb.WriteString("\n脚本错误：" + strings.ReplaceAll(e.Message, "\n", " "))
+		if e.Source != "" {
+			sb.WriteString(fmt.Sprintf("（%s:%d）", e.Source, e.Line))
+		}
+	}
+	return sb.String()
+}
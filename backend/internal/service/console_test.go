@@ -0,0 +1,72 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestSanitizeConsoleEntries(t *testing.T) {
+	logs := service.SanitizeConsoleEntries([]service.ConsoleEntry{
+		{Level: "ERROR", Message: "TypeError: x is undefined", Source: "https://gov.example.com/app.js?token=abc", Line: 42},
+		{Level: "debug", Message: "ignored"},
+		{Level: "warning", Message: "   "},
+		{Level: "log", Message: strings.Repeat("长", 600)},
+	})
+	if len(logs) != 2 {
+		t.Fatalf("expected 2 entries, got %d: %+v", len(logs), logs)
+	}
+	if logs[0].Level != service.LogLevelError || strings.Contains(logs[0].Source, "abc") {
+		t.Errorf("unexpected error entry: %+v", logs[0])
+	}
+	if logs[1].Level != service.LogLevelInfo || len([]rune(logs[1].Message)) != 501 {
+		t.Errorf("long message should be truncated: level=%s len=%d", logs[1].Level, len([]rune(logs[1].Message)))
+	}
+}
+
+func TestScriptErrorsInTechnicalView(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "旧系统", MergeStrategy: service.MergeOff}
+	db.DB.Create(&proj)
+	sess := db.Session{ProjectID: proj.ID, Title: "办件提交"}
+	db.DB.Create(&sess)
+
+	var entries []service.ConsoleEntry
+	for i := 0; i < 5; i++ {
+		entries = append(entries, service.ConsoleEntry{Level: "error", Message: "Uncaught ReferenceError: jQuery is not defined"})
+	}
+	entries = append(entries, service.ConsoleEntry{Level: "warn", Message: "deprecated API"})
+	for i, logs := range [][]db.StepLog{service.SanitizeConsoleEntries(entries), nil} {
+		_, err := service.IngestStep(db.DB, service.StepInput{
+			Step: db.RecordingStep{SessionID: sess.ID, Action: "click", PageTitle: "办件登记", TargetElement: "按钮", StepIndex: i + 1},
+			Logs: logs,
+		})
+		if err != nil {
+			t.Fatalf("IngestStep: %v", err)
+		}
+	}
+
+	svc := service.NewDocService()
+	content, err := svc.BuildDocument(sess.ID)
+	if err != nil {
+		t.Fatalf("BuildDocument: %v", err)
+	}
+	tech := content.TechnicalView[0].Steps
+	if tech[0].ScriptErrors != 5 || tech[1].ScriptErrors != 0 {
+		t.Errorf("unexpected script error counts: %d, %d", tech[0].ScriptErrors, tech[1].ScriptErrors)
+	}
+	if n := strings.Count(tech[0].TechNote, "脚本错误："); n != 4 || !strings.Contains(tech[0].TechNote, "等共 5 条") {
+		t.Errorf("tech note should list 3 errors and a count, got:\n%s", tech[0].TechNote)
+	}
+	if strings.Contains(tech[0].TechNote, "deprecated") {
+		t.Error("warnings should not be flagged")
+	}
+	if md := svc.GenerateMarkdown(content, "technical"); strings.Count(md, "⚠️ 该步骤执行期间页面抛出 5 个脚本错误") != 1 {
+		t.Errorf("technical markdown should flag the step once:\n%s", md)
+	}
+	if strings.Contains(svc.GenerateMarkdown(content, "business"), "脚本错误") {
+		t.Error("business view should not flag script errors")
+	}
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"strings"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// 裁剪参数（截图实际像素）
+const (
+	cropMinWidth  = 640 // 裁剪区域最小宽度，保留足够的周边上下文
+	cropMinHeight = 400
+	cropPadding   = 120 // 边界框四周额外保留的像素
+	highlightLine = 3   // 目标元素红框线宽
+)
+
+// StepVLMRequest 根据步骤构造 VLM 请求：加载截图并烧录遮蔽区域，在有交互坐标时裁剪到目标附近
+func StepVLMRequest(step *db.RecordingStep) VLMRequest {
+	req := VLMRequest{
+		StepAction:    step.Action,
+		TargetElement: step.TargetElement,
+		PageURL:       step.PageURL,
+		PageTitle:     step.PageTitle,
+		MaskedText:    step.MaskedText,
+		KeyCombo:      step.KeyCombo,
+		StepIndex:     step.StepIndex,
+		SessionID:     step.SessionID,
+		StepID:        step.ID,
+	}
+	if step.ScreenshotID == "" {
+		return req
+	}
+	// 纯文本模式不加载截图，只附带识别出的屏幕文字
+	if CurrentSettings().TextOnlyGeneration {
+		var texts []string
+		db.DB.Model(&db.Screenshot{}).Where("id = ?", step.ScreenshotID).Pluck("ocr_text", &texts)
+		if len(texts) > 0 {
+			req.ScreenText = texts[0]
+		}
+		return req
+	}
+	var screenshot db.Screenshot
+	if err := db.DB.First(&screenshot, "id = ?", step.ScreenshotID).Error; err != nil {
+		return req
+	}
+	req.ScreenText = screenshot.OCRText
+	// 遮蔽区域在发送给 VLM 前烧录，模型看不到被标记的内容
+	req.ScreenshotB64 = RedactedScreenshot(&screenshot, RedactBlack)
+	if req.ScreenshotB64 == "" {
+		return req
+	}
+	if cropped, ok := CropAroundTarget(req.ScreenshotB64, step, StepViewportWidth(step, screenshot.Width)); ok {
+		req.ScreenshotB64 = cropped
+		req.TargetHighlighted = step.BBoxW > 0 && step.BBoxH > 0
+	}
+	return req
+}
+
+// CropAroundTarget 以目标元素边界框（或点击点）为中心裁剪截图，并用红框标出目标元素。
+// 步骤坐标为视口 CSS 像素，viewportWidth 非零时按截图实际宽度换算（高分屏截图为设备像素）；
+// 没有坐标或图片无法解码时返回 false，调用方使用原图
+func CropAroundTarget(dataURL string, step *db.RecordingStep, viewportWidth int) (string, bool) {
+	hasBox := step.BBoxW > 0 && step.BBoxH > 0
+	hasClick := step.ClickX > 0 || step.ClickY > 0
+	if dataURL == "" || (!hasBox && !hasClick) {
+		return "", false
+	}
+
+	img, err := decodeDataURL(dataURL)
+	if err != nil {
+		return "", false
+	}
+	bounds := img.Bounds()
+	scale := 1.0
+	if viewportWidth > 0 {
+		scale = float64(bounds.Dx()) / float64(viewportWidth)
+	}
+	px := func(v int) int { return int(float64(v) * scale) }
+
+	var target image.Rectangle
+	if hasBox {
+		target = image.Rect(px(step.BBoxX), px(step.BBoxY), px(step.BBoxX+step.BBoxW), px(step.BBoxY+step.BBoxH)).Add(bounds.Min)
+		if !target.Overlaps(bounds) {
+			return "", false
+		}
+	} else {
+		click := image.Pt(px(step.ClickX), px(step.ClickY)).Add(bounds.Min)
+		if !click.In(bounds) {
+			return "", false
+		}
+		target = image.Rectangle{Min: click, Max: click}
+	}
+
+	crop := expandRect(target.Inset(-cropPadding), cropMinWidth, cropMinHeight)
+	crop = fitRect(crop, bounds)
+	if crop.Eq(bounds) && !hasBox {
+		// 截图本身已经很小，裁剪没有意义
+		return "", false
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, crop.Dx(), crop.Dy()))
+	draw.Draw(out, out.Bounds(), img, crop.Min, draw.Src)
+	if hasBox {
+		drawOutline(out, target.Sub(crop.Min).Intersect(out.Bounds()), color.RGBA{R: 255, A: 255})
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, out); err != nil {
+		return "", false
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), true
+}
+
+// StepViewportWidth 步骤坐标所在视口的 CSS 宽度：优先使用录制时上报的视口宽度，
+// 未上报时（旧插件、导入的录制）退回 fallback（通常为截图宽度）
+func StepViewportWidth(step *db.RecordingStep, fallback int) int {
+	if step.ViewportW > 0 {
+		return step.ViewportW
+	}
+	return fallback
+}
+
+// ElementScreenshot 生成步骤目标元素的局部截图（按边界框裁剪并标红框），
+// 没有边界框或无法裁剪时返回空串；点击坐标只用于 VLM 请求，不生成局部图
+func ElementScreenshot(dataURL string, step *db.RecordingStep, viewportWidth int) string {
+	if step.BBoxW <= 0 || step.BBoxH <= 0 {
+		return ""
+	}
+	cropped, _ := CropAroundTarget(dataURL, step, viewportWidth)
+	return cropped
+}
+
+func decodeDataURL(dataURL string) (image.Image, error) {
+	data := dataURL
+	if i := strings.Index(data, ","); i >= 0 && strings.HasPrefix(data, "data:") {
+		data = data[i+1:]
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	return img, err
+}
+
+// expandRect 围绕中心把矩形扩大到不小于 minW×minH
+func expandRect(r image.Rectangle, minW, minH int) image.Rectangle {
+	if dx := minW - r.Dx(); dx > 0 {
+		r.Min.X -= dx / 2
+		r.Max.X += dx - dx/2
+	}
+	if dy := minH - r.Dy(); dy > 0 {
+		r.Min.Y -= dy / 2
+		r.Max.Y += dy - dy/2
+	}
+	return r
+}
+
+// fitRect 将矩形平移到图片范围内（尽量保持尺寸），超出部分截掉
+func fitRect(r, bounds image.Rectangle) image.Rectangle {
+	if r.Min.X < bounds.Min.X {
+		r = r.Add(image.Pt(bounds.Min.X-r.Min.X, 0))
+	}
+	if r.Min.Y < bounds.Min.Y {
+		r = r.Add(image.Pt(0, bounds.Min.Y-r.Min.Y))
+	}
+	if r.Max.X > bounds.Max.X {
+		r = r.Sub(image.Pt(r.Max.X-bounds.Max.X, 0))
+	}
+	if r.Max.Y > bounds.Max.Y {
+		r = r.Sub(image.Pt(0, r.Max.Y-bounds.Max.Y))
+	}
+	return r.Intersect(bounds)
+}
+
+func drawOutline(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	if r.Empty() {
+		return
+	}
+	src := image.NewUniform(c)
+	w := highlightLine
+	draw.Draw(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, min(r.Min.Y+w, r.Max.Y)), src, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Min.X, max(r.Max.Y-w, r.Min.Y), r.Max.X, r.Max.Y), src, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Min.X, r.Min.Y, min(r.Min.X+w, r.Max.X), r.Max.Y), src, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(max(r.Max.X-w, r.Min.X), r.Min.Y, r.Max.X, r.Max.Y), src, image.Point{}, draw.Src)
+}
@@ -0,0 +1,152 @@
+package service_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func pngDataURL(t *testing.T, w, h int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func decodePNG(t *testing.T, dataURL string) image.Image {
+	t.Helper()
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(dataURL, "data:image/png;base64,"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func TestCropAroundTarget(t *testing.T) {
+	full := pngDataURL(t, 1600, 1000)
+
+	t.Run("BoundingBoxNearEdge", func(t *testing.T) {
+		step := &db.RecordingStep{BBoxX: 1400, BBoxY: 900, BBoxW: 100, BBoxH: 50}
+		out, ok := service.CropAroundTarget(full, step, 0)
+		if !ok {
+			t.Fatal("expected crop")
+		}
+		img := decodePNG(t, out)
+		if img.Bounds().Dx() != 640 || img.Bounds().Dy() != 400 {
+			t.Fatalf("unexpected crop size %v", img.Bounds())
+		}
+		// 裁剪区域被平移到右下角 (960,600)，目标框左上角应为红色
+		if r, g, _, _ := img.At(1400-960, 900-600).RGBA(); r != 0xffff || g != 0 {
+			t.Errorf("expected red outline at target corner")
+		}
+	})
+
+	t.Run("ScalesViewportCoordinates", func(t *testing.T) {
+		// 视口 800 宽、截图 1600 宽（devicePixelRatio=2）
+		step := &db.RecordingStep{BBoxX: 700, BBoxY: 450, BBoxW: 50, BBoxH: 25}
+		out, ok := service.CropAroundTarget(full, step, 800)
+		if !ok {
+			t.Fatal("expected crop")
+		}
+		if r, g, _, _ := decodePNG(t, out).At(1400-960, 900-600).RGBA(); r != 0xffff || g != 0 {
+			t.Errorf("expected red outline at scaled target corner")
+		}
+	})
+
+	t.Run("ClickOnly", func(t *testing.T) {
+		step := &db.RecordingStep{ClickX: 800, ClickY: 500}
+		out, ok := service.CropAroundTarget(full, step, 0)
+		if !ok {
+			t.Fatal("expected crop")
+		}
+		if b := decodePNG(t, out).Bounds(); b.Dx() != 640 || b.Dy() != 400 {
+			t.Errorf("unexpected crop size %v", b)
+		}
+	})
+
+	t.Run("NoGeometryOrOutside", func(t *testing.T) {
+		if _, ok := service.CropAroundTarget(full, &db.RecordingStep{}, 0); ok {
+			t.Error("expected no crop without coordinates")
+		}
+		if _, ok := service.CropAroundTarget(full, &db.RecordingStep{ClickX: 5000, ClickY: 10}, 0); ok {
+			t.Error("expected no crop for click outside screenshot")
+		}
+		if _, ok := service.CropAroundTarget("data:image/png;base64,bm90LWFuLWltYWdl", &db.RecordingStep{ClickX: 1, ClickY: 1}, 0); ok {
+			t.Error("expected no crop for undecodable image")
+		}
+	})
+}
+
+func TestElementScreenshotInBusinessView(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "局部图", MergeStrategy: service.MergeOff}
+	db.DB.Create(&proj)
+	sess := db.Session{ProjectID: proj.ID, Title: "办件登记"}
+	db.DB.Create(&sess)
+
+	full := pngDataURL(t, 1600, 1000)
+	for i, step := range []db.RecordingStep{
+		{Action: "click", BBoxX: 700, BBoxY: 450, BBoxW: 100, BBoxH: 40}, // 有边界框：生成局部图
+		{Action: "click", ClickX: 300, ClickY: 200},                      // 仅点击坐标：不生成
+	} {
+		step.SessionID, step.StepIndex, step.PageTitle, step.TargetElement = sess.ID, i+1, "办件登记", "按钮"
+		_, err := service.IngestStep(db.DB, service.StepInput{Step: step, Screenshot: &db.Screenshot{DataURL: db.LongText(full), Width: 1600}})
+		if err != nil {
+			t.Fatalf("IngestStep: %v", err)
+		}
+	}
+
+	var shots []db.Screenshot
+	db.DB.Joins("JOIN recording_steps ON recording_steps.id = screenshots.step_id").
+		Order("recording_steps.step_index").Find(&shots)
+	if len(shots) != 2 || shots[0].ElementURL == "" || shots[1].ElementURL != "" {
+		t.Fatalf("expected element crop only for the step with a bounding box")
+	}
+	if b := decodePNG(t, string(shots[0].ElementURL)).Bounds(); b.Dx() != 640 || b.Dy() != 400 {
+		t.Errorf("unexpected element crop size %v", b)
+	}
+
+	// 高分屏：截图 1600 宽、视口 800 宽，边界框按视口宽度换算到截图像素
+	hidpi := db.RecordingStep{SessionID: sess.ID, StepIndex: 3, Action: "click", ViewportW: 800, ViewportH: 500,
+		BBoxX: 700, BBoxY: 450, BBoxW: 50, BBoxH: 25, Excluded: true}
+	res, err := service.IngestStep(db.DB, service.StepInput{Step: hidpi, Screenshot: &db.Screenshot{DataURL: db.LongText(full), Width: 1600}})
+	if err != nil {
+		t.Fatalf("IngestStep: %v", err)
+	}
+	var hidpiShot db.Screenshot
+	db.DB.First(&hidpiShot, "id = ?", res.Step.ScreenshotID)
+	if r, g, _, _ := decodePNG(t, string(hidpiShot.ElementURL)).At(1400-960, 900-600).RGBA(); r != 0xffff || g != 0 {
+		t.Errorf("element crop should scale by the recorded viewport width")
+	}
+
+	content, err := service.NewDocService().BuildDocument(sess.ID)
+	if err != nil {
+		t.Fatalf("BuildDocument: %v", err)
+	}
+	biz, tech := content.BusinessView[0].Steps, content.TechnicalView[0].Steps
+	if biz[0].ScreenshotURL != string(shots[0].ElementURL) || biz[1].ScreenshotURL != full {
+		t.Error("business view should prefer the element crop when available")
+	}
+	if tech[0].ScreenshotURL != full {
+		t.Error("technical view should keep the full screenshot")
+	}
+}
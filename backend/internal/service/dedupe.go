@@ -0,0 +1,117 @@
+package service
+
+import (
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// DuplicateWindowMS 两次相同操作间隔不超过该值（毫秒）时视为重复提交
+const DuplicateWindowMS = 2000
+
+// IsDuplicateStep 判断 next 是否为 prev 的重复提交（同一 DOM 指纹上的同一操作）
+func IsDuplicateStep(prev, next *db.RecordingStep) bool {
+	if prev == nil || next.DOMFingerprint == "" || prev.DOMFingerprint != next.DOMFingerprint {
+		return false
+	}
+	if prev.Action != next.Action || prev.PageURL != next.PageURL ||
+		prev.InputValue != next.InputValue || prev.MaskedText != next.MaskedText {
+		return false
+	}
+	if prev.Timestamp != 0 && next.Timestamp != 0 {
+		diff := next.Timestamp - prev.Timestamp
+		if diff < 0 {
+			diff = -diff
+		}
+		return diff <= DuplicateWindowMS
+	}
+	return true
+}
+
+// DuplicateRoot 返回重复链上的原始步骤 ID
+func DuplicateRoot(step *db.RecordingStep) string {
+	if step.DuplicateOf != "" {
+		return step.DuplicateOf
+	}
+	return step.ID
+}
+
+// LastStep 返回会话中序号最大的步骤（无步骤时返回 nil）
+func LastStep(tx *gorm.DB, sessionID string) *db.RecordingStep {
+	var step db.RecordingStep
+	if err := tx.Where("session_id = ?", sessionID).Order("step_index desc").First(&step).Error; err != nil {
+		return nil
+	}
+	return &step
+}
+
+// DetectDuplicateSteps 扫描整个会话，返回连续的重复步骤（DuplicateOf 为应指向的原始步骤），不修改数据
+func DetectDuplicateSteps(tx *gorm.DB, sessionID string) ([]db.RecordingStep, error) {
+	var steps []db.RecordingStep
+	if err := tx.Where("session_id = ?", sessionID).Order("step_index").Find(&steps).Error; err != nil {
+		return nil, err
+	}
+
+	duplicates := []db.RecordingStep{}
+	for i := 1; i < len(steps); i++ {
+		prev, cur := &steps[i-1], &steps[i]
+		if !IsDuplicateStep(prev, cur) {
+			continue
+		}
+		// 连续多次重复都指向第一次操作
+		cur.DuplicateOf = DuplicateRoot(prev)
+		duplicates = append(duplicates, *cur)
+	}
+	return duplicates, nil
+}
+
+// FlagDuplicateSteps 扫描整个会话，标记连续的重复步骤，返回被标记的步骤
+func FlagDuplicateSteps(tx *gorm.DB, sessionID string) ([]db.RecordingStep, error) {
+	flagged, err := DetectDuplicateSteps(tx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range flagged {
+		if err := tx.Model(&db.RecordingStep{}).Where("id = ? AND duplicate_of <> ?", s.ID, s.DuplicateOf).
+			Update("duplicate_of", s.DuplicateOf).Error; err != nil {
+			return nil, err
+		}
+	}
+	return flagged, nil
+}
+
+// RemoveDuplicateSteps 删除会话中已标记的重复步骤及其截图，并重新编号
+func RemoveDuplicateSteps(tx *gorm.DB, sessionID string) (int64, error) {
+	var ids []string
+	if err := tx.Model(&db.RecordingStep{}).
+		Where("session_id = ? AND duplicate_of <> ''", sessionID).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	return RemoveSteps(tx, sessionID, ids)
+}
+
+// RemoveSteps 删除会话中的指定步骤及其截图、网络请求、控制台日志、脱敏审计与格式校验失败记录，并重新编号
+func RemoveSteps(tx *gorm.DB, sessionID string, ids []string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if err := tx.Where("step_id IN ?", ids).Delete(&db.Screenshot{}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Where("step_id IN ?", ids).Delete(&db.StepRequest{}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Where("step_id IN ?", ids).Delete(&db.StepLog{}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Where("step_id IN ?", ids).Delete(&db.MaskingEvent{}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Where("step_id IN ?", ids).Delete(&db.OutputValidationFailure{}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Where("id IN ?", ids).Delete(&db.RecordingStep{}).Error; err != nil {
+		return 0, err
+	}
+	return int64(len(ids)), RenumberSteps(tx, sessionID)
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// DemoProjectName 示例项目名称，据此判断是否已经导入过示例数据
+const DemoProjectName = "示例项目：社保缴费"
+
+// ErrDemoSeeded 示例数据已存在
+var ErrDemoSeeded = errors.New("demo data already seeded")
+
+// DemoResult 示例数据导入结果
+type DemoResult struct {
+	ProjectID string `json:"project_id"`
+	SessionID string `json:"session_id"`
+	Steps     int    `json:"steps"`
+}
+
+// demoStep 示例步骤：操作、目标元素与在示意截图中的位置
+type demoStep struct {
+	action, target, text, page, path string
+	box                              image.Rectangle
+	requests                         []db.StepRequest
+}
+
+const demoHost = "https://demo.gpilot.local"
+
+var demoSteps = []demoStep{
+	{action: "navigation", target: "政务服务网首页", page: "政务服务网 - 首页", path: "/portal",
+		box: image.Rect(0, 0, 1280, 64)},
+	{action: "click", target: "个人社保缴费", text: "个人社保缴费", page: "政务服务网 - 首页", path: "/portal",
+		box: image.Rect(120, 200, 420, 330)},
+	{action: "input", target: "身份证号 输入框", text: "身份证号：[已脱敏]", page: "社保缴费 - 查询", path: "/social-insurance/pay",
+		box: image.Rect(400, 200, 880, 244)},
+	{action: "select", target: "缴费年度 下拉框", text: "2026", page: "社保缴费 - 查询", path: "/social-insurance/pay",
+		box: image.Rect(400, 280, 880, 324)},
+	{action: "click", target: "查询应缴金额 按钮", text: "查询应缴金额", page: "社保缴费 - 查询", path: "/social-insurance/pay",
+		box:      image.Rect(400, 370, 600, 414),
+		requests: []db.StepRequest{{Method: "GET", URL: demoHost + "/api/social-insurance/bills?year=2026", Status: 200, ResourceType: "xhr", MimeType: "application/json", DurationMS: 182}}},
+	{action: "click", target: "立即缴费 按钮", text: "立即缴费", page: "社保缴费 - 缴费确认", path: "/social-insurance/confirm",
+		box:      image.Rect(540, 560, 740, 604),
+		requests: []db.StepRequest{{Method: "POST", URL: demoHost + "/api/social-insurance/payments", Status: 201, ResourceType: "fetch", MimeType: "application/json", DurationMS: 356}}},
+}
+
+// SeedDemo 导入示例项目、会话、步骤与示意截图，新部署无需安装浏览器插件即可体验文档生成流程；
+// 已导入过时返回 ErrDemoSeeded 和已有的示例项目
+func SeedDemo() (*DemoResult, error) {
+	var existing db.Project
+	if err := db.DB.Where("name = ?", DemoProjectName).First(&existing).Error; err == nil {
+		return &DemoResult{ProjectID: existing.ID}, ErrDemoSeeded
+	}
+
+	project := db.Project{Name: DemoProjectName, Description: "演示数据，展示录制步骤到操作手册的生成流程，可随意修改或删除"}
+	if err := db.DB.Create(&project).Error; err != nil {
+		return nil, err
+	}
+	start := time.Now().Add(-time.Duration(len(demoSteps)) * 8 * time.Second)
+	session := db.Session{
+		ProjectID: project.ID,
+		Title:     "城乡居民社保缴费",
+		Status:    "recording",
+		StartedAt: &start,
+		TargetURL: demoHost + "/portal",
+	}
+	if err := db.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	for i, d := range demoSteps {
+		data, err := demoScreenshot(d)
+		if err != nil {
+			return nil, err
+		}
+		shot, err := ScreenshotFromBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		at := start.Add(time.Duration(i) * 8 * time.Second).UnixMilli()
+		shot.CapturedAt = at
+		step := db.RecordingStep{
+			SessionID:     session.ID,
+			Timestamp:     at,
+			Action:        d.action,
+			TargetElement: d.target,
+			MaskedText:    d.text,
+			IsMasked:      d.action == "input",
+			PageURL:       demoHost + d.path,
+			PageTitle:     d.page,
+			ClickX:        (d.box.Min.X + d.box.Max.X) / 2,
+			ClickY:        (d.box.Min.Y + d.box.Max.Y) / 2,
+			ViewportW:     demoWidth,
+			ViewportH:     demoHeight,
+		}
+		if d.action != "navigation" {
+			step.BBoxX, step.BBoxY, step.BBoxW, step.BBoxH = d.box.Min.X, d.box.Min.Y, d.box.Dx(), d.box.Dy()
+		}
+		if _, err := IngestStep(db.DB, StepInput{Step: step, Screenshot: shot, Requests: append([]db.StepRequest(nil), d.requests...)}); err != nil {
+			return nil, err
+		}
+	}
+
+	end := time.Now()
+	if err := db.DB.Model(&session).Updates(db.Session{Status: "completed", EndedAt: &end}).Error; err != nil {
+		return nil, err
+	}
+	return &DemoResult{ProjectID: project.ID, SessionID: session.ID, Steps: len(demoSteps)}, nil
+}
+
+// 示意截图尺寸（与步骤坐标同为视口像素）
+const (
+	demoWidth  = 1280
+	demoHeight = 720
+)
+
+var (
+	demoBackground = color.RGBA{0xf2, 0xf4, 0xf7, 0xff}
+	demoHeader     = color.RGBA{0x1f, 0x5f, 0xbf, 0xff}
+	demoCard       = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	demoField      = color.RGBA{0xdc, 0xe1, 0xe8, 0xff}
+	demoButton     = color.RGBA{0xe8, 0x6a, 0x1f, 0xff}
+)
+
+// demoScreenshot 绘制示意截图：顶栏、内容卡片、同页的其他控件和本步骤的目标控件
+func demoScreenshot(d demoStep) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, demoWidth, demoHeight))
+	fill := func(r image.Rectangle, c color.Color) { draw.Draw(img, r, &image.Uniform{c}, image.Point{}, draw.Src) }
+	fill(img.Bounds(), demoBackground)
+	fill(image.Rect(0, 0, demoWidth, 64), demoHeader)
+	fill(image.Rect(80, 120, demoWidth-80, demoHeight-40), demoCard)
+	for _, other := range demoSteps {
+		if other.path == d.path && other.action != "navigation" {
+			fill(other.box, demoField)
+		}
+	}
+	if d.action != "navigation" {
+		c := demoField
+		if d.action == "click" {
+			c = demoButton
+		}
+		fill(d.box, c)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
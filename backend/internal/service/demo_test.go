@@ -0,0 +1,42 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestSeedDemo(t *testing.T) {
+	setupDB(t)
+	result, err := service.SeedDemo()
+	if err != nil {
+		t.Fatalf("SeedDemo: %v", err)
+	}
+	var steps []db.RecordingStep
+	db.DB.Where("session_id = ?", result.SessionID).Order("step_index").Find(&steps)
+	if len(steps) != result.Steps || steps[len(steps)-1].StepIndex != result.Steps {
+		t.Fatalf("expected %d numbered steps, got %d", result.Steps, len(steps))
+	}
+	var shots int64
+	db.DB.Model(&db.Screenshot{}).Where("session_id = ? AND element_url <> ''", result.SessionID).Count(&shots)
+	if shots != int64(result.Steps-1) {
+		t.Errorf("expected element crops for every non-navigation step, got %d", shots)
+	}
+	var session db.Session
+	db.DB.First(&session, "id = ?", result.SessionID)
+	if session.Status != "completed" || session.DurationMS == 0 {
+		t.Errorf("unexpected demo session %+v", session)
+	}
+
+	content, err := service.NewDocService().BuildDocument(result.SessionID)
+	if err != nil || len(content.BusinessView) == 0 {
+		t.Fatalf("demo session should build a document: %v", err)
+	}
+
+	again, err := service.SeedDemo()
+	if !errors.Is(err, service.ErrDemoSeeded) || again.ProjectID != result.ProjectID {
+		t.Errorf("second seed should report the existing project, got %+v %v", again, err)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package service
+
+import "errors"
+
+// diskFree Windows 下暂不支持磁盘空间检查
+func diskFree(path string) (uint64, error) {
+	return 0, errors.New("disk space check not supported on windows")
+}
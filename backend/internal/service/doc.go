@@ -3,6 +3,9 @@ package service
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
 	"strings"
 	"time"
 
@@ -19,28 +22,297 @@ type DocStep struct {
 	StepIndex     int    `json:"step_index"`
 	Action        string `json:"action"`
 	Description   string `json:"description"`
+	Title         string `json:"title,omitempty"` // 步骤短标题，写在步骤标题中并用作截图说明
 	TechNote      string `json:"tech_note,omitempty"`
 	ScreenshotID  string `json:"screenshot_id"`
 	ScreenshotURL string `json:"screenshot_url,omitempty"` // base64 data URL
 	PageURL       string `json:"page_url,omitempty"`
 	PageTitle     string `json:"page_title"`
 	IsEdited      bool   `json:"is_edited"`
+	ElapsedMS     int64  `json:"elapsed_ms,omitempty"`    // 完成该步骤（业务视图为合并后的整组）所用时间
+	PositionHint  string `json:"position_hint,omitempty"` // 目标不在首屏时的滚动提示，渲染在截图下方
+	ScriptErrors  int    `json:"script_errors,omitempty"` // 步骤执行期间目标系统抛出的脚本错误数（技术视图标记）
+	TextOnly      bool   `json:"text_only,omitempty"`     // 描述生成时未发送截图，仅依据操作元数据
+}
+
+// ScreenshotAlt 截图的替代文本，有短标题时附在序号之后
+func (st DocStep) ScreenshotAlt() string {
+	if st.Title != "" {
+		return fmt.Sprintf("步骤%d截图：%s", st.StepIndex, st.Title)
+	}
+	return fmt.Sprintf("步骤%d截图", st.StepIndex)
+}
+
+// 非步骤类章节
+const (
+	SectionOverview = "overview" // 流程概述（位于业务视图顶部）
+	SectionFAQ      = "faq"      // 常见问题（位于业务视图末尾）
+	SectionChapter  = "chapter"  // 合订手册的章标题，其后的章节与步骤下沉一级
+	SectionGroup    = "group"    // 合订手册的分组标题（如业务模块），其后的章再下沉一级；无标题时表示分组结束
+)
+
+// FAQItem 常见问题条目
+type FAQItem struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
 }
 
 // DocSection 文档章节
 type DocSection struct {
 	SectionIndex int       `json:"section_index"`
+	Kind         string    `json:"kind,omitempty"` // 空表示普通步骤章节
 	Title        string    `json:"title"`
+	Summary      string    `json:"summary,omitempty"`
+	URLPattern   string    `json:"url_pattern,omitempty"`
+	TabID        int       `json:"tab_id,omitempty"`
+	Transition   string    `json:"transition,omitempty"` // 从上一章节切换标签页/窗口的说明
 	Steps        []DocStep `json:"steps"`
+	FAQ          []FAQItem `json:"faq,omitempty"`
+	DurationMS   int64     `json:"duration_ms,omitempty"` // 本章节各步骤耗时之和
 }
 
 // GeneratedDocContent 文档内容
 type GeneratedDocContent struct {
-	SessionTitle  string       `json:"session_title"`
-	ProjectName   string       `json:"project_name"`
-	GeneratedAt   string       `json:"generated_at"`
-	BusinessView  []DocSection `json:"business_view"`
-	TechnicalView []DocSection `json:"technical_view"`
+	SessionTitle  string            `json:"session_title"`
+	ProjectName   string            `json:"project_name"`
+	GeneratedAt   string            `json:"generated_at"`
+	Metadata      map[string]string `json:"metadata,omitempty"`     // 项目与文档自定义字段合并结果，渲染到导出文档头部
+	DurationMS    int64             `json:"duration_ms,omitempty"`  // 整个流程的耗时
+	ShowTiming    bool              `json:"show_timing,omitempty"`  // 业务视图渲染耗时提示（约 N 分钟）
+	Numbering     string            `json:"numbering,omitempty"`    // 章节与步骤编号样式，见 NumberingStyles
+	HeadingBase   int               `json:"heading_base,omitempty"` // Markdown 文档标题级别，章节、步骤依次下沉
+	BusinessView  []DocSection      `json:"business_view"`
+	TechnicalView []DocSection      `json:"technical_view"`
+	Media         []DocMedia        `json:"media,omitempty"`     // 补充材料（整段操作录像等）
+	Watermark     *Watermark        `json:"watermark,omitempty"` // 导出水印（导出时按当前设置填充）
+	Template      string            `json:"template,omitempty"`  // 项目使用的文档模板标识
+	Preface       string            `json:"preface,omitempty"`   // 模板前言（Markdown），位于正文之前
+	Closing       string            `json:"closing,omitempty"`   // 模板结尾（Markdown），位于正文之后
+	HideHeader    bool              `json:"-"`                   // Markdown 导出省略头部信息块（项目、生成时间、自定义字段）
+	Flowchart     string            `json:"-"`                   // Mermaid 流程图源码，Markdown 导出时渲染在正文之前
+}
+
+// DocMedia 文档引用的会话附件
+type DocMedia struct {
+	ID         string `json:"id"`
+	Kind       string `json:"kind"`
+	FileName   string `json:"file_name,omitempty"`
+	MimeType   string `json:"mime_type"`
+	Size       int64  `json:"size"`
+	DurationMS int64  `json:"duration_ms,omitempty"`
+	URL        string `json:"url"`
+}
+
+// 业务视图步骤合并策略
+const (
+	MergeByLocation = "location" // 同一页面 + 同一位置（默认，依赖语义描述中的位置锚点）
+	MergeByPage     = "page"     // 同一页面的连续操作全部合并
+	MergeByForm     = "form"     // 同一页面 + 同一表单（按 XPath 中的 form 节点判断）
+	MergeByTime     = "time"     // 同一页面 + 与上一步间隔不超过时间窗口
+	MergeOff        = "off"      // 不合并
+)
+
+// MergeStrategies 支持的合并策略
+var MergeStrategies = []string{MergeByLocation, MergeByPage, MergeByForm, MergeByTime, MergeOff}
+
+// MergeRules 项目级合并配置
+type MergeRules struct {
+	Strategy      string
+	WindowSeconds int
+}
+
+// canMerge 判断 step 能否并入当前分组
+func (r MergeRules) canMerge(group []db.RecordingStep, step db.RecordingStep) bool {
+	first := group[0]
+	last := group[len(group)-1]
+	samePage := step.PageTitle == first.PageTitle
+
+	switch r.Strategy {
+	case MergeOff:
+		return false
+	case MergeByPage:
+		return samePage
+	case MergeByForm:
+		form := formRegion(step.TargetXPath)
+		return samePage && form != "" && form == formRegion(first.TargetXPath)
+	case MergeByTime:
+		window := int64(r.WindowSeconds) * 1000
+		if window <= 0 {
+			window = 30 * 1000
+		}
+		return samePage && step.Timestamp-last.Timestamp <= window
+	default:
+		ctxPrev := parseStepContext(first.TargetElement, first.Action)
+		ctxCurr := parseStepContext(step.TargetElement, step.Action)
+		return samePage && ctxCurr.location == ctxPrev.location
+	}
+}
+
+// formRegion 返回 XPath 中最内层 form 节点之前的路径（不在表单内时为空）
+func formRegion(xpath string) string {
+	lower := strings.ToLower(xpath)
+	idx := strings.LastIndex(lower, "/form")
+	if idx == -1 {
+		return ""
+	}
+	end := strings.Index(lower[idx+1:], "/")
+	if end == -1 {
+		return xpath
+	}
+	return xpath[:idx+1+end]
+}
+
+type stepContext struct {
+	location string
+	compName string
+	purpose  string
+	verb     string
+}
+
+// parseStepContext 从语义描述中提取位置、组件名、目的与动词
+func parseStepContext(t string, action string) stepContext {
+	ctx := stepContext{location: "页面区域", compName: "组件", purpose: "业务交互"}
+
+	// 提取位置
+	const locAnchor = "页面的 "
+	if idx := strings.Index(t, locAnchor); idx != -1 {
+		sub := t[idx+len(locAnchor):]
+		if endIdx := strings.Index(sub, "，"); endIdx != -1 {
+			ctx.location = strings.TrimSpace(sub[:endIdx])
+		}
+	}
+
+	// 提取组件名
+	const compAnchor = "功能为 "
+	if idx := strings.Index(t, compAnchor); idx != -1 {
+		sub := t[idx+len(compAnchor):]
+		if endIdx := strings.Index(sub, " 的"); endIdx != -1 {
+			ctx.compName = strings.TrimSpace(sub[:endIdx])
+		}
+	}
+
+	// 提取目的
+	const purposeAnchor = "实现 "
+	if idx := strings.Index(t, purposeAnchor); idx != -1 {
+		sub := t[idx+len(purposeAnchor):]
+		ctx.purpose = strings.TrimRight(strings.TrimSpace(sub), "。")
+	}
+
+	// 提取动词 - 优先从语义描述中提取，其次根据 action 兜底
+	if strings.Contains(t, "录入了") {
+		ctx.verb = "录入"
+	} else if strings.Contains(t, "按下了") {
+		ctx.verb = "按下"
+	} else if strings.Contains(t, "切换到") {
+		ctx.verb = "切换到"
+	} else if strings.Contains(t, "选择了") {
+		ctx.verb = "选择"
+	} else if strings.Contains(t, "点击了") {
+		ctx.verb = "点击"
+	} else {
+		switch action {
+		case "click":
+			ctx.verb = "点击"
+		case "input":
+			ctx.verb = "录入"
+		case "select":
+			ctx.verb = "选择"
+		case ActionKeypress, ActionShortcut:
+			ctx.verb = "按下"
+		default:
+			ctx.verb = "操作"
+		}
+	}
+	return ctx
+}
+
+type stepChunk struct {
+	pattern string
+	tabID   int
+	steps   []db.RecordingStep
+}
+
+// splitByNavigation 在导航到新的页面/URL 模式或切换标签页/窗口时切分章节；没有步骤时返回一个空章节。
+// iframe 内的操作沿用所在顶层页面的章节
+func splitByNavigation(steps []db.RecordingStep) []stepChunk {
+	chunks := []stepChunk{{}}
+	for _, step := range steps {
+		cur := &chunks[len(chunks)-1]
+		pattern := URLPattern(step.PageURL)
+		if step.FramePath != "" {
+			pattern = cur.pattern
+		}
+		switchedTab := step.TabID != 0 && cur.tabID != 0 && step.TabID != cur.tabID
+		if switchedTab || (pattern != "" && cur.pattern != "" && pattern != cur.pattern) {
+			chunks = append(chunks, stepChunk{pattern: pattern, tabID: step.TabID})
+			cur = &chunks[len(chunks)-1]
+		}
+		if cur.pattern == "" {
+			cur.pattern = pattern
+		}
+		if cur.tabID == 0 {
+			cur.tabID = step.TabID
+		}
+		cur.steps = append(cur.steps, step)
+	}
+	return chunks
+}
+
+// tabTransition 描述进入章节时的标签页/窗口切换：返回之前的标签页、打开新窗口或新标签页；
+// 未切换时返回空串
+func tabTransition(chunks []stepChunk, i int) string {
+	if i == 0 || chunks[i].tabID == 0 || len(chunks[i].steps) == 0 {
+		return ""
+	}
+	prev := chunks[i-1]
+	if prev.tabID == 0 || prev.tabID == chunks[i].tabID {
+		return ""
+	}
+	first := chunks[i].steps[0]
+	title := first.PageTitle
+	if title == "" {
+		title = URLPattern(first.PageURL)
+	}
+	for _, c := range chunks[:i] {
+		if c.tabID == chunks[i].tabID {
+			return fmt.Sprintf("返回「%s」窗口继续操作", title)
+		}
+	}
+	if len(prev.steps) > 0 && first.WindowID != 0 && first.WindowID != prev.steps[len(prev.steps)-1].WindowID {
+		return fmt.Sprintf("系统弹出新窗口「%s」，在该窗口中继续操作", title)
+	}
+	return fmt.Sprintf("系统在新标签页中打开「%s」，切换到该标签页继续操作", title)
+}
+
+var (
+	numericSegment = regexp.MustCompile(`^\d+$`)
+	idSegment      = regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$|^(?i)[0-9a-f]{16,}$`)
+)
+
+// URLPattern 归一化页面地址：去掉协议、查询参数，将数字/ID 路径段替换为 :id；
+// hash 路由（#/path）视为路径的一部分
+func URLPattern(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	p := u.Path
+	if frag := strings.TrimPrefix(u.Fragment, "!"); strings.HasPrefix(frag, "/") {
+		if i := strings.Index(frag, "?"); i != -1 {
+			frag = frag[:i]
+		}
+		p = strings.TrimSuffix(p, "/") + "#" + frag
+	}
+	segs := strings.Split(p, "/")
+	for i, seg := range segs {
+		if numericSegment.MatchString(seg) || idSegment.MatchString(seg) {
+			segs[i] = ":id"
+		}
+	}
+	return u.Host + strings.TrimSuffix(strings.Join(segs, "/"), "/")
 }
 
 // BuildDocument 聚合 steps 构建双视图文档
@@ -53,80 +325,82 @@ func (s *DocService) BuildDocument(sessionID string) (*GeneratedDocContent, erro
 	var project db.Project
 	db.DB.First(&project, "id = ?", session.ProjectID)
 
+	if err := RecomputeTiming(db.DB, sessionID); err != nil {
+		return nil, err
+	}
 	var steps []db.RecordingStep
 	db.DB.Where("session_id = ?", sessionID).Order("step_index").Find(&steps)
 
 	// 加载截图
 	screenshotMap := make(map[string]string)
+	elementMap := make(map[string]string) // 目标元素局部图，业务视图优先使用
 	var screenshots []db.Screenshot
 	db.DB.Where("session_id = ?", sessionID).Find(&screenshots)
 	for _, sc := range screenshots {
-		screenshotMap[sc.StepID] = sc.DataURL
-	}
-
-	// 构建业务视图 steps (支持按区域合并所有连续操作)
-	bizSteps := make([]DocStep, 0, len(steps))
-	techSteps := make([]DocStep, 0, len(steps))
-
-	type stepContext struct {
-		location string
-		compName string
-		purpose  string
-		verb     string
+		screenshotMap[sc.StepID] = string(sc.DataURL)
+		if sc.ElementURL != "" && sc.DataURL != "" {
+			elementMap[sc.StepID] = string(sc.ElementURL)
+		}
 	}
 
-	parseStep := func(t string, action string) stepContext {
-		ctx := stepContext{location: "页面区域", compName: "组件", purpose: "业务交互"}
-
-		// 提取位置
-		const locAnchor = "页面的 "
-		if idx := strings.Index(t, locAnchor); idx != -1 {
-			sub := t[idx+len(locAnchor):]
-			if endIdx := strings.Index(sub, "，"); endIdx != -1 {
-				ctx.location = strings.TrimSpace(sub[:endIdx])
-			}
+	// 步骤触发的接口（技术视图）
+	endpoints := stepEndpoints(sessionID, steps)
+	scriptErrors := stepErrors(sessionID)
+
+	// techStep 技术视图步骤：保留元素定位、接口与脚本错误等原始细节
+	techStep := func(s db.RecordingStep) DocStep {
+		note := fmt.Sprintf(
+			"元素：%s\nXPath：%s\nCSS：%s\nAction：%s",
+			s.TargetElement, s.TargetXPath, s.TargetSelector, s.Action,
+		)
+		desc := s.TargetElement
+		if s.FramePath != "" {
+			note += "\nFrame：" + s.FramePath
 		}
-
-		// 提取组件名
-		const compAnchor = "功能为 "
-		if idx := strings.Index(t, compAnchor); idx != -1 {
-			sub := t[idx+len(compAnchor):]
-			if endIdx := strings.Index(sub, " 的"); endIdx != -1 {
-				ctx.compName = strings.TrimSpace(sub[:endIdx])
-			}
+		if s.ViewportW > 0 {
+			note += fmt.Sprintf("\n视口：%d×%d，滚动偏移：(%d, %d)", s.ViewportW, s.ViewportH, s.ScrollX, s.ScrollY)
 		}
-
-		// 提取目的
-		const purposeAnchor = "实现 "
-		if idx := strings.Index(t, purposeAnchor); idx != -1 {
-			sub := t[idx+len(purposeAnchor):]
-			ctx.purpose = strings.TrimRight(strings.TrimSpace(sub), "。")
+		if s.TabID != 0 {
+			note += fmt.Sprintf("\n标签页：%d（窗口 %d）", s.TabID, s.WindowID)
 		}
-
-		// 提取动词 - 优先从语义描述中提取，其次根据 action 兜底
-		if strings.Contains(t, "录入了") {
-			ctx.verb = "录入"
-		} else if strings.Contains(t, "切换到") {
-			ctx.verb = "切换到"
-		} else if strings.Contains(t, "选择了") {
-			ctx.verb = "选择"
-		} else if strings.Contains(t, "点击了") {
-			ctx.verb = "点击"
-		} else {
-			switch action {
-			case "click":
-				ctx.verb = "点击"
-			case "input":
-				ctx.verb = "录入"
-			case "select":
-				ctx.verb = "选择"
-			default:
-				ctx.verb = "操作"
+		if s.SelectorStale && s.SelectorCheckedAt != nil {
+			note += "\n定位：已失效（" + s.SelectorCheckedAt.Format("2006-01-02") + " 校验时找不到元素）"
+		}
+		for _, e := range endpoints[s.ID] {
+			note += "\n接口：" + e
+		}
+		note += consoleErrorNote(scriptErrors[s.ID])
+		if s.Excluded {
+			note += "\n业务视图：已排除"
+		}
+		if s.AIProvider != "" {
+			note += "\n描述生成：" + generationMode(s)
+		}
+		if s.KeyCombo != "" {
+			note += "\n按键：" + s.KeyCombo
+			if desc == "" {
+				desc = KeyPhrase(s.Action, s.KeyCombo)
 			}
 		}
-		return ctx
+		return DocStep{
+			StepIndex:     s.StepIndex,
+			Action:        s.Action,
+			Description:   desc,
+			Title:         s.AITitle,
+			ScreenshotID:  s.ScreenshotID,
+			ScreenshotURL: screenshotMap[s.ID],
+			PageTitle:     s.PageTitle,
+			PageURL:       s.PageURL,
+			ElapsedMS:     s.ElapsedMS,
+			PositionHint:  PositionHint(&s),
+			ScriptErrors:  len(scriptErrors[s.ID]),
+			TextOnly:      s.AITextOnly,
+			TechNote:      note,
+		}
 	}
 
+	// 构建业务视图 steps (支持按区域合并所有连续操作)
+	var bizSteps, techSteps []DocStep
 	var currentGroup []db.RecordingStep
 
 	flushGroup := func() {
@@ -147,10 +421,15 @@ func (s *DocService) BuildDocument(sessionID string) (*GeneratedDocContent, erro
 			// 聚合描述生成
 			actions := []string{}
 			lastPurpose := ""
-			firstCtx := parseStep(first.TargetElement, first.Action)
+			firstCtx := parseStepContext(first.TargetElement, first.Action)
 
 			for _, s := range currentGroup {
-				ctx := parseStep(s.TargetElement, s.Action)
+				ctx := parseStepContext(s.TargetElement, s.Action)
+				if s.KeyCombo != "" {
+					// 键盘操作以按键本身作为操作对象
+					actions = append(actions, fmt.Sprintf("按下 【%s】", s.KeyCombo))
+					continue
+				}
 				actions = append(actions, fmt.Sprintf("%s 【%s】", ctx.verb, ctx.compName))
 				lastPurpose = ctx.purpose
 			}
@@ -159,74 +438,121 @@ func (s *DocService) BuildDocument(sessionID string) (*GeneratedDocContent, erro
 				first.PageTitle, firstCtx.location, strings.Join(actions, "、"), lastPurpose)
 		}
 
+		if desc == "" && first.KeyCombo != "" {
+			desc = fmt.Sprintf("在 [%s] 页面%s", first.PageTitle, KeyPhrase(first.Action, first.KeyCombo))
+		}
 		if desc == "" {
 			desc = fmt.Sprintf("在 [%s] 页面执行 %s 操作", first.PageTitle, first.Action)
 		}
 
+		var elapsed int64
+		for _, s := range currentGroup {
+			elapsed += s.ElapsedMS
+		}
+
+		// 单步优先使用目标元素局部图；合并的多步涉及多个元素，保留整屏截图以便看清上下文
+		bizScreenshot := screenshotMap[last.ID]
+		if element := elementMap[last.ID]; element != "" && len(currentGroup) == 1 {
+			bizScreenshot = element
+		}
+
 		bizStep := DocStep{
 			StepIndex:     first.StepIndex,
 			Action:        first.Action,
 			Description:   desc,
+			Title:         last.AITitle, // 合并的多步以最后一步（通常是提交、确认）为准
 			ScreenshotID:  last.ScreenshotID,
-			ScreenshotURL: screenshotMap[last.ID],
+			ScreenshotURL: bizScreenshot,
 			PageTitle:     first.PageTitle,
 			IsEdited:      first.IsEdited,
+			ElapsedMS:     elapsed,
+			PositionHint:  PositionHint(&last),
+			TextOnly:      len(currentGroup) == 1 && first.AITextOnly,
 		}
 		bizSteps = append(bizSteps, bizStep)
 
 		// 技术视图暂不合并，保持原始细节
 		for _, s := range currentGroup {
-			tStep := DocStep{
-				StepIndex:     s.StepIndex,
-				Action:        s.Action,
-				Description:   s.TargetElement,
-				ScreenshotID:  s.ScreenshotID,
-				ScreenshotURL: screenshotMap[s.ID],
-				PageTitle:     s.PageTitle,
-				PageURL:       s.PageURL,
-				TechNote: fmt.Sprintf(
-					"元素：%s\nXPath：%s\nCSS：%s\nAction：%s",
-					s.TargetElement, s.TargetXPath, s.TargetSelector, s.Action,
-				),
-			}
-			techSteps = append(techSteps, tStep)
+			techSteps = append(techSteps, techStep(s))
 		}
 
 		currentGroup = nil
 	}
 
-	for _, step := range steps {
-		if len(currentGroup) > 0 {
-			prev := currentGroup[0]
-			ctxPrev := parseStep(prev.TargetElement, prev.Action)
-			ctxCurr := parseStep(step.TargetElement, step.Action)
-
-			// 合并条件：同一页面 且 同一位置
-			canMerge := step.PageTitle == prev.PageTitle && ctxCurr.location == ctxPrev.location
+	content := &GeneratedDocContent{
+		SessionTitle:  session.Title,
+		ProjectName:   project.Name,
+		GeneratedAt:   time.Now().Format("2006-01-02 15:04:05"),
+		Metadata:      MergeMetadata(project.Metadata, nil),
+		ShowTiming:    project.ShowTiming,
+		Numbering:     project.NumberingStyle,
+		HeadingBase:   project.HeadingBase,
+		BusinessView:  []DocSection{},
+		TechnicalView: []DocSection{},
+		Media:         SessionMediaLinks(sessionID),
+	}
 
-			if !canMerge {
+	// 按导航边界切分章节，章节内再按合并策略聚合业务步骤
+	rules := MergeRules{Strategy: project.MergeStrategy, WindowSeconds: project.MergeWindowSeconds}
+	chunks := splitByNavigation(steps)
+	for i, chunk := range chunks {
+		bizSteps = make([]DocStep, 0, len(chunk.steps))
+		techSteps = make([]DocStep, 0, len(chunk.steps))
+		for _, step := range chunk.steps {
+			// 排除的步骤不进入业务视图，也不打断前后步骤的合并
+			if step.Excluded {
+				techSteps = append(techSteps, techStep(step))
+				continue
+			}
+			if len(currentGroup) > 0 && !rules.canMerge(currentGroup, step) {
 				flushGroup()
 			}
+			currentGroup = append(currentGroup, step)
 		}
-		currentGroup = append(currentGroup, step)
-	}
-	flushGroup()
+		flushGroup()
+		sort.SliceStable(techSteps, func(a, b int) bool { return techSteps[a].StepIndex < techSteps[b].StepIndex })
 
-	content := &GeneratedDocContent{
-		SessionTitle: session.Title,
-		ProjectName:  project.Name,
-		GeneratedAt:  time.Now().Format("2006-01-02 15:04:05"),
-		BusinessView: []DocSection{
-			{SectionIndex: 1, Title: session.Title + " - 操作说明", Steps: bizSteps},
-		},
-		TechnicalView: []DocSection{
-			{SectionIndex: 1, Title: session.Title + " - 技术参考", Steps: techSteps},
-		},
+		title := session.Title
+		if len(chunk.steps) > 0 && chunk.steps[0].PageTitle != "" {
+			title = chunk.steps[0].PageTitle
+		}
+		var duration int64
+		for _, st := range chunk.steps {
+			duration += st.ElapsedMS
+		}
+		content.DurationMS += duration
+		transition := tabTransition(chunks, i)
+		content.BusinessView = append(content.BusinessView, DocSection{
+			SectionIndex: i + 1, Title: title + " - 操作说明", URLPattern: chunk.pattern, Steps: bizSteps, DurationMS: duration,
+			TabID: chunk.tabID, Transition: transition,
+		})
+		content.TechnicalView = append(content.TechnicalView, DocSection{
+			SectionIndex: i + 1, Title: title + " - 技术参考", URLPattern: chunk.pattern, Steps: techSteps, DurationMS: duration,
+			TabID: chunk.tabID, Transition: transition,
+		})
 	}
 
 	return content, nil
 }
 
+// generationMode 技术视图中标注的描述来源：提供商（模型）与生成模式
+func generationMode(s db.RecordingStep) string {
+	switch s.AIProvider {
+	case "rule-based":
+		return "规则描述"
+	case ProviderReused:
+		return "画面与上一步相同，沿用上一步描述"
+	}
+	mode := "截图 + 元数据"
+	if s.AITextOnly {
+		mode = "仅文本"
+	}
+	if s.AIModel != "" {
+		return fmt.Sprintf("%s（%s），%s", s.AIProvider, s.AIModel, mode)
+	}
+	return s.AIProvider + "，" + mode
+}
+
 // SaveGeneratedDoc 保存生成的文档到数据库
 func (s *DocService) SaveGeneratedDoc(sessionID string, content *GeneratedDocContent) (*db.GeneratedDocument, error) {
 	bizJSON, _ := json.Marshal(content.BusinessView)
@@ -239,8 +565,8 @@ func (s *DocService) SaveGeneratedDoc(sessionID string, content *GeneratedDocCon
 		SessionID:     sessionID,
 		ProjectID:     session.ProjectID,
 		Status:        "draft",
-		BusinessView:  string(bizJSON),
-		TechnicalView: string(techJSON),
+		BusinessView:  db.LongText(bizJSON),
+		TechnicalView: db.LongText(techJSON),
 	}
 
 	if err := db.DB.Create(doc).Error; err != nil {
@@ -249,40 +575,239 @@ func (s *DocService) SaveGeneratedDoc(sessionID string, content *GeneratedDocCon
 
 	// 更新 session 的 generated_doc_id
 	db.DB.Model(&session).Update("generated_doc_id", doc.ID)
+	QueueEmbedding(EmbedDocument, doc.ID)
 
 	return doc, nil
 }
 
-// GenerateMarkdown 生成 Markdown 格式
+// LoadDocument 从已保存的文档恢复内容（保留生成时的章节标题、摘要等）；
+// 生成后才标记排除的步骤同样从业务视图中去掉，所有导出方式都经由此处
+func (s *DocService) LoadDocument(doc *db.GeneratedDocument) (*GeneratedDocContent, error) {
+	content, err := s.loadDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	dropExcludedSteps(content, doc.SessionID)
+	return content, nil
+}
+
+// dropExcludedSteps 去掉业务视图中已排除的步骤（合并步骤以首个步骤的序号计）
+func dropExcludedSteps(content *GeneratedDocContent, sessionID string) {
+	var indexes []int
+	db.DB.Model(&db.RecordingStep{}).Where("session_id = ? AND excluded = ?", sessionID, true).Pluck("step_index", &indexes)
+	if len(indexes) == 0 {
+		return
+	}
+	excluded := make(map[int]bool, len(indexes))
+	for _, idx := range indexes {
+		excluded[idx] = true
+	}
+	for i := range content.BusinessView {
+		sec := &content.BusinessView[i]
+		kept := make([]DocStep, 0, len(sec.Steps))
+		for _, st := range sec.Steps {
+			if !excluded[st.StepIndex] {
+				kept = append(kept, st)
+			}
+		}
+		sec.Steps = kept
+	}
+}
+
+// loadDocument 按原样恢复已保存的内容，用于需要写回文档的场景
+func (s *DocService) loadDocument(doc *db.GeneratedDocument) (*GeneratedDocContent, error) {
+	var session db.Session
+	db.DB.First(&session, "id = ?", doc.SessionID)
+	var project db.Project
+	db.DB.First(&project, "id = ?", doc.ProjectID)
+
+	content := &GeneratedDocContent{
+		SessionTitle: session.Title,
+		ProjectName:  project.Name,
+		GeneratedAt:  doc.CreatedAt.Format("2006-01-02 15:04:05"),
+		Metadata:     MergeMetadata(project.Metadata, doc.Metadata),
+		ShowTiming:   project.ShowTiming,
+		Numbering:    project.NumberingStyle,
+		HeadingBase:  project.HeadingBase,
+		Media:        SessionMediaLinks(doc.SessionID), // 附件不随文档固化，始终引用会话当前的附件
+		Watermark:    CurrentWatermark(),
+	}
+	if t, ok := FindTemplate(project.TemplateType); ok {
+		ApplyTemplate(content, t)
+	}
+	if err := json.Unmarshal([]byte(doc.BusinessView), &content.BusinessView); err != nil {
+		return nil, fmt.Errorf("invalid business view: %w", err)
+	}
+	for _, sec := range content.BusinessView {
+		content.DurationMS += sec.DurationMS
+	}
+	if err := json.Unmarshal([]byte(doc.TechnicalView), &content.TechnicalView); err != nil {
+		return nil, fmt.Errorf("invalid technical view: %w", err)
+	}
+	return content, nil
+}
+
+// GenerateMarkdown 生成 Markdown 格式；viewType 为 business | technical | both，
+// both 输出业务操作说明并附技术附录，两者的步骤互相链接
 func (s *DocService) GenerateMarkdown(content *GeneratedDocContent, viewType string) string {
 	var sb strings.Builder
 
-	sb.WriteString(fmt.Sprintf("# %s\n\n", content.SessionTitle))
-	sb.WriteString(fmt.Sprintf("> 项目：%s  \n> 生成时间：%s\n\n---\n\n", content.ProjectName, content.GeneratedAt))
+	// h1 文档标题，h2 视图、章节与补充材料，h3 步骤（合订手册中分组、章在前，章节与步骤依次下沉）；整体按项目设置的标题级别下沉
+	h1, h2, h3 := headingMarks(content.HeadingBase, 0), headingMarks(content.HeadingBase, 1), headingMarks(content.HeadingBase, 2)
+	sb.WriteString(fmt.Sprintf("%s %s\n\n", h1, content.SessionTitle))
+	// 头部信息块：项目、生成时间、自定义字段与预计耗时
+	var header []string
+	timing := content.ShowTiming && viewType != "technical"
+	if !content.HideHeader {
+		header = append(header, "项目："+content.ProjectName, "生成时间："+content.GeneratedAt)
+		for _, f := range SortedMetadata(content.Metadata) {
+			header = append(header, f.Key+"："+f.Value)
+		}
+		// 耗时提示只出现在业务视图（含组合导出中的业务部分）
+		if hint := TimingHint(content.DurationMS); timing && hint != "" {
+			header = append(header, "预计耗时："+hint)
+		}
+	}
+	// Markdown 没有页面概念，水印文字作为醒目的头部标注（不随信息块省略）
+	if content.Watermark != nil && content.Watermark.Text != "" {
+		header = append(header, "**"+content.Watermark.Text+"**")
+	}
+	if len(header) > 0 {
+		sb.WriteString("> " + strings.Join(header, "  \n> ") + "\n\n---\n\n")
+	}
+
+	// writeSections 输出一个视图的全部章节；anchor / link 非空时在步骤前插入锚点、在步骤后追加交叉链接
+	writeSections := func(sections []DocSection, timing bool, anchor func(DocStep) string, link func(DocStep) string) {
+		num := newDocNumbering(content.Numbering)
+		// withHint 在标题后追加“（约 N 分钟）”
+		withHint := func(title string, ms int64) string {
+			if hint := TimingHint(ms); timing && hint != "" {
+				return title + "（" + hint + "）"
+			}
+			return title
+		}
+		// 合订手册：分组标题与章标题使后续章节逐级下沉
+		chapterDepth, secMark, stepMark := 1, h2, h3
+		for _, section := range sections {
+			switch section.Kind {
+			case SectionGroup:
+				chapterDepth = 1
+				if section.Title != "" {
+					sb.WriteString(fmt.Sprintf("%s %s\n\n", h2, section.Title))
+					chapterDepth = 2
+				}
+				continue
+			case SectionChapter:
+				sb.WriteString(fmt.Sprintf("%s %s\n\n", headingMarks(content.HeadingBase, chapterDepth), section.Title))
+				secMark, stepMark = headingMarks(content.HeadingBase, chapterDepth+1), headingMarks(content.HeadingBase, chapterDepth+2)
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("%s %s\n\n", secMark, withHint(num.Section(section), section.DurationMS)))
+			if section.Transition != "" {
+				sb.WriteString(fmt.Sprintf("> %s\n\n", section.Transition))
+			}
+			if section.Summary != "" {
+				sb.WriteString(fmt.Sprintf("%s\n\n", section.Summary))
+			}
+			for _, item := range section.FAQ {
+				sb.WriteString(fmt.Sprintf("**问：%s**\n\n答：%s\n\n", item.Question, item.Answer))
+			}
+			for _, step := range section.Steps {
+				if anchor != nil {
+					sb.WriteString(fmt.Sprintf("<a id=\"%s\"></a>\n\n", anchor(step)))
+				}
+				sb.WriteString(fmt.Sprintf("%s %s\n\n", stepMark, withHint(num.Step(step), step.ElapsedMS)))
+				sb.WriteString(fmt.Sprintf("%s\n\n", step.Description))
+				if step.ScriptErrors > 0 {
+					sb.WriteString(fmt.Sprintf("> ⚠️ 该步骤执行期间页面抛出 %d 个脚本错误\n\n", step.ScriptErrors))
+				}
+				if step.TechNote != "" {
+					sb.WriteString(fmt.Sprintf("```\n%s\n```\n\n", step.TechNote))
+				}
+				if step.ScreenshotURL != "" {
+					sb.WriteString(fmt.Sprintf("![%s](%s)\n\n", step.ScreenshotAlt(), step.ScreenshotURL))
+				}
+				if step.PositionHint != "" {
+					sb.WriteString(fmt.Sprintf("*提示：%s*\n\n", step.PositionHint))
+				}
+				if link != nil {
+					if l := link(step); l != "" {
+						sb.WriteString(l + "\n\n")
+					}
+				}
+				sb.WriteString("---\n\n")
+			}
+		}
+	}
 
-	var sections []DocSection
-	if viewType == "technical" {
-		sections = content.TechnicalView
-		sb.WriteString("## 技术参考文档\n\n")
-	} else {
-		sections = content.BusinessView
-		sb.WriteString("## 操作说明文档\n\n")
+	if content.Preface != "" {
+		sb.WriteString(strings.TrimSpace(content.Preface) + "\n\n")
+	}
+	if content.Flowchart != "" {
+		sb.WriteString(h2 + " 流程概览\n\n```mermaid\n" + strings.TrimSpace(content.Flowchart) + "\n```\n\n")
 	}
 
-	for _, section := range sections {
-		sb.WriteString(fmt.Sprintf("## %s\n\n", section.Title))
-		for _, step := range section.Steps {
-			sb.WriteString(fmt.Sprintf("### 第 %d 步\n\n", step.StepIndex))
-			sb.WriteString(fmt.Sprintf("%s\n\n", step.Description))
-			if step.TechNote != "" {
-				sb.WriteString(fmt.Sprintf("```\n%s\n```\n\n", step.TechNote))
+	switch viewType {
+	case "technical":
+		sb.WriteString(h2 + " 技术参考文档\n\n")
+		writeSections(content.TechnicalView, false, nil, nil)
+	case "both":
+		// 业务操作说明在前，技术附录在后；业务步骤链接到其合并的首个技术步骤，技术步骤链接回所属业务步骤
+		owner := bizStepOwners(content.BusinessView)
+		bizAnchor := func(st DocStep) string { return fmt.Sprintf("step-%d", st.StepIndex) }
+		techAnchor := func(st DocStep) string { return fmt.Sprintf("tech-step-%d", st.StepIndex) }
+		sb.WriteString(h2 + " 操作说明文档\n\n")
+		writeSections(content.BusinessView, timing, bizAnchor, func(st DocStep) string {
+			return fmt.Sprintf("[技术细节 →](#%s)", techAnchor(st))
+		})
+		sb.WriteString(h2 + " 技术附录\n\n")
+		writeSections(content.TechnicalView, false, techAnchor, func(st DocStep) string {
+			idx, ok := owner(st.StepIndex)
+			if !ok {
+				return ""
 			}
-			if step.ScreenshotURL != "" {
-				sb.WriteString(fmt.Sprintf("![步骤%d截图](%s)\n\n", step.StepIndex, step.ScreenshotURL))
+			return fmt.Sprintf("[← 返回操作步骤](#step-%d)", idx)
+		})
+	default:
+		sb.WriteString(h2 + " 操作说明文档\n\n")
+		writeSections(content.BusinessView, timing, nil, nil)
+	}
+
+	if len(content.Media) > 0 {
+		sb.WriteString(h2 + " 补充材料\n\n")
+		for i, m := range content.Media {
+			name := m.FileName
+			if name == "" {
+				name = fmt.Sprintf("操作录像 %d", i+1)
 			}
-			sb.WriteString("---\n\n")
+			line := fmt.Sprintf("- [%s](%s)", name, m.URL)
+			if hint := TimingHint(m.DurationMS); hint != "" {
+				line += "（时长" + hint + "）"
+			}
+			sb.WriteString(line + "\n")
 		}
+		sb.WriteString("\n")
+	}
+	if content.Closing != "" {
+		sb.WriteString(strings.TrimSpace(content.Closing) + "\n")
 	}
 
 	return sb.String()
 }
+
+// bizStepOwners 返回技术步骤序号 → 所属业务步骤序号的查找函数：业务步骤以合并组的首个步骤编号，
+// 技术步骤归属于序号不大于它的最近一个业务步骤
+func bizStepOwners(business []DocSection) func(int) (int, bool) {
+	var starts []int
+	for _, st := range allDocSteps(business) {
+		starts = append(starts, st.StepIndex)
+	}
+	sort.Ints(starts)
+	return func(idx int) (int, bool) {
+		i := sort.SearchInts(starts, idx+1)
+		if i == 0 {
+			return 0, false
+		}
+		return starts[i-1], true
+	}
+}
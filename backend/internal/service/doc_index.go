@@ -0,0 +1,143 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// docIndexSummaryLen 目录中文档摘要的最大字数，超出部分省略
+const docIndexSummaryLen = 120
+
+// DocIndexLinks 文档的深链接：Page 为静态站点中的页面（相对路径），其余为 API 路径
+type DocIndexLinks struct {
+	Page     string `json:"page"`
+	Document string `json:"document"`
+	Export   string `json:"export"`
+}
+
+// DocIndexEntry 项目文档目录中的一篇文档
+type DocIndexEntry struct {
+	DocumentID string        `json:"document_id"`
+	SessionID  string        `json:"session_id"`
+	Title      string        `json:"title"`
+	Summary    string        `json:"summary,omitempty"`
+	StepCount  int           `json:"step_count"`
+	DurationMS int64         `json:"duration_ms,omitempty"`
+	ApprovedAt *time.Time    `json:"approved_at,omitempty"`
+	Links      DocIndexLinks `json:"links"`
+}
+
+// DocIndex 项目的跨文档目录，作为该系统文档集的首页
+type DocIndex struct {
+	ProjectID   string          `json:"project_id"`
+	ProjectName string          `json:"project_name"`
+	GeneratedAt string          `json:"generated_at"`
+	Documents   []DocIndexEntry `json:"documents"`
+}
+
+// latestApprovedDocs 项目下的已审批文档，同一会话只取最新审批的一份，按审批时间倒序
+func latestApprovedDocs(projectID string) ([]db.GeneratedDocument, error) {
+	var docs []db.GeneratedDocument
+	if err := db.DB.Where("project_id = ? AND status = ?", projectID, "approved").
+		Order("approved_at DESC, created_at DESC").Find(&docs).Error; err != nil {
+		return nil, err
+	}
+	latest := docs[:0]
+	seen := map[string]bool{}
+	for _, doc := range docs {
+		if !seen[doc.SessionID] {
+			seen[doc.SessionID] = true
+			latest = append(latest, doc)
+		}
+	}
+	return latest, nil
+}
+
+// ProjectDocIndex 列出项目下所有已审批文档（同一会话取最新一份）的标题、摘要、步骤数与深链接，
+// 顺序与静态站点首页一致
+func (s *DocService) ProjectDocIndex(projectID string) (*DocIndex, error) {
+	var project db.Project
+	if err := db.DB.First(&project, "id = ?", projectID).Error; err != nil {
+		return nil, err
+	}
+	docs, err := latestApprovedDocs(projectID)
+	if err != nil {
+		return nil, err
+	}
+	idx := &DocIndex{
+		ProjectID:   project.ID,
+		ProjectName: project.Name,
+		GeneratedAt: time.Now().Format("2006-01-02 15:04:05"),
+		Documents:   []DocIndexEntry{},
+	}
+	for i := range docs {
+		doc := &docs[i]
+		content, err := s.LoadDocument(doc)
+		if err != nil {
+			return nil, fmt.Errorf("document %s: %w", doc.ID, err)
+		}
+		idx.Documents = append(idx.Documents, DocIndexEntry{
+			DocumentID: doc.ID,
+			SessionID:  doc.SessionID,
+			Title:      content.SessionTitle,
+			Summary:    docSummary(content),
+			StepCount:  len(allDocSteps(content.BusinessView)),
+			DurationMS: content.DurationMS,
+			ApprovedAt: doc.ApprovedAt,
+			Links: DocIndexLinks{
+				Page:     sitePage(doc.ID),
+				Document: "/api/v1/documents/" + doc.ID,
+				Export:   "/api/v1/documents/" + doc.ID + "/export?format=md",
+			},
+		})
+	}
+	return idx, nil
+}
+
+// Markdown 目录的 Markdown 版本，文档标题链接到静态站点中的页面，与站点放在同一目录即可导航
+func (idx *DocIndex) Markdown() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s · 文档目录\n\n", idx.ProjectName))
+	if len(idx.Documents) == 0 {
+		sb.WriteString("暂无已审批文档\n\n")
+	}
+	for _, d := range idx.Documents {
+		meta := fmt.Sprintf("%d 步", d.StepCount)
+		if d.ApprovedAt != nil {
+			meta += " · 审批于 " + d.ApprovedAt.Format("2006-01-02")
+		}
+		sb.WriteString(fmt.Sprintf("- [%s](%s)（%s）\n", d.Title, d.Links.Page, meta))
+		if d.Summary != "" {
+			sb.WriteString("  " + d.Summary + "\n")
+		}
+	}
+	sb.WriteString(fmt.Sprintf("\n*生成时间：%s*\n", idx.GeneratedAt))
+	return sb.String()
+}
+
+// docSummary 文档摘要：优先取流程概述，其次为首个有说明的章节；合并换行并截断
+func docSummary(content *GeneratedDocContent) string {
+	var summary string
+	for _, sec := range content.BusinessView {
+		if sec.Kind == SectionOverview && sec.Summary != "" {
+			summary = sec.Summary
+			break
+		}
+		if summary == "" && sec.Kind == "" {
+			summary = sec.Summary
+		}
+	}
+	summary = strings.Join(strings.Fields(summary), " ")
+	if short := truncateRunes(summary, docIndexSummaryLen); short != summary {
+		summary = short + "…"
+	}
+	return summary
+}
+
+// sitePage 文档在静态站点中的页面文件名
+func sitePage(docID string) string {
+	return "doc-" + shortID(docID) + ".html"
+}
@@ -21,10 +21,9 @@ func setupDB(t *testing.T) {
 	if err != nil {
 		t.Fatalf("open DB: %v", err)
 	}
-	db.DB.AutoMigrate(
-		&db.Project{}, &db.Session{}, &db.RecordingStep{},
-		&db.Screenshot{}, &db.GeneratedDocument{}, &db.LLMProvider{},
-	)
+	if err := db.Migrate(db.DB); err != nil {
+		t.Fatalf("migrate DB: %v", err)
+	}
 }
 
 // ─────────────────────────────────────
@@ -75,6 +74,15 @@ func seedSessionWithSteps(t *testing.T, stepCount int) (projectID, sessionID str
 	return proj.ID, sess.ID
 }
 
+// allSteps 展开所有章节的步骤
+func allSteps(sections []service.DocSection) []service.DocStep {
+	var steps []service.DocStep
+	for _, sec := range sections {
+		steps = append(steps, sec.Steps...)
+	}
+	return steps
+}
+
 func TestBuildDocument_NormalFlow(t *testing.T) {
 	setupDB(t)
 	_, sessionID := seedSessionWithSteps(t, 5)
@@ -104,8 +112,8 @@ func TestBuildDocument_NormalFlow(t *testing.T) {
 		t.Fatal("technical_view is empty!")
 	}
 
-	bizSteps := content.BusinessView[0].Steps
-	techSteps := content.TechnicalView[0].Steps
+	bizSteps := allSteps(content.BusinessView)
+	techSteps := allSteps(content.TechnicalView)
 
 	if len(bizSteps) != 5 {
 		t.Errorf("expected 5 biz steps, got %d", len(bizSteps))
@@ -192,7 +200,7 @@ func TestBuildDocument_WithScreenshots(t *testing.T) {
 	}
 
 	// 验证截图被加载
-	for i, s := range content.BusinessView[0].Steps {
+	for i, s := range allSteps(content.BusinessView) {
 		if s.ScreenshotURL == "" {
 			t.Errorf("step %d missing screenshot_url", i+1)
 		}
@@ -279,6 +287,61 @@ func TestGenerateMarkdown_TechnicalView(t *testing.T) {
 	}
 }
 
+func TestGenerateMarkdown_BothViews(t *testing.T) {
+	setupDB(t)
+	_, sessionID := seedSessionWithSteps(t, 2)
+
+	svc := service.NewDocService()
+	content, _ := svc.BuildDocument(sessionID)
+	md := svc.GenerateMarkdown(content, "both")
+
+	biz, tech, ok := strings.Cut(md, "## 技术附录")
+	if !ok || !strings.Contains(biz, "## 操作说明文档") {
+		t.Fatalf("expected business walkthrough followed by technical appendix:\n%s", md)
+	}
+	if !containsAll(biz, `<a id="step-1"></a>`, "[技术细节 →](#tech-step-1)") || strings.Contains(biz, "元素：") {
+		t.Errorf("business part should link to technical detail only:\n%s", biz)
+	}
+	if !containsAll(tech, `<a id="tech-step-1"></a>`, `<a id="tech-step-2"></a>`, "元素：", "[← 返回操作步骤](#step-") {
+		t.Errorf("technical appendix should carry anchors and back links:\n%s", tech)
+	}
+	// 单视图导出不带锚点
+	if strings.Contains(svc.GenerateMarkdown(content, "business"), "<a id=") {
+		t.Error("business-only export should not contain anchors")
+	}
+}
+
+func TestGenerateMarkdown_Numbering(t *testing.T) {
+	svc := service.NewDocService()
+	content := &service.GeneratedDocContent{
+		SessionTitle: "办理流程",
+		BusinessView: []service.DocSection{
+			{Kind: service.SectionOverview, Title: "流程概述", Steps: []service.DocStep{}},
+			{Title: "登录", Steps: []service.DocStep{{StepIndex: 1}, {StepIndex: 2}}},
+			{Title: "填写申请", Steps: []service.DocStep{{StepIndex: 3}}},
+		},
+	}
+	cases := []struct {
+		style string
+		base  int
+		want  []string
+	}{
+		{"", 0, []string{"# 办理流程", "## 流程概述", "## 登录", "### 第 2 步", "### 第 3 步"}},
+		{service.NumberingHierarchical, 0, []string{"## 流程概述", "## 1 登录", "### 1.2", "## 2 填写申请", "### 2.1"}},
+		{service.NumberingEnglish, 3, []string{"### 办理流程", "#### 登录", "##### Step 3"}},
+	}
+	for _, tc := range cases {
+		content.Numbering = tc.style
+		content.HeadingBase = tc.base
+		md := svc.GenerateMarkdown(content, "business")
+		for _, w := range tc.want {
+			if !strings.Contains(md, w+"\n") {
+				t.Errorf("style %q base %d: markdown missing %q:\n%s", tc.style, tc.base, w, md)
+			}
+		}
+	}
+}
+
 // ─────────────────────────────────────
 // effectiveCfg 测试（DB 配置覆盖环境变量）
 // ─────────────────────────────────────
@@ -315,3 +378,237 @@ func TestEffectiveCfg_DBOverridesEnv(t *testing.T) {
 	}
 	t.Logf("✅ DB config correctly overrides env var for gemini")
 }
+
+// ─────────────────────────────────────
+// 数据保留策略测试
+// ─────────────────────────────────────
+
+func TestRetention_PurgesExpiredData(t *testing.T) {
+	setupDB(t)
+	projectID, sessionID := seedSessionWithSteps(t, 2)
+	db.DB.Create(&db.Screenshot{SessionID: sessionID, StepID: "s1", DataURL: "data:image/png;base64,AAAA"})
+
+	approvedAt := time.Now().AddDate(0, 0, -10)
+	db.DB.Create(&db.GeneratedDocument{SessionID: sessionID, ProjectID: projectID, Status: "approved", ApprovedAt: &approvedAt})
+
+	// 一个很旧的会话
+	old := db.Session{ProjectID: projectID, Title: "旧会话"}
+	db.DB.Create(&old)
+	db.DB.Model(&old).UpdateColumn("created_at", time.Now().AddDate(-2, 0, 0))
+	db.DB.Create(&db.RecordingStep{SessionID: old.ID, StepIndex: 1, Action: "click"})
+
+	db.DB.Model(&db.Project{}).Where("id = ?", projectID).Updates(map[string]interface{}{
+		"screenshot_retention_days": 7,
+		"session_retention_days":    365,
+	})
+
+	res, err := service.NewRetentionService(0, "").RunOnce(time.Now())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	if res.ScreenshotsPurged != 1 || res.SessionsPurged != 1 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	var sc db.Screenshot
+	db.DB.First(&sc, "session_id = ?", sessionID)
+	if !sc.IsRawDeleted || sc.DataURL != "" {
+		t.Errorf("screenshot should be purged: %+v", sc)
+	}
+	var count int64
+	db.DB.Model(&db.RecordingStep{}).Where("session_id = ?", old.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("expected steps of expired session removed, got %d", count)
+	}
+	db.DB.Model(&db.Session{}).Where("id = ?", sessionID).Count(&count)
+	if count != 1 {
+		t.Error("recent session should be kept")
+	}
+}
+
+// ─────────────────────────────────────
+// 合并策略测试
+// ─────────────────────────────────────
+
+func TestBuildDocument_MergeStrategies(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "合并策略"}
+	db.DB.Create(&proj)
+	sess := db.Session{ProjectID: proj.ID, Title: "表单填写"}
+	db.DB.Create(&sess)
+
+	base := time.Now().UnixMilli()
+	xpaths := []string{
+		"/html/body/form[1]/input[1]",
+		"/html/body/form[1]/input[2]",
+		"/html/body/form[2]/button[1]",
+		"/html/body/div/a[1]",
+	}
+	offsets := []int64{0, 5000, 10000, 120000}
+	for i := range xpaths {
+		db.DB.Create(&db.RecordingStep{
+			SessionID: sess.ID, StepIndex: i + 1, Action: "click",
+			PageTitle: "申请表", TargetXPath: xpaths[i], Timestamp: base + offsets[i],
+			AIDescription: "描述",
+		})
+	}
+
+	cases := map[string]int{
+		service.MergeOff:        4,
+		service.MergeByPage:     1,
+		service.MergeByForm:     3,
+		service.MergeByTime:     2,
+		service.MergeByLocation: 1,
+	}
+	docSvc := service.NewDocService()
+	for strategy, want := range cases {
+		db.DB.Model(&proj).Updates(map[string]interface{}{"merge_strategy": strategy, "merge_window_seconds": 30})
+		content, err := docSvc.BuildDocument(sess.ID)
+		if err != nil {
+			t.Fatalf("%s: %v", strategy, err)
+		}
+		if got := len(content.BusinessView[0].Steps); got != want {
+			t.Errorf("strategy %s: expected %d business steps, got %d", strategy, want, got)
+		}
+		if got := len(content.TechnicalView[0].Steps); got != 4 {
+			t.Errorf("strategy %s: technical view should keep all steps, got %d", strategy, got)
+		}
+	}
+}
+
+// ─────────────────────────────────────
+// 章节切分测试
+// ─────────────────────────────────────
+
+func TestBuildDocument_SplitsSectionsOnNavigation(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "章节切分", MergeStrategy: service.MergeOff}
+	db.DB.Create(&proj)
+	sess := db.Session{ProjectID: proj.ID, Title: "办件流程"}
+	db.DB.Create(&sess)
+
+	urls := []string{
+		"http://gov.example.com/cases",
+		"http://gov.example.com/cases?page=2",
+		"http://gov.example.com/cases/1001/edit",
+		"http://gov.example.com/cases/1002/edit",
+		"",
+		"http://gov.example.com/#/report",
+	}
+	for i, u := range urls {
+		db.DB.Create(&db.RecordingStep{
+			SessionID: sess.ID, StepIndex: i + 1, Action: "click",
+			PageTitle: "页面" + string(rune('A'+i)), PageURL: u, AIDescription: "描述",
+		})
+	}
+
+	content, err := service.NewDocService().BuildDocument(sess.ID)
+	if err != nil {
+		t.Fatalf("BuildDocument: %v", err)
+	}
+	want := []int{2, 3, 1}
+	if len(content.BusinessView) != len(want) {
+		t.Fatalf("expected %d sections, got %d", len(want), len(content.BusinessView))
+	}
+	for i, sec := range content.BusinessView {
+		if len(sec.Steps) != want[i] {
+			t.Errorf("section %d: expected %d steps, got %d", i+1, want[i], len(sec.Steps))
+		}
+		if sec.SectionIndex != i+1 {
+			t.Errorf("section %d has index %d", i+1, sec.SectionIndex)
+		}
+	}
+	if p := content.BusinessView[1].URLPattern; p != "gov.example.com/cases/:id/edit" {
+		t.Errorf("unexpected url pattern %q", p)
+	}
+	if len(content.TechnicalView) != len(want) {
+		t.Errorf("technical view should share section boundaries, got %d", len(content.TechnicalView))
+	}
+}
+
+func TestBuildDocument_SplitsSectionsByTab(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "多窗口", MergeStrategy: service.MergeOff}
+	db.DB.Create(&proj)
+	sess := db.Session{ProjectID: proj.ID, Title: "审批流程"}
+	db.DB.Create(&sess)
+
+	steps := []db.RecordingStep{
+		{TabID: 1, WindowID: 1, PageTitle: "待办列表", PageURL: "http://oa.example.com/todo"},
+		{TabID: 1, WindowID: 1, PageTitle: "待办列表", PageURL: "http://oa.example.com/todo", FramePath: "iframe#content"},
+		{TabID: 2, WindowID: 7, PageTitle: "选择审批人", PageURL: "http://oa.example.com/todo"},
+		{TabID: 1, WindowID: 1, PageTitle: "待办列表", PageURL: "http://oa.example.com/todo"},
+		{TabID: 3, WindowID: 1, PageTitle: "附件预览", PageURL: "http://oa.example.com/preview"},
+	}
+	for i := range steps {
+		steps[i].SessionID, steps[i].StepIndex, steps[i].Action, steps[i].AIDescription = sess.ID, i+1, "click", "描述"
+		db.DB.Create(&steps[i])
+	}
+
+	content, err := service.NewDocService().BuildDocument(sess.ID)
+	if err != nil {
+		t.Fatalf("BuildDocument: %v", err)
+	}
+	want := []struct {
+		steps      int
+		transition string
+	}{
+		{2, ""},
+		{1, "系统弹出新窗口「选择审批人」，在该窗口中继续操作"},
+		{1, "返回「待办列表」窗口继续操作"},
+		{1, "系统在新标签页中打开「附件预览」，切换到该标签页继续操作"},
+	}
+	if len(content.BusinessView) != len(want) {
+		t.Fatalf("expected %d sections, got %d", len(want), len(content.BusinessView))
+	}
+	for i, w := range want {
+		sec := content.BusinessView[i]
+		if len(sec.Steps) != w.steps || sec.Transition != w.transition {
+			t.Errorf("section %d: got %d steps, transition %q", i+1, len(sec.Steps), sec.Transition)
+		}
+	}
+	if note := content.TechnicalView[0].Steps[1].TechNote; !strings.Contains(note, "Frame：iframe#content") {
+		t.Errorf("technical note should include frame path, got %q", note)
+	}
+	md := service.NewDocService().GenerateMarkdown(content, "business")
+	if !strings.Contains(md, "> 返回「待办列表」窗口继续操作") {
+		t.Error("markdown should describe tab switches")
+	}
+}
+
+func TestBuildDocument_ExcludedSteps(t *testing.T) {
+	setupDB(t)
+	projectID, sessionID := seedSessionWithSteps(t, 5)
+	db.DB.Model(&db.Project{}).Where("id = ?", projectID).Update("merge_strategy", service.MergeOff)
+	svc := service.NewDocService()
+
+	// 生成后再排除：已保存的文档在加载（导出）时同样去掉该步骤
+	content, _ := svc.BuildDocument(sessionID)
+	doc, _ := svc.SaveGeneratedDoc(sessionID, content)
+	db.DB.Model(&db.RecordingStep{}).Where("session_id = ? AND step_index = ?", sessionID, 2).Update("excluded", true)
+
+	loaded, _ := svc.LoadDocument(doc)
+	for _, content := range []*service.GeneratedDocContent{loaded, mustBuild(t, svc, sessionID)} {
+		biz := allSteps(content.BusinessView)
+		if len(biz) != 4 || strings.Contains(svc.GenerateMarkdown(content, "business"), "点击登录按钮") {
+			t.Errorf("excluded step should be left out of the business view: %+v", biz)
+		}
+		tech := allSteps(content.TechnicalView)
+		if len(tech) != 5 {
+			t.Errorf("technical view should keep every step, got %d", len(tech))
+		}
+	}
+	tech := allSteps(mustBuild(t, svc, sessionID).TechnicalView)
+	if tech[1].StepIndex != 2 || !strings.Contains(tech[1].TechNote, "业务视图：已排除") {
+		t.Errorf("technical view should flag the excluded step in order: %+v", tech[1])
+	}
+}
+
+func mustBuild(t *testing.T, svc *service.DocService, sessionID string) *service.GeneratedDocContent {
+	t.Helper()
+	content, err := svc.BuildDocument(sessionID)
+	if err != nil {
+		t.Fatalf("BuildDocument: %v", err)
+	}
+	return content
+}
@@ -0,0 +1,646 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gpilot/backend/internal/config"
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// ErrEmbeddingDisabled 未启用语义检索（embedding.provider 为空）
+var ErrEmbeddingDisabled = errors.New("semantic search is not configured")
+
+// 向量索引的来源类型
+const (
+	EmbedStep     = "step"     // 步骤描述
+	EmbedDocument = "document" // 会话的最新文档（标题、概述与章节）
+)
+
+// Embedder 文本向量化引擎
+type Embedder interface {
+	// Model 模型标识，写入索引；更换模型后旧向量不参与检索，重建索引时重新向量化
+	Model() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// localEmbeddingDims 内置向量的维度
+const localEmbeddingDims = 512
+
+// LocalEmbedder 内置的字词哈希向量：英文与数字按词、汉字按单字与相邻双字散列到固定维度，
+// 无需外部服务，能匹配用词相近的描述；需要真正按语义检索时配置提供商的向量模型
+type LocalEmbedder struct{}
+
+// Model 实现 Embedder
+func (LocalEmbedder) Model() string {
+	return fmt.Sprintf("local-hash-%d", localEmbeddingDims)
+}
+
+// Embed 实现 Embedder
+func (LocalEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, localEmbeddingDims)
+		for _, tok := range embeddingTokens(text) {
+			h := fnv.New32a()
+			h.Write([]byte(tok))
+			x := h.Sum32()
+			if x>>31 == 1 {
+				v[x%localEmbeddingDims]--
+			} else {
+				v[x%localEmbeddingDims]++
+			}
+		}
+		out[i] = normalizeVector(v)
+	}
+	return out, nil
+}
+
+// embeddingTokens 英文与数字按词切分（转小写），汉字取单字与相邻双字
+func embeddingTokens(text string) []string {
+	var tokens []string
+	var word []rune
+	var prevHan rune
+	flush := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flush()
+			tokens = append(tokens, string(r))
+			if prevHan != 0 {
+				tokens = append(tokens, string([]rune{prevHan, r}))
+			}
+			prevHan = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		default:
+			flush()
+		}
+		prevHan = 0
+	}
+	flush()
+	return tokens
+}
+
+// defaultEmbeddingModels 各提供商未配置 embedding.model 时使用的向量模型
+var defaultEmbeddingModels = map[string]string{
+	"openai": "text-embedding-3-small",
+	"zhipu":  "embedding-3",
+	"ollama": "bge-m3",
+}
+
+// providerEmbedder 调用模型提供商的向量接口；每次调用读取当前的提供商配置（含 DB 中保存的密钥、地址与代理）
+type providerEmbedder struct {
+	ai       *AIService
+	provider string
+	model    string
+}
+
+// Model 实现 Embedder
+func (e providerEmbedder) Model() string {
+	return e.provider + ":" + e.model
+}
+
+// Embed 实现 Embedder
+func (e providerEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	cfg := e.ai.effectiveCfg()
+	apiKey, baseURL, _ := providerFields(cfg, e.provider)
+	if baseURL == nil {
+		return nil, fmt.Errorf("unknown embedding provider %q", e.provider)
+	}
+	client := e.ai.httpClient(cfg, e.provider)
+
+	url, auth := *baseURL+"/embeddings", ""
+	if e.provider == "ollama" {
+		url = *baseURL + "/api/embed"
+	} else {
+		if *apiKey == "" {
+			return nil, fmt.Errorf("%s: api key is not configured", e.provider)
+		}
+		auth = "Bearer " + *apiKey
+	}
+	data, _ := json.Marshal(map[string]interface{}{"model": e.model, "input": texts})
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, &statusError{provider: e.provider, code: resp.StatusCode, body: string(b)}
+	}
+
+	// Ollama 返回 {"embeddings": [[...]]}，OpenAI 兼容接口返回 {"data": [{"index": 0, "embedding": [...]}]}
+	var result struct {
+		Embeddings [][]float32 `json:"embeddings"`
+		Data       []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	vectors := result.Embeddings
+	if e.provider != "ollama" {
+		vectors = make([][]float32, len(result.Data))
+		for _, d := range result.Data {
+			if d.Index < 0 || d.Index >= len(vectors) {
+				return nil, fmt.Errorf("%s: embedding index %d out of range", e.provider, d.Index)
+			}
+			vectors[d.Index] = d.Embedding
+		}
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("%s: got %d embeddings for %d texts", e.provider, len(vectors), len(texts))
+	}
+	return vectors, nil
+}
+
+var embeddingState struct {
+	sync.RWMutex
+	embedder Embedder
+	timeout  time.Duration
+}
+
+// ConfigureEmbedding 按配置选择向量模型：local 使用内置向量，其余提供商经 ai 读取地址、密钥与代理；
+// 未配置提供商时关闭语义检索
+func ConfigureEmbedding(cfg config.EmbeddingConfig, ai *AIService) {
+	switch cfg.Provider {
+	case "":
+		SetEmbedder(nil, 0)
+	case "local":
+		SetEmbedder(LocalEmbedder{}, cfg.Timeout)
+	default:
+		model := cfg.Model
+		if model == "" {
+			model = defaultEmbeddingModels[cfg.Provider]
+		}
+		SetEmbedder(providerEmbedder{ai: ai, provider: cfg.Provider, model: model}, cfg.Timeout)
+	}
+}
+
+// SetEmbedder 替换向量化引擎，nil 关闭语义检索
+func SetEmbedder(e Embedder, timeout time.Duration) {
+	embeddingState.Lock()
+	defer embeddingState.Unlock()
+	embeddingState.embedder, embeddingState.timeout = e, timeout
+}
+
+// EmbeddingModel 当前的向量模型标识，未启用语义检索时为空
+func EmbeddingModel() string {
+	embeddingState.RLock()
+	defer embeddingState.RUnlock()
+	if embeddingState.embedder == nil {
+		return ""
+	}
+	return embeddingState.embedder.Model()
+}
+
+func currentEmbedder() (Embedder, time.Duration) {
+	embeddingState.RLock()
+	defer embeddingState.RUnlock()
+	return embeddingState.embedder, embeddingState.timeout
+}
+
+// embed 在超时内向量化一批文本；embedSlots 限制同时进行的请求数，避免批量生成时压垮提供商
+func embed(e Embedder, timeout time.Duration, texts []string) ([][]float32, error) {
+	embedSlots <- struct{}{}
+	defer func() { <-embedSlots }()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	vectors, err := e.Embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(vectors), len(texts))
+	}
+	return vectors, nil
+}
+
+var embedSlots = make(chan struct{}, 2)
+
+const (
+	embedBatch        = 16   // 单次请求向量化的文本条数
+	maxEmbedTextRunes = 2000 // 单条文本的长度上限（字符），超出部分截断
+)
+
+// embedItem 待索引的一条文本
+type embedItem struct {
+	sourceType, sourceID, sessionID, text string
+}
+
+func (it embedItem) key() string { return it.sourceType + "/" + it.sourceID }
+
+// saveEmbeddings 向量化并保存条目，返回实际向量化的条数；文本与模型都未变的条目跳过
+func saveEmbeddings(items []embedItem) (int, error) {
+	e, timeout := currentEmbedder()
+	if e == nil {
+		return 0, ErrEmbeddingDisabled
+	}
+	if len(items) == 0 {
+		return 0, nil
+	}
+	model := e.Model()
+	sessionIDs := map[string]bool{}
+	for _, it := range items {
+		sessionIDs[it.sessionID] = true
+	}
+	var existing []db.Embedding
+	if err := db.DB.Select("source_type", "source_id", "model", "text_hash").
+		Where("session_id IN ?", mapKeys(sessionIDs)).Find(&existing).Error; err != nil {
+		return 0, err
+	}
+	current := map[string]string{}
+	for _, row := range existing {
+		current[row.SourceType+"/"+row.SourceID] = row.Model + "|" + row.TextHash
+	}
+	var pending []embedItem
+	for _, it := range items {
+		if current[it.key()] != model+"|"+textHash(it.text) {
+			pending = append(pending, it)
+		}
+	}
+
+	embedded := 0
+	for start := 0; start < len(pending); start += embedBatch {
+		batch := pending[start:min(start+embedBatch, len(pending))]
+		texts := make([]string, len(batch))
+		for i, it := range batch {
+			texts[i] = it.text
+		}
+		vectors, err := embed(e, timeout, texts)
+		if err != nil {
+			return embedded, err
+		}
+		for i, it := range batch {
+			row := db.Embedding{
+				SourceType: it.sourceType, SourceID: it.sourceID, SessionID: it.sessionID,
+				Model: model, TextHash: textHash(it.text), Text: it.text, Vector: encodeVector(vectors[i]),
+			}
+			err := db.DB.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Where("source_type = ? AND source_id = ?", it.sourceType, it.sourceID).
+					Delete(&db.Embedding{}).Error; err != nil {
+					return err
+				}
+				return tx.Create(&row).Error
+			})
+			if err != nil {
+				return embedded, err
+			}
+			embedded++
+		}
+	}
+	return embedded, nil
+}
+
+// stepEmbeddingItem 步骤的索引文本：“页面标题：描述”；没有描述、已排除或疑似重复的步骤不索引
+func stepEmbeddingItem(step *db.RecordingStep) (embedItem, bool) {
+	if step.AIDescription == "" || step.Excluded || step.DuplicateOf != "" {
+		return embedItem{}, false
+	}
+	text := step.AIDescription
+	if step.PageTitle != "" {
+		text = step.PageTitle + "：" + text
+	}
+	return embedItem{sourceType: EmbedStep, sourceID: step.ID, sessionID: step.SessionID, text: clipEmbedText(text)}, true
+}
+
+// documentEmbeddingItem 文档的索引文本：会话标题，以及业务视图各章节的标题与说明
+func documentEmbeddingItem(doc *db.GeneratedDocument, session *db.Session) (embedItem, error) {
+	var sections []DocSection
+	if err := json.Unmarshal([]byte(doc.BusinessView), &sections); err != nil {
+		return embedItem{}, fmt.Errorf("document %s: %w", doc.ID, err)
+	}
+	lines := []string{session.Title}
+	for _, sec := range sections {
+		if sec.Kind == SectionFAQ {
+			continue
+		}
+		lines = append(lines, strings.TrimSpace(sec.Title+" "+sec.Summary))
+	}
+	return embedItem{sourceType: EmbedDocument, sourceID: doc.ID, sessionID: doc.SessionID, text: clipEmbedText(strings.Join(lines, "\n"))}, nil
+}
+
+func clipEmbedText(text string) string {
+	return truncateRunes(strings.TrimSpace(text), maxEmbedTextRunes)
+}
+
+// IndexStepEmbedding 更新单个步骤的向量索引；步骤不再需要索引时删除旧条目
+func IndexStepEmbedding(stepID string) error {
+	var step db.RecordingStep
+	if err := db.DB.First(&step, "id = ?", stepID).Error; err != nil {
+		return err
+	}
+	item, ok := stepEmbeddingItem(&step)
+	if !ok {
+		return db.DB.Where("source_type = ? AND source_id = ?", EmbedStep, stepID).Delete(&db.Embedding{}).Error
+	}
+	_, err := saveEmbeddings([]embedItem{item})
+	return err
+}
+
+// IndexDocumentEmbedding 更新文档的向量索引：只索引会话当前的文档，被新版本取代的旧文档条目一并删除
+func IndexDocumentEmbedding(docID string) error {
+	var doc db.GeneratedDocument
+	if err := db.DB.First(&doc, "id = ?", docID).Error; err != nil {
+		return err
+	}
+	var session db.Session
+	if err := db.DB.First(&session, "id = ?", doc.SessionID).Error; err != nil {
+		return err
+	}
+	if session.GeneratedDocID != doc.ID {
+		return nil
+	}
+	item, err := documentEmbeddingItem(&doc, &session)
+	if err != nil {
+		return err
+	}
+	if _, err := saveEmbeddings([]embedItem{item}); err != nil {
+		return err
+	}
+	return db.DB.Where("source_type = ? AND session_id = ? AND source_id <> ?", EmbedDocument, doc.SessionID, doc.ID).
+		Delete(&db.Embedding{}).Error
+}
+
+// QueueEmbedding 后台更新步骤或文档的向量索引（未启用语义检索时忽略），失败只记录日志
+func QueueEmbedding(sourceType, id string) {
+	if id == "" || EmbeddingModel() == "" {
+		return
+	}
+	go func() {
+		index := IndexStepEmbedding
+		if sourceType == EmbedDocument {
+			index = IndexDocumentEmbedding
+		}
+		if err := index(id); err != nil {
+			log.Printf("⚠️ embedding %s %s: %v", sourceType, id, err)
+		}
+	}()
+}
+
+// EmbeddingReindexResult 重建向量索引的结果
+type EmbeddingReindexResult struct {
+	Steps     int   `json:"steps"`     // 参与索引的步骤数
+	Documents int   `json:"documents"` // 参与索引的文档数
+	Embedded  int   `json:"embedded"`  // 本次重新向量化的条数（文本与模型都未变的跳过）
+	Removed   int64 `json:"removed"`   // 删除的失效条目（描述已清空、步骤已排除、文档已被取代等）
+}
+
+// ReindexEmbeddings 重建项目（projectID 为空时为全部项目）的向量索引：补齐缺失与过期的条目，删除失效条目；
+// 更换向量模型后调用即可全部重新向量化
+func ReindexEmbeddings(projectID string) (*EmbeddingReindexResult, error) {
+	if EmbeddingModel() == "" {
+		return nil, ErrEmbeddingDisabled
+	}
+	sessionQuery := db.DB.Model(&db.Session{})
+	if projectID != "" {
+		if err := db.DB.First(&db.Project{}, "id = ?", projectID).Error; err != nil {
+			return nil, err
+		}
+		sessionQuery = sessionQuery.Where("project_id = ?", projectID)
+	}
+	var sessions []db.Session
+	if err := sessionQuery.Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	result := &EmbeddingReindexResult{}
+	if len(sessions) == 0 {
+		return result, nil
+	}
+	ids := make([]string, len(sessions))
+	for i, s := range sessions {
+		ids[i] = s.ID
+	}
+
+	var items []embedItem
+	var steps []db.RecordingStep
+	if err := db.DB.Where("session_id IN ? AND excluded = ? AND duplicate_of = ''", ids, false).
+		Order("session_id, step_index").Find(&steps).Error; err != nil {
+		return nil, err
+	}
+	for i := range steps {
+		if item, ok := stepEmbeddingItem(&steps[i]); ok {
+			items = append(items, item)
+			result.Steps++
+		}
+	}
+	for i := range sessions {
+		session := &sessions[i]
+		if session.GeneratedDocID == "" {
+			continue
+		}
+		var doc db.GeneratedDocument
+		if err := db.DB.First(&doc, "id = ?", session.GeneratedDocID).Error; err != nil {
+			continue
+		}
+		item, err := documentEmbeddingItem(&doc, session)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		result.Documents++
+	}
+
+	keep := map[string]bool{}
+	for _, it := range items {
+		keep[it.key()] = true
+	}
+	var existing []db.Embedding
+	if err := db.DB.Select("id", "source_type", "source_id").Where("session_id IN ?", ids).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	var stale []string
+	for _, row := range existing {
+		if !keep[row.SourceType+"/"+row.SourceID] {
+			stale = append(stale, row.ID)
+		}
+	}
+	if len(stale) > 0 {
+		res := db.DB.Where("id IN ?", stale).Delete(&db.Embedding{})
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		result.Removed = res.RowsAffected
+	}
+
+	embedded, err := saveEmbeddings(items)
+	result.Embedded = embedded
+	if err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// SemanticHit 语义检索结果，Score 为余弦相似度（越大越相关）
+type SemanticHit struct {
+	SourceType   string  `json:"source_type"`
+	SessionID    string  `json:"session_id"`
+	SessionTitle string  `json:"session_title"`
+	StepID       string  `json:"step_id,omitempty"`
+	StepIndex    int     `json:"step_index,omitempty"`
+	DocumentID   string  `json:"document_id,omitempty"`
+	Text         string  `json:"text"`
+	Score        float64 `json:"score"`
+}
+
+// SemanticSearch 按语义检索步骤与文档（如“怎么退回申请”），可按项目、会话与来源类型（step | document）限定范围；
+// 只比较当前模型生成的向量，按相似度从高到低返回前 limit 条
+func SemanticSearch(query, projectID, sessionID, sourceType string, limit int) ([]SemanticHit, error) {
+	e, timeout := currentEmbedder()
+	if e == nil {
+		return nil, ErrEmbeddingDisabled
+	}
+	vectors, err := embed(e, timeout, []string{clipEmbedText(query)})
+	if err != nil {
+		return nil, err
+	}
+	q := vectors[0]
+
+	var rows []struct {
+		SourceType   string
+		SourceID     string
+		SessionID    string
+		SessionTitle string
+		StepIndex    int
+		Text         string
+		Vector       []byte
+	}
+	tx := db.DB.Table("embeddings").
+		Select("embeddings.source_type, embeddings.source_id, embeddings.session_id, sessions.title AS session_title, "+
+			"COALESCE(recording_steps.step_index, 0) AS step_index, embeddings.text, embeddings.vector").
+		Joins("JOIN sessions ON sessions.id = embeddings.session_id").
+		Joins("LEFT JOIN recording_steps ON embeddings.source_type = ? AND recording_steps.id = embeddings.source_id", EmbedStep).
+		Where("embeddings.model = ?", e.Model()).
+		Where("embeddings.source_type <> ? OR recording_steps.id IS NOT NULL", EmbedStep) // 步骤已删除的条目不返回
+	if projectID != "" {
+		tx = tx.Where("sessions.project_id = ?", projectID)
+	}
+	if sessionID != "" {
+		tx = tx.Where("embeddings.session_id = ?", sessionID)
+	}
+	if sourceType != "" {
+		tx = tx.Where("embeddings.source_type = ?", sourceType)
+	}
+	if err := tx.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	hits := []SemanticHit{}
+	for _, r := range rows {
+		score := cosine(q, decodeVector(r.Vector))
+		if score <= 0 {
+			continue
+		}
+		hit := SemanticHit{
+			SourceType: r.SourceType, SessionID: r.SessionID, SessionTitle: r.SessionTitle,
+			Text: r.Text, Score: math.Round(score*1e4) / 1e4,
+		}
+		if r.SourceType == EmbedStep {
+			hit.StepID, hit.StepIndex = r.SourceID, r.StepIndex
+		} else {
+			hit.DocumentID = r.SourceID
+		}
+		hits = append(hits, hit)
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+// encodeVector 向量按 float32 小端序编码
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return buf
+}
+
+func decodeVector(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
+
+// cosine 余弦相似度，维度不同或任一向量为零时为 0
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+func normalizeVector(v []float32) []float32 {
+	var n float64
+	for _, x := range v {
+		n += float64(x) * float64(x)
+	}
+	if n == 0 {
+		return v
+	}
+	n = math.Sqrt(n)
+	for i := range v {
+		v[i] = float32(float64(v[i]) / n)
+	}
+	return v
+}
+
+func textHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+func mapKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,181 @@
+package service_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gpilot/backend/internal/config"
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+	"gorm.io/gorm"
+)
+
+// renamedEmbedder 与内置向量相同，但模型标识不同（模拟更换向量模型）
+type renamedEmbedder struct{ service.LocalEmbedder }
+
+func (renamedEmbedder) Model() string { return "renamed" }
+
+func TestSemanticSearch(t *testing.T) {
+	setupDB(t)
+	t.Cleanup(func() { service.SetEmbedder(nil, 0) })
+	proj := db.Project{Name: "审批系统"}
+	db.DB.Create(&proj)
+	sess := db.Session{ProjectID: proj.ID, Title: "请假审批"}
+	db.DB.Create(&sess)
+	steps := []db.RecordingStep{
+		{AIDescription: "点击【驳回】按钮，将申请退回给申请人", PageTitle: "审批详情"},
+		{AIDescription: "在【开始日期】中选择请假开始的日期", PageTitle: "请假申请"},
+		{AIDescription: "点击【提交】按钮提交请假申请", PageTitle: "请假申请"},
+		{AIDescription: "误点了帮助链接", PageTitle: "请假申请", Excluded: true},
+		{Action: "navigation"},
+	}
+	for i := range steps {
+		steps[i].SessionID, steps[i].StepIndex = sess.ID, i+1
+		db.DB.Create(&steps[i])
+	}
+	svc := service.NewDocService()
+	content, _ := svc.BuildDocument(sess.ID)
+	doc, _ := svc.SaveGeneratedDoc(sess.ID, content)
+
+	if _, err := service.SemanticSearch("退回申请", "", "", "", 10); !errors.Is(err, service.ErrEmbeddingDisabled) {
+		t.Fatalf("expected ErrEmbeddingDisabled, got %v", err)
+	}
+	service.SetEmbedder(service.LocalEmbedder{}, time.Second)
+	res, err := service.ReindexEmbeddings(proj.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 已排除与没有描述的步骤不索引
+	if res.Steps != 3 || res.Documents != 1 || res.Embedded != 4 || res.Removed != 0 {
+		t.Fatalf("unexpected reindex result %+v", res)
+	}
+
+	hits, err := service.SemanticSearch("怎么退回申请", proj.ID, "", service.EmbedStep, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 || hits[0].StepID != steps[0].ID || hits[0].StepIndex != 1 || hits[0].SessionTitle != "请假审批" ||
+		hits[0].Text != "审批详情：点击【驳回】按钮，将申请退回给申请人" || hits[0].Score <= hits[1].Score {
+		t.Fatalf("unexpected hits %+v", hits)
+	}
+	hits, _ = service.SemanticSearch("请假审批", "", sess.ID, service.EmbedDocument, 10)
+	if len(hits) != 1 || hits[0].DocumentID != doc.ID || hits[0].StepID != "" {
+		t.Errorf("unexpected document hits %+v", hits)
+	}
+	if hits, _ := service.SemanticSearch("退回", "other-project", "", "", 10); len(hits) != 0 {
+		t.Errorf("hits outside the project: %+v", hits)
+	}
+
+	// 文本未变时不重新向量化；步骤被排除后删除其条目
+	if res, _ := service.ReindexEmbeddings(proj.ID); res.Embedded != 0 {
+		t.Errorf("unchanged entries should be skipped: %+v", res)
+	}
+	db.DB.Model(&steps[0]).Update("excluded", true)
+	if err := service.IndexStepEmbedding(steps[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if hits, _ := service.SemanticSearch("退回申请", "", "", service.EmbedStep, 10); len(hits) == 0 || hits[0].StepID == steps[0].ID {
+		t.Errorf("excluded step should no longer be found: %+v", hits)
+	}
+
+	// 新版本文档取代旧文档的条目
+	newer := db.GeneratedDocument{SessionID: sess.ID, ProjectID: proj.ID, BusinessView: doc.BusinessView, TechnicalView: doc.TechnicalView}
+	db.DB.Create(&newer)
+	db.DB.Model(&sess).Update("generated_doc_id", newer.ID)
+	if err := service.IndexDocumentEmbedding(newer.ID); err != nil {
+		t.Fatal(err)
+	}
+	var docs []db.Embedding
+	db.DB.Where("source_type = ?", service.EmbedDocument).Find(&docs)
+	if len(docs) != 1 || docs[0].SourceID != newer.ID {
+		t.Errorf("expected only the latest document, got %+v", docs)
+	}
+
+	// 更换模型后旧向量不参与检索，重建时全部重新向量化
+	service.SetEmbedder(renamedEmbedder{}, time.Second)
+	if hits, _ := service.SemanticSearch("退回申请", "", "", "", 10); len(hits) != 0 {
+		t.Errorf("vectors of another model should be ignored: %+v", hits)
+	}
+	if res, _ := service.ReindexEmbeddings(""); res.Embedded != 3 {
+		t.Errorf("expected every entry to be re-embedded, got %+v", res)
+	}
+
+	// 清除会话时删除其索引
+	db.DB.Transaction(func(tx *gorm.DB) error {
+		_, err := service.PurgeSession(tx, sess.ID, time.Now())
+		return err
+	})
+	var left int64
+	db.DB.Model(&db.Embedding{}).Count(&left)
+	if left != 0 {
+		t.Errorf("purge should remove %d embeddings", left)
+	}
+	if _, err := service.ReindexEmbeddings("missing"); err == nil {
+		t.Error("expected error for unknown project")
+	}
+}
+
+func TestProviderEmbedder(t *testing.T) {
+	setupDB(t)
+	t.Cleanup(func() { service.SetEmbedder(nil, 0) })
+	var got struct {
+		Model string   `json:"model"`
+		Input []string `json:"input"`
+	}
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+		switch r.URL.Path {
+		case "/embeddings":
+			// 返回顺序与输入不同，按 index 归位
+			w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`))
+		case "/api/embed":
+			w.Write([]byte(`{"embeddings":[[0.6,0.8]]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	cfg := service.MockConfigForTest()
+	cfg.OpenAIBaseURL, cfg.OpenAIAPIKey, cfg.OllamaBaseURL = srv.URL, "sk-test", srv.URL
+	ai := service.NewAIService(&cfg)
+
+	sess := db.Session{Title: "会话"}
+	db.DB.Create(&sess)
+	a := db.RecordingStep{SessionID: sess.ID, StepIndex: 1, AIDescription: "第一步"}
+	b := db.RecordingStep{SessionID: sess.ID, StepIndex: 2, AIDescription: "第二步"}
+	db.DB.Create(&a)
+	db.DB.Create(&b)
+
+	service.ConfigureEmbedding(config.EmbeddingConfig{Provider: "openai", Timeout: time.Second}, ai)
+	if model := service.EmbeddingModel(); model != "openai:text-embedding-3-small" {
+		t.Fatalf("unexpected model %q", model)
+	}
+	if _, err := service.ReindexEmbeddings(""); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer sk-test" || got.Model != "text-embedding-3-small" || len(got.Input) != 2 {
+		t.Errorf("unexpected request %q %+v", auth, got)
+	}
+
+	// Ollama 的查询向量与第二步相同方向更近
+	service.ConfigureEmbedding(config.EmbeddingConfig{Provider: "ollama", Model: "bge-m3", Timeout: time.Second}, ai)
+	db.DB.Model(&db.Embedding{}).Where("session_id = ?", sess.ID).Update("model", "ollama:bge-m3")
+	hits, err := service.SemanticSearch("第二步", "", "", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth != "" || len(hits) != 2 || hits[0].StepID != b.ID || hits[0].Score != 0.8 {
+		t.Errorf("unexpected hits %+v", hits)
+	}
+
+	service.ConfigureEmbedding(config.EmbeddingConfig{}, ai)
+	if service.EmbeddingModel() != "" {
+		t.Error("empty provider should disable semantic search")
+	}
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// 图片 MIME → 扩展名
+var imageExts = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+}
+
+// imageSink 把文档中的 data URL 截图写成独立文件，同一截图只写一次
+type imageSink struct {
+	put    func(path string, data []byte) error
+	images map[string]string // data URL → 相对路径
+}
+
+func newImageSink(put func(path string, data []byte) error) *imageSink {
+	return &imageSink{put: put, images: map[string]string{}}
+}
+
+// externalize 返回文档副本，其中的截图 data URL 被替换为 images/ 下的相对路径；原文档不被修改
+func (k *imageSink) externalize(content *GeneratedDocContent) (*GeneratedDocContent, error) {
+	out := *content
+	var err error
+	if out.BusinessView, err = k.rewrite(content.BusinessView); err != nil {
+		return nil, err
+	}
+	if out.TechnicalView, err = k.rewrite(content.TechnicalView); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (k *imageSink) rewrite(sections []DocSection) ([]DocSection, error) {
+	out := make([]DocSection, len(sections))
+	for i, sec := range sections {
+		sec.Steps = append([]DocStep(nil), sec.Steps...)
+		for j := range sec.Steps {
+			st := &sec.Steps[j]
+			if st.ScreenshotURL == "" {
+				continue
+			}
+			if path, ok := k.images[st.ScreenshotURL]; ok {
+				st.ScreenshotURL = path
+				continue
+			}
+			mime, data, err := ParseDataURL(st.ScreenshotURL)
+			if err != nil {
+				// 非 data URL（例如已是外部链接）原样保留
+				continue
+			}
+			ext := imageExts[mime]
+			if ext == "" {
+				ext = ".bin"
+			}
+			name := fmt.Sprintf("step-%03d-", st.StepIndex)
+			if st.ScreenshotID != "" {
+				name += shortID(st.ScreenshotID)
+			} else {
+				sum := sha256.Sum256(data)
+				name += hex.EncodeToString(sum[:4])
+			}
+			path := "images/" + name + ext
+			if err := k.put(path, data); err != nil {
+				return nil, err
+			}
+			k.images[st.ScreenshotURL] = path
+			st.ScreenshotURL = path
+		}
+		out[i] = sec
+	}
+	return out, nil
+}
+
+// zipPut 返回向 zip 写入单个文件的函数
+func zipPut(zw *zip.Writer) func(path string, data []byte) error {
+	return func(path string, data []byte) error {
+		f, err := zw.Create(path)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		return err
+	}
+}
+
+// WriteMarkdownZip 导出为 zip：manual.md + images/ 目录，Markdown 以相对路径引用图片，
+// 避免 wiki 拒收内嵌数兆 base64 图片的 Markdown
+func (s *DocService) WriteMarkdownZip(w io.Writer, content *GeneratedDocContent, viewType string) error {
+	zw := zip.NewWriter(w)
+	exported, err := newImageSink(zipPut(zw)).externalize(content)
+	if err != nil {
+		return err
+	}
+	if err := zipPut(zw)("manual.md", []byte(s.GenerateMarkdown(exported, viewType))); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func shortID(id string) string {
+	id = strings.ReplaceAll(id, "-", "")
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// 导出时的截图模式
+const (
+	ScreenshotsFull      = "full"      // 原图（默认）
+	ScreenshotsThumbnail = "thumbnail" // 缩略图，宽度不超过 thumbnailWidth
+	ScreenshotsNone      = "none"      // 不含截图
+)
+
+// ScreenshotModes 支持的截图模式
+var ScreenshotModes = []string{ScreenshotsFull, ScreenshotsThumbnail, ScreenshotsNone}
+
+const thumbnailWidth = 480
+
+// ScreenshotContent 按截图模式返回文档副本；原文档不被修改，full 原样返回
+func (s *DocService) ScreenshotContent(content *GeneratedDocContent, mode string) *GeneratedDocContent {
+	if mode == "" || mode == ScreenshotsFull {
+		return content
+	}
+	thumbs := map[string]string{}
+	rewrite := func(sections []DocSection) []DocSection {
+		out := make([]DocSection, len(sections))
+		for i, sec := range sections {
+			sec.Steps = append([]DocStep(nil), sec.Steps...)
+			for j := range sec.Steps {
+				st := &sec.Steps[j]
+				if st.ScreenshotURL == "" {
+					continue
+				}
+				if mode == ScreenshotsNone {
+					st.ScreenshotURL = ""
+					continue
+				}
+				thumb, ok := thumbs[st.ScreenshotURL]
+				if !ok {
+					thumb = ThumbnailDataURL(st.ScreenshotURL, thumbnailWidth)
+					thumbs[st.ScreenshotURL] = thumb
+				}
+				st.ScreenshotURL = thumb
+			}
+			out[i] = sec
+		}
+		return out
+	}
+	out := *content
+	out.BusinessView = rewrite(content.BusinessView)
+	out.TechnicalView = rewrite(content.TechnicalView)
+	return &out
+}
+
+// ThumbnailDataURL 将截图按比例缩小到不超过 maxWidth 像素宽（区域平均采样）；
+// 已足够小、不是 data URL 或无法解码时原样返回。JPEG 截图仍输出 JPEG，其余输出 PNG
+func ThumbnailDataURL(dataURL string, maxWidth int) string {
+	mime, data, err := ParseDataURL(dataURL)
+	if err != nil {
+		return dataURL
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return dataURL
+	}
+	b := src.Bounds()
+	if b.Dx() <= maxWidth {
+		return dataURL
+	}
+	w, h := maxWidth, max(b.Dy()*maxWidth/b.Dx(), 1)
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := b.Min.Y+y*b.Dy()/h, b.Min.Y+max((y+1)*b.Dy()/h, y*b.Dy()/h+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/w, b.Min.X+max((x+1)*b.Dx()/w, x*b.Dx()/w+1)
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+cr, g+cg, bl+cb, a+ca, n+1
+				}
+			}
+			out.SetRGBA(x, y, color.RGBA{R: uint8(r / n >> 8), G: uint8(g / n >> 8), B: uint8(bl / n >> 8), A: uint8(a / n >> 8)})
+		}
+	}
+
+	var buf bytes.Buffer
+	if mime == "image/jpeg" {
+		err = jpeg.Encode(&buf, out, &jpeg.Options{Quality: 85})
+	} else {
+		mime = "image/png"
+		err = png.Encode(&buf, out)
+	}
+	if err != nil {
+		return dataURL
+	}
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// ExportFilename 清理用户指定的导出文件名：去掉路径分隔符、控制字符和 Windows 保留字符，
+// 去掉与 ext 相同的扩展名并限制长度；清理后为空时使用 fallback。返回值带 ext
+func ExportFilename(name, fallback, ext string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSuffix(strings.TrimSpace(name), ext)
+	if runes := []rune(name); len(runes) > 100 {
+		name = string(runes[:100])
+	}
+	name = strings.Trim(name, " .")
+	if name == "" {
+		name = fallback
+	}
+	return name + ext
+}
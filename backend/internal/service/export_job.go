@@ -0,0 +1,173 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// 导出任务状态
+const (
+	ExportRunning   = "running"
+	ExportCompleted = "completed"
+	ExportFailed    = "failed"
+	ExportExpired   = "expired" // 产物已过期并被删除
+)
+
+// 导出来源
+const (
+	ExportSourceDocument = "document" // 单篇生成文档
+	ExportSourceCompiled = "compiled" // 合订手册
+)
+
+// ExportDirName 存储目录下存放导出产物的子目录（临时文件，不会被打包进备份）
+const ExportDirName = "exports"
+
+// exportJobKeep 已过期或失败的导出任务记录保留多久后删除
+const exportJobKeep = 7 * 24 * time.Hour
+
+// exportSlots 同时进行的导出任务数上限，其余任务排队等待
+var exportSlots = make(chan struct{}, 2)
+
+// ExportJobDir 导出任务的产物目录
+func ExportJobDir(storagePath, jobID string) string {
+	return filepath.Join(storagePath, ExportDirName, jobID)
+}
+
+// ExportJobFile 导出任务的产物文件
+func ExportJobFile(storagePath string, job *db.ExportJob) string {
+	return filepath.Join(ExportJobDir(storagePath, job.ID), job.FileName)
+}
+
+// ExportJobExpired 产物已过期（不论清理任务是否已删除文件）
+func ExportJobExpired(job *db.ExportJob, now time.Time) bool {
+	return job.Status == ExportExpired || (job.ExpiresAt != nil && !now.Before(*job.ExpiresAt))
+}
+
+// FailInterruptedExports 把服务重启前未结束的导出任务标记为失败，返回处理的记录数
+func FailInterruptedExports() (int64, error) {
+	now := time.Now()
+	res := db.DB.Model(&db.ExportJob{}).Where("status = ?", ExportRunning).
+		Updates(db.ExportJob{Status: ExportFailed, Error: "interrupted by server restart", FinishedAt: &now})
+	return res.RowsAffected, res.Error
+}
+
+// StartExportJob 创建导出任务记录并在后台调用 write 把产物写入 <storagePath>/exports/<id>/<FileName>；
+// job 需填好来源、格式与文件名。完成后产物保留 export_expiry_hours 小时
+func StartExportJob(storagePath string, job *db.ExportJob, write func(w io.Writer) error) error {
+	job.Status = ExportRunning
+	if err := db.DB.Create(job).Error; err != nil {
+		return err
+	}
+	go runExport(storagePath, *job, write)
+	return nil
+}
+
+// runExport 执行导出并记录结果，失败时删除写了一半的产物
+func runExport(storagePath string, job db.ExportJob, write func(w io.Writer) error) {
+	exportSlots <- struct{}{}
+	defer func() { <-exportSlots }()
+
+	size, err := writeExportFile(ExportJobFile(storagePath, &job), write)
+	now := time.Now()
+	updates := map[string]interface{}{"finished_at": &now}
+	if err != nil {
+		os.RemoveAll(ExportJobDir(storagePath, job.ID))
+		updates["status"], updates["error"] = ExportFailed, err.Error()
+	} else {
+		expires := now.Add(CurrentSettings().ExportExpiry())
+		updates["status"], updates["size"], updates["expires_at"] = ExportCompleted, size, &expires
+	}
+	if err := db.DB.Model(&db.ExportJob{}).Where("id = ?", job.ID).Updates(updates).Error; err != nil {
+		log.Printf("⚠️ export %s: save result: %v", job.ID, err)
+	}
+}
+
+// writeExportFile 创建产物文件并写入，返回文件大小；开启静态加密时产物含截图，
+// 先在内存中生成再整体加密落盘，下载时由 ReadSealedFile 解密
+func writeExportFile(path string, write func(w io.Writer) error) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	if db.EncryptionEnabled() {
+		var buf bytes.Buffer
+		if err := write(&buf); err != nil {
+			return 0, err
+		}
+		sealed, err := db.SealBytes(buf.Bytes())
+		if err != nil {
+			return 0, err
+		}
+		if err := os.WriteFile(path, sealed, 0o600); err != nil {
+			return 0, err
+		}
+		return int64(len(sealed)), nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return 0, err
+	}
+	if err := f.Close(); err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// CleanupExports 把到期的导出任务标记为已过期，删除过期与无主（任务失败或记录已随文档、会话删除）的产物目录，
+// 并删除过期或失败超过 7 天的任务记录；返回删除的产物目录数
+func CleanupExports(storagePath string, now time.Time) (int64, error) {
+	if err := db.DB.Model(&db.ExportJob{}).Where("status = ? AND expires_at <= ?", ExportCompleted, now).
+		Update("status", ExportExpired).Error; err != nil {
+		return 0, err
+	}
+	if err := db.DB.Where("status IN ? AND finished_at < ?", []string{ExportExpired, ExportFailed}, now.Add(-exportJobKeep)).
+		Delete(&db.ExportJob{}).Error; err != nil {
+		return 0, err
+	}
+	if storagePath == "" {
+		return 0, nil
+	}
+
+	// 先列出目录再查询仍有效的任务：新任务总是先建记录再写产物，不会被误删
+	entries, err := os.ReadDir(filepath.Join(storagePath, ExportDirName))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var live []string
+	if err := db.DB.Model(&db.ExportJob{}).Where("status IN ?", []string{ExportRunning, ExportCompleted}).
+		Pluck("id", &live).Error; err != nil {
+		return 0, err
+	}
+	keep := make(map[string]bool, len(live))
+	for _, id := range live {
+		keep[id] = true
+	}
+	var removed int64
+	for _, e := range entries {
+		if keep[e.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(storagePath, ExportDirName, e.Name())); err != nil {
+			return removed, fmt.Errorf("export %s: %w", e.Name(), err)
+		}
+		removed++
+	}
+	return removed, nil
+}
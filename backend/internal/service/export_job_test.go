@@ -0,0 +1,139 @@
+package service_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func waitExport(t *testing.T, jobID string) db.ExportJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var job db.ExportJob
+		db.DB.First(&job, "id = ?", jobID)
+		if job.Status != service.ExportRunning {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("export %s did not finish", jobID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStartExportJob(t *testing.T) {
+	setupDB(t)
+	storage := t.TempDir()
+
+	job := &db.ExportJob{SourceType: service.ExportSourceDocument, SourceID: "doc", Format: "md", FileName: "manual.md"}
+	err := service.StartExportJob(storage, job, func(w io.Writer) error {
+		_, err := io.WriteString(w, "# 手册\n")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("start export: %v", err)
+	}
+	done := waitExport(t, job.ID)
+	if done.Status != service.ExportCompleted || done.Size != 9 || done.FinishedAt == nil || done.ExpiresAt == nil {
+		t.Fatalf("unexpected job: %+v", done)
+	}
+	if d := done.ExpiresAt.Sub(*done.FinishedAt); d != 24*time.Hour {
+		t.Errorf("artifact should expire after export_expiry_hours, got %v", d)
+	}
+	data, err := os.ReadFile(service.ExportJobFile(storage, &done))
+	if err != nil || string(data) != "# 手册\n" {
+		t.Errorf("artifact content: %q, %v", data, err)
+	}
+
+	// 写入失败时任务失败并删除写了一半的产物
+	failed := &db.ExportJob{SourceType: service.ExportSourceDocument, SourceID: "doc", Format: "md", FileName: "manual.md"}
+	service.StartExportJob(storage, failed, func(w io.Writer) error {
+		io.WriteString(w, "partial")
+		return errors.New("render failed")
+	})
+	if got := waitExport(t, failed.ID); got.Status != service.ExportFailed || got.Error != "render failed" {
+		t.Errorf("unexpected failed job: %+v", got)
+	}
+	if _, err := os.Stat(service.ExportJobDir(storage, failed.ID)); !os.IsNotExist(err) {
+		t.Errorf("partial artifact should be removed, stat err=%v", err)
+	}
+}
+
+func TestStartExportJob_Sealed(t *testing.T) {
+	setupDB(t)
+	storage := t.TempDir()
+	if err := db.SetEncryptionKey([]byte("0123456789abcdef0123456789abcdef")); err != nil {
+		t.Fatalf("SetEncryptionKey: %v", err)
+	}
+	t.Cleanup(func() { db.SetEncryptionKey(nil) })
+
+	job := &db.ExportJob{SourceType: service.ExportSourceDocument, SourceID: "doc", Format: "md", FileName: "manual.md"}
+	service.StartExportJob(storage, job, func(w io.Writer) error {
+		_, err := io.WriteString(w, "# 手册\n![截图](data:image/png;base64,SECRET)\n")
+		return err
+	})
+	done := waitExport(t, job.ID)
+	if done.Status != service.ExportCompleted {
+		t.Fatalf("unexpected job: %+v", done)
+	}
+	path := service.ExportJobFile(storage, &done)
+	raw, _ := os.ReadFile(path)
+	if !db.IsSealedBytes(raw) || strings.Contains(string(raw), "SECRET") || done.Size != int64(len(raw)) {
+		t.Errorf("artifact should be sealed on disk: %q", raw)
+	}
+	if sealed, err := service.SealedFile(path); !sealed || err != nil {
+		t.Errorf("SealedFile = %v, %v", sealed, err)
+	}
+	data, err := service.ReadSealedFile(path)
+	if err != nil || !strings.Contains(string(data), "SECRET") {
+		t.Errorf("decrypted artifact: %q, %v", data, err)
+	}
+}
+
+func TestCleanupExports(t *testing.T) {
+	setupDB(t)
+	storage := t.TempDir()
+	now := time.Now()
+	later, earlier, longAgo := now.Add(time.Hour), now.Add(-time.Hour), now.Add(-8*24*time.Hour)
+
+	valid := db.ExportJob{SourceType: service.ExportSourceDocument, Status: service.ExportCompleted, FileName: "a.md", ExpiresAt: &later}
+	expired := db.ExportJob{SourceType: service.ExportSourceDocument, Status: service.ExportCompleted, FileName: "b.md", ExpiresAt: &earlier}
+	stale := db.ExportJob{SourceType: service.ExportSourceDocument, Status: service.ExportFailed, FinishedAt: &longAgo}
+	for _, job := range []*db.ExportJob{&valid, &expired, &stale} {
+		db.DB.Create(job)
+	}
+	for _, id := range []string{valid.ID, expired.ID, "deleted-job"} {
+		dir := service.ExportJobDir(storage, id)
+		os.MkdirAll(dir, 0o755)
+		os.WriteFile(filepath.Join(dir, "manual.md"), []byte("x"), 0o644)
+	}
+
+	removed, err := service.CleanupExports(storage, now)
+	if err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("expected 2 artifact directories removed, got %d", removed)
+	}
+	if _, err := os.Stat(service.ExportJobDir(storage, valid.ID)); err != nil {
+		t.Errorf("unexpired artifact should be kept: %v", err)
+	}
+	var got db.ExportJob
+	db.DB.First(&got, "id = ?", expired.ID)
+	if got.Status != service.ExportExpired || !service.ExportJobExpired(&got, now) {
+		t.Errorf("expired job status: %s", got.Status)
+	}
+	var n int64
+	db.DB.Model(&db.ExportJob{}).Where("id = ?", stale.ID).Count(&n)
+	if n != 0 {
+		t.Error("jobs failed more than 7 days ago should be deleted")
+	}
+}
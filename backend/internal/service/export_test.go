@@ -0,0 +1,253 @@
+package service_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestWriteMarkdownZip(t *testing.T) {
+	setupDB(t)
+	_, sessionID := seedSessionWithSteps(t, 3)
+
+	var steps []db.RecordingStep
+	db.DB.Where("session_id = ?", sessionID).Find(&steps)
+	for i, s := range steps {
+		sc := db.Screenshot{
+			SessionID:  sessionID,
+			StepID:     s.ID,
+			DataURL:    db.LongText("data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte{0x89, 'P', 'N', 'G', byte(i)})),
+			CapturedAt: time.Now().UnixMilli(),
+		}
+		db.DB.Create(&sc)
+		db.DB.Model(&s).Update("screenshot_id", sc.ID)
+	}
+
+	svc := service.NewDocService()
+	content, _ := svc.BuildDocument(sessionID)
+	var buf bytes.Buffer
+	if err := svc.WriteMarkdownZip(&buf, content, "technical"); err != nil {
+		t.Fatalf("WriteMarkdownZip: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	var md string
+	images := 0
+	for _, f := range zr.File {
+		switch {
+		case f.Name == "manual.md":
+			rc, _ := f.Open()
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			md = string(data)
+		case strings.HasPrefix(f.Name, "images/") && strings.HasSuffix(f.Name, ".png"):
+			images++
+		}
+	}
+	if images != 3 {
+		t.Errorf("expected 3 image files, got %d", images)
+	}
+	if strings.Contains(md, "base64") || !strings.Contains(md, "](images/step-001-") {
+		t.Errorf("markdown should reference relative image paths:\n%s", md)
+	}
+	// 原文档内容不被修改
+	if !strings.HasPrefix(allSteps(content.TechnicalView)[0].ScreenshotURL, "data:") {
+		t.Error("WriteMarkdownZip mutated the source document")
+	}
+}
+
+func TestBuildSite(t *testing.T) {
+	setupDB(t)
+	projectID, sessionID := seedSessionWithSteps(t, 2)
+
+	svc := service.NewDocService()
+	content, _ := svc.BuildDocument(sessionID)
+	draft, _ := svc.SaveGeneratedDoc(sessionID, content)
+	approved, _ := svc.SaveGeneratedDoc(sessionID, content)
+	now := time.Now()
+	db.DB.Model(approved).Updates(map[string]interface{}{"status": "approved", "approved_at": &now})
+
+	files, err := svc.BuildSite(projectID)
+	if err != nil {
+		t.Fatalf("BuildSite: %v", err)
+	}
+	byPath := map[string]string{}
+	for _, f := range files {
+		byPath[f.Path] = string(f.Data)
+	}
+	page := "doc-" + strings.ReplaceAll(approved.ID, "-", "")[:8] + ".html"
+	if _, ok := byPath[page]; !ok {
+		t.Fatalf("missing approved document page %s", page)
+	}
+	if _, ok := byPath["doc-"+strings.ReplaceAll(draft.ID, "-", "")[:8]+".html"]; ok {
+		t.Error("draft document should not be published")
+	}
+	if !strings.Contains(byPath["index.html"], `href="`+page+`"`) {
+		t.Error("index should link to the document page")
+	}
+	if !containsAll(byPath["search-index.js"], "window.GPILOT_SEARCH=", "点击登录按钮") {
+		t.Errorf("unexpected search index: %s", byPath["search-index.js"])
+	}
+
+	var buf bytes.Buffer
+	if err := service.WriteSiteZip(&buf, files); err != nil {
+		t.Fatalf("WriteSiteZip: %v", err)
+	}
+	dir := t.TempDir() + "/site"
+	if err := service.WriteSiteDir(dir, files); err != nil {
+		t.Fatalf("WriteSiteDir: %v", err)
+	}
+	if err := service.WriteSiteDir(dir, files); err != nil {
+		t.Fatalf("WriteSiteDir (republish): %v", err)
+	}
+
+	if _, err := svc.BuildSite("missing"); err == nil {
+		t.Error("expected error for unknown project")
+	}
+}
+
+func TestProjectDocIndex(t *testing.T) {
+	setupDB(t)
+	projectID, sessionID := seedSessionWithSteps(t, 2)
+	draftSession := db.Session{ProjectID: projectID, Title: "草稿流程"}
+	db.DB.Create(&draftSession)
+
+	svc := service.NewDocService()
+	content, _ := svc.BuildDocument(sessionID)
+	older, _ := svc.SaveGeneratedDoc(sessionID, content)
+	overview := service.DocSection{Kind: service.SectionOverview, Title: "流程概述", Summary: "登录系统并\n提交" + strings.Repeat("表", 130)}
+	content.BusinessView = append([]service.DocSection{overview}, content.BusinessView...)
+	latest, _ := svc.SaveGeneratedDoc(sessionID, content)
+	earlier, now := time.Now().Add(-time.Hour), time.Now()
+	db.DB.Model(older).Updates(map[string]interface{}{"status": "approved", "approved_at": &earlier})
+	db.DB.Model(latest).Updates(map[string]interface{}{"status": "approved", "approved_at": &now})
+	draftContent, _ := svc.BuildDocument(draftSession.ID)
+	svc.SaveGeneratedDoc(draftSession.ID, draftContent)
+
+	idx, err := svc.ProjectDocIndex(projectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同一会话只列出最新审批的一份，草稿不列出
+	if len(idx.Documents) != 1 {
+		t.Fatalf("expected 1 document, got %+v", idx.Documents)
+	}
+	d := idx.Documents[0]
+	page := "doc-" + strings.ReplaceAll(latest.ID, "-", "")[:8] + ".html"
+	if d.DocumentID != latest.ID || d.StepCount != 2 || d.Links.Page != page || d.Links.Export != "/api/v1/documents/"+latest.ID+"/export?format=md" {
+		t.Errorf("unexpected entry %+v", d)
+	}
+	if !strings.HasPrefix(d.Summary, "登录系统并 提交表") || !strings.HasSuffix(d.Summary, "…") || len([]rune(d.Summary)) != 121 {
+		t.Errorf("summary should come from the overview, collapsed and truncated: %q", d.Summary)
+	}
+	md := idx.Markdown()
+	if !containsAll(md, "# 测试项目 · 文档目录", "- [测试录制会话]("+page+")（2 步 · 审批于 ", "  登录系统并") {
+		t.Errorf("unexpected markdown:\n%s", md)
+	}
+
+	files, _ := svc.BuildSite(projectID)
+	for _, f := range files {
+		if f.Path == "index.html" && !strings.Contains(string(f.Data), `<div class="summary">登录系统并 提交`) {
+			t.Error("site index should show the document summary")
+		}
+	}
+	if _, err := svc.ProjectDocIndex("missing"); err == nil {
+		t.Error("expected error for unknown project")
+	}
+}
+
+func TestExportWatermark(t *testing.T) {
+	setupDB(t)
+	projectID, sessionID := seedSessionWithSteps(t, 1)
+	svc := service.NewDocService()
+	content, _ := svc.BuildDocument(sessionID)
+	doc, _ := svc.SaveGeneratedDoc(sessionID, content)
+	now := time.Now()
+	db.DB.Model(doc).Updates(map[string]interface{}{"status": "approved", "approved_at": &now})
+
+	// 未配置时不加水印
+	if loaded, _ := svc.LoadDocument(doc); loaded.Watermark != nil {
+		t.Errorf("expected no watermark by default, got %+v", loaded.Watermark)
+	}
+
+	stamp := "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte{0x89, 'P', 'N', 'G'})
+	if _, err := service.UpdateSettings(map[string]json.RawMessage{
+		"watermark_text":  json.RawMessage(`"内部资料 – 禁止外传"`),
+		"watermark_image": json.RawMessage(`"` + stamp + `"`),
+	}); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	for _, p := range []map[string]json.RawMessage{
+		{"watermark_image": json.RawMessage(`"data:text/plain;base64,aGk="`)},
+		{"watermark_opacity": json.RawMessage(`0`)},
+	} {
+		if _, err := service.UpdateSettings(p); !errors.Is(err, service.ErrInvalidSettings) {
+			t.Errorf("patch %v: expected ErrInvalidSettings, got %v", p, err)
+		}
+	}
+
+	loaded, _ := svc.LoadDocument(doc)
+	if md := svc.GenerateMarkdown(loaded, "business"); !strings.Contains(md, "> **内部资料 – 禁止外传**") {
+		t.Errorf("markdown should carry the watermark text:\n%s", md)
+	}
+
+	files, err := svc.BuildSite(projectID)
+	if err != nil {
+		t.Fatalf("BuildSite: %v", err)
+	}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Path, ".html") {
+			continue
+		}
+		if !strings.Contains(string(f.Data), `<div class="watermark" style="background-image:url(&#34;data:image/svg&#43;xml;base64,`) {
+			t.Errorf("%s: missing watermark overlay", f.Path)
+		}
+	}
+	tile, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(loaded.Watermark.TileSVG(), "data:image/svg+xml;base64,"))
+	if !containsAll(string(tile), "rotate(-30", "内部资料 – 禁止外传", `href="`+stamp+`"`, `opacity="0.15"`) {
+		t.Errorf("unexpected watermark tile: %s", tile)
+	}
+}
+
+func TestExportScreenshotModes(t *testing.T) {
+	full := pngDataURL(t, 1280, 720)
+	if b := decodePNG(t, service.ThumbnailDataURL(full, 480)).Bounds(); b.Dx() != 480 || b.Dy() != 270 {
+		t.Errorf("expected 480x270 thumbnail, got %v", b)
+	}
+	small := pngDataURL(t, 200, 100)
+	if service.ThumbnailDataURL(small, 480) != small {
+		t.Error("small screenshot should be kept as is")
+	}
+
+	svc := service.NewDocService()
+	content := &service.GeneratedDocContent{
+		BusinessView:  []service.DocSection{{Steps: []service.DocStep{{StepIndex: 1, ScreenshotURL: full}}}},
+		TechnicalView: []service.DocSection{{Steps: []service.DocStep{{StepIndex: 1, ScreenshotURL: full}}}},
+	}
+	thumb := svc.ScreenshotContent(content, service.ScreenshotsThumbnail)
+	if url := allSteps(thumb.TechnicalView)[0].ScreenshotURL; url == full || decodePNG(t, url).Bounds().Dx() != 480 {
+		t.Error("thumbnail mode should shrink screenshots")
+	}
+	none := svc.ScreenshotContent(content, service.ScreenshotsNone)
+	if allSteps(none.BusinessView)[0].ScreenshotURL != "" || allSteps(content.BusinessView)[0].ScreenshotURL != full {
+		t.Error("none mode should drop screenshots without mutating the source")
+	}
+
+	for name, want := range map[string]string{"": "manual.md", "操作手册.md": "操作手册.md", `a/b:c*?.md`: "abc.md", " .. ": "manual.md"} {
+		if got := service.ExportFilename(name, "manual", ".md"); got != want {
+			t.Errorf("ExportFilename(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
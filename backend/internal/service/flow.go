@@ -0,0 +1,192 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// 流程图节点与连线类型
+const (
+	FlowPage     = "page"     // 页面（按 URL 模式归并，再次回到同一页面时复用节点）
+	FlowStep     = "step"     // 页面上的操作步骤
+	FlowNext     = "next"     // 步骤的先后顺序
+	FlowNavigate = "navigate" // 页面之间的跳转
+)
+
+// FlowNode 流程图节点；步骤节点的 Page 为所在页面节点的 ID
+type FlowNode struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Label      string `json:"label"`
+	Page       string `json:"page,omitempty"`
+	URLPattern string `json:"url_pattern,omitempty"`
+	StepID     string `json:"step_id,omitempty"`
+	StepIndex  int    `json:"step_index,omitempty"`
+	Action     string `json:"action,omitempty"`
+}
+
+// FlowEdge 流程图连线；页面跳转按 (From, To) 合并，Count 为跳转次数，Label 为首次触发跳转的操作
+type FlowEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Type  string `json:"type"`
+	Label string `json:"label,omitempty"`
+	Count int    `json:"count,omitempty"`
+}
+
+// FlowGraph 会话的业务流程图：页面节点按首次出现的顺序排列，步骤节点按录制顺序排列
+type FlowGraph struct {
+	SessionID string     `json:"session_id"`
+	Title     string     `json:"title"`
+	Nodes     []FlowNode `json:"nodes"`
+	Edges     []FlowEdge `json:"edges"`
+}
+
+// Pages 页面节点（按首次出现的顺序）
+func (g *FlowGraph) Pages() []FlowNode {
+	var pages []FlowNode
+	for _, n := range g.Nodes {
+		if n.Type == FlowPage {
+			pages = append(pages, n)
+		}
+	}
+	return pages
+}
+
+// Mermaid 页面级的 Mermaid 流程图源码（flowchart TD）：页面为节点，页面跳转为连线，连线上标注触发跳转的操作；
+// 没有页面时返回空串
+func (g *FlowGraph) Mermaid() string {
+	pages := g.Pages()
+	if len(pages) == 0 {
+		return ""
+	}
+	ids := map[string]string{}
+	var sb strings.Builder
+	sb.WriteString("flowchart TD\n")
+	for i, p := range pages {
+		ids[p.ID] = fmt.Sprintf("p%d", i+1)
+		sb.WriteString(fmt.Sprintf("    %s[\"%s\"]\n", ids[p.ID], mermaidText(p.Label)))
+	}
+	for _, e := range g.Edges {
+		if e.Type != FlowNavigate {
+			continue
+		}
+		label := truncateRunes(e.Label, mermaidLabelLen)
+		if label != e.Label {
+			label += "…"
+		}
+		if e.Count > 1 {
+			label += fmt.Sprintf(" ×%d", e.Count)
+		}
+		if label == "" {
+			sb.WriteString(fmt.Sprintf("    %s --> %s\n", ids[e.From], ids[e.To]))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("    %s -->|\"%s\"| %s\n", ids[e.From], mermaidText(label), ids[e.To]))
+	}
+	return sb.String()
+}
+
+// mermaidLabelLen 连线标注的最大字数，超出部分省略
+const mermaidLabelLen = 24
+
+// mermaidText 转义 Mermaid 带引号标签中的特殊字符并合并换行
+func mermaidText(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "\n", " ", "\r", "").Replace(s)
+}
+
+// SessionFlow 把会话转换为流程图：与文档章节一致，在导航到新的 URL 模式或切换标签页时进入新页面；
+// 重复提交与已排除的步骤不计入
+func SessionFlow(tx *gorm.DB, sessionID string) (*FlowGraph, error) {
+	var session db.Session
+	if err := tx.First(&session, "id = ?", sessionID).Error; err != nil {
+		return nil, err
+	}
+	var steps []db.RecordingStep
+	if err := tx.Where("session_id = ? AND duplicate_of = '' AND excluded = ?", sessionID, false).
+		Order("step_index").Find(&steps).Error; err != nil {
+		return nil, err
+	}
+	g := BuildFlowGraph(steps)
+	g.SessionID, g.Title = session.ID, session.Title
+	return g, nil
+}
+
+// BuildFlowGraph 由按顺序排列的步骤构建流程图
+func BuildFlowGraph(steps []db.RecordingStep) *FlowGraph {
+	g := &FlowGraph{Nodes: []FlowNode{}, Edges: []FlowEdge{}}
+	pageIDs := map[string]string{} // URL 模式 → 页面节点 ID
+	navigations := map[[2]string]int{}
+	prevPage, prevStep := "", ""
+	var prevLabel string
+	for _, chunk := range splitByNavigation(steps) {
+		if len(chunk.steps) == 0 {
+			continue
+		}
+		pageID, ok := pageIDs[chunk.pattern]
+		if !ok {
+			pageID = fmt.Sprintf("page-%d", len(pageIDs)+1)
+			pageIDs[chunk.pattern] = pageID
+			g.Nodes = append(g.Nodes, FlowNode{ID: pageID, Type: FlowPage, Label: flowPageLabel(chunk), URLPattern: chunk.pattern})
+		}
+		if prevPage != "" && prevPage != pageID {
+			key := [2]string{prevPage, pageID}
+			if i, ok := navigations[key]; ok {
+				g.Edges[i].Count++
+			} else {
+				navigations[key] = len(g.Edges)
+				g.Edges = append(g.Edges, FlowEdge{From: prevPage, To: pageID, Type: FlowNavigate, Label: prevLabel, Count: 1})
+			}
+		}
+		for _, s := range chunk.steps {
+			label := flowStepLabel(s)
+			g.Nodes = append(g.Nodes, FlowNode{
+				ID: s.ID, Type: FlowStep, Label: label, Page: pageID,
+				StepID: s.ID, StepIndex: s.StepIndex, Action: s.Action,
+			})
+			if prevStep != "" {
+				g.Edges = append(g.Edges, FlowEdge{From: prevStep, To: s.ID, Type: FlowNext})
+			}
+			prevStep, prevLabel = s.ID, label
+		}
+		prevPage = pageID
+	}
+	return g
+}
+
+// flowPageLabel 页面名称：首个有标题的步骤的页面标题，其次为 URL 模式
+func flowPageLabel(chunk stepChunk) string {
+	for _, s := range chunk.steps {
+		if s.PageTitle != "" {
+			return s.PageTitle
+		}
+	}
+	if chunk.pattern != "" {
+		return chunk.pattern
+	}
+	return "未知页面"
+}
+
+// flowStepLabel 步骤名称：优先使用短标题与 AI 描述，其次为“动词【组件名】”（与业务视图的合并描述一致）、按键或操作类型
+func flowStepLabel(s db.RecordingStep) string {
+	switch {
+	case s.AITitle != "":
+		return s.AITitle
+	case s.AIDescription != "":
+		return s.AIDescription
+	case s.Action == "navigation":
+		return "打开页面"
+	case s.KeyCombo != "":
+		return KeyPhrase(s.Action, s.KeyCombo)
+	case s.TargetElement != "":
+		ctx := parseStepContext(s.TargetElement, s.Action)
+		if !strings.Contains(s.TargetElement, "功能为 ") {
+			ctx.compName = s.TargetElement
+		}
+		return fmt.Sprintf("%s【%s】", ctx.verb, ctx.compName)
+	}
+	return s.Action
+}
@@ -0,0 +1,94 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestSessionFlow(t *testing.T) {
+	setupDB(t)
+	sess := db.Session{Title: "缴费"}
+	db.DB.Create(&sess)
+	steps := []db.RecordingStep{
+		{Action: "navigation", PageURL: "https://gov.example.com/cases/101", PageTitle: "案件列表"},
+		{Action: "click", TargetElement: "查询", PageURL: "https://gov.example.com/cases/101", PageTitle: "案件列表"},
+		{Action: "click", TargetElement: "缴费", PageURL: "https://gov.example.com/cases/101", PageTitle: "案件列表"},
+		{Action: "input", TargetElement: "金额", PageURL: "https://gov.example.com/pay", PageTitle: "缴费"},
+		{Action: "input", TargetElement: "金额", PageURL: "https://gov.example.com/pay", DuplicateOf: "x"},
+		{Action: service.ActionKeypress, KeyCombo: "Enter", PageURL: "https://gov.example.com/pay"},
+		{Action: "click", TargetElement: "返回", PageURL: "https://gov.example.com/cases/202", AIDescription: "点击【返回】回到案件列表"},
+		{Action: "click", TargetElement: "缴费", PageURL: "https://gov.example.com/cases/202"},
+		{Action: "click", TargetElement: "确定", PageURL: "https://gov.example.com/pay"},
+	}
+	for i, s := range steps {
+		s.SessionID, s.StepIndex = sess.ID, i+1
+		db.DB.Create(&s)
+	}
+
+	g, err := service.SessionFlow(db.DB, sess.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := g.Pages()
+	if len(pages) != 2 || pages[0].Label != "案件列表" || pages[1].Label != "缴费" || pages[0].URLPattern != "gov.example.com/cases/:id" {
+		t.Fatalf("unexpected pages %+v", pages)
+	}
+	if len(g.Nodes) != 2+8 {
+		t.Fatalf("expected 8 step nodes besides pages, got %d nodes", len(g.Nodes))
+	}
+	labels := map[int]string{}
+	for _, n := range g.Nodes {
+		if n.Type == service.FlowStep {
+			labels[n.StepIndex] = n.Label
+			if n.StepIndex == 4 && n.Page != pages[1].ID {
+				t.Errorf("step 4 should belong to the payment page: %+v", n)
+			}
+		}
+	}
+	if labels[1] != "打开页面" || labels[2] != "点击【查询】" || labels[4] != "录入【金额】" || labels[6] != "按下 Enter 键" || labels[7] != "点击【返回】回到案件列表" {
+		t.Errorf("unexpected labels %v", labels)
+	}
+
+	var next int
+	var navs []service.FlowEdge
+	for _, e := range g.Edges {
+		if e.Type == service.FlowNext {
+			next++
+		} else {
+			navs = append(navs, e)
+		}
+	}
+	if next != 7 {
+		t.Errorf("expected 7 sequence edges, got %d", next)
+	}
+	// 案件列表 → 缴费 出现两次，合并为一条
+	if len(navs) != 2 || navs[0].Count != 2 || navs[0].Label != "点击【缴费】" || navs[1].From != pages[1].ID || navs[1].Count != 1 {
+		t.Errorf("unexpected navigation edges %+v", navs)
+	}
+}
+
+func TestFlowGraphMermaid(t *testing.T) {
+	steps := []db.RecordingStep{
+		{Action: "click", TargetElement: "缴费", PageURL: "https://gov.example.com/cases/1", PageTitle: `案件 "列表"`},
+		{Action: "click", TargetElement: "确定", PageURL: "https://gov.example.com/pay", PageTitle: "缴费",
+			AIDescription: "在缴费页面核对金额、缴款人与缴费项目后点击【确定】提交缴费申请"},
+		{Action: "click", TargetElement: "返回", PageURL: "https://gov.example.com/cases/2"},
+	}
+	for i := range steps {
+		steps[i].ID, steps[i].StepIndex = string(rune('a'+i)), i+1
+	}
+	got := service.BuildFlowGraph(steps).Mermaid()
+	want := "flowchart TD\n" +
+		"    p1[\"案件 #quot;列表#quot;\"]\n" +
+		"    p2[\"缴费\"]\n" +
+		"    p1 -->|\"点击【缴费】\"| p2\n" +
+		"    p2 -->|\"在缴费页面核对金额、缴款人与缴费项目后点击【确定…\"| p1\n"
+	if got != want {
+		t.Errorf("unexpected mermaid:\n%s", got)
+	}
+	if service.BuildFlowGraph(nil).Mermaid() != "" {
+		t.Error("empty graph should render nothing")
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidGlossary 术语表条目为空、过长、与规范用语相同、重复，或术语又是另一条目的规范用语
+var ErrInvalidGlossary = errors.New("invalid glossary")
+
+// maxGlossaryTermLen 术语与规范用语的最大长度（字符）
+const maxGlossaryTermLen = 32
+
+// ProjectGlossary 项目的术语表，按术语排序
+func ProjectGlossary(projectID string) []db.GlossaryTerm {
+	var terms []db.GlossaryTerm
+	db.DB.Where("project_id = ?", projectID).Order("term").Find(&terms)
+	return terms
+}
+
+// SessionGlossary 会话所属项目的术语表
+func SessionGlossary(sessionID string) []db.GlossaryTerm {
+	var session db.Session
+	if err := db.DB.Select("project_id").First(&session, "id = ?", sessionID).Error; err != nil {
+		return nil
+	}
+	return ProjectGlossary(session.ProjectID)
+}
+
+// SetGlossary 整体替换项目术语表（需在事务中调用）
+func SetGlossary(tx *gorm.DB, projectID string, terms []db.GlossaryTerm) ([]db.GlossaryTerm, error) {
+	seen := make(map[string]bool, len(terms))
+	preferred := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		preferred[strings.TrimSpace(t.Preferred)] = true
+	}
+	out := make([]db.GlossaryTerm, 0, len(terms))
+	for _, t := range terms {
+		term, wording := strings.TrimSpace(t.Term), strings.TrimSpace(t.Preferred)
+		switch {
+		case term == "" || wording == "":
+			return nil, fmt.Errorf("%w: term and preferred are required", ErrInvalidGlossary)
+		case utf8.RuneCountInString(term) > maxGlossaryTermLen || utf8.RuneCountInString(wording) > maxGlossaryTermLen:
+			return nil, fmt.Errorf("%w: %q is longer than %d characters", ErrInvalidGlossary, term, maxGlossaryTermLen)
+		case term == wording:
+			return nil, fmt.Errorf("%w: %q is the same as its preferred wording", ErrInvalidGlossary, term)
+		case seen[term]:
+			return nil, fmt.Errorf("%w: duplicate term %q", ErrInvalidGlossary, term)
+		case preferred[term]:
+			return nil, fmt.Errorf("%w: %q is also used as a preferred wording", ErrInvalidGlossary, term)
+		}
+		seen[term] = true
+		out = append(out, db.GlossaryTerm{ProjectID: projectID, Term: term, Preferred: wording})
+	}
+	if err := tx.Where("project_id = ?", projectID).Delete(&db.GlossaryTerm{}).Error; err != nil {
+		return nil, err
+	}
+	if len(out) > 0 {
+		if err := tx.Create(&out).Error; err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Term < out[j].Term })
+	return out, nil
+}
+
+// WithGlossary 返回使用项目术语表的服务副本：提示词中列出规范用语，输出再统一替换
+func (s *AIService) WithGlossary(terms []db.GlossaryTerm) *AIService {
+	c := *s
+	c.glossary = terms
+	return &c
+}
+
+// glossaryHint 提示词中的术语规范说明，按规范用语归并
+func glossaryHint(terms []db.GlossaryTerm) string {
+	if len(terms) == 0 {
+		return ""
+	}
+	var order []string
+	variants := map[string][]string{}
+	for _, t := range terms {
+		if _, ok := variants[t.Preferred]; !ok {
+			order = append(order, t.Preferred)
+		}
+		variants[t.Preferred] = append(variants[t.Preferred], "「"+t.Term+"」")
+	}
+	var sb strings.Builder
+	sb.WriteString("术语规范（必须遵守）：\n")
+	for _, p := range order {
+		sb.WriteString(fmt.Sprintf("- 统一写作「%s」，不要写作%s\n", p, strings.Join(variants[p], "、")))
+	}
+	return sb.String()
+}
+
+// applyGlossary 将文本中的不规范说法替换为规范用语；较长的术语优先匹配，已是规范用语的部分保持不变
+func applyGlossary(text string, terms []db.GlossaryTerm) string {
+	if len(terms) == 0 || text == "" {
+		return text
+	}
+	// 规范用语也作为候选原样保留，避免其本身包含术语时（如 办理 → 办理人）被重复替换
+	type pair struct{ from, to string }
+	pairs := make([]pair, 0, len(terms)*2)
+	for _, t := range terms {
+		pairs = append(pairs, pair{t.Preferred, t.Preferred}, pair{t.Term, t.Preferred})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return len(pairs[i].from) > len(pairs[j].from) })
+	args := make([]string, 0, len(pairs)*2)
+	for _, p := range pairs {
+		args = append(args, p.from, p.to)
+	}
+	return strings.NewReplacer(args...).Replace(text)
+}
@@ -0,0 +1,56 @@
+package service_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestProjectGlossary(t *testing.T) {
+	setupDB(t)
+	projectID, sessionID := seedSessionWithSteps(t, 1)
+
+	for name, terms := range map[string][]db.GlossaryTerm{
+		"empty":     {{Term: "操作员"}},
+		"same":      {{Term: "经办人", Preferred: "经办人"}},
+		"duplicate": {{Term: "操作员", Preferred: "经办人"}, {Term: " 操作员", Preferred: "办事员"}},
+		"chained":   {{Term: "操作员", Preferred: "办理人"}, {Term: "办理人", Preferred: "经办人"}},
+	} {
+		if _, err := service.SetGlossary(db.DB, projectID, terms); !errors.Is(err, service.ErrInvalidGlossary) {
+			t.Errorf("%s: expected ErrInvalidGlossary, got %v", name, err)
+		}
+	}
+	_, err := service.SetGlossary(db.DB, projectID, []db.GlossaryTerm{
+		{Term: "操作员", Preferred: "经办人"},
+		{Term: "办理人", Preferred: "经办人"},
+		{Term: "办理", Preferred: "办理业务"}, // 规范用语包含术语本身
+	})
+	if err != nil {
+		t.Fatalf("SetGlossary: %v", err)
+	}
+	if got := service.SessionGlossary(sessionID); len(got) != 3 {
+		t.Fatalf("expected 3 terms, got %+v", got)
+	}
+
+	var prompt string
+	ai := fakeOllama(t, func(p string) string {
+		prompt = p
+		return "第1步：操作员点击【提交】，由办理人审核后办理业务"
+	}).WithGlossary(service.SessionGlossary(sessionID))
+
+	resp, _ := ai.GenerateStepDescription(service.VLMRequest{StepAction: "click", TargetElement: "提交"})
+	if resp.Description != "第1步：经办人点击【提交】，由经办人审核后办理业务" {
+		t.Errorf("glossary should be applied to the output, got %q", resp.Description)
+	}
+	if !strings.Contains(prompt, "统一写作「经办人」，不要写作「办理人」、「操作员」") {
+		t.Errorf("glossary should be listed in the prompt:\n%s", prompt)
+	}
+
+	// 替换整个术语表
+	if terms, _ := service.SetGlossary(db.DB, projectID, nil); len(terms) != 0 || len(service.ProjectGlossary(projectID)) != 0 {
+		t.Error("an empty list should clear the glossary")
+	}
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gpilot/backend/internal/config"
+	"github.com/gpilot/backend/internal/db"
+)
+
+// 组件状态
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+)
+
+// ComponentHealth 单个组件的检查结果
+type ComponentHealth struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// HealthReport 深度健康检查报告
+type HealthReport struct {
+	Status     string            `json:"status"`
+	CheckedAt  string            `json:"checked_at"`
+	Components []ComponentHealth `json:"components"`
+}
+
+// HealthChecker 深度健康检查（DB、存储目录、磁盘空间、可选的 VLM 连通性）
+type HealthChecker struct {
+	storagePath  string
+	minFreeBytes uint64
+	ai           *AIService
+}
+
+func NewHealthChecker(storage config.StorageConfig, ai *AIService) *HealthChecker {
+	return &HealthChecker{
+		storagePath:  storage.Path,
+		minFreeBytes: uint64(storage.MinFreeMB) * 1024 * 1024,
+		ai:           ai,
+	}
+}
+
+// Check 执行所有检查；withProviders 为 true 时额外 ping 已配置的 VLM 提供商
+func (h *HealthChecker) Check(withProviders bool) *HealthReport {
+	components := []ComponentHealth{
+		timed("database", h.checkDB),
+		timed("storage", h.checkStorage),
+		timed("disk", h.checkDisk),
+	}
+	if withProviders && h.ai != nil {
+		components = append(components, h.ai.PingProviders()...)
+	}
+
+	report := &HealthReport{
+		Status:     HealthOK,
+		CheckedAt:  time.Now().Format(time.RFC3339),
+		Components: components,
+	}
+	for _, c := range components {
+		switch c.Status {
+		case HealthDown:
+			report.Status = HealthDown
+		case HealthDegraded:
+			if report.Status == HealthOK {
+				report.Status = HealthDegraded
+			}
+		}
+	}
+	return report
+}
+
+func timed(name string, fn func() (string, string)) ComponentHealth {
+	start := time.Now()
+	status, detail := fn()
+	return ComponentHealth{
+		Name:      name,
+		Status:    status,
+		LatencyMS: time.Since(start).Milliseconds(),
+		Detail:    detail,
+	}
+}
+
+func (h *HealthChecker) checkDB() (string, string) {
+	if db.DB == nil {
+		return HealthDown, "database not initialized"
+	}
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		return HealthDown, err.Error()
+	}
+	if err := sqlDB.Ping(); err != nil {
+		return HealthDown, err.Error()
+	}
+	return HealthOK, ""
+}
+
+func (h *HealthChecker) checkStorage() (string, string) {
+	if err := os.MkdirAll(h.storagePath, 0o755); err != nil {
+		return HealthDown, err.Error()
+	}
+	// 写入并删除探针文件，确认目录真正可写
+	probe := filepath.Join(h.storagePath, fmt.Sprintf(".health-%d", time.Now().UnixNano()))
+	if err := os.WriteFile(probe, []byte("ok"), 0o600); err != nil {
+		return HealthDown, err.Error()
+	}
+	_ = os.Remove(probe)
+	return HealthOK, h.storagePath
+}
+
+func (h *HealthChecker) checkDisk() (string, string) {
+	free, err := diskFree(h.storagePath)
+	if err != nil {
+		return HealthDegraded, err.Error()
+	}
+	detail := fmt.Sprintf("%d MB free", free/1024/1024)
+	if free < h.minFreeBytes {
+		return HealthDegraded, detail + fmt.Sprintf(" (below %d MB)", h.minFreeBytes/1024/1024)
+	}
+	return HealthOK, detail
+}
+
+// transportErrorDetail 网络错误的概要说明；原始错误文本含请求 URL，可能带有凭据，不对外输出
+func transportErrorDetail(err error) string {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return "timeout"
+	}
+	return "unreachable"
+}
+
+// PingProviders 对已配置的 VLM 提供商做一次快速连通性检查（列模型接口，不消耗额度）
+func (s *AIService) PingProviders() []ComponentHealth {
+	eff := s.effectiveCfg()
+
+	type target struct {
+		name   string
+		url    string
+		header map[string]string
+	}
+	bearer := func(key string) map[string]string { return map[string]string{"Authorization": "Bearer " + key} }
+	targets := []target{{"ollama", eff.OllamaBaseURL + "/api/tags", nil}}
+	if eff.ZhipuAPIKey != "" {
+		targets = append(targets, target{"zhipu", eff.ZhipuBaseURL + "/models", bearer(eff.ZhipuAPIKey)})
+	}
+	if eff.GeminiAPIKey != "" {
+		// 密钥放在请求头中，避免出现在网络错误信息的 URL 里
+		targets = append(targets, target{"gemini", eff.GeminiBaseURL + "/models", map[string]string{"x-goog-api-key": eff.GeminiAPIKey}})
+	}
+	if eff.OpenRouterAPIKey != "" {
+		targets = append(targets, target{"openrouter", eff.OpenRouterBaseURL + "/models", bearer(eff.OpenRouterAPIKey)})
+	}
+	if eff.OpenAIAPIKey != "" {
+		targets = append(targets, target{"openai", eff.OpenAIBaseURL + "/models", bearer(eff.OpenAIAPIKey)})
+	}
+
+	results := make([]ComponentHealth, 0, len(targets))
+	for _, t := range targets {
+		t := t
+		results = append(results, timed("provider:"+t.name, func() (string, string) {
+			req, err := http.NewRequest("GET", t.url, nil)
+			if err != nil {
+				return HealthDegraded, "invalid base URL"
+			}
+			for k, v := range t.header {
+				req.Header.Set(k, v)
+			}
+			// 与实际调用走同一出站代理，超时保持较短
+			client := s.httpClient(eff, t.name)
+			client.Timeout = 3 * time.Second
+			resp, err := client.Do(req)
+			if err != nil {
+				// VLM 不可用时有规则兜底，只算降级；健康检查无需认证，不输出原始错误
+				return HealthDegraded, transportErrorDetail(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return HealthDegraded, fmt.Sprintf("status %d", resp.StatusCode)
+			}
+			return HealthOK, ""
+		}))
+	}
+	return results
+}
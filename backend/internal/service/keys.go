@@ -0,0 +1,110 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// 键盘操作类型
+const (
+	ActionKeypress = "keypress" // 单个功能键（Enter、Esc、F2 等）
+	ActionShortcut = "shortcut" // 带修饰键的组合键（Ctrl+S 等）
+)
+
+// IsKeyAction 是否为键盘操作
+func IsKeyAction(action string) bool {
+	return action == ActionKeypress || action == ActionShortcut
+}
+
+// 修饰键按固定顺序输出，同一组合键总是得到相同的写法
+var modifierOrder = []string{"Ctrl", "Alt", "Shift", "Meta"}
+
+var modifierAliases = map[string]string{
+	"ctrl": "Ctrl", "control": "Ctrl", "alt": "Alt", "option": "Alt", "shift": "Shift",
+	"meta": "Meta", "cmd": "Meta", "command": "Meta", "win": "Meta",
+}
+
+var namedKeys = map[string]string{
+	"enter": "Enter", "return": "Enter",
+	"esc": "Esc", "escape": "Esc",
+	"tab": "Tab", "space": "Space", " ": "Space",
+	"backspace": "Backspace", "delete": "Delete", "del": "Delete", "insert": "Insert",
+	"home": "Home", "end": "End", "pageup": "PageUp", "pagedown": "PageDown",
+	"arrowup": "↑", "up": "↑", "arrowdown": "↓", "down": "↓",
+	"arrowleft": "←", "left": "←", "arrowright": "→", "right": "→",
+}
+
+// ErrKeyRequired 键盘操作缺少按键
+var ErrKeyRequired = errors.New("key is required")
+
+// NormalizeKeyCombo 将按键与修饰键规范化为 “Ctrl+Shift+S” 形式；
+// combo 非空时按 “+” 拆分解析（如 ctrl+s），否则由 key 与 modifiers 组合
+func NormalizeKeyCombo(combo, key string, modifiers []string) (string, error) {
+	if combo != "" {
+		parts := strings.Split(combo, "+")
+		// “Ctrl++” 中最后一段为空，表示加号键本身
+		if strings.HasSuffix(combo, "++") {
+			parts = append(parts[:len(parts)-2], "+")
+		}
+		key, modifiers = parts[len(parts)-1], parts[:len(parts)-1]
+	}
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return "", ErrKeyRequired
+	}
+
+	seen := map[string]bool{}
+	for _, m := range modifiers {
+		name, ok := modifierAliases[strings.ToLower(strings.TrimSpace(m))]
+		if !ok {
+			return "", fmt.Errorf("unknown modifier %q", m)
+		}
+		seen[name] = true
+	}
+	if _, ok := modifierAliases[strings.ToLower(key)]; ok {
+		return "", fmt.Errorf("key %q is a modifier", key)
+	}
+
+	parts := make([]string, 0, len(modifierOrder)+1)
+	for _, m := range modifierOrder {
+		if seen[m] {
+			parts = append(parts, m)
+		}
+	}
+	return strings.Join(append(parts, keyName(key)), "+"), nil
+}
+
+// keyName 规范化主键名：单字符转大写，功能键 F1-F24 与常用命名键使用统一写法
+func keyName(key string) string {
+	if name, ok := namedKeys[strings.ToLower(key)]; ok {
+		return name
+	}
+	if utf8.RuneCountInString(key) == 1 {
+		return strings.ToUpper(key)
+	}
+	lower := strings.ToLower(key)
+	if lower[0] == 'f' && len(lower) <= 3 && strings.Trim(lower[1:], "0123456789") == "" {
+		return strings.ToUpper(lower)
+	}
+	return strings.ToUpper(key[:1]) + key[1:]
+}
+
+// HasModifier 规范化后的组合键是否包含修饰键
+func HasModifier(combo string) bool {
+	for _, m := range modifierOrder {
+		if strings.HasPrefix(combo, m+"+") {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyPhrase 键盘操作的中文表述，如 “按下 Enter 键”“按下快捷键 Ctrl+S”
+func KeyPhrase(action, combo string) string {
+	if action == ActionShortcut {
+		return "按下快捷键 " + combo
+	}
+	return "按下 " + combo + " 键"
+}
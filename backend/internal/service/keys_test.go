@@ -0,0 +1,86 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestNormalizeKeyCombo(t *testing.T) {
+	cases := []struct {
+		combo, key string
+		modifiers  []string
+		want       string
+	}{
+		{"", "Enter", nil, "Enter"},
+		{"", "escape", nil, "Esc"},
+		{"", "f2", nil, "F2"},
+		{"", "s", []string{"shift", "control"}, "Ctrl+Shift+S"},
+		{"ctrl+alt+delete", "", nil, "Ctrl+Alt+Delete"},
+		{"cmd+ArrowUp", "", nil, "Meta+↑"},
+		{"ctrl++", "", nil, "Ctrl++"},
+		{"", "PrintScreen", nil, "PrintScreen"},
+	}
+	for _, c := range cases {
+		got, err := service.NormalizeKeyCombo(c.combo, c.key, c.modifiers)
+		if err != nil || got != c.want {
+			t.Errorf("NormalizeKeyCombo(%q, %q, %v) = %q, %v; want %q", c.combo, c.key, c.modifiers, got, err, c.want)
+		}
+	}
+
+	for _, c := range []struct {
+		combo, key string
+		modifiers  []string
+	}{
+		{"", "", nil},
+		{"", "s", []string{"hyper"}},
+		{"ctrl+shift", "", nil},
+	} {
+		if _, err := service.NormalizeKeyCombo(c.combo, c.key, c.modifiers); err == nil {
+			t.Errorf("NormalizeKeyCombo(%q, %q, %v): expected error", c.combo, c.key, c.modifiers)
+		}
+	}
+
+	if service.HasModifier("Enter") || !service.HasModifier("Shift+Tab") {
+		t.Error("HasModifier misreports modifiers")
+	}
+}
+
+func TestBuildDocument_KeyboardSteps(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "键盘", MergeStrategy: service.MergeByPage}
+	db.DB.Create(&proj)
+	sess := db.Session{ProjectID: proj.ID, Title: "办件录入"}
+	db.DB.Create(&sess)
+
+	steps := []db.RecordingStep{
+		{Action: "input", TargetElement: "在 办件登记 页面的 表单区域，在功能为 申请人 的 输入框 中录入了业务信息，实现 填写申请人。"},
+		{Action: service.ActionKeypress, KeyCombo: "Enter"},
+		{Action: service.ActionShortcut, KeyCombo: "Ctrl+S"},
+	}
+	for i := range steps {
+		steps[i].SessionID, steps[i].StepIndex, steps[i].PageTitle = sess.ID, i+1, "办件登记"
+		db.DB.Create(&steps[i])
+	}
+
+	content, err := service.NewDocService().BuildDocument(sess.ID)
+	if err != nil {
+		t.Fatalf("BuildDocument: %v", err)
+	}
+	biz := content.BusinessView[0].Steps
+	if len(biz) != 1 || !strings.Contains(biz[0].Description, "按下 【Enter】、按下 【Ctrl+S】") {
+		t.Errorf("merged business step should list key presses, got %+v", biz)
+	}
+	tech := content.TechnicalView[0].Steps
+	if len(tech) != 3 {
+		t.Fatalf("expected 3 technical steps, got %d", len(tech))
+	}
+	if tech[2].Description != "按下快捷键 Ctrl+S" || !strings.Contains(tech[2].TechNote, "按键：Ctrl+S") {
+		t.Errorf("technical shortcut step not rendered: %+v", tech[2])
+	}
+	if tech[1].Description != "按下 Enter 键" {
+		t.Errorf("technical keypress step not rendered: %q", tech[1].Description)
+	}
+}
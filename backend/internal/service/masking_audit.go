@@ -0,0 +1,171 @@
+package service
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// MaskingHit 插件上报的一次规则命中：只有规则、字段、类别与次数，不含被替换的原文
+type MaskingHit struct {
+	RuleID   string `json:"rule_id"`
+	RuleType string `json:"rule_type"`
+	Alias    string `json:"alias"`
+	Category string `json:"category"`
+	Field    string `json:"field"`
+	Count    int    `json:"count"`
+}
+
+// maxAuditLabelRunes 别名、类别的长度上限（防止把原文塞进标签字段）
+const maxAuditLabelRunes = 32
+
+// MaskingEvents 将命中记录转换为审计行；类别缺省时取别名去掉括号后的文本
+func MaskingEvents(hits []MaskingHit) []db.MaskingEvent {
+	out := make([]db.MaskingEvent, 0, len(hits))
+	for _, h := range hits {
+		category := strings.TrimSpace(h.Category)
+		if category == "" {
+			category = strings.Trim(strings.TrimSpace(h.Alias), "【】[]")
+		}
+		out = append(out, db.MaskingEvent{
+			RuleID: h.RuleID, RuleType: h.RuleType, Alias: truncateRunes(h.Alias, maxAuditLabelRunes),
+			Category: truncateRunes(category, maxAuditLabelRunes), Field: h.Field, Count: h.Count,
+		})
+	}
+	return out
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// AttachMaskingEvents 写入步骤的脱敏审计记录（需在事务中调用）
+func AttachMaskingEvents(tx *gorm.DB, step *db.RecordingStep, events []db.MaskingEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	for i := range events {
+		events[i].StepID, events[i].SessionID = step.ID, step.SessionID
+	}
+	return tx.Create(&events).Error
+}
+
+// 残留敏感信息检测：与默认脱敏规则一致，但不含误报率高的邮政编码
+var piiDetectors = []struct {
+	category string
+	re       *regexp.Regexp
+}{
+	{"身份证号", regexp.MustCompile(`\b\d{17}[\dXx]\b`)},
+	{"银行卡号", regexp.MustCompile(`\b\d{4}[\s\-]?\d{4}[\s\-]?\d{4}[\s\-]?\d{4}(\d{1,3})?\b`)},
+	{"手机号", regexp.MustCompile(`(^|\D)1[3-9]\d{9}(\D|$)`)},
+	{"邮箱", regexp.MustCompile(`[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`)},
+}
+
+// PIIFinding 步骤已保存字段中疑似未脱敏的敏感信息（只给出类别与次数）
+type PIIFinding struct {
+	Field    string `json:"field"`
+	Category string `json:"category"`
+	Count    int    `json:"count"`
+}
+
+// ScanResidualPII 检查步骤中会进入文档的文本字段是否仍含疑似敏感信息
+func ScanResidualPII(step *db.RecordingStep) []PIIFinding {
+	fields := []struct{ name, text string }{
+		{"masked_text", step.MaskedText},
+		{"input_value", step.InputValue},
+		{"target_element", step.TargetElement},
+		{"ai_description", step.AIDescription},
+		{"page_title", step.PageTitle},
+		{"page_url", step.PageURL},
+	}
+	var out []PIIFinding
+	for _, f := range fields {
+		text := f.text
+		for _, d := range piiDetectors {
+			matches := d.re.FindAllStringIndex(text, -1)
+			if len(matches) == 0 {
+				continue
+			}
+			out = append(out, PIIFinding{Field: f.name, Category: d.category, Count: len(matches)})
+			// 已归类的片段不再参与后续检测（身份证号不会再被当作手机号）
+			text = d.re.ReplaceAllString(text, " ")
+		}
+	}
+	return out
+}
+
+// StepMaskingAudit 单个步骤的脱敏审计
+type StepMaskingAudit struct {
+	StepID     string            `json:"step_id"`
+	StepIndex  int               `json:"step_index"`
+	IsMasked   bool              `json:"is_masked"`
+	Events     []db.MaskingEvent `json:"events"`
+	Categories []string          `json:"categories"` // 被替换的文本类别（去重）
+	Residual   []PIIFinding      `json:"residual"`   // 疑似残留的敏感信息
+}
+
+// SessionMaskingAudit 会话脱敏审计：逐步骤列出命中的规则与残留检测结果
+type SessionMaskingAudit struct {
+	SessionID     string             `json:"session_id"`
+	Steps         []StepMaskingAudit `json:"steps"`
+	MaskedSteps   int                `json:"masked_steps"`
+	Replacements  map[string]int     `json:"replacements"` // 类别 → 替换次数
+	ResidualSteps int                `json:"residual_steps"`
+	Clean         bool               `json:"clean"` // 没有任何疑似残留
+}
+
+// BuildMaskingAudit 汇总会话的脱敏审计
+func BuildMaskingAudit(sessionID string) (*SessionMaskingAudit, error) {
+	var steps []db.RecordingStep
+	if err := db.DB.Where("session_id = ?", sessionID).Order("step_index").Find(&steps).Error; err != nil {
+		return nil, err
+	}
+	var events []db.MaskingEvent
+	if err := db.DB.Where("session_id = ?", sessionID).Order("created_at").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	byStep := map[string][]db.MaskingEvent{}
+	for _, e := range events {
+		byStep[e.StepID] = append(byStep[e.StepID], e)
+	}
+
+	audit := &SessionMaskingAudit{SessionID: sessionID, Steps: make([]StepMaskingAudit, 0, len(steps)), Replacements: map[string]int{}}
+	for i := range steps {
+		s := &steps[i]
+		sa := StepMaskingAudit{
+			StepID: s.ID, StepIndex: s.StepIndex, IsMasked: s.IsMasked,
+			Events: byStep[s.ID], Categories: []string{}, Residual: ScanResidualPII(s),
+		}
+		if sa.Events == nil {
+			sa.Events = []db.MaskingEvent{}
+		}
+		if sa.Residual == nil {
+			sa.Residual = []PIIFinding{}
+		}
+		seen := map[string]bool{}
+		for _, e := range sa.Events {
+			audit.Replacements[e.Category] += e.Count
+			if !seen[e.Category] {
+				seen[e.Category] = true
+				sa.Categories = append(sa.Categories, e.Category)
+			}
+		}
+		sort.Strings(sa.Categories)
+		if len(sa.Events) > 0 || s.IsMasked {
+			audit.MaskedSteps++
+		}
+		if len(sa.Residual) > 0 {
+			audit.ResidualSteps++
+		}
+		audit.Steps = append(audit.Steps, sa)
+	}
+	audit.Clean = audit.ResidualSteps == 0
+	return audit, nil
+}
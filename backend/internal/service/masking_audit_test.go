@@ -0,0 +1,71 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestScanResidualPII(t *testing.T) {
+	step := &db.RecordingStep{
+		MaskedText:    "申请人【手机号】，身份证 110101199003071234",
+		InputValue:    "13812345678",
+		TargetElement: "联系邮箱 zhang@example.com",
+		PageURL:       "http://gov.example.com/cases?t=1700000000000",
+	}
+	got := map[string]string{}
+	for _, f := range service.ScanResidualPII(step) {
+		got[f.Field] = f.Category
+	}
+	want := map[string]string{"masked_text": "身份证号", "input_value": "手机号", "target_element": "邮箱"}
+	if len(got) != len(want) {
+		t.Fatalf("unexpected findings: %v", got)
+	}
+	for field, category := range want {
+		if got[field] != category {
+			t.Errorf("%s: expected %s, got %q", field, category, got[field])
+		}
+	}
+}
+
+func TestBuildMaskingAudit(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "审计"}
+	db.DB.Create(&proj)
+	sess := db.Session{ProjectID: proj.ID, Title: "办件登记"}
+	db.DB.Create(&sess)
+
+	hits := []service.MaskingHit{
+		{RuleID: "r1", RuleType: "regex", Alias: "【手机号】", Field: "masked_text", Count: 2},
+		{RuleType: "exact", Alias: "【姓名】", Category: "姓名", Field: "input_value", Count: 1},
+	}
+	steps := []service.StepInput{
+		{Step: db.RecordingStep{Action: "input", MaskedText: "【手机号】【手机号】", InputValue: "【姓名】", IsMasked: true}, MaskingEvents: service.MaskingEvents(hits)},
+		{Step: db.RecordingStep{Action: "input", InputValue: "13812345678"}},
+	}
+	for i := range steps {
+		steps[i].Step.SessionID, steps[i].Step.StepIndex = sess.ID, i+1
+		if _, err := service.IngestStep(db.DB, steps[i]); err != nil {
+			t.Fatalf("IngestStep: %v", err)
+		}
+	}
+
+	audit, err := service.BuildMaskingAudit(sess.ID)
+	if err != nil {
+		t.Fatalf("BuildMaskingAudit: %v", err)
+	}
+	if len(audit.Steps) != 2 || audit.MaskedSteps != 1 || audit.ResidualSteps != 1 || audit.Clean {
+		t.Fatalf("unexpected audit summary: %+v", audit)
+	}
+	first := audit.Steps[0]
+	if len(first.Events) != 2 || first.Events[0].Category != "手机号" || len(first.Categories) != 2 {
+		t.Errorf("unexpected step audit: %+v", first)
+	}
+	if audit.Replacements["手机号"] != 2 || audit.Replacements["姓名"] != 1 {
+		t.Errorf("unexpected replacement totals: %v", audit.Replacements)
+	}
+	if r := audit.Steps[1].Residual; len(r) != 1 || r[0].Field != "input_value" || r[0].Category != "手机号" {
+		t.Errorf("expected residual phone number in step 2, got %+v", r)
+	}
+}
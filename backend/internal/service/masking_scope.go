@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// MaskingScopeURL 规则仅在 URL 匹配的页面生效（如只对公民详情页启用“遮蔽全部输入”）
+const MaskingScopeURL = "url"
+
+// URL 匹配方式
+const (
+	URLPatternGlob  = "glob"  // 整个 URL 匹配，* 匹配任意字符（含 /），? 匹配单个字符
+	URLPatternRegex = "regex" // 正则，在 URL 中查找
+)
+
+// CompileURLPattern 将 URL 模式编译为正则；patternType 为空时按 glob 处理
+func CompileURLPattern(patternType, pattern string) (*regexp.Regexp, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return nil, errors.New("url pattern is empty")
+	}
+	if patternType == URLPatternRegex {
+		return regexp.Compile(pattern)
+	}
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+// RuleAppliesTo 规则是否在该页面生效；非 URL 作用范围的规则总是生效，模式无效时视为不生效
+func RuleAppliesTo(rule *db.MaskingRule, pageURL string) bool {
+	if rule.Scope != MaskingScopeURL {
+		return true
+	}
+	re, err := CompileURLPattern(rule.URLPatternType, rule.URLPattern)
+	return err == nil && re.MatchString(pageURL)
+}
+
+// ApplicableRules 筛选在该页面生效的启用规则
+func ApplicableRules(rules []db.MaskingRule, pageURL string) []db.MaskingRule {
+	out := make([]db.MaskingRule, 0, len(rules))
+	for i := range rules {
+		if rules[i].IsActive && RuleAppliesTo(&rules[i], pageURL) {
+			out = append(out, rules[i])
+		}
+	}
+	return out
+}
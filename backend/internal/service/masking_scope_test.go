@@ -0,0 +1,43 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestRuleAppliesTo(t *testing.T) {
+	detail := "https://gov.example.com/citizens/42/detail?tab=base"
+	cases := []struct {
+		rule db.MaskingRule
+		url  string
+		want bool
+	}{
+		{db.MaskingRule{Scope: "global"}, detail, true},
+		{db.MaskingRule{Scope: "url", URLPattern: "https://gov.example.com/citizens/*/detail*"}, detail, true},
+		{db.MaskingRule{Scope: "url", URLPattern: "https://gov.example.com/citizens/*/detail"}, detail, false},
+		{db.MaskingRule{Scope: "url", URLPattern: "*/cases/*"}, detail, false},
+		{db.MaskingRule{Scope: "url", URLPattern: "https://gov.example.com/citizens/4?/detail*"}, detail, true},
+		{db.MaskingRule{Scope: "url", URLPatternType: "regex", URLPattern: `/citizens/\d+/detail`}, detail, true},
+		{db.MaskingRule{Scope: "url", URLPatternType: "regex", URLPattern: `/citizens/\d+/edit`}, detail, false},
+		{db.MaskingRule{Scope: "url", URLPatternType: "regex", URLPattern: `([`}, detail, false},
+		{db.MaskingRule{Scope: "url"}, detail, false},
+	}
+	for _, c := range cases {
+		if got := service.RuleAppliesTo(&c.rule, c.url); got != c.want {
+			t.Errorf("RuleAppliesTo(%s %q) = %v, want %v", c.rule.URLPatternType, c.rule.URLPattern, got, c.want)
+		}
+	}
+
+	rules := []db.MaskingRule{
+		{Scope: "global", IsActive: true, Alias: "a"},
+		{Scope: "global", IsActive: false, Alias: "b"},
+		{Scope: "url", URLPattern: "*/citizens/*", IsActive: true, Alias: "c"},
+		{Scope: "url", URLPattern: "*/cases/*", IsActive: true, Alias: "d"},
+	}
+	got := service.ApplicableRules(rules, detail)
+	if len(got) != 2 || got[0].Alias != "a" || got[1].Alias != "c" {
+		t.Errorf("unexpected applicable rules: %+v", got)
+	}
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gpilot/backend/internal/db"
+)
+
+// MediaDirName 存储目录下存放会话附件的子目录（随存储目录一起备份）
+const MediaDirName = "media"
+
+// MediaKindVideo 整段操作录像
+const MediaKindVideo = "video"
+
+// mediaExtensions 支持的录像格式（按内容嗅探，不信任客户端声明的类型）
+var mediaExtensions = map[string]string{
+	"video/webm": ".webm",
+	"video/mp4":  ".mp4",
+}
+
+var (
+	// ErrMediaTooLarge 附件超过大小上限
+	ErrMediaTooLarge = errors.New("media too large")
+	// ErrUnsupportedMedia 附件不是支持的录像格式
+	ErrUnsupportedMedia = errors.New("unsupported media type (webm or mp4 expected)")
+)
+
+// MediaInput 一次附件上传
+type MediaInput struct {
+	SessionID  string
+	FileName   string
+	DurationMS int64
+	Body       io.Reader
+	MaxBytes   int64
+}
+
+// SessionMediaDir 会话附件目录
+func SessionMediaDir(storagePath, sessionID string) string {
+	return filepath.Join(storagePath, MediaDirName, sessionID)
+}
+
+// SaveSessionMedia 流式写入附件文件并登记记录：先写临时文件，嗅探格式、计算哈希，
+// 校验通过后改名为正式文件（开启静态加密时加密后写入正式文件）；任何一步失败都不会留下文件
+func SaveSessionMedia(storagePath string, in MediaInput) (*db.SessionMedia, error) {
+	dir := SessionMediaDir(storagePath, in.SessionID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// 多读 1 字节用于判断是否超限
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(in.Body, in.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if size > in.MaxBytes {
+		return nil, ErrMediaTooLarge
+	}
+	if size == 0 {
+		return nil, errors.New("empty body")
+	}
+
+	head := make([]byte, 512)
+	n, _ := tmp.ReadAt(head, 0)
+	mime := http.DetectContentType(head[:n])
+	ext, ok := mediaExtensions[mime]
+	if !ok {
+		return nil, ErrUnsupportedMedia
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	media := &db.SessionMedia{
+		Base:       db.Base{ID: uuid.New().String()},
+		SessionID:  in.SessionID,
+		Kind:       MediaKindVideo,
+		MimeType:   mime,
+		FileName:   mediaFileName(in.FileName),
+		Size:       size,
+		SHA256:     hex.EncodeToString(h.Sum(nil)),
+		DurationMS: in.DurationMS,
+	}
+	media.Path = filepath.ToSlash(filepath.Join(MediaDirName, in.SessionID, media.ID+ext))
+	final := MediaFilePath(storagePath, media)
+	if err := placeMediaFile(tmp.Name(), final); err != nil {
+		return nil, err
+	}
+	if err := db.DB.Create(media).Error; err != nil {
+		os.Remove(final)
+		return nil, err
+	}
+	return media, nil
+}
+
+// placeMediaFile 把校验通过的临时文件放到正式位置；开启静态加密时录像可能拍到敏感信息，加密后落盘
+func placeMediaFile(tmp, final string) error {
+	if !db.EncryptionEnabled() {
+		return os.Rename(tmp, final)
+	}
+	data, err := os.ReadFile(tmp)
+	if err != nil {
+		return err
+	}
+	sealed, err := db.SealBytes(data)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(final, sealed, 0o600); err != nil {
+		os.Remove(final)
+		return err
+	}
+	return nil
+}
+
+// SealMediaFiles 加密启用加密前保存的明文附件文件，返回处理的文件数
+func SealMediaFiles(storagePath string) (int, error) {
+	if !db.EncryptionEnabled() {
+		return 0, nil
+	}
+	var media []db.SessionMedia
+	if err := db.DB.Find(&media).Error; err != nil {
+		return 0, err
+	}
+	sealed := 0
+	for i := range media {
+		path := MediaFilePath(storagePath, &media[i])
+		ok, err := SealedFile(path)
+		if errors.Is(err, os.ErrNotExist) || ok {
+			continue
+		}
+		if err != nil {
+			return sealed, err
+		}
+		// 先写临时文件再改名，中途失败不会损坏原文件
+		tmp := path + ".sealing"
+		if err := placeMediaFile(path, tmp); err != nil {
+			return sealed, err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return sealed, err
+		}
+		sealed++
+	}
+	return sealed, nil
+}
+
+// mediaFileName 客户端文件名仅用于展示，去掉目录部分
+func mediaFileName(name string) string {
+	name = filepath.Base(filepath.FromSlash(strings.TrimSpace(name)))
+	if name == "." || name == string(filepath.Separator) {
+		return ""
+	}
+	return name
+}
+
+// MediaFilePath 附件文件的绝对路径
+func MediaFilePath(storagePath string, media *db.SessionMedia) string {
+	return filepath.Join(storagePath, filepath.FromSlash(media.Path))
+}
+
+// DeleteSessionMedia 删除单个附件的记录与文件
+func DeleteSessionMedia(storagePath string, media *db.SessionMedia) error {
+	if err := db.DB.Delete(media).Error; err != nil {
+		return err
+	}
+	if err := os.Remove(MediaFilePath(storagePath, media)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// RemoveSessionMediaFiles 删除会话的附件目录；在删除会话的事务提交后调用
+func RemoveSessionMediaFiles(storagePath string, sessionIDs []string) error {
+	if storagePath == "" {
+		return nil
+	}
+	var errs []error
+	for _, id := range sessionIDs {
+		// 防御：ID 不应包含路径分隔符
+		if id == "" || strings.ContainsAny(id, `/\`) || id == ".." {
+			continue
+		}
+		if err := os.RemoveAll(SessionMediaDir(storagePath, id)); err != nil {
+			errs = append(errs, fmt.Errorf("session %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MediaURL 附件文件的访问地址
+func MediaURL(mediaID string) string {
+	return "/api/v1/media/" + mediaID + "/file"
+}
+
+// SessionMediaLinks 会话附件在文档中的引用，按上传顺序排列
+func SessionMediaLinks(sessionID string) []DocMedia {
+	var media []db.SessionMedia
+	db.DB.Where("session_id = ?", sessionID).Order("created_at").Find(&media)
+	links := make([]DocMedia, 0, len(media))
+	for _, m := range media {
+		links = append(links, DocMedia{
+			ID: m.ID, Kind: m.Kind, FileName: m.FileName, MimeType: m.MimeType,
+			Size: m.Size, DurationMS: m.DurationMS, URL: MediaURL(m.ID),
+		})
+	}
+	return links
+}
@@ -0,0 +1,129 @@
+package service_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+// webmBytes 以 EBML 头开头的最小 WebM 样本（仅用于格式嗅探）
+func webmBytes(n int) []byte {
+	return append([]byte{0x1A, 0x45, 0xDF, 0xA3}, bytes.Repeat([]byte{0}, n)...)
+}
+
+func TestSaveSessionMedia(t *testing.T) {
+	setupDB(t)
+	storage := t.TempDir()
+	proj := db.Project{Name: "录像"}
+	db.DB.Create(&proj)
+	sess := db.Session{ProjectID: proj.ID, Title: "办件流程"}
+	db.DB.Create(&sess)
+
+	media, err := service.SaveSessionMedia(storage, service.MediaInput{
+		SessionID: sess.ID, FileName: "../../流程.webm", DurationMS: 150_000,
+		Body: bytes.NewReader(webmBytes(1024)), MaxBytes: 4096,
+	})
+	if err != nil {
+		t.Fatalf("SaveSessionMedia: %v", err)
+	}
+	if media.MimeType != "video/webm" || media.FileName != "流程.webm" || media.Size != 1028 || len(media.SHA256) != 64 {
+		t.Errorf("unexpected media record: %+v", media)
+	}
+	path := service.MediaFilePath(storage, media)
+	if filepath.Dir(path) != service.SessionMediaDir(storage, sess.ID) {
+		t.Errorf("media stored outside session dir: %s", path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("media file missing: %v", err)
+	}
+
+	_, err = service.SaveSessionMedia(storage, service.MediaInput{
+		SessionID: sess.ID, Body: bytes.NewReader(webmBytes(8192)), MaxBytes: 4096,
+	})
+	if !errors.Is(err, service.ErrMediaTooLarge) {
+		t.Errorf("expected ErrMediaTooLarge, got %v", err)
+	}
+	_, err = service.SaveSessionMedia(storage, service.MediaInput{
+		SessionID: sess.ID, Body: strings.NewReader("not a video"), MaxBytes: 4096,
+	})
+	if !errors.Is(err, service.ErrUnsupportedMedia) {
+		t.Errorf("expected ErrUnsupportedMedia, got %v", err)
+	}
+	// 失败的上传不留下临时文件
+	entries, _ := os.ReadDir(service.SessionMediaDir(storage, sess.ID))
+	if len(entries) != 1 {
+		t.Errorf("expected only the saved file, got %d entries", len(entries))
+	}
+
+	// 文档引用附件
+	db.DB.Create(&db.RecordingStep{SessionID: sess.ID, StepIndex: 1, Action: "click", PageTitle: "首页", AIDescription: "点击登录"})
+	svc := service.NewDocService()
+	content, err := svc.BuildDocument(sess.ID)
+	if err != nil {
+		t.Fatalf("BuildDocument: %v", err)
+	}
+	if len(content.Media) != 1 || content.Media[0].URL != service.MediaURL(media.ID) {
+		t.Fatalf("document should link the media, got %+v", content.Media)
+	}
+	md := svc.GenerateMarkdown(content, "business")
+	if !strings.Contains(md, "## 补充材料") || !strings.Contains(md, "- [流程.webm](/api/v1/media/"+media.ID+"/file)（时长约 3 分钟）") {
+		t.Errorf("markdown missing media link:\n%s", md)
+	}
+
+	if err := service.RemoveSessionMediaFiles(storage, []string{sess.ID}); err != nil {
+		t.Fatalf("RemoveSessionMediaFiles: %v", err)
+	}
+	if _, err := os.Stat(service.SessionMediaDir(storage, sess.ID)); !os.IsNotExist(err) {
+		t.Error("session media dir should be removed")
+	}
+}
+
+func TestSaveSessionMedia_Sealed(t *testing.T) {
+	setupDB(t)
+	storage := t.TempDir()
+	sess := db.Session{ProjectID: "p", Title: "加密录像"}
+	db.DB.Create(&sess)
+	save := func() *db.SessionMedia {
+		t.Helper()
+		media, err := service.SaveSessionMedia(storage, service.MediaInput{
+			SessionID: sess.ID, Body: bytes.NewReader(webmBytes(1024)), MaxBytes: 4096,
+		})
+		if err != nil {
+			t.Fatalf("SaveSessionMedia: %v", err)
+		}
+		return media
+	}
+	// 启用加密前保存的明文附件
+	legacy := save()
+
+	if err := db.SetEncryptionKey([]byte("0123456789abcdef0123456789abcdef")); err != nil {
+		t.Fatalf("SetEncryptionKey: %v", err)
+	}
+	t.Cleanup(func() { db.SetEncryptionKey(nil) })
+	media := save()
+	raw, _ := os.ReadFile(service.MediaFilePath(storage, media))
+	if !db.IsSealedBytes(raw) || media.MimeType != "video/webm" || media.Size != 1028 {
+		t.Errorf("media should be sealed on disk: %+v", media)
+	}
+	if data, err := service.ReadSealedFile(service.MediaFilePath(storage, media)); err != nil || !bytes.Equal(data, webmBytes(1024)) {
+		t.Errorf("decrypted media differs: %v", err)
+	}
+
+	n, err := service.SealMediaFiles(storage)
+	if err != nil || n != 1 {
+		t.Fatalf("SealMediaFiles: %d %v", n, err)
+	}
+	legacyPath := service.MediaFilePath(storage, legacy)
+	if sealed, _ := service.SealedFile(legacyPath); !sealed {
+		t.Error("legacy media should be sealed")
+	}
+	if data, _ := service.ReadSealedFile(legacyPath); !bytes.Equal(data, webmBytes(1024)) {
+		t.Error("legacy media content changed after sealing")
+	}
+}
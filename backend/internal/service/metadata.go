@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// 元数据字段限制
+const (
+	MaxMetadataFields   = 50
+	MaxMetadataKeyLen   = 64
+	MaxMetadataValueLen = 1024
+)
+
+// ErrInvalidMetadata 元数据不符合限制
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// ValidateMetadata 校验并规范化元数据：去除键值首尾空白，丢弃空值字段
+func ValidateMetadata(m map[string]string) (db.Metadata, error) {
+	if len(m) > MaxMetadataFields {
+		return nil, fmt.Errorf("%w: at most %d fields", ErrInvalidMetadata, MaxMetadataFields)
+	}
+	out := db.Metadata{}
+	for k, v := range m {
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if k == "" || len([]rune(k)) > MaxMetadataKeyLen {
+			return nil, fmt.Errorf("%w: key must be 1-%d characters", ErrInvalidMetadata, MaxMetadataKeyLen)
+		}
+		if len([]rune(v)) > MaxMetadataValueLen {
+			return nil, fmt.Errorf("%w: value of %q exceeds %d characters", ErrInvalidMetadata, k, MaxMetadataValueLen)
+		}
+		if v != "" {
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+// MergeMetadata 合并项目默认字段与文档字段，文档同名字段优先
+func MergeMetadata(project, doc db.Metadata) map[string]string {
+	if len(project) == 0 && len(doc) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(project)+len(doc))
+	for k, v := range project {
+		out[k] = v
+	}
+	for k, v := range doc {
+		out[k] = v
+	}
+	return out
+}
+
+// MetadataField 用于渲染的有序字段
+type MetadataField struct {
+	Key   string
+	Value string
+}
+
+// SortedMetadata 按键排序，保证导出结果稳定
+func SortedMetadata(m map[string]string) []MetadataField {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fields := make([]MetadataField, len(keys))
+	for i, k := range keys {
+		fields[i] = MetadataField{Key: k, Value: m[k]}
+	}
+	return fields
+}
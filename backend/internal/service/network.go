@@ -0,0 +1,166 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// MaxRequestsPerStep 单个步骤最多保留的请求数（轮询、埋点等噪声请求不应撑爆存储）
+const MaxRequestsPerStep = 50
+
+// NetworkEntry 插件上报的单条请求元数据（来自 chrome.webRequest 或 HAR 条目），不含请求头与正文
+type NetworkEntry struct {
+	Method     string `json:"method"`
+	URL        string `json:"url"`
+	Status     int    `json:"status"`
+	Type       string `json:"type"` // xhr / fetch / document（兼容 webRequest 的 xmlhttprequest、main_frame、sub_frame）
+	MimeType   string `json:"mime_type"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// 只保留业务接口与页面请求，图片、样式、脚本等静态资源不入库
+var requestTypes = map[string]string{
+	"":               "",
+	"xhr":            "xhr",
+	"xmlhttprequest": "xhr",
+	"fetch":          "fetch",
+	"document":       "document",
+	"main_frame":     "document",
+	"sub_frame":      "document",
+}
+
+var requestMethods = map[string]bool{
+	"GET": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true, "HEAD": true, "OPTIONS": true,
+}
+
+// 参数名包含以下片段时视为敏感参数，值替换为 ***
+var sensitiveParams = []string{
+	"token", "password", "passwd", "pwd", "secret", "key", "auth", "session", "sign", "ticket", "credential", "code",
+}
+
+// SanitizeRequestURL 脱敏请求 URL：去掉用户名密码与锚点，敏感查询参数的值替换为 ***；
+// 仅接受 http / https
+func SanitizeRequestURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	u.User = nil
+	u.Fragment, u.RawFragment = "", ""
+	if u.RawQuery != "" {
+		q := u.Query()
+		for name := range q {
+			lower := strings.ToLower(name)
+			for _, s := range sensitiveParams {
+				if strings.Contains(lower, s) {
+					for i := range q[name] {
+						q[name][i] = "***"
+					}
+					break
+				}
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	return u.String(), nil
+}
+
+// SanitizeRequests 过滤并脱敏上报的请求：丢弃静态资源、非法方法与无法解析的 URL，
+// 超过 MaxRequestsPerStep 的部分截断
+func SanitizeRequests(entries []NetworkEntry) []db.StepRequest {
+	out := make([]db.StepRequest, 0, len(entries))
+	for _, e := range entries {
+		if len(out) >= MaxRequestsPerStep {
+			break
+		}
+		typ, ok := requestTypes[strings.ToLower(e.Type)]
+		method := strings.ToUpper(strings.TrimSpace(e.Method))
+		if method == "" {
+			method = "GET"
+		}
+		if !ok || !requestMethods[method] {
+			continue
+		}
+		u, err := SanitizeRequestURL(e.URL)
+		if err != nil {
+			continue
+		}
+		out = append(out, db.StepRequest{
+			Method: method, URL: u, Status: e.Status, ResourceType: typ,
+			MimeType: e.MimeType, DurationMS: e.DurationMS,
+		})
+	}
+	return out
+}
+
+// AttachStepRequests 将请求追加到步骤下（在步骤已有请求之后编号），返回写入的记录
+func AttachStepRequests(tx *gorm.DB, step *db.RecordingStep, reqs []db.StepRequest) ([]db.StepRequest, error) {
+	if len(reqs) == 0 {
+		return reqs, nil
+	}
+	var count int64
+	if err := tx.Model(&db.StepRequest{}).Where("step_id = ?", step.ID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	room := MaxRequestsPerStep - int(count)
+	if room <= 0 {
+		return []db.StepRequest{}, nil
+	}
+	if len(reqs) > room {
+		reqs = reqs[:room]
+	}
+	for i := range reqs {
+		reqs[i].StepID, reqs[i].SessionID, reqs[i].Seq = step.ID, step.SessionID, int(count)+i+1
+	}
+	if err := tx.Create(&reqs).Error; err != nil {
+		return nil, err
+	}
+	return reqs, nil
+}
+
+// RequestEndpoint 技术视图中的接口表述，如 “POST /api/cases → 201”；
+// 与页面同域时省略域名，查询参数不展示
+func RequestEndpoint(r db.StepRequest, pageURL string) string {
+	endpoint := r.URL
+	if u, err := url.Parse(r.URL); err == nil {
+		endpoint = u.Host + u.EscapedPath()
+		if p, err := url.Parse(pageURL); err == nil && p.Host == u.Host {
+			endpoint = u.EscapedPath()
+		}
+		if endpoint == "" {
+			endpoint = "/"
+		}
+	}
+	line := r.Method + " " + endpoint
+	if r.Status > 0 {
+		line += fmt.Sprintf(" → %d", r.Status)
+	}
+	return line
+}
+
+// stepEndpoints 按步骤汇总接口表述（同一步内重复的接口只列一次），用于技术视图
+func stepEndpoints(sessionID string, steps []db.RecordingStep) map[string][]string {
+	var reqs []db.StepRequest
+	db.DB.Where("session_id = ?", sessionID).Order("step_id, seq").Find(&reqs)
+	pages := make(map[string]string, len(steps))
+	for _, s := range steps {
+		pages[s.ID] = s.PageURL
+	}
+	out := map[string][]string{}
+	seen := map[string]bool{}
+	for _, r := range reqs {
+		line := RequestEndpoint(r, pages[r.StepID])
+		if key := r.StepID + "\x00" + line; !seen[key] {
+			seen[key] = true
+			out[r.StepID] = append(out[r.StepID], line)
+		}
+	}
+	return out
+}
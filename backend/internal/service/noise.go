@@ -0,0 +1,171 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// 疑似噪声步骤的原因
+const (
+	NoiseScroll     = "scroll"     // 滚动（目标位置已由后续步骤的滚动提示说明）
+	NoiseHover      = "hover"      // 只悬停，随后没有在同一页面点击
+	NoiseBackground = "background" // 点击页面空白处（body / html）
+	NoiseUndone     = "undone"     // 随即被撤销的操作及撤销动作本身
+)
+
+// noiseUndoWindowMS 操作与撤销它的动作之间的最大间隔（毫秒）
+const noiseUndoWindowMS = 5000
+
+// NoiseStep 疑似噪声的步骤
+type NoiseStep struct {
+	StepID    string `json:"step_id"`
+	StepIndex int    `json:"step_index"`
+	Action    string `json:"action"`
+	Target    string `json:"target,omitempty"`
+	Reason    string `json:"reason"`
+	Excluded  bool   `json:"excluded"` // 已排除出业务视图
+}
+
+// DetectNoiseSteps 扫描会话并返回疑似噪声的步骤（只预览，不修改）；疑似重复提交的步骤由重复检测处理，不在此列出
+func DetectNoiseSteps(tx *gorm.DB, sessionID string) ([]NoiseStep, error) {
+	var steps []db.RecordingStep
+	if err := tx.Where("session_id = ? AND duplicate_of = ''", sessionID).Order("step_index").Find(&steps).Error; err != nil {
+		return nil, err
+	}
+	return FindNoiseSteps(steps), nil
+}
+
+// FindNoiseSteps 按启发式规则找出按顺序排列的步骤中疑似噪声的步骤
+func FindNoiseSteps(steps []db.RecordingStep) []NoiseStep {
+	reasons := make([]string, len(steps))
+	flag := func(i int, reason string) {
+		if reasons[i] == "" {
+			reasons[i] = reason
+		}
+	}
+	for i := range steps {
+		s := &steps[i]
+		switch {
+		case s.Action == "scroll":
+			flag(i, NoiseScroll)
+		case s.Action == "hover" && !followedByClick(steps, i):
+			flag(i, NoiseHover)
+		case s.Action == "click" && isBackgroundTarget(s):
+			flag(i, NoiseBackground)
+		}
+		if i > 0 && undoes(&steps[i-1], s, steps[:i-1]) {
+			flag(i-1, NoiseUndone)
+			flag(i, NoiseUndone)
+		}
+	}
+
+	out := []NoiseStep{}
+	for i, reason := range reasons {
+		if reason == "" {
+			continue
+		}
+		s := steps[i]
+		out = append(out, NoiseStep{
+			StepID: s.ID, StepIndex: s.StepIndex, Action: s.Action, Target: s.TargetElement,
+			Reason: reason, Excluded: s.Excluded,
+		})
+	}
+	return out
+}
+
+// followedByClick 悬停后紧接着在同一页面点击或选择（如展开菜单后点击菜单项）；点击空白处不算
+func followedByClick(steps []db.RecordingStep, i int) bool {
+	if i+1 >= len(steps) {
+		return false
+	}
+	next := steps[i+1]
+	if next.Action == "click" && isBackgroundTarget(&next) {
+		return false
+	}
+	return (next.Action == "click" || next.Action == "select") && next.PageURL == steps[i].PageURL
+}
+
+// isBackgroundTarget 目标为页面本身（body / html）而非具体控件
+func isBackgroundTarget(s *db.RecordingStep) bool {
+	selector := strings.ToLower(strings.TrimSpace(s.TargetSelector))
+	xpath := strings.ToLower(strings.TrimRight(strings.TrimSpace(s.TargetXPath), "/"))
+	element := strings.ToLower(strings.TrimSpace(s.TargetElement))
+	switch {
+	case selector == "body" || selector == "html" || strings.HasSuffix(selector, "> body"):
+		return true
+	case xpath == "/html" || xpath == "/html/body" || xpath == "/html[1]/body[1]":
+		return true
+	case selector == "" && xpath == "" && (element == "body" || element == "html"):
+		return true
+	}
+	return false
+}
+
+// isToggleTarget 复选框、单选框、开关等点击两次即复原的控件
+func isToggleTarget(s *db.RecordingStep) bool {
+	text := strings.ToLower(s.TargetSelector + " " + s.TargetElement)
+	for _, kw := range []string{"checkbox", "radio", "switch", "toggle", "复选框", "单选", "开关"} {
+		if strings.Contains(text, kw) {
+			return true
+		}
+	}
+	return false
+}
+
+// undoes 判断 next 是否随即撤销了 prev：Ctrl/Cmd+Z、清空刚输入的内容、再次点击同一开关、导航后立即返回原页面；
+// before 为 prev 之前的步骤
+func undoes(prev, next *db.RecordingStep, before []db.RecordingStep) bool {
+	if prev.Timestamp != 0 && next.Timestamp != 0 && next.Timestamp-prev.Timestamp > noiseUndoWindowMS {
+		return false
+	}
+	sameTarget := prev.TargetSelector != "" && prev.TargetSelector == next.TargetSelector
+	switch {
+	case next.Action == ActionShortcut && (next.KeyCombo == "Ctrl+Z" || next.KeyCombo == "Meta+Z"):
+		return prev.Action != ActionShortcut
+	case next.Action == "input" && prev.Action == "input":
+		return sameTarget && next.MaskedText == "" && next.InputValue == ""
+	case next.Action == "click" && prev.Action == "click":
+		return sameTarget && isToggleTarget(next)
+	case next.Action == "navigation" && prev.Action == "navigation":
+		return len(before) > 0 && next.PageURL != "" && next.PageURL == before[len(before)-1].PageURL
+	}
+	return false
+}
+
+// ApplyNoiseFilter 处理疑似噪声的步骤：默认排除出业务视图（可通过编辑步骤恢复），remove 为 true 时删除并重新编号；
+// stepIDs 非空时只处理其中的疑似噪声步骤。返回被处理的步骤（需在事务中调用）
+func ApplyNoiseFilter(tx *gorm.DB, sessionID string, stepIDs []string, remove bool) ([]NoiseStep, error) {
+	candidates, err := DetectNoiseSteps(tx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	selected := map[string]bool{}
+	for _, id := range stepIDs {
+		selected[id] = true
+	}
+	applied := []NoiseStep{}
+	var ids []string
+	for _, n := range candidates {
+		if len(stepIDs) > 0 && !selected[n.StepID] {
+			continue
+		}
+		if n.Excluded && !remove {
+			continue
+		}
+		applied = append(applied, n)
+		ids = append(ids, n.StepID)
+	}
+	if len(ids) == 0 {
+		return applied, nil
+	}
+	if remove {
+		_, err = RemoveSteps(tx, sessionID, ids)
+		return applied, err
+	}
+	for i := range applied {
+		applied[i].Excluded = true
+	}
+	return applied, tx.Model(&db.RecordingStep{}).Where("id IN ?", ids).Update("excluded", true).Error
+}
@@ -0,0 +1,77 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestFindNoiseSteps(t *testing.T) {
+	steps := []db.RecordingStep{
+		{Action: "navigation", PageURL: "/home", Timestamp: 1000},
+		{Action: "scroll", PageURL: "/home", Timestamp: 2000},
+		{Action: "hover", PageURL: "/home", TargetSelector: "#menu", Timestamp: 3000},
+		{Action: "click", PageURL: "/home", TargetSelector: "#menu-item", Timestamp: 3500},
+		{Action: "hover", PageURL: "/home", TargetSelector: "#help", Timestamp: 4000},
+		{Action: "click", PageURL: "/home", TargetSelector: "body", Timestamp: 5000},
+		{Action: "input", PageURL: "/home", TargetSelector: "#name", MaskedText: "张三", Timestamp: 6000},
+		{Action: "input", PageURL: "/home", TargetSelector: "#name", Timestamp: 7000},
+		{Action: "click", PageURL: "/home", TargetSelector: "input[type=checkbox]#agree", Timestamp: 8000},
+		{Action: "click", PageURL: "/home", TargetSelector: "input[type=checkbox]#agree", Timestamp: 8500},
+		{Action: "click", PageURL: "/home", TargetSelector: "#delete", Timestamp: 9000},
+		{Action: service.ActionShortcut, KeyCombo: "Ctrl+Z", PageURL: "/home", Timestamp: 9500},
+		{Action: "navigation", PageURL: "/help", Timestamp: 10000},
+		{Action: "navigation", PageURL: "/home", Timestamp: 11000},
+		{Action: "click", PageURL: "/home", TargetSelector: "#submit", Timestamp: 20000},
+		// 间隔过长，不视为撤销
+		{Action: "click", PageURL: "/home", TargetSelector: "input[type=checkbox]#agree", Timestamp: 30000},
+	}
+	for i := range steps {
+		steps[i].ID, steps[i].StepIndex = string(rune('a'+i)), i+1
+	}
+
+	want := map[int]string{
+		2: service.NoiseScroll, 5: service.NoiseHover, 6: service.NoiseBackground,
+		7: service.NoiseUndone, 8: service.NoiseUndone, 9: service.NoiseUndone, 10: service.NoiseUndone,
+		11: service.NoiseUndone, 12: service.NoiseUndone, 13: service.NoiseUndone, 14: service.NoiseUndone,
+	}
+	got := service.FindNoiseSteps(steps)
+	if len(got) != len(want) {
+		t.Fatalf("expected %d noise steps, got %+v", len(want), got)
+	}
+	for _, n := range got {
+		if want[n.StepIndex] != n.Reason {
+			t.Errorf("step %d: reason %q, want %q", n.StepIndex, n.Reason, want[n.StepIndex])
+		}
+	}
+}
+
+func TestApplyNoiseFilter(t *testing.T) {
+	setupDB(t)
+	sess := db.Session{Title: "噪声"}
+	db.DB.Create(&sess)
+	for i, action := range []string{"navigation", "scroll", "click", "scroll"} {
+		db.DB.Create(&db.RecordingStep{SessionID: sess.ID, StepIndex: i + 1, Action: action, PageURL: "/home", TargetSelector: "#ok"})
+	}
+
+	// 默认只排除出业务视图；已排除的步骤仍在预览中列出但不再重复处理
+	applied, err := service.ApplyNoiseFilter(db.DB, sess.ID, nil, false)
+	if err != nil || len(applied) != 2 || !applied[0].Excluded {
+		t.Fatalf("unexpected exclude result %+v, %v", applied, err)
+	}
+	if again, _ := service.ApplyNoiseFilter(db.DB, sess.ID, nil, false); len(again) != 0 {
+		t.Errorf("already excluded steps should be skipped: %+v", again)
+	}
+
+	// 只删除选中的步骤，其余步骤重新编号
+	applied, err = service.ApplyNoiseFilter(db.DB, sess.ID, []string{applied[0].StepID}, true)
+	if err != nil || len(applied) != 1 {
+		t.Fatalf("unexpected remove result %+v, %v", applied, err)
+	}
+	var steps []db.RecordingStep
+	db.DB.Where("session_id = ?", sess.ID).Order("step_index").Find(&steps)
+	if len(steps) != 3 || steps[1].Action != "click" || steps[2].StepIndex != 3 || !steps[2].Excluded {
+		t.Errorf("unexpected steps after removal %+v", steps)
+	}
+}
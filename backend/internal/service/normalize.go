@@ -0,0 +1,127 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxDescriptionRunes 步骤描述的最大长度（字符），超出时在句末截断
+const maxDescriptionRunes = 120
+
+// outputRule 输出后处理规则
+type outputRule func(text string, req VLMRequest) string
+
+// 后处理流水线：与回答的提供商无关，规则描述同样经过；
+// 纯文本任务（标题、概述、常见问题）保留多行结构，只做开场白清理与标点规范
+var (
+	textRules = []outputRule{stripBoilerplate, normalizePunctuation}
+	stepRules = []outputRule{stripBoilerplate, firstLine, normalizePunctuation, enforceStepPrefix, trimDescription}
+)
+
+// NormalizeOutput 对模型输出执行后处理流水线
+func NormalizeOutput(text string, req VLMRequest) string {
+	rules := stepRules
+	if req.Prompt != "" {
+		rules = textRules
+	}
+	for _, rule := range rules {
+		text = rule(text, req)
+	}
+	return text
+}
+
+// boilerplateRes 模型常见的开场白、标签和格式残留
+var boilerplateRes = []*regexp.Regexp{
+	regexp.MustCompile(`^(好的|当然|没问题)[，,。.!！～~]+\s*`),
+	regexp.MustCompile(`^(以下是|下面是|这是)[^\n：:]*[：:]\s*`),
+	regexp.MustCompile(`^(根据|从)(截图|图片|您提供的|你提供的|提供的)[^\n，,：:]*[，,：:]\s*`),
+	regexp.MustCompile(`^(步骤描述|描述|输出|回答)\s*[：:]\s*`),
+}
+
+// stripBoilerplate 去掉开场白、Markdown 强调符号和包裹全文的引号
+func stripBoilerplate(text string, _ VLMRequest) string {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "**", ""))
+	for changed := true; changed; {
+		changed = false
+		for _, re := range boilerplateRes {
+			if loc := re.FindStringIndex(text); loc != nil && loc[1] > 0 {
+				text, changed = strings.TrimSpace(text[loc[1]:]), true
+			}
+		}
+	}
+	for _, q := range [][2]string{{"“", "”"}, {`"`, `"`}, {"「", "」"}, {"`", "`"}} {
+		if len(text) > len(q[0])+len(q[1]) && strings.HasPrefix(text, q[0]) && strings.HasSuffix(text, q[1]) &&
+			!strings.Contains(text[len(q[0]):len(text)-len(q[1])], q[1]) {
+			text = strings.TrimSpace(text[len(q[0]) : len(text)-len(q[1])])
+		}
+	}
+	return text
+}
+
+// firstLine 步骤描述只保留第一行非空内容（模型偶尔附带解释）
+func firstLine(text string, _ VLMRequest) string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(strings.TrimLeft(line, "-*# ")); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// cjkPunct 紧邻中文时替换为全角的半角标点
+var cjkPunct = map[rune]rune{',': '，', ':': '：', ';': '；', '!': '！', '?': '？', '(': '（', ')': '）'}
+
+// normalizePunctuation 全角字母数字转半角，中文语境下的半角标点转全角，合并重复的句读
+func normalizePunctuation(text string, _ VLMRequest) string {
+	runes := []rune(text)
+	for i, r := range runes {
+		if r >= '！' && r <= '～' && (unicode.IsLetter(r-0xFEE0) || unicode.IsDigit(r-0xFEE0)) {
+			runes[i] = r - 0xFEE0
+		}
+	}
+	isHan := func(i int) bool { return i >= 0 && i < len(runes) && unicode.Is(unicode.Han, runes[i]) }
+	var sb strings.Builder
+	for i, r := range runes {
+		if full, ok := cjkPunct[r]; ok && (isHan(i-1) || isHan(i+1)) {
+			r = full
+		} else if r == '.' && isHan(i-1) && (i == len(runes)-1 || runes[i+1] == ' ' || runes[i+1] == '\n') {
+			r = '。'
+		}
+		sb.WriteRune(r)
+	}
+	out := sb.String()
+	for _, p := range []string{"。", "，", "！", "？"} {
+		for strings.Contains(out, p+p) {
+			out = strings.ReplaceAll(out, p+p, p)
+		}
+	}
+	return out
+}
+
+// enforceStepPrefix 描述以「第N步：」开头且序号与实际一致；序号未知时不处理
+func enforceStepPrefix(text string, req VLMRequest) string {
+	if req.StepIndex <= 0 || text == "" {
+		return text
+	}
+	if loc := stepNumberRe.FindStringIndex(text); loc != nil {
+		text = strings.TrimLeft(text[loc[1]:], "：: ，,")
+	}
+	return fmt.Sprintf("第%d步：%s", req.StepIndex, text)
+}
+
+// trimDescription 超长描述在最后一个句读处截断，找不到时硬截断并加省略号
+func trimDescription(text string, _ VLMRequest) string {
+	if utf8.RuneCountInString(text) <= maxDescriptionRunes {
+		return text
+	}
+	runes := []rune(text)[:maxDescriptionRunes]
+	for i := len(runes) - 1; i >= maxDescriptionRunes/2; i-- {
+		if strings.ContainsRune("。！？；", runes[i]) {
+			return string(runes[:i+1])
+		}
+	}
+	return string(runes[:maxDescriptionRunes-1]) + "…"
+}
@@ -0,0 +1,59 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestNormalizeOutput(t *testing.T) {
+	step := service.VLMRequest{StepIndex: 3}
+	cases := []struct {
+		name string
+		in   string
+		req  service.VLMRequest
+		want string
+	}{
+		{"boilerplate", "好的，以下是步骤描述：\n**点击「提交」按钮**", step, "第3步：点击「提交」按钮"},
+		{"screenshot lead-in", "根据截图，用户在登录页输入用户名", step, "第3步：用户在登录页输入用户名"},
+		{"wrong prefix", "第 7 步: 打开订单列表", step, "第3步：打开订单列表"},
+		{"quoted", "“第3步：打开设置页”", step, "第3步：打开设置页"},
+		{"extra lines", "点击保存。\n\n说明：这一步会保存表单。", step, "第3步：点击保存。"},
+		{"width and punctuation", "输入ＡＢＣ１２３,然后点击确定.", step, "第3步：输入ABC123，然后点击确定。"},
+		{"doubled punctuation", "点击确定。。", step, "第3步：点击确定。"},
+		{"unknown index", "好的。点击确定", service.VLMRequest{}, "点击确定"},
+		{"text keeps lines", "以下是常见问题：\n1. 如何登录?\n2. 如何退出?", service.VLMRequest{Prompt: "p"}, "1. 如何登录？\n2. 如何退出？"},
+	}
+	for _, c := range cases {
+		if got := service.NormalizeOutput(c.in, c.req); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestNormalizeOutput_TrimsLongDescriptions(t *testing.T) {
+	sentence := "在弹出的对话框中核对订单金额与收货地址。"
+	got := service.NormalizeOutput(strings.Repeat(sentence, 10), service.VLMRequest{StepIndex: 1})
+	if n := utf8.RuneCountInString(got); n > 120 || !strings.HasSuffix(got, "。") {
+		t.Errorf("expected cut at a sentence end within 120 runes, got %d: %q", n, got)
+	}
+
+	got = service.NormalizeOutput(strings.Repeat("很", 200), service.VLMRequest{StepIndex: 1})
+	if n := utf8.RuneCountInString(got); n != 120 || !strings.HasSuffix(got, "…") {
+		t.Errorf("expected hard cut with ellipsis, got %d: %q", n, got)
+	}
+}
+
+func TestGenerateStepDescription_Normalized(t *testing.T) {
+	setupDB(t)
+	aiSvc := fakeOllama(t, func(prompt string) string { return "当然！以下是描述：\n第1步: 点击登录按钮" })
+	resp, err := aiSvc.GenerateStepDescription(service.VLMRequest{StepIndex: 2, StepAction: "click", PageTitle: "登录"})
+	if err != nil {
+		t.Fatalf("GenerateStepDescription: %v", err)
+	}
+	if resp.Description != "第2步：点击登录按钮" {
+		t.Errorf("unexpected description %q", resp.Description)
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 导出编号样式
+const (
+	NumberingStep         = "step"         // 第 N 步（默认）
+	NumberingHierarchical = "hierarchical" // 章节 1、2…，步骤 1.1、1.2…（章节内重新计数）
+	NumberingEnglish      = "english"      // Step N
+)
+
+// NumberingStyles 支持的编号样式
+var NumberingStyles = []string{NumberingStep, NumberingHierarchical, NumberingEnglish}
+
+// docNumbering 按编号样式为章节和步骤生成标题
+type docNumbering struct {
+	style   string
+	section int // 当前步骤章节序号（概述、常见问题等特殊章节不编号）
+	step    int // 当前章节内的步骤序号
+}
+
+func newDocNumbering(style string) *docNumbering {
+	return &docNumbering{style: style}
+}
+
+// Section 章节标题；进入新章节时重置章节内步骤序号
+func (n *docNumbering) Section(sec DocSection) string {
+	n.step = 0
+	if sec.Kind != "" || n.style != NumberingHierarchical {
+		return sec.Title
+	}
+	n.section++
+	return fmt.Sprintf("%d %s", n.section, sec.Title)
+}
+
+// Step 步骤标题，有短标题时附在序号之后（目录据此列出各步骤）；step 与 english 样式使用步骤的全局序号
+func (n *docNumbering) Step(st DocStep) string {
+	n.step++
+	var label string
+	switch n.style {
+	case NumberingHierarchical:
+		label = fmt.Sprintf("%d.%d", max(n.section, 1), n.step)
+	case NumberingEnglish:
+		label = fmt.Sprintf("Step %d", st.StepIndex)
+	default:
+		label = fmt.Sprintf("第 %d 步", st.StepIndex)
+	}
+	if st.Title != "" {
+		label += " " + st.Title
+	}
+	return label
+}
+
+// headingMarks 标题级别对应的 Markdown 标记：base 为文档标题级别，depth 为相对下沉层数，最深 6 级
+func headingMarks(base, depth int) string {
+	if base < 1 {
+		base = 1
+	}
+	return strings.Repeat("#", min(base+depth, 6))
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gpilot/backend/internal/config"
+	"github.com/gpilot/backend/internal/db"
+)
+
+// ErrOCRDisabled 未配置文字识别（ocr.command）
+var ErrOCRDisabled = errors.New("ocr is not configured")
+
+// OCREngine 截图文字识别引擎
+type OCREngine interface {
+	Recognize(ctx context.Context, image []byte) (string, error)
+}
+
+// TesseractEngine 调用 tesseract 命令行识别，图片经标准输入传入
+type TesseractEngine struct {
+	Command   string
+	Languages string
+}
+
+// Recognize 实现 OCREngine
+func (e TesseractEngine) Recognize(ctx context.Context, image []byte) (string, error) {
+	cmd := exec.CommandContext(ctx, e.Command, "stdin", "stdout", "-l", e.Languages)
+	cmd.Stdin = bytes.NewReader(image)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("tesseract: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// maxOCRTextRunes 单张截图保存的识别文字上限（字符）
+const maxOCRTextRunes = 4000
+
+// ocrSlots 同时进行的识别数上限，避免批量上传时启动过多 tesseract 进程
+var ocrSlots = make(chan struct{}, 2)
+
+var ocrState struct {
+	sync.RWMutex
+	engine  OCREngine
+	timeout time.Duration
+}
+
+// ConfigureOCR 按配置启用 tesseract 识别，未配置命令时关闭
+func ConfigureOCR(cfg config.OCRConfig) {
+	if cfg.Command == "" {
+		SetOCREngine(nil, 0)
+		return
+	}
+	SetOCREngine(TesseractEngine{Command: cfg.Command, Languages: cfg.Languages}, cfg.Timeout)
+}
+
+// SetOCREngine 替换识别引擎，nil 关闭识别
+func SetOCREngine(engine OCREngine, timeout time.Duration) {
+	ocrState.Lock()
+	defer ocrState.Unlock()
+	ocrState.engine, ocrState.timeout = engine, timeout
+}
+
+// OCREnabled 是否已启用文字识别
+func OCREnabled() bool {
+	ocrState.RLock()
+	defer ocrState.RUnlock()
+	return ocrState.engine != nil
+}
+
+// ExtractScreenshotText 识别截图文字并保存到 Screenshot.OCRText，返回保存的文字。
+// 识别的是烧录遮蔽区域后的图片，被遮蔽的内容不会进入识别结果
+func ExtractScreenshotText(screenshotID string) (string, error) {
+	ocrState.RLock()
+	engine, timeout := ocrState.engine, ocrState.timeout
+	ocrState.RUnlock()
+	if engine == nil {
+		return "", ErrOCRDisabled
+	}
+	var shot db.Screenshot
+	if err := db.DB.First(&shot, "id = ?", screenshotID).Error; err != nil {
+		return "", err
+	}
+	var text string
+	if shot.DataURL != "" {
+		_, image, err := ParseDataURL(RedactedScreenshot(&shot, RedactBlack))
+		if err != nil {
+			return "", fmt.Errorf("screenshot %s: %w", screenshotID, err)
+		}
+		ocrSlots <- struct{}{}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		raw, err := engine.Recognize(ctx, image)
+		cancel()
+		<-ocrSlots
+		if err != nil {
+			return "", err
+		}
+		text = cleanOCRText(raw)
+	}
+	err := db.DB.Model(&db.Screenshot{}).Where("id = ?", screenshotID).Update("ocr_text", text).Error
+	return text, err
+}
+
+// QueueOCR 后台识别截图文字（未启用识别时忽略），失败只记录日志
+func QueueOCR(screenshotID string) {
+	if screenshotID == "" || !OCREnabled() {
+		return
+	}
+	go func() {
+		if _, err := ExtractScreenshotText(screenshotID); err != nil {
+			log.Printf("⚠️ ocr %s: %v", screenshotID, err)
+		}
+	}()
+}
+
+// cleanOCRText 去掉空行和中文字符之间多余的空格（tesseract 逐字输出中文时常见），并截断到上限
+func cleanOCRText(raw string) string {
+	var lines []string
+	for _, line := range strings.Split(raw, "\n") {
+		runes := []rune(strings.TrimSpace(line))
+		var sb strings.Builder
+		for i, r := range runes {
+			if r == ' ' && i > 0 && i < len(runes)-1 &&
+				unicode.Is(unicode.Han, runes[i-1]) && unicode.Is(unicode.Han, runes[i+1]) {
+				continue
+			}
+			sb.WriteRune(r)
+		}
+		if s := sb.String(); s != "" {
+			lines = append(lines, s)
+		}
+	}
+	text := strings.Join(lines, "\n")
+	if utf8.RuneCountInString(text) > maxOCRTextRunes {
+		text = string([]rune(text)[:maxOCRTextRunes])
+	}
+	return text
+}
+
+// screenTextHint 提示词中附带的屏幕文字（截断，换行合并）
+func screenTextHint(text string) string {
+	if text == "" {
+		return ""
+	}
+	text = strings.ReplaceAll(text, "\n", " / ")
+	if utf8.RuneCountInString(text) > 300 {
+		text = string([]rune(text)[:300]) + "…"
+	}
+	return "截图中识别出的文字（供参考，可能有误）：" + text + "\n"
+}
+
+// ScreenTextHit 屏幕文字检索结果
+type ScreenTextHit struct {
+	SessionID    string `json:"session_id"`
+	SessionTitle string `json:"session_title"`
+	StepID       string `json:"step_id"`
+	StepIndex    int    `json:"step_index"`
+	ScreenshotID string `json:"screenshot_id"`
+	Snippet      string `json:"snippet"` // 命中处前后的文字
+}
+
+// likeEscaper 转义 LIKE 通配符（以 ! 为转义字符，各数据库通用）
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// SearchScreenText 按屏幕文字检索步骤（如“哪一步出现了缴费按钮”），可按项目或会话限定范围
+func SearchScreenText(query, projectID, sessionID string, limit int) ([]ScreenTextHit, error) {
+	var rows []struct {
+		ScreenTextHit
+		OCRText string `gorm:"column:ocr_text"`
+	}
+	q := db.DB.Table("screenshots").
+		Select("sessions.id AS session_id, sessions.title AS session_title, recording_steps.id AS step_id, "+
+			"recording_steps.step_index, screenshots.id AS screenshot_id, screenshots.ocr_text").
+		Joins("JOIN recording_steps ON recording_steps.id = screenshots.step_id").
+		Joins("JOIN sessions ON sessions.id = screenshots.session_id").
+		Where("screenshots.ocr_text LIKE ? ESCAPE '!'", "%"+likeEscaper.Replace(query)+"%")
+	if projectID != "" {
+		q = q.Where("sessions.project_id = ?", projectID)
+	}
+	if sessionID != "" {
+		q = q.Where("sessions.id = ?", sessionID)
+	}
+	if err := q.Order("sessions.created_at DESC, recording_steps.step_index").Limit(limit).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	hits := make([]ScreenTextHit, len(rows))
+	for i, r := range rows {
+		hits[i] = r.ScreenTextHit
+		hits[i].Snippet = snippetAround(r.OCRText, query, 20)
+	}
+	return hits, nil
+}
+
+// snippetAround 截取 query 首次出现处前后各 width 个字符
+func snippetAround(text, query string, width int) string {
+	i := strings.Index(text, query)
+	if i < 0 {
+		return ""
+	}
+	before := []rune(text[:i])
+	after := []rune(text[i+len(query):])
+	start, end := max(0, len(before)-width), min(len(after), width)
+	snippet := string(before[start:]) + query + string(after[:end])
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(after) {
+		snippet += "…"
+	}
+	return strings.ReplaceAll(snippet, "\n", " ")
+}
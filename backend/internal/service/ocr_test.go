@@ -0,0 +1,76 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+type fakeOCR func(image []byte) (string, error)
+
+func (f fakeOCR) Recognize(_ context.Context, image []byte) (string, error) { return f(image) }
+
+func TestScreenshotOCR(t *testing.T) {
+	setupDB(t)
+	projectID, sessionID := seedSessionWithSteps(t, 3)
+	var steps []db.RecordingStep
+	db.DB.Where("session_id = ?", sessionID).Order("step_index").Find(&steps)
+	if err := service.AttachScreenshot(db.DB, &steps[1], &db.Screenshot{DataURL: db.LongText(pngDataURL(t, 40, 20))}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := service.ExtractScreenshotText(steps[1].ScreenshotID); !errors.Is(err, service.ErrOCRDisabled) {
+		t.Fatalf("expected ErrOCRDisabled without an engine, got %v", err)
+	}
+	service.SetOCREngine(fakeOCR(func(image []byte) (string, error) {
+		if len(image) == 0 {
+			return "", errors.New("empty image")
+		}
+		return "  个人中心 \n\n请 点 击 缴 费 按钮\nPay 100%", nil
+	}), time.Second)
+	t.Cleanup(func() { service.SetOCREngine(nil, 0) })
+
+	text, err := service.ExtractScreenshotText(steps[1].ScreenshotID)
+	if err != nil || text != "个人中心\n请点击缴费按钮\nPay 100%" {
+		t.Fatalf("unexpected OCR text %q: %v", text, err)
+	}
+
+	hits, err := service.SearchScreenText("缴费", projectID, "", 10)
+	if err != nil || len(hits) != 1 {
+		t.Fatalf("expected one hit, got %+v %v", hits, err)
+	}
+	if hits[0].StepIndex != 2 || hits[0].SessionTitle != "测试录制会话" || !strings.Contains(hits[0].Snippet, "请点击缴费按钮") {
+		t.Errorf("unexpected hit %+v", hits[0])
+	}
+	if hits, _ = service.SearchScreenText("缴费", "other-project", "", 10); len(hits) != 0 {
+		t.Errorf("project filter should apply, got %+v", hits)
+	}
+	if hits, _ = service.SearchScreenText("0%", "", sessionID, 10); len(hits) != 1 {
+		t.Errorf("literal %% should match, got %+v", hits)
+	}
+	if hits, _ = service.SearchScreenText("%", "", sessionID, 10); len(hits) != 1 {
+		t.Errorf("wildcards should be escaped, got %+v", hits)
+	}
+	if hits, _ = service.SearchScreenText("_", "", sessionID, 10); len(hits) != 0 {
+		t.Errorf("wildcards should be escaped, got %+v", hits)
+	}
+
+	// 识别文字作为辅助信息写入步骤描述提示词
+	req := service.StepVLMRequest(&steps[1])
+	if req.ScreenText != text {
+		t.Fatalf("expected screen text on the VLM request, got %q", req.ScreenText)
+	}
+	var prompt string
+	aiSvc := fakeOllama(t, func(p string) string { prompt = p; return "点击缴费按钮" })
+	if _, err := aiSvc.GenerateStepDescription(req); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(prompt, "截图中识别出的文字") || !strings.Contains(prompt, "个人中心 / 请点击缴费按钮") {
+		t.Errorf("prompt should carry the screen text:\n%s", prompt)
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// 步骤描述格式校验未通过的原因
+const (
+	ProblemEmpty             = "empty"              // 输出为空
+	ProblemMultipleSentences = "multiple_sentences" // 不止一句
+	ProblemMissingAction     = "missing_action"     // 没有写出操作动作
+	ProblemMissingTarget     = "missing_target"     // 没有写出操作对象
+)
+
+// problemHints 重问时的纠正说明
+var problemHints = map[string]string{
+	ProblemEmpty:             "输出为空",
+	ProblemMultipleSentences: "只能写一句话",
+	ProblemMissingAction:     "必须写明操作动作",
+	ProblemMissingTarget:     "必须写明操作的目标元素",
+}
+
+// actionVerbs 各操作类型可接受的动词；未列出的类型不检查动作
+var actionVerbs = map[string][]string{
+	"click":      {"点击", "单击", "双击", "点选", "选择", "勾选", "打开", "进入", "切换", "展开", "提交", "确认"},
+	"input":      {"输入", "填写", "填入", "录入", "键入"},
+	"select":     {"选择", "选中", "勾选", "切换"},
+	"drag":       {"拖拽", "拖动", "拖至", "拖到"},
+	"navigation": {"导航", "打开", "进入", "访问", "跳转", "前往", "返回"},
+	"scroll":     {"滚动", "滑动", "下拉", "翻页"},
+	"hover":      {"悬停", "移到", "移至", "指向"},
+}
+
+// targetSplitRe 目标元素文本的分词分隔符
+var targetSplitRe = regexp.MustCompile(`[\s,，。:：;；/|()（）\[\]【】「」“”"'<>]+`)
+
+// quotedRe 引号或括号标出的控件名，视为已写明目标
+var quotedRe = regexp.MustCompile(`「[^」]+」|“[^”]+”|【[^】]+】|\[[^\]]+\]`)
+
+// ValidateDescription 校验步骤描述：单句、包含动作和目标；纯文本任务不校验，返回未通过的校验项
+func ValidateDescription(desc string, req VLMRequest) []string {
+	if req.Prompt != "" {
+		return nil
+	}
+	body := strings.TrimSpace(stepNumberRe.ReplaceAllString(desc, ""))
+	body = strings.TrimLeft(body, "：: ")
+	if body == "" {
+		return []string{ProblemEmpty}
+	}
+	var problems []string
+	if strings.ContainsAny(strings.TrimRight(body, "。！？.!?"), "。！？\n") {
+		problems = append(problems, ProblemMultipleSentences)
+	}
+	if !hasAction(body, req) {
+		problems = append(problems, ProblemMissingAction)
+	}
+	if !hasTarget(body, req) {
+		problems = append(problems, ProblemMissingTarget)
+	}
+	return problems
+}
+
+// hasAction 描述中是否出现与操作类型相符的动词；键盘操作写出按键即可
+func hasAction(body string, req VLMRequest) bool {
+	if IsKeyAction(req.StepAction) {
+		return strings.Contains(body, "按") || (req.KeyCombo != "" && strings.Contains(strings.ToLower(body), strings.ToLower(req.KeyCombo)))
+	}
+	verbs, ok := actionVerbs[req.StepAction]
+	if !ok {
+		return true
+	}
+	for _, v := range verbs {
+		if strings.Contains(body, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTarget 描述中是否提到操作对象：目标元素、相关文本或页面标题中的任一词（至少两个字符），
+// 或用引号、括号标出了控件名；三者都为空时不检查
+func hasTarget(body string, req VLMRequest) bool {
+	if req.TargetElement == "" && req.MaskedText == "" && req.PageTitle == "" {
+		return true
+	}
+	if quotedRe.MatchString(body) {
+		return true
+	}
+	lower := strings.ToLower(body)
+	for _, src := range []string{req.TargetElement, req.MaskedText, req.PageTitle} {
+		for _, word := range targetSplitRe.Split(src, -1) {
+			if utf8.RuneCountInString(word) >= 2 && strings.Contains(lower, strings.ToLower(word)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// correctionHint 重问时附加的纠正说明
+func correctionHint(problems []string) string {
+	if len(problems) == 0 {
+		return ""
+	}
+	hints := make([]string, len(problems))
+	for i, p := range problems {
+		hints[i] = problemHints[p]
+	}
+	return fmt.Sprintf("上一次的输出不符合格式要求（%s），请按格式重新输出一句话。\n", strings.Join(hints, "、"))
+}
+
+// recordValidationFailure 记录一次格式校验失败及纠正重问是否成功
+func recordValidationFailure(p providerEntry, req VLMRequest, output string, problems []string, recovered bool) {
+	f := db.OutputValidationFailure{
+		Provider:  p.name,
+		Label:     p.label,
+		Model:     p.model,
+		StepIndex: req.StepIndex,
+		SessionID: req.SessionID,
+		StepID:    req.StepID,
+		Problems:  problems,
+		Output:    output,
+		Recovered: recovered,
+	}
+	if p.row != nil {
+		f.ProviderID = p.row.ID
+	}
+	db.DB.Create(&f)
+}
+
+// ValidationFailures 最近的格式校验失败记录，provider 非空时只看该类型
+func ValidationFailures(provider string, limit int) []db.OutputValidationFailure {
+	q := db.DB.Order("created_at DESC").Limit(limit)
+	if provider != "" {
+		q = q.Where("provider = ?", provider)
+	}
+	var out []db.OutputValidationFailure
+	q.Find(&out)
+	return out
+}
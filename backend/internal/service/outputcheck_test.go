@@ -0,0 +1,74 @@
+package service_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestValidateDescription(t *testing.T) {
+	click := service.VLMRequest{StepAction: "click", TargetElement: "提交 按钮", PageTitle: "申请表"}
+	cases := []struct {
+		desc string
+		req  service.VLMRequest
+		want []string
+	}{
+		{"第1步：点击提交按钮，完成申请。", click, nil},
+		{"第1步：点击「确定」", click, nil},
+		{"第1步：", click, []string{service.ProblemEmpty}},
+		{"第1步：点击提交按钮。随后等待审核。", click, []string{service.ProblemMultipleSentences}},
+		{"第1步：查看按钮", click, []string{service.ProblemMissingAction}},
+		{"第1步：点击这里", click, []string{service.ProblemMissingTarget}},
+		{"第1步：完成操作", click, []string{service.ProblemMissingAction, service.ProblemMissingTarget}},
+		{"第2步：按下 Ctrl+S 保存", service.VLMRequest{StepAction: "shortcut", KeyCombo: "Ctrl+S"}, nil},
+		{"随便写点什么。再写一句。", service.VLMRequest{Prompt: "p"}, nil},
+	}
+	for _, c := range cases {
+		if got := service.ValidateDescription(c.desc, c.req); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.desc, got, c.want)
+		}
+	}
+}
+
+func TestGenerateStepDescription_ReasksOnInvalidOutput(t *testing.T) {
+	setupDB(t)
+	stubborn := false
+	var prompts []string
+	aiSvc := fakeOllama(t, func(prompt string) string {
+		prompts = append(prompts, prompt)
+		if !stubborn && strings.Contains(prompt, "不符合格式要求") {
+			return "点击提交按钮"
+		}
+		return "完成本步骤"
+	})
+	req := service.VLMRequest{StepIndex: 1, StepAction: "click", TargetElement: "提交按钮", SessionID: "s1", StepID: "st1"}
+
+	resp, err := aiSvc.GenerateStepDescription(req)
+	if err != nil || resp.Provider != "ollama" || resp.Description != "第1步：点击提交按钮" || len(prompts) != 2 {
+		t.Fatalf("expected a corrected answer after one re-ask, got %+v %v (%d calls)", resp, err, len(prompts))
+	}
+	if !strings.Contains(prompts[1], "必须写明操作动作") {
+		t.Errorf("correction prompt should name the problems:\n%s", prompts[1])
+	}
+
+	// 重问后仍不合格：降级到规则描述
+	stubborn, prompts = true, nil
+	if resp, _ = aiSvc.GenerateStepDescription(req); resp.Provider != "rule-based" || len(prompts) != 2 {
+		t.Errorf("expected fallback after a failed re-ask, got %+v (%d calls)", resp, len(prompts))
+	}
+	// 指定提供商时不降级，返回输出并标出问题
+	if resp, err = aiSvc.GenerateWithProvider(req, "ollama", ""); err != nil || len(resp.Invalid) == 0 {
+		t.Errorf("expected invalid problems on explicit provider, got %+v %v", resp, err)
+	}
+
+	failures := service.ValidationFailures("ollama", 10)
+	if len(failures) != 3 || !failures[2].Recovered || failures[0].Recovered || failures[0].Output != "第1步：完成本步骤" ||
+		failures[0].SessionID != "s1" || failures[0].StepID != "st1" {
+		t.Errorf("unexpected failure records: %+v", failures)
+	}
+	if len(service.ValidationFailures("openai", 10)) != 0 {
+		t.Error("provider filter should apply")
+	}
+}
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTrace 上传内容不是 Playwright trace.zip
+var ErrInvalidTrace = errors.New("not a playwright trace archive")
+
+// ErrTraceTooLarge trace.zip 解压后的内容超过上限
+var ErrTraceTooLarge = errors.New("playwright trace too large when decompressed")
+
+// traceExpansion 解压后读取的内容总量上限为会话附件大小上限的倍数（轨迹文本可压缩，录屏帧基本不可压缩）
+const traceExpansion = 4
+
+// traceEvent trace.trace 中的一行事件（只解析转换需要的字段，兼容按 apiName 或 class/method 记录调用的版本）
+type traceEvent struct {
+	Type    string `json:"type"`
+	CallID  string `json:"callId"`
+	APIName string `json:"apiName"` // 旧版：page.click
+	Class   string `json:"class"`   // 新版：Frame
+	Method  string `json:"method"`  // 新版：click
+	PageID  string `json:"pageId"`
+	Params  struct {
+		URL      string `json:"url"`
+		Selector string `json:"selector"`
+		Value    string `json:"value"`
+		Text     string `json:"text"`
+		Key      string `json:"key"`
+		Options  []struct {
+			Value        string `json:"value"`
+			Label        string `json:"label"`
+			ValueOrLabel string `json:"valueOrLabel"`
+		} `json:"options"`
+	} `json:"params"`
+	StartTime float64         `json:"startTime"`
+	EndTime   float64         `json:"endTime"`
+	Time      float64         `json:"time"`
+	Error     json.RawMessage `json:"error"`
+	Point     *struct {
+		X float64 `json:"x"`
+		Y float64 `json:"y"`
+	} `json:"point"`
+	// screencast-frame
+	SHA1      string  `json:"sha1"`
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	Timestamp float64 `json:"timestamp"`
+	// context-options
+	Title         string  `json:"title"`
+	WallTime      float64 `json:"wallTime"`
+	MonotonicTime float64 `json:"monotonicTime"`
+	Options       struct {
+		Viewport *struct {
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		} `json:"viewport"`
+	} `json:"options"`
+}
+
+// traceAction 一次 API 调用（before / input / after 事件合并）
+type traceAction struct {
+	traceEvent
+	point  *struct{ X, Y float64 }
+	failed bool
+	end    float64
+}
+
+// traceFrame 录屏帧
+type traceFrame struct {
+	sha1          string
+	width, height int
+	time          float64
+}
+
+// playwrightActions Playwright 调用 → 步骤操作类型
+var playwrightActions = map[string]string{
+	"goto":         "navigation",
+	"click":        "click",
+	"dblclick":     "click",
+	"tap":          "click",
+	"check":        "click",
+	"uncheck":      "click",
+	"fill":         "input",
+	"type":         "input",
+	"selectOption": "select",
+	"hover":        "hover",
+	"press":        ActionKeypress,
+}
+
+// traceCallClasses 页面操作所属的类，其中未转换的调用计入跳过列表（上下文、追踪等调用直接忽略）
+var traceCallClasses = map[string]bool{"Frame": true, "Page": true, "ElementHandle": true, "Locator": true}
+
+// ImportPlaywrightTrace 把 Playwright trace.zip（操作与录屏帧）转换为项目下的新会话（需在事务中调用）。
+// 每个操作取执行前最近的录屏帧作为截图（导航取完成时的帧），失败的操作跳过；
+// 输入值按导入外部录制的规则脱敏，截图原样保存，需在审阅时补标遮蔽区域
+func ImportPlaywrightTrace(tx *gorm.DB, projectID string, archive []byte) (*RecorderImport, error) {
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTrace, err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	var traces []string
+	for _, f := range zr.File {
+		files[f.Name] = f
+		if strings.HasSuffix(f.Name, ".trace") {
+			traces = append(traces, f.Name)
+		}
+	}
+	if len(traces) == 0 {
+		return nil, fmt.Errorf("%w: no .trace file", ErrInvalidTrace)
+	}
+	sort.Strings(traces)
+
+	// 逐条目读取时扣减剩余额度，超出即中止
+	budget := traceExpansion * CurrentSettings().MaxMediaBytes()
+	read := func(f *zip.File) ([]byte, error) {
+		data, err := readZipFileLimit(f, budget)
+		if errors.Is(err, errZipEntryTooLarge) {
+			return nil, ErrTraceTooLarge
+		}
+		budget -= int64(len(data))
+		return data, err
+	}
+
+	var (
+		title, pageURL string
+		wall, mono     float64
+		viewportW      int
+		viewportH      int
+		calls          = map[string]*traceAction{}
+		actions        []*traceAction
+		frames         = map[string][]traceFrame{}
+		navigations    []traceEvent
+	)
+	for _, name := range traces {
+		data, err := read(files[name])
+		if err != nil {
+			return nil, err
+		}
+		sc := bufio.NewScanner(bytes.NewReader(data))
+		sc.Buffer(make([]byte, 0, 64*1024), 16<<20)
+		for sc.Scan() {
+			var e traceEvent
+			if json.Unmarshal(sc.Bytes(), &e) != nil {
+				continue
+			}
+			switch e.Type {
+			case "context-options":
+				title, wall, mono = e.Title, e.WallTime, e.MonotonicTime
+				if v := e.Options.Viewport; v != nil {
+					viewportW, viewportH = v.Width, v.Height
+				}
+			case "before":
+				if e.Method == "" && e.APIName != "" {
+					e.Method = e.APIName[strings.LastIndex(e.APIName, ".")+1:]
+					e.Class = "Page"
+				}
+				a := &traceAction{traceEvent: e, end: e.StartTime}
+				calls[e.CallID] = a
+				actions = append(actions, a)
+			case "input":
+				if a := calls[e.CallID]; a != nil && e.Point != nil {
+					a.point = &struct{ X, Y float64 }{e.Point.X, e.Point.Y}
+				}
+			case "after":
+				if a := calls[e.CallID]; a != nil {
+					a.end = e.EndTime
+					a.failed = len(e.Error) > 0 && string(e.Error) != "null"
+				}
+			case "screencast-frame":
+				frames[e.PageID] = append(frames[e.PageID], traceFrame{e.SHA1, e.Width, e.Height, e.Timestamp})
+			case "event":
+				if e.Method == "navigated" && e.Params.URL != "" {
+					navigations = append(navigations, e)
+				}
+			}
+		}
+	}
+	sort.SliceStable(actions, func(i, j int) bool { return actions[i].StartTime < actions[j].StartTime })
+	sort.SliceStable(navigations, func(i, j int) bool { return navigations[i].Time < navigations[j].Time })
+	for id := range frames {
+		sort.SliceStable(frames[id], func(i, j int) bool { return frames[id][i].time < frames[id][j].time })
+	}
+
+	session, err := createImportedSession(tx, projectID, title)
+	if err != nil {
+		return nil, err
+	}
+	result := &RecorderImport{Session: session, Skipped: []string{}}
+	nav := 0
+	for _, a := range actions {
+		action, ok := playwrightActions[a.Method]
+		if !ok || a.failed {
+			if traceCallClasses[a.Class] {
+				result.Skipped = append(result.Skipped, a.Method)
+			}
+			continue
+		}
+		// 操作开始前发生的导航（包括点击链接、提交表单引起的跳转）决定所在页面
+		for ; nav < len(navigations) && navigations[nav].Time <= a.StartTime; nav++ {
+			pageURL = sanitizePageURL(navigations[nav].Params.URL)
+		}
+
+		step := db.RecordingStep{SessionID: session.ID, Action: action, PageURL: pageURL,
+			ViewportW: viewportW, ViewportH: viewportH}
+		if wall > 0 {
+			step.Timestamp = int64(wall + a.StartTime - mono)
+		}
+		if a.point != nil {
+			step.ClickX, step.ClickY = int(a.point.X), int(a.point.Y)
+		}
+		step.TargetElement, step.TargetSelector = playwrightTarget(a.Params.Selector)
+		shotAt := a.StartTime
+		switch a.Method {
+		case "goto":
+			pageURL = sanitizePageURL(a.Params.URL)
+			step.PageURL, step.TargetElement = pageURL, pageURL
+			if session.TargetURL == "" {
+				session.TargetURL = pageURL
+			}
+			shotAt = a.end
+		case "fill", "type":
+			value := a.Params.Value
+			if a.Method == "type" {
+				value = a.Params.Text
+			}
+			step.MaskedText, step.IsMasked = maskRecordedValue(value, step)
+		case "selectOption":
+			if len(a.Params.Options) > 0 {
+				o := a.Params.Options[0]
+				step.MaskedText = firstNonEmpty(o.Label, o.ValueOrLabel, o.Value)
+			}
+		case "press":
+			combo, err := NormalizeKeyCombo(a.Params.Key, "", nil)
+			if err != nil {
+				result.Skipped = append(result.Skipped, a.Method)
+				continue
+			}
+			step.KeyCombo = combo
+			if HasModifier(combo) {
+				step.Action = ActionShortcut
+			}
+		}
+
+		in := StepInput{Step: step}
+		if f, ok := frameAt(frames[a.PageID], shotAt); ok {
+			if zf := files["resources/"+f.sha1]; zf != nil {
+				data, err := read(zf)
+				if err != nil {
+					return nil, err
+				}
+				if shot, err := ScreenshotFromBytes(data); err == nil {
+					if shot.Width == 0 {
+						shot.Width, shot.Height = f.width, f.height
+					}
+					shot.CapturedAt = step.Timestamp
+					in.Screenshot = shot
+				}
+			}
+		}
+		if _, err := IngestStep(tx, in); err != nil {
+			return nil, err
+		}
+		result.Steps++
+	}
+	if result.Steps == 0 {
+		return nil, ErrEmptyRecording
+	}
+	return result, finishImportedSession(tx, session)
+}
+
+// frameAt 取 at 时刻之前最近的录屏帧，没有时取之后的第一帧
+func frameAt(frames []traceFrame, at float64) (traceFrame, bool) {
+	if len(frames) == 0 {
+		return traceFrame{}, false
+	}
+	i := sort.Search(len(frames), func(i int) bool { return frames[i].time > at })
+	if i == 0 {
+		return frames[0], true
+	}
+	return frames[i-1], true
+}
+
+// playwrightQuoted getByRole / getByLabel / getByText / getByPlaceholder 等生成的选择器中的名称，
+// 如 internal:role=button[name="提交"i]、internal:label="用户名"s、internal:attr=[placeholder="请输入"i]
+var playwrightQuoted = regexp.MustCompile(`(?:name|label|text|placeholder|alt|title|data-testid)="((?:[^"\\]|\\.)*)"`)
+
+// playwrightTarget 从 Playwright 选择器中取可读的目标名称与 CSS 选择器（链式选择器取最后一段）
+func playwrightTarget(selector string) (element, css string) {
+	if selector == "" {
+		return "", ""
+	}
+	parts := strings.Split(selector, " >> ")
+	last := strings.TrimSpace(parts[len(parts)-1])
+	switch {
+	case strings.HasPrefix(last, "internal:"), strings.HasPrefix(last, "text="):
+		if m := playwrightQuoted.FindStringSubmatch(last); m != nil {
+			return strings.ReplaceAll(m[1], `\"`, `"`), ""
+		}
+		if rest, ok := strings.CutPrefix(last, "text="); ok {
+			return strings.Trim(rest, `"'`), ""
+		}
+		return strings.TrimPrefix(last, "internal:role="), ""
+	case strings.HasPrefix(last, "xpath="), strings.HasPrefix(last, "//"):
+		return last, ""
+	}
+	return last, strings.TrimPrefix(last, "css=")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,94 @@
+package service_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+// playwrightTrace 构造最小的 trace.zip：调用事件、录屏帧与帧图片
+func playwrightTrace(t *testing.T, events []string, frames map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("trace.trace")
+	w.Write([]byte(strings.Join(events, "\n")))
+	for name, dataURL := range frames {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(dataURL, "data:image/png;base64,"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, _ := zw.Create("resources/" + name)
+		w.Write(raw)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImportPlaywrightTrace(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "Playwright"}
+	db.DB.Create(&proj)
+
+	archive := playwrightTrace(t, []string{
+		`{"type":"context-options","version":6,"title":"缴费流程 › 查询账单","wallTime":1700000000000,"monotonicTime":1000,"options":{"viewport":{"width":1280,"height":720}}}`,
+		`{"type":"screencast-frame","pageId":"page@1","sha1":"blank.png","width":40,"height":20,"timestamp":1001}`,
+		`{"type":"before","callId":"call@1","startTime":1002,"class":"Frame","method":"goto","pageId":"page@1","params":{"url":"https://gov.example.com/login?ticket=abc"}}`,
+		`{"type":"event","time":1050,"class":"Frame","method":"navigated","params":{"url":"https://gov.example.com/login?ticket=abc"}}`,
+		`{"type":"screencast-frame","pageId":"page@1","sha1":"login.png","width":40,"height":20,"timestamp":1100}`,
+		`{"type":"after","callId":"call@1","endTime":1200}`,
+		`{"type":"before","callId":"call@2","startTime":1300,"class":"Frame","method":"fill","pageId":"page@1","params":{"selector":"internal:label=\"手机号\"i","value":"13812345678"}}`,
+		`{"type":"after","callId":"call@2","endTime":1350}`,
+		`{"type":"before","callId":"call@3","startTime":1400,"class":"Frame","method":"waitForSelector","pageId":"page@1","params":{"selector":"#menu"}}`,
+		`{"type":"before","callId":"call@4","startTime":1500,"class":"Frame","method":"click","pageId":"page@1","params":{"selector":"internal:role=button[name=\"查询账单\"i]"}}`,
+		`{"type":"input","callId":"call@4","point":{"x":320,"y":240}}`,
+		`{"type":"after","callId":"call@4","endTime":1600}`,
+		`{"type":"event","time":1580,"class":"Frame","method":"navigated","params":{"url":"https://gov.example.com/bills#/2026"}}`,
+		`{"type":"before","callId":"call@5","startTime":1700,"class":"Frame","method":"press","pageId":"page@1","params":{"selector":"body","key":"Control+P"}}`,
+		`{"type":"after","callId":"call@5","endTime":1750}`,
+		`{"type":"before","callId":"call@6","startTime":1800,"class":"Frame","method":"click","pageId":"page@1","params":{"selector":"#missing"}}`,
+		`{"type":"after","callId":"call@6","endTime":1900,"error":{"message":"Timeout 30000ms exceeded"}}`,
+		`{"type":"before","callId":"call@7","startTime":1950,"class":"Tracing","method":"tracingStop"}`,
+	}, map[string]string{"blank.png": pngDataURL(t, 40, 20), "login.png": pngDataURL(t, 40, 20)})
+
+	result, err := service.ImportPlaywrightTrace(db.DB, proj.ID, archive)
+	if err != nil {
+		t.Fatalf("ImportPlaywrightTrace: %v", err)
+	}
+	if result.Steps != 4 || strings.Join(result.Skipped, ",") != "waitForSelector,click" {
+		t.Fatalf("unexpected result: %d steps, skipped %v", result.Steps, result.Skipped)
+	}
+	if s := result.Session; s.Title != "缴费流程 › 查询账单" || s.TargetURL != "https://gov.example.com/login?ticket=%2A%2A%2A" {
+		t.Errorf("unexpected session %+v", s)
+	}
+
+	var steps []db.RecordingStep
+	db.DB.Where("session_id = ?", result.Session.ID).Order("step_index").Find(&steps)
+	want := []struct{ action, target, text, page string }{
+		{"navigation", "https://gov.example.com/login?ticket=%2A%2A%2A", "", "https://gov.example.com/login?ticket=%2A%2A%2A"},
+		{"input", "手机号", "【手机号】", "https://gov.example.com/login?ticket=%2A%2A%2A"},
+		{"click", "查询账单", "", "https://gov.example.com/login?ticket=%2A%2A%2A"},
+		{service.ActionShortcut, "body", "", "https://gov.example.com/bills#/2026"},
+	}
+	for i, w := range want {
+		s := steps[i]
+		if s.Action != w.action || s.TargetElement != w.target || s.MaskedText != w.text || s.PageURL != w.page || s.ScreenshotID == "" {
+			t.Errorf("step %d: got %s %q %q %q (shot %q), want %+v", i+1, s.Action, s.TargetElement, s.MaskedText, s.PageURL, s.ScreenshotID, w)
+		}
+	}
+	if steps[2].ClickX != 320 || steps[3].KeyCombo != "Ctrl+P" || steps[0].ViewportW != 1280 || steps[1].Timestamp != 1700000000300 {
+		t.Errorf("unexpected point, key, viewport or timestamp: %+v %+v", steps[2], steps[3])
+	}
+
+	if _, err := service.ImportPlaywrightTrace(db.DB, proj.ID, []byte("not a zip")); !errors.Is(err, service.ErrInvalidTrace) {
+		t.Errorf("expected ErrInvalidTrace, got %v", err)
+	}
+}
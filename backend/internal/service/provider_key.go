@@ -0,0 +1,74 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrKeyInvalid 提供商拒绝了 API Key（401/403，或 Gemini 的 API_KEY_INVALID）
+	ErrKeyInvalid = errors.New("API key is invalid")
+	// ErrQuotaExceeded API Key 有效但配额已用尽或被限流（429）
+	ErrQuotaExceeded = errors.New("API quota exceeded")
+)
+
+// VerifyKey 用候选 API Key（及可选的 baseURL）发起一次轻量调用验证其可用性：
+// 查询模型列表，智谱没有列表接口则发起一次极短的补全。不写入数据库
+func (s *AIService) VerifyKey(provider, apiKey, baseURL string) error {
+	eff := s.effectiveCfg()
+	set := func(key, base *string) {
+		*key = apiKey
+		if baseURL != "" {
+			*base = baseURL
+		}
+	}
+	var err error
+	switch provider {
+	case "gemini":
+		set(&eff.GeminiAPIKey, &eff.GeminiBaseURL)
+		_, err = s.listModels(eff, provider)
+	case "openai":
+		set(&eff.OpenAIAPIKey, &eff.OpenAIBaseURL)
+		_, err = s.listModels(eff, provider)
+	case "openrouter":
+		// OpenRouter 模型目录不校验密钥，改查密钥信息（含剩余额度）
+		set(&eff.OpenRouterAPIKey, &eff.OpenRouterBaseURL)
+		var info struct {
+			Data struct {
+				LimitRemaining *float64 `json:"limit_remaining"`
+			} `json:"data"`
+		}
+		err = s.getJSON(eff, provider, eff.OpenRouterBaseURL+"/key",
+			map[string]string{"Authorization": "Bearer " + apiKey}, &info)
+		if err == nil && info.Data.LimitRemaining != nil && *info.Data.LimitRemaining <= 0 {
+			return fmt.Errorf("%w (openrouter credit limit reached)", ErrQuotaExceeded)
+		}
+	case "zhipu":
+		set(&eff.ZhipuAPIKey, &eff.ZhipuBaseURL)
+		t := eff.ProviderTuning["zhipu"]
+		t.MaxTokens = 1
+		eff.ProviderTuning["zhipu"] = t
+		_, err = s.callZhipu(VLMRequest{Prompt: "ping"}, eff)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+	}
+	return classifyKeyError(err)
+}
+
+// classifyKeyError 将提供商返回的状态码归类为密钥无效 / 配额不足，其余错误原样返回
+func classifyKeyError(err error) error {
+	var se *statusError
+	if !errors.As(err, &se) {
+		return err
+	}
+	switch {
+	case se.code == http.StatusUnauthorized || se.code == http.StatusForbidden,
+		se.code == http.StatusBadRequest && strings.Contains(se.body, "API_KEY_INVALID"):
+		return fmt.Errorf("%w (%s status %d)", ErrKeyInvalid, se.provider, se.code)
+	case se.code == http.StatusTooManyRequests:
+		return fmt.Errorf("%w (%s status %d)", ErrQuotaExceeded, se.provider, se.code)
+	}
+	return err
+}
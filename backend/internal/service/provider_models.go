@@ -0,0 +1,169 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gpilot/backend/internal/config"
+)
+
+// ModelInfo 提供商可用的模型
+type ModelInfo struct {
+	ID      string `json:"id"`                // 写入 LLMProvider.model 的取值
+	Name    string `json:"name,omitempty"`    // 展示名称（提供商返回时）
+	IsFree  bool   `json:"is_free,omitempty"` // OpenRouter 免费模型
+	Current bool   `json:"current"`           // 当前配置使用的模型
+}
+
+// ErrModelListUnsupported 提供商没有可用的模型列表接口
+var ErrModelListUnsupported = errors.New("provider does not support model listing")
+
+// ListModels 查询提供商的模型列表（Ollama /api/tags、OpenAI /models、OpenRouter 模型目录、Gemini /models），
+// 按 ID 排序；使用该提供商已保存的地址、密钥与代理
+func (s *AIService) ListModels(provider string) ([]ModelInfo, error) {
+	if s.LocalOnly() && modelListers[provider] && IsExternalProvider(provider) {
+		return nil, fmt.Errorf("%w: %s", ErrProviderDisabled, provider)
+	}
+	eff := s.effectiveCfg()
+	models, err := s.listModels(eff, provider)
+	if err != nil {
+		return nil, err
+	}
+	current := map[string]string{
+		"ollama": eff.OllamaModel, "openai": eff.OpenAIModel,
+		"openrouter": eff.OpenRouterModel, "gemini": eff.GeminiModel,
+	}[provider]
+	for i := range models {
+		models[i].Current = models[i].ID == current
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	return models, nil
+}
+
+func (s *AIService) listModels(eff *config.LLMConfig, provider string) ([]ModelInfo, error) {
+	var (
+		models []ModelInfo
+		err    error
+	)
+	switch provider {
+	case "ollama":
+		models, err = s.listOllamaModels(eff)
+	case "openai":
+		models, err = s.listOpenAIModels(eff, "openai", eff.OpenAIBaseURL, eff.OpenAIAPIKey)
+	case "openrouter":
+		models, err = s.listOpenAIModels(eff, "openrouter", eff.OpenRouterBaseURL, eff.OpenRouterAPIKey)
+	case "gemini":
+		models, err = s.listGeminiModels(eff)
+	case "zhipu":
+		return nil, fmt.Errorf("%w: %s", ErrModelListUnsupported, provider)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", provider, err)
+	}
+	return models, nil
+}
+
+// modelListers 支持模型列表查询的提供商
+var modelListers = map[string]bool{"ollama": true, "openai": true, "openrouter": true, "gemini": true}
+
+func (s *AIService) listOllamaModels(cfg *config.LLMConfig) ([]ModelInfo, error) {
+	var result struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := s.getJSON(cfg, "ollama", cfg.OllamaBaseURL+"/api/tags", nil, &result); err != nil {
+		return nil, err
+	}
+	out := make([]ModelInfo, 0, len(result.Models))
+	for _, m := range result.Models {
+		out = append(out, ModelInfo{ID: m.Name})
+	}
+	return out, nil
+}
+
+// listOpenAIModels OpenAI 兼容的 /models 接口（OpenRouter 目录无需密钥，并附带价格）
+func (s *AIService) listOpenAIModels(cfg *config.LLMConfig, provider, baseURL, apiKey string) ([]ModelInfo, error) {
+	if apiKey == "" && provider != "openrouter" {
+		return nil, fmt.Errorf("provider %s is not configured", provider)
+	}
+	var result struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Name    string `json:"name"`
+			Pricing *struct {
+				Prompt     string `json:"prompt"`
+				Completion string `json:"completion"`
+			} `json:"pricing"`
+		} `json:"data"`
+	}
+	var header map[string]string
+	if apiKey != "" {
+		header = map[string]string{"Authorization": "Bearer " + apiKey}
+	}
+	if err := s.getJSON(cfg, provider, baseURL+"/models", header, &result); err != nil {
+		return nil, err
+	}
+	out := make([]ModelInfo, 0, len(result.Data))
+	for _, m := range result.Data {
+		free := strings.HasSuffix(m.ID, ":free") ||
+			(m.Pricing != nil && m.Pricing.Prompt == "0" && m.Pricing.Completion == "0")
+		out = append(out, ModelInfo{ID: m.ID, Name: m.Name, IsFree: free})
+	}
+	return out, nil
+}
+
+// listGeminiModels 只返回支持 generateContent 的模型
+func (s *AIService) listGeminiModels(cfg *config.LLMConfig) ([]ModelInfo, error) {
+	if cfg.GeminiAPIKey == "" {
+		return nil, fmt.Errorf("provider gemini is not configured")
+	}
+	var result struct {
+		Models []struct {
+			Name        string   `json:"name"`
+			DisplayName string   `json:"displayName"`
+			Methods     []string `json:"supportedGenerationMethods"`
+		} `json:"models"`
+	}
+	// 密钥放在请求头中，避免出现在网络错误信息的 URL 里
+	header := map[string]string{"x-goog-api-key": cfg.GeminiAPIKey}
+	if err := s.getJSON(cfg, "gemini", cfg.GeminiBaseURL+"/models?pageSize=1000", header, &result); err != nil {
+		return nil, err
+	}
+	out := make([]ModelInfo, 0, len(result.Models))
+	for _, m := range result.Models {
+		if !OneOf("generateContent", m.Methods) {
+			continue
+		}
+		out = append(out, ModelInfo{ID: strings.TrimPrefix(m.Name, "models/"), Name: m.DisplayName})
+	}
+	return out, nil
+}
+
+// getJSON 以提供商的客户端设置发起 GET 请求并解析 JSON 响应
+func (s *AIService) getJSON(cfg *config.LLMConfig, provider, url string, header map[string]string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := s.httpClient(cfg, provider).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{provider: provider, code: resp.StatusCode, body: string(b)}
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
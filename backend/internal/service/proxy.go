@@ -0,0 +1,77 @@
+package service
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gpilot/backend/internal/config"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ProxyDirect LLMProvider.Proxy 取值：该提供商不走任何代理（包括全局代理和环境变量）
+const ProxyDirect = "direct"
+
+// CheckProviderProxy 校验 LLMProvider.Proxy：空串、"direct" 或代理 URL
+func CheckProviderProxy(proxy string) error {
+	if proxy == "" || proxy == ProxyDirect {
+		return nil
+	}
+	return config.CheckProxyURL(proxy)
+}
+
+// providerProxy 提供商实际使用的代理：提供商覆盖 > 全局 llm.proxy；空串表示沿用环境变量
+func providerProxy(cfg *config.LLMConfig, provider string) string {
+	if p, ok := cfg.ProviderProxies[provider]; ok && p != "" {
+		return p
+	}
+	return cfg.Proxy
+}
+
+// proxyFunc 构造代理选择函数。llm.no_proxy 在显式代理和环境变量代理下都生效；
+// localhost 与回环地址（本地 Ollama）始终直连
+func proxyFunc(proxy, noProxy string) func(*http.Request) (*url.URL, error) {
+	if proxy == ProxyDirect {
+		return nil
+	}
+	pc := httpproxy.Config{HTTPProxy: proxy, HTTPSProxy: proxy, NoProxy: noProxy}
+	if proxy == "" {
+		pc = *httpproxy.FromEnvironment()
+		if noProxy != "" {
+			pc.NoProxy = strings.Trim(pc.NoProxy+","+noProxy, ",")
+		}
+	}
+	fn := pc.ProxyFunc()
+	return func(r *http.Request) (*url.URL, error) {
+		return fn(r.URL)
+	}
+}
+
+// transports 按代理配置缓存的 Transport，复用连接池
+var transports sync.Map
+
+// transportFor 提供商使用的 Transport
+func transportFor(cfg *config.LLMConfig, provider string) *http.Transport {
+	proxy := providerProxy(cfg, provider)
+	key := proxy + "|" + cfg.NoProxy
+	if t, ok := transports.Load(key); ok {
+		return t.(*http.Transport)
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = proxyFunc(proxy, cfg.NoProxy)
+	actual, _ := transports.LoadOrStore(key, t)
+	return actual.(*http.Transport)
+}
+
+// RedactProxy 隐藏代理地址中的密码，用于接口输出
+func RedactProxy(proxy string) string {
+	if proxy == "" || proxy == ProxyDirect {
+		return proxy
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return ""
+	}
+	return u.Redacted()
+}
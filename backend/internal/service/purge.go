@@ -0,0 +1,115 @@
+package service
+
+import (
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// PurgedSessionTitle 清除后会话的标题（原标题可能包含办事人姓名等信息）
+const PurgedSessionTitle = "[已清除]"
+
+// PurgeResult 会话清除结果
+type PurgeResult struct {
+	SessionID         string    `json:"session_id"`
+	StepsAnonymized   int64     `json:"steps_anonymized"`
+	ScreenshotsPurged int64     `json:"screenshots_purged"`
+	DocumentsPurged   int64     `json:"documents_purged"`
+	LogsPurged        int64     `json:"logs_purged"`
+	MediaPurged       int64     `json:"media_purged"`
+	PurgedAt          time.Time `json:"purged_at"`
+}
+
+// PurgeSession 应数据主体删除请求不可逆地清除会话内容（需在事务中调用）：
+// 删除截图、生成的文档、控制台输出和附件记录（附件文件由调用方用 RemoveSessionMediaFiles 删除），
+// 清空步骤的输入值、页面文本、目标元素、描述等内容字段，页面地址只保留归一化后的模式。
+// 保留的匿名骨架为步骤序号、操作类型、耗时与几何位置，以及已脱敏的接口模式和脱敏审计计数，
+// 便于统计仍可用；重复执行无副作用
+func PurgeSession(tx *gorm.DB, sessionID string, now time.Time) (*PurgeResult, error) {
+	var session db.Session
+	if err := tx.First(&session, "id = ?", sessionID).Error; err != nil {
+		return nil, err
+	}
+	if err := secureDelete(tx); err != nil {
+		return nil, err
+	}
+	if session.PurgedAt != nil {
+		now = *session.PurgedAt // 保留首次清除时间
+	}
+	result := &PurgeResult{SessionID: sessionID, PurgedAt: now}
+
+	var steps []db.RecordingStep
+	if err := tx.Select("id", "page_url").Where("session_id = ?", sessionID).Find(&steps).Error; err != nil {
+		return nil, err
+	}
+	for _, st := range steps {
+		res := tx.Model(&db.RecordingStep{}).Where("id = ?", st.ID).Updates(map[string]interface{}{
+			"TargetSelector": "",
+			"TargetXPath":    "",
+			"TargetElement":  "",
+			"AriaLabel":      "",
+			"MaskedText":     "",
+			"InputValue":     "",
+			"PageURL":        URLPattern(st.PageURL),
+			"PageTitle":      "",
+			"FramePath":      "",
+			"ScreenshotID":   "",
+			"AIDescription":  "",
+			"AITitle":        "",
+			"AINotes":        "",
+			"DOMFingerprint": "",
+		})
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		result.StepsAnonymized += res.RowsAffected
+	}
+
+	// 接口地址入库前已脱敏，仅再归一化为模式，保留方法与状态码
+	var requests []db.StepRequest
+	if err := tx.Select("id", "url").Where("session_id = ?", sessionID).Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range requests {
+		if err := tx.Model(&db.StepRequest{}).Where("id = ?", r.ID).Update("url", URLPattern(r.URL)).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	for _, d := range []struct {
+		model interface{}
+		count *int64
+	}{
+		{&db.Screenshot{}, &result.ScreenshotsPurged},
+		{&db.GeneratedDocument{}, &result.DocumentsPurged},
+		{&db.StepLog{}, &result.LogsPurged},
+		{&db.SessionMedia{}, &result.MediaPurged},
+	} {
+		res := tx.Where("session_id = ?", sessionID).Delete(d.model)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		*d.count = res.RowsAffected
+	}
+	// 文档已清除，其分享链接与导出任务一并删除（产物文件由 CleanupExports 清理）；回放截图与录制截图同样可能含个人信息，检索索引保存了描述原文，问答记录会复述步骤内容，格式校验失败记录保存了模型输出原文
+	for _, model := range []interface{}{
+		&db.DocumentShare{}, &db.ReplayStep{}, &db.ReplayRun{}, &db.Embedding{}, &db.ChatMessage{}, &db.ChatConversation{},
+		&db.ExportJob{}, &db.OutputValidationFailure{},
+	} {
+		if err := tx.Where("session_id = ?", sessionID).Delete(model).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	err := tx.Model(&session).Updates(map[string]interface{}{
+		"title":            PurgedSessionTitle,
+		"target_url":       URLPattern(session.TargetURL),
+		"generated_doc_id": "",
+		"purged_at":        now,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,87 @@
+package service_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestPurgeSession(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "清除", MergeStrategy: service.MergeOff}
+	db.DB.Create(&proj)
+	sess := db.Session{ProjectID: proj.ID, Title: "张三营业执照申请", TargetURL: "https://gov.example.com/citizens/42"}
+	db.DB.Create(&sess)
+
+	res, err := service.IngestStep(db.DB, service.StepInput{
+		Step: db.RecordingStep{
+			SessionID: sess.ID, StepIndex: 1, Action: "input",
+			TargetElement: "姓名", MaskedText: "张三", InputValue: "张三", AIDescription: "输入张三", AITitle: "审批张三申请",
+			PageURL: "https://gov.example.com/citizens/42/edit?name=zhangsan", PageTitle: "张三 - 编辑",
+		},
+		Screenshot:    &db.Screenshot{DataURL: db.LongText(pngDataURL(t, 20, 20))},
+		Requests:      []db.StepRequest{{Method: "POST", URL: "https://gov.example.com/api/citizens/42", Status: 200}},
+		Logs:          []db.StepLog{{Level: "error", Message: "张三 not found"}},
+		MaskingEvents: []db.MaskingEvent{{RuleType: "regex", Category: "手机号", Field: "input_value", Count: 1}},
+	})
+	if err != nil {
+		t.Fatalf("IngestStep: %v", err)
+	}
+	svc := service.NewDocService()
+	content, _ := svc.BuildDocument(sess.ID)
+	if _, err := svc.SaveGeneratedDoc(sess.ID, content); err != nil {
+		t.Fatalf("SaveGeneratedDoc: %v", err)
+	}
+
+	db.DB.Create(&db.OutputValidationFailure{Provider: "ollama", SessionID: sess.ID, StepID: res.Step.ID, Output: "输入张三"})
+
+	now := time.Now()
+	result, err := service.PurgeSession(db.DB, sess.ID, now)
+	if err != nil {
+		t.Fatalf("PurgeSession: %v", err)
+	}
+	if result.StepsAnonymized != 1 || result.ScreenshotsPurged != 1 || result.DocumentsPurged != 1 || result.LogsPurged != 1 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	var step db.RecordingStep
+	db.DB.First(&step, "id = ?", res.Step.ID)
+	if step.InputValue != "" || step.MaskedText != "" || step.TargetElement != "" || step.AIDescription != "" || step.AITitle != "" ||
+		step.PageTitle != "" || step.ScreenshotID != "" {
+		t.Errorf("step content should be cleared: %+v", step)
+	}
+	if step.Action != "input" || step.StepIndex != 1 {
+		t.Errorf("skeleton should be kept: %+v", step)
+	}
+	if step.PageURL != "gov.example.com/citizens/:id/edit" {
+		t.Errorf("page url should be reduced to its pattern, got %q", step.PageURL)
+	}
+
+	var purged db.Session
+	db.DB.First(&purged, "id = ?", sess.ID)
+	if purged.Title != service.PurgedSessionTitle || purged.PurgedAt == nil || purged.GeneratedDocID != "" {
+		t.Errorf("session should be anonymized: %+v", purged)
+	}
+	var req db.StepRequest
+	db.DB.First(&req, "session_id = ?", sess.ID)
+	if req.URL != "gov.example.com/api/citizens/:id" || req.Method != "POST" {
+		t.Errorf("unexpected request skeleton: %+v", req)
+	}
+	var events int64
+	db.DB.Model(&db.MaskingEvent{}).Where("session_id = ?", sess.ID).Count(&events)
+	if events != 1 {
+		t.Error("masking audit counts should be kept")
+	}
+	var failures int64
+	db.DB.Model(&db.OutputValidationFailure{}).Where("session_id = ?", sess.ID).Count(&failures)
+	if failures != 0 {
+		t.Error("validation failures keep the model output and should be removed")
+	}
+
+	again, err := service.PurgeSession(db.DB, sess.ID, now.Add(time.Hour))
+	if err != nil || again.ScreenshotsPurged != 0 || !again.PurgedAt.Equal(*purged.PurgedAt) {
+		t.Errorf("second purge should keep the original purge time: %+v %v", again, err)
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// ErrQuotaReached 提供商配置的调用次数或费用上限已用完
+var ErrQuotaReached = errors.New("provider quota reached")
+
+// ProviderBudgetUsage 提供商配置在当天 / 当月的调用次数与估算费用
+type ProviderBudgetUsage struct {
+	CallsToday int64   `json:"calls_today"`
+	CallsMonth int64   `json:"calls_month"`
+	CostToday  float64 `json:"cost_today"`
+	CostMonth  float64 `json:"cost_month"`
+}
+
+// UsageOf 统计提供商配置（LLMProvider.ID）截至 now 的当天、当月用量（按服务器本地时区划分）
+func UsageOf(providerID string, now time.Time) ProviderBudgetUsage {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	var u ProviderBudgetUsage
+	sum := func(since time.Time, calls *int64, cost *float64) {
+		var row struct {
+			Calls int64
+			Cost  float64
+		}
+		db.DB.Model(&db.ProviderCall{}).
+			Select("COUNT(*) AS calls, COALESCE(SUM(cost), 0) AS cost").
+			Where("provider_id = ? AND created_at >= ?", providerID, since).Scan(&row)
+		*calls, *cost = row.Calls, row.Cost
+	}
+	sum(day, &u.CallsToday, &u.CostToday)
+	sum(month, &u.CallsMonth, &u.CostMonth)
+	return u
+}
+
+// QuotaExceeded 检查提供商配置是否已达预算上限，返回触发的上限说明；未设置上限时不查询
+func QuotaExceeded(p db.LLMProvider, now time.Time) (bool, string) {
+	if p.DailyCallCap <= 0 && p.MonthlyCallCap <= 0 && p.DailyCostCap <= 0 && p.MonthlyCostCap <= 0 {
+		return false, ""
+	}
+	u := UsageOf(p.ID, now)
+	switch {
+	case p.DailyCallCap > 0 && u.CallsToday >= int64(p.DailyCallCap):
+		return true, fmt.Sprintf("daily call cap %d reached", p.DailyCallCap)
+	case p.MonthlyCallCap > 0 && u.CallsMonth >= int64(p.MonthlyCallCap):
+		return true, fmt.Sprintf("monthly call cap %d reached", p.MonthlyCallCap)
+	case p.DailyCostCap > 0 && u.CostToday+p.CostPerCall > p.DailyCostCap:
+		return true, fmt.Sprintf("daily cost cap %g reached", p.DailyCostCap)
+	case p.MonthlyCostCap > 0 && u.CostMonth+p.CostPerCall > p.MonthlyCostCap:
+		return true, fmt.Sprintf("monthly cost cap %g reached", p.MonthlyCostCap)
+	}
+	return false, ""
+}
+
+// recordProviderCall 记录一次成功调用；环境变量配置（没有 DB 记录）不计量
+func recordProviderCall(p providerEntry) {
+	if p.row == nil {
+		return
+	}
+	db.DB.Create(&db.ProviderCall{
+		ProviderID: p.row.ID,
+		Provider:   p.name,
+		Label:      p.label,
+		Model:      p.model,
+		Cost:       p.row.CostPerCall,
+	})
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// ErrNotApproved 会话没有已审批的文档，不允许销毁原始截图
+var ErrNotApproved = errors.New("session has no approved document")
+
+// RawDestroyResult 原始截图销毁结果
+type RawDestroyResult struct {
+	SessionID          string `json:"session_id"`
+	Destroyed          int    `json:"destroyed"`           // 原图已替换为遮蔽版本的截图数
+	Dropped            int    `json:"dropped"`             // 无法生成遮蔽版本、整张清除的截图数
+	DocumentsRewritten int    `json:"documents_rewritten"` // 内嵌截图被替换为遮蔽版本的文档数
+}
+
+// DestroyRawScreenshots 在会话文档审批后销毁原始截图：整屏图替换为烧录遮蔽区域后的版本，
+// 局部图从遮蔽版本重新裁剪，已保存文档中内嵌的截图同步替换，并标记 IsRawDeleted。
+// 遮蔽区域保留作为记录（再次烧录结果不变）；无法处理的截图整张清除，宁可缺图也不保留原图
+func (s *DocService) DestroyRawScreenshots(sessionID string) (*RawDestroyResult, error) {
+	var approved int64
+	if err := db.DB.Model(&db.GeneratedDocument{}).
+		Where("session_id = ? AND status = ?", sessionID, "approved").Count(&approved).Error; err != nil {
+		return nil, err
+	}
+	if approved == 0 {
+		return nil, ErrNotApproved
+	}
+
+	var shots []db.Screenshot
+	if err := db.DB.Where("session_id = ? AND is_raw_deleted = ?", sessionID, false).Find(&shots).Error; err != nil {
+		return nil, err
+	}
+	var docs []db.GeneratedDocument
+	if err := db.DB.Where("session_id = ?", sessionID).Find(&docs).Error; err != nil {
+		return nil, err
+	}
+
+	// 先替换文档中的截图：RedactContent 依据的是尚未替换的原图
+	result := &RawDestroyResult{SessionID: sessionID}
+	docUpdates := make([]map[string]interface{}, len(docs))
+	for i := range docs {
+		content, err := s.loadDocument(&docs[i])
+		if err != nil {
+			return nil, err
+		}
+		redacted := s.RedactContent(content, RedactBlack)
+		bizJSON, _ := json.Marshal(redacted.BusinessView)
+		techJSON, _ := json.Marshal(redacted.TechnicalView)
+		if db.LongText(bizJSON) == docs[i].BusinessView && db.LongText(techJSON) == docs[i].TechnicalView {
+			continue
+		}
+		// map 更新不经过模型钩子，需自行加密
+		biz, err := db.Seal(string(bizJSON))
+		if err != nil {
+			return nil, err
+		}
+		tech, err := db.Seal(string(techJSON))
+		if err != nil {
+			return nil, err
+		}
+		docUpdates[i] = map[string]interface{}{"business_view": biz, "technical_view": tech}
+		result.DocumentsRewritten++
+	}
+
+	shotUpdates := make([]map[string]interface{}, len(shots))
+	for i := range shots {
+		shot := &shots[i]
+		masked := RedactedScreenshot(shot, RedactBlack)
+		element := ""
+		if masked != "" && shot.ElementURL != "" {
+			var step db.RecordingStep
+			if err := db.DB.First(&step, "id = ?", shot.StepID).Error; err == nil {
+				element = ElementScreenshot(masked, &step, StepViewportWidth(&step, shot.Width))
+			}
+		}
+		if masked == "" && shot.DataURL != "" {
+			result.Dropped++
+		} else {
+			result.Destroyed++
+		}
+		// map 更新不经过模型钩子，需自行加密
+		var err error
+		if masked, err = db.Seal(masked); err != nil {
+			return nil, err
+		}
+		if element, err = db.Seal(element); err != nil {
+			return nil, err
+		}
+		shotUpdates[i] = map[string]interface{}{"data_url": masked, "element_url": element, "is_raw_deleted": true}
+	}
+
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		if err := secureDelete(tx); err != nil {
+			return err
+		}
+		for i, updates := range docUpdates {
+			if updates == nil {
+				continue
+			}
+			if err := tx.Model(&db.GeneratedDocument{}).Where("id = ?", docs[i].ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		for i, updates := range shotUpdates {
+			if err := tx.Model(&db.Screenshot{}).Where("id = ?", shots[i].ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// secureDelete SQLite 默认只把删除的内容标记为空闲页，开启 secure_delete 使被覆盖的字节清零；
+// 其他数据库不处理
+func secureDelete(tx *gorm.DB) error {
+	if tx.Dialector.Name() != "sqlite" {
+		return nil
+	}
+	return tx.Exec("PRAGMA secure_delete = ON").Error
+}
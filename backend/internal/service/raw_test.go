@@ -0,0 +1,79 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestDestroyRawScreenshots(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "销毁原图", MergeStrategy: service.MergeOff}
+	db.DB.Create(&proj)
+	sess := db.Session{ProjectID: proj.ID, Title: "办件登记"}
+	db.DB.Create(&sess)
+
+	full := pngDataURL(t, 1600, 1000)
+	step := db.RecordingStep{
+		SessionID: sess.ID, StepIndex: 1, Action: "click", PageTitle: "办件登记", TargetElement: "身份证号",
+		BBoxX: 700, BBoxY: 450, BBoxW: 100, BBoxH: 40,
+	}
+	if _, err := service.IngestStep(db.DB, service.StepInput{
+		Step: step,
+		Screenshot: &db.Screenshot{DataURL: db.LongText(full), Width: 1600,
+			MaskedRegions: service.EncodeMaskedRegions([]service.MaskRegion{{X: 720, Y: 455, Width: 40, Height: 20}})},
+	}); err != nil {
+		t.Fatalf("IngestStep: %v", err)
+	}
+
+	svc := service.NewDocService()
+	content, err := svc.BuildDocument(sess.ID)
+	if err != nil {
+		t.Fatalf("BuildDocument: %v", err)
+	}
+	doc, err := svc.SaveGeneratedDoc(sess.ID, content)
+	if err != nil {
+		t.Fatalf("SaveGeneratedDoc: %v", err)
+	}
+
+	if _, err := svc.DestroyRawScreenshots(sess.ID); !errors.Is(err, service.ErrNotApproved) {
+		t.Fatalf("expected ErrNotApproved before approval, got %v", err)
+	}
+	db.DB.Model(doc).Update("status", "approved")
+
+	res, err := svc.DestroyRawScreenshots(sess.ID)
+	if err != nil {
+		t.Fatalf("DestroyRawScreenshots: %v", err)
+	}
+	if res.Destroyed != 1 || res.Dropped != 0 || res.DocumentsRewritten != 1 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	var shot db.Screenshot
+	db.DB.First(&shot, "session_id = ?", sess.ID)
+	if !shot.IsRawDeleted || shot.DataURL == db.LongText(full) {
+		t.Fatal("expected raw screenshot replaced and flagged")
+	}
+	if r, _, _, _ := decodePNG(t, string(shot.DataURL)).At(730, 460).RGBA(); r != 0 {
+		t.Error("expected masked region burned into the stored screenshot")
+	}
+	if r, _, _, _ := decodePNG(t, string(shot.ElementURL)).At(730-430, 460-270).RGBA(); r != 0 {
+		t.Error("expected element crop regenerated from the masked screenshot")
+	}
+
+	db.DB.First(doc, "id = ?", doc.ID)
+	saved, err := svc.LoadDocument(doc)
+	if err != nil {
+		t.Fatalf("LoadDocument: %v", err)
+	}
+	if saved.TechnicalView[0].Steps[0].ScreenshotURL != string(shot.DataURL) ||
+		saved.BusinessView[0].Steps[0].ScreenshotURL != string(shot.ElementURL) {
+		t.Error("expected saved document to embed the masked screenshots")
+	}
+
+	if res, err = svc.DestroyRawScreenshots(sess.ID); err != nil || res.Destroyed != 0 || res.DocumentsRewritten != 0 {
+		t.Errorf("second run should be a no-op: %+v %v", res, err)
+	}
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// ErrEmptyRecording 录制中没有可转换的步骤
+var ErrEmptyRecording = errors.New("recording has no importable steps")
+
+// ChromeRecording Chrome DevTools Recorder 导出的 JSON
+type ChromeRecording struct {
+	Title string               `json:"title"`
+	Steps []ChromeRecorderStep `json:"steps"`
+}
+
+// ChromeRecorderStep Recorder 的单个步骤（只解析转换需要的字段）
+type ChromeRecorderStep struct {
+	Type      string            `json:"type"`
+	URL       string            `json:"url"`
+	Value     string            `json:"value"`
+	Key       string            `json:"key"`
+	Selectors []json.RawMessage `json:"selectors"` // 每项为字符串，或穿透 shadow DOM 的字符串数组
+	Frame     []int             `json:"frame"`
+	Width     int               `json:"width"` // setViewport
+	Height    int               `json:"height"`
+	X         int               `json:"x"` // scroll
+	Y         int               `json:"y"`
+
+	AssertedEvents []struct {
+		Type  string `json:"type"`
+		URL   string `json:"url"`
+		Title string `json:"title"`
+	} `json:"assertedEvents"`
+}
+
+// RecorderImport 导入结果
+type RecorderImport struct {
+	Session *db.Session `json:"session"`
+	Steps   int         `json:"steps"`
+	Skipped []string    `json:"skipped"` // 未转换的步骤类型（等待、断言、自定义步骤等），按出现顺序
+}
+
+// recorderActions Recorder 步骤类型 → 步骤操作类型
+var recorderActions = map[string]string{
+	"navigate":    "navigation",
+	"click":       "click",
+	"doubleClick": "click",
+	"change":      "input",
+	"hover":       "hover",
+	"scroll":      "scroll",
+}
+
+// ImportChromeRecording 把 Chrome Recorder 导出转换为项目下的新会话（需在事务中调用）。
+// 外部录制未经插件脱敏：密码框的值一律替换，其他输入值中的身份证号、手机号等按类别替换，
+// 页面地址中的敏感查询参数替换为 ***
+func ImportChromeRecording(tx *gorm.DB, projectID string, rec *ChromeRecording) (*RecorderImport, error) {
+	session, err := createImportedSession(tx, projectID, rec.Title)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RecorderImport{Session: session, Skipped: []string{}}
+	var pageURL, pageTitle string
+	var viewportW, viewportH int
+	held := map[string]bool{} // 已按下未松开的修饰键
+	for _, rs := range rec.Steps {
+		// 断言的导航事件给出步骤执行后的页面
+		nextURL, nextTitle := pageURL, pageTitle
+		for _, e := range rs.AssertedEvents {
+			if e.Type == "navigation" {
+				nextURL, nextTitle = sanitizePageURL(e.URL), e.Title
+			}
+		}
+
+		step := db.RecordingStep{SessionID: session.ID, PageURL: pageURL, PageTitle: pageTitle,
+			ViewportW: viewportW, ViewportH: viewportH}
+		switch rs.Type {
+		case "setViewport":
+			viewportW, viewportH = rs.Width, rs.Height
+			continue
+		case "keyDown":
+			if name, ok := modifierAliases[strings.ToLower(rs.Key)]; ok {
+				held[name] = true
+				continue
+			}
+			var mods []string
+			for _, m := range modifierOrder {
+				if held[m] {
+					mods = append(mods, m)
+				}
+			}
+			combo, err := NormalizeKeyCombo("", rs.Key, mods)
+			if err != nil {
+				result.Skipped = append(result.Skipped, rs.Type)
+				continue
+			}
+			step.Action, step.KeyCombo = ActionKeypress, combo
+			if HasModifier(combo) {
+				step.Action = ActionShortcut
+			}
+		case "keyUp":
+			if name, ok := modifierAliases[strings.ToLower(rs.Key)]; ok {
+				delete(held, name)
+			}
+			continue
+		default:
+			action, ok := recorderActions[rs.Type]
+			if !ok {
+				result.Skipped = append(result.Skipped, rs.Type)
+				continue
+			}
+			step.Action = action
+		}
+
+		applyRecorderSelectors(&step, rs.Selectors)
+		if len(rs.Frame) > 0 {
+			frames := make([]string, len(rs.Frame))
+			for i, f := range rs.Frame {
+				frames[i] = fmt.Sprintf("frames[%d]", f)
+			}
+			step.FramePath = strings.Join(frames, " > ")
+		}
+		switch rs.Type {
+		case "navigate":
+			nextURL = sanitizePageURL(rs.URL)
+			step.PageURL, step.PageTitle = nextURL, nextTitle
+			step.TargetElement = nextTitle
+			if step.TargetElement == "" {
+				step.TargetElement = nextURL
+			}
+			if session.TargetURL == "" {
+				session.TargetURL = nextURL
+			}
+		case "change":
+			step.MaskedText, step.IsMasked = maskRecordedValue(rs.Value, step)
+		case "scroll":
+			step.ScrollX, step.ScrollY = rs.X, rs.Y
+		}
+		pageURL, pageTitle = nextURL, nextTitle
+
+		if _, err := IngestStep(tx, StepInput{Step: step}); err != nil {
+			return nil, err
+		}
+		result.Steps++
+	}
+	if result.Steps == 0 {
+		return nil, ErrEmptyRecording
+	}
+	return result, finishImportedSession(tx, session)
+}
+
+// createImportedSession 为导入的录制创建会话，未提供标题时使用默认标题
+func createImportedSession(tx *gorm.DB, projectID, title string) (*db.Session, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		title = "导入的录制"
+	}
+	now := time.Now()
+	session := &db.Session{ProjectID: projectID, Title: title, Status: "recording", StartedAt: &now}
+	return session, tx.Create(session).Error
+}
+
+// finishImportedSession 步骤写入完成后标记会话结束，并重新读取（含步骤写入时更新的时长）
+func finishImportedSession(tx *gorm.DB, session *db.Session) error {
+	end := time.Now()
+	if err := tx.Model(session).Updates(db.Session{Status: "completed", EndedAt: &end, TargetURL: session.TargetURL}).Error; err != nil {
+		return err
+	}
+	return tx.First(session, "id = ?", session.ID).Error
+}
+
+// applyRecorderSelectors 从 Recorder 的候选选择器中取 CSS、XPath 与可读名称（aria / text 选择器）
+func applyRecorderSelectors(step *db.RecordingStep, selectors []json.RawMessage) {
+	for _, raw := range selectors {
+		var parts []string
+		if err := json.Unmarshal(raw, &parts); err != nil {
+			var single string
+			if json.Unmarshal(raw, &single) != nil {
+				continue
+			}
+			parts = []string{single}
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		// 穿透 shadow DOM 时取最内层
+		sel := parts[len(parts)-1]
+		switch {
+		case strings.HasPrefix(sel, "aria/"):
+			if step.AriaLabel == "" {
+				step.AriaLabel = strings.TrimPrefix(sel, "aria/")
+			}
+		case strings.HasPrefix(sel, "text/"):
+			if step.TargetElement == "" {
+				step.TargetElement = strings.TrimPrefix(sel, "text/")
+			}
+		case strings.HasPrefix(sel, "xpath/"):
+			if step.TargetXPath == "" {
+				step.TargetXPath = strings.TrimPrefix(sel, "xpath/")
+			}
+		case strings.HasPrefix(sel, "pierce/"):
+			// 与 CSS 选择器重复，忽略
+		default:
+			if step.TargetSelector == "" && len(parts) == 1 {
+				step.TargetSelector = sel
+			}
+		}
+	}
+	if step.AriaLabel != "" {
+		step.TargetElement = step.AriaLabel
+	}
+	if step.TargetElement == "" {
+		step.TargetElement = step.TargetSelector
+	}
+}
+
+// passwordHints 选择器或名称中出现以下片段时视为密码框
+var passwordHints = []string{"password", "passwd", "pwd", "密码"}
+
+// maskRecordedValue 脱敏导入的输入值，返回保存的文本与是否做过替换
+func maskRecordedValue(value string, step db.RecordingStep) (string, bool) {
+	where := strings.ToLower(step.TargetSelector + " " + step.TargetXPath + " " + step.AriaLabel + " " + step.TargetElement)
+	for _, h := range passwordHints {
+		if strings.Contains(where, h) {
+			return "******", true
+		}
+	}
+	masked := false
+	for _, d := range piiDetectors {
+		value = d.re.ReplaceAllStringFunc(value, func(m string) string {
+			masked = true
+			// 手机号规则带前后的非数字字符，只替换号码本身
+			core := strings.TrimFunc(m, func(r rune) bool { return r < '0' || r > '9' })
+			if d.category != "手机号" {
+				core = m
+			}
+			return strings.Replace(m, core, "【"+d.category+"】", 1)
+		})
+	}
+	return value, masked
+}
+
+// sanitizePageURL 去掉页面地址中的账号与敏感查询参数，保留前端路由（#/path），无法解析时丢弃
+func sanitizePageURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	raw, fragment, _ := strings.Cut(raw, "#")
+	clean, err := SanitizeRequestURL(raw)
+	if err != nil {
+		return ""
+	}
+	if fragment != "" {
+		clean += "#" + fragment
+	}
+	return clean
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// ChromeRecorderExport 导出为 Chrome DevTools Recorder 可导入的 JSON。
+// 各类步骤的必填字段不同（如 click 的 offsetX 为 0 也必须出现），步骤以键值对表示
+type ChromeRecorderExport struct {
+	Title string                   `json:"title"`
+	Steps []map[string]interface{} `json:"steps"`
+}
+
+// recorderKeys 规范化按键名 → Recorder（KeyboardEvent.key）写法，其余单字符键转小写
+var recorderKeys = map[string]string{
+	"Ctrl": "Control", "Esc": "Escape", "Space": " ",
+	"↑": "ArrowUp", "↓": "ArrowDown", "←": "ArrowLeft", "→": "ArrowRight",
+}
+
+// recorderFrame 导入的 Recorder 录制保存的 iframe 路径，如 frames[0] > frames[2]
+var recorderFrame = regexp.MustCompile(`^frames\[(\d+)\]$`)
+
+// ChromeRecordingForDocument 按文档技术视图中的步骤顺序导出 Chrome Recorder 录制
+func ChromeRecordingForDocument(doc *db.GeneratedDocument, content *GeneratedDocContent) (*ChromeRecorderExport, error) {
+	var indexes []int
+	for _, sec := range content.TechnicalView {
+		for _, s := range sec.Steps {
+			indexes = append(indexes, s.StepIndex)
+		}
+	}
+	var steps []db.RecordingStep
+	if err := db.DB.Where("session_id = ? AND step_index IN ?", doc.SessionID, indexes).Find(&steps).Error; err != nil {
+		return nil, err
+	}
+	byIndex := make(map[int]db.RecordingStep, len(steps))
+	for _, s := range steps {
+		byIndex[s.StepIndex] = s
+	}
+	ordered := make([]db.RecordingStep, 0, len(indexes))
+	for _, i := range indexes {
+		if s, ok := byIndex[i]; ok {
+			ordered = append(ordered, s)
+		}
+	}
+	return ExportChromeRecording(content.SessionTitle, ordered), nil
+}
+
+// ExportChromeRecording 把录制步骤转换为 Recorder 步骤，便于目标系统开发人员在 DevTools 中回放与调试。
+// 输入值导出脱敏后的文本；页面地址在步骤之间变化时记为上一步的导航断言，首步前补充打开页面；
+// 插件录制的 iframe 路径为选择器，无法换算为 Recorder 的 frame 序号，这类步骤不带 frame
+func ExportChromeRecording(title string, steps []db.RecordingStep) *ChromeRecorderExport {
+	out := &ChromeRecorderExport{Title: title, Steps: []map[string]interface{}{}}
+	var viewportW, viewportH int
+	var pageURL string
+	for _, s := range steps {
+		if s.ViewportW > 0 && (s.ViewportW != viewportW || s.ViewportH != viewportH) {
+			viewportW, viewportH = s.ViewportW, s.ViewportH
+			out.Steps = append(out.Steps, map[string]interface{}{
+				"type": "setViewport", "width": viewportW, "height": viewportH,
+				"deviceScaleFactor": 1, "isMobile": false, "hasTouch": false, "isLandscape": false,
+			})
+		}
+		if s.Action != "navigation" && s.PageURL != "" && s.PageURL != pageURL {
+			if len(out.Steps) == 0 || out.Steps[len(out.Steps)-1]["type"] == "setViewport" {
+				out.Steps = append(out.Steps, recorderNavigate(s.PageURL, s.PageTitle))
+			} else {
+				// 点击链接、提交表单等引起的跳转：回放时等待导航完成
+				prev := out.Steps[len(out.Steps)-1]
+				prev["assertedEvents"] = []map[string]string{{"type": "navigation", "url": s.PageURL, "title": s.PageTitle}}
+			}
+		}
+		if s.PageURL != "" {
+			pageURL = s.PageURL
+		}
+
+		frame := recorderFrames(s.FramePath)
+		withTarget := func(step map[string]interface{}) map[string]interface{} {
+			step["selectors"] = recorderSelectors(s)
+			if frame != nil {
+				step["frame"] = frame
+			}
+			return step
+		}
+		switch {
+		case s.Action == "navigation":
+			url := firstNonEmpty(s.PageURL, s.TargetElement)
+			out.Steps = append(out.Steps, recorderNavigate(url, s.PageTitle))
+			pageURL = url
+		case IsKeyAction(s.Action):
+			if s.KeyCombo != "" {
+				out.Steps = append(out.Steps, recorderKeySteps(s.KeyCombo)...)
+			}
+		case s.Action == "scroll":
+			out.Steps = append(out.Steps, map[string]interface{}{"type": "scroll", "x": s.ScrollX, "y": s.ScrollY})
+		case len(recorderSelectors(s)) == 0:
+			// 没有任何定位信息的步骤无法回放
+		case s.Action == "input", s.Action == "select":
+			out.Steps = append(out.Steps, withTarget(map[string]interface{}{"type": "change", "value": s.MaskedText}))
+		case s.Action == "hover":
+			out.Steps = append(out.Steps, withTarget(map[string]interface{}{"type": "hover"}))
+		default:
+			offsetX, offsetY := s.BBoxW/2, s.BBoxH/2
+			if s.BBoxW > 0 && s.ClickX >= s.BBoxX && s.ClickX <= s.BBoxX+s.BBoxW && s.ClickY >= s.BBoxY && s.ClickY <= s.BBoxY+s.BBoxH {
+				offsetX, offsetY = s.ClickX-s.BBoxX, s.ClickY-s.BBoxY
+			}
+			out.Steps = append(out.Steps, withTarget(map[string]interface{}{"type": "click", "offsetX": offsetX, "offsetY": offsetY}))
+		}
+	}
+	return out
+}
+
+func recorderNavigate(url, title string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "navigate", "url": url,
+		"assertedEvents": []map[string]string{{"type": "navigation", "url": url, "title": title}},
+	}
+}
+
+// recorderSelectors Recorder 的候选选择器：aria 名称、CSS、XPath，以及与以上都不同的元素文字
+func recorderSelectors(s db.RecordingStep) [][]string {
+	var out [][]string
+	if s.AriaLabel != "" {
+		out = append(out, []string{"aria/" + s.AriaLabel})
+	}
+	if s.TargetSelector != "" {
+		out = append(out, []string{s.TargetSelector})
+	}
+	if s.TargetXPath != "" {
+		out = append(out, []string{"xpath/" + s.TargetXPath})
+	}
+	if t := s.TargetElement; t != "" && t != s.AriaLabel && t != s.TargetSelector && t != s.TargetXPath {
+		out = append(out, []string{"text/" + s.TargetElement})
+	}
+	return out
+}
+
+// recorderFrames 把 frames[N] 形式的 iframe 路径换算为 Recorder 的 frame 序号，其他写法返回 nil
+func recorderFrames(path string) []int {
+	if path == "" {
+		return nil
+	}
+	var frames []int
+	for _, part := range strings.Split(path, " > ") {
+		m := recorderFrame.FindStringSubmatch(strings.TrimSpace(part))
+		if m == nil {
+			return nil
+		}
+		n, _ := strconv.Atoi(m[1])
+		frames = append(frames, n)
+	}
+	return frames
+}
+
+// recorderKeySteps 组合键按下修饰键、主键，再倒序松开，如 Ctrl+S → Control↓ s↓ s↑ Control↑
+func recorderKeySteps(combo string) []map[string]interface{} {
+	parts := strings.Split(combo, "+")
+	if strings.HasSuffix(combo, "++") {
+		parts = append(parts[:len(parts)-2], "+")
+	}
+	keys := make([]string, len(parts))
+	for i, p := range parts {
+		if k, ok := recorderKeys[p]; ok {
+			keys[i] = k
+		} else if len([]rune(p)) == 1 {
+			keys[i] = strings.ToLower(p)
+		} else {
+			keys[i] = p
+		}
+	}
+	steps := make([]map[string]interface{}, 0, 2*len(keys))
+	for _, k := range keys {
+		steps = append(steps, map[string]interface{}{"type": "keyDown", "key": k})
+	}
+	for i := len(keys) - 1; i >= 0; i-- {
+		steps = append(steps, map[string]interface{}{"type": "keyUp", "key": keys[i]})
+	}
+	return steps
+}
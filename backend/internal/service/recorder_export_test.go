@@ -0,0 +1,74 @@
+package service_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestExportChromeRecording_RoundTrip(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "导出"}
+	db.DB.Create(&proj)
+	var rec service.ChromeRecording
+	if err := json.Unmarshal([]byte(chromeRecording), &rec); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := service.ImportChromeRecording(db.DB, proj.ID, &rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var steps []db.RecordingStep
+	db.DB.Where("session_id = ?", imported.Session.ID).Order("step_index").Find(&steps)
+
+	exported := service.ExportChromeRecording("登录并缴费", steps)
+	var types []string
+	for _, s := range exported.Steps {
+		types = append(types, s["type"].(string))
+	}
+	want := []string{"setViewport", "navigate", "click", "change", "change", "keyDown", "keyUp", "click",
+		"keyDown", "keyDown", "keyUp", "keyUp", "scroll"}
+	if len(types) != len(want) {
+		t.Fatalf("unexpected steps %v", types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("unexpected steps %v", types)
+		}
+	}
+	menu := exported.Steps[7]
+	if frame := menu["frame"].([]int); len(frame) != 1 || frame[0] != 0 {
+		t.Errorf("expected frame [0], got %v", menu["frame"])
+	}
+	// 点击菜单后页面跳转，记为该步骤的导航断言
+	if events, ok := menu["assertedEvents"].([]map[string]string); !ok || events[0]["url"] != "https://gov.example.com/pay" {
+		t.Errorf("expected a navigation assertion on the menu click, got %v", menu["assertedEvents"])
+	}
+	if exported.Steps[3]["value"] != "【手机号】" || exported.Steps[8]["key"] != "Control" || exported.Steps[9]["key"] != "s" {
+		t.Errorf("unexpected values or keys: %v / %v", exported.Steps[3], exported.Steps[8:10])
+	}
+
+	// 导出结果可以再导入为相同的步骤
+	data, _ := json.Marshal(exported)
+	var again service.ChromeRecording
+	if err := json.Unmarshal(data, &again); err != nil {
+		t.Fatal(err)
+	}
+	reimported, err := service.ImportChromeRecording(db.DB, proj.ID, &again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var steps2 []db.RecordingStep
+	db.DB.Where("session_id = ?", reimported.Session.ID).Order("step_index").Find(&steps2)
+	if len(steps2) != len(steps) {
+		t.Fatalf("expected %d steps after round trip, got %d", len(steps), len(steps2))
+	}
+	for i := range steps {
+		a, b := steps[i], steps2[i]
+		if a.Action != b.Action || a.TargetElement != b.TargetElement || a.KeyCombo != b.KeyCombo || a.FramePath != b.FramePath || a.PageURL != b.PageURL {
+			t.Errorf("step %d changed: %s %q %q %q → %s %q %q %q", i+1, a.Action, a.TargetElement, a.KeyCombo, a.PageURL, b.Action, b.TargetElement, b.KeyCombo, b.PageURL)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package service_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+const chromeRecording = `{
+  "title": "登录并缴费",
+  "steps": [
+    {"type": "setViewport", "width": 1280, "height": 720, "deviceScaleFactor": 1},
+    {"type": "navigate", "url": "https://gov.example.com/login?token=abc#/home",
+     "assertedEvents": [{"type": "navigation", "url": "https://gov.example.com/login?token=abc#/home", "title": "统一登录"}]},
+    {"type": "click", "selectors": [["aria/账号"], ["#username"], ["xpath///*[@id=\"username\"]"], ["pierce/#username"]], "offsetX": 10, "offsetY": 5},
+    {"type": "change", "value": "13812345678", "selectors": [["#username"]]},
+    {"type": "change", "value": "secret", "selectors": [["input[type=password]"]]},
+    {"type": "keyDown", "key": "Enter"},
+    {"type": "keyUp", "key": "Enter"},
+    {"type": "waitForElement", "selectors": [["#menu"]]},
+    {"type": "click", "selectors": [["text/缴费"], ["div.menu > a"]], "frame": [0],
+     "assertedEvents": [{"type": "navigation", "url": "https://gov.example.com/pay", "title": "缴费"}]},
+    {"type": "keyDown", "key": "Control"},
+    {"type": "keyDown", "key": "s"},
+    {"type": "keyUp", "key": "s"},
+    {"type": "keyUp", "key": "Control"},
+    {"type": "scroll", "x": 0, "y": 600}
+  ]
+}`
+
+func TestImportChromeRecording(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "导入"}
+	db.DB.Create(&proj)
+	var rec service.ChromeRecording
+	if err := json.Unmarshal([]byte(chromeRecording), &rec); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := service.ImportChromeRecording(db.DB, proj.ID, &rec)
+	if err != nil {
+		t.Fatalf("ImportChromeRecording: %v", err)
+	}
+	if result.Steps != 8 || len(result.Skipped) != 1 || result.Skipped[0] != "waitForElement" {
+		t.Fatalf("unexpected result: %d steps, skipped %v", result.Steps, result.Skipped)
+	}
+	if s := result.Session; s.Title != "登录并缴费" || s.Status != "completed" || s.TargetURL != "https://gov.example.com/login?token=%2A%2A%2A#/home" {
+		t.Errorf("unexpected session %+v", s)
+	}
+
+	var steps []db.RecordingStep
+	db.DB.Where("session_id = ?", result.Session.ID).Order("step_index").Find(&steps)
+	want := []struct{ action, target, text, combo string }{
+		{"navigation", "统一登录", "", ""},
+		{"click", "账号", "", ""},
+		{"input", "#username", "【手机号】", ""},
+		{"input", "input[type=password]", "******", ""},
+		{service.ActionKeypress, "", "", "Enter"},
+		{"click", "缴费", "", ""},
+		{service.ActionShortcut, "", "", "Ctrl+S"},
+		{"scroll", "", "", ""},
+	}
+	for i, w := range want {
+		s := steps[i]
+		if s.Action != w.action || s.TargetElement != w.target || s.MaskedText != w.text || s.KeyCombo != w.combo {
+			t.Errorf("step %d: got %s %q %q %q, want %+v", i+1, s.Action, s.TargetElement, s.MaskedText, s.KeyCombo, w)
+		}
+	}
+	if s := steps[1]; s.TargetSelector != "#username" || s.TargetXPath != `//*[@id="username"]` || s.ViewportW != 1280 || s.PageTitle != "统一登录" {
+		t.Errorf("selectors and page not carried over: %+v", s)
+	}
+	// 点击菜单后导航到缴费页，后续步骤属于新页面
+	if steps[5].FramePath != "frames[0]" || steps[5].PageTitle != "统一登录" || steps[6].PageURL != "https://gov.example.com/pay" {
+		t.Errorf("unexpected frame or page: %+v / %+v", steps[5], steps[6])
+	}
+	if !steps[2].IsMasked || steps[2].InputValue != "" {
+		t.Errorf("recorded values must be masked: %+v", steps[2])
+	}
+
+	_, err = service.ImportChromeRecording(db.DB, proj.ID, &service.ChromeRecording{Steps: []service.ChromeRecorderStep{{Type: "waitForExpression"}}})
+	if !errors.Is(err, service.ErrEmptyRecording) {
+		t.Errorf("expected ErrEmptyRecording, got %v", err)
+	}
+}
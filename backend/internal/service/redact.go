@@ -0,0 +1,205 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// MaskRegion 截图中需遮蔽的区域（视口 CSS 像素，与步骤边界框同一坐标系）
+type MaskRegion struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// 遮蔽样式
+const (
+	RedactBlack    = "black"    // 黑色实心矩形
+	RedactPixelate = "pixelate" // 粗马赛克（块大小足以让文字不可辨认）
+)
+
+// RedactStyles 支持的遮蔽样式
+var RedactStyles = []string{RedactBlack, RedactPixelate}
+
+const pixelateBlock = 16
+
+// ParseMaskedRegions 解析 Screenshot.MaskedRegions（JSON 数组），空串返回 nil
+func ParseMaskedRegions(raw string) ([]MaskRegion, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var regions []MaskRegion
+	if err := json.Unmarshal([]byte(raw), &regions); err != nil {
+		return nil, err
+	}
+	return regions, nil
+}
+
+// EncodeMaskedRegions 序列化遮蔽区域，忽略空区域；没有区域时返回空串
+func EncodeMaskedRegions(regions []MaskRegion) string {
+	valid := make([]MaskRegion, 0, len(regions))
+	for _, r := range regions {
+		if r.Width > 0 && r.Height > 0 {
+			valid = append(valid, r)
+		}
+	}
+	if len(valid) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(valid)
+	return string(data)
+}
+
+// RedactDataURL 将遮蔽区域烧录进截图；坐标按 viewportWidth 换算为图片像素（为 0 时不缩放）。
+// 没有区域时原样返回；JPEG 截图仍输出 JPEG，其余输出 PNG
+func RedactDataURL(dataURL string, regions []MaskRegion, viewportWidth int, style string) (string, error) {
+	if len(regions) == 0 || dataURL == "" {
+		return dataURL, nil
+	}
+	mime, data, err := ParseDataURL(dataURL)
+	if err != nil {
+		return "", err
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	bounds := src.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(out, out.Bounds(), src, bounds.Min, draw.Src)
+
+	scale := 1.0
+	if viewportWidth > 0 {
+		scale = float64(bounds.Dx()) / float64(viewportWidth)
+	}
+	px := func(v int) int { return int(float64(v) * scale) }
+	for _, r := range regions {
+		rect := image.Rect(px(r.X), px(r.Y), px(r.X+r.Width)+1, px(r.Y+r.Height)+1).Intersect(out.Bounds())
+		if rect.Empty() {
+			continue
+		}
+		if style == RedactPixelate {
+			pixelate(out, rect)
+		} else {
+			draw.Draw(out, rect, image.NewUniform(color.Black), image.Point{}, draw.Src)
+		}
+	}
+
+	var buf bytes.Buffer
+	if mime == "image/jpeg" {
+		err = jpeg.Encode(&buf, out, &jpeg.Options{Quality: 85})
+	} else {
+		mime = "image/png"
+		err = png.Encode(&buf, out)
+	}
+	if err != nil {
+		return "", err
+	}
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// pixelate 以块平均色填充区域
+func pixelate(img *image.RGBA, r image.Rectangle) {
+	for by := r.Min.Y; by < r.Max.Y; by += pixelateBlock {
+		for bx := r.Min.X; bx < r.Max.X; bx += pixelateBlock {
+			block := image.Rect(bx, by, bx+pixelateBlock, by+pixelateBlock).Intersect(r)
+			var sr, sg, sb, n uint32
+			for y := block.Min.Y; y < block.Max.Y; y++ {
+				for x := block.Min.X; x < block.Max.X; x++ {
+					c := img.RGBAAt(x, y)
+					sr, sg, sb, n = sr+uint32(c.R), sg+uint32(c.G), sb+uint32(c.B), n+1
+				}
+			}
+			avg := color.RGBA{R: uint8(sr / n), G: uint8(sg / n), B: uint8(sb / n), A: 255}
+			draw.Draw(img, block, image.NewUniform(avg), image.Point{}, draw.Src)
+		}
+	}
+}
+
+// RedactedScreenshot 返回烧录遮蔽区域后的整屏截图；区域无法解析或图片无法处理时返回空串（宁可缺图也不泄露原图）
+func RedactedScreenshot(shot *db.Screenshot, style string) string {
+	regions, err := ParseMaskedRegions(shot.MaskedRegions)
+	if err != nil {
+		return ""
+	}
+	out, err := RedactDataURL(string(shot.DataURL), regions, shot.Width, style)
+	if err != nil {
+		return ""
+	}
+	return out
+}
+
+// RedactContent 返回文档副本，其中带遮蔽区域的截图已烧录遮蔽；局部图从遮蔽后的整屏截图重新裁剪。
+// 原文档不被修改
+func (s *DocService) RedactContent(content *GeneratedDocContent, style string) *GeneratedDocContent {
+	shots := map[string]*db.Screenshot{}
+	load := func(id string) *db.Screenshot {
+		if shot, ok := shots[id]; ok {
+			return shot
+		}
+		var shot db.Screenshot
+		if err := db.DB.First(&shot, "id = ?", id).Error; err != nil || shot.MaskedRegions == "" {
+			shots[id] = nil
+			return nil
+		}
+		shots[id] = &shot
+		return &shot
+	}
+	rewrite := func(sections []DocSection) []DocSection {
+		out := make([]DocSection, len(sections))
+		for i, sec := range sections {
+			sec.Steps = append([]DocStep(nil), sec.Steps...)
+			for j := range sec.Steps {
+				st := &sec.Steps[j]
+				if st.ScreenshotID == "" || st.ScreenshotURL == "" {
+					continue
+				}
+				shot := load(st.ScreenshotID)
+				if shot == nil {
+					continue
+				}
+				st.ScreenshotURL = redactDocImage(st.ScreenshotURL, shot, style)
+			}
+			out[i] = sec
+		}
+		return out
+	}
+	out := *content
+	out.BusinessView = rewrite(content.BusinessView)
+	out.TechnicalView = rewrite(content.TechnicalView)
+	return &out
+}
+
+// redactDocImage 遮蔽文档中引用的一张截图：局部图从遮蔽后的整屏截图重新裁剪，
+// 整屏截图（包括生成文档后又被替换的旧版本）按同一坐标系直接遮蔽
+func redactDocImage(url string, shot *db.Screenshot, style string) string {
+	regions, err := ParseMaskedRegions(shot.MaskedRegions)
+	if err != nil {
+		return ""
+	}
+	if url != string(shot.ElementURL) {
+		out, err := RedactDataURL(url, regions, shot.Width, style)
+		if err != nil {
+			return ""
+		}
+		return out
+	}
+	full, err := RedactDataURL(string(shot.DataURL), regions, shot.Width, style)
+	if err != nil {
+		return ""
+	}
+	var step db.RecordingStep
+	if err := db.DB.First(&step, "id = ?", shot.StepID).Error; err != nil {
+		return ""
+	}
+	return ElementScreenshot(full, &step, StepViewportWidth(&step, shot.Width))
+}
@@ -0,0 +1,88 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestRedactDataURL(t *testing.T) {
+	full := pngDataURL(t, 1600, 1000)
+	// 视口 800 CSS 像素，截图为 2 倍像素比
+	regions := []service.MaskRegion{{X: 100, Y: 50, Width: 100, Height: 20}}
+
+	out, err := service.RedactDataURL(full, regions, 800, service.RedactBlack)
+	if err != nil {
+		t.Fatalf("RedactDataURL: %v", err)
+	}
+	img := decodePNG(t, out)
+	if r, g, b, _ := img.At(300, 120).RGBA(); r != 0 || g != 0 || b != 0 {
+		t.Error("expected black pixel inside the scaled region")
+	}
+	if r, _, _, _ := img.At(150, 120).RGBA(); r != 0xffff {
+		t.Error("expected pixels outside the region to stay untouched")
+	}
+
+	if same, _ := service.RedactDataURL(full, nil, 800, service.RedactBlack); same != full {
+		t.Error("expected screenshot without regions to be returned as-is")
+	}
+	if _, err := service.RedactDataURL("not a data url", regions, 800, service.RedactBlack); err == nil {
+		t.Error("expected error for malformed data URL")
+	}
+}
+
+func TestEncodeMaskedRegions(t *testing.T) {
+	raw := service.EncodeMaskedRegions([]service.MaskRegion{
+		{X: 1, Y: 2, Width: 3, Height: 4},
+		{X: 5, Y: 6, Width: 0, Height: 10}, // 空区域被忽略
+	})
+	regions, err := service.ParseMaskedRegions(raw)
+	if err != nil || len(regions) != 1 || regions[0].Width != 3 {
+		t.Fatalf("unexpected round trip: %q %v %v", raw, regions, err)
+	}
+	if service.EncodeMaskedRegions(nil) != "" {
+		t.Error("expected empty string when there are no regions")
+	}
+}
+
+func TestRedactContent(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "烧录遮蔽", MergeStrategy: service.MergeOff}
+	db.DB.Create(&proj)
+	sess := db.Session{ProjectID: proj.ID, Title: "办件登记"}
+	db.DB.Create(&sess)
+
+	full := pngDataURL(t, 1600, 1000)
+	masked := service.EncodeMaskedRegions([]service.MaskRegion{{X: 720, Y: 455, Width: 40, Height: 20}})
+	step := db.RecordingStep{
+		SessionID: sess.ID, StepIndex: 1, Action: "click", PageTitle: "办件登记", TargetElement: "身份证号",
+		BBoxX: 700, BBoxY: 450, BBoxW: 100, BBoxH: 40,
+	}
+	if _, err := service.IngestStep(db.DB, service.StepInput{
+		Step:       step,
+		Screenshot: &db.Screenshot{DataURL: db.LongText(full), Width: 1600, MaskedRegions: masked},
+	}); err != nil {
+		t.Fatalf("IngestStep: %v", err)
+	}
+
+	svc := service.NewDocService()
+	content, err := svc.BuildDocument(sess.ID)
+	if err != nil {
+		t.Fatalf("BuildDocument: %v", err)
+	}
+	redacted := svc.RedactContent(content, service.RedactBlack)
+
+	if content.TechnicalView[0].Steps[0].ScreenshotURL != full {
+		t.Error("original content must not be modified")
+	}
+	fullImg := decodePNG(t, redacted.TechnicalView[0].Steps[0].ScreenshotURL)
+	if r, _, _, _ := fullImg.At(730, 460).RGBA(); r != 0 {
+		t.Error("expected masked region burned into the full screenshot")
+	}
+	// 局部图裁剪起点为 (430,270)，遮蔽区域应同样被烧录
+	elemImg := decodePNG(t, redacted.BusinessView[0].Steps[0].ScreenshotURL)
+	if r, _, _, _ := elemImg.At(730-430, 460-270).RGBA(); r != 0 {
+		t.Error("expected masked region burned into the element crop")
+	}
+}
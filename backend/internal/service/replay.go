@@ -0,0 +1,401 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gpilot/backend/internal/config"
+	"github.com/gpilot/backend/internal/db"
+)
+
+// ErrReplayDisabled 未配置回放浏览器（replay.chrome_path）
+var ErrReplayDisabled = errors.New("replay is not configured")
+
+// ErrReplayRunning 会话已有进行中的回放
+var ErrReplayRunning = errors.New("a replay of this session is already running")
+
+// 回放记录状态（running | completed | failed）与步骤结果（passed | failed | skipped）
+const (
+	ReplayRunning   = "running"
+	ReplayCompleted = "completed"
+	ReplayFailed    = "failed"
+	ReplayPassed    = "passed"
+	ReplaySkipped   = "skipped"
+)
+
+// 回放方式
+const (
+	ReplayModeReplay = "replay" // 执行步骤并截取新截图
+	ReplayModeVerify = "verify" // 只打开步骤所在页面，检查选择器与 XPath 是否仍能找到元素
+)
+
+// verifyWait 校验选择器时等待元素出现的最长时间（不超过单步超时）
+const verifyWait = 5 * time.Second
+
+// ReplayAction 交给浏览器执行的一个操作，由录制步骤转换
+type ReplayAction struct {
+	Action   string
+	URL      string // 导航地址
+	Selector string // CSS 选择器，优先于 XPath
+	XPath    string
+	Text     string // 输入的文本（脱敏后的占位内容）或下拉选项
+	KeyCombo string
+	ScrollX  int
+	ScrollY  int
+}
+
+// ReplayBrowser 回放使用的浏览器页面
+type ReplayBrowser interface {
+	// WaitFor 等待操作的目标元素出现，导航、滚动与按键没有目标时直接返回
+	WaitFor(ctx context.Context, a ReplayAction) error
+	Do(ctx context.Context, a ReplayAction) error
+	Screenshot(ctx context.Context) ([]byte, error)
+	// Exists 在 ctx 结束前反复查找元素，xpath 为 true 时 selector 是 XPath；找不到时返回 false 而不是错误
+	Exists(ctx context.Context, selector string, xpath bool) (bool, error)
+	Close()
+}
+
+// ReplayEngine 启动回放浏览器，视口为录制时的尺寸
+type ReplayEngine interface {
+	Open(width, height int) (ReplayBrowser, error)
+}
+
+var replayState struct {
+	sync.RWMutex
+	engine  ReplayEngine
+	timeout time.Duration
+}
+
+// replayStart 串行化“检查是否在回放 → 创建回放记录”
+var replayStart sync.Mutex
+
+// replaySlots 同时进行的回放数上限，每个回放占用一个浏览器进程
+var replaySlots = make(chan struct{}, 2)
+
+// ConfigureReplay 按配置启用 Chrome 回放，未配置浏览器路径时关闭
+func ConfigureReplay(cfg config.ReplayConfig) {
+	if cfg.ChromePath == "" {
+		SetReplayEngine(nil, 0)
+		return
+	}
+	SetReplayEngine(ChromeEngine{ExecPath: cfg.ChromePath}, cfg.StepTimeout)
+}
+
+// SetReplayEngine 替换回放引擎，nil 关闭回放
+func SetReplayEngine(engine ReplayEngine, stepTimeout time.Duration) {
+	replayState.Lock()
+	defer replayState.Unlock()
+	replayState.engine, replayState.timeout = engine, stepTimeout
+}
+
+// ReplayEnabled 是否已启用回放
+func ReplayEnabled() bool {
+	replayState.RLock()
+	defer replayState.RUnlock()
+	return replayState.engine != nil
+}
+
+// FailInterruptedReplays 把服务重启前未结束的回放标记为失败，返回处理的记录数
+func FailInterruptedReplays() (int64, error) {
+	now := time.Now()
+	res := db.DB.Model(&db.ReplayRun{}).Where("status = ?", ReplayRunning).
+		Updates(db.ReplayRun{Status: ReplayFailed, Error: "interrupted by server restart", FinishedAt: &now})
+	return res.RowsAffected, res.Error
+}
+
+// StartReplay 在后台用无头浏览器按顺序回放会话的录制步骤并截取新截图，返回新建的回放记录。
+// baseURL 非空时替换录制地址的协议与主机（如回放到测试环境）；输入步骤填写脱敏后的文本
+func StartReplay(sessionID string, baseURL *url.URL) (*db.ReplayRun, error) {
+	return startReplay(sessionID, baseURL, ReplayModeReplay)
+}
+
+// StartVerification 在后台逐步打开录制步骤所在的页面，检查 TargetSelector / TargetXPath 是否仍能找到元素，
+// 并在步骤上记录 selector_stale 与校验时间；不执行点击、输入等操作，也不截图
+func StartVerification(sessionID string, baseURL *url.URL) (*db.ReplayRun, error) {
+	return startReplay(sessionID, baseURL, ReplayModeVerify)
+}
+
+// startReplay 创建回放记录并在后台执行，同一会话同时只有一个回放或校验
+func startReplay(sessionID string, baseURL *url.URL, mode string) (*db.ReplayRun, error) {
+	replayState.RLock()
+	engine, timeout := replayState.engine, replayState.timeout
+	replayState.RUnlock()
+	if engine == nil {
+		return nil, ErrReplayDisabled
+	}
+
+	replayStart.Lock()
+	defer replayStart.Unlock()
+	var running int64
+	if err := db.DB.Model(&db.ReplayRun{}).Where("session_id = ? AND status = ?", sessionID, ReplayRunning).
+		Count(&running).Error; err != nil {
+		return nil, err
+	}
+	if running > 0 {
+		return nil, ErrReplayRunning
+	}
+	var steps []db.RecordingStep
+	if err := db.DB.Where("session_id = ?", sessionID).Order("step_index").Find(&steps).Error; err != nil {
+		return nil, err
+	}
+	run := &db.ReplayRun{SessionID: sessionID, Mode: mode, Status: ReplayRunning, Total: len(steps)}
+	if baseURL != nil {
+		run.BaseURL = baseURL.String()
+	}
+	if err := db.DB.Create(run).Error; err != nil {
+		return nil, err
+	}
+	go runReplay(engine, timeout, *run, steps, baseURL)
+	return run, nil
+}
+
+// runReplay 执行回放：逐步等待目标、执行操作、截图并保存结果，单步失败不影响后续步骤
+func runReplay(engine ReplayEngine, timeout time.Duration, run db.ReplayRun, steps []db.RecordingStep, base *url.URL) {
+	replaySlots <- struct{}{}
+	defer func() { <-replaySlots }()
+
+	width, height := 1280, 720
+	for _, s := range steps {
+		if s.ViewportW > 0 && s.ViewportH > 0 {
+			width, height = s.ViewportW, s.ViewportH
+			break
+		}
+	}
+	browser, err := engine.Open(width, height)
+	if err != nil {
+		finishReplay(&run, err)
+		return
+	}
+	defer browser.Close()
+
+	p := &replayer{browser: browser, timeout: timeout, base: base}
+	for _, s := range steps {
+		result := db.ReplayStep{RunID: run.ID, SessionID: run.SessionID, StepID: s.ID, StepIndex: s.StepIndex}
+		start := time.Now()
+		if run.Mode == ReplayModeVerify {
+			p.verify(s, &result)
+		} else {
+			p.replay(s, &result)
+		}
+		switch result.Status {
+		case ReplayPassed:
+			run.Passed++
+		case ReplayFailed:
+			run.Failed++
+		default:
+			run.Skipped++
+		}
+		result.DurationMS = time.Since(start).Milliseconds()
+		if err := db.DB.Create(&result).Error; err != nil {
+			finishReplay(&run, err)
+			return
+		}
+		db.DB.Model(&db.ReplayRun{}).Where("id = ?", run.ID).
+			Updates(map[string]interface{}{"passed": run.Passed, "failed": run.Failed, "skipped": run.Skipped})
+	}
+	finishReplay(&run, nil)
+}
+
+// replayer 一次回放中的浏览器与当前页面
+type replayer struct {
+	browser ReplayBrowser
+	timeout time.Duration
+	base    *url.URL
+	opened  bool
+	pageURL string // 校验时当前打开的页面
+}
+
+// replay 执行一个步骤；首个操作不是导航时先打开其所在页面
+func (p *replayer) replay(s db.RecordingStep, result *db.ReplayStep) {
+	a, reason := replayAction(s, p.base)
+	if reason != "" {
+		result.Status, result.Error = ReplaySkipped, reason
+		return
+	}
+	var err error
+	if !p.opened && a.Action != "navigation" && s.PageURL != "" {
+		open := ReplayAction{Action: "navigation", URL: rebaseURL(s.PageURL, p.base)}
+		err = withStepTimeout(p.timeout, func(ctx context.Context) error { return p.browser.Do(ctx, open) })
+	}
+	if err == nil {
+		err = replayStep(p.browser, p.timeout, a, result)
+	}
+	p.opened = true
+	if err != nil {
+		result.Status, result.Error = ReplayFailed, err.Error()
+		return
+	}
+	result.Status = ReplayPassed
+}
+
+// verify 校验一个步骤的选择器：需要时打开步骤所在页面，逐个查找录制的 CSS 选择器与 XPath，
+// 任一找不到即标记步骤失效；页面打不开时只记录失败，不改动步骤的失效标记
+func (p *replayer) verify(s db.RecordingStep, result *db.ReplayStep) {
+	switch {
+	case s.FramePath != "":
+		result.Status, result.Error = ReplaySkipped, "steps inside iframes are not supported"
+		return
+	case s.TargetSelector == "" && s.TargetXPath == "":
+		result.Status, result.Error = ReplaySkipped, "no selector or XPath"
+		return
+	case s.PageURL == "":
+		result.Status, result.Error = ReplaySkipped, "no page URL"
+		return
+	}
+	if pageURL := rebaseURL(s.PageURL, p.base); pageURL != p.pageURL {
+		open := ReplayAction{Action: "navigation", URL: pageURL}
+		if err := withStepTimeout(p.timeout, func(ctx context.Context) error { return p.browser.Do(ctx, open) }); err != nil {
+			p.pageURL = ""
+			result.Status, result.Error = ReplayFailed, fmt.Sprintf("open %s: %v", pageURL, err)
+			return
+		}
+		p.pageURL = pageURL
+	}
+
+	wait := verifyWait
+	if p.timeout > 0 && p.timeout < wait {
+		wait = p.timeout
+	}
+	var missing []string
+	for _, loc := range []struct {
+		label, value string
+		xpath        bool
+	}{{"selector", s.TargetSelector, false}, {"XPath", s.TargetXPath, true}} {
+		if loc.value == "" {
+			continue
+		}
+		var found bool
+		err := withStepTimeout(wait, func(ctx context.Context) (err error) {
+			found, err = p.browser.Exists(ctx, loc.value, loc.xpath)
+			return err
+		})
+		if err != nil {
+			result.Status, result.Error = ReplayFailed, fmt.Sprintf("check %s %s: %v", loc.label, loc.value, err)
+			return
+		}
+		if !found {
+			missing = append(missing, loc.label+" "+loc.value)
+		}
+	}
+
+	now := time.Now()
+	if err := db.DB.Model(&db.RecordingStep{}).Where("id = ?", s.ID).
+		Updates(map[string]interface{}{"selector_stale": len(missing) > 0, "selector_checked_at": now}).Error; err != nil {
+		result.Status, result.Error = ReplayFailed, err.Error()
+		return
+	}
+	if len(missing) > 0 {
+		result.Status, result.Error = ReplayFailed, "not found: "+strings.Join(missing, "; ")
+		return
+	}
+	result.Status = ReplayPassed
+}
+
+// replayStep 等待目标、执行操作并截图：点击、悬停与按键截取操作前的画面（与录制截图一致，标出要操作的位置），
+// 其余操作截取操作后的画面；操作失败时仍尽量截取当时的画面，便于排查
+func replayStep(browser ReplayBrowser, timeout time.Duration, a ReplayAction, result *db.ReplayStep) error {
+	capture := func(ctx context.Context) error {
+		data, err := browser.Screenshot(ctx)
+		if err != nil {
+			return fmt.Errorf("screenshot: %w", err)
+		}
+		shot, err := ScreenshotFromBytes(data)
+		if err != nil {
+			return fmt.Errorf("screenshot: %w", err)
+		}
+		result.DataURL, result.Width, result.Height = shot.DataURL, shot.Width, shot.Height
+		return nil
+	}
+	err := withStepTimeout(timeout, func(ctx context.Context) error {
+		if err := browser.WaitFor(ctx, a); err != nil {
+			return err
+		}
+		switch a.Action {
+		case "click", "hover", ActionKeypress, ActionShortcut:
+			if err := capture(ctx); err != nil {
+				return err
+			}
+			return browser.Do(ctx, a)
+		}
+		if err := browser.Do(ctx, a); err != nil {
+			return err
+		}
+		return capture(ctx)
+	})
+	if err != nil && result.DataURL == "" {
+		_ = withStepTimeout(timeout, capture)
+	}
+	return err
+}
+
+func withStepTimeout(timeout time.Duration, f func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return f(ctx)
+}
+
+func finishReplay(run *db.ReplayRun, err error) {
+	now := time.Now()
+	run.Status, run.FinishedAt = ReplayCompleted, &now
+	if err != nil {
+		run.Status, run.Error = ReplayFailed, err.Error()
+		log.Printf("replay %s of session %s failed: %v", run.ID, run.SessionID, err)
+	}
+	db.DB.Model(&db.ReplayRun{}).Where("id = ?", run.ID).Updates(map[string]interface{}{
+		"status": run.Status, "error": run.Error, "finished_at": now,
+		"passed": run.Passed, "failed": run.Failed, "skipped": run.Skipped,
+	})
+}
+
+// replayAction 把录制步骤转换为回放操作，无法回放时返回跳过原因
+func replayAction(s db.RecordingStep, base *url.URL) (ReplayAction, string) {
+	a := ReplayAction{Action: s.Action, Selector: s.TargetSelector, XPath: s.TargetXPath}
+	switch {
+	case s.DuplicateOf != "":
+		// 重复提交的步骤再执行一次会重复提交
+		return a, "duplicate of an earlier step"
+	case s.FramePath != "":
+		return a, "steps inside iframes are not supported"
+	}
+	switch s.Action {
+	case "navigation":
+		a.URL = rebaseURL(s.PageURL, base)
+		if a.URL == "" {
+			return a, "no page URL"
+		}
+	case "click", "input", "select", "hover":
+		if a.Selector == "" && a.XPath == "" {
+			return a, "no selector or XPath"
+		}
+		a.Text = s.MaskedText
+	case ActionKeypress, ActionShortcut:
+		if s.KeyCombo == "" {
+			return a, "no key"
+		}
+		a.KeyCombo = s.KeyCombo
+	case "scroll":
+		a.ScrollX, a.ScrollY = s.ScrollX, s.ScrollY
+	default:
+		return a, fmt.Sprintf("action %q is not supported", s.Action)
+	}
+	return a, ""
+}
+
+// rebaseURL 用 base 的协议与主机替换录制地址中的协议与主机，base 为 nil 时原样返回
+func rebaseURL(raw string, base *url.URL) string {
+	if base == nil || raw == "" {
+		return raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	u.Scheme, u.Host, u.User = base.Scheme, base.Host, nil
+	return u.String()
+}
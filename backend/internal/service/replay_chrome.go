@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/input"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/kb"
+)
+
+// replaySettle 点击、按键等操作后等待页面响应（跳转、弹窗、异步渲染）的时间
+const replaySettle = 500 * time.Millisecond
+
+// ChromeEngine 通过 chromedp 启动无头 Chrome / Chromium 回放
+type ChromeEngine struct {
+	ExecPath string
+}
+
+// Open 实现 ReplayEngine：每次回放使用独立的浏览器进程与临时用户目录
+func (e ChromeEngine) Open(width, height int) (ReplayBrowser, error) {
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.ExecPath(e.ExecPath),
+		chromedp.WindowSize(width, height),
+	)
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
+	ctx, cancel := chromedp.NewContext(allocCtx)
+	b := &chromeBrowser{ctx: ctx, cancel: func() { cancel(); cancelAlloc() }}
+	if err := chromedp.Run(ctx, chromedp.EmulateViewport(int64(width), int64(height))); err != nil {
+		b.Close()
+		return nil, fmt.Errorf("start chrome: %w", err)
+	}
+	return b, nil
+}
+
+// chromeBrowser 回放使用的浏览器标签页
+type chromeBrowser struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// run 在标签页上执行操作，ctx 结束（步骤超时）时中止
+func (b *chromeBrowser) run(ctx context.Context, actions ...chromedp.Action) error {
+	runCtx, cancel := context.WithCancel(b.ctx)
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+	err := chromedp.Run(runCtx, actions...)
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// query 目标元素的选择器：优先 CSS，没有时使用 XPath
+func (a ReplayAction) query() (string, chromedp.QueryOption) {
+	if a.Selector != "" {
+		return a.Selector, chromedp.ByQuery
+	}
+	return a.XPath, chromedp.BySearch
+}
+
+// WaitFor 实现 ReplayBrowser
+func (b *chromeBrowser) WaitFor(ctx context.Context, a ReplayAction) error {
+	switch a.Action {
+	case "click", "input", "select", "hover":
+		sel, by := a.query()
+		if err := b.run(ctx, chromedp.WaitVisible(sel, by)); err != nil {
+			return fmt.Errorf("wait for %s: %w", sel, err)
+		}
+	}
+	return nil
+}
+
+// Do 实现 ReplayBrowser
+func (b *chromeBrowser) Do(ctx context.Context, a ReplayAction) error {
+	sel, by := a.query()
+	switch a.Action {
+	case "navigation":
+		return b.run(ctx, chromedp.Navigate(a.URL))
+	case "click":
+		return b.run(ctx, chromedp.Click(sel, by), chromedp.Sleep(replaySettle))
+	case "input":
+		return b.run(ctx, chromedp.SetValue(sel, "", by), chromedp.SendKeys(sel, a.Text, by))
+	case "select":
+		return b.run(ctx, chromedp.QueryAfter(sel, func(ctx context.Context, _ runtime.ExecutionContextID, nodes ...*cdp.Node) error {
+			return selectOption(ctx, nodes[0], a.Text)
+		}, by))
+	case "hover":
+		return b.run(ctx, chromedp.ScrollIntoView(sel, by), chromedp.QueryAfter(sel, func(ctx context.Context, _ runtime.ExecutionContextID, nodes ...*cdp.Node) error {
+			box, err := dom.GetBoxModel().WithNodeID(nodes[0].NodeID).Do(ctx)
+			if err != nil {
+				return err
+			}
+			q := box.Content
+			return input.DispatchMouseEvent(input.MouseMoved, (q[0]+q[4])/2, (q[1]+q[5])/2).Do(ctx)
+		}, by), chromedp.Sleep(replaySettle))
+	case "scroll":
+		return b.run(ctx, chromedp.Evaluate(fmt.Sprintf("window.scrollTo(%d, %d)", a.ScrollX, a.ScrollY), nil))
+	case ActionKeypress, ActionShortcut:
+		key, mods, err := chromeKey(a.KeyCombo)
+		if err != nil {
+			return err
+		}
+		return b.run(ctx, chromedp.KeyEvent(key, chromedp.KeyModifiers(mods...)), chromedp.Sleep(replaySettle))
+	}
+	return fmt.Errorf("action %q is not supported", a.Action)
+}
+
+// Screenshot 实现 ReplayBrowser：截取当前视口
+func (b *chromeBrowser) Screenshot(ctx context.Context) ([]byte, error) {
+	var buf []byte
+	err := b.run(ctx, chromedp.CaptureScreenshot(&buf))
+	return buf, err
+}
+
+// Exists 实现 ReplayBrowser：在页面中反复查找元素直到找到或 ctx 结束
+func (b *chromeBrowser) Exists(ctx context.Context, selector string, xpath bool) (bool, error) {
+	arg, _ := json.Marshal(selector)
+	expr := fmt.Sprintf("document.querySelector(%s) !== null", arg)
+	if xpath {
+		expr = fmt.Sprintf("document.evaluate(%s, document, null, XPathResult.FIRST_ORDERED_NODE_TYPE, null).singleNodeValue !== null", arg)
+	}
+	for {
+		var found bool
+		if err := b.run(ctx, chromedp.Evaluate(expr, &found)); err != nil {
+			if ctx.Err() != nil {
+				return false, nil
+			}
+			return false, err
+		}
+		if found {
+			return true, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+// Close 实现 ReplayBrowser：关闭浏览器进程
+func (b *chromeBrowser) Close() {
+	b.cancel()
+}
+
+// selectOption 按选项文字（其次按值）选中下拉框的选项并触发 change 事件
+func selectOption(ctx context.Context, node *cdp.Node, option string) error {
+	obj, err := dom.ResolveNode().WithNodeID(node.NodeID).Do(ctx)
+	if err != nil {
+		return err
+	}
+	arg, _ := json.Marshal(option)
+	var found bool
+	err = chromedp.CallFunctionOn(`function(option) {
+		const o = Array.from(this.options || []).find(o => o.text.trim() === option) ||
+			Array.from(this.options || []).find(o => o.value === option);
+		if (!o) return false;
+		this.value = o.value;
+		this.dispatchEvent(new Event('input', {bubbles: true}));
+		this.dispatchEvent(new Event('change', {bubbles: true}));
+		return true;
+	}`, &found, func(p *runtime.CallFunctionOnParams) *runtime.CallFunctionOnParams {
+		return p.WithObjectID(obj.ObjectID).WithArguments([]*runtime.CallArgument{{Value: arg}})
+	}).Do(ctx)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("option %q not found", option)
+	}
+	return nil
+}
+
+// chromeKeys 规范化按键名 → chromedp 键值
+var chromeKeys = map[string]string{
+	"Enter": kb.Enter, "Esc": kb.Escape, "Tab": kb.Tab, "Space": " ",
+	"Backspace": kb.Backspace, "Delete": kb.Delete, "Insert": kb.Insert,
+	"Home": kb.Home, "End": kb.End, "PageUp": kb.PageUp, "PageDown": kb.PageDown,
+	"↑": kb.ArrowUp, "↓": kb.ArrowDown, "←": kb.ArrowLeft, "→": kb.ArrowRight,
+}
+
+var chromeModifiers = map[string]input.Modifier{
+	"Ctrl": input.ModifierCtrl, "Alt": input.ModifierAlt, "Shift": input.ModifierShift, "Meta": input.ModifierMeta,
+}
+
+// chromeKey 把规范化的组合键（如 Ctrl+S、Enter、F5）转换为 chromedp 的键值与修饰键
+func chromeKey(combo string) (string, []input.Modifier, error) {
+	parts := strings.Split(combo, "+")
+	if strings.HasSuffix(combo, "++") {
+		parts = append(parts[:len(parts)-2], "+")
+	}
+	var mods []input.Modifier
+	for _, m := range parts[:len(parts)-1] {
+		mod, ok := chromeModifiers[m]
+		if !ok {
+			return "", nil, fmt.Errorf("unknown modifier %q", m)
+		}
+		mods = append(mods, mod)
+	}
+	key := parts[len(parts)-1]
+	if k, ok := chromeKeys[key]; ok {
+		return k, mods, nil
+	}
+	// F1-F12 在 kb 中连续编码
+	if n, err := strconv.Atoi(strings.TrimPrefix(key, "F")); err == nil && strings.HasPrefix(key, "F") && n >= 1 && n <= 12 {
+		return string(rune(0x0800 + n)), mods, nil
+	}
+	if r := []rune(key); len(r) == 1 {
+		return strings.ToLower(key), mods, nil
+	}
+	return "", nil, errors.New("unsupported key " + key)
+}
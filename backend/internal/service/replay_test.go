@@ -0,0 +1,234 @@
+package service_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+// fakeReplayEngine 记录回放操作，目标选择器在 missing 中时等待失败
+type fakeReplayEngine struct {
+	mu       sync.Mutex
+	actions  []service.ReplayAction
+	missing  map[string]bool
+	viewport [2]int
+	release  chan struct{} // 非 nil 时第一个操作阻塞到关闭为止
+}
+
+func (e *fakeReplayEngine) Open(width, height int) (service.ReplayBrowser, error) {
+	e.viewport = [2]int{width, height}
+	return e, nil
+}
+
+func (e *fakeReplayEngine) WaitFor(ctx context.Context, a service.ReplayAction) error {
+	if e.missing[a.Selector] {
+		return errors.New("wait for " + a.Selector + ": not found")
+	}
+	return nil
+}
+
+func (e *fakeReplayEngine) Do(ctx context.Context, a service.ReplayAction) error {
+	if e.release != nil {
+		<-e.release
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.actions = append(e.actions, a)
+	return nil
+}
+
+func (e *fakeReplayEngine) Screenshot(ctx context.Context) ([]byte, error) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 64, 36)))
+	return buf.Bytes(), err
+}
+
+func (e *fakeReplayEngine) Exists(ctx context.Context, selector string, xpath bool) (bool, error) {
+	return !e.missing[selector], nil
+}
+
+func (e *fakeReplayEngine) Close() {}
+
+func waitReplay(t *testing.T, runID string) db.ReplayRun {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var run db.ReplayRun
+		db.DB.First(&run, "id = ?", runID)
+		if run.Status != service.ReplayRunning {
+			return run
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("replay %s did not finish", runID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStartReplay(t *testing.T) {
+	setupDB(t)
+	t.Cleanup(func() { service.SetReplayEngine(nil, 0) })
+	sess := db.Session{Title: "回放"}
+	db.DB.Create(&sess)
+	steps := []db.RecordingStep{
+		{Action: "click", TargetSelector: "#login", PageURL: "https://prod.example.com/portal?a=1", ViewportW: 1366, ViewportH: 768},
+		{Action: "input", TargetSelector: "#name", MaskedText: "【姓名】", PageURL: "https://prod.example.com/portal"},
+		{Action: "click", TargetSelector: "#gone", PageURL: "https://prod.example.com/portal"},
+		{Action: "click", TargetElement: "无定位信息", PageURL: "https://prod.example.com/portal"},
+		{Action: service.ActionShortcut, KeyCombo: "Ctrl+S", PageURL: "https://prod.example.com/portal"},
+		{Action: "navigation", PageURL: "https://prod.example.com/done"},
+	}
+	for i, s := range steps {
+		s.SessionID, s.StepIndex = sess.ID, i+1
+		db.DB.Create(&s)
+	}
+
+	if _, err := service.StartReplay(sess.ID, nil); !errors.Is(err, service.ErrReplayDisabled) {
+		t.Fatalf("expected ErrReplayDisabled, got %v", err)
+	}
+	engine := &fakeReplayEngine{missing: map[string]bool{"#gone": true}}
+	service.SetReplayEngine(engine, time.Second)
+	base, _ := url.Parse("https://test.example.com")
+	run, err := service.StartReplay(sess.ID, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := waitReplay(t, run.ID)
+	if done.Status != service.ReplayCompleted || done.Passed != 4 || done.Failed != 1 || done.Skipped != 1 || done.FinishedAt == nil {
+		t.Fatalf("unexpected run %+v", done)
+	}
+	if engine.viewport != [2]int{1366, 768} {
+		t.Errorf("expected the recorded viewport, got %v", engine.viewport)
+	}
+
+	// 首步不是导航时先打开所在页面；地址换成测试环境
+	want := []struct{ action, url, selector, text string }{
+		{"navigation", "https://test.example.com/portal?a=1", "", ""},
+		{"click", "", "#login", ""},
+		{"input", "", "#name", "【姓名】"},
+		{service.ActionShortcut, "", "", ""},
+		{"navigation", "https://test.example.com/done", "", ""},
+	}
+	if len(engine.actions) != len(want) {
+		t.Fatalf("unexpected actions %+v", engine.actions)
+	}
+	for i, w := range want {
+		a := engine.actions[i]
+		if a.Action != w.action || a.URL != w.url || a.Selector != w.selector || a.Text != w.text {
+			t.Errorf("action %d: got %+v, want %+v", i, a, w)
+		}
+	}
+
+	var results []db.ReplayStep
+	db.DB.Where("run_id = ?", run.ID).Order("step_index").Find(&results)
+	if len(results) != len(steps) {
+		t.Fatalf("expected a result per step, got %d", len(results))
+	}
+	if r := results[2]; r.Status != service.ReplayFailed || r.Error == "" || r.DataURL == "" {
+		t.Errorf("failed step should keep the error and a screenshot: %+v", r)
+	}
+	if r := results[3]; r.Status != service.ReplaySkipped {
+		t.Errorf("step without selector should be skipped: %+v", r)
+	}
+	if r := results[0]; r.Status != service.ReplayPassed || r.Width != 64 || r.Height != 36 {
+		t.Errorf("unexpected passed step %+v", r)
+	}
+}
+
+func TestStartReplay_OneAtATime(t *testing.T) {
+	setupDB(t)
+	t.Cleanup(func() { service.SetReplayEngine(nil, 0) })
+	sess := db.Session{Title: "回放"}
+	db.DB.Create(&sess)
+	db.DB.Create(&db.RecordingStep{SessionID: sess.ID, StepIndex: 1, Action: "navigation", PageURL: "https://example.com/"})
+
+	engine := &fakeReplayEngine{release: make(chan struct{})}
+	service.SetReplayEngine(engine, time.Second)
+	run, err := service.StartReplay(sess.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.StartReplay(sess.ID, nil); !errors.Is(err, service.ErrReplayRunning) {
+		t.Errorf("expected ErrReplayRunning, got %v", err)
+	}
+	close(engine.release)
+	waitReplay(t, run.ID)
+
+	// 服务重启时仍在进行的回放标记为失败
+	db.DB.Create(&db.ReplayRun{SessionID: sess.ID, Status: service.ReplayRunning})
+	if n, err := service.FailInterruptedReplays(); err != nil || n != 1 {
+		t.Errorf("expected 1 interrupted replay, got %d %v", n, err)
+	}
+}
+
+func TestStartVerification(t *testing.T) {
+	setupDB(t)
+	t.Cleanup(func() { service.SetReplayEngine(nil, 0) })
+	sess := db.Session{Title: "校验"}
+	db.DB.Create(&sess)
+	steps := []db.RecordingStep{
+		{Action: "navigation", PageURL: "https://prod.example.com/portal"},
+		{Action: "click", TargetSelector: "#login", TargetXPath: "//button[1]", PageURL: "https://prod.example.com/portal"},
+		{Action: "input", TargetSelector: "#name", TargetXPath: "//input[@id='old']", PageURL: "https://prod.example.com/portal"},
+		{Action: "click", TargetSelector: "#pay", PageURL: "https://prod.example.com/pay"},
+		{Action: "click", TargetSelector: "#menu", FramePath: "0", PageURL: "https://prod.example.com/pay"},
+	}
+	for i, s := range steps {
+		s.SessionID, s.StepIndex = sess.ID, i+1
+		db.DB.Create(&s)
+	}
+	// 上次校验失效、这次已恢复的步骤清除标记
+	db.DB.Model(&db.RecordingStep{}).Where("session_id = ? AND step_index = 4", sess.ID).Update("selector_stale", true)
+
+	engine := &fakeReplayEngine{missing: map[string]bool{"//input[@id='old']": true}}
+	service.SetReplayEngine(engine, time.Second)
+	base, _ := url.Parse("https://test.example.com")
+	run, err := service.StartVerification(sess.ID, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Mode != service.ReplayModeVerify {
+		t.Errorf("expected verify mode, got %q", run.Mode)
+	}
+	done := waitReplay(t, run.ID)
+	if done.Status != service.ReplayCompleted || done.Passed != 2 || done.Failed != 1 || done.Skipped != 2 {
+		t.Fatalf("unexpected run %+v", done)
+	}
+
+	// 只打开页面（同一页面只打开一次），不执行点击与输入
+	want := []string{"https://test.example.com/portal", "https://test.example.com/pay"}
+	if len(engine.actions) != len(want) {
+		t.Fatalf("unexpected actions %+v", engine.actions)
+	}
+	for i, u := range want {
+		if a := engine.actions[i]; a.Action != "navigation" || a.URL != u {
+			t.Errorf("action %d: got %+v, want navigation to %s", i, a, u)
+		}
+	}
+
+	var got []db.RecordingStep
+	db.DB.Where("session_id = ?", sess.ID).Order("step_index").Find(&got)
+	if got[2].SelectorStale != true || got[2].SelectorCheckedAt == nil {
+		t.Errorf("step with a missing XPath should be stale: %+v", got[2])
+	}
+	if got[1].SelectorStale || got[3].SelectorStale || got[3].SelectorCheckedAt == nil {
+		t.Errorf("resolved steps should not be stale: %+v / %+v", got[1], got[3])
+	}
+	if got[0].SelectorCheckedAt != nil || got[4].SelectorCheckedAt != nil {
+		t.Error("skipped steps should not be checked")
+	}
+	var result db.ReplayStep
+	db.DB.Where("run_id = ? AND step_index = 3", run.ID).First(&result)
+	if result.Status != service.ReplayFailed || result.Error != "not found: XPath //input[@id='old']" || result.DataURL != "" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// RetentionResult 一次保留策略执行的清理结果
+type RetentionResult struct {
+	ScreenshotsPurged int64  `json:"screenshots_purged"`
+	SessionsPurged    int64  `json:"sessions_purged"`
+	RanAt             string `json:"ran_at"`
+}
+
+// RetentionService 按项目保留策略定期清理数据（数据最小化）
+type RetentionService struct {
+	interval    time.Duration
+	storagePath string // 清除会话时一并删除其附件文件
+}
+
+func NewRetentionService(interval time.Duration, storagePath string) *RetentionService {
+	return &RetentionService{interval: interval, storagePath: storagePath}
+}
+
+// Start 启动后台调度，ctx 取消时退出；interval <= 0 时不启动
+func (s *RetentionService) Start(ctx context.Context) {
+	if s.interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				res, err := s.RunOnce(time.Now())
+				if err != nil {
+					log.Printf("⚠️ retention purge failed: %v", err)
+					continue
+				}
+				if res.ScreenshotsPurged > 0 || res.SessionsPurged > 0 {
+					log.Printf("🧹 retention purge: %d screenshots, %d sessions", res.ScreenshotsPurged, res.SessionsPurged)
+				}
+				orphans, err := s.CleanupOrphans()
+				if err != nil {
+					log.Printf("⚠️ orphan cleanup failed: %v", err)
+					continue
+				}
+				if orphans.Total > 0 {
+					log.Printf("🧹 orphan cleanup: %v", orphans.Removed)
+				}
+				if n, err := CleanupExports(s.storagePath, time.Now()); err != nil {
+					log.Printf("⚠️ export cleanup failed: %v", err)
+				} else if n > 0 {
+					log.Printf("🧹 export cleanup: %d artifact(s)", n)
+				}
+			}
+		}
+	}()
+}
+
+// RunOnce 对所有配置了保留策略的项目执行一次清理
+func (s *RetentionService) RunOnce(now time.Time) (*RetentionResult, error) {
+	result := &RetentionResult{RanAt: now.Format(time.RFC3339)}
+
+	var projects []db.Project
+	if err := db.DB.Where("screenshot_retention_days > 0 OR session_retention_days > 0").Find(&projects).Error; err != nil {
+		return nil, err
+	}
+
+	for _, p := range projects {
+		var purged []string
+		err := db.DB.Transaction(func(tx *gorm.DB) error {
+			if p.SessionRetentionDays > 0 {
+				cutoff := now.AddDate(0, 0, -p.SessionRetentionDays)
+				var ids []string
+				if err := tx.Model(&db.Session{}).
+					Where("project_id = ? AND created_at < ?", p.ID, cutoff).
+					Pluck("id", &ids).Error; err != nil {
+					return err
+				}
+				if err := DeleteSessions(tx, ids); err != nil {
+					return err
+				}
+				result.SessionsPurged += int64(len(ids))
+				purged = ids
+			}
+
+			if p.ScreenshotRetentionDays > 0 {
+				cutoff := now.AddDate(0, 0, -p.ScreenshotRetentionDays)
+				approved := tx.Model(&db.GeneratedDocument{}).Select("session_id").
+					Where("project_id = ? AND status = ? AND approved_at < ?", p.ID, "approved", cutoff)
+				res := tx.Model(&db.Screenshot{}).
+					Where("session_id IN (?) AND is_raw_deleted = ?", approved, false).
+					Updates(map[string]interface{}{"data_url": "", "element_url": "", "ocr_text": "", "is_raw_deleted": true})
+				if res.Error != nil {
+					return res.Error
+				}
+				result.ScreenshotsPurged += res.RowsAffected
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := RemoveSessionMediaFiles(s.storagePath, purged); err != nil {
+			log.Printf("⚠️ retention: remove media files: %v", err)
+		}
+	}
+	return result, nil
+}
+
+// CleanupOrphans 在一个事务中清理孤儿记录（见 CleanupOrphans），随后删除其附件文件
+func (s *RetentionService) CleanupOrphans() (*OrphanResult, error) {
+	var result *OrphanResult
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = CleanupOrphans(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := RemoveSessionMediaFiles(s.storagePath, result.SessionIDs); err != nil {
+		log.Printf("⚠️ orphan cleanup: remove media files: %v", err)
+	}
+	return result, nil
+}
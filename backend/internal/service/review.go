@@ -0,0 +1,190 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// 审阅问题类型
+const (
+	ReviewNumbering   = "numbering"    // 描述中的步骤序号与实际顺序不符
+	ReviewTerminology = "terminology"  // 同一对象/操作前后用词不一致
+	ReviewMissingStep = "missing_step" // 流程中疑似缺少步骤（StepID 指向缺失位置之前的步骤）
+)
+
+// ReviewSuggestion 一条审阅建议，Suggestion 为建议替换后的步骤描述，可按步骤单独采纳
+type ReviewSuggestion struct {
+	StepID     string `json:"step_id"`
+	StepIndex  int    `json:"step_index"`
+	Kind       string `json:"kind"`
+	Issue      string `json:"issue"`
+	Original   string `json:"original,omitempty"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// ReviewResult 审阅结果；AIReviewed 为 false 表示没有可用模型，只做了规则检查
+type ReviewResult struct {
+	SessionID   string             `json:"session_id"`
+	AIReviewed  bool               `json:"ai_reviewed"`
+	Suggestions []ReviewSuggestion `json:"suggestions"`
+}
+
+var stepNumberRe = regexp.MustCompile(`^第\s*(\d+)\s*步`)
+
+// ReviewSession 将会话全部步骤描述交给模型做一致性审阅（序号、术语、缺失步骤）；
+// 序号检查按规则本地完成，模型不可用时仍返回
+func (s *AIService) ReviewSession(sessionID string) (*ReviewResult, error) {
+	var steps []db.RecordingStep
+	if err := db.DB.Where("session_id = ?", sessionID).
+		Order("step_index ASC").Find(&steps).Error; err != nil {
+		return nil, err
+	}
+
+	result := &ReviewResult{SessionID: sessionID, Suggestions: checkNumbering(steps)}
+	described := 0
+	for _, st := range steps {
+		if st.AIDescription != "" {
+			described++
+		}
+	}
+	if described == 0 {
+		return result, nil
+	}
+
+	resp, err := s.GenerateText(buildReviewPrompt(steps))
+	if err != nil {
+		// 模型不可用时只返回规则检查结果
+		return result, nil
+	}
+	result.AIReviewed = true
+
+	byIndex := map[int]db.RecordingStep{}
+	for _, st := range steps {
+		byIndex[st.StepIndex] = st
+	}
+	seen := map[string]bool{}
+	for _, sg := range result.Suggestions {
+		seen[sg.StepID+"|"+sg.Kind] = true
+	}
+	for _, sg := range parseReviewResponse(resp.Description) {
+		st, ok := byIndex[sg.StepIndex]
+		if !ok {
+			continue
+		}
+		sg.StepID = st.ID
+		if sg.Kind != ReviewMissingStep {
+			sg.Original = st.AIDescription
+		}
+		if seen[sg.StepID+"|"+sg.Kind] {
+			continue
+		}
+		seen[sg.StepID+"|"+sg.Kind] = true
+		result.Suggestions = append(result.Suggestions, sg)
+	}
+	return result, nil
+}
+
+// checkNumbering 检查描述开头的「第N步」是否与实际序号一致
+func checkNumbering(steps []db.RecordingStep) []ReviewSuggestion {
+	suggestions := []ReviewSuggestion{}
+	for _, st := range steps {
+		m := stepNumberRe.FindStringSubmatch(st.AIDescription)
+		if m == nil {
+			continue
+		}
+		n, _ := strconv.Atoi(m[1])
+		if n == st.StepIndex {
+			continue
+		}
+		suggestions = append(suggestions, ReviewSuggestion{
+			StepID:     st.ID,
+			StepIndex:  st.StepIndex,
+			Kind:       ReviewNumbering,
+			Issue:      fmt.Sprintf("描述中的序号为第%d步，实际为第%d步", n, st.StepIndex),
+			Original:   st.AIDescription,
+			Suggestion: stepNumberRe.ReplaceAllString(st.AIDescription, fmt.Sprintf("第%d步", st.StepIndex)),
+		})
+	}
+	return suggestions
+}
+
+func buildReviewPrompt(steps []db.RecordingStep) string {
+	var sb strings.Builder
+	for _, st := range steps {
+		sb.WriteString(fmt.Sprintf("%d. [%s @ %s] %s\n", st.StepIndex, st.Action, st.PageTitle, st.AIDescription))
+	}
+	return fmt.Sprintf(`%s以下是一份操作手册的全部步骤（序号. [操作类型 @ 页面] 描述）：
+%s
+请检查：
+1. terminology：同一按钮、菜单、字段在不同步骤中的叫法不一致；
+2. missing_step：相邻步骤之间明显缺少必要操作（如未打开页面就填写表单）；
+3. numbering：描述中出现的序号与实际顺序不符。
+
+以 JSON 数组输出，每个问题一项，没有问题输出 []，不要输出其他内容：
+[{"step_index": <序号>, "kind": "terminology|missing_step|numbering", "issue": "<问题说明>", "suggestion": "<修改后的完整步骤描述；missing_step 时为缺失步骤的描述>"}]`, reviewerPreamble(), sb.String())
+}
+
+// parseReviewResponse 从模型输出中提取 JSON 数组（容忍 ```json 包裹和前后说明文字）
+func parseReviewResponse(text string) []ReviewSuggestion {
+	start := strings.Index(text, "[")
+	end := strings.LastIndex(text, "]")
+	if start < 0 || end <= start {
+		return nil
+	}
+	var raw []ReviewSuggestion
+	if err := json.Unmarshal([]byte(text[start:end+1]), &raw); err != nil {
+		return nil
+	}
+	out := make([]ReviewSuggestion, 0, len(raw))
+	for _, sg := range raw {
+		switch sg.Kind {
+		case ReviewNumbering, ReviewTerminology, ReviewMissingStep:
+		default:
+			continue
+		}
+		if sg.Issue == "" {
+			continue
+		}
+		sg.Suggestion = strings.TrimSpace(sg.Suggestion)
+		out = append(out, sg)
+	}
+	return out
+}
+
+// ErrMissingStepNotApplicable 缺失步骤建议的是新步骤而非修改 StepID 的描述，只能手动补录，不能直接采纳
+var ErrMissingStepNotApplicable = errors.New("missing_step suggestions cannot be applied, record the step instead")
+
+// AcceptedSuggestion 用户采纳的单条建议；Kind 为审阅结果中的问题类型（旧客户端不传时按修改描述处理）
+type AcceptedSuggestion struct {
+	StepID      string `json:"step_id" binding:"required"`
+	Kind        string `json:"kind" binding:"omitempty,oneof=numbering terminology missing_step"`
+	Description string `json:"description" binding:"required"`
+}
+
+// ApplyReviewSuggestions 将采纳的建议写回步骤描述（标记为已编辑），只允许修改本会话的步骤；
+// 含缺失步骤建议时整体拒绝，避免覆盖其前一步的描述；调用方在事务提交后为这些步骤排队更新检索索引
+func ApplyReviewSuggestions(tx *gorm.DB, sessionID string, accepted []AcceptedSuggestion) (int64, error) {
+	for _, a := range accepted {
+		if a.Kind == ReviewMissingStep {
+			return 0, ErrMissingStepNotApplicable
+		}
+	}
+	var updated int64
+	for _, a := range accepted {
+		res := tx.Model(&db.RecordingStep{}).
+			Where("id = ? AND session_id = ?", a.StepID, sessionID).
+			Updates(map[string]interface{}{"AIDescription": a.Description, "IsEdited": true})
+		if res.Error != nil {
+			return 0, res.Error
+		}
+		updated += res.RowsAffected
+	}
+	return updated, nil
+}
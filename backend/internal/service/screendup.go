@@ -0,0 +1,125 @@
+package service
+
+import (
+	"image"
+	"math/bits"
+	"strings"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// ProviderReused 画面与上一步几乎相同、复用（改写）上一步描述时记录的提供商
+const ProviderReused = "reused"
+
+// screenHash 截图的差值哈希（dHash），基于烧录遮蔽区域后的整张截图；无截图或无法解码时返回 false
+func screenHash(screenshotID string) (uint64, bool) {
+	if screenshotID == "" {
+		return 0, false
+	}
+	var shot db.Screenshot
+	if err := db.DB.First(&shot, "id = ?", screenshotID).Error; err != nil || shot.DataURL == "" {
+		return 0, false
+	}
+	img, err := decodeDataURL(RedactedScreenshot(&shot, RedactBlack))
+	if err != nil {
+		return 0, false
+	}
+	return dHash(img), true
+}
+
+// dHash 缩小到 9×8 灰度后比较横向相邻像素的明暗，得到 64 位哈希；
+// 光标闪烁、输入框内容变化等细微差异只影响个别位
+func dHash(img image.Image) uint64 {
+	b := img.Bounds()
+	var gray [8][9]uint32
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 9; x++ {
+			gray[y][x] = blockLuma(img,
+				b.Min.X+x*b.Dx()/9, b.Min.Y+y*b.Dy()/8,
+				b.Min.X+(x+1)*b.Dx()/9, b.Min.Y+(y+1)*b.Dy()/8)
+		}
+	}
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// blockLuma 区域平均亮度，大图按最多 8×8 个采样点估算
+func blockLuma(img image.Image, x0, y0, x1, y1 int) uint32 {
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+	stepX, stepY := max(1, (x1-x0)/8), max(1, (y1-y0)/8)
+	var sum, n uint32
+	for y := y0; y < y1; y += stepY {
+		for x := x0; x < x1; x += stepX {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += (299*r + 587*g + 114*b) / 1000 >> 8
+			n++
+		}
+	}
+	return sum / n
+}
+
+// hashDistance 两个哈希不同的位数
+func hashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// adaptDescription 把上一步的描述改写成当前步骤的：操作类型与按键必须相同，
+// 目标元素或操作文本不同时，旧值须原样出现在描述中才能替换，否则无法改写
+func adaptDescription(prev string, from, to *db.RecordingStep) (string, bool) {
+	if prev == "" || from.Action != to.Action || from.KeyCombo != to.KeyCombo {
+		return "", false
+	}
+	for _, f := range [][2]string{{from.TargetElement, to.TargetElement}, {from.MaskedText, to.MaskedText}} {
+		if f[0] == f[1] {
+			continue
+		}
+		if f[0] == "" || f[1] == "" || !strings.Contains(prev, f[0]) {
+			return "", false
+		}
+		prev = strings.ReplaceAll(prev, f[0], f[1])
+	}
+	return prev, true
+}
+
+// screenDeduper 批量生成时记住上一步的画面哈希和描述，相邻截图几乎相同时跳过 VLM 调用
+type screenDeduper struct {
+	distance int // 视为相同画面的最大哈希差异位数，0 表示关闭
+
+	prev     db.RecordingStep
+	prevHash uint64
+	hashed   bool
+	prevDesc string
+}
+
+// reuse 当前步骤画面与上一步几乎相同且上一步描述可改写时返回改写后的描述；
+// 无论是否复用，当前步骤都成为下一次比较的“上一步”
+func (d *screenDeduper) reuse(step *db.RecordingStep) (string, bool) {
+	if d.distance <= 0 {
+		return "", false
+	}
+	hash, ok := screenHash(step.ScreenshotID)
+	prev, prevHash, hashed, prevDesc := d.prev, d.prevHash, d.hashed, d.prevDesc
+	d.prev, d.prevHash, d.hashed, d.prevDesc = *step, hash, ok, ""
+	if !ok || !hashed || hashDistance(hash, prevHash) > d.distance {
+		return "", false
+	}
+	return adaptDescription(prevDesc, &prev, step)
+}
+
+// described 记录当前步骤最终采用的描述
+func (d *screenDeduper) described(desc string) {
+	d.prevDesc = desc
+}
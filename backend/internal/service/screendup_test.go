@@ -0,0 +1,91 @@
+package service_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func patternDataURL(t *testing.T, white func(x, y int) bool) string {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 180, 80))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 180; x++ {
+			if white(x, y) {
+				img.Set(x, y, color.White)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestGenerateDocForSession_ReusesDuplicateScreens(t *testing.T) {
+	setupDB(t)
+	sess := db.Session{Title: "表单填写"}
+	db.DB.Create(&sess)
+	form := func(x, y int) bool { return x < 90 }
+	// 第 2 步只有输入框里多了几个像素，第 3 步换了页面
+	typed := func(x, y int) bool { return x < 90 || (x > 140 && x < 146 && y > 30 && y < 36) }
+	result := func(x, y int) bool { return (x/20)%2 == 0 }
+	steps := []struct {
+		target string
+		screen func(x, y int) bool
+	}{{"查询按钮", form}, {"缴费按钮", typed}, {"结果列表", result}}
+	for i, s := range steps {
+		step := db.RecordingStep{SessionID: sess.ID, StepIndex: i + 1, Action: "click", TargetElement: s.target}
+		if _, err := service.IngestStep(db.DB, service.StepInput{Step: step, Screenshot: &db.Screenshot{DataURL: db.LongText(patternDataURL(t, s.screen))}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	calls := 0
+	aiSvc := fakeOllama(t, func(prompt string) string {
+		calls++
+		target, _, _ := strings.Cut(prompt[strings.Index(prompt, "目标元素：")+len("目标元素："):], "\n")
+		return "点击" + target
+	})
+	generate := func() []service.DocGenerateProgress {
+		ch := make(chan service.DocGenerateProgress, 10)
+		if err := aiSvc.GenerateDocForSession(sess.ID, ch); err != nil {
+			t.Fatal(err)
+		}
+		close(ch)
+		var out []service.DocGenerateProgress
+		for p := range ch {
+			out = append(out, p)
+		}
+		return out
+	}
+
+	progress := generate()
+	if calls != 2 || !progress[1].Reused || progress[2].Reused {
+		t.Fatalf("expected the near-identical step to skip the VLM call, got %d calls %+v", calls, progress)
+	}
+	var saved []db.RecordingStep
+	db.DB.Where("session_id = ?", sess.ID).Order("step_index").Find(&saved)
+	if saved[1].AIDescription != "第2步：点击缴费按钮" || saved[1].AIProvider != service.ProviderReused {
+		t.Errorf("expected an adapted description, got %q from %q", saved[1].AIDescription, saved[1].AIProvider)
+	}
+
+	// 设为 0 关闭复用
+	if _, err := service.UpdateSettings(map[string]json.RawMessage{"duplicate_screen_distance": json.RawMessage("0")}); err != nil {
+		t.Fatal(err)
+	}
+	calls = 0
+	generate()
+	if calls != 3 {
+		t.Errorf("expected every step to call the VLM when disabled, got %d calls", calls)
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"strings"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// 允许上传的截图格式
+var screenshotMIMEs = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/webp": true,
+}
+
+// ErrUnsupportedImage 上传内容不是支持的图片格式
+var ErrUnsupportedImage = errors.New("unsupported image type (png/jpeg/webp)")
+
+// ScreenshotFromBytes 将二进制图片转换为截图记录（按内容识别格式，尽量读取宽高）
+func ScreenshotFromBytes(data []byte) (*db.Screenshot, error) {
+	mime := http.DetectContentType(data)
+	if !screenshotMIMEs[mime] {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedImage, mime)
+	}
+	shot := &db.Screenshot{
+		DataURL: db.LongText("data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data)),
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		shot.Width, shot.Height = cfg.Width, cfg.Height
+	}
+	return shot, nil
+}
+
+// ParseDataURL 解析 base64 data URL，返回 MIME 类型和原始字节
+func ParseDataURL(dataURL string) (string, []byte, error) {
+	rest, ok := strings.CutPrefix(dataURL, "data:")
+	if !ok {
+		return "", nil, errors.New("not a data URL")
+	}
+	meta, payload, ok := strings.Cut(rest, ",")
+	if !ok || !strings.HasSuffix(meta, ";base64") {
+		return "", nil, errors.New("not a base64 data URL")
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", nil, err
+	}
+	return strings.TrimSuffix(meta, ";base64"), data, nil
+}
+
+// AttachScreenshot 为步骤写入截图（同时按边界框生成目标元素局部图）：已有截图时原地替换内容，
+// 否则新建并关联（需在事务中调用）
+func AttachScreenshot(tx *gorm.DB, step *db.RecordingStep, shot *db.Screenshot) error {
+	shot.SessionID = step.SessionID
+	shot.StepID = step.ID
+	shot.ElementURL = db.LongText(ElementScreenshot(string(shot.DataURL), step, StepViewportWidth(step, shot.Width)))
+	if step.ScreenshotID != "" {
+		// map 更新不经过 Screenshot 钩子，需自行加密
+		dataURL, err := db.Seal(string(shot.DataURL))
+		if err != nil {
+			return err
+		}
+		elementURL, err := db.Seal(string(shot.ElementURL))
+		if err != nil {
+			return err
+		}
+		res := tx.Model(&db.Screenshot{}).Where("id = ?", step.ScreenshotID).Updates(map[string]interface{}{
+			"data_url":       dataURL,
+			"element_url":    elementURL,
+			"width":          shot.Width,
+			"height":         shot.Height,
+			"captured_at":    shot.CapturedAt,
+			"masked_regions": shot.MaskedRegions, // 遮蔽区域属于新图片，旧区域不能套用
+			"is_raw_deleted": false,
+			"ocr_text":       "",
+		})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			return tx.First(shot, "id = ?", step.ScreenshotID).Error
+		}
+	}
+	if err := tx.Create(shot).Error; err != nil {
+		return err
+	}
+	step.ScreenshotID = shot.ID
+	return tx.Model(step).Update("screenshot_id", shot.ID).Error
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// 存储目录中的文件（导出产物、会话附件）在开启静态加密时以 db.SealBytes 的格式落盘，
+// 读取时按文件开头的密文前缀判断，启用加密前写入的明文文件原样读取
+
+// SealedFile 文件是否以密文落盘
+func SealedFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	head := make([]byte, 16) // 足以容纳密文前缀
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	return db.IsSealedBytes(head[:n]), nil
+}
+
+// ReadSealedFile 读取文件，密文落盘的文件解密后返回
+func ReadSealedFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return db.UnsealBytes(data)
+}
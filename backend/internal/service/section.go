@@ -0,0 +1,120 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+var (
+	// ErrSectionNotFound 章节序号超出范围
+	ErrSectionNotFound = errors.New("section not found")
+	// ErrSectionOrder 排序列表必须恰好包含每个章节序号一次
+	ErrSectionOrder = errors.New("order must list every section index exactly once")
+	// ErrSectionHasSteps 删除章节时没有其他步骤章节可以接收其中的步骤
+	ErrSectionHasSteps = errors.New("section steps have no other section to move to")
+)
+
+// EditSections 读取文档指定视图（business | technical）的章节，经 edit 修改后重新编号并写回
+func (s *DocService) EditSections(doc *db.GeneratedDocument, view string, edit func([]DocSection) ([]DocSection, error)) ([]DocSection, error) {
+	raw := &doc.BusinessView
+	if view == "technical" {
+		raw = &doc.TechnicalView
+	}
+	var sections []DocSection
+	if err := json.Unmarshal([]byte(*raw), &sections); err != nil {
+		return nil, fmt.Errorf("invalid %s view: %w", view, err)
+	}
+	sections, err := edit(sections)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sections {
+		sections[i].SectionIndex = i + 1
+	}
+	data, _ := json.Marshal(sections)
+	*raw = db.LongText(data)
+	if err := db.DB.Save(doc).Error; err != nil {
+		return nil, err
+	}
+	if view != "technical" {
+		QueueEmbedding(EmbedDocument, doc.ID)
+	}
+	return sections, nil
+}
+
+// AddSection 在 position（1 起，0 或超出范围表示末尾）插入一个空的步骤章节
+func AddSection(sections []DocSection, position int, title, summary string) []DocSection {
+	if position < 1 || position > len(sections) {
+		position = len(sections) + 1
+	}
+	sec := DocSection{Title: title, Summary: summary, Steps: []DocStep{}}
+	return append(sections[:position-1], append([]DocSection{sec}, sections[position-1:]...)...)
+}
+
+// RenameSection 修改章节标题和/或摘要；nil 表示不修改
+func RenameSection(sections []DocSection, index int, title, summary *string) ([]DocSection, error) {
+	if index < 1 || index > len(sections) {
+		return nil, ErrSectionNotFound
+	}
+	if title != nil {
+		sections[index-1].Title = *title
+	}
+	if summary != nil {
+		sections[index-1].Summary = *summary
+	}
+	return sections, nil
+}
+
+// ReorderSections 按 order（现有章节序号的排列）重排章节
+func ReorderSections(sections []DocSection, order []int) ([]DocSection, error) {
+	if len(order) != len(sections) {
+		return nil, ErrSectionOrder
+	}
+	seen := make(map[int]bool, len(order))
+	out := make([]DocSection, 0, len(sections))
+	for _, idx := range order {
+		if idx < 1 || idx > len(sections) || seen[idx] {
+			return nil, ErrSectionOrder
+		}
+		seen[idx] = true
+		out = append(out, sections[idx-1])
+	}
+	return out, nil
+}
+
+// RemoveSection 删除章节；其中的步骤并入前一个步骤章节（没有时并入后一个），不丢失步骤
+func RemoveSection(sections []DocSection, index int) ([]DocSection, error) {
+	if index < 1 || index > len(sections) {
+		return nil, ErrSectionNotFound
+	}
+	removed := sections[index-1]
+	sections = append(sections[:index-1], sections[index:]...)
+	if len(removed.Steps) == 0 {
+		return sections, nil
+	}
+	target := -1
+	for i := index - 2; i >= 0 && target < 0; i-- {
+		if sections[i].Kind == "" {
+			target = i
+		}
+	}
+	for i := index - 1; i < len(sections) && target < 0; i++ {
+		if sections[i].Kind == "" {
+			target = i
+		}
+	}
+	if target < 0 {
+		return nil, ErrSectionHasSteps
+	}
+	t := &sections[target]
+	if target < index-1 {
+		t.Steps = append(t.Steps, removed.Steps...)
+	} else {
+		t.Steps = append(append([]DocStep{}, removed.Steps...), t.Steps...)
+	}
+	t.DurationMS += removed.DurationMS
+	return sections, nil
+}
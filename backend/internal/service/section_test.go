@@ -0,0 +1,79 @@
+package service_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gpilot/backend/internal/service"
+)
+
+func sectionTitles(sections []service.DocSection) []string {
+	titles := make([]string, len(sections))
+	for i, sec := range sections {
+		titles[i] = sec.Title
+	}
+	return titles
+}
+
+func TestSectionEditing(t *testing.T) {
+	secs := []service.DocSection{
+		{Kind: service.SectionOverview, Title: "概述"},
+		{Title: "A", Steps: []service.DocStep{{StepIndex: 1}}},
+		{Title: "B", Steps: []service.DocStep{{StepIndex: 2}, {StepIndex: 3}}},
+	}
+
+	secs = service.AddSection(secs, 2, "新章节", "")
+	if got := sectionTitles(secs); len(got) != 4 || got[1] != "新章节" {
+		t.Fatalf("AddSection: %v", got)
+	}
+	title := "B2"
+	if _, err := service.RenameSection(secs, 9, &title, nil); !errors.Is(err, service.ErrSectionNotFound) {
+		t.Errorf("rename out of range: expected ErrSectionNotFound, got %v", err)
+	}
+	secs, _ = service.RenameSection(secs, 4, &title, nil)
+	if secs[3].Title != "B2" {
+		t.Errorf("RenameSection: %v", sectionTitles(secs))
+	}
+
+	for _, order := range [][]int{{1, 2, 3}, {1, 1, 2, 3}, {1, 2, 3, 5}} {
+		if _, err := service.ReorderSections(secs, order); !errors.Is(err, service.ErrSectionOrder) {
+			t.Errorf("order %v: expected ErrSectionOrder, got %v", order, err)
+		}
+	}
+	secs, _ = service.ReorderSections(secs, []int{1, 4, 3, 2})
+	if got := sectionTitles(secs); got[1] != "B2" || got[3] != "新章节" {
+		t.Errorf("ReorderSections: %v", got)
+	}
+
+	// B2 位于第一个步骤章节，删除后步骤并入后面的 A（概述章节不接收步骤）
+	secs, err := service.RemoveSection(secs, 2)
+	if err != nil || len(secs) != 3 || len(secs[1].Steps) != 3 || secs[1].Steps[0].StepIndex != 2 {
+		t.Fatalf("RemoveSection: %v %+v", err, secs)
+	}
+	secs, _ = service.RemoveSection(secs, 3)
+	if _, err := service.RemoveSection(secs, 2); !errors.Is(err, service.ErrSectionHasSteps) {
+		t.Errorf("last step section: expected ErrSectionHasSteps, got %v", err)
+	}
+}
+
+func TestEditSectionsPersists(t *testing.T) {
+	setupDB(t)
+	_, sessionID := seedSessionWithSteps(t, 5)
+	svc := service.NewDocService()
+	content, _ := svc.BuildDocument(sessionID)
+	doc, _ := svc.SaveGeneratedDoc(sessionID, content)
+
+	sections, err := svc.EditSections(doc, "technical", func(secs []service.DocSection) ([]service.DocSection, error) {
+		return service.AddSection(secs, 1, "准备工作", "登录前确认账号"), nil
+	})
+	if err != nil || sections[0].SectionIndex != 1 || sections[1].SectionIndex != 2 {
+		t.Fatalf("EditSections: %v %+v", err, sections)
+	}
+	loaded, _ := svc.LoadDocument(doc)
+	if loaded.TechnicalView[0].Title != "准备工作" || loaded.BusinessView[0].Title == "准备工作" {
+		t.Error("only the technical view should gain the new section")
+	}
+	if len(allSteps(loaded.TechnicalView)) != len(allSteps(content.TechnicalView)) {
+		t.Error("steps should be untouched")
+	}
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// SeleniumProject Selenium IDE 保存的 .side 项目（只解析转换需要的字段）
+type SeleniumProject struct {
+	Name  string         `json:"name"`
+	URL   string         `json:"url"` // 基础地址，open 命令的相对路径据此补全
+	Tests []SeleniumTest `json:"tests"`
+}
+
+// SeleniumTest .side 项目中的一个测试用例
+type SeleniumTest struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Commands []SeleniumCommand `json:"commands"`
+}
+
+// SeleniumCommand 测试用例中的一条命令，target 为定位器，targets 为候选定位器 [定位器, 类型]
+type SeleniumCommand struct {
+	Command string     `json:"command"`
+	Target  string     `json:"target"`
+	Targets [][]string `json:"targets"`
+	Value   string     `json:"value"`
+}
+
+// seleniumActions Selenium 命令 → 步骤操作类型
+var seleniumActions = map[string]string{
+	"open":          "navigation",
+	"click":         "click",
+	"clickAt":       "click",
+	"doubleClick":   "click",
+	"doubleClickAt": "click",
+	"check":         "click",
+	"uncheck":       "click",
+	"type":          "input",
+	"editContent":   "input",
+	"sendKeys":      "input",
+	"select":        "select",
+	"mouseOver":     "hover",
+}
+
+// seleniumKey sendKeys 中的特殊键，如 ${KEY_ENTER}
+var seleniumKey = regexp.MustCompile(`\$\{KEY_([A-Z0-9_]+)\}`)
+
+// seleniumKeyNames 去掉下划线后仍与 NormalizeKeyCombo 写法不同的 Selenium 键名
+var seleniumKeyNames = map[string]string{
+	"BKSP": "Backspace", "PGUP": "PageUp", "PGDN": "PageDown", "NUMPAD_ENTER": "Enter",
+}
+
+// ImportSeleniumProject 把 Selenium IDE .side 项目中的每个测试用例转换为项目下的一个新会话（需在事务中调用）。
+// 断言、等待、变量与流程控制等命令跳过；没有可转换命令的用例不导入，全部为空时返回 ErrEmptyRecording。
+// 输入值与页面地址按导入外部录制的规则脱敏
+func ImportSeleniumProject(tx *gorm.DB, projectID string, side *SeleniumProject) ([]*RecorderImport, error) {
+	base, _ := url.Parse(side.URL)
+	results := []*RecorderImport{}
+	for _, test := range side.Tests {
+		title := test.Name
+		if title == "" {
+			title = side.Name
+		}
+		result, err := importSeleniumTest(tx, projectID, title, base, test.Commands)
+		if err != nil {
+			return nil, err
+		}
+		if result != nil {
+			results = append(results, result)
+		}
+	}
+	if len(results) == 0 {
+		return nil, ErrEmptyRecording
+	}
+	return results, nil
+}
+
+// importSeleniumTest 转换单个测试用例，没有可转换的命令时不创建会话并返回 nil
+func importSeleniumTest(tx *gorm.DB, projectID, title string, base *url.URL, commands []SeleniumCommand) (*RecorderImport, error) {
+	var session *db.Session
+	result := &RecorderImport{Skipped: []string{}}
+	var pageURL, targetURL, framePath string
+	var viewportW, viewportH int
+	emit := func(step db.RecordingStep) error {
+		if session == nil {
+			var err error
+			if session, err = createImportedSession(tx, projectID, title); err != nil {
+				return err
+			}
+			result.Session = session
+		}
+		step.SessionID, step.PageURL, step.FramePath = session.ID, pageURL, framePath
+		step.ViewportW, step.ViewportH = viewportW, viewportH
+		if _, err := IngestStep(tx, StepInput{Step: step}); err != nil {
+			return err
+		}
+		result.Steps++
+		return nil
+	}
+
+	for _, cmd := range commands {
+		// 以 // 开头的命令在 IDE 中被注释掉，不会执行
+		if strings.HasPrefix(cmd.Command, "//") || cmd.Command == "" {
+			continue
+		}
+		switch cmd.Command {
+		case "setWindowSize":
+			w, h, _ := strings.Cut(cmd.Target, "x")
+			viewportW, _ = strconv.Atoi(strings.TrimSpace(w))
+			viewportH, _ = strconv.Atoi(strings.TrimSpace(h))
+			continue
+		case "selectFrame":
+			framePath = seleniumFramePath(framePath, cmd.Target)
+			continue
+		}
+		action, ok := seleniumActions[cmd.Command]
+		if !ok {
+			result.Skipped = append(result.Skipped, cmd.Command)
+			continue
+		}
+
+		step := db.RecordingStep{Action: action}
+		if action != "navigation" {
+			applySeleniumLocators(&step, cmd)
+		}
+		switch cmd.Command {
+		case "open":
+			pageURL = sanitizePageURL(resolveSeleniumURL(base, cmd.Target))
+			step.TargetElement = pageURL
+			if targetURL == "" {
+				targetURL = pageURL
+			}
+		case "type", "editContent":
+			step.MaskedText, step.IsMasked = maskRecordedValue(cmd.Value, step)
+		case "select":
+			// 选项定位器：label=、value=、index=、id=，默认按 label
+			_, option, found := strings.Cut(cmd.Value, "=")
+			if !found {
+				option = cmd.Value
+			}
+			step.MaskedText = option
+		case "sendKeys":
+			// 文本部分作为一次输入，特殊键各作为一次按键
+			if text := seleniumKey.ReplaceAllString(cmd.Value, ""); text != "" {
+				step.MaskedText, step.IsMasked = maskRecordedValue(text, step)
+				if err := emit(step); err != nil {
+					return nil, err
+				}
+			}
+			for _, m := range seleniumKey.FindAllStringSubmatch(cmd.Value, -1) {
+				key := m[1]
+				if name, ok := seleniumKeyNames[key]; ok {
+					key = name
+				}
+				combo, err := NormalizeKeyCombo("", strings.ReplaceAll(key, "_", ""), nil)
+				if err != nil {
+					result.Skipped = append(result.Skipped, cmd.Command)
+					continue
+				}
+				if err := emit(db.RecordingStep{Action: ActionKeypress, KeyCombo: combo}); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := emit(step); err != nil {
+			return nil, err
+		}
+	}
+	if session == nil {
+		return nil, nil
+	}
+	session.TargetURL = targetURL
+	return result, finishImportedSession(tx, session)
+}
+
+// applySeleniumLocators 从定位器与候选定位器中取 CSS、XPath 与可读名称（链接文字）
+func applySeleniumLocators(step *db.RecordingStep, cmd SeleniumCommand) {
+	locators := []string{cmd.Target}
+	for _, t := range cmd.Targets {
+		if len(t) > 0 {
+			locators = append(locators, t[0])
+		}
+	}
+	for _, loc := range locators {
+		kind, value, found := strings.Cut(loc, "=")
+		if !found {
+			if strings.HasPrefix(loc, "//") && step.TargetXPath == "" {
+				step.TargetXPath = loc
+			}
+			continue
+		}
+		switch kind {
+		case "css":
+			if step.TargetSelector == "" {
+				step.TargetSelector = value
+			}
+		case "id":
+			if step.TargetSelector == "" {
+				step.TargetSelector = "#" + value
+			}
+		case "name":
+			if step.TargetSelector == "" {
+				step.TargetSelector = `[name="` + value + `"]`
+			}
+		case "xpath":
+			if step.TargetXPath == "" {
+				step.TargetXPath = value
+			}
+		case "linkText", "partialLinkText", "link":
+			if step.TargetElement == "" {
+				step.TargetElement = value
+			}
+		}
+	}
+	if step.TargetElement == "" {
+		step.TargetElement = firstNonEmpty(step.TargetSelector, step.TargetXPath)
+	}
+}
+
+// resolveSeleniumURL 按项目基础地址补全 open 命令的相对路径
+func resolveSeleniumURL(base *url.URL, target string) string {
+	ref, err := url.Parse(target)
+	if err != nil || base == nil || ref.IsAbs() {
+		return target
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// seleniumFramePath 按 selectFrame 命令更新所在框架：index=N 进入子框架，relative=parent 返回上一层，
+// relative=top 回到顶层，其他定位器原样记录
+func seleniumFramePath(current, target string) string {
+	switch {
+	case target == "relative=top":
+		return ""
+	case target == "relative=parent":
+		if i := strings.LastIndex(current, " > "); i >= 0 {
+			return current[:i]
+		}
+		return ""
+	case strings.HasPrefix(target, "index="):
+		target = "frames[" + strings.TrimPrefix(target, "index=") + "]"
+	}
+	if current == "" {
+		return target
+	}
+	return current + " > " + target
+}
@@ -0,0 +1,91 @@
+package service_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+const seleniumSide = `{
+  "id": "p1", "version": "2.0", "name": "社保系统回归", "url": "https://gov.example.com",
+  "tests": [{
+    "id": "t1", "name": "登录",
+    "commands": [
+      {"id": "c1", "command": "open", "target": "/login?token=abc", "targets": [], "value": ""},
+      {"id": "c2", "command": "setWindowSize", "target": "1280x720", "targets": [], "value": ""},
+      {"id": "c3", "command": "click", "target": "id=username", "targets": [["id=username", "id"], ["xpath=//input[@id='username']", "xpath:attributes"]], "value": ""},
+      {"id": "c4", "command": "type", "target": "id=username", "targets": [], "value": "13812345678"},
+      {"id": "c5", "command": "type", "target": "name=pwd", "targets": [], "value": "secret"},
+      {"id": "c6", "command": "sendKeys", "target": "name=pwd", "targets": [], "value": "${KEY_ENTER}"},
+      {"id": "c7", "command": "waitForElementVisible", "target": "css=.menu", "targets": [], "value": "3000"},
+      {"id": "c8", "command": "//click", "target": "css=.ad", "targets": [], "value": ""},
+      {"id": "c9", "command": "selectFrame", "target": "index=0", "targets": [], "value": ""},
+      {"id": "c10", "command": "click", "target": "linkText=缴费", "targets": [["css=div.menu > a", "css:finder"]], "value": ""},
+      {"id": "c11", "command": "select", "target": "id=year", "targets": [], "value": "label=2026"},
+      {"id": "c12", "command": "selectFrame", "target": "relative=top", "targets": [], "value": ""},
+      {"id": "c13", "command": "mouseOver", "target": "css=.help", "targets": [], "value": ""}
+    ]
+  }, {
+    "id": "t2", "name": "只有断言",
+    "commands": [{"id": "c1", "command": "assertTitle", "target": "首页", "targets": [], "value": ""}]
+  }]
+}`
+
+func TestImportSeleniumProject(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "导入"}
+	db.DB.Create(&proj)
+	var side service.SeleniumProject
+	if err := json.Unmarshal([]byte(seleniumSide), &side); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := service.ImportSeleniumProject(db.DB, proj.ID, &side)
+	if err != nil {
+		t.Fatalf("ImportSeleniumProject: %v", err)
+	}
+	// 只有断言的用例不导入
+	if len(results) != 1 {
+		t.Fatalf("expected 1 imported test, got %d", len(results))
+	}
+	result := results[0]
+	if result.Steps != 8 || len(result.Skipped) != 1 || result.Skipped[0] != "waitForElementVisible" {
+		t.Fatalf("unexpected result: %d steps, skipped %v", result.Steps, result.Skipped)
+	}
+	if s := result.Session; s.Title != "登录" || s.Status != "completed" || s.TargetURL != "https://gov.example.com/login?token=%2A%2A%2A" {
+		t.Errorf("unexpected session %+v", s)
+	}
+
+	var steps []db.RecordingStep
+	db.DB.Where("session_id = ?", result.Session.ID).Order("step_index").Find(&steps)
+	want := []struct{ action, target, text, combo string }{
+		{"navigation", "https://gov.example.com/login?token=%2A%2A%2A", "", ""},
+		{"click", "#username", "", ""},
+		{"input", "#username", "【手机号】", ""},
+		{"input", `[name="pwd"]`, "******", ""},
+		{service.ActionKeypress, "", "", "Enter"},
+		{"click", "缴费", "", ""},
+		{"select", "#year", "2026", ""},
+		{"hover", ".help", "", ""},
+	}
+	for i, w := range want {
+		s := steps[i]
+		if s.Action != w.action || s.TargetElement != w.target || s.MaskedText != w.text || s.KeyCombo != w.combo {
+			t.Errorf("step %d: got %s %q %q %q, want %+v", i+1, s.Action, s.TargetElement, s.MaskedText, s.KeyCombo, w)
+		}
+	}
+	if s := steps[1]; s.TargetXPath != "//input[@id='username']" || s.ViewportW != 1280 || s.PageURL != result.Session.TargetURL {
+		t.Errorf("locators and page not carried over: %+v", s)
+	}
+	if steps[5].FramePath != "frames[0]" || steps[5].TargetSelector != "div.menu > a" || steps[7].FramePath != "" {
+		t.Errorf("unexpected frame: %q %q / %q", steps[5].FramePath, steps[5].TargetSelector, steps[7].FramePath)
+	}
+
+	empty := service.SeleniumProject{Tests: []service.SeleniumTest{{Name: "空", Commands: []service.SeleniumCommand{{Command: "pause", Target: "1000"}}}}}
+	if _, err := service.ImportSeleniumProject(db.DB, proj.ID, &empty); !errors.Is(err, service.ErrEmptyRecording) {
+		t.Errorf("expected ErrEmptyRecording, got %v", err)
+	}
+}
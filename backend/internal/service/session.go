@@ -0,0 +1,134 @@
+package service
+
+import (
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// DeleteSessions 删除会话及其步骤、截图、步骤附属记录（网络请求、控制台日志、脱敏审计）、
+// 生成文档、附件记录、回放记录、导出任务、合订手册中的章节、检索索引与问答记录、格式校验失败记录、标签关联（需在事务中调用；附件文件在事务提交后由 RemoveSessionMediaFiles 删除）
+func DeleteSessions(tx *gorm.DB, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Table("session_tags").Where("session_id IN ?", ids).Delete(nil).Error; err != nil {
+		return err
+	}
+	models := []interface{}{
+		&db.RecordingStep{}, &db.Screenshot{}, &db.StepRequest{}, &db.StepLog{}, &db.MaskingEvent{},
+		&db.GeneratedDocument{}, &db.DocumentShare{}, &db.SessionMedia{}, &db.ReplayStep{}, &db.ReplayRun{},
+		&db.CompiledChapter{}, &db.Embedding{}, &db.ChatMessage{}, &db.ChatConversation{}, &db.ExportJob{},
+		&db.OutputValidationFailure{},
+	}
+	for _, model := range models {
+		if err := tx.Where("session_id IN ?", ids).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Where("id IN ?", ids).Delete(&db.Session{}).Error
+}
+
+// DeleteDocuments 删除生成文档及其分享链接、导出任务与检索索引；会话的当前文档被删除时改为该会话最新的剩余文档（没有则清空），
+// 返回改为当前文档的文档 ID，供事务提交后重建检索索引（需在事务中调用）
+func DeleteDocuments(tx *gorm.DB, ids []string) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	if err := tx.Where("document_id IN ?", ids).Delete(&db.DocumentShare{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("source_type = ? AND source_id IN ?", EmbedDocument, ids).Delete(&db.Embedding{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("source_type = ? AND source_id IN ?", ExportSourceDocument, ids).Delete(&db.ExportJob{}).Error; err != nil {
+		return nil, err
+	}
+	var sessions []db.Session
+	if err := tx.Select("id").Where("generated_doc_id IN ?", ids).Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("id IN ?", ids).Delete(&db.GeneratedDocument{}).Error; err != nil {
+		return nil, err
+	}
+	var promoted []string
+	for _, session := range sessions {
+		var latest db.GeneratedDocument
+		err := tx.Select("id").Where("session_id = ?", session.ID).Order("created_at DESC").Limit(1).Find(&latest).Error
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&db.Session{}).Where("id = ?", session.ID).UpdateColumn("generated_doc_id", latest.ID).Error; err != nil {
+			return nil, err
+		}
+		if latest.ID != "" {
+			promoted = append(promoted, latest.ID)
+		}
+	}
+	return promoted, nil
+}
+
+// stepCountBatch 分组统计步骤数时每次查询的会话数（受数据库绑定参数个数限制）
+const stepCountBatch = 500
+
+// FillStepCounts 以分组查询一次统计多组会话的步骤数并填入 StepCount，避免逐个会话 COUNT
+func FillStepCounts(tx *gorm.DB, groups ...[]db.Session) error {
+	var ids []string
+	for _, sessions := range groups {
+		for _, s := range sessions {
+			ids = append(ids, s.ID)
+		}
+	}
+	counts := make(map[string]int64, len(ids))
+	for start := 0; start < len(ids); start += stepCountBatch {
+		end := min(start+stepCountBatch, len(ids))
+		var rows []struct {
+			SessionID string
+			Count     int64
+		}
+		if err := tx.Model(&db.RecordingStep{}).Select("session_id, COUNT(*) AS count").
+			Where("session_id IN ?", ids[start:end]).Group("session_id").Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, r := range rows {
+			counts[r.SessionID] = r.Count
+		}
+	}
+	for _, sessions := range groups {
+		for i := range sessions {
+			sessions[i].StepCount = counts[sessions[i].ID]
+		}
+	}
+	return nil
+}
+
+// RenumberSteps 按当前顺序将会话步骤重新编号为 1..N，并同步会话的序号计数器与耗时
+func RenumberSteps(tx *gorm.DB, sessionID string) error {
+	var steps []db.RecordingStep
+	if err := tx.Select("id", "step_index").Where("session_id = ?", sessionID).
+		Order("step_index, created_at").Find(&steps).Error; err != nil {
+		return err
+	}
+	for i, s := range steps {
+		if s.StepIndex == i+1 {
+			continue
+		}
+		if err := tx.Model(&db.RecordingStep{}).Where("id = ?", s.ID).
+			UpdateColumn("step_index", i+1).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Model(&db.Session{}).Where("id = ?", sessionID).UpdateColumn("step_seq", len(steps)).Error; err != nil {
+		return err
+	}
+	return RecomputeTiming(tx, sessionID)
+}
+
+// ProjectFreeOnly 会话所属项目是否开启了仅免费生成
+func ProjectFreeOnly(sessionID string) bool {
+	var session db.Session
+	if err := db.DB.Select("project_id").First(&session, "id = ?", sessionID).Error; err != nil {
+		return false
+	}
+	var project db.Project
+	return db.DB.Select("free_only").First(&project, "id = ?", session.ProjectID).Error == nil && project.FreeOnly
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// 步骤对比结果
+const (
+	DiffUnchanged = "unchanged"
+	DiffChanged   = "changed"
+	DiffAdded     = "added"   // 只在新会话中出现
+	DiffRemoved   = "removed" // 只在旧会话中出现
+)
+
+// DiffStep 对比结果中的步骤摘要
+type DiffStep struct {
+	StepID         string `json:"step_id"`
+	StepIndex      int    `json:"step_index"`
+	Action         string `json:"action"`
+	TargetElement  string `json:"target_element,omitempty"`
+	TargetSelector string `json:"target_selector,omitempty"`
+	PageURL        string `json:"page_url,omitempty"`
+	PageTitle      string `json:"page_title,omitempty"`
+}
+
+// StepDiff 一组对齐的步骤：新增步骤没有 Base，删除步骤没有 Target；Changes 列出变化的字段
+type StepDiff struct {
+	Status  string    `json:"status"`
+	Base    *DiffStep `json:"base,omitempty"`
+	Target  *DiffStep `json:"target,omitempty"`
+	Changes []string  `json:"changes,omitempty"`
+}
+
+// SessionDiff 两个会话的步骤对比，Steps 按流程顺序排列
+type SessionDiff struct {
+	BaseSessionID   string `json:"base_session_id"`
+	TargetSessionID string `json:"target_session_id"`
+	Summary         struct {
+		Unchanged int `json:"unchanged"`
+		Changed   int `json:"changed"`
+		Added     int `json:"added"`
+		Removed   int `json:"removed"`
+	} `json:"summary"`
+	Steps []StepDiff `json:"steps"`
+}
+
+// DiffSessions 对比同一流程的两次录制（如系统升级前后）：按页面、选择器与 DOM 指纹对齐步骤，
+// 列出新增、删除与变化的步骤；重复提交与已排除的步骤不参与对比
+func DiffSessions(tx *gorm.DB, baseID, targetID string) (*SessionDiff, error) {
+	load := func(sessionID string) ([]db.RecordingStep, error) {
+		var steps []db.RecordingStep
+		err := tx.Where("session_id = ? AND duplicate_of = '' AND excluded = ?", sessionID, false).
+			Order("step_index").Find(&steps).Error
+		return steps, err
+	}
+	base, err := load(baseID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := load(targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &SessionDiff{BaseSessionID: baseID, TargetSessionID: targetID, Steps: []StepDiff{}}
+	add := func(d StepDiff) {
+		switch d.Status {
+		case DiffUnchanged:
+			diff.Summary.Unchanged++
+		case DiffChanged:
+			diff.Summary.Changed++
+		case DiffAdded:
+			diff.Summary.Added++
+		case DiffRemoved:
+			diff.Summary.Removed++
+		}
+		diff.Steps = append(diff.Steps, d)
+	}
+	// 对齐的步骤之间，同一页面上的同类操作视为同一步骤发生了变化（如按钮改名、选择器变化），优先配对元素文字相同的步骤；
+	// 其余为删除或新增。删除的步骤排在前面，变化与新增的步骤按新会话的顺序排列
+	flush := func(removed, added []db.RecordingStep) {
+		pair := make([]int, len(added))
+		for j := range pair {
+			pair[j] = -1
+		}
+		match := func(b, t *db.RecordingStep, sameText bool) bool {
+			return b.Action == t.Action && URLPattern(b.PageURL) == URLPattern(t.PageURL) &&
+				(!sameText || b.TargetElement != "" && b.TargetElement == t.TargetElement)
+		}
+		paired := make([]bool, len(removed))
+		for _, sameText := range []bool{true, false} {
+			for i := range removed {
+				for j := range added {
+					if !paired[i] && pair[j] < 0 && match(&removed[i], &added[j], sameText) {
+						pair[j], paired[i] = i, true
+					}
+				}
+			}
+		}
+		for i, b := range removed {
+			if !paired[i] {
+				add(StepDiff{Status: DiffRemoved, Base: diffStep(b)})
+			}
+		}
+		for j, t := range added {
+			if pair[j] >= 0 {
+				add(pairDiff(removed[pair[j]], t))
+			} else {
+				add(StepDiff{Status: DiffAdded, Target: diffStep(t)})
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for _, m := range alignSteps(base, target) {
+		flush(base[i:m[0]], target[j:m[1]])
+		add(pairDiff(base[m[0]], target[m[1]]))
+		i, j = m[0]+1, m[1]+1
+	}
+	flush(base[i:], target[j:])
+	return diff, nil
+}
+
+// alignSteps 按最长公共子序列对齐两组步骤，返回匹配的下标对（按顺序）
+func alignSteps(a, b []db.RecordingStep) [][2]int {
+	// lcs[i][j]：a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case sameStep(&a[i], &b[j]):
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var pairs [][2]int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case sameStep(&a[i], &b[j]):
+			pairs = append(pairs, [2]int{i, j})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+// sameStep 两次录制中的步骤是否为同一操作：DOM 指纹相同，或同一页面上的同类操作且选择器、XPath、元素文字之一相同
+func sameStep(a, b *db.RecordingStep) bool {
+	if a.Action != b.Action {
+		return false
+	}
+	if a.DOMFingerprint != "" && a.DOMFingerprint == b.DOMFingerprint {
+		return true
+	}
+	if URLPattern(a.PageURL) != URLPattern(b.PageURL) {
+		return false
+	}
+	switch a.Action {
+	case "navigation", "scroll":
+		return true
+	case ActionKeypress, ActionShortcut:
+		return a.KeyCombo == b.KeyCombo
+	}
+	same := func(x, y string) bool { return x != "" && x == y }
+	return same(a.TargetSelector, b.TargetSelector) || same(a.TargetXPath, b.TargetXPath) || same(a.TargetElement, b.TargetElement)
+}
+
+// pairDiff 对比一对对齐的步骤
+func pairDiff(a, b db.RecordingStep) StepDiff {
+	var changes []string
+	for _, f := range []struct {
+		name string
+		x, y string
+	}{
+		{"target_element", a.TargetElement, b.TargetElement},
+		{"target_selector", a.TargetSelector, b.TargetSelector},
+		{"target_xpath", a.TargetXPath, b.TargetXPath},
+		{"frame_path", a.FramePath, b.FramePath},
+		{"key_combo", a.KeyCombo, b.KeyCombo},
+		{"masked_text", a.MaskedText, b.MaskedText},
+		{"page_url", URLPattern(a.PageURL), URLPattern(b.PageURL)},
+		{"page_title", a.PageTitle, b.PageTitle},
+	} {
+		if f.x != f.y {
+			changes = append(changes, f.name)
+		}
+	}
+	d := StepDiff{Status: DiffUnchanged, Base: diffStep(a), Target: diffStep(b), Changes: changes}
+	if len(changes) > 0 {
+		d.Status = DiffChanged
+	}
+	return d
+}
+
+func diffStep(s db.RecordingStep) *DiffStep {
+	return &DiffStep{
+		StepID: s.ID, StepIndex: s.StepIndex, Action: s.Action,
+		TargetElement: s.TargetElement, TargetSelector: s.TargetSelector,
+		PageURL: s.PageURL, PageTitle: s.PageTitle,
+	}
+}
@@ -0,0 +1,71 @@
+package service_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestDiffSessions(t *testing.T) {
+	setupDB(t)
+	create := func(steps []db.RecordingStep) string {
+		sess := db.Session{Title: "缴费"}
+		db.DB.Create(&sess)
+		for i, s := range steps {
+			s.SessionID, s.StepIndex = sess.ID, i+1
+			db.DB.Create(&s)
+		}
+		return sess.ID
+	}
+	before := create([]db.RecordingStep{
+		{Action: "navigation", PageURL: "https://gov.example.com/cases/101"},
+		{Action: "click", TargetSelector: "#query", TargetElement: "查询", PageURL: "https://gov.example.com/cases/101"},
+		{Action: "click", TargetSelector: "#query", TargetElement: "查询", PageURL: "https://gov.example.com/cases/101", DuplicateOf: "x"},
+		{Action: "click", TargetSelector: "#print", TargetElement: "打印", PageURL: "https://gov.example.com/cases/101"},
+		{Action: "input", TargetSelector: "#amount", TargetElement: "金额", PageURL: "https://gov.example.com/pay"},
+		{Action: "click", TargetSelector: ".btn-ok", TargetElement: "确定", PageURL: "https://gov.example.com/pay"},
+	})
+	after := create([]db.RecordingStep{
+		{Action: "navigation", PageURL: "https://gov.example.com/cases/202"},
+		{Action: "click", TargetSelector: "#query", TargetElement: "查询案件", PageURL: "https://gov.example.com/cases/202"},
+		{Action: "input", TargetSelector: "#amount", TargetElement: "金额", PageURL: "https://gov.example.com/pay"},
+		{Action: "click", TargetSelector: "#agree", TargetElement: "同意协议", PageURL: "https://gov.example.com/pay"},
+		{Action: "click", TargetSelector: "#submit", TargetElement: "确定", PageURL: "https://gov.example.com/pay", DOMFingerprint: "f"},
+		{Action: "click", TargetSelector: "#done", TargetElement: "完成", PageURL: "https://gov.example.com/done"},
+	})
+
+	diff, err := service.DiffSessions(db.DB, before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range diff.Steps {
+		got = append(got, d.Status)
+	}
+	want := []string{
+		service.DiffUnchanged, // 导航：地址中的 ID 不同视为同一页面
+		service.DiffChanged,   // 查询按钮改名，选择器不变
+		service.DiffRemoved,   // 打印按钮不再出现
+		service.DiffUnchanged,
+		service.DiffAdded,
+		service.DiffChanged, // 确定按钮选择器变化，按文字配对
+		service.DiffAdded,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if c := diff.Steps[1].Changes; !reflect.DeepEqual(c, []string{"target_element"}) {
+		t.Errorf("unexpected changes %v", c)
+	}
+	if c := diff.Steps[5].Changes; !reflect.DeepEqual(c, []string{"target_selector"}) || diff.Steps[5].Base.StepIndex != 6 {
+		t.Errorf("unexpected changed step %+v", diff.Steps[5])
+	}
+	if diff.Steps[4].Target.TargetElement != "同意协议" || diff.Steps[6].Target.TargetElement != "完成" {
+		t.Errorf("unexpected added steps %+v %+v", diff.Steps[4].Target, diff.Steps[6].Target)
+	}
+	if s := diff.Summary; s.Unchanged != 2 || s.Changed != 2 || s.Added != 2 || s.Removed != 1 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+}
@@ -0,0 +1,31 @@
+package service_test
+
+import (
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestFillStepCounts(t *testing.T) {
+	setupDB(t)
+	_, withSteps := seedSessionWithSteps(t, 3)
+	// 超过单次查询的会话数，分批统计
+	sessions := make([]db.Session, 600)
+	for i := range sessions {
+		sessions[i] = db.Session{ProjectID: "p", Title: "空会话"}
+	}
+	db.DB.CreateInBatches(&sessions, 100)
+	db.DB.Create(&db.RecordingStep{SessionID: sessions[599].ID, StepIndex: 1, Action: "click"})
+
+	other := []db.Session{{Base: db.Base{ID: withSteps}}}
+	if err := service.FillStepCounts(db.DB, sessions, other); err != nil {
+		t.Fatal(err)
+	}
+	if other[0].StepCount != 3 || sessions[0].StepCount != 0 || sessions[599].StepCount != 1 {
+		t.Errorf("unexpected counts: %d %d %d", other[0].StepCount, sessions[0].StepCount, sessions[599].StepCount)
+	}
+	if err := service.FillStepCounts(db.DB); err != nil {
+		t.Errorf("no sessions: %v", err)
+	}
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RuntimeSettings 可在运行时调整、无需重启即生效的服务端设置
+type RuntimeSettings struct {
+	AITimeoutSeconds    int    `json:"ai_timeout_seconds"`    // 单次 VLM 请求超时
+	AIConcurrency       int    `json:"ai_concurrency"`        // 全局同时进行的 VLM 请求数上限
+	MaxScreenshotMB     int    `json:"max_screenshot_mb"`     // 单张截图大小上限
+	MaxMediaMB          int    `json:"max_media_mb"`          // 单个会话附件（操作录像）大小上限
+	DefaultExportFormat string `json:"default_export_format"` // 未指定 format 时的导出格式：md | mdzip | json
+	ExportExpiryHours   int    `json:"export_expiry_hours"`   // 后台导出产物的保留时长，过期后不可下载
+
+	WatermarkText    string  `json:"watermark_text"`    // 导出水印文字（如“内部资料 – 禁止外传”），空表示不加
+	WatermarkImage   string  `json:"watermark_image"`   // 导出水印图片（data URL，如密级印章），空表示不加
+	WatermarkOpacity float64 `json:"watermark_opacity"` // 水印不透明度
+
+	BannedPhrases []string `json:"banned_phrases"` // 全局禁用词，与项目禁用词合并
+
+	TextOnlyGeneration bool `json:"text_only_generation"` // 不向任何提供商发送截图，只依据操作元数据生成（带宽受限的部署）
+	// 批量生成时相邻截图感知哈希差异不超过该位数视为相同画面，复用上一步描述而不调用 VLM；0 表示关闭
+	DuplicateScreenDistance int `json:"duplicate_screen_distance"`
+	// 同一元素上间隔不超过该秒数的连续输入事件在上报时合并为一步（逐键上报的插件）；0 表示关闭
+	InputCoalesceSeconds int `json:"input_coalesce_seconds"`
+	// 会话标记为已完成时自动把疑似噪声的步骤（孤立滚动、只悬停、点击空白处、随即撤销的操作）排除出业务视图
+	ExcludeNoiseOnComplete bool `json:"exclude_noise_on_complete"`
+
+	// 提示词角色设定，按目标行业调整（如“银行柜面系统操作手册编写助手”）
+	AssistantPersona   string `json:"assistant_persona"`   // 生成步骤描述、标题、概述、常见问题时的角色
+	ReviewerPersona    string `json:"reviewer_persona"`    // 一致性审阅时的角色
+	SystemInstructions string `json:"system_instructions"` // 附加在角色设定之后的全局说明（行业用语、写作风格等），空表示不加
+}
+
+// DefaultSettings 内置默认设置（数据库中没有记录的项使用该值）
+func DefaultSettings() RuntimeSettings {
+	return RuntimeSettings{
+		AITimeoutSeconds:        30,
+		AIConcurrency:           4,
+		MaxScreenshotMB:         20,
+		MaxMediaMB:              500,
+		DefaultExportFormat:     "md",
+		ExportExpiryHours:       24,
+		WatermarkOpacity:        0.15,
+		DuplicateScreenDistance: 4,
+		InputCoalesceSeconds:    10,
+		AssistantPersona:        "政务软件操作手册编写助手",
+		ReviewerPersona:         "政务软件操作手册审校员",
+	}
+}
+
+// ErrInvalidSettings 设置取值不合法
+var ErrInvalidSettings = errors.New("invalid settings")
+
+var exportFormats = map[string]bool{"md": true, "mdzip": true, "json": true}
+
+// Validate 校验设置取值
+func (r RuntimeSettings) Validate() error {
+	switch {
+	case r.AITimeoutSeconds < 1 || r.AITimeoutSeconds > 600:
+		return fmt.Errorf("%w: ai_timeout_seconds must be 1-600", ErrInvalidSettings)
+	case r.AIConcurrency < 1 || r.AIConcurrency > 64:
+		return fmt.Errorf("%w: ai_concurrency must be 1-64", ErrInvalidSettings)
+	case r.MaxScreenshotMB < 1 || r.MaxScreenshotMB > 100:
+		return fmt.Errorf("%w: max_screenshot_mb must be 1-100", ErrInvalidSettings)
+	case r.MaxMediaMB < 1 || r.MaxMediaMB > 4096:
+		return fmt.Errorf("%w: max_media_mb must be 1-4096", ErrInvalidSettings)
+	case !exportFormats[r.DefaultExportFormat]:
+		return fmt.Errorf("%w: default_export_format must be md, mdzip or json", ErrInvalidSettings)
+	case r.ExportExpiryHours < 1 || r.ExportExpiryHours > 720:
+		return fmt.Errorf("%w: export_expiry_hours must be 1-720", ErrInvalidSettings)
+	case utf8.RuneCountInString(r.WatermarkText) > 100:
+		return fmt.Errorf("%w: watermark_text must be at most 100 characters", ErrInvalidSettings)
+	case r.WatermarkOpacity < 0.05 || r.WatermarkOpacity > 1:
+		return fmt.Errorf("%w: watermark_opacity must be 0.05-1", ErrInvalidSettings)
+	case strings.TrimSpace(r.AssistantPersona) == "" || utf8.RuneCountInString(r.AssistantPersona) > 50:
+		return fmt.Errorf("%w: assistant_persona must be 1-50 characters", ErrInvalidSettings)
+	case strings.TrimSpace(r.ReviewerPersona) == "" || utf8.RuneCountInString(r.ReviewerPersona) > 50:
+		return fmt.Errorf("%w: reviewer_persona must be 1-50 characters", ErrInvalidSettings)
+	case r.DuplicateScreenDistance < 0 || r.DuplicateScreenDistance > 16:
+		return fmt.Errorf("%w: duplicate_screen_distance must be 0-16", ErrInvalidSettings)
+	case r.InputCoalesceSeconds < 0 || r.InputCoalesceSeconds > 300:
+		return fmt.Errorf("%w: input_coalesce_seconds must be 0-300", ErrInvalidSettings)
+	case utf8.RuneCountInString(r.SystemInstructions) > 2000:
+		return fmt.Errorf("%w: system_instructions must be at most 2000 characters", ErrInvalidSettings)
+	}
+	if err := validateBannedPhrases(r.BannedPhrases); err != nil {
+		return fmt.Errorf("%w: banned_phrases %v", ErrInvalidSettings, err)
+	}
+	if r.WatermarkImage != "" {
+		mime, data, err := ParseDataURL(r.WatermarkImage)
+		if err != nil || imageExts[mime] == "" {
+			return fmt.Errorf("%w: watermark_image must be a PNG, JPEG, GIF or WebP data URL", ErrInvalidSettings)
+		}
+		if len(data) > maxWatermarkImageBytes {
+			return fmt.Errorf("%w: watermark_image must be at most 512 KB", ErrInvalidSettings)
+		}
+	}
+	return nil
+}
+
+// AITimeout VLM 请求超时
+func (r RuntimeSettings) AITimeout() time.Duration {
+	return time.Duration(r.AITimeoutSeconds) * time.Second
+}
+
+// ExportExpiry 后台导出产物的保留时长
+func (r RuntimeSettings) ExportExpiry() time.Duration {
+	return time.Duration(r.ExportExpiryHours) * time.Hour
+}
+
+// MaxScreenshotBytes 单张截图大小上限（字节）
+func (r RuntimeSettings) MaxScreenshotBytes() int {
+	return r.MaxScreenshotMB << 20
+}
+
+// MaxMediaBytes 单个会话附件大小上限（字节）
+func (r RuntimeSettings) MaxMediaBytes() int64 {
+	return int64(r.MaxMediaMB) << 20
+}
+
+// settingsTTL 缓存有效期：本实例修改立即生效，共享数据库的其他实例在该时间内生效
+const settingsTTL = 10 * time.Second
+
+var settingsCache struct {
+	sync.Mutex
+	val      RuntimeSettings
+	src      *gorm.DB
+	loadedAt time.Time
+}
+
+// CurrentSettings 返回当前生效的设置（带短期缓存，读取失败时使用默认值）
+func CurrentSettings() RuntimeSettings {
+	settingsCache.Lock()
+	defer settingsCache.Unlock()
+	if settingsCache.src == db.DB && db.DB != nil && time.Since(settingsCache.loadedAt) < settingsTTL {
+		return settingsCache.val
+	}
+	val, err := LoadSettings()
+	if err != nil {
+		val = DefaultSettings()
+	}
+	settingsCache.val, settingsCache.src, settingsCache.loadedAt = val, db.DB, time.Now()
+	return val
+}
+
+// LoadSettings 从数据库读取设置，未保存的项使用默认值
+func LoadSettings() (RuntimeSettings, error) {
+	val := DefaultSettings()
+	if db.DB == nil {
+		return val, nil
+	}
+	var rows []db.Setting
+	if err := db.DB.Find(&rows).Error; err != nil {
+		return val, err
+	}
+	for _, r := range rows {
+		// 逐项合并，库中残留的未知键或非法值不影响其余设置
+		next, err := mergeSettings(val, map[string]json.RawMessage{r.Key: json.RawMessage(r.Value)})
+		if err == nil && next.Validate() == nil {
+			val = next
+		}
+	}
+	return val, nil
+}
+
+// UpdateSettings 部分更新设置：校验后写入数据库并立即在本实例生效
+func UpdateSettings(patch map[string]json.RawMessage) (RuntimeSettings, error) {
+	cur, err := LoadSettings()
+	if err != nil {
+		return cur, err
+	}
+	next, err := mergeSettings(cur, patch)
+	if err != nil {
+		return cur, err
+	}
+	if err := next.Validate(); err != nil {
+		return cur, err
+	}
+
+	err = db.DB.Transaction(func(tx *gorm.DB) error {
+		for key, raw := range patch {
+			row := db.Setting{Key: key, Value: string(raw), UpdatedAt: time.Now()}
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&row).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return cur, err
+	}
+
+	settingsCache.Lock()
+	settingsCache.val, settingsCache.src, settingsCache.loadedAt = next, db.DB, time.Now()
+	settingsCache.Unlock()
+	// 并发上限调高时唤醒等待中的请求
+	aiLimiter.cond.Broadcast()
+	return next, nil
+}
+
+// ReloadSettings 丢弃缓存并从数据库重新读取设置（如从备份恢复后）
+func ReloadSettings() RuntimeSettings {
+	settingsCache.Lock()
+	settingsCache.loadedAt = time.Time{}
+	settingsCache.Unlock()
+	val := CurrentSettings()
+	aiLimiter.cond.Broadcast()
+	return val
+}
+
+// mergeSettings 将 JSON 片段覆盖到 base 上，未知键或类型错误时报错
+func mergeSettings(base RuntimeSettings, patch map[string]json.RawMessage) (RuntimeSettings, error) {
+	if len(patch) == 0 {
+		return base, nil
+	}
+	b, _ := json.Marshal(patch)
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&base); err != nil {
+		return base, fmt.Errorf("%w: %v", ErrInvalidSettings, err)
+	}
+	return base, nil
+}
+
+// ─────────────────────────────────────
+// VLM 并发限制
+// ─────────────────────────────────────
+
+// aiLimiter 全局 VLM 请求并发限制，上限随设置实时调整
+var aiLimiter struct {
+	sync.Mutex
+	cond  *sync.Cond
+	inUse int
+}
+
+func init() {
+	aiLimiter.cond = sync.NewCond(&aiLimiter.Mutex)
+}
+
+// acquireAISlot 等待一个 VLM 请求名额，返回释放函数
+func acquireAISlot() func() {
+	limit := CurrentSettings().AIConcurrency
+	aiLimiter.Lock()
+	for aiLimiter.inUse >= limit {
+		aiLimiter.cond.Wait()
+		limit = CurrentSettings().AIConcurrency
+	}
+	aiLimiter.inUse++
+	aiLimiter.Unlock()
+	return func() {
+		aiLimiter.Lock()
+		aiLimiter.inUse--
+		aiLimiter.Unlock()
+		aiLimiter.cond.Broadcast()
+	}
+}
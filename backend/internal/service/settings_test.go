@@ -0,0 +1,95 @@
+package service_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestRuntimeSettings(t *testing.T) {
+	setupDB(t)
+
+	if got := service.CurrentSettings(); !reflect.DeepEqual(got, service.DefaultSettings()) {
+		t.Fatalf("expected defaults on empty table, got %+v", got)
+	}
+
+	patch := map[string]json.RawMessage{
+		"ai_timeout_seconds":    json.RawMessage(`90`),
+		"default_export_format": json.RawMessage(`"mdzip"`),
+	}
+	updated, err := service.UpdateSettings(patch)
+	if err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	if updated.AITimeoutSeconds != 90 || updated.DefaultExportFormat != "mdzip" || updated.AIConcurrency != 4 {
+		t.Errorf("unexpected merged settings: %+v", updated)
+	}
+	// 本实例立即生效，无需等待缓存过期
+	if got := service.CurrentSettings(); !reflect.DeepEqual(got, updated) {
+		t.Errorf("CurrentSettings not refreshed: %+v", got)
+	}
+	if n := int64(0); db.DB.Model(&db.Setting{}).Count(&n).Error != nil || n != 2 {
+		t.Errorf("expected only patched keys stored, got %d rows", n)
+	}
+
+	bad := []map[string]json.RawMessage{
+		{"ai_concurrency": json.RawMessage(`0`)},
+		{"default_export_format": json.RawMessage(`"pdf"`)},
+		{"max_screenshot_mb": json.RawMessage(`"big"`)},
+		{"unknown_key": json.RawMessage(`1`)},
+	}
+	for _, p := range bad {
+		if _, err := service.UpdateSettings(p); !errors.Is(err, service.ErrInvalidSettings) {
+			t.Errorf("patch %v: expected ErrInvalidSettings, got %v", p, err)
+		}
+	}
+	if got := service.CurrentSettings(); !reflect.DeepEqual(got, updated) {
+		t.Errorf("rejected patches must not change settings: %+v", got)
+	}
+
+	// 库中残留的非法值被忽略，其余项照常生效
+	db.DB.Create(&db.Setting{Key: "ai_concurrency", Value: `-3`})
+	loaded, err := service.LoadSettings()
+	if err != nil {
+		t.Fatalf("LoadSettings: %v", err)
+	}
+	if loaded.AIConcurrency != 4 || loaded.AITimeoutSeconds != 90 {
+		t.Errorf("unexpected loaded settings: %+v", loaded)
+	}
+}
+
+func TestPromptPersonaSettings(t *testing.T) {
+	setupDB(t)
+	var prompts []string
+	aiSvc := fakeOllama(t, func(prompt string) string {
+		prompts = append(prompts, prompt)
+		return "点击提交按钮"
+	})
+	req := service.VLMRequest{StepAction: "click", TargetElement: "提交按钮"}
+
+	aiSvc.GenerateStepDescription(req)
+	if !strings.HasPrefix(prompts[0], "你是政务软件操作手册编写助手。") {
+		t.Errorf("default persona expected:\n%s", prompts[0])
+	}
+
+	_, err := service.UpdateSettings(map[string]json.RawMessage{
+		"assistant_persona":   json.RawMessage(`"银行柜面系统操作手册编写助手"`),
+		"system_instructions": json.RawMessage(`"办理业务的人统一称为柜员。"`),
+	})
+	if err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	aiSvc.GenerateStepDescription(req)
+	if !strings.HasPrefix(prompts[1], "你是银行柜面系统操作手册编写助手。\n办理业务的人统一称为柜员。\n") {
+		t.Errorf("persona and instructions should lead the prompt:\n%s", prompts[1])
+	}
+
+	if _, err := service.UpdateSettings(map[string]json.RawMessage{"reviewer_persona": json.RawMessage(`" "`)}); !errors.Is(err, service.ErrInvalidSettings) {
+		t.Errorf("blank persona: expected ErrInvalidSettings, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// 分享链接有效期
+const (
+	DefaultShareTTL = 7 * 24 * time.Hour
+	MaxShareTTL     = 90 * 24 * time.Hour
+)
+
+var (
+	// ErrShareNotApproved 只有已审批的文档可以对外分享
+	ErrShareNotApproved = errors.New("only approved documents can be shared")
+	// ErrShareInvalid 分享令牌不存在、已撤销、已过期，或文档已不再是已审批状态
+	ErrShareInvalid = errors.New("share link is invalid or expired")
+)
+
+// CreateShare 为已审批文档生成分享链接，返回记录与明文令牌（令牌只在此时返回一次）
+func CreateShare(doc *db.GeneratedDocument, ttl time.Duration, note string) (*db.DocumentShare, string, error) {
+	if doc.Status != "approved" {
+		return nil, "", ErrShareNotApproved
+	}
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	share := &db.DocumentShare{
+		DocumentID:  doc.ID,
+		SessionID:   doc.SessionID,
+		TokenHash:   hashShareToken(token),
+		TokenPrefix: token[:6],
+		Note:        note,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	if err := db.DB.Create(share).Error; err != nil {
+		return nil, "", err
+	}
+	return share, token, nil
+}
+
+// ResolveShare 校验分享令牌并返回对应的文档，同时记录一次访问
+func ResolveShare(token string, now time.Time) (*db.DocumentShare, *db.GeneratedDocument, error) {
+	var share db.DocumentShare
+	if token == "" || db.DB.First(&share, "token_hash = ?", hashShareToken(token)).Error != nil {
+		return nil, nil, ErrShareInvalid
+	}
+	if share.RevokedAt != nil || !now.Before(share.ExpiresAt) {
+		return nil, nil, ErrShareInvalid
+	}
+	var doc db.GeneratedDocument
+	if err := db.DB.First(&doc, "id = ?", share.DocumentID).Error; err != nil || doc.Status != "approved" {
+		return nil, nil, ErrShareInvalid
+	}
+	db.DB.Model(&share).Updates(map[string]interface{}{
+		"access_count":   gorm.Expr("access_count + 1"),
+		"last_access_at": now,
+	})
+	return &share, &doc, nil
+}
+
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RenderShare 将文档渲染为独立的只读 HTML 页面：截图烧录遮蔽区域后内嵌，带导出水印，不含站内导航
+func (s *DocService) RenderShare(doc *db.GeneratedDocument) ([]byte, error) {
+	content, err := s.LoadDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	content = s.RedactContent(content, RedactBlack)
+	page := siteDoc{Title: content.SessionTitle, Content: content}
+	if doc.ApprovedAt != nil {
+		page.ApprovedAt = doc.ApprovedAt.Format("2006-01-02")
+	}
+	var buf bytes.Buffer
+	err = siteTemplates.ExecuteTemplate(&buf, "share", map[string]interface{}{
+		"Title": page.Title, "Watermark": watermarkCSS(content.Watermark), "Num": newDocNumbering(content.Numbering),
+		"Project": content.ProjectName, "Doc": page, "Metadata": SortedMetadata(content.Metadata),
+	})
+	return buf.Bytes(), err
+}
@@ -0,0 +1,61 @@
+package service_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestDocumentShare(t *testing.T) {
+	setupDB(t)
+	_, sessionID := seedSessionWithSteps(t, 2)
+	svc := service.NewDocService()
+	content, _ := svc.BuildDocument(sessionID)
+	doc, _ := svc.SaveGeneratedDoc(sessionID, content)
+
+	if _, _, err := service.CreateShare(doc, time.Hour, ""); !errors.Is(err, service.ErrShareNotApproved) {
+		t.Fatalf("draft document: expected ErrShareNotApproved, got %v", err)
+	}
+	now := time.Now()
+	db.DB.Model(doc).Updates(map[string]interface{}{"status": "approved", "approved_at": &now})
+
+	share, token, err := service.CreateShare(doc, time.Hour, "供应商 A")
+	if err != nil {
+		t.Fatalf("CreateShare: %v", err)
+	}
+	if share.TokenHash == token || !strings.HasPrefix(token, share.TokenPrefix) {
+		t.Error("token should be stored hashed, with a recognisable prefix")
+	}
+
+	_, got, err := service.ResolveShare(token, now)
+	if err != nil || got.ID != doc.ID {
+		t.Fatalf("ResolveShare: %v", err)
+	}
+	html, err := svc.RenderShare(got)
+	if err != nil || !containsAll(string(html), "测试录制会话", "点击登录按钮") || strings.Contains(string(html), "<nav>") {
+		t.Errorf("unexpected share page (%v):\n%s", err, html)
+	}
+	db.DB.First(share, "id = ?", share.ID)
+	if share.AccessCount != 1 || share.LastAccessAt == nil {
+		t.Errorf("access should be recorded: %+v", share)
+	}
+
+	for name, check := range map[string]func() error{
+		"unknown token": func() error { _, _, err := service.ResolveShare("nope", now); return err },
+		"expired":       func() error { _, _, err := service.ResolveShare(token, now.Add(2*time.Hour)); return err },
+		"unapproved": func() error {
+			db.DB.Model(doc).Update("status", "draft")
+			defer db.DB.Model(doc).Update("status", "approved")
+			_, _, err := service.ResolveShare(token, now)
+			return err
+		},
+	} {
+		if err := check(); !errors.Is(err, service.ErrShareInvalid) {
+			t.Errorf("%s: expected ErrShareInvalid, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// SiteFile 静态站点中的一个文件（相对路径）
+type SiteFile struct {
+	Path string
+	Data []byte
+}
+
+// siteDoc 站点中的一篇文档
+type siteDoc struct {
+	Page       string
+	Title      string
+	Summary    string
+	ApprovedAt string
+	StepCount  int
+	Content    *GeneratedDocContent
+}
+
+// BuildSite 将项目下所有已审批文档渲染为可导航的静态站点：
+// index.html（目录、摘要 + 搜索）、每篇文档一页、images/ 截图、search-index.js 搜索索引。
+// 同一会话有多份已审批文档时取最新一份
+func (s *DocService) BuildSite(projectID string) ([]SiteFile, error) {
+	var project db.Project
+	if err := db.DB.First(&project, "id = ?", projectID).Error; err != nil {
+		return nil, err
+	}
+	docs, err := latestApprovedDocs(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []SiteFile
+	sink := newImageSink(func(path string, data []byte) error {
+		files = append(files, SiteFile{Path: path, Data: data})
+		return nil
+	})
+
+	var pages []siteDoc
+	for i := range docs {
+		doc := &docs[i]
+		content, err := s.LoadDocument(doc)
+		if err != nil {
+			return nil, fmt.Errorf("document %s: %w", doc.ID, err)
+		}
+		// 发布的站点总是烧录遮蔽区域
+		if content, err = sink.externalize(s.RedactContent(content, RedactBlack)); err != nil {
+			return nil, err
+		}
+		page := siteDoc{
+			Page:      sitePage(doc.ID),
+			Title:     content.SessionTitle,
+			Summary:   docSummary(content),
+			StepCount: len(allDocSteps(content.BusinessView)),
+			Content:   content,
+		}
+		if doc.ApprovedAt != nil {
+			page.ApprovedAt = doc.ApprovedAt.Format("2006-01-02")
+		}
+		pages = append(pages, page)
+	}
+
+	render := func(name string, data interface{}) ([]byte, error) {
+		var buf bytes.Buffer
+		err := siteTemplates.ExecuteTemplate(&buf, name, data)
+		return buf.Bytes(), err
+	}
+	generatedAt := time.Now().Format("2006-01-02 15:04")
+	watermark := watermarkCSS(CurrentWatermark())
+	for _, p := range pages {
+		html, err := render("doc", map[string]interface{}{
+			"Title": p.Title, "Watermark": watermark, "Num": newDocNumbering(p.Content.Numbering),
+			"Project": project.Name, "Doc": p, "Pages": pages, "GeneratedAt": generatedAt,
+			"Metadata": SortedMetadata(p.Content.Metadata),
+		})
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, SiteFile{Path: p.Page, Data: html})
+	}
+	index, err := render("index", map[string]interface{}{
+		"Title": project.Name, "Watermark": watermark,
+		"Project": project.Name, "Pages": pages, "GeneratedAt": generatedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, SiteFile{Path: "index.html", Data: index})
+
+	// 搜索索引以 JS 形式提供，file:// 直接打开也能用
+	type entry struct {
+		Page  string `json:"page"`
+		Title string `json:"title"`
+		Text  string `json:"text"`
+	}
+	entries := make([]entry, 0, len(pages))
+	for _, p := range pages {
+		var sb strings.Builder
+		for _, sec := range p.Content.BusinessView {
+			sb.WriteString(sec.Title + " " + sec.Summary + " ")
+			for _, st := range sec.Steps {
+				sb.WriteString(st.Description + " ")
+			}
+			for _, f := range sec.FAQ {
+				sb.WriteString(f.Question + " " + f.Answer + " ")
+			}
+		}
+		entries = append(entries, entry{Page: p.Page, Title: p.Title, Text: sb.String()})
+	}
+	idx, _ := json.Marshal(entries)
+	files = append(files, SiteFile{Path: "search-index.js", Data: []byte("window.GPILOT_SEARCH=" + string(idx) + ";\n")})
+	return files, nil
+}
+
+// WriteSiteZip 将站点打包为 zip
+func WriteSiteZip(w io.Writer, files []SiteFile) error {
+	zw := zip.NewWriter(w)
+	put := zipPut(zw)
+	for _, f := range files {
+		if err := put(f.Path, f.Data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// WriteSiteDir 将站点写入目录：先写入同级临时目录再整体替换，发布过程中旧站点保持可访问
+func WriteSiteDir(dir string, files []SiteFile) error {
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), ".site-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	for _, f := range files {
+		p := filepath.Join(tmp, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, f.Data, 0o644); err != nil {
+			return err
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dir)
+}
+
+// watermarkCSS 水印层样式：以固定定位的平铺背景覆盖整页，浏览器打印 / 另存为 PDF 时每页都会带上
+func watermarkCSS(wm *Watermark) template.CSS {
+	if wm == nil {
+		return ""
+	}
+	return template.CSS(`background-image:url("` + wm.TileSVG() + `")`)
+}
+
+// imgSrc 截图地址：内嵌的 data:image/ 截图需显式放行，其余地址仍按普通 URL 过滤
+func imgSrc(u string) interface{} {
+	if strings.HasPrefix(u, "data:image/") {
+		return template.URL(u)
+	}
+	return u
+}
+
+func allDocSteps(sections []DocSection) []DocStep {
+	var steps []DocStep
+	for _, sec := range sections {
+		steps = append(steps, sec.Steps...)
+	}
+	return steps
+}
+
+var siteTemplates = template.Must(template.New("site").Funcs(template.FuncMap{"timing": TimingHint, "img": imgSrc}).Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body{margin:0;font-family:-apple-system,"PingFang SC","Microsoft YaHei",sans-serif;color:#1f2937;background:#f8fafc}
+header{background:#1e3a8a;color:#fff;padding:14px 24px}header a{color:#fff;text-decoration:none}
+.wrap{display:flex;max-width:1200px;margin:0 auto}
+nav{width:240px;padding:20px;border-right:1px solid #e5e7eb;min-height:calc(100vh - 50px)}
+nav a{display:block;padding:4px 0;color:#1e40af;text-decoration:none}nav a.active{font-weight:600}
+main{flex:1;padding:24px 32px;background:#fff}
+.step{margin:18px 0;padding-bottom:18px;border-bottom:1px solid #f1f5f9}
+.step img{max-width:100%;border:1px solid #e5e7eb;margin-top:8px}
+.summary{color:#475569}.meta{color:#64748b;font-size:13px}
+#q{width:100%;padding:8px;font-size:15px;box-sizing:border-box}
+#results li{margin:6px 0}
+.watermark{position:fixed;top:0;left:0;right:0;bottom:0;pointer-events:none;z-index:1000;background-repeat:repeat;-webkit-print-color-adjust:exact;print-color-adjust:exact}
+</style>
+</head>
+<body>{{with .Watermark}}<div class="watermark" style="{{.}}"></div>{{end}}{{end}}
+
+{{define "index"}}{{template "head" .}}
+<header><a href="index.html">{{.Project}}</a> · 操作手册</header>
+<div class="wrap"><main>
+<input id="q" placeholder="搜索操作手册…" autocomplete="off">
+<ul id="results"></ul>
+<h2>文档目录</h2>
+{{if not .Pages}}<p class="meta">暂无已审批文档</p>{{end}}
+<ul>{{range .Pages}}<li><a href="{{.Page}}">{{.Title}}</a> <span class="meta">{{.StepCount}} 步{{if .ApprovedAt}} · 审批于 {{.ApprovedAt}}{{end}}</span>{{if .Summary}}<div class="summary">{{.Summary}}</div>{{end}}</li>{{end}}</ul>
+<p class="meta">生成时间：{{.GeneratedAt}}</p>
+</main></div>
+<script src="search-index.js"></script>
+<script>
+var q=document.getElementById('q'),out=document.getElementById('results');
+q.addEventListener('input',function(){
+  var k=q.value.trim().toLowerCase();out.innerHTML='';if(!k)return;
+  (window.GPILOT_SEARCH||[]).forEach(function(d){
+    var i=d.text.toLowerCase().indexOf(k);if(i<0&&d.title.toLowerCase().indexOf(k)<0)return;
+    var li=document.createElement('li'),a=document.createElement('a');a.href=d.page;a.textContent=d.title;li.appendChild(a);
+    if(i>=0){var s=document.createElement('div');s.className='meta';s.textContent='…'+d.text.substr(Math.max(0,i-20),60)+'…';li.appendChild(s);}
+    out.appendChild(li);
+  });
+});
+</script>
+</body></html>{{end}}
+
+{{define "doc"}}{{template "head" .}}
+<header><a href="index.html">{{.Project}}</a> · {{.Doc.Title}}</header>
+<div class="wrap">
+<nav>{{$cur := .Doc.Page}}{{range .Pages}}<a href="{{.Page}}"{{if eq .Page $cur}} class="active"{{end}}>{{.Title}}</a>{{end}}</nav>
+{{template "doc-main" .}}</div>
+</body></html>{{end}}
+
+{{define "share"}}{{template "head" .}}
+<header>{{.Project}} · {{.Doc.Title}}</header>
+<div class="wrap">{{template "doc-main" .}}</div>
+</body></html>{{end}}
+
+{{define "doc-main"}}<main>
+<h1>{{.Doc.Title}}</h1>
+{{$timing := .Doc.Content.ShowTiming}}<p class="meta">项目：{{.Project}} · 生成时间：{{.Doc.Content.GeneratedAt}}{{if .Doc.ApprovedAt}} · 审批于 {{.Doc.ApprovedAt}}{{end}}{{if $timing}}{{with timing .Doc.Content.DurationMS}} · 预计耗时：{{.}}{{end}}{{end}}</p>
+{{with .Metadata}}<table class="meta">{{range .}}<tr><th align="left">{{.Key}}</th><td>{{.Value}}</td></tr>{{end}}</table>{{end}}
+{{range .Doc.Content.BusinessView}}
+<section>
+<h2>{{$.Num.Section .}}{{if $timing}}{{with timing .DurationMS}}（{{.}}）{{end}}{{end}}</h2>
+{{if .Transition}}<p class="meta">{{.Transition}}</p>{{end}}
+{{if .Summary}}<p class="summary">{{.Summary}}</p>{{end}}
+{{range .FAQ}}<p><strong>问：{{.Question}}</strong><br>答：{{.Answer}}</p>{{end}}
+{{range .Steps}}<div class="step"><h3>{{$.Num.Step .}}{{if $timing}}{{with timing .ElapsedMS}}（{{.}}）{{end}}{{end}}</h3><p>{{.Description}}</p>{{if .ScreenshotURL}}<img src="{{img .ScreenshotURL}}" alt="{{.ScreenshotAlt}}" loading="lazy">{{end}}{{if .PositionHint}}<p class="meta">提示：{{.PositionHint}}</p>{{end}}</div>{{end}}
+</section>
+{{end}}
+</main>{{end}}
+`))
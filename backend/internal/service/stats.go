@@ -0,0 +1,167 @@
+package service
+
+import (
+	"io/fs"
+	"math"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// UsageStats 管理看板使用统计
+type UsageStats struct {
+	Totals          StatsTotals     `json:"totals"`
+	SessionsByWeek  []WeekCount     `json:"sessions_by_week"`
+	StepsPerSession StepsPerSession `json:"steps_per_session"`
+	Providers       []ProviderUsage `json:"providers"`
+	AvgGenerateMS   float64         `json:"avg_generation_ms"` // AI 生成单步描述的平均耗时
+	Storage         StorageUsage    `json:"storage"`
+	GeneratedAt     string          `json:"generated_at"`
+}
+
+// StatsTotals 各类数据总数
+type StatsTotals struct {
+	Projects          int64 `json:"projects"`
+	Sessions          int64 `json:"sessions"`
+	Steps             int64 `json:"steps"`
+	Documents         int64 `json:"documents"`
+	ApprovedDocuments int64 `json:"approved_documents"`
+}
+
+// WeekCount 某周（周一开始）录制的会话数
+type WeekCount struct {
+	WeekStart string `json:"week_start"`
+	Count     int    `json:"count"`
+}
+
+// StepsPerSession 会话步骤数分布
+type StepsPerSession struct {
+	Avg    float64 `json:"avg"`
+	Median int     `json:"median"`
+	Max    int     `json:"max"`
+}
+
+// ProviderUsage 生成步骤描述的提供商分布
+type ProviderUsage struct {
+	Provider string `json:"provider"`
+	Steps    int64  `json:"steps"`
+}
+
+// StorageUsage 存储占用（字节）
+type StorageUsage struct {
+	ScreenshotBytes int64 `json:"screenshot_bytes"` // 数据库中截图 data URL 的总长度
+	StorageDirBytes int64 `json:"storage_dir_bytes"`
+}
+
+// CollectStats 汇总使用统计；weeks 为会话趋势覆盖的周数（含本周）
+func CollectStats(storagePath string, weeks int, now time.Time) (*UsageStats, error) {
+	st := &UsageStats{GeneratedAt: now.Format(time.RFC3339)}
+
+	counts := []struct {
+		model interface{}
+		where string
+		dst   *int64
+	}{
+		{&db.Project{}, "", &st.Totals.Projects},
+		{&db.Session{}, "", &st.Totals.Sessions},
+		{&db.RecordingStep{}, "", &st.Totals.Steps},
+		{&db.GeneratedDocument{}, "", &st.Totals.Documents},
+		{&db.GeneratedDocument{}, "status = 'approved'", &st.Totals.ApprovedDocuments},
+	}
+	for _, c := range counts {
+		q := db.DB.Model(c.model)
+		if c.where != "" {
+			q = q.Where(c.where)
+		}
+		if err := q.Count(c.dst).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// 按周分桶在 Go 中完成，避免依赖各数据库的日期函数
+	start := weekStart(now).AddDate(0, 0, -7*(weeks-1))
+	var createdAt []time.Time
+	if err := db.DB.Model(&db.Session{}).Where("created_at >= ?", start).Pluck("created_at", &createdAt).Error; err != nil {
+		return nil, err
+	}
+	buckets := make([]WeekCount, weeks)
+	for i := range buckets {
+		buckets[i].WeekStart = start.AddDate(0, 0, 7*i).Format("2006-01-02")
+	}
+	for _, t := range createdAt {
+		if i := int(math.Round(weekStart(t.In(now.Location())).Sub(start).Hours() / (24 * 7))); i >= 0 && i < weeks {
+			buckets[i].Count++
+		}
+	}
+	st.SessionsByWeek = buckets
+
+	var perSession []int
+	if err := db.DB.Model(&db.RecordingStep{}).Group("session_id").Pluck("COUNT(*)", &perSession).Error; err != nil {
+		return nil, err
+	}
+	if len(perSession) > 0 {
+		sort.Ints(perSession)
+		sum := 0
+		for _, n := range perSession {
+			sum += n
+		}
+		// 没有步骤的会话也计入平均值
+		if st.Totals.Sessions > 0 {
+			st.StepsPerSession.Avg = float64(sum) / float64(st.Totals.Sessions)
+		}
+		st.StepsPerSession.Median = perSession[len(perSession)/2]
+		st.StepsPerSession.Max = perSession[len(perSession)-1]
+	}
+
+	if err := db.DB.Model(&db.RecordingStep{}).
+		Select("ai_provider AS provider, COUNT(*) AS steps").
+		Where("ai_provider <> ''").Group("ai_provider").Order("steps DESC").
+		Scan(&st.Providers).Error; err != nil {
+		return nil, err
+	}
+
+	var avg *float64
+	if err := db.DB.Model(&db.RecordingStep{}).Where("ai_latency_ms > 0").
+		Select("AVG(ai_latency_ms)").Scan(&avg).Error; err != nil {
+		return nil, err
+	}
+	if avg != nil {
+		st.AvgGenerateMS = *avg
+	}
+
+	var shotBytes *int64
+	if err := db.DB.Model(&db.Screenshot{}).Select("SUM(LENGTH(data_url) + LENGTH(COALESCE(element_url, '')))").Scan(&shotBytes).Error; err != nil {
+		return nil, err
+	}
+	if shotBytes != nil {
+		st.Storage.ScreenshotBytes = *shotBytes
+	}
+	st.Storage.StorageDirBytes = dirSize(storagePath)
+	return st, nil
+}
+
+// weekStart 返回 t 所在周的周一零点
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	y, m, d := t.AddDate(0, 0, -offset).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func dirSize(root string) int64 {
+	var total int64
+	if root == "" {
+		return 0
+	}
+	_ = filepath.WalkDir(root, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
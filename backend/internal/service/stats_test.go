@@ -0,0 +1,58 @@
+package service_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestCollectStats(t *testing.T) {
+	setupDB(t)
+	_, sessionID := seedSessionWithSteps(t, 4)
+	_, _ = seedSessionWithSteps(t, 2)
+
+	// 一个会话放到三周前，验证按周分桶
+	now := time.Date(2026, 3, 18, 10, 0, 0, 0, time.Local) // 周三
+	db.DB.Model(&db.Session{}).Where("id = ?", sessionID).UpdateColumn("created_at", now.AddDate(0, 0, -21))
+	db.DB.Model(&db.Session{}).Where("id <> ?", sessionID).UpdateColumn("created_at", now)
+
+	var steps []db.RecordingStep
+	db.DB.Where("session_id = ?", sessionID).Order("step_index").Find(&steps)
+	db.DB.Model(&steps[0]).Updates(map[string]interface{}{"AIProvider": "gemini", "AILatencyMS": 100})
+	db.DB.Model(&steps[1]).Updates(map[string]interface{}{"AIProvider": "gemini", "AILatencyMS": 300})
+	db.DB.Model(&steps[2]).Updates(map[string]interface{}{"AIProvider": "ollama", "AILatencyMS": 200})
+	db.DB.Create(&db.Screenshot{SessionID: sessionID, StepID: steps[0].ID, DataURL: "data:image/png;base64,AAAA"})
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.bin"), make([]byte, 10), 0o644)
+
+	st, err := service.CollectStats(dir, 4, now)
+	if err != nil {
+		t.Fatalf("CollectStats: %v", err)
+	}
+	if st.Totals.Sessions != 2 || st.Totals.Steps != 6 || st.Totals.Projects != 2 {
+		t.Errorf("unexpected totals: %+v", st.Totals)
+	}
+	if len(st.SessionsByWeek) != 4 || st.SessionsByWeek[0].Count != 1 || st.SessionsByWeek[3].Count != 1 {
+		t.Errorf("unexpected weekly buckets: %+v", st.SessionsByWeek)
+	}
+	if st.SessionsByWeek[3].WeekStart != "2026-03-16" {
+		t.Errorf("weeks should start on Monday, got %s", st.SessionsByWeek[3].WeekStart)
+	}
+	if st.StepsPerSession.Avg != 3 || st.StepsPerSession.Max != 4 {
+		t.Errorf("unexpected steps per session: %+v", st.StepsPerSession)
+	}
+	if len(st.Providers) != 2 || st.Providers[0].Provider != "gemini" || st.Providers[0].Steps != 2 {
+		t.Errorf("unexpected provider distribution: %+v", st.Providers)
+	}
+	if st.AvgGenerateMS != 200 {
+		t.Errorf("expected avg generation 200ms, got %v", st.AvgGenerateMS)
+	}
+	if st.Storage.ScreenshotBytes != int64(len("data:image/png;base64,AAAA")) || st.Storage.StorageDirBytes != 10 {
+		t.Errorf("unexpected storage usage: %+v", st.Storage)
+	}
+}
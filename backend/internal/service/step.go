@@ -0,0 +1,169 @@
+package service
+
+import (
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// StepInput 一次步骤上报：步骤本身 + 可选截图
+type StepInput struct {
+	Step          db.RecordingStep
+	Screenshot    *db.Screenshot    // 为 nil 时不保存截图
+	Requests      []db.StepRequest  // 已脱敏的网络请求元数据（见 SanitizeRequests）
+	Logs          []db.StepLog      // 控制台输出（见 SanitizeConsoleEntries）
+	MaskingEvents []db.MaskingEvent // 脱敏审计（命中的规则与类别，不含原文）
+	SkipDuplicate bool              // 重复提交时直接返回原步骤，不入库
+}
+
+// IngestResult 上报结果；Duplicate / Replayed / Coalesced 为 true 时 Step 为已存在的原步骤
+type IngestResult struct {
+	Step      *db.RecordingStep
+	Duplicate bool // 命中同一 DOM 指纹的重复提交（SkipDuplicate）
+	Replayed  bool // 幂等键已存在，属于客户端重试
+	Coalesced bool // 逐键上报的输入事件，已并入上一步
+}
+
+// IngestStep 在一个事务内完成序号分配、幂等与重复检测、连续输入合并、步骤与截图（及网络请求、控制台日志、脱敏审计）写入及耗时更新，
+// 返回从库中重新读取的完整步骤
+func IngestStep(gdb *gorm.DB, in StepInput) (*IngestResult, error) {
+	result := &IngestResult{}
+	err := gdb.Transaction(func(tx *gorm.DB) error {
+		s := in.Step
+
+		// 先分配序号（首条语句即为写操作，拿到锁后再做后续读取）
+		requested := s.StepIndex
+		index, err := nextStepIndex(tx, s.SessionID, requested)
+		if err != nil {
+			return err
+		}
+		s.StepIndex = index
+
+		// 不入库时归还刚分配的序号
+		existing := func(id string) error {
+			var original db.RecordingStep
+			if err := tx.First(&original, "id = ?", id).Error; err != nil {
+				return err
+			}
+			result.Step = &original
+			if requested > 0 {
+				return nil
+			}
+			return tx.Model(&db.Session{}).Where("id = ?", s.SessionID).
+				UpdateColumn("step_seq", gorm.Expr("step_seq - 1")).Error
+		}
+
+		// 客户端重试：同一会话内的同一幂等键直接返回原记录
+		if s.IdempotencyKey != nil {
+			var prev db.RecordingStep
+			err := tx.Select("id").Where("session_id = ? AND idempotency_key = ?", s.SessionID, *s.IdempotencyKey).
+				Limit(1).Find(&prev).Error
+			if err != nil {
+				return err
+			}
+			if prev.ID != "" {
+				result.Replayed = true
+				return existing(prev.ID)
+			}
+		}
+
+		last := LastStep(tx, s.SessionID)
+
+		// 逐键上报的插件：同一元素上连续的输入事件并入上一步，只保留最终的值
+		window := int64(CurrentSettings().InputCoalesceSeconds) * 1000
+		if CanCoalesceInput(last, &s, window) {
+			if err := coalesceInput(tx, last, in); err != nil {
+				return err
+			}
+			result.Coalesced = true
+			return existing(last.ID)
+		}
+
+		// 同一 DOM 指纹上的重复提交：SkipDuplicate 时直接返回原步骤，否则标记后入库
+		if IsDuplicateStep(last, &s) {
+			if in.SkipDuplicate {
+				result.Duplicate = true
+				return existing(DuplicateRoot(last))
+			}
+			s.DuplicateOf = DuplicateRoot(last)
+		}
+
+		if err := tx.Create(&s).Error; err != nil {
+			return err
+		}
+		if in.Screenshot != nil {
+			shot := *in.Screenshot
+			if err := AttachScreenshot(tx, &s, &shot); err != nil {
+				return err
+			}
+		}
+		if _, err := AttachStepRequests(tx, &s, in.Requests); err != nil {
+			return err
+		}
+		if _, err := AttachStepLogs(tx, &s, in.Logs); err != nil {
+			return err
+		}
+		if err := AttachMaskingEvents(tx, &s, in.MaskingEvents); err != nil {
+			return err
+		}
+		if err := RecomputeTiming(tx, s.SessionID); err != nil {
+			return err
+		}
+
+		var saved db.RecordingStep
+		if err := tx.First(&saved, "id = ?", s.ID).Error; err != nil {
+			return err
+		}
+		result.Step = &saved
+		return nil
+	})
+	if err != nil && in.Step.IdempotencyKey != nil {
+		// 并发重试时另一请求先写入同一幂等键，本事务因唯一索引冲突回滚：改为返回已写入的记录
+		var prev db.RecordingStep
+		if gdb.Where("session_id = ? AND idempotency_key = ?", in.Step.SessionID, *in.Step.IdempotencyKey).
+			Limit(1).Find(&prev).Error == nil && prev.ID != "" {
+			return &IngestResult{Step: &prev, Replayed: true}, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// nextStepIndex 通过会话上的 step_seq 计数器分配步骤序号。
+// 计数器用 UPDATE 自增，数据库会对该行加锁（SQLite 为整库写锁），
+// 并发上报因此在事务内串行化，不会得到重复序号；requested > 0 时沿用客户端序号，计数器只进不退
+func nextStepIndex(tx *gorm.DB, sessionID string, requested int) (int, error) {
+	if requested > 0 {
+		err := tx.Model(&db.Session{}).Where("id = ? AND step_seq < ?", sessionID, requested).
+			UpdateColumn("step_seq", requested).Error
+		return requested, err
+	}
+
+	res := tx.Model(&db.Session{}).Where("id = ?", sessionID).UpdateColumn("step_seq", gorm.Expr("step_seq + 1"))
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	if res.RowsAffected == 0 {
+		// 会话记录不存在（历史数据），退回按现有最大序号计算
+		var max int
+		err := tx.Model(&db.RecordingStep{}).Where("session_id = ?", sessionID).
+			Select("COALESCE(MAX(step_index), 0)").Scan(&max).Error
+		return max + 1, err
+	}
+	var seq int
+	err := tx.Model(&db.Session{}).Where("id = ?", sessionID).Select("step_seq").Scan(&seq).Error
+	return seq, err
+}
+
+// RepairStepIndexes 修复会话中重复或不连续的步骤序号：按 (step_index, created_at) 重新编号为 1..N，
+// 返回修复前存在重复的序号个数
+func RepairStepIndexes(tx *gorm.DB, sessionID string) (int64, error) {
+	var duplicated []int
+	err := tx.Model(&db.RecordingStep{}).Where("session_id = ?", sessionID).
+		Group("step_index").Having("COUNT(*) > 1").Pluck("step_index", &duplicated).Error
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(duplicated)), RenumberSteps(tx, sessionID)
+}
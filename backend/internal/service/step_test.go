@@ -0,0 +1,248 @@
+package service_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestIngestStep(t *testing.T) {
+	setupDB(t)
+	sess := db.Session{ProjectID: "p", Title: "录制"}
+	db.DB.Create(&sess)
+
+	t.Run("StepWithScreenshot", func(t *testing.T) {
+		res, err := service.IngestStep(db.DB, service.StepInput{
+			Step:       db.RecordingStep{SessionID: sess.ID, Action: "click"},
+			Screenshot: &db.Screenshot{DataURL: "data:image/png;base64,AAAA", Width: 10, Height: 10},
+		})
+		if err != nil || res.Duplicate || res.Replayed {
+			t.Fatalf("IngestStep: res=%+v err=%v", res, err)
+		}
+		step := res.Step
+		if step.StepIndex != 1 || step.ScreenshotID == "" {
+			t.Fatalf("step not fully populated: %+v", step)
+		}
+		var shot db.Screenshot
+		if err := db.DB.First(&shot, "id = ?", step.ScreenshotID).Error; err != nil || shot.StepID != step.ID || shot.SessionID != sess.ID {
+			t.Errorf("screenshot not linked: %+v err=%v", shot, err)
+		}
+	})
+
+	t.Run("RollsBackOnScreenshotFailure", func(t *testing.T) {
+		var existing db.Screenshot
+		db.DB.First(&existing)
+		// 主键冲突导致截图写入失败，步骤也不应留下
+		_, err := service.IngestStep(db.DB, service.StepInput{
+			Step:       db.RecordingStep{SessionID: sess.ID, Action: "input"},
+			Screenshot: &db.Screenshot{Base: db.Base{ID: existing.ID}, DataURL: "data:,x"},
+		})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		var count int64
+		db.DB.Model(&db.RecordingStep{}).Where("session_id = ?", sess.ID).Count(&count)
+		if count != 1 {
+			t.Errorf("expected dangling step to be rolled back, got %d steps", count)
+		}
+	})
+}
+
+func TestIngestStep_ConcurrentIndexes(t *testing.T) {
+	// 文件库 + busy_timeout，模拟多个连接并发上报
+	var err error
+	db.DB, err = gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "race.db")+"?_busy_timeout=5000"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migrate(db.DB); err != nil {
+		t.Fatal(err)
+	}
+	sess := db.Session{ProjectID: "p", Title: "并发"}
+	db.DB.Create(&sess)
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := service.IngestStep(db.DB, service.StepInput{
+				Step: db.RecordingStep{SessionID: sess.ID, Action: "click", DOMFingerprint: fmt.Sprintf("fp-%d", i)},
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("IngestStep: %v", err)
+		}
+	}
+
+	var indexes []int
+	db.DB.Model(&db.RecordingStep{}).Where("session_id = ?", sess.ID).Order("step_index").Pluck("step_index", &indexes)
+	for i, idx := range indexes {
+		if idx != i+1 {
+			t.Fatalf("expected indexes 1..%d, got %v", n, indexes)
+		}
+	}
+}
+
+func TestRepairStepIndexes(t *testing.T) {
+	setupDB(t)
+	sess := db.Session{ProjectID: "p", Title: "修复"}
+	db.DB.Create(&sess)
+	for _, idx := range []int{1, 2, 2, 3, 3} {
+		db.DB.Create(&db.RecordingStep{SessionID: sess.ID, StepIndex: idx, Action: "click"})
+	}
+
+	duplicated, err := service.RepairStepIndexes(db.DB, sess.ID)
+	if err != nil || duplicated != 2 {
+		t.Fatalf("RepairStepIndexes: duplicated=%d err=%v", duplicated, err)
+	}
+	var indexes []int
+	db.DB.Model(&db.RecordingStep{}).Where("session_id = ?", sess.ID).Order("step_index").Pluck("step_index", &indexes)
+	if fmt.Sprint(indexes) != "[1 2 3 4 5]" {
+		t.Errorf("unexpected indexes %v", indexes)
+	}
+
+	// 计数器已同步，新步骤接在末尾
+	res, err := service.IngestStep(db.DB, service.StepInput{Step: db.RecordingStep{SessionID: sess.ID, Action: "input"}})
+	if err != nil || res.Step.StepIndex != 6 {
+		t.Errorf("expected next index 6, got %+v err=%v", res, err)
+	}
+}
+
+func TestIngestStep_IdempotencyKey(t *testing.T) {
+	setupDB(t)
+	sess := db.Session{ProjectID: "p", Title: "幂等"}
+	db.DB.Create(&sess)
+
+	key := "client-step-1"
+	in := service.StepInput{
+		Step:       db.RecordingStep{SessionID: sess.ID, Action: "click", IdempotencyKey: &key},
+		Screenshot: &db.Screenshot{DataURL: "data:image/png;base64,AAAA"},
+	}
+	first, err := service.IngestStep(db.DB, in)
+	if err != nil || first.Replayed {
+		t.Fatalf("first ingest: %+v err=%v", first, err)
+	}
+	replay, err := service.IngestStep(db.DB, in)
+	if err != nil || !replay.Replayed || replay.Step.ID != first.Step.ID {
+		t.Fatalf("expected replay of %s, got %+v err=%v", first.Step.ID, replay, err)
+	}
+
+	var steps, shots int64
+	db.DB.Model(&db.RecordingStep{}).Where("session_id = ?", sess.ID).Count(&steps)
+	db.DB.Model(&db.Screenshot{}).Where("session_id = ?", sess.ID).Count(&shots)
+	if steps != 1 || shots != 1 {
+		t.Errorf("replay should not create rows: steps=%d screenshots=%d", steps, shots)
+	}
+
+	// 序号计数器未被重放占用
+	next, _ := service.IngestStep(db.DB, service.StepInput{Step: db.RecordingStep{SessionID: sess.ID, Action: "input"}})
+	if next.Step.StepIndex != 2 {
+		t.Errorf("expected next index 2, got %d", next.Step.StepIndex)
+	}
+
+	// 幂等键只在会话内唯一：其他会话的客户端可以使用相同的键
+	other := db.Session{ProjectID: "p", Title: "另一会话"}
+	db.DB.Create(&other)
+	res, err := service.IngestStep(db.DB, service.StepInput{Step: db.RecordingStep{SessionID: other.ID, Action: "click", IdempotencyKey: &key}})
+	if err != nil || res.Replayed || res.Step.SessionID != other.ID {
+		t.Fatalf("same key in another session should create a step: %+v err=%v", res, err)
+	}
+}
+
+func TestIngestStep_CoalescesInput(t *testing.T) {
+	setupDB(t)
+	sess := db.Session{ProjectID: "p", Title: "逐键输入"}
+	db.DB.Create(&sess)
+
+	ingest := func(action, selector, text string, ts int64) *service.IngestResult {
+		t.Helper()
+		res, err := service.IngestStep(db.DB, service.StepInput{
+			Step: db.RecordingStep{SessionID: sess.ID, Action: action, TargetSelector: selector, MaskedText: text, Timestamp: ts, PageURL: "https://oa.example.com/form"},
+			Logs: []db.StepLog{{Level: "info", Message: "keystroke " + text}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	first := ingest("input", "#name", "张", 1000)
+	for i, text := range []string{"张*", "张**"} {
+		if res := ingest("input", "#name", text, int64(2000+i*1000)); !res.Coalesced || res.Step.ID != first.Step.ID {
+			t.Fatalf("keystroke %d should be coalesced: %+v", i+2, res)
+		}
+	}
+	// 其他元素、超出时间窗口的输入都新建步骤，序号连续
+	other := ingest("input", "#phone", "138", 4000)
+	late := ingest("input", "#phone", "138****", 60000)
+	if other.Coalesced || late.Coalesced || other.Step.StepIndex != 2 || late.Step.StepIndex != 3 {
+		t.Errorf("unexpected steps: %+v / %+v", other.Step, late.Step)
+	}
+
+	var merged db.RecordingStep
+	db.DB.First(&merged, "id = ?", first.Step.ID)
+	var logs int64
+	db.DB.Model(&db.StepLog{}).Where("step_id = ?", merged.ID).Count(&logs)
+	if merged.MaskedText != "张**" || merged.Timestamp != 3000 || logs != 3 {
+		t.Errorf("merged step should keep the final value and all logs: %+v, %d logs", merged, logs)
+	}
+
+	if _, err := service.UpdateSettings(map[string]json.RawMessage{"input_coalesce_seconds": json.RawMessage("0")}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		service.UpdateSettings(map[string]json.RawMessage{"input_coalesce_seconds": json.RawMessage("10")})
+	})
+	if res := ingest("input", "#phone", "138****0000", 60500); res.Coalesced {
+		t.Error("coalescing should be disabled when input_coalesce_seconds is 0")
+	}
+}
+
+func TestIngestStep_CoalescedScreenshotKeepsLatestRegions(t *testing.T) {
+	setupDB(t)
+	sess := db.Session{ProjectID: "p", Title: "逐键输入遮蔽"}
+	db.DB.Create(&sess)
+
+	ingest := func(text string, ts int64, regions ...service.MaskRegion) *service.IngestResult {
+		t.Helper()
+		res, err := service.IngestStep(db.DB, service.StepInput{
+			Step:       db.RecordingStep{SessionID: sess.ID, Action: "input", TargetSelector: "#id-card", MaskedText: text, Timestamp: ts},
+			Screenshot: &db.Screenshot{DataURL: "data:image/png;base64,AAAA", MaskedRegions: service.EncodeMaskedRegions(regions)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	first := ingest("1", 1000, service.MaskRegion{X: 10, Y: 10, Width: 20, Height: 10})
+	res := ingest("11", 2000,
+		service.MaskRegion{X: 10, Y: 10, Width: 40, Height: 10},
+		service.MaskRegion{X: 100, Y: 50, Width: 30, Height: 10})
+	if !res.Coalesced || res.Step.ScreenshotID != first.Step.ScreenshotID {
+		t.Fatalf("expected coalesced step with the same screenshot: %+v", res.Step)
+	}
+
+	var shot db.Screenshot
+	db.DB.First(&shot, "id = ?", first.Step.ScreenshotID)
+	regions, _ := service.ParseMaskedRegions(shot.MaskedRegions)
+	if len(regions) != 2 || regions[0].Width != 40 {
+		t.Errorf("coalesced screenshot should keep the latest masked regions, got %+v", regions)
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// 步骤短标题的字数范围（规则生成时超出部分截断）
+const (
+	stepTitleMinRunes = 2
+	stepTitleMaxRunes = 8
+)
+
+// stepTitleLineRe 模型输出中的短标题行，如“标题：提交申请”
+var stepTitleLineRe = regexp.MustCompile(`^[\s*#>-]*(?:短标题|步骤标题|标题)\s*[:：]\s*(.*)$`)
+
+// splitStepTitle 从步骤描述的模型输出中拆出短标题行；没有标题行或标题字数不合要求时标题为空，由规则兜底
+func splitStepTitle(raw string) (desc, title string) {
+	var lines []string
+	for _, line := range strings.Split(raw, "\n") {
+		if m := stepTitleLineRe.FindStringSubmatch(line); m != nil {
+			if title == "" {
+				title = cleanStepTitle(m[1])
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	desc = strings.TrimSpace(strings.Join(lines, "\n"))
+	if n := utf8.RuneCountInString(title); n < stepTitleMinRunes || n > stepTitleMaxRunes {
+		title = ""
+	}
+	return desc, title
+}
+
+// cleanStepTitle 去掉短标题两侧的引号、括号、星号与句末标点
+func cleanStepTitle(s string) string {
+	return strings.Trim(strings.TrimSpace(s), "\"'“”‘’「」『』【】[]（）()*`。，、；;：:.!！ ")
+}
+
+// stepTitleVerbs 规则生成短标题时各操作类型的动词
+var stepTitleVerbs = map[string]string{
+	"click":      "点击",
+	"input":      "填写",
+	"select":     "选择",
+	"drag":       "拖拽",
+	"navigation": "打开",
+	"scroll":     "滚动",
+	"hover":      "悬停",
+}
+
+// RuleStepTitle 规则生成的步骤短标题：动词 + 操作对象（相关文本、元素名称或页面标题），超过 8 个字时截断
+func RuleStepTitle(step *db.RecordingStep) string {
+	if step.KeyCombo != "" {
+		return truncateRunes("按 "+step.KeyCombo, stepTitleMaxRunes)
+	}
+	verb := stepTitleVerbs[step.Action]
+	if verb == "" {
+		verb = "操作"
+	}
+	target := cleanStepTitle(step.MaskedText)
+	if target == "" && step.TargetElement != "" {
+		if strings.Contains(step.TargetElement, "功能为 ") {
+			target = parseStepContext(step.TargetElement, step.Action).compName
+		} else {
+			target, _, _ = strings.Cut(step.TargetElement, " (")
+			target = cleanStepTitle(target)
+		}
+	}
+	if step.Action == "navigation" || target == "" {
+		target = step.PageTitle
+	}
+	return truncateRunes(verb+target, stepTitleMaxRunes)
+}
@@ -0,0 +1,76 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestStepTitles(t *testing.T) {
+	setupDB(t)
+	_, sessionID := seedSessionWithSteps(t, 3)
+	aiSvc := fakeOllama(t, func(prompt string) string {
+		if !strings.Contains(prompt, "标题：[短标题]") {
+			t.Errorf("prompt should ask for a short title:\n%s", prompt)
+		}
+		switch {
+		case strings.Contains(prompt, "当前是第1步"):
+			return "打开系统首页\n标题：「打开首页」"
+		case strings.Contains(prompt, "当前是第2步"):
+			return "**标题：这个标题明显超过了八个字**\n点击登录按钮"
+		}
+		return "填写用户名"
+	})
+
+	progressCh := make(chan service.DocGenerateProgress, 10)
+	if err := aiSvc.GenerateDocForSession(sessionID, progressCh); err != nil {
+		t.Fatalf("GenerateDocForSession: %v", err)
+	}
+	var steps []db.RecordingStep
+	db.DB.Where("session_id = ?", sessionID).Order("step_index").Find(&steps)
+	// 模型给出的标题去掉括号后保存；过长或缺失时按规则生成，标题行不写入描述
+	if steps[0].AITitle != "打开首页" || steps[0].AIDescription != "第1步：打开系统首页" {
+		t.Errorf("unexpected step 1: %q / %q", steps[0].AITitle, steps[0].AIDescription)
+	}
+	if steps[1].AITitle != service.RuleStepTitle(&steps[1]) || strings.Contains(steps[1].AIDescription, "标题") {
+		t.Errorf("unexpected step 2: %q / %q", steps[1].AITitle, steps[1].AIDescription)
+	}
+	for _, st := range steps {
+		if n := len([]rune(st.AITitle)); n < 2 || n > 8 {
+			t.Errorf("step %d title %q not 2-8 characters", st.StepIndex, st.AITitle)
+		}
+	}
+
+	svc := service.NewDocService()
+	content, _ := svc.BuildDocument(sessionID)
+	md := svc.GenerateMarkdown(content, "business")
+	if !strings.Contains(md, "### 第 1 步 打开首页") {
+		t.Errorf("step heading should include the title:\n%s", md)
+	}
+	g, _ := service.SessionFlow(db.DB, sessionID)
+	for _, n := range g.Nodes {
+		if n.StepID == steps[0].ID && n.Label != "打开首页" {
+			t.Errorf("flow node should use the title: %+v", n)
+		}
+	}
+}
+
+func TestRuleStepTitle(t *testing.T) {
+	cases := []struct {
+		step db.RecordingStep
+		want string
+	}{
+		{db.RecordingStep{Action: "click", TargetElement: "提交申请 (button#submit)"}, "点击提交申请"},
+		{db.RecordingStep{Action: "input", MaskedText: "【申请人姓名】"}, "填写申请人姓名"},
+		{db.RecordingStep{Action: "navigation", PageTitle: "政务大厅首页"}, "打开政务大厅首页"},
+		{db.RecordingStep{Action: "click", TargetElement: "上传营业执照扫描件 (button#upload)"}, "点击上传营业执照"},
+		{db.RecordingStep{Action: "keydown", KeyCombo: "Ctrl+S"}, "按 Ctrl+S"},
+	}
+	for _, c := range cases {
+		if got := service.RuleStepTitle(&c.step); got != c.want {
+			t.Errorf("RuleStepTitle(%+v) = %q, want %q", c.step, got, c.want)
+		}
+	}
+}
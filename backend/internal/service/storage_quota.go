@@ -0,0 +1,179 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// ErrStorageQuotaExceeded 项目存储已达配额上限
+var ErrStorageQuotaExceeded = errors.New("project storage quota exceeded")
+
+// ProjectStorage 项目的存储占用（字节）：截图按数据库中 data URL 的长度计（含回放截图），附件与导出产物按文件大小计
+type ProjectStorage struct {
+	ProjectID       string `json:"project_id"`
+	Name            string `json:"name"`
+	ScreenshotBytes int64  `json:"screenshot_bytes"`
+	MediaBytes      int64  `json:"media_bytes"`
+	ExportBytes     int64  `json:"export_bytes"` // 尚未过期的后台导出产物
+	TotalBytes      int64  `json:"total_bytes"`
+	QuotaBytes      int64  `json:"quota_bytes"` // 0 表示不限
+	Exceeded        bool   `json:"exceeded"`
+}
+
+// storageUsageQuery 按项目分组统计一类存储占用：model 联结 join 得到所属项目 project，对 sum 求和后累加到 field
+type storageUsageQuery struct {
+	model   interface{}
+	join    string
+	project string
+	sum     string
+	where   string
+	args    []interface{}
+	field   func(*ProjectStorage) *int64
+}
+
+var storageUsageQueries = []storageUsageQuery{
+	{model: &db.Screenshot{}, join: "JOIN sessions ON sessions.id = screenshots.session_id", project: "sessions.project_id",
+		sum:   "LENGTH(screenshots.data_url) + LENGTH(COALESCE(screenshots.element_url, ''))",
+		field: func(u *ProjectStorage) *int64 { return &u.ScreenshotBytes }},
+	{model: &db.ReplayStep{}, join: "JOIN sessions ON sessions.id = replay_steps.session_id", project: "sessions.project_id",
+		sum:   "LENGTH(COALESCE(replay_steps.data_url, ''))",
+		field: func(u *ProjectStorage) *int64 { return &u.ScreenshotBytes }},
+	{model: &db.SessionMedia{}, join: "JOIN sessions ON sessions.id = session_media.session_id", project: "sessions.project_id",
+		sum:   "session_media.size",
+		field: func(u *ProjectStorage) *int64 { return &u.MediaBytes }},
+	{model: &db.ExportJob{}, join: "JOIN sessions ON sessions.id = export_jobs.session_id", project: "sessions.project_id",
+		sum: "export_jobs.size", where: "export_jobs.source_type = ? AND export_jobs.status = ?",
+		args:  []interface{}{ExportSourceDocument, ExportCompleted},
+		field: func(u *ProjectStorage) *int64 { return &u.ExportBytes }},
+	{model: &db.ExportJob{}, join: "JOIN compiled_documents ON compiled_documents.id = export_jobs.source_id", project: "compiled_documents.project_id",
+		sum: "export_jobs.size", where: "export_jobs.source_type = ? AND export_jobs.status = ?",
+		args:  []interface{}{ExportSourceCompiled, ExportCompleted},
+		field: func(u *ProjectStorage) *int64 { return &u.ExportBytes }},
+}
+
+// ProjectStorageUsage 统计项目的存储占用；projectIDs 为空时统计所有项目，按占用从大到小排列
+func ProjectStorageUsage(tx *gorm.DB, projectIDs ...string) ([]ProjectStorage, error) {
+	var projects []db.Project
+	q := tx.Select("id", "name", "storage_quota_mb")
+	if len(projectIDs) > 0 {
+		q = q.Where("id IN ?", projectIDs)
+	}
+	if err := q.Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	usage := make(map[string]*ProjectStorage, len(projects))
+	out := make([]ProjectStorage, len(projects))
+	for i, p := range projects {
+		out[i] = ProjectStorage{ProjectID: p.ID, Name: p.Name, QuotaBytes: int64(p.StorageQuotaMB) << 20}
+		usage[p.ID] = &out[i]
+	}
+
+	for _, uq := range storageUsageQueries {
+		var rows []struct {
+			ProjectID string
+			Bytes     int64
+		}
+		q := tx.Model(uq.model).Select(uq.project + " AS project_id, COALESCE(SUM(" + uq.sum + "), 0) AS bytes").
+			Joins(uq.join).Group(uq.project)
+		if uq.where != "" {
+			q = q.Where(uq.where, uq.args...)
+		}
+		if len(projectIDs) > 0 {
+			q = q.Where(uq.project+" IN ?", projectIDs)
+		}
+		if err := q.Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			if u := usage[r.ProjectID]; u != nil {
+				*uq.field(u) += r.Bytes
+			}
+		}
+	}
+	for i := range out {
+		u := &out[i]
+		u.TotalBytes = u.ScreenshotBytes + u.MediaBytes + u.ExportBytes
+		u.Exceeded = u.QuotaBytes > 0 && u.TotalBytes >= u.QuotaBytes
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].TotalBytes > out[j].TotalBytes })
+	return out, nil
+}
+
+// storageUsageTTL 写入前检查配额时复用统计结果的时长，避免录制中每一步都重新统计整个项目
+const storageUsageTTL = 10 * time.Second
+
+var storageUsageCache struct {
+	sync.Mutex
+	src     *gorm.DB
+	entries map[string]cachedStorageUsage
+}
+
+type cachedStorageUsage struct {
+	used     int64
+	loadedAt time.Time
+}
+
+// ReserveStorage 检查项目写入 incoming 字节后是否超出存储配额：未设置配额时直接通过；
+// 超出时返回 ErrStorageQuotaExceeded（附已用量与配额），通过时把 incoming 计入缓存的用量
+func ReserveStorage(projectID string, incoming int64) error {
+	var project db.Project
+	if err := db.DB.Select("id", "storage_quota_mb").Where("id = ?", projectID).Limit(1).Find(&project).Error; err != nil {
+		return err
+	}
+	if project.StorageQuotaMB <= 0 {
+		return nil
+	}
+	quota := int64(project.StorageQuotaMB) << 20
+
+	storageUsageCache.Lock()
+	defer storageUsageCache.Unlock()
+	if storageUsageCache.src != db.DB {
+		storageUsageCache.src, storageUsageCache.entries = db.DB, map[string]cachedStorageUsage{}
+	}
+	entry, ok := storageUsageCache.entries[projectID]
+	if !ok || time.Since(entry.loadedAt) >= storageUsageTTL {
+		usage, err := ProjectStorageUsage(db.DB, projectID)
+		if err != nil {
+			return err
+		}
+		entry = cachedStorageUsage{loadedAt: time.Now()}
+		if len(usage) > 0 {
+			entry.used = usage[0].TotalBytes
+		}
+	}
+	if entry.used+incoming > quota {
+		storageUsageCache.entries[projectID] = entry
+		return fmt.Errorf("%w: %s used of %d MB quota", ErrStorageQuotaExceeded, formatMB(entry.used), project.StorageQuotaMB)
+	}
+	entry.used += incoming
+	storageUsageCache.entries[projectID] = entry
+	return nil
+}
+
+// StorageRemaining 项目在存储配额内的剩余字节数（用于大小事先未知的流式上传）；未设置配额时 limited 为 false
+func StorageRemaining(projectID string) (remaining int64, limited bool, err error) {
+	usage, err := ProjectStorageUsage(db.DB, projectID)
+	if err != nil || len(usage) == 0 || usage[0].QuotaBytes == 0 {
+		return 0, false, err
+	}
+	return usage[0].QuotaBytes - usage[0].TotalBytes, true, nil
+}
+
+// ReserveSessionStorage 按会话所属项目检查存储配额，见 ReserveStorage；会话或项目不存在时视为未设置配额
+func ReserveSessionStorage(sessionID string, incoming int64) error {
+	var session db.Session
+	if err := db.DB.Select("id", "project_id").Where("id = ?", sessionID).Limit(1).Find(&session).Error; err != nil {
+		return err
+	}
+	return ReserveStorage(session.ProjectID, incoming)
+}
+
+func formatMB(bytes int64) string {
+	return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+}
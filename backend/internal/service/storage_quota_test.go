@@ -0,0 +1,58 @@
+package service_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestProjectStorageUsage(t *testing.T) {
+	setupDB(t)
+	big := db.Project{Name: "大项目", StorageQuotaMB: 1}
+	small := db.Project{Name: "小项目"}
+	db.DB.Create(&big)
+	db.DB.Create(&small)
+	sess := db.Session{ProjectID: big.ID, Title: "录制"}
+	db.DB.Create(&sess)
+	db.DB.Create(&db.Screenshot{SessionID: sess.ID, StepID: "s1", DataURL: db.LongText(strings.Repeat("a", 1000)), ElementURL: db.LongText(strings.Repeat("b", 200))})
+	db.DB.Create(&db.ReplayStep{RunID: "r1", SessionID: sess.ID, Status: service.ReplayPassed, DataURL: db.LongText(strings.Repeat("c", 300))})
+	db.DB.Create(&db.SessionMedia{SessionID: sess.ID, Kind: "video", MimeType: "video/webm", Path: "media/x.webm", Size: 5000})
+	compiled := db.CompiledDocument{ProjectID: big.ID, Title: "合订本"}
+	db.DB.Create(&compiled)
+	for _, job := range []db.ExportJob{
+		{SourceType: service.ExportSourceDocument, SourceID: "doc", SessionID: sess.ID, Status: service.ExportCompleted, Size: 700},
+		{SourceType: service.ExportSourceCompiled, SourceID: compiled.ID, Status: service.ExportCompleted, Size: 800},
+		// 已过期的产物文件已删除，不计入
+		{SourceType: service.ExportSourceDocument, SourceID: "doc", SessionID: sess.ID, Status: service.ExportExpired, Size: 9000},
+	} {
+		db.DB.Create(&job)
+	}
+
+	usage, err := service.ProjectStorageUsage(db.DB)
+	if err != nil {
+		t.Fatalf("usage: %v", err)
+	}
+	if len(usage) != 2 || usage[0].ProjectID != big.ID || usage[1].TotalBytes != 0 {
+		t.Fatalf("expected both projects, largest first: %+v", usage)
+	}
+	u := usage[0]
+	if u.ScreenshotBytes != 1500 || u.MediaBytes != 5000 || u.ExportBytes != 1500 || u.TotalBytes != 8000 {
+		t.Errorf("unexpected breakdown: %+v", u)
+	}
+	if u.QuotaBytes != 1<<20 || u.Exceeded {
+		t.Errorf("unexpected quota: %+v", u)
+	}
+
+	if err := service.ReserveStorage(big.ID, 1<<20-8000); err != nil {
+		t.Errorf("write up to the quota should pass: %v", err)
+	}
+	if err := service.ReserveStorage(big.ID, 1); !errors.Is(err, service.ErrStorageQuotaExceeded) {
+		t.Errorf("reserved bytes should count towards the quota, got %v", err)
+	}
+	if err := service.ReserveStorage(small.ID, 1<<30); err != nil {
+		t.Errorf("projects without a quota are unlimited: %v", err)
+	}
+}
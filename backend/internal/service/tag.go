@@ -0,0 +1,100 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// MaxTagNameLen 标签名长度上限
+const MaxTagNameLen = 64
+
+// ErrInvalidTagName 标签名为空或过长
+var ErrInvalidTagName = errors.New("tag name must be 1-64 characters")
+
+// NormalizeTagNames 去除空白、去重，保持原有顺序
+func NormalizeTagNames(names []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(names))
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if n == "" || len([]rune(n)) > MaxTagNameLen {
+			return nil, ErrInvalidTagName
+		}
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		out = append(out, n)
+	}
+	return out, nil
+}
+
+// EnsureTags 按名称查找标签，不存在时创建（需在事务中调用）
+func EnsureTags(tx *gorm.DB, names []string) ([]db.Tag, error) {
+	names, err := NormalizeTagNames(names)
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]db.Tag, 0, len(names))
+	for _, n := range names {
+		var tag db.Tag
+		if err := tx.Where(db.Tag{Name: n}).FirstOrCreate(&tag).Error; err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// SetTags 将项目或会话的标签整体替换为 names（owner 为 *db.Project 或 *db.Session，需在事务中调用）
+func SetTags(tx *gorm.DB, owner interface{}, names []string) ([]db.Tag, error) {
+	tags, err := EnsureTags(tx, names)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Model(owner).Association("Tags").Replace(tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// DeleteTag 删除标签及其全部关联（需在事务中调用）
+func DeleteTag(tx *gorm.DB, id string) error {
+	for _, table := range []string{"project_tags", "session_tags"} {
+		if err := tx.Table(table).Where("tag_id = ?", id).Delete(nil).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Delete(&db.Tag{}, "id = ?", id).Error
+}
+
+// FilterByTags 限定查询结果必须带有全部指定标签。
+// joinTable/ownerColumn 为 project_tags/project_id 或 session_tags/session_id
+func FilterByTags(q *gorm.DB, joinTable, ownerColumn string, names []string) *gorm.DB {
+	if len(names) == 0 {
+		return q
+	}
+	sub := q.Session(&gorm.Session{NewDB: true}).Table(joinTable).
+		Select(joinTable+"."+ownerColumn).
+		Joins("JOIN tags ON tags.id = "+joinTable+".tag_id").
+		Where("tags.name IN ?", names).
+		Group(joinTable+"."+ownerColumn).
+		Having("COUNT(DISTINCT tags.id) = ?", len(names))
+	return q.Where("id IN (?)", sub)
+}
+
+// ParseTagFilter 解析逗号分隔的标签过滤参数（去空、去重）
+func ParseTagFilter(raw string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, n := range strings.Split(raw, ",") {
+		if n = strings.TrimSpace(n); n != "" && !seen[n] {
+			seen[n] = true
+			names = append(names, n)
+		}
+	}
+	return names
+}
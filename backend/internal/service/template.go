@@ -0,0 +1,157 @@
+package service
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+var (
+	// ErrTemplateBuiltIn 内置模板不可修改或删除
+	ErrTemplateBuiltIn = errors.New("built-in templates cannot be modified")
+	// ErrTemplateExists 模板标识已被占用（含内置模板）
+	ErrTemplateExists = errors.New("template key already exists")
+	// ErrTemplateInUse 仍有项目使用该模板
+	ErrTemplateInUse = errors.New("template is used by projects")
+)
+
+// TemplateKeyPattern 模板标识：小写字母、数字、- 和 _
+var TemplateKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// DocViews 文档导出视图
+var DocViews = []string{"business", "technical", "both"}
+
+// BuiltinTemplates 内置模板：business / technical / both 兼容原有的 Project.TemplateType 取值，
+// 其余为常用文档类型的预置模板
+var BuiltinTemplates = []db.DocTemplate{
+	{Key: "both", Name: "业务 + 技术视图", Description: "默认模板：文档同时保存业务视图和技术视图，导出业务视图"},
+	{Key: "business", Name: "业务操作说明", Description: "面向业务人员的操作说明", View: "business"},
+	{Key: "technical", Name: "技术参考", Description: "面向开发与测试人员，包含元素定位、接口与脚本错误", View: "technical"},
+	{
+		Key: "manual", Name: "操作手册", Description: "正式发布的系统操作手册，附修订记录", View: "business",
+		Preface: "## 适用范围\n\n本手册面向业务经办人员，按操作顺序说明每一步的页面位置与操作要点。",
+		Closing: "## 修订记录\n\n| 版本 | 日期 | 修订人 | 说明 |\n| --- | --- | --- | --- |\n|  |  |  |  |",
+	},
+	{
+		Key: "training", Name: "培训讲义", Description: "新人培训讲义，含学习目标与课后练习", View: "business",
+		Preface: "## 学习目标\n\n完成本讲义后，学员能够独立完成以下业务流程。\n\n## 课前准备\n\n- 已开通系统账号及相应权限\n- 准备一份练习用的测试数据",
+		Closing: "## 课后练习\n\n1. 不看讲义，独立完成一遍完整流程。\n2. 记录操作中遇到的问题，在答疑环节提出。",
+	},
+	{
+		Key: "acceptance", Name: "验收文档", Description: "验收测试记录，业务步骤后附技术附录与签字栏", View: "both",
+		Preface: "## 验收说明\n\n本文档记录验收测试的操作过程与系统响应，请验收人员逐步核对。",
+		Closing: "## 验收结论\n\n| 验收项 | 结论 |\n| --- | --- |\n| 功能是否符合需求 | □ 通过　□ 不通过 |\n| 验收意见 |  |\n\n验收人（签字）：__________　　日期：__________",
+	},
+}
+
+func init() {
+	for i := range BuiltinTemplates {
+		BuiltinTemplates[i].BuiltIn = true
+	}
+}
+
+// builtinTemplate 按标识查找内置模板
+func builtinTemplate(key string) (*db.DocTemplate, bool) {
+	for i := range BuiltinTemplates {
+		if BuiltinTemplates[i].Key == key {
+			t := BuiltinTemplates[i]
+			return &t, true
+		}
+	}
+	return nil, false
+}
+
+// FindTemplate 按标识查找模板，内置模板优先
+func FindTemplate(key string) (*db.DocTemplate, bool) {
+	if t, ok := builtinTemplate(key); ok {
+		return t, true
+	}
+	var t db.DocTemplate
+	if err := db.DB.Where(&db.DocTemplate{Key: key}).First(&t).Error; err != nil {
+		return nil, false
+	}
+	return &t, true
+}
+
+// ListTemplates 内置模板在前，自定义模板按标识排序
+func ListTemplates() ([]db.DocTemplate, error) {
+	var custom []db.DocTemplate
+	if err := db.DB.Find(&custom).Error; err != nil {
+		return nil, err
+	}
+	sort.Slice(custom, func(i, j int) bool { return custom[i].Key < custom[j].Key })
+	return append(append([]db.DocTemplate(nil), BuiltinTemplates...), custom...), nil
+}
+
+// SaveTemplate 新建（create=true）或更新自定义模板
+func SaveTemplate(t *db.DocTemplate, create bool) error {
+	if _, ok := builtinTemplate(t.Key); ok {
+		if create {
+			return ErrTemplateExists
+		}
+		return ErrTemplateBuiltIn
+	}
+	if create {
+		var count int64
+		db.DB.Model(&db.DocTemplate{}).Where(&db.DocTemplate{Key: t.Key}).Count(&count)
+		if count > 0 {
+			return ErrTemplateExists
+		}
+	}
+	return db.DB.Save(t).Error
+}
+
+// DeleteTemplate 删除自定义模板；仍被项目引用时拒绝
+func DeleteTemplate(t *db.DocTemplate) error {
+	if t.BuiltIn {
+		return ErrTemplateBuiltIn
+	}
+	var count int64
+	db.DB.Model(&db.Project{}).Where("template_type = ?", t.Key).Count(&count)
+	if count > 0 {
+		return ErrTemplateInUse
+	}
+	return db.DB.Delete(t).Error
+}
+
+// ApplyTemplate 将模板的前言与结尾写入文档内容
+func ApplyTemplate(content *GeneratedDocContent, t *db.DocTemplate) {
+	content.Template = t.Key
+	content.Preface = t.Preface
+	content.Closing = t.Closing
+}
+
+// TemplateView 模板的默认导出视图
+func TemplateView(key string) string {
+	if t, ok := FindTemplate(key); ok && t.View != "" {
+		return t.View
+	}
+	return "business"
+}
+
+// SampleDocContent 模板预览用的示例文档
+func SampleDocContent() *GeneratedDocContent {
+	return &GeneratedDocContent{
+		SessionTitle: "示例：提交请假申请",
+		ProjectName:  "示例项目",
+		GeneratedAt:  "2024-01-01 09:00:00",
+		BusinessView: []DocSection{{
+			Title: "请假申请", Summary: "在办公系统中填写并提交请假申请。",
+			Steps: []DocStep{
+				{StepIndex: 1, Action: "click", Description: "点击左侧菜单中的【请假申请】"},
+				{StepIndex: 2, Action: "input", Description: "在【请假事由】输入框中填写事由"},
+				{StepIndex: 3, Action: "click", Description: "点击【提交】按钮"},
+			},
+		}},
+		TechnicalView: []DocSection{{
+			Title: "请假申请",
+			Steps: []DocStep{
+				{StepIndex: 1, Action: "click", Description: "请假申请", TechNote: "元素：请假申请\nCSS：#menu-leave"},
+				{StepIndex: 2, Action: "input", Description: "请假事由", TechNote: "元素：请假事由\nCSS：#reason"},
+				{StepIndex: 3, Action: "click", Description: "提交", TechNote: "元素：提交\nCSS：button[type=submit]"},
+			},
+		}},
+	}
+}
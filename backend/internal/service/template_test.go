@@ -0,0 +1,62 @@
+package service_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestDocTemplates(t *testing.T) {
+	setupDB(t)
+	projectID, sessionID := seedSessionWithSteps(t, 2)
+
+	if err := service.SaveTemplate(&db.DocTemplate{Key: "manual", Name: "x"}, true); !errors.Is(err, service.ErrTemplateExists) {
+		t.Errorf("built-in key: expected ErrTemplateExists, got %v", err)
+	}
+	builtin, _ := service.FindTemplate("manual")
+	if err := service.DeleteTemplate(builtin); !errors.Is(err, service.ErrTemplateBuiltIn) {
+		t.Errorf("delete built-in: expected ErrTemplateBuiltIn, got %v", err)
+	}
+
+	custom := &db.DocTemplate{Key: "sop", Name: "SOP", View: "technical", Preface: "## 目的", Closing: "## 附录"}
+	if err := service.SaveTemplate(custom, true); err != nil {
+		t.Fatalf("SaveTemplate: %v", err)
+	}
+	if err := service.SaveTemplate(&db.DocTemplate{Key: "sop", Name: "again"}, true); !errors.Is(err, service.ErrTemplateExists) {
+		t.Errorf("duplicate key: expected ErrTemplateExists, got %v", err)
+	}
+	list, _ := service.ListTemplates()
+	if len(list) != len(service.BuiltinTemplates)+1 || list[len(list)-1].Key != "sop" {
+		t.Errorf("custom template should follow built-ins: %+v", list)
+	}
+	if service.TemplateView("sop") != "technical" || service.TemplateView("both") != "business" {
+		t.Error("TemplateView should use the template's view, defaulting to business")
+	}
+
+	db.DB.Model(&db.Project{}).Where("id = ?", projectID).Update("template_type", "sop")
+	svc := service.NewDocService()
+	content, _ := svc.BuildDocument(sessionID)
+	doc, _ := svc.SaveGeneratedDoc(sessionID, content)
+	loaded, err := svc.LoadDocument(doc)
+	if err != nil || loaded.Template != "sop" {
+		t.Fatalf("LoadDocument should apply the project template: %v %+v", err, loaded)
+	}
+	md := svc.GenerateMarkdown(loaded, "business")
+	if !(strings.Index(md, "## 目的") < strings.Index(md, "点击登录按钮") && strings.Index(md, "点击登录按钮") < strings.Index(md, "## 附录")) {
+		t.Errorf("preface and closing should wrap the steps:\n%s", md)
+	}
+
+	if err := service.DeleteTemplate(custom); !errors.Is(err, service.ErrTemplateInUse) {
+		t.Errorf("template in use: expected ErrTemplateInUse, got %v", err)
+	}
+	db.DB.Model(&db.Project{}).Where("id = ?", projectID).Update("template_type", "both")
+	if err := service.DeleteTemplate(custom); err != nil {
+		t.Errorf("DeleteTemplate: %v", err)
+	}
+	if _, ok := service.FindTemplate("sop"); ok {
+		t.Error("deleted template should no longer be found")
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/gpilot/backend/internal/db"
+	"gorm.io/gorm"
+)
+
+// MaxStepGapMS 相邻步骤间隔的计时上限：超过该值视为暂停或离开，按上限计入耗时
+const MaxStepGapMS int64 = 5 * 60 * 1000
+
+// StepGap 计算两个步骤时间戳之间计入耗时的间隔（毫秒）；任一时间戳缺失或乱序时为 0
+func StepGap(prev, next int64) int64 {
+	if prev <= 0 || next <= prev {
+		return 0
+	}
+	if gap := next - prev; gap < MaxStepGapMS {
+		return gap
+	}
+	return MaxStepGapMS
+}
+
+// RecomputeTiming 按步骤顺序重新计算每步耗时（elapsed_ms，距上一步的间隔）与会话总时长，
+// 只更新有变化的行；步骤上报、重新编号后调用，保证乱序上报时也能得到正确结果
+func RecomputeTiming(tx *gorm.DB, sessionID string) error {
+	var steps []db.RecordingStep
+	if err := tx.Select("id", "step_index", "timestamp", "elapsed_ms").Where("session_id = ?", sessionID).
+		Order("step_index, created_at").Find(&steps).Error; err != nil {
+		return err
+	}
+	var total int64
+	for i, s := range steps {
+		var elapsed int64
+		if i > 0 {
+			elapsed = StepGap(steps[i-1].Timestamp, s.Timestamp)
+		}
+		total += elapsed
+		if elapsed == s.ElapsedMS {
+			continue
+		}
+		if err := tx.Model(&db.RecordingStep{}).Where("id = ?", s.ID).
+			UpdateColumn("elapsed_ms", elapsed).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Model(&db.Session{}).Where("id = ?", sessionID).UpdateColumn("duration_ms", total).Error
+}
+
+// TimingHint 将耗时格式化为业务视图中的提示（如“约 2 分钟”），不足 30 秒时返回空串
+func TimingHint(ms int64) string {
+	if ms < 30*1000 {
+		return ""
+	}
+	minutes := (ms + 30*1000) / (60 * 1000)
+	if minutes < 60 {
+		return fmt.Sprintf("约 %d 分钟", minutes)
+	}
+	if minutes%60 == 0 {
+		return fmt.Sprintf("约 %d 小时", minutes/60)
+	}
+	return fmt.Sprintf("约 %d 小时 %d 分钟", minutes/60, minutes%60)
+}
@@ -0,0 +1,113 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestRecomputeTiming(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "耗时", MergeStrategy: service.MergeOff, ShowTiming: true}
+	db.DB.Create(&proj)
+	sess := db.Session{ProjectID: proj.ID, Title: "办件流程"}
+	db.DB.Create(&sess)
+
+	const base int64 = 1_700_000_000_000
+	// 乱序上报：第 3 步先到；第 4 步前停顿 1 小时，按上限计
+	steps := []struct {
+		index int
+		ts    int64
+		url   string
+	}{
+		{1, base, "http://gov.example.com/cases"},
+		{3, base + 150_000, "http://gov.example.com/cases/1/edit"},
+		{2, base + 30_000, "http://gov.example.com/cases"},
+		{4, base + 150_000 + 3_600_000, "http://gov.example.com/cases/1/edit"},
+	}
+	for _, s := range steps {
+		_, err := service.IngestStep(db.DB, service.StepInput{Step: db.RecordingStep{
+			SessionID: sess.ID, StepIndex: s.index, Timestamp: s.ts, Action: "click",
+			PageTitle: "页面", PageURL: s.url, AIDescription: "描述",
+		}})
+		if err != nil {
+			t.Fatalf("IngestStep: %v", err)
+		}
+	}
+
+	var saved []db.RecordingStep
+	db.DB.Where("session_id = ?", sess.ID).Order("step_index").Find(&saved)
+	want := []int64{0, 30_000, 120_000, service.MaxStepGapMS}
+	for i, s := range saved {
+		if s.ElapsedMS != want[i] {
+			t.Errorf("step %d: expected elapsed %d, got %d", s.StepIndex, want[i], s.ElapsedMS)
+		}
+	}
+	db.DB.First(&sess, "id = ?", sess.ID)
+	if total := 150_000 + service.MaxStepGapMS; sess.DurationMS != total {
+		t.Errorf("expected session duration %d, got %d", total, sess.DurationMS)
+	}
+
+	content, err := service.NewDocService().BuildDocument(sess.ID)
+	if err != nil {
+		t.Fatalf("BuildDocument: %v", err)
+	}
+	if len(content.BusinessView) != 2 {
+		t.Fatalf("expected 2 sections, got %d", len(content.BusinessView))
+	}
+	if d := content.BusinessView[0].DurationMS; d != 30_000 {
+		t.Errorf("section 1: expected 30000ms, got %d", d)
+	}
+	if d := content.BusinessView[1].DurationMS; d != 120_000+service.MaxStepGapMS {
+		t.Errorf("section 2: expected %d, got %d", 120_000+service.MaxStepGapMS, d)
+	}
+	if content.DurationMS != sess.DurationMS {
+		t.Errorf("document duration %d should match session %d", content.DurationMS, sess.DurationMS)
+	}
+
+	svc := service.NewDocService()
+	md := svc.GenerateMarkdown(content, "business")
+	for _, s := range []string{"> 预计耗时：约 8 分钟", "（约 1 分钟）", "### 第 3 步（约 2 分钟）", "### 第 4 步（约 5 分钟）"} {
+		if !strings.Contains(md, s) {
+			t.Errorf("business markdown missing %q", s)
+		}
+	}
+	if strings.Contains(svc.GenerateMarkdown(content, "technical"), "约") {
+		t.Error("technical view should not render timing hints")
+	}
+	content.ShowTiming = false
+	if strings.Contains(svc.GenerateMarkdown(content, "business"), "预计耗时") {
+		t.Error("timing hints should be omitted when disabled")
+	}
+
+	// 保存后重新加载仍保留耗时
+	doc, err := svc.SaveGeneratedDoc(sess.ID, content)
+	if err != nil {
+		t.Fatalf("SaveGeneratedDoc: %v", err)
+	}
+	loaded, err := svc.LoadDocument(doc)
+	if err != nil {
+		t.Fatalf("LoadDocument: %v", err)
+	}
+	if loaded.DurationMS != content.DurationMS || !loaded.ShowTiming {
+		t.Errorf("loaded document lost timing: duration=%d show=%v", loaded.DurationMS, loaded.ShowTiming)
+	}
+}
+
+func TestTimingHint(t *testing.T) {
+	cases := map[int64]string{
+		0:         "",
+		29_000:    "",
+		30_000:    "约 1 分钟",
+		150_000:   "约 3 分钟",
+		3_600_000: "约 1 小时",
+		5_430_000: "约 1 小时 31 分钟",
+	}
+	for ms, want := range cases {
+		if got := service.TimingHint(ms); got != want {
+			t.Errorf("TimingHint(%d) = %q, want %q", ms, got, want)
+		}
+	}
+}
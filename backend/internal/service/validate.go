@@ -0,0 +1,31 @@
+package service
+
+// 各类枚举字段的合法取值，API 层据此统一校验（与插件 shared/types 保持一致）
+var (
+	// SessionStatuses 会话状态
+	SessionStatuses = []string{"idle", "recording", "paused", "completed", "generating", "exported"}
+	// ActionTypes 步骤操作类型
+	ActionTypes = []string{"click", "input", "select", "drag", "navigation", "scroll", "hover", ActionKeypress, ActionShortcut}
+	// DocumentStatuses 文档审批状态
+	DocumentStatuses = []string{"draft", "approved"}
+	// MaskingRuleTypes 脱敏规则类型
+	MaskingRuleTypes = []string{"regex", "exact", "element_click"}
+	// MaskingScopes 脱敏规则作用范围
+	MaskingScopes = []string{"global", "session", MaskingScopeURL}
+	// URLPatternTypes URL 作用范围的匹配方式
+	URLPatternTypes = []string{URLPatternGlob, URLPatternRegex}
+	// MaskedFields 脱敏审计记录中可出现的步骤字段
+	MaskedFields = []string{"masked_text", "input_value", "target_element", "page_title", "page_url", "screenshot"}
+	// ImportFormats 可导入为会话的外部录制格式
+	ImportFormats = []string{"chrome-recorder", "playwright-trace", "selenium-side"}
+)
+
+// OneOf 判断 value 是否为 allowed 中的取值
+func OneOf(value string, allowed []string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
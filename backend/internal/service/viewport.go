@@ -0,0 +1,35 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gpilot/backend/internal/db"
+)
+
+// PositionHint 根据视口尺寸、滚动偏移与目标位置判断目标是否在首屏之外，
+// 返回如“目标位于首屏以下，需向下滚动页面后操作”的提示；信息不足或目标在首屏内时返回空串
+func PositionHint(step *db.RecordingStep) string {
+	if step.ViewportW <= 0 || step.ViewportH <= 0 {
+		return ""
+	}
+	x, y := step.ClickX, step.ClickY
+	if step.BBoxW > 0 && step.BBoxH > 0 {
+		x, y = step.BBoxX, step.BBoxY
+	} else if x == 0 && y == 0 {
+		return ""
+	}
+
+	// 目标在文档中的位置 = 视口内位置 + 滚动偏移；超出一屏即需要用户滚动
+	var where, dirs []string
+	if step.ScrollY > 0 && y+step.ScrollY >= step.ViewportH {
+		where, dirs = append(where, "首屏以下"), append(dirs, "向下")
+	}
+	if step.ScrollX > 0 && x+step.ScrollX >= step.ViewportW {
+		where, dirs = append(where, "首屏右侧"), append(dirs, "向右")
+	}
+	if len(where) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("目标位于%s，需%s滚动页面后操作", strings.Join(where, "、"), strings.Join(dirs, "、"))
+}
@@ -0,0 +1,60 @@
+package service_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gpilot/backend/internal/db"
+	"github.com/gpilot/backend/internal/service"
+)
+
+func TestPositionHint(t *testing.T) {
+	cases := []struct {
+		name string
+		step db.RecordingStep
+		want string
+	}{
+		{"NoViewport", db.RecordingStep{BBoxY: 900, BBoxW: 80, BBoxH: 30, ScrollY: 600}, ""},
+		{"AboveFold", db.RecordingStep{ViewportW: 1280, ViewportH: 720, BBoxY: 100, BBoxW: 80, BBoxH: 30, ScrollY: 200}, ""},
+		{"BelowFold", db.RecordingStep{ViewportW: 1280, ViewportH: 720, BBoxY: 300, BBoxW: 80, BBoxH: 30, ScrollY: 600},
+			"目标位于首屏以下，需向下滚动页面后操作"},
+		{"ClickOnly", db.RecordingStep{ViewportW: 1280, ViewportH: 720, ClickX: 1000, ClickY: 50, ScrollX: 400},
+			"目标位于首屏右侧，需向右滚动页面后操作"},
+		{"Both", db.RecordingStep{ViewportW: 800, ViewportH: 600, BBoxX: 700, BBoxY: 500, BBoxW: 80, BBoxH: 30, ScrollX: 300, ScrollY: 300},
+			"目标位于首屏以下、首屏右侧，需向下、向右滚动页面后操作"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := service.PositionHint(&c.step); got != c.want {
+				t.Errorf("PositionHint = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestBuildDocument_PositionHint(t *testing.T) {
+	setupDB(t)
+	proj := db.Project{Name: "视口", MergeStrategy: service.MergeOff}
+	db.DB.Create(&proj)
+	sess := db.Session{ProjectID: proj.ID, Title: "长表单"}
+	db.DB.Create(&sess)
+	db.DB.Create(&db.RecordingStep{
+		SessionID: sess.ID, StepIndex: 1, Action: "click", PageTitle: "登记", AIDescription: "点击【提交】",
+		ViewportW: 1280, ViewportH: 720, BBoxY: 500, BBoxW: 80, BBoxH: 30, ScrollY: 1200,
+	})
+
+	svc := service.NewDocService()
+	content, err := svc.BuildDocument(sess.ID)
+	if err != nil {
+		t.Fatalf("BuildDocument: %v", err)
+	}
+	if hint := content.BusinessView[0].Steps[0].PositionHint; hint == "" {
+		t.Error("expected position hint on business step")
+	}
+	if note := content.TechnicalView[0].Steps[0].TechNote; !strings.Contains(note, "视口：1280×720，滚动偏移：(0, 1200)") {
+		t.Errorf("technical note should include viewport, got %q", note)
+	}
+	if md := svc.GenerateMarkdown(content, "business"); !strings.Contains(md, "*提示：目标位于首屏以下") {
+		t.Error("markdown should render position hint")
+	}
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// maxWatermarkImageBytes 水印图片大小上限（每个导出页面都会内嵌一份）
+const maxWatermarkImageBytes = 512 << 10
+
+// Watermark 导出文档的水印：文字和图片可同时设置，沿对角线平铺在每一页上
+type Watermark struct {
+	Text    string  `json:"text,omitempty"`
+	Image   string  `json:"image,omitempty"` // data URL
+	Opacity float64 `json:"opacity"`
+}
+
+// CurrentWatermark 当前设置中的导出水印；未配置文字和图片时返回 nil
+func CurrentWatermark() *Watermark {
+	st := CurrentSettings()
+	if st.WatermarkText == "" && st.WatermarkImage == "" {
+		return nil
+	}
+	return &Watermark{Text: st.WatermarkText, Image: st.WatermarkImage, Opacity: st.WatermarkOpacity}
+}
+
+// 水印平铺单元尺寸（CSS 像素）
+const (
+	watermarkTileW = 360
+	watermarkTileH = 240
+)
+
+// TileSVG 生成一个水印平铺单元（SVG data URL）：内容旋转 -30°，图片在上、文字在下，
+// 作为页面背景重复铺满即形成对角线水印
+func (w *Watermark) TileSVG() string {
+	cx, cy := watermarkTileW/2, watermarkTileH/2
+	var body strings.Builder
+	textY := cy
+	if w.Image != "" {
+		// 图片限制在 120×120 的方框内，保持比例
+		body.WriteString(fmt.Sprintf(`<image href="%s" x="%d" y="%d" width="120" height="120" preserveAspectRatio="xMidYMid meet"/>`,
+			html.EscapeString(w.Image), cx-60, cy-60))
+		if w.Text != "" {
+			textY = cy + 80
+		}
+	}
+	if w.Text != "" {
+		body.WriteString(fmt.Sprintf(`<text x="%d" y="%d" text-anchor="middle" dominant-baseline="middle" font-size="20" font-family="sans-serif" fill="#6b7280">%s</text>`,
+			cx, textY, html.EscapeString(w.Text)))
+	}
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d"><g opacity="%g" transform="rotate(-30 %d %d)">%s</g></svg>`,
+		watermarkTileW, watermarkTileH, w.Opacity, cx, cy, body.String())
+	return "data:image/svg+xml;base64," + base64.StdEncoding.EncodeToString([]byte(svg))
+}
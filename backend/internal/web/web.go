@@ -0,0 +1,88 @@
+// Package web 提供随二进制打包的 Web 界面静态文件，单个可执行文件即可同时提供后端与前端
+package web
+
+import (
+	"embed"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dist 前端构建产物；未放入构建产物时仅包含占位首页
+//
+//go:embed all:dist
+var dist embed.FS
+
+// Files 返回静态文件来源：dir 非空时使用磁盘目录（便于前端开发时免重新编译），否则使用内嵌文件
+func Files(dir string) fs.FS {
+	if dir != "" {
+		return os.DirFS(dir)
+	}
+	sub, _ := fs.Sub(dist, "dist")
+	return sub
+}
+
+// Handler 返回用于 gin NoRoute 的静态文件处理器：
+// 存在的文件直接返回；无扩展名的路径回退到 index.html 交由前端路由处理；
+// /api/ 下未匹配的路由及非 GET 请求返回 JSON 404
+func Handler(files fs.FS) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := c.Request.URL.Path
+		if strings.HasPrefix(p, "/api/") || (c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead) {
+			notFound(c)
+			return
+		}
+
+		name := strings.TrimPrefix(path.Clean(p), "/")
+		if name == "" {
+			name = "index.html"
+		}
+		if serveFile(c, files, name) {
+			return
+		}
+		// 带扩展名的资源缺失时不回退，避免把 HTML 当作脚本或图片返回
+		if path.Ext(name) != "" {
+			notFound(c)
+			return
+		}
+		if !serveFile(c, files, "index.html") {
+			notFound(c)
+		}
+	}
+}
+
+// notFound 与 API 统一的错误响应格式
+func notFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "not_found", "message": "not found"}})
+}
+
+// serveFile 返回单个文件，文件不存在或为目录时返回 false
+func serveFile(c *gin.Context, files fs.FS, name string) bool {
+	f, err := files.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil || st.IsDir() {
+		return false
+	}
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		return false
+	}
+
+	// 构建工具生成的 assets/ 文件名带内容哈希，可长期缓存；其余文件（尤其是 index.html）每次校验
+	if strings.HasPrefix(name, "assets/") {
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		c.Header("Cache-Control", "no-cache")
+	}
+	http.ServeContent(c.Writer, c.Request, name, st.ModTime(), rs)
+	return true
+}
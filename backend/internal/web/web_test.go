@@ -0,0 +1,54 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	files := fstest.MapFS{
+		"index.html":         {Data: []byte("<html>app</html>")},
+		"assets/app-1a2b.js": {Data: []byte("console.log(1)")},
+		"favicon.ico":        {Data: []byte("ico")},
+	}
+	r := gin.New()
+	r.GET("/api/v1/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	r.NoRoute(Handler(files))
+
+	cases := []struct {
+		method, path string
+		code         int
+		body, cache  string
+	}{
+		{"GET", "/", 200, "app", "no-cache"},
+		{"GET", "/projects/123/edit", 200, "app", "no-cache"},
+		{"GET", "/assets/app-1a2b.js", 200, "console.log", "immutable"},
+		{"GET", "/favicon.ico", 200, "ico", "no-cache"},
+		{"GET", "/assets/missing.js", 404, "not found", ""},
+		{"GET", "/api/v1/unknown", 404, "not found", ""},
+		{"POST", "/projects", 404, "not found", ""},
+		{"GET", "/api/v1/ping", 200, "pong", ""},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != tc.code || !strings.Contains(w.Body.String(), tc.body) {
+			t.Errorf("%s %s: got %d %q", tc.method, tc.path, w.Code, w.Body.String())
+		}
+		if tc.cache != "" && !strings.Contains(w.Header().Get("Cache-Control"), tc.cache) {
+			t.Errorf("%s %s: Cache-Control %q, want %q", tc.method, tc.path, w.Header().Get("Cache-Control"), tc.cache)
+		}
+	}
+}
+
+func TestEmbeddedPlaceholder(t *testing.T) {
+	if _, err := Files("").Open("index.html"); err != nil {
+		t.Fatalf("embedded index.html missing: %v", err)
+	}
+}
@@ -41,11 +41,11 @@ async function restoreState() {
 // ─────────────────────────────────────
 // 消息路由
 // ─────────────────────────────────────
-chrome.runtime.onMessage.addListener((msg: ExtMessage, _sender, sendResponse) => {
+chrome.runtime.onMessage.addListener((msg: ExtMessage, sender, sendResponse) => {
     (async () => {
         await initializationPromise;
         try {
-            const resp = await handleMessage(msg);
+            const resp = await handleMessage(msg, sender);
             sendResponse(resp);
         } catch (err) {
             console.warn('[G-Pilot] Message handler error:', err);
@@ -55,7 +55,7 @@ chrome.runtime.onMessage.addListener((msg: ExtMessage, _sender, sendResponse) =>
     return true; // 保持异步响应通道
 });
 
-async function handleMessage(msg: ExtMessage): Promise<unknown> {
+async function handleMessage(msg: ExtMessage, sender?: chrome.runtime.MessageSender): Promise<unknown> {
     switch (msg.type) {
         case 'SESSION_START': {
             const { projectId, title, targetUrl } = msg.payload as any;
@@ -140,10 +140,20 @@ async function handleMessage(msg: ExtMessage): Promise<unknown> {
             const payload = msg.payload as any;
             console.log(`[G-Pilot] Step captured: ${payload.action}, target: ${payload.target_element}`);
 
+            // 记录来源标签页与窗口，弹出窗口、新标签页中的操作据此分章节
+            const senderTab = sender?.tab;
+            if (senderTab) {
+                payload.tab_id = senderTab.id;
+                payload.window_id = senderTab.windowId;
+                payload.screenshot_width ??= senderTab.width;
+                payload.screenshot_height ??= senderTab.height;
+            }
+
             let screenshotDataURL = payload.screenshot_data_url || '';
             if (!screenshotDataURL) {
                 try {
-                    const [tab] = await chrome.tabs.query({ active: true, currentWindow: true });
+                    // 优先截取操作所在窗口（弹出窗口不一定是 currentWindow）
+                    const [tab] = senderTab ? [senderTab] : await chrome.tabs.query({ active: true, currentWindow: true });
                     if (tab?.id && tab.windowId) {
                         screenshotDataURL = await chrome.tabs.captureVisibleTab(tab.windowId, { format: 'jpeg', quality: 80 });
                     }
@@ -193,13 +203,35 @@ async function handleMessage(msg: ExtMessage): Promise<unknown> {
                 sendToActiveTab(updateMsg).catch(() => { });
                 chrome.runtime.sendMessage(updateMsg).catch(() => { });
 
-                return { stepId: stepData.data?.id, stepIndex: state.stepCount };
+                // 点击触发的接口请求通常在步骤上报之后才完成，观察窗口结束后补报
+                const stepId = stepData.data?.id;
+                if (stepId && senderTab?.id !== undefined) {
+                    scheduleNetworkReport(state.sessionId, stepId, senderTab.id, Date.now());
+                }
+
+                return { stepId, stepIndex: state.stepCount };
             } catch (e) {
                 console.error('[G-Pilot] Failed to save step:', e);
                 return { ok: false, error: String(e) };
             }
         }
 
+        case 'STEP_CONSOLE': {
+            const { stepId, entries } = msg.payload as any;
+            if (!state.sessionId || !stepId) return { ok: false };
+            try {
+                await fetch(`${API_BASE}/sessions/${state.sessionId}/steps/${stepId}/console`, {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ entries }),
+                });
+                return { ok: true };
+            } catch (e) {
+                console.warn('[G-Pilot] Failed to report console entries:', e);
+                return { ok: false, error: String(e) };
+            }
+        }
+
         case 'CAPTURE_SCREENSHOT': {
             // content script 无法直接截图，委托给 background
             try {
@@ -243,6 +275,78 @@ async function handleMessage(msg: ExtMessage): Promise<unknown> {
     }
 }
 
+// ─────────────────────────────────────
+// 网络请求记录：只记方法、URL、状态码与耗时，不读取请求头和正文；URL 由服务端脱敏
+// ─────────────────────────────────────
+const NETWORK_WINDOW_MS = 1500;
+const NETWORK_BUFFER_MAX = 200;
+
+interface NetworkRecord {
+    tabId: number;
+    method: string;
+    url: string;
+    status: number;
+    type: string;
+    startedAt: number;
+    duration_ms: number;
+}
+
+const networkStarts = new Map<string, number>();
+const networkLog: NetworkRecord[] = [];
+const networkFilter: chrome.webRequest.RequestFilter = {
+    urls: ['<all_urls>'],
+    types: ['xmlhttprequest', 'main_frame', 'sub_frame'],
+};
+
+chrome.webRequest.onBeforeRequest.addListener((details) => {
+    if (state.isRecording && !state.isPaused && details.tabId >= 0) {
+        networkStarts.set(details.requestId, details.timeStamp);
+    }
+    return undefined;
+}, networkFilter);
+
+chrome.webRequest.onCompleted.addListener((details) => {
+    const startedAt = networkStarts.get(details.requestId);
+    networkStarts.delete(details.requestId);
+    // 忽略 G-Pilot 自身的后端请求
+    if (startedAt === undefined || details.url.startsWith(API_BASE)) return;
+    networkLog.push({
+        tabId: details.tabId,
+        method: details.method,
+        url: details.url,
+        status: details.statusCode,
+        type: details.type,
+        startedAt,
+        duration_ms: Math.round(details.timeStamp - startedAt),
+    });
+    if (networkLog.length > NETWORK_BUFFER_MAX) networkLog.splice(0, networkLog.length - NETWORK_BUFFER_MAX);
+}, networkFilter);
+
+chrome.webRequest.onErrorOccurred.addListener((details) => {
+    networkStarts.delete(details.requestId);
+}, networkFilter);
+
+function scheduleNetworkReport(sessionId: string, stepId: string, tabId: number, since: number) {
+    // 截图与上报本身需要时间，向前放宽一个窗口，覆盖点击瞬间发出的请求
+    const from = since - NETWORK_WINDOW_MS;
+    setTimeout(async () => {
+        const matched = networkLog.filter(r => r.tabId === tabId && r.startedAt >= from && r.startedAt <= since + NETWORK_WINDOW_MS);
+        if (matched.length === 0) return;
+        // 已归属的请求移出缓冲区，避免连续操作时重复归到下一步
+        for (const r of matched) networkLog.splice(networkLog.indexOf(r), 1);
+        const requests = matched.map(({ method, url, status, type, duration_ms }) => ({ method, url, status, type, duration_ms }));
+        try {
+            await fetch(`${API_BASE}/sessions/${sessionId}/steps/${stepId}/requests`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ requests }),
+            });
+        } catch (e) {
+            console.warn('[G-Pilot] Failed to report step requests:', e);
+        }
+    }, NETWORK_WINDOW_MS);
+}
+
 // ─────────────────────────────────────
 // 安全发送到 content script（忽略"接收端不存在"错误）
 // ─────────────────────────────────────
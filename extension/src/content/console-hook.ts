@@ -0,0 +1,32 @@
+// 运行在页面主环境（MAIN world）：捕获目标系统的脚本错误与控制台错误/警告，
+// 通过 postMessage 转交内容脚本；只转发消息文本与来源位置，不读取页面数据
+(() => {
+    const SOURCE = 'gpilot-console';
+    const post = (level: string, message: string, source = '', line = 0) => {
+        try {
+            window.postMessage({ source: SOURCE, level, message: String(message).slice(0, 2000), src: source, line, timestamp: Date.now() }, '*');
+        } catch { /* 忽略不可序列化的内容 */ }
+    };
+    const format = (args: unknown[]) => args.map(a => {
+        if (a instanceof Error) return a.stack || a.message;
+        if (typeof a === 'object') {
+            try { return JSON.stringify(a); } catch { return String(a); }
+        }
+        return String(a);
+    }).join(' ');
+
+    for (const level of ['error', 'warn'] as const) {
+        const original = console[level];
+        console[level] = function (...args: unknown[]) {
+            post(level, format(args));
+            return original.apply(this, args);
+        };
+    }
+    window.addEventListener('error', (e) => {
+        if (e.message) post('error', e.message, e.filename, e.lineno);
+    });
+    window.addEventListener('unhandledrejection', (e) => {
+        const reason = e.reason instanceof Error ? (e.reason.stack || e.reason.message) : String(e.reason);
+        post('error', `Unhandled rejection: ${reason}`);
+    });
+})();
@@ -1,8 +1,8 @@
 // Content Script 入口 - 事件监听 + 脱敏 + 悬浮控制台
 console.log('[G-Pilot] Content script loading...');
 import './content.css';
-import { applyMaskingRules, generateDOMFingerprint, getStableSelector, getXPath } from '../shared/utils';
-import type { ActionType, MaskingRule, Session, MessageType } from '../shared/types';
+import { applyMaskingRules, generateClientStepId, generateDOMFingerprint, getFrameOffset, getFramePath, getStableSelector, getXPath, rulesForURL } from '../shared/utils';
+import type { ActionType, MaskingHit, MaskingRule, Session, MessageType } from '../shared/types';
 
 // ─────────────────────────────────────
 // 状态
@@ -13,6 +13,8 @@ let sessionId: string | null = null;
 let maskRules: MaskingRule[] = [];
 let isMarkMode = false;
 let isMinimized = false;
+// 内容脚本注入到所有 frame；悬浮控制台与页面导航只由顶层页面负责
+const isTopFrame = window === window.top;
 
 // ─────────────────────────────────────
 // 同步状态（防止刷新页面后状态丢失）
@@ -31,7 +33,7 @@ async function syncStateWithBackground() {
         updateStepCounter(state.stepCount || 0);
         updateFloatingConsoleStatus();
 
-        if (isRecording && !isPaused) {
+        if (isRecording && !isPaused && isTopFrame) {
             captureEvent('navigation', document.body, { inputValue: location.href });
         }
     }
@@ -52,7 +54,7 @@ chrome.runtime.onMessage.addListener((msg, _sender, sendResponse) => {
             maskRules = msg.payload?.maskRules ?? [];
             showFloatingConsole();
             updateStepCounter(0);
-            captureEvent('navigation', document.body, { inputValue: location.href });
+            if (isTopFrame) captureEvent('navigation', document.body, { inputValue: location.href });
             sendResponse({ ok: true });
             break;
         case 'SESSION_PAUSE':
@@ -163,12 +165,20 @@ function inferActionPurpose(name: string, action: string, tagName: string, pageT
     return `执行 ${cleanPageTitle} 的功能交互`;
 }
 
+// 事件附加信息：输入值、键盘操作的按键组合
+interface StepExtra {
+    inputValue?: string;
+    keyCombo?: string;
+}
+
 // ─────────────────────────────────────
 // 事件捕获辅助：提取高度语义化的操作说明
 // ─────────────────────────────────────
-function getElementFriendlyName(action: ActionType, el: Element, rawText: string, extra?: { inputValue?: string }): string {
+function getElementFriendlyName(action: ActionType, el: Element, rawText: string, extra?: StepExtra): string {
     const pageName = document.title || '当前页面';
     const location = getElementLocation(el);
+    const keyDesc = extra?.keyCombo ? (action === 'shortcut' ? `快捷键 ${extra.keyCombo}` : `${extra.keyCombo} 键`) : '';
+    if (keyDesc && (el === document.body || el === document.documentElement)) return `在 ${pageName} 页面按下了${keyDesc}。`;
     let targetEl = el;
     let name = rawText.trim() || el.getAttribute('aria-label') || el.getAttribute('title') || '';
 
@@ -210,9 +220,11 @@ function getElementFriendlyName(action: ActionType, el: Element, rawText: string
     } else if (finalTag === 'select') { componentType = '下拉选择器'; verb = '选择了'; }
     else if (action === 'navigation') return `在 ${pageName} 页面执行了页面导航操作，进入新业务模块，实现功能模块切换。`;
 
-    const actionDesc = verb === '在...中输入了内容'
-        ? `在功能为 ${displayName} 的 ${componentType} 中录入了业务信息`
-        : `${verb}功能为 ${displayName} 的 ${componentType}`;
+    const actionDesc = keyDesc
+        ? `在功能为 ${displayName} 的 ${componentType} 中按下了${keyDesc}`
+        : verb === '在...中输入了内容'
+            ? `在功能为 ${displayName} 的 ${componentType} 中录入了业务信息`
+            : `${verb}功能为 ${displayName} 的 ${componentType}`;
 
     return `在 ${pageName} 页面的 ${location}，${actionDesc}，实现 ${purpose}。`;
 }
@@ -220,7 +232,7 @@ function getElementFriendlyName(action: ActionType, el: Element, rawText: string
 // ─────────────────────────────────────
 // 事件捕获
 // ─────────────────────────────────────
-function captureEvent(action: ActionType, el: Element, extra?: { inputValue?: string }) {
+function captureEvent(action: ActionType, el: Element, extra?: StepExtra) {
     if (!isRecording || isPaused) return;
 
     console.log(`[G-Pilot] Capturing event: ${action}`, el);
@@ -234,8 +246,10 @@ function captureEvent(action: ActionType, el: Element, extra?: { inputValue?: st
 
     if (rawText.length > 2000) rawText = rawText.slice(0, 2000) + '...';
 
-    const maskedText = applyMaskingRules(rawText, maskRules);
-    const inputVal = extra?.inputValue ? applyMaskingRules(extra.inputValue, maskRules) : '';
+    const maskingAudit: MaskingHit[] = [];
+    const pageRules = rulesForURL(maskRules, location.href);
+    const maskedText = applyMaskingRules(rawText, pageRules, { field: 'masked_text', hits: maskingAudit });
+    const inputVal = extra?.inputValue ? applyMaskingRules(extra.inputValue, pageRules, { field: 'input_value', hits: maskingAudit }) : '';
     const stepDescription = getElementFriendlyName(action, el, rawText, extra);
 
     const step = {
@@ -246,12 +260,23 @@ function captureEvent(action: ActionType, el: Element, extra?: { inputValue?: st
         aria_label: ariaLabel,
         masked_text: maskedText,
         input_value: inputVal,
+        key_combo: extra?.keyCombo,
         page_url: location.href,
         page_title: document.title,
         timestamp: Date.now(),
         is_masked: maskedText !== rawText,
+        masking_audit: maskingAudit,
         dom_fingerprint: generateDOMFingerprint(action, ariaLabel, tagName, rawText),
-        element_rect: (action !== 'navigation' && tagName !== 'body') ? (el.getBoundingClientRect ? el.getBoundingClientRect() : null) : null,
+        client_step_id: generateClientStepId(), // 幂等键：上报重试时后端返回原记录
+        frame_path: isTopFrame ? '' : getFramePath(),
+        // 视口与滚动偏移仅在顶层页面可靠（跨域 iframe 无法读取顶层滚动位置）
+        ...(isTopFrame ? {
+            viewport_width: window.innerWidth,
+            viewport_height: window.innerHeight,
+            scroll_x: Math.round(window.scrollX),
+            scroll_y: Math.round(window.scrollY),
+        } : {}),
+        element_rect: (action !== 'navigation' && tagName !== 'body') ? getTopViewportRect(el) : null,
     };
 
     const uiElements = document.querySelectorAll('.gpilot-ui');
@@ -260,14 +285,60 @@ function captureEvent(action: ActionType, el: Element, extra?: { inputValue?: st
     setTimeout(() => {
         safeSendMessage({
             type: 'STEP_CAPTURED',
-            payload: { ...step, screenshot_width: window.innerWidth, screenshot_height: window.innerHeight },
+            // iframe 中的视口尺寸不是截图尺寸，交由 background 按标签页尺寸换算
+            payload: isTopFrame ? { ...step, screenshot_width: window.innerWidth, screenshot_height: window.innerHeight } : step,
         }).then(resp => {
             uiElements.forEach(node => (node as HTMLElement).classList.remove('gpilot-hide'));
             if (resp && resp.stepIndex !== undefined) updateStepCounter(resp.stepIndex);
+            if (resp?.stepId) scheduleConsoleReport(resp.stepId, step.timestamp);
         });
     }, 100);
 }
 
+// ─────────────────────────────────────
+// 控制台输出：由 console-hook（页面主环境）转发，按脱敏规则处理后归属到步骤
+// ─────────────────────────────────────
+const CONSOLE_WINDOW_MS = 1500;
+const CONSOLE_BUFFER_MAX = 100;
+
+interface ConsoleRecord {
+    level: string;
+    message: string;
+    source: string;
+    line: number;
+    timestamp: number;
+}
+
+const consoleLog: ConsoleRecord[] = [];
+
+window.addEventListener('message', (e) => {
+    if (e.source !== window || e.data?.source !== 'gpilot-console') return;
+    if (!isRecording || isPaused) return;
+    const { level, message, src, line, timestamp } = e.data;
+    // 过滤 G-Pilot 自身的日志
+    if (typeof message !== 'string' || message.startsWith('[G-Pilot]')) return;
+    consoleLog.push({ level, message: applyMaskingRules(message, rulesForURL(maskRules, location.href)), source: src || '', line: line || 0, timestamp });
+    if (consoleLog.length > CONSOLE_BUFFER_MAX) consoleLog.splice(0, consoleLog.length - CONSOLE_BUFFER_MAX);
+});
+
+function scheduleConsoleReport(stepId: string, since: number) {
+    setTimeout(() => {
+        const entries = consoleLog.filter(r => r.timestamp >= since && r.timestamp <= since + CONSOLE_WINDOW_MS);
+        if (entries.length === 0) return;
+        for (const r of entries) consoleLog.splice(consoleLog.indexOf(r), 1);
+        safeSendMessage({ type: 'STEP_CONSOLE', payload: { stepId, entries } });
+    }, CONSOLE_WINDOW_MS);
+}
+
+// 元素在顶层视口中的位置（截图以顶层页面为准）；跨域 iframe 中无法换算时返回 null
+function getTopViewportRect(el: Element): DOMRect | null {
+    if (!el.getBoundingClientRect) return null;
+    const rect = el.getBoundingClientRect();
+    if (isTopFrame) return rect;
+    const offset = getFrameOffset();
+    return offset ? new DOMRect(rect.x + offset.x, rect.y + offset.y, rect.width, rect.height) : null;
+}
+
 // ─────────────────────────────────────
 // 安全 sendMessage
 // ─────────────────────────────────────
@@ -457,10 +528,27 @@ document.addEventListener('change', (e) => {
     if (target.tagName === 'SELECT') captureEvent('select', target, { inputValue: target.options[target.selectedIndex]?.text });
 }, true);
 
+// 键盘操作：功能键（Enter / Esc / F1-F12）记为 keypress，带 Ctrl/Alt/Meta 的组合键记为 shortcut。
+// 普通字符输入由 input 事件负责；多行文本框中的 Enter 属于输入内容，不单独记录
+const FUNCTION_KEYS = /^(Enter|Escape|F\d{1,2})$/;
+const MODIFIER_KEYS = ['Control', 'Alt', 'Shift', 'Meta'];
+document.addEventListener('keydown', (e) => {
+    if (!isRecording || isPaused || e.repeat || MODIFIER_KEYS.includes(e.key)) return;
+    const target = e.target as Element;
+    if (target.closest('.gpilot-ui')) return;
+    const isShortcut = e.ctrlKey || e.altKey || e.metaKey;
+    if (!isShortcut && !FUNCTION_KEYS.test(e.key)) return;
+    if (!isShortcut && e.key === 'Enter' && (target.tagName === 'TEXTAREA' || (target as HTMLElement).isContentEditable)) return;
+
+    const mods = [e.ctrlKey && 'Ctrl', e.altKey && 'Alt', e.shiftKey && 'Shift', e.metaKey && 'Meta'].filter(Boolean);
+    const key = e.key === 'Escape' ? 'Esc' : e.key.length === 1 ? e.key.toUpperCase() : e.key;
+    captureEvent(isShortcut ? 'shortcut' : 'keypress', target, { keyCombo: [...mods, key].join('+') });
+}, true);
+
 let lastURL = location.href;
 const navObserver = new MutationObserver(() => {
     if (location.href !== lastURL) {
-        if (isRecording && !isPaused) captureEvent('navigation', document.body, { inputValue: location.href });
+        if (isRecording && !isPaused && isTopFrame) captureEvent('navigation', document.body, { inputValue: location.href });
         lastURL = location.href;
     }
 });
@@ -474,7 +562,7 @@ let stepCounter: HTMLElement | null = null;
 let miniStepCounter: HTMLElement | null = null;
 
 function showFloatingConsole() {
-    if (floatingConsole) return;
+    if (floatingConsole || !isTopFrame) return;
     floatingConsole = document.createElement('div');
     floatingConsole.className = `gpilot-ui gpilot-console ${isMinimized ? 'minimized' : ''}`;
     floatingConsole.innerHTML = `
@@ -179,10 +179,15 @@ export default function RecordTab({ recordingState, onStateChange }: Props) {
             eventSource.close();
         });
 
-        eventSource.onerror = () => {
+        eventSource.onerror = (e) => {
             setDocStatus('idle');
             eventSource.close();
-            setError('文档生成失败，请检查后端连接');
+            // 服务端 error 事件携带统一格式的错误体；连接中断时没有数据
+            let message = '';
+            try {
+                message = JSON.parse((e as MessageEvent).data)?.message || '';
+            } catch { }
+            setError(message ? `文档生成失败：${message}` : '文档生成失败，请检查后端连接');
         };
     };
 
@@ -1,6 +1,6 @@
 // G-Pilot 共享类型定义
 
-export type ActionType = 'click' | 'input' | 'select' | 'drag' | 'navigation' | 'scroll' | 'hover';
+export type ActionType = 'click' | 'input' | 'select' | 'drag' | 'navigation' | 'scroll' | 'hover' | 'keypress' | 'shortcut';
 export type SessionStatus = 'idle' | 'recording' | 'paused' | 'completed' | 'generating' | 'exported';
 export type MessageType =
     | 'SESSION_START'
@@ -8,6 +8,7 @@ export type MessageType =
     | 'SESSION_RESUME'
     | 'SESSION_STOP'
     | 'STEP_CAPTURED'
+    | 'STEP_CONSOLE'
     | 'CAPTURE_SCREENSHOT'
     | 'MASKING_RULE_ADD'
     | 'MASKING_APPLY_ALL'
@@ -35,7 +36,7 @@ export interface Project {
     id: string;
     name: string;
     description?: string;
-    template_type: 'business' | 'technical' | 'both';
+    template_type: string; // 文档模板 key：内置 business / technical / both / manual / training / acceptance，或自定义模板
     created_at: string;
     sessions?: Session[];
 }
@@ -65,13 +66,23 @@ export interface RecordingStep {
     aria_label?: string;
     masked_text: string;
     input_value?: string;
+    key_combo?: string; // 键盘操作的按键组合，如 Enter、Ctrl+S
     page_url: string;
     page_title: string;
+    tab_id?: number;
+    window_id?: number;
+    frame_path?: string; // 目标所在 iframe 路径，顶层页面为空
+    viewport_width?: number;
+    viewport_height?: number;
+    scroll_x?: number;
+    scroll_y?: number;
     screenshot_id?: string;
     ai_description?: string;
     is_edited: boolean;
     is_masked: boolean;
+    excluded?: boolean; // 不写入业务视图（误点、调试步骤），录制记录保留
     dom_fingerprint?: string;
+    client_step_id?: string;
     element_rect?: DOMRect;
 }
 
@@ -80,11 +91,23 @@ export interface MaskingRule {
     rule_type: 'regex' | 'exact' | 'element_click';
     pattern: string;
     alias: string;
-    scope: 'global' | 'session';
+    scope: 'global' | 'session' | 'url';
+    url_pattern?: string;             // scope 为 url 时生效的页面
+    url_pattern_type?: 'glob' | 'regex';
     is_active: boolean;
     description?: string;
 }
 
+// 脱敏审计：一条规则在某个字段上的命中次数（不含原文）
+export interface MaskingHit {
+    rule_id?: string;
+    rule_type: string;
+    alias: string;
+    category?: string;
+    field: string;
+    count: number;
+}
+
 export interface RecordingState {
     isRecording: boolean;
     isPaused: boolean;
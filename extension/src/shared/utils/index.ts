@@ -1,5 +1,15 @@
 // API 请求封装工具
 import { API_BASE } from '../types';
+import type { MaskingHit } from '../types';
+
+// 后端统一错误格式：{"error": {"code", "message", "fields"}}
+async function apiError(res: Response): Promise<Error> {
+    try {
+        const json = await res.json();
+        if (json?.error?.message) return new Error(`API error ${res.status} (${json.error.code}): ${json.error.message}`);
+    } catch { }
+    return new Error(`API error ${res.status}`);
+}
 
 export async function apiPost<T>(path: string, body: unknown): Promise<T> {
     const res = await fetch(`${API_BASE}${path}`, {
@@ -7,14 +17,14 @@ export async function apiPost<T>(path: string, body: unknown): Promise<T> {
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify(body),
     });
-    if (!res.ok) throw new Error(`API error ${res.status}: ${await res.text()}`);
+    if (!res.ok) throw await apiError(res);
     const json = await res.json();
     return json.data ?? json;
 }
 
 export async function apiGet<T>(path: string): Promise<T> {
     const res = await fetch(`${API_BASE}${path}`);
-    if (!res.ok) throw new Error(`API error ${res.status}`);
+    if (!res.ok) throw await apiError(res);
     const json = await res.json();
     return json.data ?? json;
 }
@@ -25,7 +35,7 @@ export async function apiPatch<T>(path: string, body: unknown): Promise<T> {
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify(body),
     });
-    if (!res.ok) throw new Error(`API error ${res.status}`);
+    if (!res.ok) throw await apiError(res);
     const json = await res.json();
     return json.data ?? json;
 }
@@ -34,7 +44,7 @@ export async function apiDelete<T>(path: string): Promise<T> {
     const res = await fetch(`${API_BASE}${path}`, {
         method: 'DELETE',
     });
-    if (!res.ok) throw new Error(`API error ${res.status}`);
+    if (!res.ok) throw await apiError(res);
     const json = await res.json();
     return json.data ?? json;
 }
@@ -55,6 +65,13 @@ export function generateDOMFingerprint(
     return hash.toString(36);
 }
 
+// 步骤幂等键（http 页面非安全上下文中没有 crypto.randomUUID，退回 getRandomValues）
+export function generateClientStepId(): string {
+    if (typeof crypto.randomUUID === 'function') return crypto.randomUUID();
+    const bytes = crypto.getRandomValues(new Uint8Array(16));
+    return Array.from(bytes, b => b.toString(16).padStart(2, '0')).join('');
+}
+
 // XPath 生成（稳定化处理）
 export function getXPath(el: Element): string {
     const parts: string[] = [];
@@ -82,21 +99,81 @@ export function getStableSelector(el: Element): string {
     return classes ? `${el.tagName.toLowerCase()}.${classes}` : el.tagName.toLowerCase();
 }
 
+// iframe 路径：从顶层页面到当前 frame 的 iframe 选择器（以 " > " 连接），顶层页面为空；
+// 跨域父页面无法访问时以 "iframe[src=...]" 表示当前 frame
+export function getFramePath(win: Window = window): string {
+    const parts: string[] = [];
+    let cur: Window = win;
+    while (cur !== cur.parent) {
+        let frame: Element | null = null;
+        try { frame = cur.frameElement; } catch { }
+        if (!frame) {
+            parts.unshift(`iframe[src="${cur.location.href}"]`);
+            break;
+        }
+        parts.unshift(frame.id ? `iframe#${frame.id}` : frame.getAttribute('name') ? `iframe[name="${frame.getAttribute('name')}"]` : getStableSelector(frame));
+        cur = cur.parent;
+    }
+    return parts.join(' > ');
+}
+
+// 当前 frame 左上角在顶层视口中的偏移；跨域无法计算时返回 null
+export function getFrameOffset(win: Window = window): { x: number; y: number } | null {
+    let x = 0, y = 0;
+    let cur: Window = win;
+    while (cur !== cur.parent) {
+        let frame: Element | null = null;
+        try { frame = cur.frameElement; } catch { }
+        if (!frame) return null;
+        const r = frame.getBoundingClientRect();
+        x += r.left + frame.clientLeft;
+        y += r.top + frame.clientTop;
+        cur = cur.parent;
+    }
+    return { x, y };
+}
+
+// URL 作用范围的规则只在匹配页面生效：glob 匹配整个 URL（* 匹配任意字符），regex 在 URL 中查找
+export function rulesForURL<T extends { scope?: string; url_pattern?: string; url_pattern_type?: string }>(rules: T[], url: string): T[] {
+    return rules.filter(rule => {
+        if (rule.scope !== 'url') return true;
+        if (!rule.url_pattern) return false;
+        try {
+            if (rule.url_pattern_type === 'regex') return new RegExp(rule.url_pattern).test(url);
+            const glob = rule.url_pattern.replace(/[.+^${}()|[\]\\]/g, '\\$&').replace(/\*/g, '.*').replace(/\?/g, '.');
+            return new RegExp(`^${glob}$`).test(url);
+        } catch {
+            return false;
+        }
+    });
+}
+
 // 应用脱敏规则到文本
 export function applyMaskingRules(
     text: string,
-    rules: Array<{ rule_type: string; pattern: string; alias: string; is_active: boolean }>
+    rules: Array<{ id?: string; rule_type: string; pattern: string; alias: string; is_active: boolean; description?: string }>,
+    audit?: { field: string; hits: MaskingHit[] }
 ): string {
     let result = text;
     for (const rule of rules) {
         if (!rule.is_active) continue;
+        let count = 0;
         if (rule.rule_type === 'regex') {
             try {
                 const re = new RegExp(rule.pattern, 'g');
-                result = result.replace(re, rule.alias);
+                result = result.replace(re, () => { count++; return rule.alias; });
             } catch { }
-        } else if (rule.rule_type === 'exact') {
-            result = result.split(rule.pattern).join(rule.alias);
+        } else if (rule.rule_type === 'exact' && rule.pattern) {
+            const parts = result.split(rule.pattern);
+            count = parts.length - 1;
+            result = parts.join(rule.alias);
+        }
+        // 审计只记录规则、字段与次数，不记录被替换的原文
+        if (audit && count > 0) {
+            audit.hits.push({
+                rule_id: rule.id, rule_type: rule.rule_type, alias: rule.alias,
+                category: rule.description, field: audit.field, count,
+            });
         }
     }
     return result;